@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// acrTokenUsername is the user name ACR accepts the access tokens of
+// az acr login --expose-token with.
+const acrTokenUsername = "00000000-0000-0000-0000-000000000000"
+
+// acrTokenTTL is the lifetime of ACR access tokens.
+const acrTokenTTL = "3h"
+
+// Login modes of the acr option.
+const (
+	acrModeAz               = "az"
+	acrModeServicePrincipal = "service_principal"
+)
+
+// acrHostPattern matches the hostnames of Azure Container Registry
+// registries, e.g. myregistry.azurecr.io, capturing the registry name.
+var acrHostPattern = regexp.MustCompile(`^([a-z0-9]+)\.azurecr\.(?:io|cn|us)$`)
+
+// ACRConfig selects how the plugin logs in to an Azure Container Registry.
+type ACRConfig struct {
+	// Mode is az to use the identity az is logged in with, or
+	// service_principal to log in with ClientID and ClientSecret.
+	Mode         string
+	ClientID     string
+	ClientSecret string
+}
+
+// parseACR reads the acr option, returning nil when it is not set. The
+// service principal may also come from AZURE_CLIENT_ID and
+// AZURE_CLIENT_SECRET, or the secret from client_secret_env.
+func parseACR(raw map[string]any) *ACRConfig {
+	m, ok := raw["acr"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	acr := &ACRConfig{}
+	acr.Mode, _ = m["mode"].(string)
+	acr.ClientID, _ = m["client_id"].(string)
+	acr.ClientSecret, _ = m["client_secret"].(string)
+	if env, ok := m["client_secret_env"].(string); ok && acr.ClientSecret == "" {
+		acr.ClientSecret = os.Getenv(env)
+	}
+	if acr.Mode == "" {
+		acr.Mode = acrModeAz
+		if acr.ClientID != "" {
+			acr.Mode = acrModeServicePrincipal
+		}
+	}
+	if acr.Mode == acrModeServicePrincipal {
+		if acr.ClientID == "" {
+			acr.ClientID = os.Getenv("AZURE_CLIENT_ID")
+		}
+		if acr.ClientSecret == "" {
+			acr.ClientSecret = os.Getenv("AZURE_CLIENT_SECRET")
+		}
+	}
+	return acr
+}
+
+// acrName returns the name of an ACR registry.
+func acrName(registry string) (string, bool) {
+	m := acrHostPattern.FindStringSubmatch(strings.ToLower(registry))
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// validateACR checks the acr option.
+func validateACR(cfg *Config) error {
+	if cfg.ACR == nil {
+		return nil
+	}
+	if _, ok := acrName(cfg.Registry); !ok {
+		return fmt.Errorf("acr requires an Azure Container Registry registry such as <name>.azurecr.io")
+	}
+	switch cfg.ACR.Mode {
+	case acrModeAz:
+	case acrModeServicePrincipal:
+		if cfg.ACR.ClientID == "" || cfg.ACR.ClientSecret == "" {
+			return fmt.Errorf("service_principal requires client_id and client_secret (or AZURE_CLIENT_ID and AZURE_CLIENT_SECRET)")
+		}
+	default:
+		return fmt.Errorf("mode must be %s or %s", acrModeAz, acrModeServicePrincipal)
+	}
+	return nil
+}
+
+// applyACR logs in to ACR registries configured without credentials. A
+// service principal logs in with its client id and secret; otherwise the
+// access token of az acr login --expose-token is used, which az obtains for
+// the identity it is logged in with, such as the federated identity of
+// azure/login or a managed identity after az login --identity.
+func applyACR(cfg *Config) {
+	name, ok := acrName(cfg.Registry)
+	if !ok || len(cfg.PasswordCommand) > 0 || hasStaticCredentials(cfg) {
+		return
+	}
+	if cfg.ACR != nil && cfg.ACR.Mode == acrModeServicePrincipal {
+		cfg.Username, cfg.Password = cfg.ACR.ClientID, cfg.ACR.ClientSecret
+		return
+	}
+	if cfg.Username != "" && cfg.Username != acrTokenUsername {
+		return
+	}
+	cfg.Username = acrTokenUsername
+	cfg.PasswordCommand = []string{"az", "acr", "login", "--name", name, "--expose-token", "--output", "tsv", "--query", "accessToken"}
+	if cfg.TokenTTL == "" {
+		cfg.TokenTTL = acrTokenTTL
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestApplyACR(t *testing.T) {
+	cfg := &Config{Registry: "myregistry.azurecr.io"}
+	applyACR(cfg)
+	if cfg.Username != acrTokenUsername || cfg.TokenTTL != acrTokenTTL {
+		t.Errorf("unexpected login: %q, ttl %q", cfg.Username, cfg.TokenTTL)
+	}
+	if !slices.Equal(cfg.PasswordCommand[:5], []string{"az", "acr", "login", "--name", "myregistry"}) || !slices.Contains(cfg.PasswordCommand, "--expose-token") {
+		t.Errorf("unexpected password_command: %v", cfg.PasswordCommand)
+	}
+
+	sp := &Config{Registry: "myregistry.azurecr.io", ACR: &ACRConfig{Mode: acrModeServicePrincipal, ClientID: "app-id", ClientSecret: "app-secret"}}
+	applyACR(sp)
+	if sp.Username != "app-id" || sp.Password != "app-secret" || len(sp.PasswordCommand) > 0 {
+		t.Errorf("expected a service principal login, got %q with command %v", sp.Username, sp.PasswordCommand)
+	}
+
+	other := &Config{Registry: "ghcr.io"}
+	applyACR(other)
+	if other.Username != "" {
+		t.Errorf("expected other registries to be left unchanged")
+	}
+}
+
+func TestParseACRDefaults(t *testing.T) {
+	t.Setenv("AZURE_CLIENT_ID", "env-id")
+	t.Setenv("AZURE_CLIENT_SECRET", "env-secret")
+	t.Setenv("ACR_SECRET", "named-secret")
+
+	acr := parseACR(map[string]any{"acr": map[string]any{"client_id": "app-id", "client_secret_env": "ACR_SECRET"}})
+	if acr.Mode != acrModeServicePrincipal || acr.ClientID != "app-id" || acr.ClientSecret != "named-secret" {
+		t.Errorf("unexpected service principal: %+v", acr)
+	}
+	acr = parseACR(map[string]any{"acr": map[string]any{"mode": "service_principal"}})
+	if acr.ClientID != "env-id" || acr.ClientSecret != "env-secret" {
+		t.Errorf("expected the environment service principal, got %+v", acr)
+	}
+	acr = parseACR(map[string]any{"acr": map[string]any{}})
+	if acr.Mode != acrModeAz || acr.ClientID != "" {
+		t.Errorf("expected az mode, got %+v", acr)
+	}
+}
+
+func TestValidateACR(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *Config
+		wantErr string
+	}{
+		{"az", &Config{Registry: "myregistry.azurecr.io", ACR: &ACRConfig{Mode: acrModeAz}}, ""},
+		{"service principal", &Config{Registry: "myregistry.azurecr.io", ACR: &ACRConfig{Mode: acrModeServicePrincipal, ClientID: "id", ClientSecret: "secret"}}, ""},
+		{"missing secret", &Config{Registry: "myregistry.azurecr.io", ACR: &ACRConfig{Mode: acrModeServicePrincipal, ClientID: "id"}}, "requires client_id and client_secret"},
+		{"unknown mode", &Config{Registry: "myregistry.azurecr.io", ACR: &ACRConfig{Mode: "cert"}}, "mode must be"},
+		{"other registry", &Config{Registry: "ghcr.io", ACR: &ACRConfig{Mode: acrModeAz}}, "requires an Azure Container Registry"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateACR(tt.cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
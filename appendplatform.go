@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/helpers"
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// getStringOrSlice returns a list option that may also be a single string.
+func getStringOrSlice(parser *helpers.ConfigParser, key string) []string {
+	if s := parser.GetString(key, "", ""); s != "" {
+		return []string{s}
+	}
+	return parser.GetStringSlice(key, nil)
+}
+
+// validateAppendPlatforms checks append_platform.
+func validateAppendPlatforms(cfg *Config) error {
+	if len(cfg.AppendPlatforms) == 0 {
+		return nil
+	}
+	switch {
+	case !cfg.Push:
+		return fmt.Errorf("append_platform requires push")
+	case len(cfg.IndexSources) > 0:
+		return fmt.Errorf("append_platform cannot be combined with index_sources")
+	case cfg.Daemonless:
+		return fmt.Errorf("append_platform cannot be combined with daemonless")
+	}
+	return validatePlatforms(cfg.AppendPlatforms)
+}
+
+// stagingRef returns the reference an appended platform is pushed to before
+// it joins the index, e.g. myorg/myapp:1.2.0-linux-arm64.
+func stagingRef(ref string, platforms []string) string {
+	return ref + "-" + strings.ReplaceAll(strings.Join(platforms, "-"), "/", "-")
+}
+
+// imagePlatforms returns the platforms of the image ref points at: those of
+// a multi-platform index, or the one of a single image.
+func (p *DockerPlugin) imagePlatforms(ctx context.Context, ref string) ([]string, error) {
+	out, err := p.getExecutor().Output(ctx, "docker", []string{"buildx", "imagetools", "inspect", "--format", "{{json .Image}}", ref})
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(out, &fields); err != nil || len(fields) == 0 {
+		return nil, fmt.Errorf("invalid image config of %s", ref)
+	}
+	if _, ok := fields["architecture"]; !ok {
+		return sortedKeys(fields), nil
+	}
+	var image struct {
+		OS           string `json:"os"`
+		Architecture string `json:"architecture"`
+		Variant      string `json:"variant"`
+	}
+	if err := json.Unmarshal(out, &image); err != nil {
+		return nil, fmt.Errorf("invalid image config of %s: %w", ref, err)
+	}
+	platform := image.OS + "/" + image.Architecture
+	if image.Variant != "" {
+		platform += "/" + image.Variant
+	}
+	return []string{platform}, nil
+}
+
+// appendPlatforms builds the platforms of append_platform for a release that
+// was pushed earlier without them, and adds them to the index its tags point
+// at. The platforms are pushed to a staging tag first, then merged
+// registry-side with docker buildx imagetools create --append. Tags that
+// have moved on to another release since, such as latest, are left alone.
+func (p *DockerPlugin) appendPlatforms(ctx context.Context, cfg *Config, releaseCtx plugin.ReleaseContext, imageNames []string, outputs *Outputs, warnings []string) *plugin.ExecuteResponse {
+	release := imageNames[0]
+	digest, err := p.resolveDigest(ctx, release)
+	if err != nil {
+		return outputs.response(false, "", fmt.Sprintf("failed to resolve %s; push the release before appending platforms: %v", release, err))
+	}
+	present, err := p.imagePlatforms(ctx, release)
+	if err != nil {
+		return outputs.response(false, "", fmt.Sprintf("failed to read platforms of %s: %v", release, err))
+	}
+
+	var platforms []string
+	for _, platform := range cfg.AppendPlatforms {
+		if slices.Contains(present, platform) {
+			warnings = append(warnings, fmt.Sprintf("platform %s is already in %s, not appended", platform, release))
+			continue
+		}
+		platforms = append(platforms, platform)
+	}
+	outputs.Platforms = append(slices.Clone(present), platforms...)
+	outputs.Warnings = warnings
+	if len(platforms) == 0 {
+		outputs.Digest = digest
+		return outputs.response(true, fmt.Sprintf("%s already has every platform", release), "")
+	}
+
+	var tags, targets []string
+	for i, ref := range imageNames {
+		if i > 0 {
+			if d, err := p.resolveDigest(ctx, ref); err == nil && d != digest {
+				warnings = append(warnings, fmt.Sprintf("%s no longer points at the release, not updated", ref))
+				continue
+			}
+		}
+		tags, targets = append(tags, outputs.Tags[i]), append(targets, ref)
+	}
+	outputs.Tags, outputs.Refs = tags, targets
+	outputs.Warnings = warnings
+
+	buildCfg := *cfg
+	buildCfg.Platforms = platforms
+	buildCfg.Load = false
+	if buildCfg.Builder == "" {
+		buildCfg.Builder = defaultBuilderName
+		buildCfg.autoBuilder = true
+	}
+	if err := p.ensureBuilder(ctx, &buildCfg); err != nil {
+		return outputs.response(false, "", fmt.Sprintf("failed to set up buildx builder: %v", err))
+	}
+	// The build pushes the staging tag.
+	if resp := p.gatePush(ctx, cfg, releaseCtx.Version, outputs); resp != nil {
+		return resp
+	}
+	if err := p.refreshLogin(ctx, cfg, false); err != nil {
+		return outputs.response(false, "", fmt.Sprintf("failed to refresh registry credentials: %v", err))
+	}
+
+	if resp := p.execCompatStage(ctx, cfg, execPreBuild, releaseCtx.Version, outputs); resp != nil {
+		return resp
+	}
+	staging := stagingRef(release, platforms)
+	started := time.Now()
+	err = p.dockerBuild(ctx, &buildCfg, []string{staging}, releaseCtx)
+	outputs.stage("build", started, err)
+	if err != nil {
+		return outputs.response(false, "", fmt.Sprintf("failed to build %s: %v", strings.Join(platforms, ", "), err))
+	}
+
+	started = time.Now()
+	args := []string{"buildx", "imagetools", "create", "--append"}
+	for _, target := range targets {
+		args = append(args, "--tag", target)
+	}
+	err = p.getExecutor().Run(ctx, "docker", append(args, staging), nil)
+	outputs.stage("append", started, err)
+	if err != nil {
+		return outputs.response(false, "", fmt.Sprintf("failed to append %s to %s: %v", strings.Join(platforms, ", "), release, err))
+	}
+	outputs.Pushed = true
+	outputs.PushedRefs = targets
+	if d, err := p.resolveDigest(ctx, release); err == nil {
+		outputs.Digest = d
+	}
+	if resp := p.execCompatStage(ctx, cfg, execPostPush, releaseCtx.Version, outputs); resp != nil {
+		return resp
+	}
+	return outputs.response(true, fmt.Sprintf("Appended %s to %s", strings.Join(platforms, ", "), release), "")
+}
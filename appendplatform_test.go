@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestExecuteAppendPlatform(t *testing.T) {
+	digests := map[string]string{
+		"myorg/myapp:1.2.0":  "sha256:release",
+		"myorg/myapp:latest": "sha256:newer",
+	}
+	mock := &MockCommandExecutor{
+		OutputFunc: func(ctx context.Context, name string, args []string) ([]byte, error) {
+			ref := args[len(args)-1]
+			switch {
+			case slices.Contains(args, "{{.Manifest.Digest}}"):
+				return []byte(digests[ref] + "\n"), nil
+			case slices.Contains(args, "{{json .Image}}"):
+				return []byte(`{"linux/amd64": {"architecture": "amd64", "os": "linux"}}`), nil
+			}
+			return nil, nil
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":           "myorg/myapp",
+			"append_platform": []any{"linux/amd64", "linux/arm64"},
+		},
+		Context: plugin.ReleaseContext{Version: "1.2.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+
+	var build, create []string
+	for _, call := range mock.RunCalls {
+		switch {
+		case slices.Contains(call.Args, "build"):
+			build = call.Args
+		case slices.Contains(call.Args, "create") && slices.Contains(call.Args, "imagetools"):
+			create = call.Args
+		}
+	}
+	staging := "myorg/myapp:1.2.0-linux-arm64"
+	if !slices.Contains(build, "--push") || build[slices.Index(build, "--platform")+1] != "linux/arm64" || build[slices.Index(build, "-t")+1] != staging {
+		t.Errorf("expected only linux/arm64 to be built and pushed to %s, got %v", staging, build)
+	}
+	want := []string{"buildx", "imagetools", "create", "--append", "--tag", "myorg/myapp:1.2.0", staging}
+	if !slices.Equal(create, want) {
+		t.Errorf("expected %v, got %v", want, create)
+	}
+
+	warnings, _ := resp.Outputs["warnings"].([]string)
+	for _, want := range []string{"platform linux/amd64 is already in", "myapp:latest no longer points at the release"} {
+		if !slices.ContainsFunc(warnings, func(w string) bool { return strings.Contains(w, want) }) {
+			t.Errorf("expected a warning containing %q, got %v", want, warnings)
+		}
+	}
+}
+
+func TestValidateAppendPlatforms(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{"unset", Config{}, ""},
+		{"valid", Config{Push: true, AppendPlatforms: []string{"linux/arm64/v8"}}, ""},
+		{"without push", Config{AppendPlatforms: []string{"linux/arm64"}}, "requires push"},
+		{"with index sources", Config{Push: true, AppendPlatforms: []string{"linux/arm64"}, IndexSources: []string{"{{version}}-amd64"}}, "index_sources"},
+		{"invalid platform", Config{Push: true, AppendPlatforms: []string{"arm64"}}, "arm64"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAppendPlatforms(&tt.cfg)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("validateAppendPlatforms() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// defaultApprovalTimeout bounds how long a push waits for approval.
+const defaultApprovalTimeout = time.Hour
+
+// approvalPollInterval is the delay between approval checks of the approval
+// gate and canary promotion; replaced in tests.
+var approvalPollInterval = 10 * time.Second
+
+// ApprovalConfig gates the push on an external approval, such as a chat
+// approval bot or a change management system.
+type ApprovalConfig struct {
+	// URL is polled until it grants approval; {{version}} is replaced with
+	// the release version.
+	URL string
+	// Token, when set, must be the body of the approving response, so an
+	// unrelated 200 OK page does not approve the push.
+	Token   string
+	Timeout string
+}
+
+// parseApproval reads the approval option, returning nil when it is not
+// set. The token may also come from DOCKER_APPROVAL_TOKEN.
+func parseApproval(raw map[string]any, token string) *ApprovalConfig {
+	m, ok := raw["approval"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	approval := &ApprovalConfig{Token: token}
+	approval.URL, _ = m["url"].(string)
+	approval.Timeout, _ = m["timeout"].(string)
+	if t, ok := m["token"].(string); ok && t != "" {
+		approval.Token = t
+	}
+	return approval
+}
+
+// validateApprovalURL checks that raw is an absolute http(s) URL.
+func validateApprovalURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("must be an http(s) URL")
+	}
+	return nil
+}
+
+// validateApproval checks the approval settings.
+func validateApproval(cfg *Config) error {
+	approval := cfg.Approval
+	if approval == nil {
+		return nil
+	}
+	if approval.URL == "" {
+		return fmt.Errorf("url is required")
+	}
+	if err := validateApprovalURL(approval.URL); err != nil {
+		return fmt.Errorf("url %v", err)
+	}
+	if approval.Timeout != "" {
+		if d, err := time.ParseDuration(approval.Timeout); err != nil || d <= 0 {
+			return fmt.Errorf("timeout must be a positive duration such as 2h")
+		}
+	}
+	return nil
+}
+
+// approvePush runs the approval stage once the image is built.
+func (p *DockerPlugin) approvePush(ctx context.Context, cfg *Config, version string, outputs *Outputs) error {
+	if cfg.Approval == nil {
+		return nil
+	}
+	started := time.Now()
+	err := p.awaitApproval(ctx, cfg, version)
+	outputs.stage("approval", started, err)
+	return err
+}
+
+// awaitApproval polls the approval URL until the push is approved, rejected
+// or the approval timeout passes.
+func (p *DockerPlugin) awaitApproval(ctx context.Context, cfg *Config, version string) error {
+	approval := cfg.Approval
+	timeout := defaultApprovalTimeout
+	if d, err := time.ParseDuration(approval.Timeout); err == nil && d > 0 {
+		timeout = d
+	}
+	approvalURL := strings.ReplaceAll(approval.URL, "{{version}}", url.PathEscape(version))
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for {
+		approved, err := p.checkApprovalURL(ctx, approvalURL, approval.Token)
+		if err != nil {
+			return err
+		}
+		if approved {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("push was not approved within %s", timeout)
+		case <-time.After(approvalPollInterval):
+		}
+	}
+}
+
+// checkApprovalURL reports whether approvalURL approves: it answers 200 OK
+// with token as its body, or with any body when token is empty. 403 and 410
+// reject; unreachable URLs and other statuses are treated as pending.
+func (p *DockerPlugin) checkApprovalURL(ctx context.Context, approvalURL, token string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, approvalURL, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := p.getHTTPClient().Do(req)
+	if err != nil {
+		return false, nil
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if token == "" {
+			return true, nil
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return subtle.ConstantTimeCompare(bytes.TrimSpace(body), []byte(token)) == 1, nil
+	case http.StatusForbidden, http.StatusGone:
+		return false, fmt.Errorf("rejected by %s: %s", req.URL.Redacted(), resp.Status)
+	}
+	return false, nil
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func fastApprovalPolling(t *testing.T) {
+	t.Helper()
+	orig := approvalPollInterval
+	approvalPollInterval = time.Millisecond
+	t.Cleanup(func() { approvalPollInterval = orig })
+}
+
+func TestValidateApproval(t *testing.T) {
+	tests := []struct {
+		name     string
+		approval *ApprovalConfig
+		wantErr  bool
+	}{
+		{"unset", nil, false},
+		{"url", &ApprovalConfig{URL: "https://changes.example.com/approve/{{version}}", Timeout: "4h"}, false},
+		{"missing url", &ApprovalConfig{Token: "go"}, true},
+		{"invalid url", &ApprovalConfig{URL: "changes.example.com"}, true},
+		{"invalid timeout", &ApprovalConfig{URL: "https://changes.example.com", Timeout: "-1h"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateApproval(&Config{Approval: tt.approval}); (err != nil) != tt.wantErr {
+				t.Errorf("validateApproval() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseApprovalTokenFromEnv(t *testing.T) {
+	t.Setenv("DOCKER_APPROVAL_TOKEN", "from-env")
+	p := &DockerPlugin{}
+
+	cfg := p.parseConfig(map[string]any{"image": "app", "approval": map[string]any{"url": "https://example.com"}})
+	if cfg.Approval == nil || cfg.Approval.Token != "from-env" {
+		t.Errorf("expected token from DOCKER_APPROVAL_TOKEN, got %+v", cfg.Approval)
+	}
+
+	cfg = p.parseConfig(map[string]any{"image": "app", "approval": map[string]any{"url": "https://example.com", "token": "configured"}})
+	if cfg.Approval.Token != "configured" {
+		t.Errorf("expected configured token to take precedence, got %q", cfg.Approval.Token)
+	}
+}
+
+// approvalServer answers pending until the pending-th request, then approves
+// with body. It reports the request paths it saw.
+func approvalServer(t *testing.T, pending int32, body string) (*httptest.Server, *[]string) {
+	t.Helper()
+	var requests atomic.Int32
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if requests.Add(1) <= pending {
+			w.Write([]byte("pending"))
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server, &paths
+}
+
+func TestExecuteApprovalGatesClassicPush(t *testing.T) {
+	fastApprovalPolling(t)
+	server, paths := approvalServer(t, 2, "ship-it\n")
+
+	p := &DockerPlugin{executor: &MockCommandExecutor{}, httpClient: server.Client()}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":    "myapp",
+			"approval": map[string]any{"url": server.URL + "/approve/{{version}}", "token": "ship-it"},
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+	if len(*paths) != 3 || (*paths)[0] != "/approve/1.0.0" {
+		t.Errorf("expected three polls of /approve/1.0.0, got %v", *paths)
+	}
+
+	stages, _ := resp.Outputs["stages"].([]StageStatus)
+	var names []string
+	for _, stage := range stages {
+		names = append(names, stage.Name)
+	}
+	if got := strings.Join(names, ","); got != "build,approval,push" {
+		t.Errorf("expected build, approval, then push stages, got %s", got)
+	}
+}
+
+func TestExecuteApprovalGatesBuildxPush(t *testing.T) {
+	fastApprovalPolling(t)
+	server, _ := approvalServer(t, 0, "ok")
+
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock, httpClient: server.Client()}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":    "myapp",
+			"builder":  "release",
+			"approval": map[string]any{"url": server.URL},
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+
+	var builds [][]string
+	for _, call := range mock.RunCalls {
+		if containsFlag(call.Args, "build") {
+			builds = append(builds, call.Args)
+		}
+	}
+	if len(builds) != 2 || containsFlag(builds[0], "--push") || !containsFlag(builds[1], "--push") {
+		t.Errorf("expected a non-pushing build before the pushing build, got %v", builds)
+	}
+}
+
+func TestExecuteApprovalRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer server.Close()
+
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock, httpClient: server.Client()}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":    "myapp",
+			"approval": map[string]any{"url": server.URL},
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success || !strings.Contains(resp.Error, "push not approved") {
+		t.Fatalf("expected rejected push, got %+v", resp)
+	}
+	for _, call := range mock.RunCalls {
+		if call.Args[0] == "push" {
+			t.Error("expected no push after rejection")
+		}
+	}
+}
+
+func TestExecuteApprovalTokenMismatchTimesOut(t *testing.T) {
+	fastApprovalPolling(t)
+	server, _ := approvalServer(t, 0, "approved")
+
+	p := &DockerPlugin{executor: &MockCommandExecutor{}, httpClient: server.Client()}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":    "myapp",
+			"approval": map[string]any{"url": server.URL, "token": "expected", "timeout": "20ms"},
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success || !strings.Contains(resp.Error, "not approved within 20ms") {
+		t.Fatalf("expected approval timeout, got %+v", resp)
+	}
+}
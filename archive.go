@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ArchiveResult records the immutable copy pushed to the archive registry.
+type ArchiveResult struct {
+	Ref    string `json:"ref"`
+	Digest string `json:"digest"`
+}
+
+// archiveTag returns the digest-derived tag used in the archive registry,
+// e.g. archive-4f3c... for sha256:4f3c.... Unlike cosignTag, it cannot be
+// mistaken for the cosign artifact tags nor the tags registries without the
+// referrers API attach OCI referrers under.
+func archiveTag(digest string) string {
+	_, hex, _ := strings.Cut(digest, ":")
+	return "archive-" + hex
+}
+
+// archiveRepository returns the repository receiving archive copies.
+func archiveRepository(cfg *Config) string {
+	image := cfg.ArchiveImage
+	if image == "" {
+		image = cfg.Image
+	}
+	return imageRepository(&Config{Registry: cfg.ArchiveRegistry, Image: image})
+}
+
+// pushArchive copies the pushed image ref, addressed by digest, into the archive
+// registry under a digest-named tag. The copy is registry-to-registry and
+// keeps multi-platform indexes intact.
+func (p *DockerPlugin) pushArchive(ctx context.Context, cfg *Config, ref, digest string) (*ArchiveResult, error) {
+	if digest == "" {
+		resolved, err := p.resolveDigest(ctx, ref)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve digest of %s: %w", ref, err)
+		}
+		digest = resolved
+	}
+
+	if cfg.ArchiveUsername != "" && cfg.ArchivePassword != "" {
+		if err := p.loginTo(ctx, cfg, cfg.ArchiveRegistry, cfg.ArchiveUsername, cfg.ArchivePassword); err != nil {
+			return nil, fmt.Errorf("failed to login to archive registry: %w", err)
+		}
+	}
+
+	target := fmt.Sprintf("%s:%s", archiveRepository(cfg), archiveTag(digest))
+	if err := p.retagImage(ctx, imageRepository(cfg)+"@"+digest, []string{target}); err != nil {
+		return nil, err
+	}
+	if err := p.copySignatures(ctx, cfg, imageRepository(cfg), target, digest); err != nil {
+		return nil, err
+	}
+	if err := p.signPromoted(ctx, cfg, []string{archiveRepository(cfg) + "@" + digest}); err != nil {
+		return nil, err
+	}
+
+	return &ArchiveResult{Ref: target, Digest: digest}, nil
+}
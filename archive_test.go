@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+const testDigest = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+
+func TestArchiveTag(t *testing.T) {
+	if got := archiveTag(testDigest); got != "archive-1111111111111111111111111111111111111111111111111111111111111111" {
+		t.Errorf("unexpected archive tag: %s", got)
+	}
+	if archiveTag(testDigest) == cosignTag(testDigest) {
+		t.Error("expected the archive tag to differ from the cosign tag of the digest")
+	}
+}
+
+func TestArchiveRepository(t *testing.T) {
+	cfg := &Config{Image: "myorg/myapp", ArchiveRegistry: "archive.internal"}
+	if got := archiveRepository(cfg); got != "archive.internal/myorg/myapp" {
+		t.Errorf("unexpected archive repository: %s", got)
+	}
+
+	cfg.ArchiveImage = "retention/myapp"
+	if got := archiveRepository(cfg); got != "archive.internal/retention/myapp" {
+		t.Errorf("unexpected archive repository with override: %s", got)
+	}
+}
+
+func TestPushArchive(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("uses pushed digest", func(t *testing.T) {
+		mock := &MockCommandExecutor{}
+		p := &DockerPlugin{executor: mock}
+		cfg := &Config{
+			Registry:        "ghcr.io",
+			Image:           "myorg/myapp",
+			ArchiveRegistry: "archive.internal",
+			ArchiveUsername: "archiver",
+			ArchivePassword: "secret",
+		}
+
+		result, err := p.pushArchive(ctx, cfg, "ghcr.io/myorg/myapp:1.0.0", testDigest)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.Ref != "archive.internal/myorg/myapp:"+archiveTag(testDigest) {
+			t.Errorf("unexpected archive ref: %s", result.Ref)
+		}
+
+		if len(mock.RunCalls) != 2 {
+			t.Fatalf("expected login and copy, got %d calls", len(mock.RunCalls))
+		}
+		login := mock.RunCalls[0]
+		if login.Args[0] != "login" || login.Args[1] != "archive.internal" || login.Stdin != "secret" {
+			t.Errorf("unexpected login: %+v", login)
+		}
+		copyArgs := mock.RunCalls[1].Args
+		if copyArgs[len(copyArgs)-1] != "ghcr.io/myorg/myapp@"+testDigest {
+			t.Errorf("expected copy from digest reference, got %v", copyArgs)
+		}
+	})
+
+	t.Run("resolves digest when unknown", func(t *testing.T) {
+		mock := &MockCommandExecutor{
+			OutputFunc: func(context.Context, string, []string) ([]byte, error) {
+				return []byte(testDigest + "\n"), nil
+			},
+		}
+		p := &DockerPlugin{executor: mock}
+		cfg := &Config{Image: "myorg/myapp", ArchiveRegistry: "archive.internal"}
+
+		result, err := p.pushArchive(ctx, cfg, "myorg/myapp:1.0.0", "")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.Digest != testDigest {
+			t.Errorf("expected resolved digest, got %s", result.Digest)
+		}
+	})
+}
+
+func TestArchiveInOutputs(t *testing.T) {
+	mock := &MockCommandExecutor{
+		StdoutFunc: func(_ string, args []string) string {
+			if args[0] == "push" {
+				return "1.0.0: digest: " + testDigest + " size: 1570\n"
+			}
+			return ""
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":            "myorg/myapp",
+			"tags":             []any{"{{version}}"},
+			"archive_registry": "archive.internal",
+		},
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+
+	archive, ok := resp.Outputs["archive"].(*ArchiveResult)
+	if !ok {
+		t.Fatalf("expected archive output, got %v", resp.Outputs["archive"])
+	}
+	if archive.Digest != testDigest || !strings.HasPrefix(archive.Ref, "archive.internal/myorg/myapp:archive-") {
+		t.Errorf("unexpected archive result: %+v", archive)
+	}
+}
+
+func TestValidateArchiveRegistry(t *testing.T) {
+	p := &DockerPlugin{}
+	resp, err := p.Validate(context.Background(), map[string]any{
+		"image":            "myapp",
+		"archive_registry": "http://archive",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Valid {
+		t.Error("expected invalid archive_registry to fail validation")
+	}
+}
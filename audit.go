@@ -0,0 +1,401 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// auditRedacted replaces sensitive values in audit records.
+const auditRedacted = "[REDACTED]"
+
+// sensitiveKeyPattern matches config keys and KEY=VALUE arguments whose
+// values must never reach an audit record.
+var sensitiveKeyPattern = regexp.MustCompile(`(?i)(password|passwd|token|secret|credential|private|signing_key|api_key|apikey|api-key|authorization|cookie|^pat$)`)
+
+// auditCIVariables are environment variables identifying the CI run.
+var auditCIVariables = []string{
+	"CI",
+	"GITHUB_RUN_ID",
+	"GITHUB_WORKFLOW",
+	"GITHUB_SHA",
+	"CI_PIPELINE_ID",
+	"CI_JOB_ID",
+	"BUILD_ID",
+	"BUILD_NUMBER",
+}
+
+// AuditRecord is the provenance record written to audit_file for every execution.
+type AuditRecord struct {
+	Plugin      string            `json:"plugin"`
+	Version     string            `json:"plugin_version"`
+	Hook        plugin.Hook       `json:"hook"`
+	DryRun      bool              `json:"dry_run"`
+	StartedAt   time.Time         `json:"started_at"`
+	FinishedAt  time.Time         `json:"finished_at"`
+	DurationMS  int64             `json:"duration_ms"`
+	Release     AuditRelease      `json:"release"`
+	Config      map[string]any    `json:"config"`
+	Commands    []AuditCommand    `json:"commands"`
+	Digests     map[string]string `json:"digests,omitempty"`
+	Result      AuditResult       `json:"result"`
+	Environment AuditEnvironment  `json:"environment"`
+	// KeyID is the SHA-256 fingerprint of the public key that signed the
+	// record. It names the key to verify with; it does not vouch for it.
+	KeyID string `json:"key_id,omitempty"`
+}
+
+// AuditRelease identifies the release an execution belonged to.
+type AuditRelease struct {
+	Version    string `json:"version"`
+	TagName    string `json:"tag_name,omitempty"`
+	CommitSHA  string `json:"commit_sha,omitempty"`
+	Repository string `json:"repository,omitempty"`
+}
+
+// AuditCommand records one external command run by the plugin.
+type AuditCommand struct {
+	Name       string    `json:"name"`
+	Args       []string  `json:"args"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMS int64     `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// AuditResult records the outcome of the execution.
+type AuditResult struct {
+	Success bool           `json:"success"`
+	Message string         `json:"message,omitempty"`
+	Error   string         `json:"error,omitempty"`
+	Outputs map[string]any `json:"outputs,omitempty"`
+}
+
+// AuditEnvironment fingerprints the machine that ran the execution.
+type AuditEnvironment struct {
+	OS            string            `json:"os"`
+	Arch          string            `json:"arch"`
+	GoVersion     string            `json:"go_version"`
+	Hostname      string            `json:"hostname,omitempty"`
+	DockerVersion string            `json:"docker_version,omitempty"`
+	CI            map[string]string `json:"ci,omitempty"`
+}
+
+// auditExecutor records every command run through the wrapped executor.
+type auditExecutor struct {
+	next CommandExecutor
+	// redactKeys lists build args to redact regardless of their name.
+	redactKeys []string
+
+	mu       sync.Mutex
+	commands []AuditCommand
+}
+
+func (e *auditExecutor) record(name string, args []string, started time.Time, err error) {
+	cmd := AuditCommand{
+		Name:       name,
+		Args:       redactArgs(args, e.redactKeys...),
+		StartedAt:  started.UTC(),
+		DurationMS: time.Since(started).Milliseconds(),
+	}
+	if err != nil {
+		cmd.Error = err.Error()
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.commands = append(e.commands, cmd)
+}
+
+// Run executes and records the command.
+func (e *auditExecutor) Run(ctx context.Context, name string, args []string, stdin io.Reader) error {
+	started := time.Now()
+	err := e.next.Run(ctx, name, args, stdin)
+	e.record(name, args, started, err)
+	return err
+}
+
+// RunCapture executes and records the command.
+func (e *auditExecutor) RunCapture(ctx context.Context, name string, args []string, stdin io.Reader, stdout io.Writer) error {
+	started := time.Now()
+	err := e.next.RunCapture(ctx, name, args, stdin, stdout)
+	e.record(name, args, started, err)
+	return err
+}
+
+// RunCaptureStderr executes and records the command.
+func (e *auditExecutor) RunCaptureStderr(ctx context.Context, name string, args []string, stdin io.Reader, stderr io.Writer) error {
+	started := time.Now()
+	err := e.next.RunCaptureStderr(ctx, name, args, stdin, stderr)
+	e.record(name, args, started, err)
+	return err
+}
+
+// Output executes and records the command.
+func (e *auditExecutor) Output(ctx context.Context, name string, args []string) ([]byte, error) {
+	started := time.Now()
+	out, err := e.next.Output(ctx, name, args)
+	e.record(name, args, started, err)
+	return out, err
+}
+
+// OutputInput executes and records the command.
+func (e *auditExecutor) OutputInput(ctx context.Context, name string, args []string, stdin io.Reader) ([]byte, error) {
+	started := time.Now()
+	out, err := e.next.OutputInput(ctx, name, args, stdin)
+	e.record(name, args, started, err)
+	return out, err
+}
+
+// redactConfig returns a copy of the raw config with sensitive values replaced.
+func redactConfig(raw map[string]any) map[string]any {
+	redacted := make(map[string]any, len(raw))
+	for key, value := range raw {
+		switch {
+		case sensitiveKeyPattern.MatchString(key):
+			redacted[key] = auditRedacted
+		default:
+			redacted[key] = redactValue(value)
+		}
+	}
+	return redacted
+}
+
+// redactValue redacts the maps nested in value, including those in lists
+// such as mirrors.
+func redactValue(value any) any {
+	switch v := value.(type) {
+	case string:
+		return redactArg(v, nil)
+	case map[string]any:
+		return redactConfig(v)
+	case []any:
+		items := make([]any, len(v))
+		for i, item := range v {
+			items[i] = redactValue(item)
+		}
+		return items
+	}
+	return value
+}
+
+// redactArgs replaces the value of sensitive KEY=VALUE arguments, such as
+// build args carrying tokens, and of any argument whose key is in keys.
+// The comma-separated key=value options of flags such as --cache-to are
+// redacted the same way.
+func redactArgs(args []string, keys ...string) []string {
+	redacted := make([]string, len(args))
+	for i, arg := range args {
+		redacted[i] = redactArg(arg, keys)
+	}
+	return redacted
+}
+
+// redactArg redacts a single argument for redactArgs. A value whose
+// leading key is sensitive is redacted whole, as it may contain commas;
+// otherwise each comma-separated option is checked, as in
+// --cache-from=type=s3,secret_access_key=X.
+func redactArg(arg string, keys []string) string {
+	if strings.HasPrefix(arg, "-") {
+		if flag, value, ok := strings.Cut(arg, "="); ok {
+			return flag + "=" + redactArg(value, keys)
+		}
+	}
+	sensitive := func(key string) bool {
+		return sensitiveKeyPattern.MatchString(key) || slices.Contains(keys, key)
+	}
+	key, _, ok := strings.Cut(arg, "=")
+	if !ok {
+		return arg
+	}
+	if sensitive(key) {
+		return key + "=" + auditRedacted
+	}
+	options := strings.Split(arg, ",")
+	for i, option := range options {
+		if key, _, ok := strings.Cut(option, "="); ok && sensitive(key) {
+			options[i] = key + "=" + auditRedacted
+		}
+	}
+	return strings.Join(options, ",")
+}
+
+// auditDigests collects the digests reported in execution outputs, keyed by
+// reference.
+func auditDigests(outputs map[string]any) map[string]string {
+	digests := make(map[string]string)
+	if stats, ok := outputs["push_stats"].([]*PushStats); ok {
+		for _, s := range stats {
+			if s.Digest != "" {
+				digests[s.Ref] = s.Digest
+			}
+		}
+	}
+	if archive, ok := outputs["archive"].(*ArchiveResult); ok && archive != nil {
+		digests[archive.Ref] = archive.Digest
+	}
+	if source, ok := outputs["source_digest"].(string); ok && source != "" {
+		digests["source"] = "sha256:" + source
+	}
+	if len(digests) == 0 {
+		return nil
+	}
+	return digests
+}
+
+// auditEnvironment fingerprints the current host. The docker version lookup
+// bypasses the audit executor so it does not appear among the commands.
+func (p *DockerPlugin) auditEnvironment(ctx context.Context) AuditEnvironment {
+	env := AuditEnvironment{
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		GoVersion: runtime.Version(),
+	}
+	env.Hostname, _ = os.Hostname()
+	if out, err := p.getExecutor().Output(ctx, "docker", []string{"version", "--format", "{{.Server.Version}}"}); err == nil {
+		env.DockerVersion = strings.TrimSpace(string(out))
+	}
+	for _, name := range auditCIVariables {
+		if value := os.Getenv(name); value != "" {
+			if env.CI == nil {
+				env.CI = make(map[string]string)
+			}
+			env.CI[name] = value
+		}
+	}
+	return env
+}
+
+// loadAuditSigningKey loads an Ed25519 PKCS#8 private key from PEM content
+// or from the file it names.
+func loadAuditSigningKey(value string) (ed25519.PrivateKey, error) {
+	data := []byte(value)
+	if !strings.HasPrefix(strings.TrimSpace(value), "-----BEGIN") {
+		var err error
+		if data, err = os.ReadFile(value); err != nil {
+			return nil, err
+		}
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("signing key must be an Ed25519 private key")
+	}
+	return signer, nil
+}
+
+// writeAudit writes record to cfg.AuditFile. When a signing key is
+// configured, the detached base64 Ed25519 signature of the file's exact
+// bytes is written next to it with a .sig suffix. The record names the key
+// by fingerprint only: a key carried by the record it signs would let
+// anyone re-sign an altered record, so verifiers pin the public key out of
+// band.
+func writeAudit(cfg *Config, record *AuditRecord) error {
+	var key ed25519.PrivateKey
+	if cfg.AuditSigningKey != "" {
+		var err error
+		if key, err = loadAuditSigningKey(cfg.AuditSigningKey); err != nil {
+			return fmt.Errorf("failed to load audit signing key: %w", err)
+		}
+		sum := sha256.Sum256(key.Public().(ed25519.PublicKey))
+		record.KeyID = "sha256:" + hex.EncodeToString(sum[:])
+	}
+
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if err := os.WriteFile(cfg.AuditFile, data, 0o644); err != nil {
+		return err
+	}
+
+	if key == nil {
+		return nil
+	}
+	signature, err := key.Sign(rand.Reader, data, crypto.Hash(0))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(cfg.AuditFile+".sig", []byte(base64.StdEncoding.EncodeToString(signature)+"\n"), 0o644)
+}
+
+// executeAudited runs the hook with every command recorded and writes the
+// audit record once it completes. A record that cannot be written fails the
+// execution.
+func (p *DockerPlugin) executeAudited(ctx context.Context, cfg *Config, req plugin.ExecuteRequest) (*plugin.ExecuteResponse, error) {
+	recorder := &auditExecutor{next: p.getExecutor(), redactKeys: redactedBuildArgs(cfg)}
+	audited := *p
+	audited.executor = recorder
+
+	record := &AuditRecord{
+		Plugin:    "docker",
+		Version:   p.GetInfo().Version,
+		Hook:      req.Hook,
+		DryRun:    req.DryRun,
+		StartedAt: time.Now().UTC(),
+		Release: AuditRelease{
+			Version:    req.Context.Version,
+			TagName:    req.Context.TagName,
+			CommitSHA:  req.Context.CommitSHA,
+			Repository: req.Context.RepositoryURL,
+		},
+		Config:      redactConfig(req.Config),
+		Environment: p.auditEnvironment(ctx),
+	}
+
+	resp, err := audited.execute(ctx, cfg, req)
+
+	record.FinishedAt = time.Now().UTC()
+	record.DurationMS = record.FinishedAt.Sub(record.StartedAt).Milliseconds()
+	record.Commands = recorder.commands
+	if resp != nil {
+		record.Result = AuditResult{
+			Success: resp.Success,
+			Message: resp.Message,
+			Error:   resp.Error,
+			Outputs: resp.Outputs,
+		}
+		record.Digests = auditDigests(resp.Outputs)
+	}
+	if err != nil {
+		record.Result.Error = err.Error()
+	}
+
+	if auditErr := writeAudit(cfg, record); auditErr != nil {
+		if resp == nil {
+			resp = &plugin.ExecuteResponse{}
+		}
+		if resp.Error != "" {
+			resp.Error += "; "
+		}
+		resp.Success = false
+		resp.Error += fmt.Sprintf("failed to write audit file: %v", auditErr)
+	}
+	return resp, err
+}
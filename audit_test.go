@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestRedactConfig(t *testing.T) {
+	redacted := redactConfig(map[string]any{
+		"image":    "myapp",
+		"password": "hunter2",
+		"build_args": map[string]any{
+			"NPM_TOKEN":  "abc",
+			"GO_VERSION": "1.22",
+		},
+		"registry_headers": map[string]any{
+			"Authorization":   "Bearer abc",
+			"X-Api-Key":       "abc",
+			"Cookie":          "session=abc",
+			"X-Request-Class": "release",
+		},
+	})
+
+	if redacted["image"] != "myapp" {
+		t.Errorf("expected image to be kept, got %v", redacted["image"])
+	}
+	if redacted["password"] != auditRedacted {
+		t.Errorf("expected password to be redacted, got %v", redacted["password"])
+	}
+	args := redacted["build_args"].(map[string]any)
+	if args["NPM_TOKEN"] != auditRedacted || args["GO_VERSION"] != "1.22" {
+		t.Errorf("unexpected build_args redaction: %v", args)
+	}
+	headers := redacted["registry_headers"].(map[string]any)
+	for _, name := range []string{"Authorization", "X-Api-Key", "Cookie"} {
+		if headers[name] != auditRedacted {
+			t.Errorf("expected registry header %s to be redacted, got %v", name, headers[name])
+		}
+	}
+	if headers["X-Request-Class"] != "release" {
+		t.Errorf("expected other registry headers to be kept, got %v", headers)
+	}
+	cache := redactConfig(map[string]any{
+		"cache_from":        []any{"type=s3,bucket=cache,secret_access_key=X"},
+		"extra_build_flags": []any{"--cache-to=type=s3,bucket=cache,secret_access_key=X"},
+	})
+	if from := cache["cache_from"].([]any); from[0] != "type=s3,bucket=cache,secret_access_key="+auditRedacted {
+		t.Errorf("expected the cache_from secret to be redacted, got %v", from)
+	}
+	if flags := cache["extra_build_flags"].([]any); flags[0] != "--cache-to=type=s3,bucket=cache,secret_access_key="+auditRedacted {
+		t.Errorf("expected the extra_build_flags secret to be redacted, got %v", flags)
+	}
+}
+
+func TestRedactArgs(t *testing.T) {
+	got := redactArgs([]string{
+		"build", "--build-arg", "NPM_TOKEN=abc,def", "--build-arg", "VERSION=1.0.0",
+		"--cache-from", "type=s3,region=eu-west-1,access_key_id=AKIA,secret_access_key=X,session_token=Y",
+		"--cache-to=type=gha,token=Z,mode=max",
+		"--build-arg=GITHUB_TOKEN=abc",
+	})
+	want := []string{
+		"build", "--build-arg", "NPM_TOKEN=" + auditRedacted, "--build-arg", "VERSION=1.0.0",
+		"--cache-from", "type=s3,region=eu-west-1,access_key_id=AKIA,secret_access_key=" + auditRedacted + ",session_token=" + auditRedacted,
+		"--cache-to=type=gha,token=" + auditRedacted + ",mode=max",
+		"--build-arg=GITHUB_TOKEN=" + auditRedacted,
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("redactArgs() = %v, want %v", got, want)
+	}
+}
+
+func TestExecuteWritesAudit(t *testing.T) {
+	chdir(t, t.TempDir())
+
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":      "myapp",
+			"password":   "hunter2",
+			"username":   "bot",
+			"audit_file": "audit.json",
+			"build_args": map[string]any{"NPM_TOKEN": "abc"},
+		},
+		Context: plugin.ReleaseContext{Version: "v1.0.0", CommitSHA: "abc123"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got %s", resp.Error)
+	}
+
+	data, err := os.ReadFile("audit.json")
+	if err != nil {
+		t.Fatalf("audit file not written: %v", err)
+	}
+	if strings.Contains(string(data), "hunter2") || strings.Contains(string(data), "NPM_TOKEN=abc") {
+		t.Error("audit record contains secrets")
+	}
+
+	var record AuditRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatalf("invalid audit record: %v", err)
+	}
+	if record.Release.CommitSHA != "abc123" || !record.Result.Success {
+		t.Errorf("unexpected record: %+v", record)
+	}
+	// Every command except the docker version fingerprint lookup is recorded.
+	if want := len(mock.RunCalls) + len(mock.OutputCalls) - 1; len(record.Commands) != want {
+		t.Errorf("expected %d recorded commands, got %d", want, len(record.Commands))
+	}
+	if _, err := os.Stat("audit.json.sig"); !os.IsNotExist(err) {
+		t.Error("expected no signature without a signing key")
+	}
+}
+
+func TestExecuteSignsAudit(t *testing.T) {
+	chdir(t, t.TempDir())
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join("keys", "audit.pem")
+	writeFiles(t, ".", map[string]string{keyFile: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))})
+
+	p := &DockerPlugin{executor: &MockCommandExecutor{}}
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":             "myapp",
+			"audit_file":        "audit.json",
+			"audit_signing_key": keyFile,
+		},
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+		DryRun:  true,
+	})
+	if err != nil || !resp.Success {
+		t.Fatalf("unexpected failure: %v %+v", err, resp)
+	}
+
+	data, err := os.ReadFile("audit.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := os.ReadFile("audit.json.sig")
+	if err != nil {
+		t.Fatalf("signature not written: %v", err)
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ed25519.Verify(pub, data, signature) {
+		t.Error("audit signature does not verify")
+	}
+	if strings.Contains(string(data), base64.StdEncoding.EncodeToString(pub)) {
+		t.Error("expected the record to leave the verifying public key out")
+	}
+	var record AuditRecord
+	sum := sha256.Sum256(pub)
+	if err := json.Unmarshal(data, &record); err != nil || record.KeyID != "sha256:"+hex.EncodeToString(sum[:]) {
+		t.Errorf("expected the key fingerprint in the record, got %q, %v", record.KeyID, err)
+	}
+}
+
+func TestExecuteAuditFailure(t *testing.T) {
+	chdir(t, t.TempDir())
+
+	p := &DockerPlugin{executor: &MockCommandExecutor{}}
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":             "myapp",
+			"audit_file":        "audit.json",
+			"audit_signing_key": "missing.pem",
+		},
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+		DryRun:  true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success || !strings.Contains(resp.Error, "failed to write audit file") {
+		t.Errorf("expected audit failure, got %+v", resp)
+	}
+}
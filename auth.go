@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// dockerHubPATURL is where Docker Hub personal access tokens are created.
+const dockerHubPATURL = "https://app.docker.com/settings/personal-access-tokens"
+
+// dockerHubAuthFailures are fragments of Docker Hub login errors caused by
+// rejected credentials.
+var dockerHubAuthFailures = []string{
+	"unauthorized",
+	"incorrect username or password",
+	"personal access token",
+}
+
+// loginSecret returns the secret used for docker login: the personal access
+// token when set, otherwise the password.
+func loginSecret(cfg *Config) string {
+	if cfg.PAT != "" {
+		return cfg.PAT
+	}
+	return cfg.Password
+}
+
+// hasStaticCredentials reports whether cfg carries a username and a secret.
+func hasStaticCredentials(cfg *Config) bool {
+	return cfg.Username != "" && loginSecret(cfg) != ""
+}
+
+// validateAuth checks the auth settings.
+func validateAuth(cfg *Config) error {
+	if cfg.PAT == "" {
+		return nil
+	}
+	if cfg.Username == "" {
+		return fmt.Errorf("auth.pat requires username")
+	}
+	if cfg.Password != "" {
+		return fmt.Errorf("set either password or auth.pat, not both")
+	}
+	if len(cfg.PasswordCommand) > 0 {
+		return fmt.Errorf("set either password_command or auth.pat, not both")
+	}
+	return nil
+}
+
+// isDockerHub reports whether registry is Docker Hub.
+func isDockerHub(registry string) bool {
+	return normalizeRegistry(registry) == "docker.io"
+}
+
+// explainLoginError adds actionable guidance to Docker Hub credential
+// rejections: password logins fail for accounts with two-factor
+// authentication, which must use a personal access token instead.
+func explainLoginError(cfg *Config, err error) error {
+	if !isDockerHub(cfg.Registry) {
+		return err
+	}
+	msg := strings.ToLower(err.Error())
+	rejected := false
+	for _, fragment := range dockerHubAuthFailures {
+		rejected = rejected || strings.Contains(msg, fragment)
+	}
+	if !rejected {
+		return err
+	}
+
+	if cfg.PAT != "" {
+		return fmt.Errorf("%w; Docker Hub rejected the personal access token: check that it has not expired or been revoked and has read & write scope", err)
+	}
+	return fmt.Errorf("%w; Docker Hub rejected the password. Accounts with two-factor authentication cannot log in with a password: create a personal access token at %s and set auth.pat (or DOCKER_PAT) instead", err, dockerHubPATURL)
+}
+
+// dockerLogin logs in to the configured registry with the static credentials.
+func (p *DockerPlugin) dockerLogin(ctx context.Context, cfg *Config) error {
+	if err := p.loginTo(ctx, cfg, cfg.Registry, cfg.Username, loginSecret(cfg)); err != nil {
+		return explainLoginError(cfg, err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestValidateAuth(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *Config
+		wantErr bool
+	}{
+		{"no pat", &Config{Username: "bot", Password: "secret"}, false},
+		{"pat", &Config{Username: "bot", PAT: "dckr_pat_x"}, false},
+		{"pat without username", &Config{PAT: "dckr_pat_x"}, true},
+		{"pat and password", &Config{Username: "bot", Password: "secret", PAT: "dckr_pat_x"}, true},
+		{"pat and password_command", &Config{Username: "bot", PAT: "dckr_pat_x", PasswordCommand: []string{"get-token"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateAuth(tt.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("validateAuth() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoginWithPAT(t *testing.T) {
+	t.Setenv("DOCKER_PAT", "")
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":    "myorg/myapp",
+			"username": "bot",
+			"auth":     map[string]any{"pat": "dckr_pat_abc"},
+		},
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+	})
+	if err != nil || !resp.Success {
+		t.Fatalf("unexpected failure: %v %+v", err, resp)
+	}
+	if mock.RunCalls[0].Args[0] != "login" || mock.RunCalls[0].Stdin != "dckr_pat_abc" {
+		t.Errorf("expected login with the PAT on stdin, got %+v", mock.RunCalls[0])
+	}
+}
+
+func TestDockerHubTwoFactorGuidance(t *testing.T) {
+	hubErr := errors.New("exit status 1: Error response from daemon: Get \"https://registry-1.docker.io/v2/\": unauthorized: incorrect username or password")
+
+	tests := []struct {
+		name     string
+		config   map[string]any
+		contains string
+	}{
+		{
+			name:     "password login suggests a PAT",
+			config:   map[string]any{"image": "myorg/myapp", "username": "bot", "password": "hunter2"},
+			contains: "set auth.pat",
+		},
+		{
+			name:     "rejected PAT",
+			config:   map[string]any{"image": "myorg/myapp", "username": "bot", "auth": map[string]any{"pat": "dckr_pat_abc"}},
+			contains: "expired or been revoked",
+		},
+		{
+			name:     "other registries keep the original error",
+			config:   map[string]any{"image": "myorg/myapp", "registry": "ghcr.io", "username": "bot", "password": "hunter2"},
+			contains: "incorrect username or password",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DOCKER_PAT", "")
+			p := &DockerPlugin{executor: &MockCommandExecutor{FailOnCall: 1, FailWithErr: hubErr}}
+
+			resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+				Hook:    plugin.HookPostPublish,
+				Config:  tt.config,
+				Context: plugin.ReleaseContext{Version: "v1.0.0"},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Success || !strings.Contains(resp.Error, tt.contains) {
+				t.Errorf("expected error containing %q, got %q", tt.contains, resp.Error)
+			}
+			if tt.config["registry"] != nil && strings.Contains(resp.Error, "auth.pat") {
+				t.Errorf("expected no Docker Hub guidance for other registries, got %q", resp.Error)
+			}
+		})
+	}
+}
+
+func TestStderrTail(t *testing.T) {
+	tail := &stderrTail{}
+	_, _ = tail.Write([]byte("progress\nError response from daemon: unauthorized\n"))
+
+	err := tail.wrap(errors.New("exit status 1"))
+	if err == nil || err.Error() != "exit status 1: Error response from daemon: unauthorized" {
+		t.Errorf("unexpected wrapped error: %v", err)
+	}
+	if tail.wrap(nil) != nil {
+		t.Error("expected nil error to stay nil")
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// Signature checks of base_image_trust policies.
+const (
+	baseTrustCosign = "cosign"
+	baseTrustDCT    = "dct"
+)
+
+// BaseImagePolicy is the signature check required of base images from the
+// registries matching Registry.
+type BaseImagePolicy struct {
+	// Registry is a hostname glob pattern, e.g. *.internal.example.com;
+	// docker.io covers Docker Hub images without a registry.
+	Registry string
+	// Method is cosign or dct (Docker Content Trust).
+	Method string
+	// Key verifies cosign signatures made with a key; keyless signatures
+	// are verified against CertificateIdentity and CertificateOIDCIssuer.
+	Key                   string
+	CertificateIdentity   string
+	CertificateOIDCIssuer string
+}
+
+// BaseImageResult records how a base image was verified.
+type BaseImageResult struct {
+	Image  string `json:"image"`
+	Method string `json:"method"`
+}
+
+// parseBaseImageTrust reads the base_image_trust list of policies.
+func parseBaseImageTrust(raw map[string]any) []BaseImagePolicy {
+	items, ok := raw["base_image_trust"].([]any)
+	if !ok {
+		return nil
+	}
+
+	policies := make([]BaseImagePolicy, 0, len(items))
+	for _, item := range items {
+		m, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		policy := BaseImagePolicy{}
+		policy.Registry, _ = m["registry"].(string)
+		policy.Method, _ = m["method"].(string)
+		policy.Key, _ = m["key"].(string)
+		policy.CertificateIdentity, _ = m["certificate_identity"].(string)
+		policy.CertificateOIDCIssuer, _ = m["certificate_oidc_issuer"].(string)
+		policies = append(policies, policy)
+	}
+	return policies
+}
+
+// validateBaseImageTrust validates every policy's registry pattern, method
+// and cosign identity.
+func validateBaseImageTrust(cfg *Config) error {
+	for i, policy := range cfg.BaseImageTrust {
+		if policy.Registry == "" {
+			return fmt.Errorf("policy %d: registry is required", i)
+		}
+		if err := validateAllowedRegistryPatterns([]string{policy.Registry}); err != nil {
+			return fmt.Errorf("policy %d: %v", i, err)
+		}
+		switch policy.Method {
+		case baseTrustCosign:
+			keyless := policy.CertificateIdentity != "" || policy.CertificateOIDCIssuer != ""
+			if policy.Key == "" && (policy.CertificateIdentity == "" || policy.CertificateOIDCIssuer == "") {
+				return fmt.Errorf("policy %s: cosign requires key, or certificate_identity and certificate_oidc_issuer", policy.Registry)
+			}
+			if policy.Key != "" && keyless {
+				return fmt.Errorf("policy %s: key and certificate_identity are mutually exclusive", policy.Registry)
+			}
+			if policy.Key != "" && !cosignKeyURIPattern.MatchString(policy.Key) {
+				if err := validatePath(policy.Key); err != nil {
+					return fmt.Errorf("policy %s: invalid key: %v", policy.Registry, err)
+				}
+			}
+		case baseTrustDCT:
+			if policy.Key != "" || policy.CertificateIdentity != "" || policy.CertificateOIDCIssuer != "" {
+				return fmt.Errorf("policy %s: dct does not take a key or certificate identity", policy.Registry)
+			}
+		default:
+			return fmt.Errorf("policy %s: invalid method %q: expected cosign or dct", policy.Registry, policy.Method)
+		}
+	}
+	return nil
+}
+
+// referenceRegistry returns the registry of an image reference. As in
+// docker, the first path component is a registry only when it contains a
+// dot or a port or is localhost; other references are Docker Hub images.
+func referenceRegistry(ref string) string {
+	first, _, ok := strings.Cut(ref, "/")
+	if ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		return normalizeRegistry(first)
+	}
+	return "docker.io"
+}
+
+// baseImagePolicy returns the first policy matching the registry of ref.
+func baseImagePolicy(cfg *Config, ref string) (BaseImagePolicy, bool) {
+	registry := referenceRegistry(ref)
+	for _, policy := range cfg.BaseImageTrust {
+		if ok, _ := path.Match(normalizeRegistry(policy.Registry), registry); ok {
+			return policy, true
+		}
+	}
+	return BaseImagePolicy{}, false
+}
+
+// dockerfileBases returns the base images of the configured Dockerfile, as
+// built for version.
+func dockerfileBases(cfg *Config, version string) ([]string, error) {
+	dockerfile := cfg.Dockerfile
+	if dockerfile == "" {
+		dockerfile = "Dockerfile"
+	}
+	f, err := os.Open(dockerfile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	buildArgs := map[string]string{"VERSION": version}
+	for key, value := range cfg.BuildArgs {
+		buildArgs[key] = value
+	}
+	contexts := make([]string, 0, len(cfg.Inputs))
+	for _, input := range cfg.Inputs {
+		contexts = append(contexts, input.Name)
+	}
+	return parseDockerfileBases(f, buildArgs, contexts)
+}
+
+// verifyBaseImages checks the signature of every base image before it is
+// built on. A base from a registry without a policy is treated as unsigned
+// and fails the release.
+func (p *DockerPlugin) verifyBaseImages(ctx context.Context, cfg *Config, bases []string) ([]BaseImageResult, error) {
+	if len(cfg.BaseImageTrust) == 0 {
+		return nil, nil
+	}
+	var err error
+	results := make([]BaseImageResult, 0, len(bases))
+	for _, base := range bases {
+		if strings.HasPrefix(base, "-") {
+			return nil, fmt.Errorf("invalid base image %q", base)
+		}
+		policy, ok := baseImagePolicy(cfg, base)
+		if !ok {
+			return nil, fmt.Errorf("base image %s: no base_image_trust policy for registry %s", base, referenceRegistry(base))
+		}
+		switch policy.Method {
+		case baseTrustCosign:
+			err = p.verifyCosignBase(ctx, policy, base)
+		case baseTrustDCT:
+			err = p.verifyTrustedBase(ctx, base)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("base image %s: %w", base, err)
+		}
+		results = append(results, BaseImageResult{Image: base, Method: policy.Method})
+	}
+	return results, nil
+}
+
+// verifyCosignBase verifies the cosign signature of ref with the policy's
+// key or keyless identity.
+func (p *DockerPlugin) verifyCosignBase(ctx context.Context, policy BaseImagePolicy, ref string) error {
+	args := []string{"verify"}
+	if policy.Key != "" {
+		args = append(args, "--key", policy.Key)
+	} else {
+		args = append(args, "--certificate-identity", policy.CertificateIdentity, "--certificate-oidc-issuer", policy.CertificateOIDCIssuer)
+	}
+	args = append(args, ref)
+	if _, err := p.getExecutor().Output(ctx, "cosign", args); err != nil {
+		return fmt.Errorf("cosign signature not verified: %w", err)
+	}
+	return nil
+}
+
+// trustInspection is the part of the docker trust inspect output naming the
+// signed tags of a repository.
+type trustInspection struct {
+	SignedTags []struct {
+		SignedTag string `json:"SignedTag"`
+		Digest    string `json:"Digest"`
+	} `json:"SignedTags"`
+}
+
+// verifyTrustedBase checks that the tag, or the digest, of ref is signed
+// with Docker Content Trust. Digest references are looked up among the
+// signed tags of their repository.
+func (p *DockerPlugin) verifyTrustedBase(ctx context.Context, ref string) error {
+	inspect, tag, digest := ref, "latest", ""
+	if repository, d, ok := strings.Cut(ref, "@"); ok {
+		inspect, tag, digest = repository, "", strings.TrimPrefix(d, "sha256:")
+		if i := strings.LastIndex(repository, ":"); i > strings.LastIndex(repository, "/") {
+			inspect = repository[:i]
+		}
+	} else if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		tag = ref[i+1:]
+	}
+
+	out, err := p.getExecutor().Output(ctx, "docker", []string{"trust", "inspect", inspect})
+	if err != nil {
+		return fmt.Errorf("no content trust data: %w", err)
+	}
+	var inspections []trustInspection
+	if err := json.Unmarshal(out, &inspections); err != nil {
+		return fmt.Errorf("failed to parse docker trust inspect output: %w", err)
+	}
+	for _, inspection := range inspections {
+		for _, signed := range inspection.SignedTags {
+			if (digest != "" && signed.Digest == digest) || (tag != "" && signed.SignedTag == tag) {
+				return nil
+			}
+		}
+	}
+	if digest != "" {
+		return fmt.Errorf("digest sha256:%s is not signed with content trust", digest)
+	}
+	return fmt.Errorf("tag %s is not signed with content trust", tag)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestValidateBaseImageTrust(t *testing.T) {
+	tests := []struct {
+		name     string
+		policies []BaseImagePolicy
+		wantErr  bool
+	}{
+		{"unset", nil, false},
+		{"cosign key", []BaseImagePolicy{{Registry: "registry.internal", Method: "cosign", Key: "cosign.pub"}}, false},
+		{"cosign kms key", []BaseImagePolicy{{Registry: "registry.internal", Method: "cosign", Key: "awskms://alias/base"}}, false},
+		{"cosign keyless", []BaseImagePolicy{{Registry: "cgr.dev", Method: "cosign", CertificateIdentity: "https://github.com/chainguard-images/images/.github/workflows/release.yaml@refs/heads/main", CertificateOIDCIssuer: "https://token.actions.githubusercontent.com"}}, false},
+		{"dct", []BaseImagePolicy{{Registry: "docker.io", Method: "dct"}}, false},
+		{"missing registry", []BaseImagePolicy{{Method: "dct"}}, true},
+		{"invalid registry", []BaseImagePolicy{{Registry: "docker.io/library", Method: "dct"}}, true},
+		{"unknown method", []BaseImagePolicy{{Registry: "docker.io", Method: "notary"}}, true},
+		{"cosign without identity", []BaseImagePolicy{{Registry: "cgr.dev", Method: "cosign", CertificateIdentity: "someone"}}, true},
+		{"cosign key and identity", []BaseImagePolicy{{Registry: "cgr.dev", Method: "cosign", Key: "cosign.pub", CertificateIdentity: "someone"}}, true},
+		{"cosign key outside working directory", []BaseImagePolicy{{Registry: "cgr.dev", Method: "cosign", Key: "../cosign.pub"}}, true},
+		{"dct with key", []BaseImagePolicy{{Registry: "docker.io", Method: "dct", Key: "cosign.pub"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateBaseImageTrust(&Config{BaseImageTrust: tt.policies}); (err != nil) != tt.wantErr {
+				t.Errorf("validateBaseImageTrust() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReferenceRegistry(t *testing.T) {
+	tests := map[string]string{
+		"alpine:3.19":                    "docker.io",
+		"library/alpine":                 "docker.io",
+		"index.docker.io/library/alpine": "docker.io",
+		"cgr.dev/chainguard/static":      "cgr.dev",
+		"localhost/base":                 "localhost",
+		"localhost:5000/base":            "localhost:5000",
+		"Registry.Internal/base@sha256:" + strings.Repeat("a", 64): "registry.internal",
+	}
+	for ref, want := range tests {
+		if got := referenceRegistry(ref); got != want {
+			t.Errorf("referenceRegistry(%q) = %q, want %q", ref, got, want)
+		}
+	}
+}
+
+// trustExecutor answers docker trust inspect with signed tags of alpine and
+// cosign verify for cgr.dev images only.
+func trustExecutor() *MockCommandExecutor {
+	return &MockCommandExecutor{
+		OutputFunc: func(_ context.Context, name string, args []string) ([]byte, error) {
+			switch {
+			case name == "docker" && len(args) > 1 && args[0] == "trust":
+				if repository, _, _ := strings.Cut(args[2], ":"); repository != "alpine" {
+					return []byte("[]"), errors.New("no signatures or cannot access " + args[2])
+				}
+				return []byte(`[{"Name":"alpine","SignedTags":[{"SignedTag":"3.19","Digest":"` + strings.Repeat("b", 64) + `"}]}]`), nil
+			case name == "cosign":
+				if !strings.HasPrefix(args[len(args)-1], "cgr.dev/") {
+					return nil, errors.New("no matching signatures")
+				}
+			}
+			return nil, nil
+		},
+	}
+}
+
+func TestVerifyTrustedBase(t *testing.T) {
+	p := &DockerPlugin{executor: trustExecutor()}
+	ctx := context.Background()
+
+	if err := p.verifyTrustedBase(ctx, "alpine:3.19"); err != nil {
+		t.Errorf("expected signed tag to verify, got %v", err)
+	}
+	if err := p.verifyTrustedBase(ctx, "alpine:3.19@sha256:"+strings.Repeat("b", 64)); err != nil {
+		t.Errorf("expected signed digest to verify, got %v", err)
+	}
+	if err := p.verifyTrustedBase(ctx, "alpine"); err == nil || !strings.Contains(err.Error(), "tag latest is not signed") {
+		t.Errorf("expected unsigned latest tag, got %v", err)
+	}
+	if err := p.verifyTrustedBase(ctx, "debian:12"); err == nil || !strings.Contains(err.Error(), "no content trust data") {
+		t.Errorf("expected missing trust data, got %v", err)
+	}
+}
+
+func TestExecuteVerifiesBaseImages(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+	writeFiles(t, dir, map[string]string{
+		"Dockerfile": "FROM cgr.dev/chainguard/go AS build\nFROM alpine:3.19\nCOPY --from=build /app /app\n",
+	})
+
+	mock := trustExecutor()
+	p := &DockerPlugin{executor: mock}
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image": "myapp",
+			"base_image_trust": []any{
+				map[string]any{"registry": "cgr.dev", "method": "cosign", "certificate_identity": "release@example.com", "certificate_oidc_issuer": "https://accounts.example.com"},
+				map[string]any{"registry": "docker.io", "method": "dct"},
+			},
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+	bases, _ := resp.Outputs["base_images"].([]BaseImageResult)
+	if len(bases) != 2 || bases[0] != (BaseImageResult{"cgr.dev/chainguard/go", "cosign"}) || bases[1] != (BaseImageResult{"alpine:3.19", "dct"}) {
+		t.Errorf("unexpected base_images output: %v", resp.Outputs["base_images"])
+	}
+}
+
+func TestExecuteRejectsUnsignedBaseImages(t *testing.T) {
+	tests := []struct {
+		name       string
+		dockerfile string
+		wantErr    string
+	}{
+		{"unsigned", "FROM cgr.dev/chainguard/go\nFROM debian:12\n", "base image debian:12: no content trust data"},
+		{"no policy", "FROM quay.io/app/base:1\n", "no base_image_trust policy for registry quay.io"},
+		{"cosign mismatch", "FROM ghcr.io/app/base:1\n", "cosign signature not verified"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			chdir(t, dir)
+			writeFiles(t, dir, map[string]string{"Dockerfile": tt.dockerfile})
+
+			mock := trustExecutor()
+			p := &DockerPlugin{executor: mock}
+			resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+				Hook: plugin.HookPostPublish,
+				Config: map[string]any{
+					"image": "myapp",
+					"base_image_trust": []any{
+						map[string]any{"registry": "*.dev", "method": "cosign", "key": "cosign.pub"},
+						map[string]any{"registry": "ghcr.io", "method": "cosign", "key": "cosign.pub"},
+						map[string]any{"registry": "docker.io", "method": "dct"},
+					},
+				},
+				Context: plugin.ReleaseContext{Version: "1.0.0"},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Success || !strings.Contains(resp.Error, "base image verification failed") || !strings.Contains(resp.Error, tt.wantErr) {
+				t.Fatalf("expected %q, got %+v", tt.wantErr, resp)
+			}
+			for _, call := range mock.RunCalls {
+				if containsFlag(call.Args, "build") {
+					t.Errorf("expected no build on an unverified base, got %v", call.Args)
+				}
+			}
+		})
+	}
+}
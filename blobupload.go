@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// blobChunkSize is the size of the chunks blobs are uploaded in. The
+// registry acknowledges every chunk, so an upload broken by the network
+// resumes after the last acknowledged chunk instead of starting over. ECR
+// requires chunks of at least 5 MiB.
+var blobChunkSize int64 = 8 << 20
+
+// setRetries makes the uploads of o resume up to push_retries times,
+// waiting push_retry_backoff, doubled for every further retry.
+func (o *ociPusher) setRetries(cfg *Config) {
+	o.retries = cfg.PushRetries
+	o.retryDelay = func(retry int) time.Duration { return pushRetryDelay(cfg, retry) }
+}
+
+// uploadBlob uploads the content of blob from f in chunks. A request failing
+// with a transient error is retried from the offset the registry reports
+// for the upload; an upload the registry no longer knows starts over.
+func (o *ociPusher) uploadBlob(ctx context.Context, blob ociDescriptor, f io.ReaderAt) error {
+	location, err := o.startUpload(ctx, blob)
+	if err != nil {
+		return err
+	}
+	var offset int64
+	retry := 0
+	for {
+		if offset < blob.Size {
+			end := min(offset+blobChunkSize, blob.Size)
+			location, offset, err = o.uploadChunk(ctx, blob, location, io.NewSectionReader(f, offset, end-offset), offset, end)
+		} else if err = o.finishUpload(ctx, blob, location); err == nil {
+			return nil
+		}
+		if err == nil {
+			retry = 0
+			continue
+		}
+		if retry >= o.retries || ctx.Err() != nil || !transientPushError(err) {
+			if retry > 0 {
+				return fmt.Errorf("%w (after %d retries)", err, retry)
+			}
+			return err
+		}
+		retry++
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(o.retryDelay(retry)):
+		}
+		if location, offset, err = o.resumeUpload(ctx, blob, location); err != nil {
+			return err
+		}
+	}
+}
+
+// startUpload starts an upload session for blob and returns its location.
+func (o *ociPusher) startUpload(ctx context.Context, blob ociDescriptor) (*url.URL, error) {
+	resp, err := o.do(ctx, http.MethodPost, fmt.Sprintf("%s/v2/%s/blobs/uploads/", o.client.baseURL, o.repository), nil, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	header := resp.Header.Get("Location")
+	if err := expect(resp, "start upload of "+blob.Digest, http.StatusAccepted); err != nil {
+		return nil, err
+	}
+	location, err := o.uploadLocation(header)
+	if err != nil {
+		return nil, fmt.Errorf("start upload of %s: %w", blob.Digest, err)
+	}
+	return location, nil
+}
+
+// uploadChunk sends the bytes of blob from start to end and returns the
+// location of the upload and the offset the registry acknowledged. On
+// failure it returns location and start unchanged.
+func (o *ociPusher) uploadChunk(ctx context.Context, blob ociDescriptor, location *url.URL, chunk io.Reader, start, end int64) (*url.URL, int64, error) {
+	req, err := o.request(ctx, http.MethodPatch, location.String(), o.throttle.reader(ctx, chunk), end-start, "application/octet-stream")
+	if err != nil {
+		return location, start, err
+	}
+	req.Header.Set("Content-Range", fmt.Sprintf("%d-%d", start, end-1))
+	resp, err := o.sendBlob(req)
+	if err != nil {
+		return location, start, fmt.Errorf("upload %s: %w", blob.Digest, err)
+	}
+	next, acked, err := o.uploadProgress(resp, blob, end)
+	if err != nil {
+		return location, start, err
+	}
+	return next, acked, nil
+}
+
+// resumeUpload asks the registry how much of the upload at location it
+// received. An upload it no longer knows is started again.
+func (o *ociPusher) resumeUpload(ctx context.Context, blob ociDescriptor, location *url.URL) (*url.URL, int64, error) {
+	resp, err := o.do(ctx, http.MethodGet, location.String(), nil, 0, "")
+	if err != nil {
+		return nil, 0, fmt.Errorf("resume upload of %s: %w", blob.Digest, err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		location, err := o.startUpload(ctx, blob)
+		return location, 0, err
+	}
+	if resp.StatusCode == http.StatusNoContent {
+		resp.StatusCode = http.StatusAccepted
+	}
+	return o.uploadProgress(resp, blob, 0)
+}
+
+// finishUpload completes the upload at location with the digest of blob.
+func (o *ociPusher) finishUpload(ctx context.Context, blob ociDescriptor, location *url.URL) error {
+	target := *location
+	query := target.Query()
+	query.Set("digest", blob.Digest)
+	target.RawQuery = query.Encode()
+	resp, err := o.do(ctx, http.MethodPut, target.String(), nil, 0, "")
+	if err != nil {
+		return fmt.Errorf("upload %s: %w", blob.Digest, err)
+	}
+	return expect(resp, "upload "+blob.Digest, http.StatusCreated)
+}
+
+// uploadProgress reads the location and acknowledged offset of an upload
+// from the answer to a chunk or status request. A registry that omits the
+// Range header is taken to have received the first received bytes.
+func (o *ociPusher) uploadProgress(resp *http.Response, blob ociDescriptor, received int64) (*url.URL, int64, error) {
+	header, acked := resp.Header.Get("Location"), resp.Header.Get("Range")
+	if err := expect(resp, "upload "+blob.Digest, http.StatusAccepted); err != nil {
+		return nil, 0, err
+	}
+	location, err := o.uploadLocation(header)
+	if err != nil {
+		return nil, 0, fmt.Errorf("upload %s: %w", blob.Digest, err)
+	}
+	if acked == "" {
+		return location, received, nil
+	}
+	_, last, ok := strings.Cut(strings.TrimPrefix(acked, "bytes="), "-")
+	end, err := strconv.ParseInt(last, 10, 64)
+	if !ok || err != nil || end+1 > blob.Size {
+		return nil, 0, fmt.Errorf("upload %s: invalid Range %q", blob.Digest, acked)
+	}
+	return location, end + 1, nil
+}
+
+// uploadLocation resolves the Location of an upload against the registry.
+func (o *ociPusher) uploadLocation(header string) (*url.URL, error) {
+	location, err := url.Parse(header)
+	if err != nil || header == "" {
+		return nil, fmt.Errorf("invalid upload location %q", header)
+	}
+	base, _ := url.Parse(o.client.baseURL)
+	return base.ResolveReference(location), nil
+}
+
+// blobIdleTimeout bounds how long a blob transfer may move no bytes.
+var blobIdleTimeout = registryHTTPTimeout
+
+// errBlobStalled fails a blob transfer that stopped making progress. It
+// reads as a timeout, so push_retries resumes the upload.
+var errBlobStalled = errors.New("blob transfer timed out without progress")
+
+// sendBlob sends req, which transfers a blob, without the total timeout of
+// the registry client: a large layer may take longer than
+// registryHTTPTimeout on a slow link. The transfer fails instead once
+// neither its request nor its response body moved for blobIdleTimeout.
+// Closing the response body ends the watch.
+func (o *ociPusher) sendBlob(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancelCause(req.Context())
+	timer := time.AfterFunc(blobIdleTimeout, func() { cancel(errBlobStalled) })
+	w := &idleWatch{ctx: ctx, timer: timer, cancel: cancel}
+	req = req.WithContext(ctx)
+	if req.Body != nil {
+		req.Body = &watchedBody{ReadCloser: req.Body, watch: w}
+	}
+	client := *o.client.httpClient
+	client.Timeout = 0
+	resp, err := client.Do(req)
+	if err != nil {
+		w.stop()
+		return nil, w.cause(err)
+	}
+	resp.Body = &watchedBody{ReadCloser: resp.Body, watch: w, closes: true}
+	return resp, nil
+}
+
+// idleWatch cancels a blob transfer when no bytes moved for blobIdleTimeout.
+type idleWatch struct {
+	ctx    context.Context
+	timer  *time.Timer
+	cancel context.CancelCauseFunc
+}
+
+func (w *idleWatch) progress() { w.timer.Reset(blobIdleTimeout) }
+
+func (w *idleWatch) stop() {
+	w.timer.Stop()
+	w.cancel(nil)
+}
+
+// cause returns errBlobStalled for an error caused by the watch.
+func (w *idleWatch) cause(err error) error {
+	if errors.Is(context.Cause(w.ctx), errBlobStalled) {
+		return fmt.Errorf("%w: %w", errBlobStalled, err)
+	}
+	return err
+}
+
+// watchedBody reports the bytes read from a transfer body to its watch.
+type watchedBody struct {
+	io.ReadCloser
+	watch  *idleWatch
+	closes bool
+}
+
+func (b *watchedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.watch.progress()
+	}
+	if err != nil && err != io.EOF {
+		err = b.watch.cause(err)
+	}
+	return n, err
+}
+
+func (b *watchedBody) Close() error {
+	err := b.ReadCloser.Close()
+	if b.closes {
+		b.watch.stop()
+	}
+	return err
+}
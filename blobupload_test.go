@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUploadBlobResumes(t *testing.T) {
+	chunkSize := blobChunkSize
+	blobChunkSize = 4
+	t.Cleanup(func() { blobChunkSize = chunkSize })
+
+	data := []byte("0123456789abcdef")
+	sum := sha256.Sum256(data)
+	blob := ociDescriptor{Digest: "sha256:" + hex.EncodeToString(sum[:]), Size: int64(len(data))}
+
+	tests := []struct {
+		name     string
+		retries  int
+		fail     func(drops *int) func(start int) bool
+		wantErr  string
+		received int
+	}{
+		{"chunks", 0, nil, "", len(data)},
+		{"resumes after the acknowledged chunk", 2, func(drops *int) func(int) bool {
+			return func(start int) bool {
+				if start == 8 && *drops == 0 {
+					*drops++
+					return true
+				}
+				return false
+			}
+		}, "", len(data)},
+		{"gives up after the retries", 1, func(*int) func(int) bool {
+			return func(start int) bool { return start == 8 }
+		}, "after 1 retries", 8},
+		{"fails without retries", 0, func(*int) func(int) bool {
+			return func(start int) bool { return start == 4 }
+		}, "upload " + blob.Digest, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg, server := newFakeRegistry(t)
+			drops := 0
+			if tt.fail != nil {
+				reg.failChunk = tt.fail(&drops)
+			}
+			o := &ociPusher{
+				client:        &registryClient{httpClient: server.Client(), baseURL: server.URL},
+				repository:    "myapp",
+				authorization: "Bearer push-token",
+				retries:       tt.retries,
+				retryDelay:    func(int) time.Duration { return 0 },
+			}
+			err := o.uploadBlob(context.Background(), blob, bytes.NewReader(data))
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr == "" && !bytes.Equal(reg.blobs[blob.Digest], data) {
+				t.Errorf("expected the blob to be stored, got %q", reg.blobs[blob.Digest])
+			}
+			if reg.received != tt.received {
+				t.Errorf("expected %d bytes to be received, got %d", tt.received, reg.received)
+			}
+		})
+	}
+}
+
+func TestUploadBlobRestartsForgottenUpload(t *testing.T) {
+	reg, server := newFakeRegistry(t)
+	data := []byte("layer")
+	sum := sha256.Sum256(data)
+	blob := ociDescriptor{Digest: "sha256:" + hex.EncodeToString(sum[:]), Size: int64(len(data))}
+	reg.failChunk = func(int) bool {
+		// The registry drops the broken upload.
+		if _, ok := reg.uploads["1"]; ok {
+			delete(reg.uploads, "1")
+			reg.uploads["lost"] = nil
+			return true
+		}
+		return false
+	}
+	o := &ociPusher{
+		client:        &registryClient{httpClient: server.Client(), baseURL: server.URL},
+		repository:    "myapp",
+		authorization: "Bearer push-token",
+		retries:       1,
+		retryDelay:    func(int) time.Duration { return 0 },
+	}
+	if err := o.uploadBlob(context.Background(), blob, bytes.NewReader(data)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(reg.blobs[blob.Digest], data) {
+		t.Errorf("expected the blob to be uploaded again, got %q", reg.blobs[blob.Digest])
+	}
+}
+
+func TestSendBlobBoundsByProgress(t *testing.T) {
+	idle := blobIdleTimeout
+	t.Cleanup(func() { blobIdleTimeout = idle })
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/stall" {
+			time.Sleep(300 * time.Millisecond)
+		}
+		for i := 0; i < 5; i++ {
+			w.Write([]byte("chunk"))
+			w.(http.Flusher).Flush()
+			time.Sleep(30 * time.Millisecond)
+		}
+	}))
+	t.Cleanup(server.Close)
+	client := server.Client()
+	client.Timeout = 50 * time.Millisecond
+	o := &ociPusher{client: &registryClient{httpClient: client, baseURL: server.URL}}
+
+	tests := []struct {
+		name    string
+		path    string
+		idle    time.Duration
+		wantErr bool
+	}{
+		{"outlasts the request timeout while progressing", "/slow", time.Second, false},
+		{"fails without progress", "/stall", 50 * time.Millisecond, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blobIdleTimeout = tt.idle
+			req, err := o.request(context.Background(), http.MethodGet, server.URL+tt.path, nil, 0, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := o.sendBlob(req)
+			if err == nil {
+				defer resp.Body.Close()
+				var body []byte
+				body, err = io.ReadAll(resp.Body)
+				if err == nil && string(body) != strings.Repeat("chunk", 5) {
+					t.Errorf("unexpected body %q", body)
+				}
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr && !errors.Is(err, errBlobStalled) {
+				t.Fatalf("expected the transfer to stall, got %v", err)
+			}
+			if tt.wantErr && !transientPushError(err) {
+				t.Errorf("expected a stalled transfer to be retried, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// optionalStageEstimates is the time each optional stage needs. Optional
+// stages only add information to the outputs; one is skipped when less than
+// its estimate remains of max_duration, so the time goes to the stages that
+// publish the release.
+var optionalStageEstimates = map[string]time.Duration{
+	"tag_moves":    30 * time.Second,
+	"image_config": 30 * time.Second,
+	"scorecard":    time.Minute,
+}
+
+// validateMaxDuration checks max_duration.
+func validateMaxDuration(cfg *Config) error {
+	if cfg.MaxDuration == "" {
+		return nil
+	}
+	if d, err := time.ParseDuration(cfg.MaxDuration); err != nil || d <= 0 {
+		return fmt.Errorf("max_duration must be a positive duration such as 45m")
+	}
+	return nil
+}
+
+// startBudget sets the deadline of the execution from max_duration.
+func startBudget(cfg *Config, now time.Time) {
+	if d, err := time.ParseDuration(cfg.MaxDuration); err == nil && d > 0 {
+		cfg.deadline = now.Add(d)
+	}
+}
+
+// runOptionalStage reports whether the optional stage name fits in the
+// remaining budget. A stage that does not fit is recorded as skipped.
+func (o *Outputs) runOptionalStage(cfg *Config, name string) bool {
+	if cfg.deadline.IsZero() {
+		return true
+	}
+	remaining := time.Until(cfg.deadline)
+	if remaining >= optionalStageEstimates[name] {
+		return true
+	}
+	if remaining < 0 {
+		remaining = 0
+	}
+	o.skipStage(name, fmt.Sprintf("%s left of max_duration %s", remaining.Round(time.Second), cfg.MaxDuration))
+	return false
+}
+
+// validateTimeout checks the operation timeout option key.
+func validateTimeout(key, timeout string) error {
+	if timeout == "" {
+		return nil
+	}
+	if d, err := time.ParseDuration(timeout); err != nil || d <= 0 {
+		return fmt.Errorf("%s must be a positive duration such as 10m", key)
+	}
+	return nil
+}
+
+// withTimeout derives the context of one operation, bounded by timeout when
+// it is set, so a hung daemon or registry fails the operation instead of
+// blocking the release.
+func withTimeout(ctx context.Context, timeout string) (context.Context, context.CancelFunc) {
+	if d, err := time.ParseDuration(timeout); err == nil && d > 0 {
+		return context.WithTimeout(ctx, d)
+	}
+	return context.WithCancel(ctx)
+}
+
+// timeoutError names the option when err was caused by the operation
+// context of withTimeout running out, rather than by the execution being
+// cancelled.
+func timeoutError(ctx, opCtx context.Context, err error, option, timeout string) error {
+	if err == nil || ctx.Err() != nil || !errors.Is(opCtx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	return fmt.Errorf("timed out after %s (%s): %w", timeout, option, err)
+}
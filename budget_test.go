@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestValidateMaxDuration(t *testing.T) {
+	for value, wantErr := range map[string]bool{"": false, "45m": false, "0s": true, "-1m": true, "soon": true} {
+		if err := validateMaxDuration(&Config{MaxDuration: value}); (err != nil) != wantErr {
+			t.Errorf("validateMaxDuration(%q) error = %v, wantErr %v", value, err, wantErr)
+		}
+	}
+}
+
+func TestRunOptionalStage(t *testing.T) {
+	outputs := &Outputs{}
+	if !outputs.runOptionalStage(&Config{}, "scorecard") {
+		t.Error("expected optional stages to run without max_duration")
+	}
+
+	cfg := &Config{MaxDuration: "10m"}
+	startBudget(cfg, time.Now())
+	if !outputs.runOptionalStage(cfg, "scorecard") {
+		t.Error("expected optional stage to fit in the budget")
+	}
+
+	startBudget(cfg, time.Now().Add(-9*time.Minute-20*time.Second))
+	if outputs.runOptionalStage(cfg, "scorecard") {
+		t.Error("expected optional stage to be skipped")
+	}
+	if !outputs.runOptionalStage(cfg, "image_config") {
+		t.Error("expected a shorter optional stage to fit in the remaining budget")
+	}
+	if len(outputs.Stages) != 1 || outputs.Stages[0].Status != stageSkipped || !strings.Contains(outputs.Stages[0].Reason, "s left of max_duration 10m") {
+		t.Errorf("unexpected stages: %+v", outputs.Stages)
+	}
+}
+
+func TestExecuteSkipsOptionalStagesOverBudget(t *testing.T) {
+	mock := &MockCommandExecutor{OutputFunc: canaryDigests}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"image": "myapp", "max_duration": "1ns"},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success || resp.Outputs["pushed"] != true {
+		t.Fatalf("expected the release to be pushed, got %+v", resp)
+	}
+
+	stages, _ := resp.Outputs["stages"].([]StageStatus)
+	var skipped []string
+	for _, stage := range stages {
+		if stage.Status == stageSkipped {
+			skipped = append(skipped, stage.Name)
+		}
+	}
+	if got := strings.Join(skipped, ","); got != "tag_moves,image_config" {
+		t.Errorf("expected tag_moves and image_config to be skipped, got %s", got)
+	}
+	if _, ok := resp.Outputs["previous_digests"]; ok {
+		t.Error("expected no tag moves to be recorded")
+	}
+}
+
+func TestValidateTimeouts(t *testing.T) {
+	if resp := runValidators(&Config{BuildTimeout: "30m", PushTimeout: "10m", LoginTimeout: "1m"}, timeoutValidators); resp != nil {
+		t.Errorf("unexpected error: %s", resp.Error)
+	}
+	if resp := runValidators(&Config{PushTimeout: "0s"}, timeoutValidators); resp == nil || !strings.Contains(resp.Error, "push_timeout") {
+		t.Errorf("expected push_timeout to be invalid, got %v", resp)
+	}
+
+	p := &DockerPlugin{}
+	resp, err := p.Validate(context.Background(), map[string]any{"image": "myapp", "login_timeout": "soon"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Valid {
+		t.Error("expected an invalid login_timeout to be reported")
+	}
+}
+
+// hangingExecutor blocks the calls matching hang until their context ends.
+func hangingExecutor(hang func(args []string) bool) *MockCommandExecutor {
+	return &MockCommandExecutor{
+		RunFunc: func(ctx context.Context, _ string, args []string, _ io.Reader) error {
+			if hang(args) {
+				<-ctx.Done()
+				return ctx.Err()
+			}
+			return nil
+		},
+	}
+}
+
+func TestExecuteOperationTimeouts(t *testing.T) {
+	tests := []struct {
+		option  string
+		config  map[string]any
+		hang    func(args []string) bool
+		wantErr string
+	}{
+		{
+			option:  "build_timeout",
+			config:  map[string]any{"image": "myapp"},
+			hang:    func(args []string) bool { return args[0] == "build" },
+			wantErr: "failed to build image: timed out after 20ms (build_timeout)",
+		},
+		{
+			option:  "push_timeout",
+			config:  map[string]any{"image": "myapp", "tags": []any{"1.0.0"}},
+			hang:    func(args []string) bool { return args[0] == "push" },
+			wantErr: "myapp:1.0.0: timed out after 20ms (push_timeout)",
+		},
+		{
+			option:  "login_timeout",
+			config:  map[string]any{"image": "myapp", "username": "user", "password": "pass"},
+			hang:    func(args []string) bool { return args[0] == "login" },
+			wantErr: "timed out after 20ms (login_timeout)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.option, func(t *testing.T) {
+			tt.config[tt.option] = "20ms"
+			p := &DockerPlugin{executor: hangingExecutor(tt.hang)}
+			resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+				Hook:    plugin.HookPostPublish,
+				Config:  tt.config,
+				Context: plugin.ReleaseContext{Version: "1.0.0"},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Success || !strings.Contains(resp.Error, tt.wantErr) {
+				t.Errorf("expected %q, got %+v", tt.wantErr, resp)
+			}
+		})
+	}
+}
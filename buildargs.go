@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// BuildArgSource is a build arg whose value is resolved at execution time
+// instead of being inlined in the release config.
+type BuildArgSource struct {
+	FromEnv  string
+	FromFile string
+	// Redact keeps the resolved value out of audit records.
+	Redact bool
+}
+
+// parseBuildArgSources extracts the build_args entries given as
+// {from_env: ...} or {from_file: ...} objects.
+func parseBuildArgSources(raw map[string]any) map[string]BuildArgSource {
+	return parseSources(raw, "build_args")
+}
+
+// parseSources extracts the {from_env, from_file, redact} objects in the
+// raw[key] map; other values are ignored.
+func parseSources(raw map[string]any, key string) map[string]BuildArgSource {
+	entries, ok := raw[key].(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	sources := make(map[string]BuildArgSource)
+	for key, value := range entries {
+		m, ok := value.(map[string]any)
+		if !ok {
+			continue
+		}
+		source := BuildArgSource{}
+		source.FromEnv, _ = m["from_env"].(string)
+		source.FromFile, _ = m["from_file"].(string)
+		source.Redact, _ = m["redact"].(bool)
+		sources[key] = source
+	}
+	if len(sources) == 0 {
+		return nil
+	}
+	return sources
+}
+
+// validateBuildArgSources checks that every source names exactly one of an
+// environment variable or a file inside the working directory.
+func validateBuildArgSources(sources map[string]BuildArgSource) error {
+	for _, key := range sortedKeys(sources) {
+		source := sources[key]
+		if (source.FromEnv == "") == (source.FromFile == "") {
+			return fmt.Errorf("build arg %s must set exactly one of from_env or from_file", key)
+		}
+		if source.FromEnv != "" && !buildArgKeyPattern.MatchString(source.FromEnv) {
+			return fmt.Errorf("build arg %s: invalid environment variable name %q", key, source.FromEnv)
+		}
+		if err := validatePath(source.FromFile); err != nil {
+			return fmt.Errorf("build arg %s: %v", key, err)
+		}
+	}
+	return nil
+}
+
+// resolveBuildArgSources reads every sourced build arg into cfg.BuildArgs.
+// Values read from files have surrounding whitespace trimmed so a trailing
+// newline in e.g. .version does not end up in the value.
+func resolveBuildArgSources(cfg *Config) error {
+	if len(cfg.BuildArgSources) == 0 {
+		return nil
+	}
+	if cfg.BuildArgs == nil {
+		cfg.BuildArgs = make(map[string]string)
+	}
+
+	for _, key := range sortedKeys(cfg.BuildArgSources) {
+		source := cfg.BuildArgSources[key]
+		switch {
+		case source.FromEnv != "":
+			value, ok := os.LookupEnv(source.FromEnv)
+			if !ok {
+				return fmt.Errorf("build arg %s: environment variable %s is not set", key, source.FromEnv)
+			}
+			cfg.BuildArgs[key] = value
+		case source.FromFile != "":
+			data, err := os.ReadFile(source.FromFile)
+			if err != nil {
+				return fmt.Errorf("build arg %s: %w", key, err)
+			}
+			cfg.BuildArgs[key] = strings.TrimSpace(string(data))
+		}
+	}
+	return nil
+}
+
+// redactedBuildArgs returns the build args flagged for redaction.
+func redactedBuildArgs(cfg *Config) []string {
+	var keys []string
+	for key, source := range cfg.BuildArgSources {
+		if source.Redact {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestParseBuildArgSources(t *testing.T) {
+	sources := parseBuildArgSources(map[string]any{
+		"build_args": map[string]any{
+			"GO_VERSION": "1.22",
+			"NPM_TOKEN":  map[string]any{"from_env": "NPM_TOKEN", "redact": true},
+			"VERSION":    map[string]any{"from_file": ".version"},
+		},
+	})
+
+	if len(sources) != 2 {
+		t.Fatalf("expected 2 sources, got %v", sources)
+	}
+	if got := sources["NPM_TOKEN"]; got.FromEnv != "NPM_TOKEN" || !got.Redact {
+		t.Errorf("unexpected NPM_TOKEN source: %+v", got)
+	}
+	if got := sources["VERSION"]; got.FromFile != ".version" || got.Redact {
+		t.Errorf("unexpected VERSION source: %+v", got)
+	}
+}
+
+func TestValidateBuildArgSources(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  BuildArgSource
+		wantErr bool
+	}{
+		{"env", BuildArgSource{FromEnv: "NPM_TOKEN"}, false},
+		{"file", BuildArgSource{FromFile: ".version"}, false},
+		{"neither", BuildArgSource{}, true},
+		{"both", BuildArgSource{FromEnv: "A", FromFile: "b"}, true},
+		{"invalid env name", BuildArgSource{FromEnv: "A;B"}, true},
+		{"file outside working directory", BuildArgSource{FromFile: "../secret"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateBuildArgSources(map[string]BuildArgSource{"ARG": tt.source})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateBuildArgSources() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResolveBuildArgSources(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+	writeFiles(t, dir, map[string]string{".version": "2.3.4\n"})
+	t.Setenv("NPM_TOKEN", "s3cret")
+
+	cfg := &Config{BuildArgSources: map[string]BuildArgSource{
+		"NPM_TOKEN": {FromEnv: "NPM_TOKEN"},
+		"VERSION":   {FromFile: ".version"},
+	}}
+	if err := resolveBuildArgSources(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.BuildArgs["NPM_TOKEN"] != "s3cret" || cfg.BuildArgs["VERSION"] != "2.3.4" {
+		t.Errorf("unexpected build args: %v", cfg.BuildArgs)
+	}
+}
+
+func TestResolveBuildArgSourcesMissingEnv(t *testing.T) {
+	cfg := &Config{BuildArgSources: map[string]BuildArgSource{
+		"NPM_TOKEN": {FromEnv: "RELICTA_TEST_UNSET_TOKEN"},
+	}}
+	err := resolveBuildArgSources(cfg)
+	if err == nil || !strings.Contains(err.Error(), "RELICTA_TEST_UNSET_TOKEN is not set") {
+		t.Errorf("expected unset variable error, got %v", err)
+	}
+}
+
+func TestExecuteResolvesBuildArgSources(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+	writeFiles(t, dir, map[string]string{".version": "2.3.4\n"})
+	t.Setenv("NPM_TOKEN", "s3cret")
+
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":      "myapp",
+			"push":       false,
+			"audit_file": "audit.json",
+			"build_args": map[string]any{
+				"NPM_TOKEN": map[string]any{"from_env": "NPM_TOKEN"},
+				"APP_BUILD": map[string]any{"from_file": ".version", "redact": true},
+			},
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+
+	build := mock.RunCalls[0].Args
+	if !containsArg(build, "--build-arg", "NPM_TOKEN=s3cret") || !containsArg(build, "--build-arg", "APP_BUILD=2.3.4") {
+		t.Errorf("expected resolved build args, got %v", build)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "audit.json"))
+	if err != nil {
+		t.Fatalf("failed to read audit file: %v", err)
+	}
+	if strings.Contains(string(data), "s3cret") || strings.Contains(string(data), "2.3.4") {
+		t.Errorf("expected sourced build args to be redacted, got %s", data)
+	}
+}
+
+func TestValidateBuildArgSourcesConfig(t *testing.T) {
+	p := &DockerPlugin{}
+	resp, err := p.Validate(context.Background(), map[string]any{
+		"image": "myapp",
+		"build_args": map[string]any{
+			"VERSION": map[string]any{"from_file": "../version"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Valid {
+		t.Error("expected validation to fail for a from_file outside the working directory")
+	}
+}
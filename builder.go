@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// defaultBuilderName is used for the buildx builder created from builder_nodes
+// when no builder name is configured.
+const defaultBuilderName = "relicta-docker"
+
+var (
+	// Platform pattern: os/arch[/variant]
+	platformPattern = regexp.MustCompile(`^[a-z0-9]+/[a-z0-9_]+(/[a-z0-9]+)?$`)
+
+	// Builder endpoint pattern: scheme://address (tcp, ssh, unix, docker-container, ...)
+	// or a docker context name.
+	builderEndpointPattern = regexp.MustCompile(`^([a-z][a-z0-9+.-]*://[^\s;|&$<>'"\x60]+|[a-zA-Z0-9][a-zA-Z0-9_.-]*)$`)
+
+	// Buildx driver pattern
+	builderDriverPattern = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+)
+
+// BuilderNode describes one node of a multi-node buildx builder.
+type BuilderNode struct {
+	Name      string
+	Endpoint  string
+	Platforms []string
+}
+
+// validatePlatform validates a target platform such as linux/arm64/v8.
+func validatePlatform(platform string) error {
+	if !platformPattern.MatchString(platform) {
+		return fmt.Errorf("invalid platform %q: expected os/arch[/variant]", platform)
+	}
+	return nil
+}
+
+// validateBuilderNodes validates the builder_nodes configuration.
+func validateBuilderNodes(nodes []BuilderNode, builder, driver string) error {
+	if driver != "" && !builderDriverPattern.MatchString(driver) {
+		return fmt.Errorf("invalid builder driver %q", driver)
+	}
+
+	seen := make(map[string]bool)
+	for i, node := range nodes {
+		if node.Endpoint == "" {
+			return fmt.Errorf("node %d: endpoint is required", i)
+		}
+		if !builderEndpointPattern.MatchString(node.Endpoint) {
+			return fmt.Errorf("node %d: invalid endpoint %q", i, node.Endpoint)
+		}
+		if err := validateBuilderName(node.Name); err != nil {
+			return fmt.Errorf("node %d: %v", i, err)
+		}
+		if len(node.Platforms) == 0 {
+			return fmt.Errorf("node %d: at least one platform is required", i)
+		}
+		for _, platform := range node.Platforms {
+			if err := validatePlatform(platform); err != nil {
+				return fmt.Errorf("node %d: %v", i, err)
+			}
+		}
+		name := nodeName(node, builder, i)
+		if seen[name] {
+			return fmt.Errorf("node %d: duplicate node name %q", i, name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// parseBuilderNodes reads the builder_nodes list from raw configuration.
+func parseBuilderNodes(raw map[string]any) []BuilderNode {
+	items, ok := raw["builder_nodes"].([]any)
+	if !ok {
+		return nil
+	}
+
+	nodes := make([]BuilderNode, 0, len(items))
+	for _, item := range items {
+		m, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		node := BuilderNode{}
+		node.Name, _ = m["name"].(string)
+		node.Endpoint, _ = m["endpoint"].(string)
+		if platforms, ok := m["platforms"].([]any); ok {
+			for _, platform := range platforms {
+				if s, ok := platform.(string); ok {
+					node.Platforms = append(node.Platforms, s)
+				}
+			}
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// nodeName returns the buildx node name for the i-th configured node.
+func nodeName(node BuilderNode, builder string, i int) string {
+	if node.Name != "" {
+		return node.Name
+	}
+	return fmt.Sprintf("%s%d", builder, i)
+}
+
+// builderNodePlatforms returns every platform handled natively by a configured node.
+func builderNodePlatforms(nodes []BuilderNode) []string {
+	var platforms []string
+	for _, node := range nodes {
+		platforms = append(platforms, node.Platforms...)
+	}
+	return platforms
+}
+
+// ensureBuilder creates or updates the multi-node buildx builder described by
+// builder_nodes. Existing nodes with the same name are updated in place, so
+// re-running a release picks up endpoint or platform changes.
+func (p *DockerPlugin) ensureBuilder(ctx context.Context, cfg *Config) error {
+	if len(cfg.BuilderNodes) == 0 {
+		if cfg.autoBuilder {
+			return p.ensureLocalBuilder(ctx, cfg)
+		}
+		return nil
+	}
+
+	_, err := p.getExecutor().Output(ctx, "docker", []string{"buildx", "inspect", cfg.Builder})
+	exists := err == nil
+
+	// buildx create copies the buildkitd config into the node, so the file
+	// is only needed until the nodes are configured.
+	var buildkitdConfig string
+	if cfg.MaxParallelism > 0 {
+		if buildkitdConfig, err = writeBuildkitdConfig(cfg); err != nil {
+			return err
+		}
+		defer os.Remove(buildkitdConfig)
+	}
+
+	for i, node := range cfg.BuilderNodes {
+		args := []string{"buildx", "create", "--name", cfg.Builder, "--node", nodeName(node, cfg.Builder, i)}
+		if exists || i > 0 {
+			args = append(args, "--append")
+		}
+		if cfg.BuilderDriver != "" {
+			args = append(args, "--driver", cfg.BuilderDriver)
+		}
+		if flags := entitlementBuildkitdFlags(cfg); flags != "" {
+			args = append(args, "--buildkitd-flags", flags)
+		}
+		if buildkitdConfig != "" {
+			args = append(args, "--config", buildkitdConfig)
+		}
+		args = append(args, "--platform", strings.Join(node.Platforms, ","), node.Endpoint)
+
+		if err := p.getExecutor().Run(ctx, "docker", args, nil); err != nil {
+			return fmt.Errorf("failed to configure node %s: %w", node.Endpoint, err)
+		}
+	}
+
+	return p.getExecutor().Run(ctx, "docker", []string{"buildx", "inspect", "--bootstrap", cfg.Builder}, nil)
+}
+
+// ensureLocalBuilder creates the builder of a multi-platform build unless it
+// exists. The default docker driver cannot build several platforms at once,
+// so the builder runs BuildKit in a container on the local daemon; platforms
+// the daemon cannot run natively are emulated.
+func (p *DockerPlugin) ensureLocalBuilder(ctx context.Context, cfg *Config) error {
+	if _, err := p.getExecutor().Output(ctx, "docker", []string{"buildx", "inspect", cfg.Builder}); err == nil {
+		return nil
+	}
+
+	args := []string{"buildx", "create", "--name", cfg.Builder, "--driver", "docker-container"}
+	if flags := entitlementBuildkitdFlags(cfg); flags != "" {
+		args = append(args, "--buildkitd-flags", flags)
+	}
+	if err := p.getExecutor().Run(ctx, "docker", args, nil); err != nil {
+		return fmt.Errorf("failed to create builder %s: %w", cfg.Builder, err)
+	}
+	return p.getExecutor().Run(ctx, "docker", []string{"buildx", "inspect", "--bootstrap", cfg.Builder}, nil)
+}
+
+// writeBuildkitdConfig writes a buildkitd.toml limiting the steps BuildKit
+// runs in parallel to max_parallelism, for build graphs large enough to
+// exhaust a node's memory or file descriptors. It returns the file's path.
+func writeBuildkitdConfig(cfg *Config) (string, error) {
+	f, err := os.CreateTemp("", "relicta-buildkitd-*.toml")
+	if err != nil {
+		return "", fmt.Errorf("failed to write buildkitd config: %w", err)
+	}
+	defer f.Close()
+	if _, err := fmt.Fprintf(f, "[worker.oci]\n  max-parallelism = %d\n\n[worker.containerd]\n  max-parallelism = %d\n", cfg.MaxParallelism, cfg.MaxParallelism); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to write buildkitd config: %w", err)
+	}
+	return f.Name(), nil
+}
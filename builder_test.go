@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestParseBuilderNodes(t *testing.T) {
+	raw := map[string]any{
+		"builder_nodes": []any{
+			map[string]any{
+				"name":      "amd64",
+				"endpoint":  "tcp://amd64-builder:1234",
+				"platforms": []any{"linux/amd64"},
+			},
+			map[string]any{
+				"endpoint":  "ssh://ci@arm64-builder",
+				"platforms": []any{"linux/arm64", "linux/arm/v7"},
+			},
+			"not a node",
+		},
+	}
+
+	nodes := parseBuilderNodes(raw)
+	if len(nodes) != 2 {
+		t.Fatalf("expected 2 nodes, got %d", len(nodes))
+	}
+	if nodes[0].Name != "amd64" || nodes[0].Endpoint != "tcp://amd64-builder:1234" {
+		t.Errorf("unexpected first node: %+v", nodes[0])
+	}
+	if len(nodes[1].Platforms) != 2 {
+		t.Errorf("expected 2 platforms on second node, got %v", nodes[1].Platforms)
+	}
+}
+
+func TestValidateBuilderNodes(t *testing.T) {
+	tests := []struct {
+		name    string
+		nodes   []BuilderNode
+		driver  string
+		wantErr string
+	}{
+		{
+			name: "valid nodes",
+			nodes: []BuilderNode{
+				{Endpoint: "tcp://amd64:1234", Platforms: []string{"linux/amd64"}},
+				{Endpoint: "arm-context", Platforms: []string{"linux/arm64/v8"}},
+			},
+			driver: "remote",
+		},
+		{
+			name:    "missing endpoint",
+			nodes:   []BuilderNode{{Platforms: []string{"linux/amd64"}}},
+			wantErr: "endpoint is required",
+		},
+		{
+			name:    "invalid endpoint",
+			nodes:   []BuilderNode{{Endpoint: "tcp://host;rm -rf /", Platforms: []string{"linux/amd64"}}},
+			wantErr: "invalid endpoint",
+		},
+		{
+			name:    "missing platforms",
+			nodes:   []BuilderNode{{Endpoint: "tcp://amd64:1234"}},
+			wantErr: "at least one platform",
+		},
+		{
+			name:    "invalid platform",
+			nodes:   []BuilderNode{{Endpoint: "tcp://amd64:1234", Platforms: []string{"amd64"}}},
+			wantErr: "invalid platform",
+		},
+		{
+			name: "duplicate node names",
+			nodes: []BuilderNode{
+				{Name: "node", Endpoint: "tcp://a:1234", Platforms: []string{"linux/amd64"}},
+				{Name: "node", Endpoint: "tcp://b:1234", Platforms: []string{"linux/arm64"}},
+			},
+			wantErr: "duplicate node name",
+		},
+		{
+			name:    "invalid driver",
+			driver:  "remote --bad",
+			wantErr: "invalid builder driver",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateBuilderNodes(tt.nodes, defaultBuilderName, tt.driver)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestEnsureBuilder(t *testing.T) {
+	ctx := context.Background()
+	nodes := []BuilderNode{
+		{Endpoint: "tcp://amd64:1234", Platforms: []string{"linux/amd64"}},
+		{Endpoint: "tcp://arm64:1234", Platforms: []string{"linux/arm64"}},
+	}
+
+	t.Run("creates new builder", func(t *testing.T) {
+		mock := &MockCommandExecutor{
+			OutputFunc: func(context.Context, string, []string) ([]byte, error) {
+				return nil, errors.New("no builder")
+			},
+		}
+		p := &DockerPlugin{executor: mock}
+		cfg := &Config{Builder: "release", BuilderNodes: nodes, BuilderDriver: "remote"}
+
+		if err := p.ensureBuilder(ctx, cfg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(mock.RunCalls) != 3 {
+			t.Fatalf("expected 3 calls (2 create, 1 bootstrap), got %d", len(mock.RunCalls))
+		}
+		first := mock.RunCalls[0].Args
+		if containsFlag(first, "--append") {
+			t.Error("first node of a new builder should not use --append")
+		}
+		if !containsArg(first, "--node", "release0") || !containsArg(first, "--platform", "linux/amd64") || !containsArg(first, "--driver", "remote") {
+			t.Errorf("unexpected create args: %v", first)
+		}
+		if first[len(first)-1] != "tcp://amd64:1234" {
+			t.Errorf("expected endpoint as last arg, got %v", first)
+		}
+		if !containsFlag(mock.RunCalls[1].Args, "--append") {
+			t.Error("additional nodes should use --append")
+		}
+		if !containsFlag(mock.RunCalls[2].Args, "--bootstrap") {
+			t.Error("expected builder bootstrap")
+		}
+	})
+
+	t.Run("updates existing builder", func(t *testing.T) {
+		mock := &MockCommandExecutor{}
+		p := &DockerPlugin{executor: mock}
+		cfg := &Config{Builder: "release", BuilderNodes: nodes}
+
+		if err := p.ensureBuilder(ctx, cfg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !containsFlag(mock.RunCalls[0].Args, "--append") {
+			t.Error("existing builder nodes should be updated with --append")
+		}
+	})
+
+	t.Run("max parallelism", func(t *testing.T) {
+		var path, config string
+		mock := &MockCommandExecutor{
+			RunFunc: func(_ context.Context, _ string, args []string, _ io.Reader) error {
+				for i, arg := range args {
+					if arg == "--config" && i+1 < len(args) {
+						path = args[i+1]
+						data, err := os.ReadFile(path)
+						if err != nil {
+							return err
+						}
+						config = string(data)
+					}
+				}
+				return nil
+			},
+		}
+		p := &DockerPlugin{executor: mock}
+		cfg := &Config{Builder: "release", BuilderNodes: nodes, MaxParallelism: 4}
+
+		if err := p.ensureBuilder(ctx, cfg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.Contains(config, "max-parallelism = 4") {
+			t.Errorf("expected buildkitd config limiting parallelism, got %q", config)
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("expected buildkitd config to be removed, got %v", err)
+		}
+	})
+
+	t.Run("create failure", func(t *testing.T) {
+		mock := &MockCommandExecutor{FailOnCall: 1}
+		p := &DockerPlugin{executor: mock}
+		cfg := &Config{Builder: "release", BuilderNodes: nodes}
+
+		err := p.ensureBuilder(ctx, cfg)
+		if err == nil || !strings.Contains(err.Error(), "tcp://amd64:1234") {
+			t.Errorf("expected error naming endpoint, got %v", err)
+		}
+	})
+}
+
+func TestMultiNodeBuilderExecute(t *testing.T) {
+	ctx := context.Background()
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(ctx, plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":     "myorg/myapp",
+			"platforms": []any{"linux/amd64", "linux/arm64"},
+			"builder_nodes": []any{
+				map[string]any{"endpoint": "tcp://amd64:1234", "platforms": []any{"linux/amd64"}},
+				map[string]any{"endpoint": "tcp://arm64:1234", "platforms": []any{"linux/arm64"}},
+			},
+		},
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+	if _, ok := resp.Outputs["warnings"]; ok {
+		t.Errorf("expected no emulation warnings, got %v", resp.Outputs["warnings"])
+	}
+
+	build := mock.RunCalls[len(mock.RunCalls)-1].Args
+	if build[0] != "buildx" || !containsArg(build, "--builder", defaultBuilderName) {
+		t.Errorf("expected buildx build on default builder, got %v", build)
+	}
+}
+
+func TestMultiPlatformBuildCreatesBuilder(t *testing.T) {
+	ctx := context.Background()
+	mock := &MockCommandExecutor{
+		OutputFunc: func(_ context.Context, _ string, args []string) ([]byte, error) {
+			if containsArg(args, "buildx", "inspect") {
+				return nil, errors.New("no builder")
+			}
+			return nil, nil
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(ctx, plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":     "myorg/myapp",
+			"platforms": []any{"linux/amd64", "linux/arm64"},
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+
+	if len(mock.RunCalls) != 3 {
+		t.Fatalf("expected builder creation, bootstrap and build, got %v", mock.RunCalls)
+	}
+	create, build := mock.RunCalls[0].Args, mock.RunCalls[2].Args
+	if !containsArg(create, "--name", defaultBuilderName) || !containsArg(create, "--driver", "docker-container") {
+		t.Errorf("unexpected builder creation: %v", create)
+	}
+	if !containsArg(build, "--builder", defaultBuilderName) || !containsFlag(build, "--push") || !containsArg(build, "--platform", "linux/amd64,linux/arm64") {
+		t.Errorf("expected a pushing multi-platform buildx build, got %v", build)
+	}
+}
+
+func TestMultiPlatformBuildReusesBuilder(t *testing.T) {
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock}
+	cfg := &Config{Builder: defaultBuilderName, autoBuilder: true}
+
+	if err := p.ensureBuilder(context.Background(), cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(mock.RunCalls) != 0 {
+		t.Errorf("expected the existing builder to be used, got %v", mock.RunCalls)
+	}
+}
+
+func TestSinglePlatformKeepsClassicBuild(t *testing.T) {
+	p := &DockerPlugin{}
+	cfg := p.parseConfig(map[string]any{"image": "myapp", "platforms": []any{"linux/arm64"}})
+	if useBuildx(cfg) {
+		t.Errorf("expected a single platform to build with classic docker build, got builder %q", cfg.Builder)
+	}
+	cfg = p.parseConfig(map[string]any{"image": "myapp", "platforms": []any{"linux/amd64", "linux/arm64"}})
+	if cfg.Builder != defaultBuilderName || !cfg.autoBuilder {
+		t.Errorf("expected several platforms to select the %s builder, got %q", defaultBuilderName, cfg.Builder)
+	}
+}
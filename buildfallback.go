@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"strings"
+)
+
+// builderFailures are fragments of lowercased buildx errors caused by the
+// builder or its driver rather than the build itself.
+var builderFailures = []string{
+	"no builder",
+	"failed to find driver",
+	"failed to initialize builder",
+	"error booting buildkit",
+	"failed to bootstrap",
+	"buildkitd",
+	"rpc error: code = unavailable",
+	"error reading from server: eof",
+	"failed to dial",
+	"failed to list workers",
+	"no valid drivers found",
+	"connection reset by peer",
+}
+
+// buildFailures are fragments of lowercased build errors caused by the
+// Dockerfile or the build context, which fail with any builder.
+var buildFailures = []string{
+	"did not complete successfully",
+	"dockerfile parse error",
+	"failed to compute cache key",
+	"failed to read dockerfile",
+	"unknown instruction",
+}
+
+// isBuilderFailure reports whether a buildx build failed because of the
+// builder or its driver, e.g. a buildkit container that crashed, rather
+// than a failing Dockerfile step.
+func isBuilderFailure(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, fragment := range buildFailures {
+		if strings.Contains(msg, fragment) {
+			return false
+		}
+	}
+	for _, fragment := range builderFailures {
+		if strings.Contains(msg, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// retriesClassic reports whether a failed buildx build is retried with
+// classic docker build: classic_fallback is set, the build targets at most
+// one platform without builder_nodes, and it failed because of the builder.
+func retriesClassic(ctx context.Context, cfg *Config, err error) bool {
+	return cfg.ClassicFallback && useBuildx(cfg) && ctx.Err() == nil &&
+		len(cfg.Platforms) <= 1 && len(cfg.BuilderNodes) == 0 && cfg.ociOutput == "" && cfg.Engine != enginePodman &&
+		isBuilderFailure(err)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestIsBuilderFailure(t *testing.T) {
+	tests := map[string]bool{
+		"ERROR: failed to dial gRPC: cannot connect to the buildkitd":                                     true,
+		"ERROR: no builder \"release\" found":                                                             true,
+		"ERROR: failed to solve: rpc error: code = Unavailable desc = error reading from server: EOF":     true,
+		"ERROR: failed to solve: process \"/bin/sh -c make\" did not complete successfully: exit code: 2": false,
+		"ERROR: failed to solve: failed to compute cache key: \"/app\" not found":                         false,
+		"exit status 1": false,
+	}
+	for msg, want := range tests {
+		if got := isBuilderFailure(errors.New(msg)); got != want {
+			t.Errorf("isBuilderFailure(%q) = %v, want %v", msg, got, want)
+		}
+	}
+}
+
+func TestExecuteClassicFallback(t *testing.T) {
+	tests := []struct {
+		name     string
+		buildErr string
+		fallback bool
+	}{
+		{"builder failure", "ERROR: failed to dial gRPC: cannot connect to the buildkitd", true},
+		{"dockerfile failure", "ERROR: failed to solve: process \"/bin/sh -c make\" did not complete successfully", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &MockCommandExecutor{
+				RunFunc: func(ctx context.Context, name string, args []string, stdin io.Reader) error {
+					if len(args) > 1 && args[0] == "buildx" && args[1] == "build" {
+						return errors.New(tt.buildErr)
+					}
+					return nil
+				},
+			}
+			p := &DockerPlugin{executor: mock}
+
+			resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+				Hook:    plugin.HookPostPublish,
+				Config:  map[string]any{"image": "myapp", "builder": "release", "classic_fallback": true},
+				Context: plugin.ReleaseContext{Version: "1.0.0"},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Success != tt.fallback {
+				t.Fatalf("expected success=%v, got %+v", tt.fallback, resp)
+			}
+			if !tt.fallback {
+				return
+			}
+
+			var commands []string
+			for _, call := range mock.RunCalls {
+				commands = append(commands, strings.Join(call.Args[:min(2, len(call.Args))], " "))
+			}
+			if !slices.Contains(commands, "build -t") || !slices.Contains(commands, "push myapp:1.0.0") {
+				t.Errorf("expected a classic build and push after the failed buildx build, got %v", commands)
+			}
+			warnings, _ := resp.Outputs["warnings"].([]string)
+			if !slices.ContainsFunc(warnings, func(w string) bool { return strings.Contains(w, "retried with classic docker build") }) {
+				t.Errorf("expected a warning about the fallback, got %v", warnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,685 @@
+package main
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// Modes of bundle.
+const (
+	bundleExport = "export"
+	bundleImport = "import"
+)
+
+// bundleManifestName is the manifest of digests at the root of a bundle,
+// next to the OCI image layout.
+const bundleManifestName = "manifest.json"
+
+// bundleFormatVersion is the version of the bundle manifest.
+const bundleFormatVersion = 1
+
+// Kinds of bundle artifacts.
+const (
+	bundleImage       = "image"
+	bundleSignature   = "signature"
+	bundleAttestation = "attestation"
+	bundleSBOM        = "sbom"
+)
+
+// Sources of bundle artifacts: how an artifact is attached to the image,
+// and so how an import pushes it.
+const (
+	// bundleFromTag is a cosign artifact tagged after the image digest,
+	// e.g. sha256-<hex>.sig.
+	bundleFromTag = "tag"
+	// bundleFromReferrer is an artifact whose subject is the image, listed
+	// by the OCI referrers API.
+	bundleFromReferrer = "referrer"
+	// bundleFromIndex is an attestation manifest inside the image index,
+	// such as the SBOM and provenance of buildx.
+	bundleFromIndex = "index"
+)
+
+// maxManifestSize bounds the manifests read from a registry.
+const maxManifestSize = 4 << 20
+
+// cosignTag returns the tag cosign attaches the artifacts of digest under,
+// before the suffix of the kind of artifact: sha256-4f3c... for
+// sha256:4f3c....
+func cosignTag(digest string) string {
+	return strings.Replace(digest, ":", "-", 1)
+}
+
+// cosignTagSuffixes maps the suffixes of the tags cosign attaches to an
+// image digest to the kind of artifact they carry.
+var cosignTagSuffixes = []struct{ suffix, kind string }{
+	{".sig", bundleSignature},
+	{".att", bundleAttestation},
+	{".sbom", bundleSBOM},
+}
+
+// BundleManifest describes the content of an air-gap bundle: the release
+// it was exported from, the image and the artifacts attached to it, and the
+// digest and size of every blob, which an import verifies before pushing.
+type BundleManifest struct {
+	FormatVersion int              `json:"format_version"`
+	Image         string           `json:"image"`
+	Version       string           `json:"version"`
+	Created       string           `json:"created"`
+	Digest        string           `json:"digest"`
+	Tags          []string         `json:"tags"`
+	Artifacts     []BundleArtifact `json:"artifacts"`
+	Blobs         map[string]int64 `json:"blobs"`
+}
+
+// BundleArtifact is the image of a bundle or an artifact attached to it.
+type BundleArtifact struct {
+	Kind      string `json:"kind"`
+	Source    string `json:"source,omitempty"`
+	Tag       string `json:"tag,omitempty"`
+	Digest    string `json:"digest"`
+	MediaType string `json:"media_type"`
+	Size      int64  `json:"size"`
+}
+
+// BundleResult records the bundle written by an export or pushed by an
+// import.
+type BundleResult struct {
+	File      string           `json:"file"`
+	Checksum  string           `json:"checksum,omitempty"`
+	Size      int64            `json:"size,omitempty"`
+	Digest    string           `json:"digest"`
+	Artifacts []BundleArtifact `json:"artifacts"`
+}
+
+// validateBundle checks bundle and bundle_file.
+func validateBundle(cfg *Config) error {
+	switch cfg.Bundle {
+	case "":
+		if cfg.BundleFile != "" {
+			return fmt.Errorf("bundle_file requires bundle")
+		}
+		return nil
+	case bundleExport:
+		if !cfg.Push {
+			return fmt.Errorf("bundle export reads the release back from the registry, so it requires push")
+		}
+	case bundleImport:
+		switch {
+		case len(cfg.IndexSources) > 0:
+			return fmt.Errorf("bundle import cannot be combined with index_sources")
+		case len(cfg.AppendPlatforms) > 0:
+			return fmt.Errorf("bundle import cannot be combined with append_platform")
+		}
+	default:
+		return fmt.Errorf("unknown bundle mode %q (use %s or %s)", cfg.Bundle, bundleExport, bundleImport)
+	}
+	if err := validatePath(cfg.BundleFile); err != nil {
+		return fmt.Errorf("invalid bundle_file: %v", err)
+	}
+	return nil
+}
+
+// bundlePath returns the bundle file of a release: bundle_file, or
+// <image>-<version>.bundle.tar in the working directory.
+func bundlePath(cfg *Config, version string) string {
+	if cfg.BundleFile != "" {
+		return cfg.BundleFile
+	}
+	return fmt.Sprintf("%s-%s.bundle.tar", path.Base(cfg.Image), strings.TrimPrefix(version, "v"))
+}
+
+// artifactKind classifies a referrer by its artifact type.
+func artifactKind(artifactType string) string {
+	t := strings.ToLower(artifactType)
+	switch {
+	case strings.Contains(t, "sbom"), strings.Contains(t, "spdx"), strings.Contains(t, "cyclonedx"):
+		return bundleSBOM
+	case strings.Contains(t, "signature"), strings.Contains(t, "cosign.simplesigning"), strings.Contains(t, "sigstore.bundle"):
+		return bundleSignature
+	}
+	return bundleAttestation
+}
+
+// registrySession returns an authorized session with the repository of
+// cfg that accepts every manifest media type.
+func (p *DockerPlugin) registrySession(ctx context.Context, cfg *Config) (*ociPusher, error) {
+	client := p.newRegistryClient(cfg)
+	username, password, _, err := p.registryCredentials(ctx, cfg, cfg.Username, loginSecret(cfg))
+	if err != nil {
+		return nil, err
+	}
+	client.username, client.password = username, password
+	client.headers = maps.Clone(client.headers)
+	if client.headers == nil {
+		client.headers = make(map[string]string)
+	}
+	client.headers["Accept"] = manifestAccept
+
+	o := &ociPusher{client: client, repository: registryRepository(cfg)}
+	o.setRetries(cfg)
+	if err := o.authorize(ctx); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// bundleStage exports the bundle of a pushed release when bundle is
+// export, and returns the failure response if that fails.
+func (p *DockerPlugin) bundleStage(ctx context.Context, cfg *Config, version string, outputs *Outputs) *plugin.ExecuteResponse {
+	if cfg.Bundle != bundleExport {
+		return nil
+	}
+	started := time.Now()
+	bundle, err := p.exportBundle(ctx, cfg, version, outputs)
+	outputs.stage("bundle", started, err)
+	if err != nil {
+		return outputs.response(false, "", fmt.Sprintf("failed to export bundle: %v", err))
+	}
+	outputs.Bundle = bundle
+	return nil
+}
+
+// exportBundle reads the pushed release back from the registry into an
+// OCI image layout, with every platform, the attestations of its index and
+// the signatures, attestations and SBOMs attached to its digest, and writes
+// it with a manifest of digests to a single tar archive.
+func (p *DockerPlugin) exportBundle(ctx context.Context, cfg *Config, version string, outputs *Outputs) (*BundleResult, error) {
+	if len(outputs.Tags) == 0 {
+		return nil, fmt.Errorf("the release has no tags")
+	}
+	dir, err := os.MkdirTemp("", "relicta-docker-bundle-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	session, err := p.registrySession(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	session.layout = dir
+
+	ref := outputs.Digest
+	if ref == "" {
+		ref = outputs.Tags[0]
+	}
+	root, data, err := session.fetchManifest(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, fmt.Errorf("%s not found in %s", ref, imageRepository(cfg))
+	}
+	manifest := &BundleManifest{
+		FormatVersion: bundleFormatVersion,
+		Image:         imageRepository(cfg),
+		Version:       version,
+		Created:       time.Now().UTC().Format(time.RFC3339),
+		Digest:        root.Digest,
+		Tags:          slices.Clone(outputs.Tags),
+		Artifacts:     []BundleArtifact{{Kind: bundleImage, Digest: root.Digest, MediaType: root.MediaType, Size: root.Size}},
+	}
+	if err := session.fetchContent(ctx, root, data, manifest); err != nil {
+		return nil, err
+	}
+
+	layout := []ociDescriptor{withRefName(root, outputs.Tags[0])}
+	for _, cosign := range cosignTagSuffixes {
+		tag := cosignTag(root.Digest) + cosign.suffix
+		desc, content, err := session.fetchManifest(ctx, tag)
+		if err != nil {
+			return nil, err
+		}
+		if content == nil {
+			continue
+		}
+		if err := session.fetchContent(ctx, desc, content, manifest); err != nil {
+			return nil, err
+		}
+		manifest.Artifacts = append(manifest.Artifacts, BundleArtifact{Kind: cosign.kind, Source: bundleFromTag, Tag: tag, Digest: desc.Digest, MediaType: desc.MediaType, Size: desc.Size})
+		layout = append(layout, withRefName(desc, tag))
+	}
+
+	referrers, err := session.fetchReferrers(ctx, root.Digest)
+	if err != nil {
+		return nil, err
+	}
+	for _, referrer := range referrers {
+		desc, content, err := session.fetchManifest(ctx, referrer.Digest)
+		if err != nil {
+			return nil, err
+		}
+		if content == nil || slices.ContainsFunc(layout, func(d ociDescriptor) bool { return d.Digest == desc.Digest }) {
+			continue
+		}
+		if err := session.fetchContent(ctx, desc, content, manifest); err != nil {
+			return nil, err
+		}
+		manifest.Artifacts = append(manifest.Artifacts, BundleArtifact{Kind: artifactKind(referrer.ArtifactType), Source: bundleFromReferrer, Digest: desc.Digest, MediaType: desc.MediaType, Size: desc.Size})
+		layout = append(layout, desc)
+	}
+
+	if manifest.Blobs, err = layoutBlobs(dir); err != nil {
+		return nil, err
+	}
+	index, err := json.MarshalIndent(ociIndex{MediaType: ociIndexMediaType, Manifests: layout}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "index.json"), index, 0o644); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "oci-layout"), []byte(`{"imageLayoutVersion":"1.0.0"}`), 0o644); err != nil {
+		return nil, err
+	}
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, bundleManifestName), content, 0o644); err != nil {
+		return nil, err
+	}
+
+	file := bundlePath(cfg, version)
+	checksum, size, err := writeTarDir(dir, file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", file, err)
+	}
+	outputs.Artifacts = append(outputs.Artifacts, plugin.Artifact{Name: filepath.Base(file), Path: file, Type: "docker-bundle", Size: size, Checksum: checksum})
+	return &BundleResult{File: file, Checksum: checksum, Size: size, Digest: root.Digest, Artifacts: manifest.Artifacts}, nil
+}
+
+// withRefName annotates a descriptor of index.json with its tag, so tools
+// reading the layout, such as skopeo, can address it.
+func withRefName(desc ociDescriptor, tag string) ociDescriptor {
+	desc.Annotations = map[string]string{"org.opencontainers.image.ref.name": tag}
+	return desc
+}
+
+// fetchManifest downloads the manifest ref, a tag or a digest, into the
+// layout and returns its descriptor and content. A manifest that does not
+// exist returns no content.
+func (o *ociPusher) fetchManifest(ctx context.Context, ref string) (ociDescriptor, []byte, error) {
+	resp, err := o.do(ctx, http.MethodGet, fmt.Sprintf("%s/v2/%s/manifests/%s", o.client.baseURL, o.repository, ref), nil, 0, "")
+	if err != nil {
+		return ociDescriptor{}, nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return ociDescriptor{}, nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return ociDescriptor{}, nil, expect(resp, "GET manifest "+ref, http.StatusOK)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxManifestSize))
+	resp.Body.Close()
+	if err != nil {
+		return ociDescriptor{}, nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	desc := ociDescriptor{Digest: "sha256:" + hex.EncodeToString(sum[:]), Size: int64(len(data))}
+	if blobDigestPattern.MatchString(ref) && ref != desc.Digest {
+		return ociDescriptor{}, nil, fmt.Errorf("manifest %s does not match its digest", ref)
+	}
+	desc.MediaType, _, _ = mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if desc.MediaType == "" || desc.MediaType == "application/json" {
+		var m struct {
+			MediaType string `json:"mediaType"`
+		}
+		_ = json.Unmarshal(data, &m)
+		desc.MediaType = m.MediaType
+	}
+	if err := o.writeBlob(desc, data); err != nil {
+		return ociDescriptor{}, nil, err
+	}
+	return desc, data, nil
+}
+
+// fetchContent downloads what the manifest desc references: the manifests
+// of an index, and the config and layers of an image manifest. The
+// attestation manifests of an index are recorded in the bundle manifest.
+func (o *ociPusher) fetchContent(ctx context.Context, desc ociDescriptor, data []byte, bundle *BundleManifest) error {
+	switch desc.MediaType {
+	case ociIndexMediaType, dockerManifestListType:
+		var index ociIndex
+		if err := json.Unmarshal(data, &index); err != nil {
+			return fmt.Errorf("invalid index %s: %w", desc.Digest, err)
+		}
+		for _, child := range index.Manifests {
+			childDesc, content, err := o.fetchManifest(ctx, child.Digest)
+			if err != nil {
+				return err
+			}
+			if content == nil {
+				return fmt.Errorf("manifest %s of %s not found", child.Digest, desc.Digest)
+			}
+			if err := o.fetchContent(ctx, childDesc, content, bundle); err != nil {
+				return err
+			}
+			if child.Annotations["vnd.docker.reference.type"] == "attestation-manifest" {
+				bundle.Artifacts = append(bundle.Artifacts, BundleArtifact{Kind: bundleAttestation, Source: bundleFromIndex, Digest: child.Digest, MediaType: childDesc.MediaType, Size: childDesc.Size})
+			}
+		}
+	case ociManifestMediaType, dockerManifestMediaType:
+		var manifest ociManifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return fmt.Errorf("invalid manifest %s: %w", desc.Digest, err)
+		}
+		for _, blob := range append([]ociDescriptor{manifest.Config}, manifest.Layers...) {
+			if err := o.fetchBlob(ctx, blob); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported manifest media type %q of %s", desc.MediaType, desc.Digest)
+	}
+	return nil
+}
+
+// fetchBlob downloads a blob into the layout and verifies its digest.
+// Non-distributable layers are not downloaded, as they are not pushed.
+func (o *ociPusher) fetchBlob(ctx context.Context, blob ociDescriptor) error {
+	if strings.Contains(blob.MediaType, nondistributableMediaTypes) || strings.Contains(blob.MediaType, "foreign") {
+		return nil
+	}
+	target, err := blobPath(o.layout, blob)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(target); err == nil {
+		return nil
+	}
+	req, err := o.request(ctx, http.MethodGet, fmt.Sprintf("%s/v2/%s/blobs/%s", o.client.baseURL, o.repository, blob.Digest), nil, 0, "")
+	if err != nil {
+		return err
+	}
+	resp, err := o.sendBlob(req)
+	if err != nil {
+		return fmt.Errorf("GET blob %s: %w", blob.Digest, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return expect(resp, "GET blob "+blob.Digest, http.StatusOK)
+	}
+	defer resp.Body.Close()
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(target), ".download-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, hash), resp.Body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("GET blob %s: %w", blob.Digest, err)
+	}
+	if "sha256:"+hex.EncodeToString(hash.Sum(nil)) != blob.Digest {
+		return fmt.Errorf("blob %s does not match its digest", blob.Digest)
+	}
+	return os.Rename(f.Name(), target)
+}
+
+// fetchReferrers lists the artifacts whose subject is digest with the OCI
+// referrers API. Registries without the API have none.
+func (o *ociPusher) fetchReferrers(ctx context.Context, digest string) ([]ociDescriptor, error) {
+	resp, err := o.do(ctx, http.MethodGet, fmt.Sprintf("%s/v2/%s/referrers/%s", o.client.baseURL, o.repository, digest), nil, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil
+	}
+	var index ociIndex
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxManifestSize)).Decode(&index); err != nil {
+		return nil, nil
+	}
+	return index.Manifests, nil
+}
+
+// writeBlob stores the manifest content of desc in the layout.
+func (o *ociPusher) writeBlob(desc ociDescriptor, data []byte) error {
+	target, err := blobPath(o.layout, desc)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(target, data, 0o644)
+}
+
+// layoutBlobs returns the size of every blob of the layout, keyed by
+// digest.
+func layoutBlobs(layout string) (map[string]int64, error) {
+	entries, err := os.ReadDir(filepath.Join(layout, "blobs", "sha256"))
+	if err != nil {
+		return nil, err
+	}
+	blobs := make(map[string]int64, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		blobs["sha256:"+entry.Name()] = info.Size()
+	}
+	return blobs, nil
+}
+
+// writeTarDir archives the files of dir into file, replacing it only once
+// the archive is complete, and returns the digest and size of the archive.
+func writeTarDir(dir, file string) (string, int64, error) {
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return "", 0, err
+	}
+	f, err := os.CreateTemp(filepath.Dir(file), ".bundle-*")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(f.Name())
+
+	hash := sha256.New()
+	w := tar.NewWriter(io.MultiWriter(f, hash))
+	err = filepath.WalkDir(dir, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, name)
+		header := &tar.Header{Name: filepath.ToSlash(rel), Mode: 0o644, Size: info.Size(), Typeflag: tar.TypeReg, ModTime: info.ModTime()}
+		if err := w.WriteHeader(header); err != nil {
+			return err
+		}
+		src, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.Copy(w, src)
+		return err
+	})
+	if err == nil {
+		err = w.Close()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	info, err := os.Stat(f.Name())
+	if err != nil {
+		return "", 0, err
+	}
+	if err := os.Rename(f.Name(), file); err != nil {
+		return "", 0, err
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), info.Size(), nil
+}
+
+// readBundle extracts a bundle into dir and verifies every blob against
+// the digests and sizes of its manifest.
+func readBundle(file, dir string) (*BundleManifest, error) {
+	if err := extractTar(file, dir); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, bundleManifestName))
+	if err != nil {
+		return nil, fmt.Errorf("no %s: the file is not a bundle", bundleManifestName)
+	}
+	var manifest BundleManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", bundleManifestName, err)
+	}
+	if manifest.FormatVersion != bundleFormatVersion {
+		return nil, fmt.Errorf("unsupported bundle format version %d", manifest.FormatVersion)
+	}
+	if len(manifest.Artifacts) == 0 || manifest.Artifacts[0].Kind != bundleImage || manifest.Artifacts[0].Digest != manifest.Digest {
+		return nil, fmt.Errorf("%s does not describe an image", bundleManifestName)
+	}
+	for _, digest := range sortedKeys(manifest.Blobs) {
+		if err := verifyBlob(dir, ociDescriptor{Digest: digest, Size: manifest.Blobs[digest]}); err != nil {
+			return nil, err
+		}
+	}
+	for _, artifact := range manifest.Artifacts {
+		if _, ok := manifest.Blobs[artifact.Digest]; !ok {
+			return nil, fmt.Errorf("%s %s is missing from the bundle", artifact.Kind, artifact.Digest)
+		}
+	}
+	return &manifest, nil
+}
+
+// verifyBlob checks that the blob desc of layout has its size and digest.
+func verifyBlob(layout string, desc ociDescriptor) error {
+	path, err := blobPath(layout, desc)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("missing blob %s", desc.Digest)
+	}
+	defer f.Close()
+	hash := sha256.New()
+	n, err := io.Copy(hash, f)
+	if err != nil {
+		return err
+	}
+	if n != desc.Size || "sha256:"+hex.EncodeToString(hash.Sum(nil)) != desc.Digest {
+		return fmt.Errorf("blob %s does not match the bundle manifest", desc.Digest)
+	}
+	return nil
+}
+
+// importBundle pushes a bundle exported on the other side of an air gap to
+// the registry of cfg: the image under the tags of the release it was
+// exported from, then the artifacts attached to it, under their cosign tags
+// or, for referrers, by digest. The image keeps its digest, so its
+// signatures stay valid.
+func (p *DockerPlugin) importBundle(ctx context.Context, cfg *Config, releaseCtx plugin.ReleaseContext, dryRun bool) (*plugin.ExecuteResponse, error) {
+	defer cfg.releaseLock()
+	if resp := runValidators(cfg, bundleValidators); resp != nil {
+		return resp, nil
+	}
+
+	outputs := &Outputs{Version: OutputsVersion, Image: cfg.Image, Registry: cfg.Registry}
+	dir, err := os.MkdirTemp("", "relicta-docker-bundle-*")
+	if err != nil {
+		return outputs.response(false, "", fmt.Sprintf("failed to create bundle directory: %v", err)), nil
+	}
+	defer os.RemoveAll(dir)
+
+	file := bundlePath(cfg, releaseCtx.Version)
+	started := time.Now()
+	manifest, err := readBundle(file, dir)
+	outputs.stage("bundle", started, err)
+	if err != nil {
+		return outputs.response(false, "", fmt.Sprintf("invalid bundle %s: %v", file, err)), nil
+	}
+	repository := imageRepository(cfg)
+	outputs.Tags = manifest.Tags
+	for _, tag := range manifest.Tags {
+		outputs.Refs = append(outputs.Refs, repository+":"+tag)
+	}
+	outputs.Digest = manifest.Digest
+	result := &BundleResult{File: file, Digest: manifest.Digest, Artifacts: manifest.Artifacts}
+	outputs.Bundle = result
+	if dryRun {
+		return outputs.response(true, fmt.Sprintf("Would push bundle %s with %d tags to %s", file, len(manifest.Tags), repository), ""), nil
+	}
+
+	if resp := p.gatePush(ctx, cfg, releaseCtx.Version, outputs); resp != nil {
+		return resp, nil
+	}
+	started = time.Now()
+	err = p.pushBundle(ctx, cfg, dir, manifest)
+	p.lookups.invalidate()
+	outputs.stage("push", started, err)
+	if err != nil {
+		return outputs.response(false, "", fmt.Sprintf("failed to push bundle %s: %v", file, err)), nil
+	}
+	outputs.Pushed = true
+	outputs.PushedRefs = outputs.Refs
+	return outputs.response(true, fmt.Sprintf("Pushed bundle %s with %d tags and %d attached artifacts to %s", file, len(manifest.Tags), len(manifest.Artifacts)-1, repository), ""), nil
+}
+
+// pushBundle uploads the verified layout of a bundle.
+func (p *DockerPlugin) pushBundle(ctx context.Context, cfg *Config, layout string, manifest *BundleManifest) error {
+	o, err := p.registrySession(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	o.layout = layout
+	o.throttle = cfg.pushThrottle
+	for _, artifact := range manifest.Artifacts {
+		var refs []string
+		switch {
+		case artifact.Kind == bundleImage:
+			refs = manifest.Tags
+		case artifact.Source == bundleFromTag:
+			refs = []string{artifact.Tag}
+		case artifact.Source == bundleFromReferrer:
+			refs = []string{artifact.Digest}
+		default:
+			// Pushed with the index that holds it.
+			continue
+		}
+		desc := ociDescriptor{MediaType: artifact.MediaType, Digest: artifact.Digest, Size: artifact.Size}
+		data, err := readBlob(layout, desc)
+		if err != nil {
+			return err
+		}
+		if err := o.push(ctx, desc, data, refs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestValidateBundle(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *Config
+		wantErr string
+	}{
+		{"disabled", &Config{}, ""},
+		{"export", &Config{Bundle: bundleExport, Push: true}, ""},
+		{"import", &Config{Bundle: bundleImport, BundleFile: "bundles/release.tar"}, ""},
+		{"file without mode", &Config{BundleFile: "release.tar"}, "requires bundle"},
+		{"unknown mode", &Config{Bundle: "save"}, "unknown bundle mode"},
+		{"export without push", &Config{Bundle: bundleExport}, "requires push"},
+		{"import with index", &Config{Bundle: bundleImport, IndexSources: []string{"myapp:1.0.0-amd64"}}, "index_sources"},
+		{"traversal", &Config{Bundle: bundleImport, BundleFile: "../release.tar"}, "invalid bundle_file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateBundle(tt.cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// seedSignature attaches a cosign signature to digest in reg and returns
+// the signature tag.
+func seedSignature(reg *fakeRegistry, digest string) string {
+	blob := func(data []byte) ociDescriptor {
+		sum := sha256.Sum256(data)
+		desc := ociDescriptor{Digest: "sha256:" + hex.EncodeToString(sum[:]), Size: int64(len(data))}
+		reg.blobs[desc.Digest] = data
+		return desc
+	}
+	config := blob([]byte(`{}`))
+	config.MediaType = "application/vnd.oci.image.config.v1+json"
+	layer := blob([]byte(`{"critical":{}}`))
+	layer.MediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+	manifest, _ := json.Marshal(ociManifest{MediaType: ociManifestMediaType, Config: config, Layers: []ociDescriptor{layer}})
+	tag := cosignTag(digest) + ".sig"
+	reg.manifests[tag] = manifest
+	reg.types[tag] = ociManifestMediaType
+	return tag
+}
+
+func TestBundleExportImport(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+	t.Setenv("TMPDIR", t.TempDir())
+	_, root := writeOCITarball(t, dir)
+	source, sourceServer := newFakeRegistry(t)
+	signature := seedSignature(source, root.Digest)
+
+	p := &DockerPlugin{executor: &MockCommandExecutor{}, httpClient: sourceServer.Client()}
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":       "myapp",
+			"registry":    strings.TrimPrefix(sourceServer.URL, "https://"),
+			"tags":        []any{"1.0.0", "latest"},
+			"daemonless":  true,
+			"oci_tarball": "image.tar",
+			"bundle":      "export",
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+	bundle, ok := resp.Outputs["bundle"].(*BundleResult)
+	if !ok || bundle.File != "myapp-1.0.0.bundle.tar" || bundle.Digest != root.Digest {
+		t.Fatalf("expected bundle output, got %v", resp.Outputs["bundle"])
+	}
+	if len(bundle.Artifacts) != 2 || bundle.Artifacts[1].Kind != bundleSignature || bundle.Artifacts[1].Tag != signature {
+		t.Errorf("expected the image and its signature, got %+v", bundle.Artifacts)
+	}
+
+	target, targetServer := newFakeRegistry(t)
+	p = &DockerPlugin{executor: &MockCommandExecutor{}, httpClient: targetServer.Client()}
+	resp, err = p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":    "myapp",
+			"registry": strings.TrimPrefix(targetServer.URL, "https://"),
+			"bundle":   "import",
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+	for _, ref := range []string{"1.0.0", "latest", signature, root.Digest} {
+		if string(target.manifests[ref]) != string(source.manifests[ref]) {
+			t.Errorf("expected %s to be imported unchanged", ref)
+		}
+	}
+	if len(target.blobs) != len(source.blobs) {
+		t.Errorf("expected %d blobs, got %d", len(source.blobs), len(target.blobs))
+	}
+	if resp.Outputs["digest"] != root.Digest {
+		t.Errorf("expected digest %s, got %v", root.Digest, resp.Outputs["digest"])
+	}
+}
+
+func TestReadBundleRejectsTamperedBlob(t *testing.T) {
+	dir := t.TempDir()
+	layer := []byte("layer")
+	sum := sha256.Sum256(layer)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	manifest, _ := json.Marshal(BundleManifest{
+		FormatVersion: bundleFormatVersion,
+		Digest:        digest,
+		Artifacts:     []BundleArtifact{{Kind: bundleImage, Digest: digest}},
+		Blobs:         map[string]int64{digest: int64(len(layer))},
+	})
+
+	file := dir + "/bundle.tar"
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := tar.NewWriter(f)
+	for name, data := range map[string][]byte{
+		bundleManifestName: manifest,
+		"blobs/sha256/" + strings.TrimPrefix(digest, "sha256:"): []byte("lay3r"),
+	} {
+		if err := w.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+	}
+	w.Close()
+	f.Close()
+
+	if _, err := readBundle(file, t.TempDir()); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("expected digest mismatch, got %v", err)
+	}
+}
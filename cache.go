@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// sourceDigestLabel records the source digest an image was built from.
+	sourceDigestLabel = "org.relicta.source-digest"
+
+	// sourceTagPrefix prefixes the tag that makes an image discoverable by
+	// its source digest.
+	sourceTagPrefix = "src-"
+)
+
+// dockerignore holds parsed .dockerignore patterns.
+type dockerignore struct {
+	patterns []ignorePattern
+}
+
+type ignorePattern struct {
+	pattern string
+	negate  bool
+}
+
+// loadDockerignore reads the .dockerignore file at the root of the build context.
+// A missing file yields an empty pattern list.
+func loadDockerignore(contextDir string) (*dockerignore, error) {
+	f, err := os.Open(filepath.Join(contextDir, ".dockerignore"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &dockerignore{}, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	ignore := &dockerignore{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p := ignorePattern{}
+		if rest, ok := strings.CutPrefix(line, "!"); ok {
+			p.negate = true
+			line = rest
+		}
+		p.pattern = filepath.ToSlash(filepath.Clean(strings.TrimPrefix(line, "/")))
+		ignore.patterns = append(ignore.patterns, p)
+	}
+	return ignore, scanner.Err()
+}
+
+// matches reports whether the slash-separated relative path is excluded.
+// A pattern matching a parent directory excludes everything below it; the
+// last matching pattern wins, as in docker.
+func (d *dockerignore) matches(rel string) bool {
+	excluded := false
+	for _, p := range d.patterns {
+		if matchIgnorePattern(p.pattern, rel) {
+			excluded = !p.negate
+		}
+	}
+	return excluded
+}
+
+func matchIgnorePattern(pattern, rel string) bool {
+	if strings.HasPrefix(pattern, "**/") {
+		suffix := strings.TrimPrefix(pattern, "**/")
+		parts := strings.Split(rel, "/")
+		for i := range parts {
+			if matchIgnorePattern(suffix, strings.Join(parts[i:], "/")) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for candidate := rel; ; {
+		if ok, _ := filepath.Match(pattern, candidate); ok {
+			return true
+		}
+		idx := strings.LastIndex(candidate, "/")
+		if idx < 0 {
+			return false
+		}
+		candidate = candidate[:idx]
+	}
+}
+
+// computeSourceDigest hashes everything that determines the built image:
+// the build context (honouring .dockerignore), the inputs, the Dockerfile, build args,
+// labels, target and platforms. The VERSION build arg injected by the plugin
+// is deliberately excluded so an unchanged source tree hashes identically
+// across releases.
+func computeSourceDigest(cfg *Config) (string, error) {
+	contextDir := cfg.Context
+	if contextDir == "" {
+		contextDir = "."
+	}
+	dockerfile := cfg.Dockerfile
+	if dockerfile == "" {
+		dockerfile = "Dockerfile"
+	}
+
+	ignore, err := loadDockerignore(contextDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read .dockerignore: %w", err)
+	}
+
+	// A checkpoint_file inside the context changes while the release runs.
+	var checkpoint string
+	if cfg.CheckpointFile != "" {
+		if rel, err := filepath.Rel(contextDir, cfg.CheckpointFile); err == nil {
+			checkpoint = filepath.ToSlash(rel)
+		}
+	}
+
+	h := sha256.New()
+
+	err = filepath.WalkDir(contextDir, func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		rel, err := filepath.Rel(contextDir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			return nil
+		}
+		if rel == ".git" || rel == checkpoint || ignore.matches(rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "file %s %o\n", rel, info.Mode().Perm())
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		return hashFile(h, path)
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to hash build context: %w", err)
+	}
+
+	if err := hashInputs(h, cfg); err != nil {
+		return "", fmt.Errorf("failed to hash inputs: %w", err)
+	}
+
+	fmt.Fprintf(h, "dockerfile %s\n", filepath.ToSlash(dockerfile))
+	if err := hashFile(h, dockerfile); err != nil {
+		return "", fmt.Errorf("failed to hash dockerfile: %w", err)
+	}
+
+	for _, key := range sortedKeys(cfg.BuildArgs) {
+		fmt.Fprintf(h, "arg %s=%s\n", key, cfg.BuildArgs[key])
+	}
+	for _, key := range sortedKeys(cfg.Labels) {
+		fmt.Fprintf(h, "label %s=%s\n", key, cfg.Labels[key])
+	}
+	fmt.Fprintf(h, "target %s\n", cfg.Target)
+	fmt.Fprintf(h, "platforms %s\n", strings.Join(cfg.Platforms, ","))
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sourceTag returns the tag under which an image built from digest is stored.
+func sourceTag(digest string) string {
+	return sourceTagPrefix + digest
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// writeFiles creates the given files (relative path -> content) below dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// chdir switches the working directory for the duration of the test.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+}
+
+func TestDockerignoreMatches(t *testing.T) {
+	ignore := &dockerignore{patterns: []ignorePattern{
+		{pattern: "node_modules"},
+		{pattern: "*.log"},
+		{pattern: "**/*.tmp"},
+		{pattern: "docs"},
+		{pattern: "docs/keep.md", negate: true},
+	}}
+
+	tests := []struct {
+		path     string
+		excluded bool
+	}{
+		{"node_modules/pkg/index.js", true},
+		{"build.log", true},
+		{"src/cache/file.tmp", true},
+		{"docs/guide.md", true},
+		{"docs/keep.md", false},
+		{"src/main.go", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := ignore.matches(tt.path); got != tt.excluded {
+				t.Errorf("matches(%q) = %v, want %v", tt.path, got, tt.excluded)
+			}
+		})
+	}
+}
+
+func TestComputeSourceDigest(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"Dockerfile":    "FROM scratch\nCOPY app /app\n",
+		"app":           "binary",
+		".dockerignore": "*.log\n",
+		"debug.log":     "noise",
+	})
+
+	cfg := &Config{
+		Context:    dir,
+		Dockerfile: filepath.Join(dir, "Dockerfile"),
+		BuildArgs:  map[string]string{"GO_VERSION": "1.22"},
+	}
+
+	first, err := computeSourceDigest(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(first) != 64 {
+		t.Fatalf("expected sha256 hex digest, got %q", first)
+	}
+
+	// Ignored files do not affect the digest.
+	writeFiles(t, dir, map[string]string{"debug.log": "more noise"})
+	second, err := computeSourceDigest(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first != second {
+		t.Error("expected digest to ignore .dockerignore'd files")
+	}
+
+	// Build args do.
+	cfg.BuildArgs["GO_VERSION"] = "1.23"
+	third, err := computeSourceDigest(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if third == second {
+		t.Error("expected digest to change with build args")
+	}
+
+	// So do context files.
+	cfg.BuildArgs["GO_VERSION"] = "1.22"
+	writeFiles(t, dir, map[string]string{"app": "new binary"})
+	fourth, err := computeSourceDigest(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fourth == second {
+		t.Error("expected digest to change with context contents")
+	}
+}
+
+func TestComputeSourceDigestMissingDockerfile(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &Config{Context: dir, Dockerfile: filepath.Join(dir, "Dockerfile")}
+
+	if _, err := computeSourceDigest(cfg); err == nil || !strings.Contains(err.Error(), "dockerfile") {
+		t.Errorf("expected dockerfile error, got %v", err)
+	}
+}
+
+func TestReuseIdentical(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"Dockerfile": "FROM scratch\n",
+	})
+	chdir(t, dir)
+
+	req := plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":           "myorg/myapp",
+			"tags":            []any{"{{version}}"},
+			"reuse_identical": true,
+		},
+		Context: plugin.ReleaseContext{Version: "v1.1.0"},
+	}
+
+	t.Run("retags existing image", func(t *testing.T) {
+		mock := &MockCommandExecutor{}
+		p := &DockerPlugin{executor: mock}
+
+		resp, err := p.Execute(ctx, req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !resp.Success {
+			t.Fatalf("expected success, got error: %s", resp.Error)
+		}
+		if resp.Outputs["reused"] != true {
+			t.Errorf("expected reused output, got %v", resp.Outputs["reused"])
+		}
+
+		if len(mock.RunCalls) != 1 {
+			t.Fatalf("expected only a retag call, got %d calls", len(mock.RunCalls))
+		}
+		args := mock.RunCalls[0].Args
+		if args[0] != "buildx" || args[1] != "imagetools" || !containsArg(args, "--tag", "myorg/myapp:1.1.0") {
+			t.Errorf("unexpected retag args: %v", args)
+		}
+		if !strings.HasPrefix(args[len(args)-1], "myorg/myapp:"+sourceTagPrefix) {
+			t.Errorf("expected retag from source tag, got %v", args)
+		}
+	})
+
+	t.Run("builds and publishes source tag when missing", func(t *testing.T) {
+		mock := &MockCommandExecutor{
+			OutputFunc: func(context.Context, string, []string) ([]byte, error) {
+				return nil, errors.New("not found")
+			},
+		}
+		p := &DockerPlugin{executor: mock}
+
+		resp, err := p.Execute(ctx, req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !resp.Success {
+			t.Fatalf("expected success, got error: %s", resp.Error)
+		}
+		digest, _ := resp.Outputs["source_digest"].(string)
+		if digest == "" {
+			t.Fatal("expected source_digest output")
+		}
+
+		build := mock.RunCalls[0].Args
+		if !containsArg(build, "-t", "myorg/myapp:"+sourceTag(digest)) {
+			t.Errorf("expected source tag in build, got %v", build)
+		}
+		if !containsArg(build, "--label", sourceDigestLabel+"="+digest) {
+			t.Errorf("expected source digest label, got %v", build)
+		}
+
+		pushCount := 0
+		for _, call := range mock.RunCalls {
+			if call.Args[0] == "push" {
+				pushCount++
+			}
+		}
+		if pushCount != 2 {
+			t.Errorf("expected version and source tags pushed, got %d pushes", pushCount)
+		}
+	})
+}
+
+func TestComputeSourceDigestIgnoresCheckpointFile(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+	writeFiles(t, dir, map[string]string{"Dockerfile": "FROM alpine\n", "main.go": "package main\n"})
+	cfg := &Config{CheckpointFile: "release.checkpoint.json"}
+
+	before, err := computeSourceDigest(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, dir, map[string]string{"release.checkpoint.json": `{"built": true}`})
+	after, err := computeSourceDigest(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before != after {
+		t.Error("expected the checkpoint file not to change the source digest")
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Canary defaults.
+const (
+	defaultCanaryTag             = "canary"
+	defaultCanaryApprovalTimeout = time.Hour
+)
+
+// CanaryConfig stages a release: the new digest is pushed under the canary
+// tag only, and the release tags are moved to it once the soak period has
+// passed and, if configured, an external approval signal appeared.
+type CanaryConfig struct {
+	// Tag receives the new digest first; it supports the tag placeholders.
+	Tag string
+	// Image is an optional repository in the same registry for the canary
+	// tag, e.g. myorg/myapp-canary.
+	Image string
+	Soak  string
+	// ApprovalURL and ApprovalFile replace {{version}} with the release
+	// version, so an approval of one release cannot approve another.
+	ApprovalURL  string
+	ApprovalFile string
+	// ApprovalToken, when set, must be the body of the approving response
+	// or the content of the approval file.
+	ApprovalToken   string
+	ApprovalTimeout string
+}
+
+// CanaryResult records the canary reference and how it was promoted.
+type CanaryResult struct {
+	Ref      string `json:"ref"`
+	Digest   string `json:"digest"`
+	Approval string `json:"approval,omitempty"`
+}
+
+// parseCanary reads the canary option, returning nil when it is not set.
+// Like the approval token, the approval token may also come from
+// DOCKER_APPROVAL_TOKEN.
+func parseCanary(raw map[string]any, token string) *CanaryConfig {
+	m, ok := raw["canary"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	canary := &CanaryConfig{ApprovalToken: token}
+	if t, ok := m["approval_token"].(string); ok && t != "" {
+		canary.ApprovalToken = t
+	}
+	canary.Tag, _ = m["tag"].(string)
+	canary.Image, _ = m["image"].(string)
+	canary.Soak, _ = m["soak"].(string)
+	canary.ApprovalURL, _ = m["approval_url"].(string)
+	canary.ApprovalFile, _ = m["approval_file"].(string)
+	canary.ApprovalTimeout, _ = m["approval_timeout"].(string)
+	if canary.Tag == "" {
+		canary.Tag = defaultCanaryTag
+	}
+	return canary
+}
+
+// validateCanary checks the canary settings.
+func validateCanary(cfg *Config) error {
+	canary := cfg.Canary
+	if canary == nil {
+		return nil
+	}
+	if canary.Image != "" {
+		if err := validateImageName(canary.Image); err != nil {
+			return fmt.Errorf("invalid image: %v", err)
+		}
+	}
+	for _, d := range []struct {
+		name  string
+		value string
+	}{
+		{"soak", canary.Soak},
+		{"approval_timeout", canary.ApprovalTimeout},
+	} {
+		if d.value == "" {
+			continue
+		}
+		if v, err := time.ParseDuration(d.value); err != nil || v < 0 {
+			return fmt.Errorf("%s must be a duration such as 30m", d.name)
+		}
+	}
+	if canary.ApprovalURL != "" && canary.ApprovalFile != "" {
+		return fmt.Errorf("approval_url and approval_file are mutually exclusive")
+	}
+	if canary.ApprovalURL != "" {
+		if err := validateApprovalURL(canary.ApprovalURL); err != nil {
+			return fmt.Errorf("approval_url %v", err)
+		}
+	}
+	if err := validatePath(canary.ApprovalFile); err != nil {
+		return fmt.Errorf("invalid approval_file: %v", err)
+	}
+	if canary.ApprovalTimeout != "" && canary.ApprovalURL == "" && canary.ApprovalFile == "" {
+		return fmt.Errorf("approval_timeout requires approval_url or approval_file")
+	}
+	return nil
+}
+
+// canaryRepository returns the repository holding the canary tag.
+func canaryRepository(cfg *Config) string {
+	if cfg.Canary.Image == "" {
+		return imageRepository(cfg)
+	}
+	return imageRepository(&Config{Registry: cfg.Registry, Image: cfg.Canary.Image})
+}
+
+// canaryRef returns the reference receiving the new digest first.
+func canaryRef(cfg *Config, version string) (string, error) {
+	tags, err := resolveTags([]string{cfg.Canary.Tag}, version, cfg.tagContext)
+	if err != nil {
+		return "", err
+	}
+	if len(tags) == 0 {
+		return "", fmt.Errorf("canary tag %q renders empty for version %s", cfg.Canary.Tag, version)
+	}
+	return fmt.Sprintf("%s:%s", canaryRepository(cfg), tags[0]), nil
+}
+
+// promoteCanary waits for the soak period and the approval of the release
+// of version, then moves the release tags to digest, the digest pushed
+// under ref. An empty digest is resolved up front, so a canary tag moved
+// during the wait does not change what is promoted.
+func (p *DockerPlugin) promoteCanary(ctx context.Context, cfg *Config, version, ref, digest string, targets []string) (*CanaryResult, error) {
+	if digest == "" {
+		resolved, err := p.resolveDigest(ctx, ref)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve digest of %s: %w", ref, err)
+		}
+		digest = resolved
+	}
+	result := &CanaryResult{Ref: ref, Digest: digest}
+
+	if soak, err := time.ParseDuration(cfg.Canary.Soak); err == nil && soak > 0 {
+		select {
+		case <-ctx.Done():
+			return result, fmt.Errorf("soak period interrupted: %w", ctx.Err())
+		case <-time.After(soak):
+		}
+	}
+
+	approval, err := p.awaitCanaryApproval(ctx, cfg.Canary, version)
+	if err != nil {
+		return result, err
+	}
+	result.Approval = approval
+
+	source := fmt.Sprintf("%s@%s", canaryRepository(cfg), digest)
+	if err := p.retagImage(ctx, source, targets); err != nil {
+		return result, fmt.Errorf("failed to move release tags to %s: %w", source, err)
+	}
+	return result, nil
+}
+
+// awaitCanaryApproval polls the approval URL or file of the release of
+// version until the rollout is approved, rejected or approval_timeout
+// passes. The URL approves with 200 OK and rejects with 403 or 410; the
+// file approves by existing. With approval_token, the approving body or
+// file must hold the token. An approving file is removed, so it cannot
+// approve a later release. It returns the approving signal, or "" when no
+// approval is configured.
+func (p *DockerPlugin) awaitCanaryApproval(ctx context.Context, canary *CanaryConfig, version string) (string, error) {
+	if canary.ApprovalURL == "" && canary.ApprovalFile == "" {
+		return "", nil
+	}
+	timeout := defaultCanaryApprovalTimeout
+	if d, err := time.ParseDuration(canary.ApprovalTimeout); err == nil && d > 0 {
+		timeout = d
+	}
+	approvalURL := strings.ReplaceAll(canary.ApprovalURL, "{{version}}", url.PathEscape(version))
+	approvalFile := strings.ReplaceAll(canary.ApprovalFile, "{{version}}", version)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		if approvalFile != "" {
+			approved, err := consumeApprovalFile(approvalFile, canary.ApprovalToken)
+			if err != nil {
+				return "", fmt.Errorf("canary rollout %w", err)
+			}
+			if approved {
+				return approvalFile, nil
+			}
+		} else {
+			approved, err := p.checkApprovalURL(ctx, approvalURL, canary.ApprovalToken)
+			if err != nil {
+				return "", fmt.Errorf("canary rollout %w", err)
+			}
+			if approved {
+				return approvalURL, nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("canary was not approved within %s", timeout)
+		case <-time.After(approvalPollInterval):
+		}
+	}
+}
+
+// consumeApprovalFile reports whether the approval file exists and, with a
+// token, holds it, and removes an approving file.
+func consumeApprovalFile(path, token string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, nil
+	}
+	if token != "" && subtle.ConstantTimeCompare(bytes.TrimSpace(data), []byte(token)) != 1 {
+		return false, nil
+	}
+	if err := os.Remove(path); err != nil {
+		return false, fmt.Errorf("approval could not be consumed: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// canaryDigests returns an OutputFunc resolving every reference to testDigest.
+func canaryDigests(_ context.Context, _ string, args []string) ([]byte, error) {
+	if containsArg(args, "--format", "{{.Manifest.Digest}}") {
+		return []byte(testDigest + "\n"), nil
+	}
+	return nil, nil
+}
+
+func TestValidateCanary(t *testing.T) {
+	tests := []struct {
+		name    string
+		canary  *CanaryConfig
+		wantErr bool
+	}{
+		{"unset", nil, false},
+		{"soak only", &CanaryConfig{Tag: "canary", Soak: "30m"}, false},
+		{"approval url", &CanaryConfig{Tag: "canary", ApprovalURL: "https://deploy.example.com/approve", ApprovalTimeout: "2h"}, false},
+		{"approval file", &CanaryConfig{Tag: "canary", ApprovalFile: "approved"}, false},
+		{"invalid soak", &CanaryConfig{Tag: "canary", Soak: "a while"}, true},
+		{"invalid image", &CanaryConfig{Tag: "canary", Image: "app;rm"}, true},
+		{"both approvals", &CanaryConfig{Tag: "canary", ApprovalURL: "https://example.com", ApprovalFile: "approved"}, true},
+		{"invalid url", &CanaryConfig{Tag: "canary", ApprovalURL: "file:///approved"}, true},
+		{"file outside workdir", &CanaryConfig{Tag: "canary", ApprovalFile: "../approved"}, true},
+		{"timeout without approval", &CanaryConfig{Tag: "canary", ApprovalTimeout: "1h"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateCanary(&Config{Canary: tt.canary}); (err != nil) != tt.wantErr {
+				t.Errorf("validateCanary() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCanaryRef(t *testing.T) {
+	tests := []struct {
+		name     string
+		canary   *CanaryConfig
+		expected string
+	}{
+		{"default tag", &CanaryConfig{Tag: defaultCanaryTag}, "ghcr.io/org/app:canary"},
+		{"templated tag", &CanaryConfig{Tag: "{{version}}-canary"}, "ghcr.io/org/app:1.2.3-canary"},
+		{"canary repository", &CanaryConfig{Tag: "latest", Image: "org/app-canary"}, "ghcr.io/org/app-canary:latest"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref, err := canaryRef(&Config{Registry: "ghcr.io", Image: "org/app", Canary: tt.canary}, "v1.2.3")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ref != tt.expected {
+				t.Errorf("canaryRef() = %q, want %q", ref, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCanaryRefRendersEmpty(t *testing.T) {
+	_, err := canaryRef(&Config{Image: "app", Canary: &CanaryConfig{Tag: "{{prerelease}}"}}, "1.0.0")
+	if err == nil || !strings.Contains(err.Error(), "renders empty") {
+		t.Errorf("expected an empty canary tag to be rejected, got %v", err)
+	}
+}
+
+func TestExecuteCanaryPromotesAfterApproval(t *testing.T) {
+	fastApprovalPolling(t)
+	dir := t.TempDir()
+	chdir(t, dir)
+	writeFiles(t, dir, map[string]string{"approved": ""})
+
+	mock := &MockCommandExecutor{OutputFunc: canaryDigests}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":  "myapp",
+			"canary": map[string]any{"approval_file": "approved"},
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+
+	var calls []string
+	for _, call := range mock.RunCalls {
+		calls = append(calls, strings.Join(call.Args, " "))
+	}
+	want := []string{
+		"tag myapp:1.0.0 myapp:canary",
+		"push myapp:canary",
+		"buildx imagetools create --tag myapp:1.0.0 --tag myapp:latest myapp@" + testDigest,
+	}
+	if got := strings.Join(calls[1:], "\n"); got != strings.Join(want, "\n") {
+		t.Errorf("unexpected calls after build:\n%s", got)
+	}
+
+	result, _ := resp.Outputs["canary"].(*CanaryResult)
+	if result == nil || result.Ref != "myapp:canary" || result.Approval != "approved" {
+		t.Errorf("unexpected canary output: %+v", resp.Outputs["canary"])
+	}
+	if resp.Outputs["digest"] != testDigest {
+		t.Errorf("expected promoted digest in outputs, got %v", resp.Outputs["digest"])
+	}
+	if _, err := os.Stat(filepath.Join(dir, "approved")); !os.IsNotExist(err) {
+		t.Errorf("expected the approval file to be consumed, got %v", err)
+	}
+}
+
+func TestAwaitCanaryApprovalIsReleaseSpecific(t *testing.T) {
+	fastApprovalPolling(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/approvals/1.2.3" {
+			w.Write([]byte("s3cret\n"))
+			return
+		}
+		w.Write([]byte("<html>status page</html>"))
+	}))
+	defer server.Close()
+	p := &DockerPlugin{httpClient: server.Client()}
+
+	byURL := &CanaryConfig{ApprovalURL: server.URL + "/approvals/{{version}}", ApprovalToken: "s3cret", ApprovalTimeout: "20ms"}
+	if signal, err := p.awaitCanaryApproval(context.Background(), byURL, "1.2.3"); err != nil || signal != server.URL+"/approvals/1.2.3" {
+		t.Errorf("expected the release URL to approve, got %q, %v", signal, err)
+	}
+	if _, err := p.awaitCanaryApproval(context.Background(), byURL, "1.2.4"); err == nil || !strings.Contains(err.Error(), "not approved") {
+		t.Errorf("expected a 200 OK without the token to stay pending, got %v", err)
+	}
+
+	dir := t.TempDir()
+	chdir(t, dir)
+	writeFiles(t, dir, map[string]string{"approvals/1.2.2": "s3cret", "approvals/1.2.3": "wrong"})
+	byFile := &CanaryConfig{ApprovalFile: "approvals/{{version}}", ApprovalToken: "s3cret", ApprovalTimeout: "20ms"}
+	if _, err := p.awaitCanaryApproval(context.Background(), byFile, "1.2.3"); err == nil {
+		t.Error("expected an approval file without the token to stay pending")
+	}
+	if _, err := p.awaitCanaryApproval(context.Background(), byFile, "1.2.2"); err != nil {
+		t.Errorf("expected the approval file of 1.2.2 to approve it, got %v", err)
+	}
+	if _, err := p.awaitCanaryApproval(context.Background(), byFile, "1.2.2"); err == nil {
+		t.Error("expected the consumed approval file to not approve again")
+	}
+}
+
+func TestExecuteCanaryRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	mock := &MockCommandExecutor{OutputFunc: canaryDigests}
+	p := &DockerPlugin{executor: mock, httpClient: server.Client()}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":   "myapp",
+			"builder": "release",
+			"canary":  map[string]any{"tag": "{{version}}-canary", "approval_url": server.URL},
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success || !strings.Contains(resp.Error, "rejected") {
+		t.Fatalf("expected rejected canary, got %+v", resp)
+	}
+
+	for _, call := range mock.RunCalls {
+		if containsFlag(call.Args, "build") && !containsArg(call.Args, "-t", "myapp:1.0.0-canary") {
+			t.Errorf("expected build to push the canary tag, got %v", call.Args)
+		}
+		if containsArg(call.Args, "-t", "myapp:latest") || containsArg(call.Args, "--tag", "myapp:latest") {
+			t.Errorf("expected release tags to stay untouched, got %v", call.Args)
+		}
+	}
+	if refs, _ := resp.Outputs["pushed_refs"].([]string); len(refs) != 1 || refs[0] != "myapp:1.0.0-canary" {
+		t.Errorf("expected only the canary ref reported as pushed, got %v", resp.Outputs["pushed_refs"])
+	}
+}
+
+func TestExecuteCanaryApprovalTimeout(t *testing.T) {
+	fastApprovalPolling(t)
+	chdir(t, t.TempDir())
+
+	mock := &MockCommandExecutor{OutputFunc: canaryDigests}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":  "myapp",
+			"canary": map[string]any{"approval_file": filepath.Join("signals", "approved"), "approval_timeout": "20ms"},
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success || !strings.Contains(resp.Error, "not approved within 20ms") {
+		t.Fatalf("expected approval timeout, got %+v", resp)
+	}
+}
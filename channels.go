@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// stableChannel is the channel of releases without a prerelease.
+const stableChannel = "stable"
+
+// channelNamePattern matches channel names: the first prerelease
+// identifier, lowercased, as {{channel}} renders it.
+var channelNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// tagTemplates returns the tag templates every release uses. With
+// channel_tags, the floating tags belong to the channels, so tags default
+// to the version alone.
+func tagTemplates(cfg *Config) []string {
+	switch {
+	case len(cfg.Tags) > 0:
+		return cfg.Tags
+	case len(cfg.ChannelTags) > 0:
+		return []string{"{{version}}"}
+	}
+	return []string{"{{version}}", "latest"}
+}
+
+// allTagTemplates returns the tag templates of every channel.
+func allTagTemplates(cfg *Config) []string {
+	templates := slices.Clone(tagTemplates(cfg))
+	for _, channel := range sortedKeys(cfg.ChannelTags) {
+		templates = append(templates, cfg.ChannelTags[channel]...)
+	}
+	return templates
+}
+
+// versionChannel returns the channel of a release version.
+func versionChannel(version string) string {
+	_, prerelease := splitPrerelease(strings.TrimPrefix(version, "v"))
+	return releaseChannel(prerelease)
+}
+
+// applyChannelTags adds the tags of the channel of the release to its tags,
+// so the tags of other channels are never written.
+func applyChannelTags(cfg *Config, version string) {
+	if len(cfg.ChannelTags) == 0 {
+		return
+	}
+	cfg.Tags = append(slices.Clone(tagTemplates(cfg)), cfg.ChannelTags[versionChannel(version)]...)
+}
+
+// validateChannelTags checks channel_tags and that no two channels write
+// the same tag: each channel's tags are rendered for a sample release of
+// the channel, and a tag rendered by two channels, such as a latest shared
+// through tags, would be moved by both. Tags that name one release, such
+// as {{version}}, cannot conflict and are not compared, nor is the latest
+// that prereleases drop.
+func validateChannelTags(cfg *Config) error {
+	if len(cfg.ChannelTags) == 0 {
+		return nil
+	}
+	channels := sortedKeys(cfg.ChannelTags)
+	for _, channel := range channels {
+		if !channelNamePattern.MatchString(channel) {
+			return fmt.Errorf("invalid channel %q: use the first prerelease identifier in lowercase, e.g. rc, or %s", channel, stableChannel)
+		}
+		if len(cfg.ChannelTags[channel]) == 0 {
+			return fmt.Errorf("channel %s has no tags", channel)
+		}
+		if err := validateTagTemplates(cfg.ChannelTags[channel]); err != nil {
+			return fmt.Errorf("channel %s: %v", channel, err)
+		}
+	}
+	if !slices.Contains(channels, stableChannel) {
+		channels = append([]string{stableChannel}, channels...)
+	}
+
+	type writer struct{ channel, template string }
+	writers := make(map[string]writer)
+	for _, channel := range channels {
+		version := "1.4.0"
+		if channel != stableChannel {
+			version += "-" + channel + ".1"
+		}
+		data := sampleTagContext.data(version)
+		for _, template := range append(slices.Clone(tagTemplates(cfg)), cfg.ChannelTags[channel]...) {
+			if containsAny(template, versionPlaceholders) || template == "latest" && channel != stableChannel && !cfg.LatestOnPrerelease {
+				continue
+			}
+			tag, err := renderTag(template, data)
+			if err != nil || tag == "" {
+				continue
+			}
+			if w, ok := writers[tag]; ok && w.channel != channel {
+				return fmt.Errorf("channels %s and %s both write tag %q (from %q and %q)", w.channel, channel, tag, w.template, template)
+			}
+			writers[tag] = writer{channel, template}
+		}
+	}
+	return nil
+}
+
+// dropLatestOnPrerelease removes latest from the tags of a prerelease,
+// unless latest_on_prerelease is set, so a release candidate is never
+// pulled as latest. A prerelease is still tagged with its version when
+// latest was its only tag.
+func dropLatestOnPrerelease(cfg *Config, version string) {
+	if cfg.LatestOnPrerelease || versionChannel(version) == stableChannel {
+		return
+	}
+	tags := slices.DeleteFunc(slices.Clone(tagTemplates(cfg)), func(tag string) bool { return tag == "latest" })
+	if len(tags) == 0 {
+		tags = []string{"{{version}}"}
+	}
+	cfg.Tags = tags
+}
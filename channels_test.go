@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestApplyChannelTags(t *testing.T) {
+	channels := map[string][]string{"stable": {"latest", "{{major}}"}, "rc": {"next"}}
+
+	stable := &Config{ChannelTags: channels}
+	applyChannelTags(stable, "v1.4.0")
+	if !slices.Equal(stable.Tags, []string{"{{version}}", "latest", "{{major}}"}) {
+		t.Errorf("unexpected stable tags: %v", stable.Tags)
+	}
+	rc := &Config{Tags: []string{"{{version}}", "{{short_sha}}"}, ChannelTags: channels}
+	applyChannelTags(rc, "1.4.0-RC.2")
+	if !slices.Equal(rc.Tags, []string{"{{version}}", "{{short_sha}}", "next"}) {
+		t.Errorf("unexpected rc tags: %v", rc.Tags)
+	}
+	beta := &Config{ChannelTags: channels}
+	applyChannelTags(beta, "1.4.0-beta.1")
+	if !slices.Equal(beta.Tags, []string{"{{version}}"}) {
+		t.Errorf("expected a channel without tags to get the shared tags only, got %v", beta.Tags)
+	}
+}
+
+func TestValidateChannelTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *Config
+		wantErr string
+	}{
+		{"isolated", &Config{ChannelTags: map[string][]string{"stable": {"latest", "{{major}}"}, "rc": {"next", "{{major}}-rc"}}}, ""},
+		{"per-channel shared tags", &Config{Tags: []string{"{{version}}", "{{channel}}", "{{if not .IsPrerelease}}latest{{end}}"}, ChannelTags: map[string][]string{"rc": {"next"}}}, ""},
+		{"latest dropped by prereleases", &Config{Tags: []string{"{{version}}", "latest"}, ChannelTags: map[string][]string{"rc": {"next"}}}, ""},
+		{"shared latest", &Config{Tags: []string{"{{version}}", "latest"}, ChannelTags: map[string][]string{"rc": {"next"}}, LatestOnPrerelease: true}, `channels stable and rc both write tag "latest"`},
+		{"shared major", &Config{Tags: []string{"{{version}}", "{{major}}"}, ChannelTags: map[string][]string{"rc": {"next"}}}, `channels stable and rc both write tag "1"`},
+		{"same tag in two channels", &Config{ChannelTags: map[string][]string{"stable": {"{{major}}"}, "beta": {"{{major}}"}}}, `channels beta and stable both write tag "1"`},
+		{"invalid channel", &Config{ChannelTags: map[string][]string{"RC": {"next"}}}, "invalid channel"},
+		{"empty channel", &Config{ChannelTags: map[string][]string{"rc": nil}}, "has no tags"},
+		{"invalid template", &Config{ChannelTags: map[string][]string{"rc": {"{{commit}}"}}}, "channel rc: invalid tag template"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateChannelTags(tt.cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestExecutePrereleaseSkipsStableChannelTags(t *testing.T) {
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock}
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":        "myorg/myapp",
+			"channel_tags": map[string]any{"stable": []any{"latest"}, "rc": "next"},
+		},
+		Context: plugin.ReleaseContext{Version: "2.0.0-rc.1"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+
+	var pushed []string
+	for _, call := range mock.RunCalls {
+		if len(call.Args) == 2 && call.Args[0] == "push" {
+			pushed = append(pushed, call.Args[1])
+		}
+	}
+	if !slices.Equal(pushed, []string{"myorg/myapp:2.0.0-rc.1", "myorg/myapp:next"}) {
+		t.Errorf("expected only the rc channel tags, got %v", pushed)
+	}
+}
+
+func TestDropLatestOnPrerelease(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *Config
+		version string
+		want    []string
+	}{
+		{"implicit latest", &Config{}, "1.4.0-rc.1", []string{"{{version}}"}},
+		{"configured latest", &Config{Tags: []string{"{{version}}", "latest", "{{channel}}"}}, "1.4.0-beta.2", []string{"{{version}}", "{{channel}}"}},
+		{"only latest", &Config{Tags: []string{"latest"}}, "1.4.0-rc.1", []string{"{{version}}"}},
+		{"stable", &Config{Tags: []string{"{{version}}", "latest"}}, "1.4.0", []string{"{{version}}", "latest"}},
+		{"opted in", &Config{Tags: []string{"{{version}}", "latest"}, LatestOnPrerelease: true}, "1.4.0-rc.1", []string{"{{version}}", "latest"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dropLatestOnPrerelease(tt.cfg, tt.version)
+			if got := tagTemplates(tt.cfg); !slices.Equal(got, tt.want) {
+				t.Errorf("tags = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
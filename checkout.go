@@ -0,0 +1,187 @@
+package main
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// cleanCheckout exports the release tag, or the release commit without a
+// tag, with git archive into a temporary directory and points the build
+// context and Dockerfile at it, so uncommitted and ignored files of the
+// working tree cannot reach the image. The returned function removes the
+// directory.
+func (p *DockerPlugin) cleanCheckout(ctx context.Context, cfg *Config, releaseCtx plugin.ReleaseContext) (func(), error) {
+	ref := releaseCtx.TagName
+	if ref == "" {
+		ref = releaseCtx.CommitSHA
+	}
+	if ref == "" {
+		return nil, fmt.Errorf("clean_checkout requires the release tag or commit")
+	}
+	if strings.HasPrefix(ref, "-") {
+		return nil, fmt.Errorf("invalid git ref %q", ref)
+	}
+
+	root, err := os.MkdirTemp("", "relicta-docker-checkout-*")
+	if err != nil {
+		return nil, err
+	}
+	cleanup := func() { os.RemoveAll(root) }
+
+	archive := filepath.Join(root, "source.tar")
+	if err := p.getExecutor().Run(ctx, "git", []string{"archive", "--format=tar", "--output=" + archive, ref}, nil); err != nil {
+		cleanup()
+		return nil, fmt.Errorf("git archive %s: %w", ref, err)
+	}
+	source := filepath.Join(root, "source")
+	if err := extractTar(archive, source); err != nil {
+		cleanup()
+		return nil, fmt.Errorf("failed to extract git archive of %s: %w", ref, err)
+	}
+
+	buildContext := cfg.Context
+	if buildContext == "" {
+		buildContext = "."
+	}
+	dockerfile := cfg.Dockerfile
+	if dockerfile == "" {
+		dockerfile = "Dockerfile"
+	}
+	cfg.Context = filepath.Join(source, buildContext)
+	cfg.Dockerfile = filepath.Join(source, dockerfile)
+	return cleanup, nil
+}
+
+// extractTar extracts the archive into dir. Entries escaping dir are
+// rejected.
+func extractTar(archive, dir string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	r := tar.NewReader(f)
+	for {
+		header, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := filepath.Clean(filepath.FromSlash(header.Name))
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("invalid path %q in archive", header.Name)
+		}
+		target := filepath.Join(dir, name)
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, header.FileInfo().Mode().Perm())
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(out, r)
+			if closeErr := out.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return err
+			}
+		}
+		// git archive also writes a global header recording the commit.
+	}
+}
+
+// maxDirtyFiles bounds the modified files named by checkCleanWorktree.
+const maxDirtyFiles = 5
+
+// checkCleanWorktree fails when the build context or the Dockerfile has
+// uncommitted changes or untracked files, which would publish code that is
+// not in the release tag. Ignored files are not reported.
+func (p *DockerPlugin) checkCleanWorktree(ctx context.Context, cfg *Config) error {
+	buildContext := cfg.Context
+	if buildContext == "" {
+		buildContext = "."
+	}
+	dockerfile := cfg.Dockerfile
+	if dockerfile == "" {
+		dockerfile = "Dockerfile"
+	}
+
+	out, err := p.getExecutor().Output(ctx, "git", []string{"status", "--porcelain", "--", buildContext, dockerfile})
+	if err != nil {
+		return fmt.Errorf("git status: %w", err)
+	}
+	var dirty []string
+	for _, line := range strings.Split(strings.TrimRight(string(out), "\n"), "\n") {
+		if len(line) > 3 {
+			dirty = append(dirty, strings.TrimSpace(line[3:]))
+		}
+	}
+	if len(dirty) == 0 {
+		return nil
+	}
+	named := dirty
+	if len(named) > maxDirtyFiles {
+		named = append(named[:maxDirtyFiles:maxDirtyFiles], fmt.Sprintf("and %d more", len(dirty)-maxDirtyFiles))
+	}
+	return fmt.Errorf("%d uncommitted changes in the build context: %s", len(dirty), strings.Join(named, ", "))
+}
+
+// verifyReleaseCommit fails when the commit being built is not the commit of
+// the release, e.g. when the tag was moved or the job checked out another
+// branch. A clean checkout builds the release tag, otherwise HEAD is built.
+func (p *DockerPlugin) verifyReleaseCommit(ctx context.Context, cfg *Config, releaseCtx plugin.ReleaseContext) error {
+	if releaseCtx.CommitSHA == "" {
+		return fmt.Errorf("verify_commit requires the release commit")
+	}
+	ref := "HEAD"
+	if cfg.CleanCheckout && releaseCtx.TagName != "" {
+		if strings.HasPrefix(releaseCtx.TagName, "-") {
+			return fmt.Errorf("invalid git ref %q", releaseCtx.TagName)
+		}
+		ref = releaseCtx.TagName
+	}
+
+	out, err := p.getExecutor().Output(ctx, "git", []string{"rev-parse", "--verify", ref + "^{commit}"})
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", ref, err)
+	}
+	built := strings.TrimSpace(string(out))
+	if built == "" {
+		return fmt.Errorf("failed to resolve %s: no commit", ref)
+	}
+	// The release commit may be abbreviated.
+	if !strings.HasPrefix(built, strings.ToLower(releaseCtx.CommitSHA)) {
+		return fmt.Errorf("you are releasing a different commit than you tagged: %s is %s, the release commit is %s", ref, built, releaseCtx.CommitSHA)
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// writeTestArchive writes a tar archive holding files to path, as git
+// archive does.
+func writeTestArchive(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := tar.NewWriter(f)
+	if err := w.WriteHeader(&tar.Header{Typeflag: tar.TypeXGlobalHeader, Name: "pax_global_header", PAXRecords: map[string]string{"comment": "abc123"}}); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := w.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0o644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(w, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// archiveOutput returns the --output path of a git archive call.
+func archiveOutput(args []string) string {
+	for _, arg := range args {
+		if path, ok := strings.CutPrefix(arg, "--output="); ok {
+			return path
+		}
+	}
+	return ""
+}
+
+func TestCleanCheckout(t *testing.T) {
+	var ref string
+	mock := &MockCommandExecutor{
+		RunFunc: func(_ context.Context, name string, args []string, _ io.Reader) error {
+			ref = args[len(args)-1]
+			writeTestArchive(t, archiveOutput(args), map[string]string{"docker/Dockerfile": "FROM alpine\n", "app/main.go": "package main"})
+			return nil
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+	cfg := &Config{Dockerfile: "docker/Dockerfile"}
+
+	cleanup, err := p.cleanCheckout(context.Background(), cfg, plugin.ReleaseContext{TagName: "v1.0.0", CommitSHA: "abc123"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ref != "v1.0.0" {
+		t.Errorf("expected the release tag to be archived, got %q", ref)
+	}
+	if data, err := os.ReadFile(filepath.Join(cfg.Context, "app/main.go")); err != nil || string(data) != "package main" {
+		t.Errorf("expected the archived context, got %q, %v", data, err)
+	}
+	if data, err := os.ReadFile(cfg.Dockerfile); err != nil || string(data) != "FROM alpine\n" {
+		t.Errorf("expected the archived Dockerfile, got %q, %v", data, err)
+	}
+
+	cleanup()
+	if _, err := os.Stat(cfg.Context); !os.IsNotExist(err) {
+		t.Errorf("expected the checkout to be removed, got %v", err)
+	}
+}
+
+func TestCleanCheckoutWithoutRef(t *testing.T) {
+	p := &DockerPlugin{executor: &MockCommandExecutor{}}
+	if _, err := p.cleanCheckout(context.Background(), &Config{}, plugin.ReleaseContext{}); err == nil {
+		t.Error("expected an error without a release tag or commit")
+	}
+}
+
+func TestExtractTarRejectsEscapes(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "evil.tar")
+	writeTestArchive(t, archive, map[string]string{"../evil": "x"})
+	if err := extractTar(archive, t.TempDir()); err == nil {
+		t.Error("expected an entry outside the directory to be rejected")
+	}
+}
+
+func TestExecuteCleanCheckout(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"Dockerfile": "FROM alpine\n", "secret.env": "TOKEN=uncommitted"})
+	chdir(t, dir)
+
+	var buildContext string
+	mock := &MockCommandExecutor{
+		RunFunc: func(_ context.Context, name string, args []string, _ io.Reader) error {
+			switch {
+			case name == "git":
+				writeTestArchive(t, archiveOutput(args), map[string]string{"Dockerfile": "FROM alpine\n"})
+			case name == "docker" && args[0] == "build":
+				buildContext = args[len(args)-1]
+				if _, err := os.Stat(filepath.Join(buildContext, "secret.env")); !os.IsNotExist(err) {
+					t.Errorf("expected uncommitted files to be left out, got %v", err)
+				}
+			}
+			return nil
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"image": "myapp", "clean_checkout": true},
+		Context: plugin.ReleaseContext{Version: "1.0.0", TagName: "v1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+	if buildContext == "" || buildContext == "." {
+		t.Fatalf("expected the build to use the checkout, got context %q", buildContext)
+	}
+	if _, err := os.Stat(buildContext); !os.IsNotExist(err) {
+		t.Errorf("expected the checkout to be removed after the release, got %v", err)
+	}
+}
+
+func TestCheckCleanWorktree(t *testing.T) {
+	t.Run("clean", func(t *testing.T) {
+		mock := &MockCommandExecutor{}
+		p := &DockerPlugin{executor: mock}
+		if err := p.checkCleanWorktree(context.Background(), &Config{Context: "services/api"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := strings.Join(mock.OutputCalls[0].Args, " "); got != "status --porcelain -- services/api Dockerfile" {
+			t.Errorf("unexpected git status args: %s", got)
+		}
+	})
+
+	t.Run("dirty", func(t *testing.T) {
+		mock := &MockCommandExecutor{
+			OutputFunc: func(context.Context, string, []string) ([]byte, error) {
+				return []byte(" M main.go\n?? debug.log\nM  go.mod\nA  a\nA  b\nA  c\n"), nil
+			},
+		}
+		p := &DockerPlugin{executor: mock}
+		err := p.checkCleanWorktree(context.Background(), &Config{})
+		if err == nil || !strings.Contains(err.Error(), "6 uncommitted changes in the build context: main.go, debug.log, go.mod, a, b, and 1 more") {
+			t.Errorf("expected the dirty files to be named, got %v", err)
+		}
+	})
+}
+
+func TestExecuteRequireCleanWorktree(t *testing.T) {
+	mock := &MockCommandExecutor{
+		OutputFunc: func(_ context.Context, name string, args []string) ([]byte, error) {
+			if name == "git" {
+				return []byte(" M main.go\n"), nil
+			}
+			return nil, nil
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"image": "myapp", "require_clean_worktree": true},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success || !strings.Contains(resp.Error, "working tree is not clean") {
+		t.Fatalf("expected a dirty worktree failure, got %+v", resp)
+	}
+	if len(mock.RunCalls) != 0 {
+		t.Errorf("expected nothing to be built, got %v", mock.RunCalls)
+	}
+}
+
+func TestVerifyReleaseCommit(t *testing.T) {
+	head := func(sha string) *MockCommandExecutor {
+		return &MockCommandExecutor{
+			OutputFunc: func(context.Context, string, []string) ([]byte, error) {
+				return []byte(sha + "\n"), nil
+			},
+		}
+	}
+	release := plugin.ReleaseContext{TagName: "v1.0.0", CommitSHA: "abc1234"}
+
+	t.Run("match", func(t *testing.T) {
+		mock := head("abc1234def5678")
+		p := &DockerPlugin{executor: mock}
+		if err := p.verifyReleaseCommit(context.Background(), &Config{}, release); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := strings.Join(mock.OutputCalls[0].Args, " "); got != "rev-parse --verify HEAD^{commit}" {
+			t.Errorf("unexpected git rev-parse args: %s", got)
+		}
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		p := &DockerPlugin{executor: head("fff0000")}
+		err := p.verifyReleaseCommit(context.Background(), &Config{}, release)
+		if err == nil || !strings.Contains(err.Error(), "you are releasing a different commit than you tagged: HEAD is fff0000, the release commit is abc1234") {
+			t.Errorf("expected a commit mismatch, got %v", err)
+		}
+	})
+
+	t.Run("clean checkout", func(t *testing.T) {
+		mock := head("abc1234")
+		p := &DockerPlugin{executor: mock}
+		if err := p.verifyReleaseCommit(context.Background(), &Config{CleanCheckout: true}, release); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := mock.OutputCalls[0].Args[2]; got != "v1.0.0^{commit}" {
+			t.Errorf("expected the release tag to be resolved, got %s", got)
+		}
+	})
+
+	t.Run("without commit", func(t *testing.T) {
+		p := &DockerPlugin{executor: head("abc1234")}
+		if err := p.verifyReleaseCommit(context.Background(), &Config{}, plugin.ReleaseContext{}); err == nil {
+			t.Error("expected an error without a release commit")
+		}
+	})
+}
+
+func TestExecuteVerifyCommitMismatch(t *testing.T) {
+	mock := &MockCommandExecutor{
+		OutputFunc: func(_ context.Context, name string, args []string) ([]byte, error) {
+			if name == "git" {
+				return []byte("fff0000\n"), nil
+			}
+			return nil, nil
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"image": "myapp", "verify_commit": true},
+		Context: plugin.ReleaseContext{Version: "1.0.0", CommitSHA: "abc1234"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success || !strings.Contains(resp.Error, "you are releasing a different commit than you tagged") {
+		t.Fatalf("expected a commit mismatch failure, got %+v", resp)
+	}
+	if len(mock.RunCalls) != 0 {
+		t.Errorf("expected nothing to be built, got %v", mock.RunCalls)
+	}
+}
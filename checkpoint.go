@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Checkpoint records the stages a release completed, so a re-run with
+// resume continues after them. It applies only to a re-run of the same
+// version, references and source.
+type Checkpoint struct {
+	Version      string   `json:"version"`
+	SourceDigest string   `json:"source_digest"`
+	Refs         []string `json:"refs"`
+	Built        bool     `json:"built"`
+	// ImageID identifies the local image of a completed classic build, and
+	// Digest the image a completed buildx build pushed.
+	ImageID    string   `json:"image_id,omitempty"`
+	Digest     string   `json:"digest,omitempty"`
+	PushedRefs []string `json:"pushed_refs,omitempty"`
+
+	path string
+	// saveErr is the first failure to write the checkpoint.
+	saveErr error
+}
+
+// checkpointPath returns checkpoint_file, or by default a file in the system
+// temporary directory named after the sorted refs, outside the build
+// context.
+func checkpointPath(cfg *Config, refs []string) string {
+	if cfg.CheckpointFile != "" {
+		return cfg.CheckpointFile
+	}
+	sorted := append([]string{}, refs...)
+	sort.Strings(sorted)
+	sum := sha256.Sum256([]byte(strings.Join(sorted, "\n")))
+	return filepath.Join(os.TempDir(), "relicta-docker-"+hex.EncodeToString(sum[:8])+".checkpoint.json")
+}
+
+// loadCheckpoint returns the checkpoint of the release, or a new one when
+// the file is missing or belongs to another release. The warning reports a
+// checkpoint that was discarded.
+func loadCheckpoint(cfg *Config, version, sourceDigest string, refs []string) (*Checkpoint, string) {
+	path := checkpointPath(cfg, refs)
+	fresh := &Checkpoint{Version: version, SourceDigest: sourceDigest, Refs: refs, path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fresh, ""
+	}
+	var loaded Checkpoint
+	if err == nil {
+		err = json.Unmarshal(data, &loaded)
+	}
+	if err != nil {
+		return fresh, fmt.Sprintf("ignored unreadable checkpoint %s: %v", path, err)
+	}
+	if loaded.Version != version || loaded.SourceDigest != sourceDigest || strings.Join(loaded.Refs, ",") != strings.Join(refs, ",") {
+		return fresh, fmt.Sprintf("ignored checkpoint %s of release %s: the version, tags or source changed", path, loaded.Version)
+	}
+	loaded.path = path
+	return &loaded, ""
+}
+
+// save writes the checkpoint. Failures are kept for the final warning
+// rather than failing the release.
+func (c *Checkpoint) save() {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err == nil {
+		err = os.WriteFile(c.path, append(data, '\n'), 0o644)
+	}
+	if err != nil && c.saveErr == nil {
+		c.saveErr = err
+	}
+}
+
+// warning reports a checkpoint that could not be written.
+func (c *Checkpoint) warning() string {
+	if c == nil || c.saveErr == nil {
+		return ""
+	}
+	return fmt.Sprintf("failed to write checkpoint %s: %v", c.path, c.saveErr)
+}
+
+// remove deletes the checkpoint of a completed release.
+func (c *Checkpoint) remove() {
+	if c != nil {
+		os.Remove(c.path)
+	}
+}
+
+// resumeBuild reports whether the build completed by a previous run can be
+// reused: the classic image must still be in the local daemon, and the
+// image pushed by buildx must still be what the first reference points at.
+func (p *DockerPlugin) resumeBuild(ctx context.Context, cfg *Config, c *Checkpoint, imageNames, pushNames []string) bool {
+	if c == nil || !c.Built || len(imageNames) == 0 {
+		return false
+	}
+	if useBuildx(cfg) {
+		if !cfg.Push || c.Digest == "" {
+			return false
+		}
+		digest, err := p.resolveDigest(ctx, pushNames[0])
+		return err == nil && digest == c.Digest
+	}
+	id, err := p.localImageID(ctx, imageNames[0])
+	return err == nil && id == c.ImageID
+}
+
+// recordBuild checkpoints a completed build. Pushes of an earlier build are
+// forgotten, since they may hold a different image.
+func (p *DockerPlugin) recordBuild(ctx context.Context, cfg *Config, c *Checkpoint, imageNames, pushNames []string) {
+	if c == nil || len(imageNames) == 0 {
+		return
+	}
+	c.Built, c.ImageID, c.Digest, c.PushedRefs = true, "", "", nil
+	if useBuildx(cfg) {
+		if cfg.Push {
+			c.Digest, _ = p.resolveDigest(ctx, pushNames[0])
+		}
+	} else {
+		c.ImageID, _ = p.localImageID(ctx, imageNames[0])
+	}
+	c.save()
+}
+
+// pending returns the references of refs the checkpoint has not recorded as
+// pushed.
+func (c *Checkpoint) pending(refs []string) []string {
+	if c == nil {
+		return refs
+	}
+	pushed := make(map[string]bool, len(c.PushedRefs))
+	for _, ref := range c.PushedRefs {
+		pushed[ref] = true
+	}
+	var pending []string
+	for _, ref := range refs {
+		if !pushed[ref] {
+			pending = append(pending, ref)
+		}
+	}
+	return pending
+}
+
+// recordPushed checkpoints pushed references.
+func (c *Checkpoint) recordPushed(stats []*PushStats) {
+	if c == nil || len(stats) == 0 {
+		return
+	}
+	for _, s := range stats {
+		c.PushedRefs = append(c.PushedRefs, s.Ref)
+	}
+	c.save()
+}
+
+// localImageID returns the ID of ref in the local daemon.
+func (p *DockerPlugin) localImageID(ctx context.Context, ref string) (string, error) {
+	out, err := p.getExecutor().Output(ctx, "docker", []string{"image", "inspect", "--format", "{{.Id}}", ref})
+	if err != nil {
+		return "", err
+	}
+	id := strings.TrimSpace(string(out))
+	if id == "" {
+		return "", fmt.Errorf("no image ID for %s", ref)
+	}
+	return id, nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestCheckpointPath(t *testing.T) {
+	a := checkpointPath(&Config{}, []string{"myapp:1.0.0", "myapp:latest"})
+	b := checkpointPath(&Config{}, []string{"myapp:latest", "myapp:1.0.0"})
+	if a != b || filepath.Dir(a) != filepath.Clean(os.TempDir()) {
+		t.Errorf("expected one checkpoint in the temporary directory per tag set, got %s and %s", a, b)
+	}
+	if got := checkpointPath(&Config{CheckpointFile: "ci/checkpoint.json"}, nil); got != "ci/checkpoint.json" {
+		t.Errorf("expected checkpoint_file, got %s", got)
+	}
+}
+
+func TestLoadCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &Config{CheckpointFile: filepath.Join(dir, "checkpoint.json")}
+	refs := []string{"myapp:1.0.0", "myapp:latest"}
+
+	checkpoint, warning := loadCheckpoint(cfg, "1.0.0", "sha256:source", refs)
+	if warning != "" || checkpoint.Built {
+		t.Fatalf("expected a fresh checkpoint, got %+v, %q", checkpoint, warning)
+	}
+	checkpoint.Built, checkpoint.ImageID = true, "sha256:image"
+	checkpoint.recordPushed([]*PushStats{{Ref: "myapp:1.0.0"}})
+	if w := checkpoint.warning(); w != "" {
+		t.Fatal(w)
+	}
+
+	loaded, warning := loadCheckpoint(cfg, "1.0.0", "sha256:source", refs)
+	if warning != "" || !loaded.Built || loaded.ImageID != "sha256:image" {
+		t.Errorf("expected the saved checkpoint, got %+v, %q", loaded, warning)
+	}
+	if pending := loaded.pending(refs); len(pending) != 1 || pending[0] != "myapp:latest" {
+		t.Errorf("expected only myapp:latest pending, got %v", pending)
+	}
+
+	other, warning := loadCheckpoint(cfg, "1.0.0", "sha256:changed", refs)
+	if other.Built || !strings.Contains(warning, "ignored checkpoint") {
+		t.Errorf("expected the checkpoint of a changed source to be ignored, got %+v, %q", other, warning)
+	}
+}
+
+func TestExecuteResumesFailedPush(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+	t.Setenv("TMPDIR", t.TempDir())
+	writeFiles(t, dir, map[string]string{"Dockerfile": "FROM alpine\n"})
+
+	failLatest := true
+	mock := &MockCommandExecutor{
+		RunFunc: func(_ context.Context, _ string, args []string, _ io.Reader) error {
+			if failLatest && args[0] == "push" && args[1] == "myapp:latest" {
+				return errors.New("connection reset")
+			}
+			return nil
+		},
+		OutputFunc: func(_ context.Context, _ string, args []string) ([]byte, error) {
+			if containsArg(args, "--format", "{{.Id}}") {
+				return []byte("sha256:image\n"), nil
+			}
+			return nil, nil
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+	req := plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"image": "myapp", "resume": true},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	}
+
+	resp, err := p.Execute(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success {
+		t.Fatal("expected the first run to fail")
+	}
+	path := checkpointPath(&Config{}, []string{"myapp:1.0.0", "myapp:latest"})
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expected a checkpoint after the failed run: %v", err)
+	}
+
+	failLatest = false
+	mock.RunCalls = nil
+	resp, err = p.Execute(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected the resumed run to succeed, got error: %s", resp.Error)
+	}
+	var commands []string
+	for _, call := range mock.RunCalls {
+		commands = append(commands, strings.Join(call.Args, " "))
+	}
+	if got := strings.Join(commands, "; "); got != "push myapp:latest" {
+		t.Errorf("expected only the failed push to run again, got %s", got)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected the checkpoint to be removed after success, got %v", err)
+	}
+}
+
+func TestExecuteRebuildsWhenImageIsGone(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+	t.Setenv("TMPDIR", t.TempDir())
+	writeFiles(t, dir, map[string]string{"Dockerfile": "FROM alpine\n"})
+
+	digest, err := computeSourceDigest(&Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkpoint, _ := loadCheckpoint(&Config{}, "1.0.0", digest, []string{"myapp:1.0.0", "myapp:latest"})
+	checkpoint.Built, checkpoint.ImageID = true, "sha256:old"
+	checkpoint.recordPushed([]*PushStats{{Ref: "myapp:1.0.0"}})
+
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock}
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"image": "myapp", "resume": true},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil || !resp.Success {
+		t.Fatalf("expected success, got %+v, %v", resp, err)
+	}
+
+	var pushes []string
+	built := false
+	for _, call := range mock.RunCalls {
+		switch call.Args[0] {
+		case "build":
+			built = true
+		case "push":
+			pushes = append(pushes, call.Args[1])
+		}
+	}
+	if !built || len(pushes) != 2 {
+		t.Errorf("expected a rebuild and both pushes, got build=%v pushes=%v", built, pushes)
+	}
+}
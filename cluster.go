@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Local clusters accepted by cluster_load.
+const (
+	clusterKind     = "kind"
+	clusterMinikube = "minikube"
+	clusterK3d      = "k3d"
+)
+
+// clusterNamePattern matches kind cluster names, minikube profiles and k3d
+// cluster names.
+var clusterNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// validateClusterLoad validates cluster_load and cluster_name. The image is
+// loaded from the local daemon, so buildx builds must load it there.
+func validateClusterLoad(cfg *Config) error {
+	if cfg.ClusterLoad == "" {
+		if cfg.ClusterName != "" {
+			return fmt.Errorf("cluster_name requires cluster_load")
+		}
+		return nil
+	}
+	switch cfg.ClusterLoad {
+	case clusterKind, clusterMinikube, clusterK3d:
+	default:
+		return fmt.Errorf("invalid cluster_load %q: expected kind, minikube or k3d", cfg.ClusterLoad)
+	}
+	if cfg.ClusterName != "" && !clusterNamePattern.MatchString(cfg.ClusterName) {
+		return fmt.Errorf("invalid cluster_name %q", cfg.ClusterName)
+	}
+	if useBuildx(cfg) && !cfg.Load {
+		return fmt.Errorf("cluster_load with buildx requires load: true")
+	}
+	return nil
+}
+
+// clusterLoadCommands returns the commands loading refs into the configured
+// cluster. minikube loads one image per invocation.
+func clusterLoadCommands(cfg *Config, refs []string) [][]string {
+	switch cfg.ClusterLoad {
+	case clusterKind:
+		args := []string{"load", "docker-image"}
+		if cfg.ClusterName != "" {
+			args = append(args, "--name", cfg.ClusterName)
+		}
+		return [][]string{append(args, refs...)}
+	case clusterMinikube:
+		commands := make([][]string, 0, len(refs))
+		for _, ref := range refs {
+			args := []string{"image", "load"}
+			if cfg.ClusterName != "" {
+				args = append(args, "--profile", cfg.ClusterName)
+			}
+			commands = append(commands, append(args, ref))
+		}
+		return commands
+	case clusterK3d:
+		args := []string{"image", "import"}
+		if cfg.ClusterName != "" {
+			args = append(args, "--cluster", cfg.ClusterName)
+		}
+		return [][]string{append(args, refs...)}
+	}
+	return nil
+}
+
+// loadIntoCluster loads the built image from the local daemon into the
+// configured kind, minikube or k3d cluster, so end-to-end tests can run
+// against it before the image is pushed.
+func (p *DockerPlugin) loadIntoCluster(ctx context.Context, cfg *Config, refs []string, outputs *Outputs) error {
+	if cfg.ClusterLoad == "" || len(refs) == 0 {
+		return nil
+	}
+	started := time.Now()
+	err := p.runClusterLoad(ctx, cfg, refs)
+	outputs.stage("cluster_load", started, err)
+	return err
+}
+
+func (p *DockerPlugin) runClusterLoad(ctx context.Context, cfg *Config, refs []string) error {
+	if _, err := lookPath(cfg.ClusterLoad); err != nil {
+		return fmt.Errorf("%s was not found in PATH", cfg.ClusterLoad)
+	}
+	for _, args := range clusterLoadCommands(cfg, refs) {
+		if err := p.getExecutor().Run(ctx, cfg.ClusterLoad, args, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
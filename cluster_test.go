@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestValidateClusterLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *Config
+		wantErr bool
+	}{
+		{"unset", &Config{}, false},
+		{"kind", &Config{ClusterLoad: "kind", ClusterName: "e2e"}, false},
+		{"minikube", &Config{ClusterLoad: "minikube"}, false},
+		{"buildx with load", &Config{ClusterLoad: "k3d", Builder: "release", Load: true}, false},
+		{"unknown tool", &Config{ClusterLoad: "microk8s"}, true},
+		{"invalid name", &Config{ClusterLoad: "kind", ClusterName: "e2e;rm"}, true},
+		{"name without tool", &Config{ClusterName: "e2e"}, true},
+		{"buildx without load", &Config{ClusterLoad: "kind", Builder: "release"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateClusterLoad(tt.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("validateClusterLoad() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClusterLoadCommands(t *testing.T) {
+	refs := []string{"app:1.0.0", "app:latest"}
+	tests := []struct {
+		name     string
+		cfg      *Config
+		expected []string
+	}{
+		{"kind", &Config{ClusterLoad: "kind", ClusterName: "e2e"}, []string{"load docker-image --name e2e app:1.0.0 app:latest"}},
+		{"minikube", &Config{ClusterLoad: "minikube", ClusterName: "ci"}, []string{"image load --profile ci app:1.0.0", "image load --profile ci app:latest"}},
+		{"k3d default cluster", &Config{ClusterLoad: "k3d"}, []string{"image import app:1.0.0 app:latest"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, args := range clusterLoadCommands(tt.cfg, refs) {
+				got = append(got, strings.Join(args, " "))
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+				t.Errorf("clusterLoadCommands() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExecuteLoadsIntoClusterBeforePush(t *testing.T) {
+	stubLookPath(t, "kind")
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"image": "myapp", "cluster_load": "kind", "cluster_name": "e2e"},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+
+	var order []string
+	for _, call := range mock.RunCalls {
+		order = append(order, call.Name+" "+call.Args[0])
+	}
+	if got := strings.Join(order, ","); got != "docker build,kind load,docker push,docker push" {
+		t.Errorf("expected build, cluster load, then push; got %s", got)
+	}
+}
+
+func TestExecuteLoadsIntoClusterBeforeBuildxPush(t *testing.T) {
+	stubLookPath(t, "k3d")
+	mock := &MockCommandExecutor{OutputFunc: daemonOn("linux/amd64")}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":        "myapp",
+			"builder":      "release",
+			"platforms":    []any{"linux/amd64", "linux/arm64"},
+			"load":         true,
+			"cluster_load": "k3d",
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+
+	if len(mock.RunCalls) != 3 || mock.RunCalls[1].Name != "k3d" {
+		t.Fatalf("expected load build, cluster import and pushing build, got %v", mock.RunCalls)
+	}
+	if !containsFlag(mock.RunCalls[2].Args, "--push") {
+		t.Errorf("expected pushing build last, got %v", mock.RunCalls[2].Args)
+	}
+}
+
+func TestExecuteClusterLoadMissingTool(t *testing.T) {
+	stubLookPath(t)
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"image": "myapp", "cluster_load": "minikube"},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success || !strings.Contains(resp.Error, "minikube was not found") {
+		t.Fatalf("expected missing tool failure, got %+v", resp)
+	}
+	for _, call := range mock.RunCalls {
+		if call.Args[0] == "push" {
+			t.Error("expected no push after cluster load failure")
+		}
+	}
+}
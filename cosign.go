@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// cosignKeyURIPattern matches cosign key references that are not files, such
+// as env://COSIGN_KEY, k8s://ns/secret or awskms://alias/release.
+var cosignKeyURIPattern = regexp.MustCompile(`^[a-z0-9]+://[^\s;|&$<>'"\x60]+$`)
+
+// cosignReferrerTypes are the artifacts copied along with a promoted image.
+const cosignReferrerTypes = "sig,att,sbom"
+
+// validateCosignConfig validates cosign_key. Key files must stay inside the
+// working directory.
+func validateCosignConfig(cfg *Config) error {
+	if cfg.CosignKey == "" {
+		return nil
+	}
+	if !cfg.CosignSign {
+		return fmt.Errorf("cosign_key requires cosign_sign")
+	}
+	if cosignKeyURIPattern.MatchString(cfg.CosignKey) {
+		return nil
+	}
+	if err := validatePath(cfg.CosignKey); err != nil {
+		return fmt.Errorf("invalid cosign_key: %v", err)
+	}
+	return nil
+}
+
+// keylessTokenSources are the environment variables through which cosign
+// obtains an OIDC identity token for keyless signing without a browser.
+var keylessTokenSources = []string{
+	"SIGSTORE_ID_TOKEN",
+	"ACTIONS_ID_TOKEN_REQUEST_URL",
+	"BUILDKITE_AGENT_ACCESS_TOKEN",
+	"GOOGLE_SERVICE_ACCOUNT_NAME",
+	"SPIFFE_ENDPOINT_SOCKET",
+}
+
+// keylessTokenFile is where cosign reads a mounted OIDC identity token,
+// such as a projected Kubernetes service account token.
+var keylessTokenFile = "/var/run/sigstore/cosign/oidc-token"
+
+// checkSigningPrerequisites reports the first prerequisite of cosign_sign
+// missing from the environment, and how to provide it, so the release
+// fails before it builds rather than when it signs.
+func checkSigningPrerequisites(cfg *Config) error {
+	if !cfg.CosignSign {
+		return nil
+	}
+	if _, err := lookPath("cosign"); err != nil {
+		return fmt.Errorf("cosign_sign requires cosign in PATH; install it, e.g. with the sigstore/cosign-installer action")
+	}
+
+	key := cfg.CosignKey
+	switch {
+	case key == "":
+		return checkKeylessIdentity()
+	case strings.HasPrefix(key, "env://"):
+		name := strings.TrimPrefix(key, "env://")
+		if os.Getenv(name) == "" {
+			return fmt.Errorf("cosign_key %s: environment variable %s is not set; put the private key in it", key, name)
+		}
+		return checkCosignPassword(key)
+	case strings.HasPrefix(key, "hashivault://"):
+		for _, name := range []string{"VAULT_ADDR", "VAULT_TOKEN"} {
+			if os.Getenv(name) == "" {
+				return fmt.Errorf("cosign_key %s: %s is not set; Vault keys need VAULT_ADDR and VAULT_TOKEN", key, name)
+			}
+		}
+		return nil
+	case cosignKeyURIPattern.MatchString(key):
+		// KMS credentials are resolved by cosign from the cloud environment.
+		return nil
+	}
+	if _, err := os.Stat(key); err != nil {
+		return fmt.Errorf("cosign_key %s does not exist; create it with cosign generate-key-pair or restore it from a secret before the release", key)
+	}
+	return checkCosignPassword(key)
+}
+
+// checkCosignPassword checks that the password of a cosign private key is
+// available: without a terminal, cosign cannot prompt for it.
+func checkCosignPassword(key string) error {
+	if _, ok := os.LookupEnv("COSIGN_PASSWORD"); !ok {
+		return fmt.Errorf("cosign_key %s: COSIGN_PASSWORD is not set; set it to the key password, or to an empty value for a key without one", key)
+	}
+	return nil
+}
+
+// checkKeylessIdentity checks that keyless signing in CI can obtain an OIDC
+// identity token. Outside CI, cosign falls back to a browser login.
+func checkKeylessIdentity() error {
+	for _, name := range keylessTokenSources {
+		if os.Getenv(name) != "" {
+			return nil
+		}
+	}
+	if _, err := os.Stat(keylessTokenFile); err == nil {
+		return nil
+	}
+	switch {
+	case os.Getenv("GITHUB_ACTIONS") == "true":
+		return fmt.Errorf("keyless signing requires an OIDC token: grant the job permissions: id-token: write, or set cosign_key")
+	case os.Getenv("GITLAB_CI") == "true":
+		return fmt.Errorf("keyless signing requires an OIDC token: declare id_tokens: SIGSTORE_ID_TOKEN: aud: sigstore in the job, or set cosign_key")
+	case os.Getenv("CI") != "":
+		return fmt.Errorf("keyless signing requires an OIDC token in CI: set SIGSTORE_ID_TOKEN, or set cosign_key")
+	}
+	return nil
+}
+
+// copySignatures copies the signatures, attestations and SBOMs attached to
+// digest in the source repository to target, so an image promoted into
+// another repository stays verifiable. Promotions within one repository keep
+// the digest and with it every attached artifact.
+func (p *DockerPlugin) copySignatures(ctx context.Context, cfg *Config, sourceRepository, target, digest string) error {
+	if !cfg.CosignCopy {
+		return nil
+	}
+	args := []string{"copy", "--only=" + cosignReferrerTypes, "--force", sourceRepository + "@" + digest, target}
+	if err := p.getExecutor().Run(ctx, "cosign", args, nil); err != nil {
+		return fmt.Errorf("failed to copy signatures to %s: %w", target, err)
+	}
+	return nil
+}
+
+// signPromoted signs each digest reference under the release identity: with
+// cosign_key when set, keyless otherwise.
+func (p *DockerPlugin) signPromoted(ctx context.Context, cfg *Config, refs []string) error {
+	if !cfg.CosignSign {
+		return nil
+	}
+	for _, ref := range refs {
+		args := []string{"sign", "--yes"}
+		if cfg.CosignKey != "" {
+			args = append(args, "--key", cfg.CosignKey)
+		}
+		args = append(args, ref)
+		if err := p.getExecutor().Run(ctx, "cosign", args, nil); err != nil {
+			return fmt.Errorf("failed to sign %s: %w", ref, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestValidateCosignConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *Config
+		wantErr bool
+	}{
+		{"unset", &Config{}, false},
+		{"key file", &Config{CosignSign: true, CosignKey: "cosign.key"}, false},
+		{"kms key", &Config{CosignSign: true, CosignKey: "awskms://alias/release"}, false},
+		{"key outside working directory", &Config{CosignSign: true, CosignKey: "../cosign.key"}, true},
+		{"key without signing", &Config{CosignKey: "cosign.key"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateCosignConfig(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateCosignConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPushArchiveCopiesAndSignsSignatures(t *testing.T) {
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock}
+	cfg := &Config{
+		Registry:        "ghcr.io",
+		Image:           "myorg/myapp",
+		ArchiveRegistry: "archive.internal",
+		CosignCopy:      true,
+		CosignSign:      true,
+		CosignKey:       "cosign.key",
+	}
+
+	if _, err := p.pushArchive(context.Background(), cfg, "ghcr.io/myorg/myapp:1.0.0", testDigest); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(mock.RunCalls) != 3 {
+		t.Fatalf("expected copy, cosign copy and cosign sign, got %d calls", len(mock.RunCalls))
+	}
+
+	copySigs := mock.RunCalls[1]
+	wantCopy := "copy --only=sig,att,sbom --force ghcr.io/myorg/myapp@" + testDigest + " archive.internal/myorg/myapp:" + archiveTag(testDigest)
+	if copySigs.Name != "cosign" || strings.Join(copySigs.Args, " ") != wantCopy {
+		t.Errorf("unexpected signature copy: %s %v", copySigs.Name, copySigs.Args)
+	}
+
+	sign := mock.RunCalls[2]
+	wantSign := "sign --yes --key cosign.key archive.internal/myorg/myapp@" + testDigest
+	if sign.Name != "cosign" || strings.Join(sign.Args, " ") != wantSign {
+		t.Errorf("unexpected signing: %s %v", sign.Name, sign.Args)
+	}
+}
+
+func TestReuseIdenticalSignsPromotedImage(t *testing.T) {
+	stubLookPath(t, "cosign")
+	t.Setenv("SIGSTORE_ID_TOKEN", "token")
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"Dockerfile": "FROM scratch\n"})
+	chdir(t, dir)
+
+	mock := &MockCommandExecutor{
+		OutputFunc: func(context.Context, string, []string) ([]byte, error) {
+			return []byte(testDigest + "\n"), nil
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":           "myorg/myapp",
+			"tags":            []any{"{{version}}"},
+			"reuse_identical": true,
+			"cosign_sign":     true,
+		},
+		Context: plugin.ReleaseContext{Version: "1.1.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+
+	if len(mock.RunCalls) != 2 {
+		t.Fatalf("expected retag and sign, got %d calls", len(mock.RunCalls))
+	}
+	sign := mock.RunCalls[1]
+	if sign.Name != "cosign" || strings.Join(sign.Args, " ") != "sign --yes myorg/myapp@"+testDigest {
+		t.Errorf("unexpected signing: %s %v", sign.Name, sign.Args)
+	}
+	if resp.Outputs["digest"] != testDigest {
+		t.Errorf("expected digest output, got %v", resp.Outputs["digest"])
+	}
+}
+
+func TestCheckSigningPrerequisites(t *testing.T) {
+	stubLookPath(t, "cosign")
+	for _, name := range append(keylessTokenSources, "GITHUB_ACTIONS", "GITLAB_CI", "CI", "COSIGN_PASSWORD", "RELEASE_KEY") {
+		t.Setenv(name, "")
+	}
+	os.Unsetenv("COSIGN_PASSWORD")
+	dir := t.TempDir()
+	chdir(t, dir)
+	writeFiles(t, dir, map[string]string{"cosign.key": "key"})
+
+	tests := []struct {
+		name    string
+		cfg     *Config
+		env     map[string]string
+		wantErr string
+	}{
+		{"keyless outside CI", &Config{CosignSign: true}, nil, ""},
+		{"keyless in GitHub Actions", &Config{CosignSign: true}, map[string]string{"GITHUB_ACTIONS": "true"}, "id-token: write"},
+		{"keyless with a token", &Config{CosignSign: true}, map[string]string{"GITHUB_ACTIONS": "true", "ACTIONS_ID_TOKEN_REQUEST_URL": "https://token"}, ""},
+		{"keyless in GitLab", &Config{CosignSign: true}, map[string]string{"GITLAB_CI": "true"}, "SIGSTORE_ID_TOKEN: aud: sigstore"},
+		{"key file without password", &Config{CosignSign: true, CosignKey: "cosign.key"}, nil, "COSIGN_PASSWORD is not set"},
+		{"key file", &Config{CosignSign: true, CosignKey: "cosign.key"}, map[string]string{"COSIGN_PASSWORD": ""}, ""},
+		{"missing key file", &Config{CosignSign: true, CosignKey: "release.key"}, nil, "release.key does not exist"},
+		{"missing env key", &Config{CosignSign: true, CosignKey: "env://RELEASE_KEY"}, nil, "RELEASE_KEY is not set"},
+		{"vault key", &Config{CosignSign: true, CosignKey: "hashivault://release"}, nil, "VAULT_ADDR is not set"},
+		{"kms key", &Config{CosignSign: true, CosignKey: "awskms://alias/release"}, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			err := checkSigningPrerequisites(tt.cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateReportsMissingCosign(t *testing.T) {
+	stubLookPath(t)
+	p := &DockerPlugin{}
+	resp, err := p.Validate(context.Background(), map[string]any{"image": "myorg/myapp", "cosign_sign": true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	found := slices.ContainsFunc(resp.Errors, func(e plugin.ValidationError) bool {
+		return e.Field == "cosign_sign" && strings.Contains(e.Message, "requires cosign in PATH")
+	})
+	if resp.Valid || !found {
+		t.Errorf("expected the missing cosign to be reported, got %+v", resp.Errors)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// validatePasswordCommand validates the password_command and token_ttl settings.
+func validatePasswordCommand(command []string, ttl string) error {
+	if len(command) > 0 && strings.TrimSpace(command[0]) == "" {
+		return fmt.Errorf("password_command must start with an executable")
+	}
+	if ttl == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(ttl)
+	if err != nil || d <= 0 {
+		return fmt.Errorf("token_ttl must be a positive duration such as 15m")
+	}
+	if len(command) == 0 {
+		return fmt.Errorf("token_ttl requires password_command")
+	}
+	return nil
+}
+
+// tokenTTL returns the configured token lifetime, or zero when unknown.
+func tokenTTL(cfg *Config) time.Duration {
+	d, err := time.ParseDuration(cfg.TokenTTL)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+// tokenNeedsRefresh reports whether a login made at loggedInAt should be
+// renewed before it is used at now. Tokens of unknown lifetime are always
+// renewed; otherwise renewal happens once half the lifetime has passed so a
+// push never starts with a token about to expire.
+func tokenNeedsRefresh(cfg *Config, loggedInAt, now time.Time) bool {
+	if len(cfg.PasswordCommand) == 0 {
+		return false
+	}
+	ttl := tokenTTL(cfg)
+	if ttl == 0 || loggedInAt.IsZero() {
+		return true
+	}
+	return now.Sub(loggedInAt) >= ttl/2
+}
+
+// fetchPassword runs password_command and returns the token it prints.
+func (p *DockerPlugin) fetchPassword(ctx context.Context, cfg *Config) (string, error) {
+	out, err := p.getExecutor().Output(ctx, cfg.PasswordCommand[0], cfg.PasswordCommand[1:])
+	if err != nil {
+		return "", fmt.Errorf("password_command failed: %w", err)
+	}
+	password := strings.TrimSpace(string(out))
+	if password == "" {
+		return "", fmt.Errorf("password_command printed no credential")
+	}
+	return password, nil
+}
+
+// refreshLogin obtains a fresh token from password_command and logs in again
+// when the current login is due for renewal, or unconditionally when force
+// is set. Configurations without password_command are left untouched.
+func (p *DockerPlugin) refreshLogin(ctx context.Context, cfg *Config, force bool) error {
+	if len(cfg.PasswordCommand) == 0 || cfg.Username == "" || skipLogin(cfg) {
+		return nil
+	}
+	now := time.Now()
+	if !force && !tokenNeedsRefresh(cfg, cfg.loggedInAt, now) {
+		return nil
+	}
+
+	password, err := p.fetchPassword(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if err := p.loginTo(ctx, cfg, cfg.Registry, cfg.Username, password); err != nil {
+		return err
+	}
+	cfg.Password = password
+	cfg.loggedInAt = now
+	return nil
+}
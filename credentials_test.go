@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestValidatePasswordCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		command []string
+		ttl     string
+		wantErr bool
+	}{
+		{"unset", nil, "", false},
+		{"command only", []string{"vault", "read", "-field=token", "secret/registry"}, "", false},
+		{"command with ttl", []string{"get-token"}, "15m", false},
+		{"empty executable", []string{""}, "", true},
+		{"invalid ttl", []string{"get-token"}, "soon", true},
+		{"negative ttl", []string{"get-token"}, "-1m", true},
+		{"ttl without command", nil, "15m", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePasswordCommand(tt.command, tt.ttl)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validatePasswordCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTokenNeedsRefresh(t *testing.T) {
+	now := time.Now()
+	cfg := &Config{PasswordCommand: []string{"get-token"}, TokenTTL: "20m"}
+
+	if !tokenNeedsRefresh(cfg, time.Time{}, now) {
+		t.Error("expected refresh without a previous login")
+	}
+	if tokenNeedsRefresh(cfg, now.Add(-5*time.Minute), now) {
+		t.Error("expected no refresh for a fresh token")
+	}
+	if !tokenNeedsRefresh(cfg, now.Add(-11*time.Minute), now) {
+		t.Error("expected refresh past half the token lifetime")
+	}
+	if !tokenNeedsRefresh(&Config{PasswordCommand: []string{"get-token"}}, now, now) {
+		t.Error("expected refresh for unknown token lifetime")
+	}
+	if tokenNeedsRefresh(&Config{}, time.Time{}, now) {
+		t.Error("expected no refresh without password_command")
+	}
+}
+
+func TestPasswordCommandReauthenticates(t *testing.T) {
+	tokens := 0
+	mock := &MockCommandExecutor{
+		OutputFunc: func(_ context.Context, name string, _ []string) ([]byte, error) {
+			if name == "get-token" {
+				tokens++
+				return []byte("token-" + string(rune('0'+tokens)) + "\n"), nil
+			}
+			return nil, nil
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":            "myapp",
+			"username":         "bot",
+			"password_command": []any{"get-token"},
+		},
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got %s", resp.Error)
+	}
+
+	var loginPasswords []string
+	for _, call := range mock.RunCalls {
+		if call.Args[0] == "login" {
+			loginPasswords = append(loginPasswords, call.Stdin)
+		}
+	}
+
+	// One login before the build and one before each of the two pushes.
+	want := []string{"token-1", "token-2", "token-3"}
+	if strings.Join(loginPasswords, ",") != strings.Join(want, ",") {
+		t.Errorf("expected logins with %v, got %v", want, loginPasswords)
+	}
+}
+
+func TestPasswordCommandFailure(t *testing.T) {
+	mock := &MockCommandExecutor{
+		OutputFunc: func(_ context.Context, name string, _ []string) ([]byte, error) {
+			if name == "get-token" {
+				return nil, errors.New("vault sealed")
+			}
+			return nil, nil
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":            "myapp",
+			"username":         "bot",
+			"password_command": []any{"get-token"},
+		},
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success || !strings.Contains(resp.Error, "vault sealed") {
+		t.Errorf("expected password_command failure, got %+v", resp)
+	}
+	if len(mock.RunCalls) != 0 {
+		t.Errorf("expected no commands after failed credential lookup, got %d", len(mock.RunCalls))
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// dockerHubServerURL is the server Docker stores Docker Hub credentials
+// under.
+const dockerHubServerURL = "https://index.docker.io/v1/"
+
+// dockerCLIConfig is the part of the docker CLI configuration naming the
+// credential helpers.
+type dockerCLIConfig struct {
+	CredsStore  string            `json:"credsStore"`
+	CredHelpers map[string]string `json:"credHelpers"`
+}
+
+// dockerConfigPath returns the docker CLI configuration file, honouring
+// DOCKER_CONFIG.
+func dockerConfigPath() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return filepath.Join(dir, "config.json")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".docker", "config.json")
+}
+
+// credentialServer returns the server a credential helper is asked for the
+// credentials of registry.
+func credentialServer(registry string) string {
+	if isDockerHub(registry) {
+		return dockerHubServerURL
+	}
+	return normalizeRegistry(registry)
+}
+
+// credentialHelperFor returns the credential helper docker uses for
+// registry: its entry in credHelpers, or else credsStore.
+func credentialHelperFor(registry string) (string, error) {
+	path := dockerConfigPath()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read docker config: %w", err)
+	}
+	var config dockerCLIConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return "", fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	server := credentialServer(registry)
+	for _, key := range []string{server, strings.TrimPrefix(server, "https://"), normalizeRegistry(registry)} {
+		if helper := config.CredHelpers[key]; helper != "" {
+			return helper, nil
+		}
+	}
+	if config.CredsStore != "" {
+		return config.CredsStore, nil
+	}
+	return "", fmt.Errorf("%s configures no credential helper for %s (credHelpers or credsStore)", path, registry)
+}
+
+// validateCredentialHelper checks that use_credential_helper is not
+// combined with credentials the plugin would log in with.
+func validateCredentialHelper(cfg *Config) error {
+	if !cfg.UseCredentialHelper {
+		return nil
+	}
+	if len(cfg.PasswordCommand) > 0 || cfg.PAT != "" || cfg.Password != "" {
+		return fmt.Errorf("use_credential_helper replaces password, auth.pat and password_command; remove them")
+	}
+	if cfg.Daemonless {
+		return fmt.Errorf("use_credential_helper is not supported with daemonless")
+	}
+	return nil
+}
+
+// checkCredentialHelper verifies, instead of logging in, that the
+// credential helper docker uses for the registry resolves credentials for
+// it; the credentials themselves are discarded.
+func (p *DockerPlugin) checkCredentialHelper(ctx context.Context, cfg *Config, outputs *Outputs) error {
+	started := time.Now()
+	_, _, err := p.resolveHelperCredentials(ctx, cfg.Registry)
+	outputs.stage("credential_helper", started, err)
+	return err
+}
+
+// resolveHelperCredentials returns the username and secret the credential
+// helper of registry resolves.
+func (p *DockerPlugin) resolveHelperCredentials(ctx context.Context, registry string) (string, string, error) {
+	helper, err := credentialHelperFor(registry)
+	if err != nil {
+		return "", "", err
+	}
+	name := "docker-credential-" + helper
+	out, err := p.getExecutor().OutputInput(ctx, name, []string{"get"}, strings.NewReader(credentialServer(registry)))
+	if err != nil {
+		return "", "", fmt.Errorf("%s found no credentials for %s: %w", name, registry, err)
+	}
+	var creds struct {
+		Username string `json:"Username"`
+		Secret   string `json:"Secret"`
+	}
+	if err := json.Unmarshal(out, &creds); err != nil {
+		return "", "", fmt.Errorf("%s printed invalid credentials for %s: %w", name, registry, err)
+	}
+	if creds.Secret == "" {
+		return "", "", fmt.Errorf("%s returned no secret for %s", name, registry)
+	}
+	return creds.Username, creds.Secret, nil
+}
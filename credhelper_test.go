@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// writeDockerConfig points DOCKER_CONFIG at a directory holding config.
+func writeDockerConfig(t *testing.T, config string) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DOCKER_CONFIG", dir)
+}
+
+func TestCredentialHelperFor(t *testing.T) {
+	writeDockerConfig(t, `{"credsStore": "osxkeychain", "credHelpers": {"123456789012.dkr.ecr.us-east-1.amazonaws.com": "ecr-login"}}`)
+
+	for registry, want := range map[string]string{
+		"123456789012.dkr.ecr.us-east-1.amazonaws.com": "ecr-login",
+		"ghcr.io":   "osxkeychain",
+		"docker.io": "osxkeychain",
+	} {
+		if got, err := credentialHelperFor(registry); err != nil || got != want {
+			t.Errorf("credentialHelperFor(%q) = %q, %v, want %q", registry, got, err, want)
+		}
+	}
+
+	writeDockerConfig(t, `{"auths": {}}`)
+	if _, err := credentialHelperFor("ghcr.io"); err == nil || !strings.Contains(err.Error(), "no credential helper for ghcr.io") {
+		t.Errorf("expected a missing helper to be reported, got %v", err)
+	}
+}
+
+func TestValidateCredentialHelper(t *testing.T) {
+	if err := validateCredentialHelper(&Config{UseCredentialHelper: true, Username: "bot"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := validateCredentialHelper(&Config{UseCredentialHelper: true, PasswordCommand: []string{"get-token"}}); err == nil {
+		t.Errorf("expected password_command to be rejected")
+	}
+	if err := validateCredentialHelper(&Config{UseCredentialHelper: true, Daemonless: true}); err == nil {
+		t.Errorf("expected daemonless to be rejected")
+	}
+}
+
+func TestExecuteWithCredentialHelper(t *testing.T) {
+	t.Setenv("DOCKER_USERNAME", "")
+	t.Setenv("DOCKER_PASSWORD", "")
+	t.Setenv("DOCKER_PAT", "")
+	t.Setenv("GITHUB_TOKEN", "ghs_token")
+	t.Setenv("GITHUB_ACTOR", "octocat")
+	writeDockerConfig(t, `{"credHelpers": {"ghcr.io": "pass"}}`)
+
+	mock := &MockCommandExecutor{
+		OutputFunc: func(ctx context.Context, name string, args []string) ([]byte, error) {
+			if name == "docker-credential-pass" {
+				return []byte(`{"ServerURL": "ghcr.io", "Username": "octocat", "Secret": "token"}`), nil
+			}
+			return nil, nil
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"registry": "ghcr.io", "image": "myorg/myapp", "use_credential_helper": true},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil || !resp.Success {
+		t.Fatalf("unexpected failure: %v %+v", err, resp)
+	}
+	for _, call := range mock.RunCalls {
+		if call.Args[0] == "login" {
+			t.Errorf("expected no docker login, got %v", call.Args)
+		}
+	}
+	i := slices.IndexFunc(mock.OutputCalls, func(call MockRunCall) bool { return call.Name == "docker-credential-pass" })
+	if i < 0 || mock.OutputCalls[i].Stdin != "ghcr.io" {
+		t.Errorf("expected the helper to be asked for ghcr.io, got %+v", mock.OutputCalls)
+	}
+}
+
+func TestExecuteFailsWithoutHelperCredentials(t *testing.T) {
+	t.Setenv("DOCKER_PASSWORD", "")
+	writeDockerConfig(t, `{"credsStore": "secretservice"}`)
+	mock := &MockCommandExecutor{
+		OutputFunc: func(ctx context.Context, name string, args []string) ([]byte, error) {
+			return nil, errors.New("credentials not found in native keychain")
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"image": "myorg/myapp", "use_credential_helper": true},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success || !strings.Contains(resp.Error, "docker-credential-secretservice found no credentials for docker.io") {
+		t.Errorf("expected the missing credentials to fail the release, got %+v", resp)
+	}
+	if len(mock.RunCalls) != 0 {
+		t.Errorf("expected nothing to be built, got %v", mock.RunCalls)
+	}
+}
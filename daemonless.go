@@ -0,0 +1,482 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// Media types of the manifests of an OCI image layout.
+const (
+	ociIndexMediaType          = "application/vnd.oci.image.index.v1+json"
+	ociManifestMediaType       = "application/vnd.oci.image.manifest.v1+json"
+	dockerManifestListType     = "application/vnd.docker.distribution.manifest.list.v2+json"
+	dockerManifestMediaType    = "application/vnd.docker.distribution.manifest.v2+json"
+	nondistributableMediaTypes = "nondistributable"
+)
+
+// blobDigestPattern matches the digests of blobs of an OCI image layout.
+var blobDigestPattern = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+
+// challengeParamPattern matches the parameters of a WWW-Authenticate
+// challenge, e.g. realm="https://auth.docker.io/token".
+var challengeParamPattern = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// ociDescriptor references a blob of an OCI image layout.
+type ociDescriptor struct {
+	MediaType    string            `json:"mediaType"`
+	Digest       string            `json:"digest"`
+	Size         int64             `json:"size"`
+	ArtifactType string            `json:"artifactType,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+}
+
+// ociIndex is an OCI image index, or the index.json of an image layout.
+type ociIndex struct {
+	MediaType string          `json:"mediaType"`
+	Manifests []ociDescriptor `json:"manifests"`
+}
+
+// ociManifest is an OCI or Docker image manifest.
+type ociManifest struct {
+	MediaType string          `json:"mediaType"`
+	Config    ociDescriptor   `json:"config"`
+	Layers    []ociDescriptor `json:"layers"`
+}
+
+// validateDaemonless checks daemonless and oci_tarball, and the options a
+// daemonless release cannot honour.
+func validateDaemonless(cfg *Config) error {
+	if !cfg.Daemonless {
+		if cfg.OCITarball != "" {
+			return fmt.Errorf("oci_tarball requires daemonless")
+		}
+		return nil
+	}
+	if err := validatePath(cfg.OCITarball); err != nil {
+		return fmt.Errorf("invalid oci_tarball: %v", err)
+	}
+	switch {
+	case cfg.OCITarball == "" && !useBuildx(cfg):
+		return fmt.Errorf("daemonless builds need a buildx builder that does not use the docker daemon, such as the kubernetes or remote driver, or a pre-built oci_tarball")
+	case cfg.Engine == enginePodman:
+		return fmt.Errorf("daemonless cannot be combined with engine podman")
+	case len(cfg.IndexSources) > 0:
+		return fmt.Errorf("daemonless cannot be combined with index_sources")
+	case cfg.Canary != nil:
+		return fmt.Errorf("daemonless cannot be combined with canary")
+	case len(cfg.EncryptionRecipients) > 0:
+		return fmt.Errorf("daemonless cannot be combined with encryption_recipients")
+	case cfg.ArchiveRegistry != "":
+		return fmt.Errorf("daemonless cannot be combined with archive_registry")
+	case len(cfg.Registries) > 1:
+		return fmt.Errorf("daemonless cannot be combined with more than one of registries")
+	case len(cfg.Mirrors) > 0:
+		return fmt.Errorf("daemonless cannot be combined with mirrors")
+	case len(cfg.TagAliases) > 0:
+		return fmt.Errorf("daemonless cannot be combined with tag_aliases")
+	case cfg.E2E != nil:
+		return fmt.Errorf("daemonless cannot be combined with e2e")
+	}
+	return nil
+}
+
+// registryRepository returns the repository path of the image in the
+// registry API. Docker Hub keeps official images under library/.
+func registryRepository(cfg *Config) string {
+	if registryHost(cfg) == "registry-1.docker.io" && !strings.Contains(cfg.Image, "/") {
+		return "library/" + cfg.Image
+	}
+	return cfg.Image
+}
+
+// releaseDaemonless pushes the image without a docker daemon: the OCI image
+// layout tarball of oci_tarball, or the one buildx exports, is uploaded over
+// the registry API.
+func (p *DockerPlugin) releaseDaemonless(ctx context.Context, cfg *Config, releaseCtx plugin.ReleaseContext, imageNames, tags []string, outputs *Outputs) (*plugin.ExecuteResponse, error) {
+	dir, err := os.MkdirTemp("", "relicta-docker-oci-*")
+	if err != nil {
+		return outputs.response(false, "", fmt.Sprintf("failed to create OCI layout directory: %v", err)), nil
+	}
+	defer os.RemoveAll(dir)
+
+	tarball := cfg.OCITarball
+	if tarball == "" {
+		buildCfg := *cfg
+		buildCfg.Push = false
+		buildCfg.Load = false
+		buildCfg.ociOutput = filepath.Join(dir, "image.tar")
+		if resp := p.execCompatStage(ctx, cfg, execPreBuild, releaseCtx.Version, outputs); resp != nil {
+			return resp, nil
+		}
+		started := time.Now()
+		err := p.tracedBuild(ctx, &buildCfg, imageNames, releaseCtx, nil)
+		outputs.stage("build", started, err)
+		if err != nil {
+			return outputs.response(false, "", fmt.Sprintf("failed to build image: %v", err)), nil
+		}
+		tarball = buildCfg.ociOutput
+	}
+
+	layout := filepath.Join(dir, "layout")
+	if err := extractTar(tarball, layout); err != nil {
+		return outputs.response(false, "", fmt.Sprintf("failed to read OCI tarball %s: %v", tarball, err)), nil
+	}
+	root, data, err := ociRoot(layout)
+	if err != nil {
+		return outputs.response(false, "", fmt.Sprintf("invalid OCI tarball %s: %v", tarball, err)), nil
+	}
+	if !cfg.Push {
+		outputs.Digest = root.Digest
+		return outputs.response(true, fmt.Sprintf("Built OCI image %s without a docker daemon", root.Digest), ""), nil
+	}
+
+	if resp := p.gatePush(ctx, cfg, releaseCtx.Version, outputs); resp != nil {
+		return resp, nil
+	}
+
+	client := p.newRegistryClient(cfg)
+	client.password = loginSecret(cfg)
+	if len(cfg.PasswordCommand) > 0 {
+		if client.password, err = p.fetchPassword(ctx, cfg); err != nil {
+			return outputs.response(false, "", fmt.Sprintf("failed to login to registry: %v", err)), nil
+		}
+	}
+
+	started := time.Now()
+	pusher := &ociPusher{client: client, repository: registryRepository(cfg), layout: layout, throttle: cfg.pushThrottle}
+	pusher.setRetries(cfg)
+	err = pusher.authorize(ctx)
+	if err == nil {
+		err = pusher.push(ctx, root, data, tags)
+	}
+	p.lookups.invalidate()
+	outputs.stage("push", started, err)
+	if err != nil {
+		return outputs.response(false, "", fmt.Sprintf("failed to push image %s: %v", imageNames[0], err)), nil
+	}
+
+	stats := make([]*PushStats, len(imageNames))
+	for i, name := range imageNames {
+		stats[i] = &PushStats{Ref: name, Digest: root.Digest}
+	}
+	stats[0].LayersPushed = pusher.blobsPushed
+	stats[0].LayersExisting = pusher.blobsExisting
+	stats[0].BytesPushed = pusher.bytesPushed
+	stats[0].BytesTotal = pusher.bytesTotal
+	outputs.setPushed(stats)
+	outputs.Digest = root.Digest
+	outputs.Pushed = true
+	if resp := p.execCompatStage(ctx, cfg, execPostPush, releaseCtx.Version, outputs); resp != nil {
+		return resp, nil
+	}
+	if resp := p.bundleStage(ctx, cfg, releaseCtx.Version, outputs); resp != nil {
+		return resp, nil
+	}
+	if w := p.recordVersion(ctx, cfg, imageRepository(cfg), releaseCtx.Version, outputs); w != "" {
+		outputs.Warnings = append(outputs.Warnings, w)
+	}
+	outputs.Warnings = append(outputs.Warnings, p.notifyDownstreams(ctx, cfg, releaseCtx.Version, outputs)...)
+
+	message := fmt.Sprintf("Pushed OCI image with %d tags without a docker daemon (%s uploaded)", len(tags), formatBytes(pusher.bytesPushed))
+	return outputs.response(true, message, ""), nil
+}
+
+// ociRoot returns the descriptor and content of the image of an OCI image
+// layout: the single manifest index.json references, or index.json itself
+// when it lists the manifests of several platforms.
+func ociRoot(layout string) (ociDescriptor, []byte, error) {
+	data, err := os.ReadFile(filepath.Join(layout, "index.json"))
+	if err != nil {
+		return ociDescriptor{}, nil, fmt.Errorf("no index.json: %w", err)
+	}
+	var index ociIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return ociDescriptor{}, nil, fmt.Errorf("invalid index.json: %w", err)
+	}
+	switch len(index.Manifests) {
+	case 0:
+		return ociDescriptor{}, nil, fmt.Errorf("index.json lists no image")
+	case 1:
+		root := index.Manifests[0]
+		content, err := readBlob(layout, root)
+		return root, content, err
+	}
+	sum := sha256.Sum256(data)
+	root := ociDescriptor{MediaType: ociIndexMediaType, Digest: "sha256:" + hex.EncodeToString(sum[:]), Size: int64(len(data))}
+	return root, data, nil
+}
+
+// blobPath returns the path of the blob of desc in layout.
+func blobPath(layout string, desc ociDescriptor) (string, error) {
+	if !blobDigestPattern.MatchString(desc.Digest) {
+		return "", fmt.Errorf("unsupported digest %q", desc.Digest)
+	}
+	return filepath.Join(layout, "blobs", "sha256", strings.TrimPrefix(desc.Digest, "sha256:")), nil
+}
+
+// readBlob returns the verified content of a manifest blob of layout.
+func readBlob(layout string, desc ociDescriptor) ([]byte, error) {
+	path, err := blobPath(layout, desc)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("missing blob %s", desc.Digest)
+	}
+	sum := sha256.Sum256(data)
+	if "sha256:"+hex.EncodeToString(sum[:]) != desc.Digest {
+		return nil, fmt.Errorf("blob %s does not match its digest", desc.Digest)
+	}
+	return data, nil
+}
+
+// ociPusher uploads the blobs and manifests of an OCI image layout to a
+// repository over the registry API.
+type ociPusher struct {
+	client     *registryClient
+	repository string
+	layout     string
+	// basicAuth sends the credentials with every request; otherwise
+	// authorization is the Authorization header of registry requests.
+	basicAuth     bool
+	authorization string
+	// throttle limits the rate of blob uploads; nil is unlimited.
+	throttle *throttle
+	// retries is the number of times a broken blob upload is resumed,
+	// waiting retryDelay before each.
+	retries    int
+	retryDelay func(retry int) time.Duration
+
+	blobsPushed   int
+	blobsExisting int
+	bytesPushed   int64
+	bytesTotal    int64
+}
+
+// authorize obtains the credentials for pushing to the repository. Token
+// registries, such as Docker Hub and GHCR, answer with a Bearer challenge
+// naming the service issuing push tokens.
+func (o *ociPusher) authorize(ctx context.Context) error {
+	resp, err := o.do(ctx, http.MethodGet, o.client.baseURL+"/v2/", nil, 0, "")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		return nil
+	}
+
+	challenge := resp.Header.Get("WWW-Authenticate")
+	scheme, params, _ := strings.Cut(challenge, " ")
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if o.client.username == "" || o.client.password == "" {
+			return fmt.Errorf("the registry requires credentials")
+		}
+		o.basicAuth = true
+		return nil
+	case "bearer":
+		return o.fetchToken(ctx, params)
+	}
+	return fmt.Errorf("unsupported registry authentication %q", challenge)
+}
+
+// fetchToken requests a push token from the realm of a Bearer challenge.
+func (o *ociPusher) fetchToken(ctx context.Context, params string) error {
+	values := make(map[string]string)
+	for _, m := range challengeParamPattern.FindAllStringSubmatch(params, -1) {
+		values[m[1]] = m[2]
+	}
+	realm, err := url.Parse(values["realm"])
+	if err != nil || realm.Scheme != "https" && realm.Scheme != "http" {
+		return fmt.Errorf("invalid token realm %q", values["realm"])
+	}
+	// Like rejectTLSDowngrade for redirects, credentials never travel to a
+	// plaintext token service unless it runs on this machine.
+	if realm.Scheme == "http" && !isLocalRegistry(realm.Host) {
+		return fmt.Errorf("refusing token realm %s: credentials require HTTPS", realm.Redacted())
+	}
+	query := realm.Query()
+	if values["service"] != "" {
+		query.Set("service", values["service"])
+	}
+	query.Set("scope", fmt.Sprintf("repository:%s:pull,push", o.repository))
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", o.client.userAgent)
+	if o.client.username != "" && o.client.password != "" {
+		req.SetBasicAuth(o.client.username, o.client.password)
+	}
+	resp, err := o.client.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("token request: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return fmt.Errorf("token request: invalid response: %w", err)
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	if token.Token == "" {
+		return fmt.Errorf("token request: no token issued")
+	}
+	o.authorization = "Bearer " + token.Token
+	return nil
+}
+
+// do sends an authorized request to the registry.
+func (o *ociPusher) do(ctx context.Context, method, target string, body io.Reader, size int64, contentType string) (*http.Response, error) {
+	req, err := o.request(ctx, method, target, body, size, contentType)
+	if err != nil {
+		return nil, err
+	}
+	return o.client.httpClient.Do(req)
+}
+
+// request returns an authorized request to the registry.
+func (o *ociPusher) request(ctx context.Context, method, target string, body io.Reader, size int64, contentType string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range o.client.headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("User-Agent", o.client.userAgent)
+	if o.basicAuth {
+		req.SetBasicAuth(o.client.username, o.client.password)
+	} else if o.authorization != "" {
+		req.Header.Set("Authorization", o.authorization)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	return req, nil
+}
+
+// expect fails unless resp has the wanted status, reporting the registry's
+// error. The body is closed.
+func expect(resp *http.Response, what string, want ...int) error {
+	defer resp.Body.Close()
+	for _, status := range want {
+		if resp.StatusCode == status {
+			return nil
+		}
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("%s: %s: %s", what, resp.Status, strings.TrimSpace(string(body)))
+}
+
+// push uploads the manifest desc with content data and everything it
+// references, then tags it with refs. The manifests of an index are
+// uploaded by digest first.
+func (o *ociPusher) push(ctx context.Context, desc ociDescriptor, data []byte, refs []string) error {
+	mediaType := desc.MediaType
+	switch mediaType {
+	case ociIndexMediaType, dockerManifestListType:
+		var index ociIndex
+		if err := json.Unmarshal(data, &index); err != nil {
+			return fmt.Errorf("invalid index %s: %w", desc.Digest, err)
+		}
+		for _, child := range index.Manifests {
+			content, err := readBlob(o.layout, child)
+			if err != nil {
+				return err
+			}
+			if err := o.push(ctx, child, content, []string{child.Digest}); err != nil {
+				return err
+			}
+		}
+	case ociManifestMediaType, dockerManifestMediaType:
+		var manifest ociManifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return fmt.Errorf("invalid manifest %s: %w", desc.Digest, err)
+		}
+		for _, blob := range append([]ociDescriptor{manifest.Config}, manifest.Layers...) {
+			if err := o.pushBlob(ctx, blob); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported manifest media type %q of %s", mediaType, desc.Digest)
+	}
+
+	for _, ref := range refs {
+		target := fmt.Sprintf("%s/v2/%s/manifests/%s", o.client.baseURL, o.repository, ref)
+		resp, err := o.do(ctx, http.MethodPut, target, bytes.NewReader(data), int64(len(data)), mediaType)
+		if err != nil {
+			return err
+		}
+		if err := expect(resp, "PUT manifest "+ref, http.StatusCreated, http.StatusOK); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pushBlob uploads a blob unless the repository already has it.
+// Non-distributable layers, such as Windows base layers, are not uploaded.
+func (o *ociPusher) pushBlob(ctx context.Context, blob ociDescriptor) error {
+	if strings.Contains(blob.MediaType, nondistributableMediaTypes) || strings.Contains(blob.MediaType, "foreign") {
+		return nil
+	}
+	o.bytesTotal += blob.Size
+
+	resp, err := o.do(ctx, http.MethodHead, fmt.Sprintf("%s/v2/%s/blobs/%s", o.client.baseURL, o.repository, blob.Digest), nil, 0, "")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		o.blobsExisting++
+		return nil
+	}
+
+	path, err := blobPath(o.layout, blob)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("missing blob %s", blob.Digest)
+	}
+	defer f.Close()
+
+	if err := o.uploadBlob(ctx, blob, f); err != nil {
+		return err
+	}
+	o.blobsPushed++
+	o.bytesPushed += blob.Size
+	return nil
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// writeOCITarball writes an OCI image layout tarball holding one image and
+// returns its path and manifest descriptor.
+func writeOCITarball(t *testing.T, dir string) (string, ociDescriptor) {
+	t.Helper()
+	blobs := map[string][]byte{}
+	add := func(mediaType string, data []byte) ociDescriptor {
+		sum := sha256.Sum256(data)
+		desc := ociDescriptor{MediaType: mediaType, Digest: "sha256:" + hex.EncodeToString(sum[:]), Size: int64(len(data))}
+		blobs[desc.Digest] = data
+		return desc
+	}
+	config := add("application/vnd.oci.image.config.v1+json", []byte(`{"architecture":"amd64","os":"linux"}`))
+	layer := add("application/vnd.oci.image.layer.v1.tar+gzip", []byte("layer"))
+	manifest, _ := json.Marshal(ociManifest{MediaType: ociManifestMediaType, Config: config, Layers: []ociDescriptor{layer}})
+	root := add(ociManifestMediaType, manifest)
+	index, _ := json.Marshal(ociIndex{MediaType: ociIndexMediaType, Manifests: []ociDescriptor{root}})
+
+	path := filepath.Join(dir, "image.tar")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := tar.NewWriter(f)
+	write := func(name string, data []byte) {
+		if err := w.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("oci-layout", []byte(`{"imageLayoutVersion":"1.0.0"}`))
+	write("index.json", index)
+	for digest, data := range blobs {
+		write("blobs/sha256/"+strings.TrimPrefix(digest, "sha256:"), data)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path, root
+}
+
+// fakeRegistry serves the push and pull endpoints of the registry API
+// behind token authentication. Manifests are kept by reference and digest.
+type fakeRegistry struct {
+	mu        sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string][]byte
+	types     map[string]string
+	scopes    []string
+	// uploads holds the bytes received by each open upload.
+	uploads map[string][]byte
+	// failChunk, when set, is called for every chunk and drops the
+	// connection when it returns true.
+	failChunk func(start int) bool
+	received  int
+}
+
+func newFakeRegistry(t *testing.T) (*fakeRegistry, *httptest.Server) {
+	t.Helper()
+	reg := &fakeRegistry{blobs: map[string][]byte{}, manifests: map[string][]byte{}, types: map[string]string{}, uploads: map[string][]byte{}}
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reg.mu.Lock()
+		defer reg.mu.Unlock()
+		if r.URL.Path == "/token" {
+			reg.scopes = append(reg.scopes, r.URL.Query().Get("scope"))
+			fmt.Fprint(w, `{"token":"push-token"}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer push-token" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="fake"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v2/myapp/"), "/")
+		switch {
+		case r.URL.Path == "/v2/":
+		case r.Method == http.MethodHead && parts[0] == "blobs":
+			if _, ok := reg.blobs[parts[1]]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case r.Method == http.MethodPost && parts[0] == "blobs":
+			id := strconv.Itoa(len(reg.uploads) + 1)
+			reg.uploads[id] = nil
+			w.Header().Set("Location", "/v2/myapp/blobs/uploads/"+id)
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == http.MethodPatch && parts[0] == "blobs":
+			id := parts[2]
+			data, ok := reg.uploads[id]
+			start, _, _ := strings.Cut(r.Header.Get("Content-Range"), "-")
+			if !ok || start != strconv.Itoa(len(data)) {
+				w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+				return
+			}
+			if reg.failChunk != nil && reg.failChunk(len(data)) {
+				conn, _, _ := w.(http.Hijacker).Hijack()
+				conn.Close()
+				return
+			}
+			chunk, _ := io.ReadAll(r.Body)
+			reg.received += len(chunk)
+			reg.uploads[id] = append(data, chunk...)
+			w.Header().Set("Location", "/v2/myapp/blobs/uploads/"+id)
+			w.Header().Set("Range", fmt.Sprintf("0-%d", len(reg.uploads[id])-1))
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == http.MethodGet && parts[0] == "blobs" && parts[1] == "uploads":
+			data, ok := reg.uploads[parts[2]]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Location", "/v2/myapp/blobs/uploads/"+parts[2])
+			if len(data) > 0 {
+				w.Header().Set("Range", fmt.Sprintf("0-%d", len(data)-1))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodPut && parts[0] == "blobs":
+			data, ok := reg.uploads[parts[2]]
+			sum := sha256.Sum256(data)
+			if digest := r.URL.Query().Get("digest"); !ok || digest != "sha256:"+hex.EncodeToString(sum[:]) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			delete(reg.uploads, parts[2])
+			reg.blobs[r.URL.Query().Get("digest")] = data
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodGet && parts[0] == "blobs":
+			data, ok := reg.blobs[parts[1]]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(data)
+		case r.Method == http.MethodPut && parts[0] == "manifests":
+			data, _ := io.ReadAll(r.Body)
+			sum := sha256.Sum256(data)
+			digest := "sha256:" + hex.EncodeToString(sum[:])
+			for _, ref := range []string{parts[1], digest} {
+				reg.manifests[ref] = data
+				reg.types[ref] = r.Header.Get("Content-Type")
+			}
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodGet && parts[0] == "manifests":
+			data, ok := reg.manifests[parts[1]]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", reg.types[parts[1]])
+			w.Write(data)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return reg, server
+}
+
+func TestValidateDaemonless(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *Config
+		wantErr string
+	}{
+		{"disabled", &Config{}, ""},
+		{"tarball", &Config{Daemonless: true, OCITarball: "image.tar"}, ""},
+		{"builder", &Config{Daemonless: true, Builder: "k8s"}, ""},
+		{"tarball without daemonless", &Config{OCITarball: "image.tar"}, "requires daemonless"},
+		{"no builder", &Config{Daemonless: true}, "need a buildx builder"},
+		{"traversal", &Config{Daemonless: true, OCITarball: "../image.tar"}, "invalid oci_tarball"},
+		{"podman", &Config{Daemonless: true, Builder: "k8s", Engine: enginePodman}, "engine podman"},
+		{"encryption", &Config{Daemonless: true, Builder: "k8s", EncryptionRecipients: []string{"jwe:key.pem"}}, "encryption_recipients"},
+		{"archive registry", &Config{Daemonless: true, Builder: "k8s", ArchiveRegistry: "archive.example.com"}, "archive_registry"},
+		{"registries", &Config{Daemonless: true, Builder: "k8s", Registries: []Mirror{{Registry: "ghcr.io"}, {Registry: "quay.io"}}}, "registries"},
+		{"single registry", &Config{Daemonless: true, Builder: "k8s", Registries: []Mirror{{Registry: "ghcr.io"}}}, ""},
+		{"mirrors", &Config{Daemonless: true, Builder: "k8s", Mirrors: []Mirror{{Registry: "quay.io"}}}, "mirrors"},
+		{"tag aliases", &Config{Daemonless: true, Builder: "k8s", TagAliases: map[string][]string{"1.0.0": {"v1.0.0"}}}, "tag_aliases"},
+		{"e2e", &Config{Daemonless: true, Builder: "k8s", E2E: &E2EConfig{Verify: []string{"true"}}}, "e2e"},
+		{"retries", &Config{Daemonless: true, Builder: "k8s", PushRetries: 2}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateDaemonless(tt.cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestExecuteDaemonlessPushesTarball(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+	_, root := writeOCITarball(t, dir)
+	reg, server := newFakeRegistry(t)
+
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock, httpClient: server.Client()}
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":       "myapp",
+			"registry":    strings.TrimPrefix(server.URL, "https://"),
+			"tags":        []any{"1.0.0", "latest"},
+			"daemonless":  true,
+			"oci_tarball": "image.tar",
+			"auth":        map[string]any{"username": "ci", "password": "secret"},
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+	for _, call := range mock.RunCalls {
+		if call.Name == "docker" {
+			t.Errorf("expected no docker commands, got %v", call.Args)
+		}
+	}
+
+	if !slices.Equal(reg.scopes, []string{"repository:myapp:pull,push"}) {
+		t.Errorf("expected one push token request, got %v", reg.scopes)
+	}
+	for _, tag := range []string{"1.0.0", "latest"} {
+		var manifest ociManifest
+		if err := json.Unmarshal(reg.manifests[tag], &manifest); err != nil {
+			t.Fatalf("expected manifest tagged %s: %v", tag, err)
+		}
+		if string(reg.blobs[manifest.Layers[0].Digest]) != "layer" || reg.blobs[manifest.Config.Digest] == nil {
+			t.Errorf("expected the blobs of %s to be uploaded", tag)
+		}
+	}
+	if resp.Outputs["digest"] != root.Digest {
+		t.Errorf("expected digest %s, got %v", root.Digest, resp.Outputs["digest"])
+	}
+	if resp.Outputs["pushed"] != true {
+		t.Errorf("expected the image to be reported as pushed")
+	}
+}
+
+func TestExecuteDaemonlessBuildsOCILayout(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+	tarball, _ := writeOCITarball(t, dir)
+	reg, server := newFakeRegistry(t)
+
+	mock := &MockCommandExecutor{
+		RunFunc: func(ctx context.Context, name string, args []string, stdin io.Reader) error {
+			for _, arg := range args {
+				if dest, ok := strings.CutPrefix(arg, "type=oci,dest="); ok {
+					data, err := os.ReadFile(tarball)
+					if err != nil {
+						return err
+					}
+					return os.WriteFile(dest, data, 0o644)
+				}
+			}
+			return nil
+		},
+	}
+	p := &DockerPlugin{executor: mock, httpClient: server.Client()}
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":      "myapp",
+			"registry":   strings.TrimPrefix(server.URL, "https://"),
+			"tags":       []any{"1.0.0"},
+			"builder":    "k8s",
+			"daemonless": true,
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+
+	var build []string
+	for _, call := range mock.RunCalls {
+		if containsFlag(call.Args, "build") {
+			build = call.Args
+		}
+	}
+	if !containsFlag(build, "--output") || containsFlag(build, "--push") || containsFlag(build, "--load") {
+		t.Errorf("expected the build to export an OCI layout only, got %v", build)
+	}
+	if _, ok := reg.manifests["1.0.0"]; !ok {
+		t.Errorf("expected the built image to be pushed")
+	}
+}
+
+func TestFetchTokenRefusesPlaintextRealm(t *testing.T) {
+	var requests int
+	tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if _, _, ok := r.BasicAuth(); !ok {
+			t.Error("expected credentials on the local token request")
+		}
+		fmt.Fprint(w, `{"token":"push-token"}`)
+	}))
+	defer tokens.Close()
+
+	newPusher := func() *ociPusher {
+		return &ociPusher{
+			client:     &registryClient{httpClient: tokens.Client(), username: "user", password: "secret"},
+			repository: "myapp",
+		}
+	}
+
+	o := newPusher()
+	err := o.fetchToken(context.Background(), `realm="http://auth.example.com/token",service="registry"`)
+	if err == nil || !strings.Contains(err.Error(), "credentials require HTTPS") {
+		t.Fatalf("expected plaintext realm to be refused, got %v", err)
+	}
+	if o.authorization != "" {
+		t.Errorf("expected no token, got %q", o.authorization)
+	}
+
+	o = newPusher()
+	if err := o.fetchToken(context.Background(), fmt.Sprintf(`realm="%s/token"`, tokens.URL)); err != nil {
+		t.Fatalf("expected loopback realm to be accepted: %v", err)
+	}
+	if requests != 1 || o.authorization != "Bearer push-token" {
+		t.Errorf("expected one token request, got %d (%q)", requests, o.authorization)
+	}
+}
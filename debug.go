@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// debugShell is the shell opened in the failed step by buildx debug.
+const debugShell = "/bin/sh"
+
+// shellSafePattern matches arguments that need no quoting in a shell.
+var shellSafePattern = regexp.MustCompile(`^[a-zA-Z0-9_@%+=:,./-]+$`)
+
+// validateDebug checks that debug is used with buildx; the classic builder
+// has no debugger.
+func validateDebug(cfg *Config) error {
+	if cfg.Debug && !useBuildx(cfg) {
+		return fmt.Errorf("debug requires builder: the buildx debugger is not available to classic builds")
+	}
+	return nil
+}
+
+// debugWrapper returns the command prefix enabling the experimental buildx
+// features debug mode relies on.
+func debugWrapper(cfg *Config) []string {
+	if !cfg.Debug {
+		return nil
+	}
+	if _, err := lookPath("env"); err != nil {
+		return nil
+	}
+	return []string{"env", "BUILDX_EXPERIMENTAL=1"}
+}
+
+// debugWarning explains when BUILDX_EXPERIMENTAL cannot be set.
+func debugWarning(cfg *Config) string {
+	if !cfg.Debug {
+		return ""
+	}
+	if _, err := lookPath("env"); err != nil {
+		return "debug: BUILDX_EXPERIMENTAL is not set: env was not found in PATH"
+	}
+	return ""
+}
+
+// debugBuildArgs turns the arguments of a buildx build into a debug build,
+// with buildx logging at debug level.
+func debugBuildArgs(args []string) []string {
+	return append([]string{"buildx", "--debug"}, args[1:]...)
+}
+
+// debugAttachInstructions returns the command that reruns the failed build
+// under the buildx debugger, which opens a shell in the container of the
+// failed step. The plugin runs without a terminal, so it cannot hold that
+// shell open itself; the rerun uses the same builder, and its cache brings
+// the build back to the failed step quickly. Nothing is pushed or loaded by
+// the rerun. Sensitive build args are redacted as in audit records.
+func debugAttachInstructions(cfg *Config, args []string) string {
+	command := []string{"BUILDX_EXPERIMENTAL=1", "docker", "buildx", "debug", "--invoke", debugShell, "--on=error", "build"}
+	rest := redactArgs(args[2:], redactedBuildArgs(cfg)...)
+	for i := 0; i < len(rest); i++ {
+		switch rest[i] {
+		case "--push", "--load":
+			continue
+		case "--progress", "--metadata-file":
+			i++
+			continue
+		}
+		command = append(command, shellQuote(rest[i]))
+	}
+	return "to open a shell in the failed step, run from the same checkout: " + strings.Join(command, " ")
+}
+
+// shellQuote quotes arg for a POSIX shell.
+func shellQuote(arg string) string {
+	if shellSafePattern.MatchString(arg) {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
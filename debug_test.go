@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestValidateDebug(t *testing.T) {
+	if err := validateDebug(&Config{Debug: true, Builder: "release"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := validateDebug(&Config{Debug: true}); err == nil {
+		t.Error("expected debug without builder to be rejected")
+	}
+}
+
+func TestDebugAttachInstructions(t *testing.T) {
+	args := []string{"buildx", "build", "--builder", "release", "--push", "-t", "myapp:1.0.0", "--build-arg", "NPM_TOKEN=abc", "--label", "title=my app", "."}
+	got := debugAttachInstructions(&Config{}, args)
+	want := "BUILDX_EXPERIMENTAL=1 docker buildx debug --invoke /bin/sh --on=error build --builder release -t myapp:1.0.0 --build-arg 'NPM_TOKEN=[REDACTED]' --label 'title=my app' ."
+	if !strings.HasSuffix(got, want) {
+		t.Errorf("debugAttachInstructions() = %q, want suffix %q", got, want)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := map[string]string{
+		"myapp:1.0.0": "myapp:1.0.0",
+		"my app":      "'my app'",
+		"it's":        `'it'\''s'`,
+	}
+	for arg, want := range tests {
+		if got := shellQuote(arg); got != want {
+			t.Errorf("shellQuote(%q) = %q, want %q", arg, got, want)
+		}
+	}
+}
+
+func TestExecuteDebugBuild(t *testing.T) {
+	stubLookPath(t, "env")
+	mock := &MockCommandExecutor{
+		RunFunc: func(_ context.Context, _ string, args []string, _ io.Reader) error {
+			if containsArg(args, "--debug", "build") {
+				return errors.New("exit status 1")
+			}
+			return nil
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"image": "myapp", "builder": "release", "debug": true},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success {
+		t.Fatal("expected the build to fail")
+	}
+
+	var build *MockRunCall
+	for i, call := range mock.RunCalls {
+		if containsArg(call.Args, "--debug", "build") {
+			build = &mock.RunCalls[i]
+		}
+	}
+	if build == nil || build.Name != "env" || build.Args[0] != "BUILDX_EXPERIMENTAL=1" || !containsArg(build.Args, "--progress", "plain") {
+		t.Fatalf("expected a debug build with BUILDX_EXPERIMENTAL, got %v", mock.RunCalls)
+	}
+	if !strings.Contains(resp.Error, "docker buildx debug --invoke /bin/sh --on=error build --builder release") {
+		t.Errorf("expected attach instructions, got %q", resp.Error)
+	}
+	if strings.Contains(resp.Error, "--push") {
+		t.Errorf("attach instructions should not push: %q", resp.Error)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+)
+
+// buildMetadata is the part of the buildx --metadata-file output naming
+// the pushed image.
+type buildMetadata struct {
+	Digest string `json:"containerimage.digest"`
+}
+
+// withMetadataFile points the metadata file of a pushing buildx build at a
+// new temporary file. The returned function removes it.
+func withMetadataFile(cfg *Config) (func(), error) {
+	f, err := os.CreateTemp("", "relicta-docker-metadata-*.json")
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	cfg.metadataFile = f.Name()
+	return func() { os.Remove(f.Name()) }, nil
+}
+
+// pushedDigest returns the digest a buildx build pushed, as recorded in its
+// metadata file. Older buildx versions do not record it, so the registry is
+// asked instead; an unknown digest is empty.
+func (p *DockerPlugin) pushedDigest(ctx context.Context, cfg *Config, ref string) string {
+	if cfg.metadataFile != "" {
+		if data, err := os.ReadFile(cfg.metadataFile); err == nil {
+			var metadata buildMetadata
+			if json.Unmarshal(data, &metadata) == nil && metadata.Digest != "" {
+				return metadata.Digest
+			}
+		}
+	}
+	digest, _ := p.resolveDigest(ctx, ref)
+	return digest
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// warningCode marks non-fatal findings in ValidateResponse.Errors. Like
+// deprecation notices, they do not make the configuration invalid.
+const warningCode = "warning"
+
+// predefinedBuildArgs are available to every build without an ARG
+// declaration; the platform args are set automatically by BuildKit.
+var predefinedBuildArgs = map[string]bool{
+	"HTTP_PROXY": true, "HTTPS_PROXY": true, "FTP_PROXY": true, "NO_PROXY": true, "ALL_PROXY": true,
+	"TARGETPLATFORM": true, "TARGETOS": true, "TARGETARCH": true, "TARGETVARIANT": true,
+	"BUILDPLATFORM": true, "BUILDOS": true, "BUILDARCH": true, "BUILDVARIANT": true,
+	"TARGETSTAGE": true,
+}
+
+// dockerfileArg is an ARG declared in a Dockerfile.
+type dockerfileArg struct {
+	Name       string
+	HasDefault bool
+}
+
+// dockerfileInstructions returns the instructions of a Dockerfile in order,
+// with line continuations joined and comments removed.
+func dockerfileInstructions(r io.Reader) ([]string, error) {
+	var instructions []string
+	var instruction strings.Builder
+
+	flush := func() {
+		if line := strings.TrimSpace(instruction.String()); line != "" {
+			instructions = append(instructions, line)
+		}
+		instruction.Reset()
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasSuffix(line, `\`) {
+			instruction.WriteString(strings.TrimSuffix(line, `\`))
+			instruction.WriteString(" ")
+			continue
+		}
+		instruction.WriteString(line)
+		flush()
+	}
+	flush()
+	return instructions, scanner.Err()
+}
+
+// parseDockerfileArgs returns the ARG declarations of a Dockerfile in order.
+// An ARG instruction may declare several args.
+func parseDockerfileArgs(r io.Reader) ([]dockerfileArg, error) {
+	instructions, err := dockerfileInstructions(r)
+	if err != nil {
+		return nil, err
+	}
+	var args []dockerfileArg
+	for _, line := range instructions {
+		keyword, rest, _ := strings.Cut(line, " ")
+		if !strings.EqualFold(keyword, "ARG") {
+			continue
+		}
+		for _, decl := range splitArgDeclarations(rest) {
+			name, _, hasDefault := strings.Cut(decl, "=")
+			args = append(args, dockerfileArg{Name: name, HasDefault: hasDefault})
+		}
+	}
+	return args, nil
+}
+
+// parseDockerfileBases returns the external images a Dockerfile builds on,
+// in order and without duplicates: the images of its FROM instructions and
+// those its COPY --from and RUN --mount=...,from= instructions read. Stages,
+// the named build contexts of contexts and scratch are skipped. Variables
+// are expanded from buildArgs and the defaults of the ARGs declared before
+// the first FROM; an image that cannot be resolved is an error.
+func parseDockerfileBases(r io.Reader, buildArgs map[string]string, contexts []string) ([]string, error) {
+	instructions, err := dockerfileInstructions(r)
+	if err != nil {
+		return nil, err
+	}
+
+	globals := make(map[string]string)
+	stages := make(map[string]bool)
+	for _, name := range contexts {
+		stages[strings.ToLower(name)] = true
+	}
+	seen := make(map[string]bool)
+	var bases []string
+	// add records the external image reference of line.
+	add := func(ref, line string) error {
+		var unresolved []string
+		image := os.Expand(ref, func(name string) string {
+			name, fallback, hasFallback := strings.Cut(name, ":-")
+			if value, ok := buildArgs[name]; ok && value != "" {
+				return value
+			}
+			if value, ok := globals[name]; ok && value != "" {
+				return value
+			}
+			if !hasFallback {
+				unresolved = append(unresolved, name)
+			}
+			return fallback
+		})
+		if len(unresolved) > 0 {
+			return fmt.Errorf("cannot resolve %s in %s: no value for %s", ref, line, strings.Join(unresolved, ", "))
+		}
+		if !stages[strings.ToLower(image)] && !strings.EqualFold(image, "scratch") && !seen[image] {
+			seen[image] = true
+			bases = append(bases, image)
+		}
+		return nil
+	}
+
+	sawFrom := false
+	stageCount := 0
+	for _, line := range instructions {
+		keyword, rest, _ := strings.Cut(line, " ")
+		switch {
+		case strings.EqualFold(keyword, "ARG") && !sawFrom:
+			for _, decl := range splitArgDeclarations(rest) {
+				if name, value, ok := strings.Cut(decl, "="); ok {
+					globals[name] = strings.Trim(value, `"'`)
+				}
+			}
+		case strings.EqualFold(keyword, "FROM"):
+			sawFrom = true
+			var fields []string
+			for _, field := range strings.Fields(rest) {
+				if !strings.HasPrefix(field, "--") {
+					fields = append(fields, field)
+				}
+			}
+			if len(fields) == 0 {
+				return nil, fmt.Errorf("FROM without an image: %s", line)
+			}
+			if err := add(fields[0], line); err != nil {
+				return nil, err
+			}
+			// Stages can also be referred to by index.
+			stages[strconv.Itoa(stageCount)] = true
+			stageCount++
+			if len(fields) >= 3 && strings.EqualFold(fields[1], "AS") {
+				stages[strings.ToLower(fields[2])] = true
+			}
+		case strings.EqualFold(keyword, "COPY"):
+			for _, field := range strings.Fields(rest) {
+				if from, ok := strings.CutPrefix(field, "--from="); ok {
+					if err := add(from, line); err != nil {
+						return nil, err
+					}
+				}
+			}
+		case strings.EqualFold(keyword, "RUN"):
+			for _, field := range strings.Fields(rest) {
+				mount, ok := strings.CutPrefix(field, "--mount=")
+				if !ok {
+					continue
+				}
+				for _, opt := range strings.Split(mount, ",") {
+					if from, ok := strings.CutPrefix(opt, "from="); ok && from != "" {
+						if err := add(from, line); err != nil {
+							return nil, err
+						}
+					}
+				}
+			}
+		}
+	}
+	return bases, nil
+}
+
+// parseDockerfileSources returns the build context paths the COPY and ADD
+// instructions and the bind mounts of RUN instructions read. Copies from
+// stages or images, remote ADD sources and heredocs do not read the context.
+// Variables are kept as written.
+func parseDockerfileSources(r io.Reader) ([]string, error) {
+	instructions, err := dockerfileInstructions(r)
+	if err != nil {
+		return nil, err
+	}
+	var sources []string
+	for _, line := range instructions {
+		keyword, rest, _ := strings.Cut(line, " ")
+		switch {
+		case strings.EqualFold(keyword, "COPY") || strings.EqualFold(keyword, "ADD"):
+			operands, fromContext := instructionOperands(rest)
+			if !fromContext || len(operands) < 2 {
+				continue
+			}
+			for _, source := range operands[:len(operands)-1] {
+				if strings.HasPrefix(source, "<<") || strings.Contains(source, "://") || strings.HasPrefix(source, "git@") {
+					continue
+				}
+				sources = append(sources, source)
+			}
+		case strings.EqualFold(keyword, "RUN"):
+			for _, field := range strings.Fields(rest) {
+				mount, ok := strings.CutPrefix(field, "--mount=")
+				if !ok {
+					continue
+				}
+				opts := make(map[string]string)
+				for _, opt := range strings.Split(mount, ",") {
+					key, value, _ := strings.Cut(opt, "=")
+					opts[key] = value
+				}
+				if opts["type"] != "bind" || opts["from"] != "" {
+					continue
+				}
+				source := opts["source"]
+				if source == "" {
+					source = opts["src"]
+				}
+				if source == "" {
+					source = "."
+				}
+				sources = append(sources, source)
+			}
+		}
+	}
+	return sources, nil
+}
+
+// parseDockerfileLabels returns the keys of the LABEL instructions of the
+// stage that is built: target, or the last stage when target is empty.
+// Labels of an earlier stage carry over to stages built on it; labels of
+// base images are not known.
+func parseDockerfileLabels(r io.Reader, target string) ([]string, error) {
+	instructions, err := dockerfileInstructions(r)
+	if err != nil {
+		return nil, err
+	}
+
+	stages := make(map[string][]string)
+	var current []string
+	var stage string
+	for _, line := range instructions {
+		keyword, rest, _ := strings.Cut(line, " ")
+		switch {
+		case strings.EqualFold(keyword, "FROM"):
+			var fields []string
+			for _, field := range strings.Fields(rest) {
+				if !strings.HasPrefix(field, "--") {
+					fields = append(fields, field)
+				}
+			}
+			current, stage = nil, ""
+			if len(fields) > 0 {
+				current = append(current, stages[strings.ToLower(fields[0])]...)
+			}
+			if len(fields) >= 3 && strings.EqualFold(fields[1], "AS") {
+				stage = strings.ToLower(fields[2])
+			}
+		case strings.EqualFold(keyword, "LABEL"):
+			decls := splitArgDeclarations(rest)
+			// The legacy form sets a single label: LABEL key value.
+			if len(decls) > 0 && !strings.Contains(decls[0], "=") {
+				decls = decls[:1]
+			}
+			for _, decl := range decls {
+				key, _, _ := strings.Cut(decl, "=")
+				current = append(current, strings.Trim(key, `"'`))
+			}
+		}
+		if stage != "" {
+			stages[stage] = current
+		}
+	}
+	if target != "" {
+		built, ok := stages[strings.ToLower(target)]
+		if !ok {
+			return nil, fmt.Errorf("target stage %s not found", target)
+		}
+		return built, nil
+	}
+	return current, nil
+}
+
+// instructionOperands splits the operands of a COPY or ADD instruction in
+// shell or JSON form, dropping flags. It reports false for a copy from
+// another stage or image.
+func instructionOperands(rest string) ([]string, bool) {
+	var operands []string
+	fields := strings.Fields(rest)
+	for len(fields) > 0 && strings.HasPrefix(fields[0], "--") {
+		if strings.HasPrefix(fields[0], "--from=") {
+			return nil, false
+		}
+		fields = fields[1:]
+	}
+	remainder := strings.Join(fields, " ")
+	if strings.HasPrefix(remainder, "[") {
+		if err := json.Unmarshal([]byte(remainder), &operands); err == nil {
+			return operands, true
+		}
+	}
+	return fields, true
+}
+
+// splitArgDeclarations splits the operands of an ARG instruction on
+// whitespace outside of quotes.
+func splitArgDeclarations(s string) []string {
+	var decls []string
+	var current strings.Builder
+	var quote rune
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+			current.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			current.WriteRune(r)
+		case r == ' ' || r == '\t':
+			if current.Len() > 0 {
+				decls = append(decls, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		decls = append(decls, current.String())
+	}
+	return decls
+}
+
+// isPredefinedBuildArg reports whether name is available without an ARG
+// declaration.
+func isPredefinedBuildArg(name string) bool {
+	return predefinedBuildArgs[strings.ToUpper(name)] || strings.HasPrefix(name, "BUILDKIT_")
+}
+
+// dockerfileArgWarnings compares the configured build args with the ARGs the
+// Dockerfile declares. Docker silently ignores build args without a matching
+// ARG, and an ARG without a default is empty unless a value is passed.
+func dockerfileArgWarnings(cfg *Config) ([]string, error) {
+	dockerfile := cfg.Dockerfile
+	if dockerfile == "" {
+		dockerfile = "Dockerfile"
+	}
+	f, err := os.Open(dockerfile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	declared, err := parseDockerfileArgs(f)
+	if err != nil {
+		return nil, err
+	}
+
+	hasDefault := make(map[string]bool)
+	for _, arg := range declared {
+		hasDefault[arg.Name] = hasDefault[arg.Name] || arg.HasDefault
+	}
+
+	// VERSION is always passed by the plugin.
+	configured := map[string]bool{"VERSION": true}
+	for key := range cfg.BuildArgs {
+		configured[key] = true
+	}
+	for key := range cfg.BuildArgSources {
+		configured[key] = true
+	}
+
+	var warnings []string
+	for _, key := range sortedKeys(configured) {
+		if _, ok := hasDefault[key]; !ok && key != "VERSION" && !isPredefinedBuildArg(key) {
+			warnings = append(warnings, fmt.Sprintf("build arg %s is not declared by an ARG in %s and is ignored by docker", key, dockerfile))
+		}
+	}
+	for _, name := range sortedKeys(hasDefault) {
+		if !hasDefault[name] && !configured[name] && !isPredefinedBuildArg(name) {
+			warnings = append(warnings, fmt.Sprintf("ARG %s in %s has no default and no value in build_args", name, dockerfile))
+		}
+	}
+	return warnings, nil
+}
+
+// addWarnings appends non-fatal findings for field to resp.
+func addWarnings(resp *plugin.ValidateResponse, field string, warnings []string) {
+	for _, warning := range warnings {
+		resp.Errors = append(resp.Errors, plugin.ValidationError{
+			Field:   field,
+			Message: warning,
+			Code:    warningCode,
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestParseDockerfileArgs(t *testing.T) {
+	dockerfile := `# syntax=docker/dockerfile:1
+ARG GO_VERSION=1.22
+FROM golang:${GO_VERSION} AS build
+ARG GO_VERSION
+arg COMMIT \
+    BUILD_DATE="2024-01-01 00:00"
+# ARG COMMENTED
+RUN go build ./...
+`
+	args, err := parseDockerfileArgs(strings.NewReader(dockerfile))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []dockerfileArg{
+		{"GO_VERSION", true},
+		{"GO_VERSION", false},
+		{"COMMIT", false},
+		{"BUILD_DATE", true},
+	}
+	if len(args) != len(want) {
+		t.Fatalf("parseDockerfileArgs() = %v, want %v", args, want)
+	}
+	for i := range want {
+		if args[i] != want[i] {
+			t.Errorf("arg %d = %+v, want %+v", i, args[i], want[i])
+		}
+	}
+}
+
+func TestDockerfileArgWarnings(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+	writeFiles(t, dir, map[string]string{
+		"Dockerfile": "ARG GO_VERSION=1.22\nFROM golang\nARG GO_VERSION\nARG COMMIT\nARG VERSION\nARG TARGETARCH\n",
+	})
+
+	warnings, err := dockerfileArgWarnings(&Config{
+		BuildArgs:       map[string]string{"GO_VERSION": "1.23", "NODE_VERSION": "20", "HTTP_PROXY": "http://proxy"},
+		BuildArgSources: map[string]BuildArgSource{"NPM_TOKEN": {FromEnv: "NPM_TOKEN"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := strings.Join(warnings, "\n")
+	for _, want := range []string{"build arg NODE_VERSION is not declared", "build arg NPM_TOKEN is not declared", "ARG COMMIT in Dockerfile has no default"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected warning %q, got:\n%s", want, got)
+		}
+	}
+	if len(warnings) != 3 {
+		t.Errorf("expected 3 warnings, got:\n%s", got)
+	}
+}
+
+func TestValidateReportsDockerfileArgWarnings(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+	writeFiles(t, dir, map[string]string{"Dockerfile": "FROM scratch\nARG COMMIT\n"})
+
+	p := &DockerPlugin{}
+	resp, err := p.Validate(context.Background(), map[string]any{
+		"image":      "myapp",
+		"build_args": map[string]any{"COMIT": "abc"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Valid {
+		t.Errorf("expected warnings not to invalidate the config, got %v", resp.Errors)
+	}
+	if len(resp.Errors) != 2 {
+		t.Fatalf("expected 2 warnings, got %v", resp.Errors)
+	}
+	for _, e := range resp.Errors {
+		if e.Code != warningCode || e.Field != "build_args" {
+			t.Errorf("unexpected validation entry: %+v", e)
+		}
+	}
+}
+
+func TestParseDockerfileSources(t *testing.T) {
+	dockerfile := `FROM golang:1.22 AS build
+COPY --chown=app go.mod go.sum ./
+COPY ["cmd", "internal", "/src/"]
+RUN --mount=type=bind,source=scripts,target=/scripts --mount=type=cache,target=/root/.cache go build ./...
+ADD https://example.com/tool.tar.gz /opt/
+COPY <<EOF /etc/app.conf
+FROM scratch
+COPY --from=build /app /app
+ADD static/ /static/
+`
+	sources, err := parseDockerfileSources(strings.NewReader(dockerfile))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"go.mod", "go.sum", "cmd", "internal", "scripts", "static/"}
+	if strings.Join(sources, ",") != strings.Join(want, ",") {
+		t.Errorf("parseDockerfileSources() = %v, want %v", sources, want)
+	}
+}
+
+func TestParseDockerfileLabels(t *testing.T) {
+	dockerfile := `FROM golang:1.22 AS build
+LABEL stage=build
+FROM alpine AS base
+LABEL owner="payments team" \
+      "team"=payments
+FROM base AS release
+LABEL cost-center 4711
+FROM base AS debug
+`
+	tests := map[string]string{
+		"":        "owner,team",
+		"release": "owner,team,cost-center",
+		"build":   "stage",
+	}
+	for target, want := range tests {
+		keys, err := parseDockerfileLabels(strings.NewReader(dockerfile), target)
+		if err != nil {
+			t.Fatalf("target %q: unexpected error: %v", target, err)
+		}
+		if strings.Join(keys, ",") != want {
+			t.Errorf("target %q: parseDockerfileLabels() = %v, want %s", target, keys, want)
+		}
+	}
+	if _, err := parseDockerfileLabels(strings.NewReader(dockerfile), "missing"); err == nil {
+		t.Error("expected an error for an unknown target")
+	}
+}
+
+func TestParseDockerfileBases(t *testing.T) {
+	dockerfile := `ARG GO_VERSION=1.22
+ARG REGISTRY
+FROM --platform=$BUILDPLATFORM golang:${GO_VERSION} AS build
+FROM build AS test
+FROM ${REGISTRY:-docker.io}/library/alpine:3.19 AS runtime
+from scratch
+FROM golang:${GO_VERSION}
+COPY --from=build /app /app
+COPY --from=1 /report /report
+COPY --from=assets /static /static
+COPY --link --from=${REGISTRY:-docker.io}/tools/busybox:1.36 /bin/busybox /bin/
+RUN --mount=type=bind,from=ghcr.io/org/toolchain:2,source=/bin,target=/tools \
+    --mount=type=cache,target=/root/.cache /tools/run
+`
+	bases, err := parseDockerfileBases(strings.NewReader(dockerfile), map[string]string{"GO_VERSION": "1.23"}, []string{"assets"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"golang:1.23", "docker.io/library/alpine:3.19", "docker.io/tools/busybox:1.36", "ghcr.io/org/toolchain:2"}
+	if strings.Join(bases, ",") != strings.Join(want, ",") {
+		t.Errorf("parseDockerfileBases() = %v, want %v", bases, want)
+	}
+
+	if _, err := parseDockerfileBases(strings.NewReader("ARG BASE\nFROM $BASE\n"), nil, nil); err == nil || !strings.Contains(err.Error(), "no value for BASE") {
+		t.Errorf("expected unresolved base error, got %v", err)
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// dockerignoreSuggestionSize is the size from which a path the Dockerfile
+// does not use is worth excluding from the build context.
+const dockerignoreSuggestionSize = 1 << 20
+
+// sourceVariablePattern matches variable references in COPY and ADD
+// sources, which may name any path.
+var sourceVariablePattern = regexp.MustCompile(`\$\{?[A-Za-z_][A-Za-z0-9_]*(:-[^}]*)?\}?`)
+
+// dockerignoreSuggestion is a large context path the Dockerfile never reads.
+type dockerignoreSuggestion struct {
+	Path string
+	Size int64
+}
+
+// contextSources lists the context paths read by a Dockerfile as
+// slash-separated glob patterns relative to the context root.
+type contextSources []string
+
+// newContextSources normalizes the sources of a Dockerfile. Variables may
+// name any file within their path component.
+func newContextSources(sources []string) contextSources {
+	normalized := make(contextSources, 0, len(sources))
+	for _, source := range sources {
+		source = sourceVariablePattern.ReplaceAllString(source, "*")
+		normalized = append(normalized, path.Clean(strings.TrimPrefix(filepath.ToSlash(source), "/")))
+	}
+	return normalized
+}
+
+// all reports whether the whole context is read, e.g. by COPY . .
+func (c contextSources) all() bool {
+	for _, source := range c {
+		if source == "." || source == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// reads reports whether rel, or a directory containing it, is read.
+func (c contextSources) reads(rel string) bool {
+	for _, source := range c {
+		if matchIgnorePattern(source, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// readsBelow reports whether a path inside the directory rel is read.
+func (c contextSources) readsBelow(rel string) bool {
+	parts := strings.Split(rel, "/")
+	for _, source := range c {
+		sourceParts := strings.Split(source, "/")
+		if len(sourceParts) <= len(parts) {
+			continue
+		}
+		below := true
+		for i, part := range parts {
+			if ok, _ := path.Match(sourceParts[i], part); !ok {
+				below = false
+				break
+			}
+		}
+		if below {
+			return true
+		}
+	}
+	return false
+}
+
+// suggestDockerignore finds the paths of the build context that are sent to
+// the builder although no COPY, ADD or bind mount of the Dockerfile reads
+// them, and that are at least dockerignoreSuggestionSize large.
+func suggestDockerignore(cfg *Config) ([]dockerignoreSuggestion, error) {
+	contextDir := cfg.Context
+	if contextDir == "" {
+		contextDir = "."
+	}
+	dockerfile := cfg.Dockerfile
+	if dockerfile == "" {
+		dockerfile = "Dockerfile"
+	}
+
+	f, err := os.Open(dockerfile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	parsed, err := parseDockerfileSources(f)
+	if err != nil {
+		return nil, err
+	}
+	sources := newContextSources(append(parsed, ".dockerignore"))
+	if sources.all() {
+		return nil, nil
+	}
+	if rel, err := filepath.Rel(contextDir, dockerfile); err == nil && !strings.HasPrefix(rel, "..") {
+		sources = append(sources, filepath.ToSlash(rel))
+	}
+
+	ignore, err := loadDockerignore(contextDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read .dockerignore: %w", err)
+	}
+
+	var suggestions []dockerignoreSuggestion
+	var visit func(dir string) error
+	visit = func(dir string) error {
+		entries, err := os.ReadDir(filepath.Join(contextDir, filepath.FromSlash(dir)))
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			rel := path.Join(dir, entry.Name())
+			if ignore.matches(rel) || sources.reads(rel) {
+				continue
+			}
+			if entry.IsDir() && sources.readsBelow(rel) {
+				if err := visit(rel); err != nil {
+					return err
+				}
+				continue
+			}
+			size, err := contextSize(contextDir, rel, ignore)
+			if err != nil {
+				return err
+			}
+			if size >= dockerignoreSuggestionSize {
+				suggestions = append(suggestions, dockerignoreSuggestion{Path: rel, Size: size})
+			}
+		}
+		return nil
+	}
+	if err := visit("."); err != nil {
+		return nil, fmt.Errorf("failed to read build context: %w", err)
+	}
+	return suggestions, nil
+}
+
+// contextSize returns the size of the files below rel sent in the build
+// context.
+func contextSize(contextDir, rel string, ignore *dockerignore) (int64, error) {
+	var size int64
+	root := filepath.Join(contextDir, filepath.FromSlash(rel))
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		r, err := filepath.Rel(contextDir, p)
+		if err != nil {
+			return err
+		}
+		if ignore.matches(filepath.ToSlash(r)) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// appendDockerignore adds the suggested paths to the .dockerignore of the
+// build context, creating it if needed.
+func appendDockerignore(cfg *Config, suggestions []dockerignoreSuggestion) error {
+	contextDir := cfg.Context
+	if contextDir == "" {
+		contextDir = "."
+	}
+	file := filepath.Join(contextDir, ".dockerignore")
+	existing, err := os.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var b strings.Builder
+	if len(existing) > 0 && !strings.HasSuffix(string(existing), "\n") {
+		b.WriteString("\n")
+	}
+	b.WriteString("\n# Not used by the Dockerfile\n")
+	for _, s := range suggestions {
+		b.WriteString("/" + s.Path + "\n")
+	}
+	return os.WriteFile(file, append(existing, b.String()...), 0o644)
+}
+
+// dockerignoreWarnings suggests .dockerignore entries for large unused
+// context paths. It only reads the context: with fix_dockerignore, the
+// entries are added by fixDockerignore when the release runs.
+func dockerignoreWarnings(cfg *Config) ([]string, error) {
+	suggestions, err := suggestDockerignore(cfg)
+	if err != nil || len(suggestions) == 0 {
+		return nil, err
+	}
+	warnings := make([]string, 0, len(suggestions))
+	for _, s := range suggestions {
+		if cfg.FixDockerignore {
+			warnings = append(warnings, fmt.Sprintf("%s (%s) is sent in the build context but not used by the Dockerfile; the release adds /%s to .dockerignore", s.Path, formatBytes(s.Size), s.Path))
+		} else {
+			warnings = append(warnings, fmt.Sprintf("%s (%s) is sent in the build context but not used by the Dockerfile; add /%s to .dockerignore", s.Path, formatBytes(s.Size), s.Path))
+		}
+	}
+	return warnings, nil
+}
+
+// fixDockerignore adds the suggested .dockerignore entries to the build
+// context before it is built, returning a warning per added entry.
+func fixDockerignore(cfg *Config) ([]string, error) {
+	suggestions, err := suggestDockerignore(cfg)
+	if err != nil || len(suggestions) == 0 {
+		return nil, err
+	}
+	if err := appendDockerignore(cfg, suggestions); err != nil {
+		return nil, err
+	}
+	warnings := make([]string, 0, len(suggestions))
+	for _, s := range suggestions {
+		warnings = append(warnings, fmt.Sprintf("added /%s (%s, not used by the Dockerfile) to .dockerignore", s.Path, formatBytes(s.Size)))
+	}
+	return warnings, nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// writeLargeFile writes a file of dockerignoreSuggestionSize bytes.
+func writeLargeFile(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, make([]byte, dockerignoreSuggestionSize), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSuggestDockerignore(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"Dockerfile":       "FROM alpine\nCOPY app/src /src\nCOPY config/${ENV}.yaml /etc/\n",
+		".dockerignore":    "node_modules\n",
+		"app/src/main.go":  "package main",
+		"config/prod.yaml": "env: prod",
+		"README.md":        "small files are not worth excluding",
+	})
+	writeLargeFile(t, filepath.Join(dir, "app/fixtures/dump.sql"))
+	writeLargeFile(t, filepath.Join(dir, "app/src/assets.bin"))
+	writeLargeFile(t, filepath.Join(dir, "docs/video.mp4"))
+	writeLargeFile(t, filepath.Join(dir, "config/big.yaml"))
+	writeLargeFile(t, filepath.Join(dir, "node_modules/pkg/index.js"))
+	chdir(t, dir)
+
+	suggestions, err := suggestDockerignore(&Config{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var paths []string
+	for _, s := range suggestions {
+		paths = append(paths, s.Path)
+	}
+	if strings.Join(paths, ",") != "app/fixtures,docs" {
+		t.Errorf("suggestDockerignore() = %v, want app/fixtures and docs", paths)
+	}
+}
+
+func TestSuggestDockerignoreWholeContext(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"Dockerfile": "FROM alpine\nCOPY . /app\n"})
+	writeLargeFile(t, filepath.Join(dir, "docs/video.mp4"))
+	chdir(t, dir)
+
+	if suggestions, err := suggestDockerignore(&Config{}); err != nil || len(suggestions) != 0 {
+		t.Errorf("expected no suggestions when the whole context is copied, got %v, %v", suggestions, err)
+	}
+}
+
+func TestFixDockerignore(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"Dockerfile":    "FROM alpine\nCOPY bin/app /app\n",
+		".dockerignore": ".git",
+		"bin/app":       "binary",
+	})
+	writeLargeFile(t, filepath.Join(dir, "testdata/golden.tar"))
+	chdir(t, dir)
+
+	p := &DockerPlugin{executor: &MockCommandExecutor{}}
+	resp, err := p.Validate(context.Background(), map[string]any{"image": "myapp"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Valid || !hasWarning(resp.Errors, "context", "add /testdata to .dockerignore") {
+		t.Errorf("expected a .dockerignore suggestion, got %+v", resp)
+	}
+
+	config := map[string]any{"image": "myapp", "fix_dockerignore": true, "push": false}
+	resp, err = p.Validate(context.Background(), config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !hasWarning(resp.Errors, "context", "the release adds /testdata") {
+		t.Errorf("expected the entry to be announced, got %+v", resp.Errors)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, ".dockerignore")); string(data) != ".git" {
+		t.Errorf("expected validation to leave .dockerignore unchanged, got:\n%s", data)
+	}
+
+	for _, dryRun := range []bool{true, false} {
+		execResp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+			Hook:    plugin.HookPostPublish,
+			Config:  config,
+			Context: plugin.ReleaseContext{Version: "1.0.0"},
+			DryRun:  dryRun,
+		})
+		if err != nil || !execResp.Success {
+			t.Fatalf("dry run %v: expected success, got %+v, %v", dryRun, execResp, err)
+		}
+		data, _ := os.ReadFile(filepath.Join(dir, ".dockerignore"))
+		if dryRun && string(data) != ".git" {
+			t.Errorf("expected a dry run to leave .dockerignore unchanged, got:\n%s", data)
+		}
+		if dryRun {
+			continue
+		}
+		if string(data) != ".git\n\n# Not used by the Dockerfile\n/testdata\n" {
+			t.Errorf("unexpected .dockerignore:\n%s", data)
+		}
+		warnings, _ := execResp.Outputs["warnings"].([]string)
+		if !slices.ContainsFunc(warnings, func(w string) bool { return strings.Contains(w, "added /testdata") }) {
+			t.Errorf("expected the added entry to be reported, got %v", warnings)
+		}
+	}
+
+	resp, _ = p.Validate(context.Background(), map[string]any{"image": "myapp"})
+	if hasWarning(resp.Errors, "context", "testdata") {
+		t.Errorf("expected no suggestion once the path is ignored, got %+v", resp.Errors)
+	}
+}
+
+// hasWarning reports whether errors holds a warning for field containing
+// text.
+func hasWarning(errors []plugin.ValidationError, field, text string) bool {
+	for _, e := range errors {
+		if e.Code == warningCode && e.Field == field && strings.Contains(e.Message, text) {
+			return true
+		}
+	}
+	return false
+}
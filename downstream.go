@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// defaultGitHubAPI is the API dispatching workflows of downstreams with a
+// repo; api_url points at GitHub Enterprise instead.
+const defaultGitHubAPI = "https://api.github.com"
+
+// githubRepoPattern matches owner/name GitHub repositories.
+var githubRepoPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+/[A-Za-z0-9_.-]+$`)
+
+// Downstream is a consumer of the image notified once it is pushed, so its
+// pipeline can rebuild against the new image: a GitHub Actions workflow
+// dispatched with repo and workflow, or a generic webhook receiving a JSON
+// event at url.
+type Downstream struct {
+	Repo     string
+	Workflow string
+	// Ref is the branch the workflow runs on; main when empty.
+	Ref    string
+	APIURL string
+
+	URL string
+	// Token authenticates the dispatch, or is sent to the webhook as a
+	// bearer token.
+	Token string
+}
+
+// target names the downstream in warnings and outputs.
+func (d Downstream) target() string {
+	if d.Repo != "" {
+		return d.Repo + "/" + d.Workflow
+	}
+	if u, err := url.Parse(d.URL); err == nil {
+		return u.Redacted()
+	}
+	return d.URL
+}
+
+// DownstreamEvent is the body a downstream webhook receives.
+type DownstreamEvent struct {
+	Event   string   `json:"event"`
+	Image   string   `json:"image"`
+	Version string   `json:"version"`
+	Digest  string   `json:"digest,omitempty"`
+	Refs    []string `json:"refs"`
+}
+
+// parseDownstreams reads the downstreams list. A token may be given
+// directly or through token_env; workflow dispatches default to
+// GITHUB_TOKEN.
+func parseDownstreams(raw map[string]any) []Downstream {
+	items, ok := raw["downstreams"].([]any)
+	if !ok {
+		return nil
+	}
+	downstreams := make([]Downstream, 0, len(items))
+	for _, item := range items {
+		m, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		d := Downstream{}
+		d.Repo, _ = m["repo"].(string)
+		d.Workflow, _ = m["workflow"].(string)
+		d.Ref, _ = m["ref"].(string)
+		d.APIURL, _ = m["api_url"].(string)
+		d.URL, _ = m["url"].(string)
+		d.Token, _ = m["token"].(string)
+		if env, ok := m["token_env"].(string); ok && d.Token == "" {
+			d.Token = os.Getenv(env)
+		}
+		if d.Repo != "" && d.Token == "" {
+			d.Token = os.Getenv("GITHUB_TOKEN")
+		}
+		downstreams = append(downstreams, d)
+	}
+	return downstreams
+}
+
+// validateDownstreams checks that every downstream is either a workflow
+// dispatch or a webhook.
+func validateDownstreams(cfg *Config) error {
+	for i, d := range cfg.Downstreams {
+		switch {
+		case d.Repo != "" && d.URL != "":
+			return fmt.Errorf("downstream %d: set either repo or url", i)
+		case d.Repo != "":
+			if !githubRepoPattern.MatchString(d.Repo) {
+				return fmt.Errorf("downstream %d: repo must be owner/name", i)
+			}
+			if d.Workflow == "" {
+				return fmt.Errorf("downstream %d: workflow is required with repo", i)
+			}
+			if d.APIURL != "" {
+				if err := validateApprovalURL(d.APIURL); err != nil {
+					return fmt.Errorf("downstream %d: api_url %v", i, err)
+				}
+			}
+			if d.Token == "" {
+				return fmt.Errorf("downstream %d: a token is required to dispatch workflows (token, token_env or GITHUB_TOKEN)", i)
+			}
+		case d.URL != "":
+			if err := validateApprovalURL(d.URL); err != nil {
+				return fmt.Errorf("downstream %d: url %v", i, err)
+			}
+		default:
+			return fmt.Errorf("downstream %d: repo or url is required", i)
+		}
+	}
+	return nil
+}
+
+// notifiedImage returns the reference downstreams rebuild against: the
+// first released reference, pinned to its digest when it is known.
+func notifiedImage(outputs *Outputs) string {
+	if len(outputs.Refs) == 0 {
+		return ""
+	}
+	if outputs.Digest == "" {
+		return outputs.Refs[0]
+	}
+	return outputs.Refs[0] + "@" + outputs.Digest
+}
+
+// notifyDownstreams notifies every downstream of the pushed image. The
+// release is already published, so failures are returned as warnings
+// rather than failing it.
+func (p *DockerPlugin) notifyDownstreams(ctx context.Context, cfg *Config, version string, outputs *Outputs) []string {
+	if len(cfg.Downstreams) == 0 {
+		return nil
+	}
+	event := DownstreamEvent{
+		Event:   "image_pushed",
+		Image:   notifiedImage(outputs),
+		Version: version,
+		Digest:  outputs.Digest,
+		Refs:    outputs.Refs,
+	}
+
+	started := time.Now()
+	var warnings, failed []string
+	for _, d := range cfg.Downstreams {
+		var err error
+		if d.Repo != "" {
+			err = p.dispatchWorkflow(ctx, d, event)
+		} else {
+			err = p.postWebhook(ctx, d, event)
+		}
+		if err != nil {
+			failed = append(failed, d.target())
+			warnings = append(warnings, fmt.Sprintf("failed to notify downstream %s: %v", d.target(), err))
+			continue
+		}
+		outputs.Notified = append(outputs.Notified, d.target())
+	}
+	var err error
+	if len(failed) > 0 {
+		err = fmt.Errorf("failed to notify %s", strings.Join(failed, ", "))
+	}
+	outputs.stage("notify", started, err)
+	return warnings
+}
+
+// dispatchWorkflow triggers the workflow_dispatch event of the downstream
+// workflow, passing the image and version as its inputs.
+func (p *DockerPlugin) dispatchWorkflow(ctx context.Context, d Downstream, event DownstreamEvent) error {
+	api := d.APIURL
+	if api == "" {
+		api = defaultGitHubAPI
+	}
+	ref := d.Ref
+	if ref == "" {
+		ref = "main"
+	}
+	body := map[string]any{
+		"ref":    ref,
+		"inputs": map[string]string{"image": event.Image, "version": event.Version},
+	}
+	target := fmt.Sprintf("%s/repos/%s/actions/workflows/%s/dispatches", strings.TrimSuffix(api, "/"), d.Repo, url.PathEscape(d.Workflow))
+	return p.postJSON(ctx, target, "Bearer "+d.Token, body, map[string]string{"Accept": "application/vnd.github+json"})
+}
+
+// postWebhook sends the event to the downstream webhook.
+func (p *DockerPlugin) postWebhook(ctx context.Context, d Downstream, event DownstreamEvent) error {
+	authorization := ""
+	if d.Token != "" {
+		authorization = "Bearer " + d.Token
+	}
+	return p.postJSON(ctx, d.URL, authorization, event, nil)
+}
+
+// postJSON posts body as JSON to target, failing unless it answers with a
+// 2xx status.
+func (p *DockerPlugin) postJSON(ctx context.Context, target, authorization string, body any, headers map[string]string) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", defaultUserAgent)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	resp, err := p.getHTTPClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestValidateDownstreams(t *testing.T) {
+	tests := []struct {
+		name       string
+		downstream Downstream
+		wantErr    string
+	}{
+		{"workflow", Downstream{Repo: "myorg/api", Workflow: "rebuild.yml", Token: "t"}, ""},
+		{"webhook", Downstream{URL: "https://ci.example.com/hook"}, ""},
+		{"neither", Downstream{}, "repo or url is required"},
+		{"both", Downstream{Repo: "myorg/api", Workflow: "rebuild.yml", URL: "https://ci.example.com/hook"}, "either repo or url"},
+		{"bad repo", Downstream{Repo: "api", Workflow: "rebuild.yml", Token: "t"}, "owner/name"},
+		{"no workflow", Downstream{Repo: "myorg/api", Token: "t"}, "workflow is required"},
+		{"no token", Downstream{Repo: "myorg/api", Workflow: "rebuild.yml"}, "token is required"},
+		{"bad url", Downstream{URL: "ftp://ci.example.com"}, "http(s) URL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateDownstreams(&Config{Downstreams: []Downstream{tt.downstream}})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestParseDownstreamsTokenDefaults(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "gh-token")
+	t.Setenv("HOOK_TOKEN", "hook-token")
+	downstreams := parseDownstreams(map[string]any{"downstreams": []any{
+		map[string]any{"repo": "myorg/api", "workflow": "rebuild.yml"},
+		map[string]any{"url": "https://ci.example.com/hook", "token_env": "HOOK_TOKEN"},
+		map[string]any{"url": "https://ci.example.com/open"},
+	}})
+	if len(downstreams) != 3 {
+		t.Fatalf("expected 3 downstreams, got %d", len(downstreams))
+	}
+	if downstreams[0].Token != "gh-token" || downstreams[1].Token != "hook-token" || downstreams[2].Token != "" {
+		t.Errorf("unexpected tokens: %q, %q, %q", downstreams[0].Token, downstreams[1].Token, downstreams[2].Token)
+	}
+}
+
+func TestNotifyDownstreams(t *testing.T) {
+	var mu sync.Mutex
+	requests := map[string]map[string]any{}
+	auth := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		requests[r.URL.Path] = body
+		auth[r.URL.Path] = r.Header.Get("Authorization")
+		switch r.URL.Path {
+		case "/repos/myorg/api/actions/workflows/rebuild.yml/dispatches":
+			w.WriteHeader(http.StatusNoContent)
+		case "/hook":
+		default:
+			http.Error(w, "no such hook", http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &Config{Downstreams: []Downstream{
+		{Repo: "myorg/api", Workflow: "rebuild.yml", APIURL: server.URL, Token: "gh-token"},
+		{URL: server.URL + "/hook", Token: "hook-token"},
+		{URL: server.URL + "/missing"},
+	}}
+	outputs := &Outputs{Refs: []string{"ghcr.io/myorg/base:1.2.0", "ghcr.io/myorg/base:latest"}, Digest: "sha256:abc"}
+	p := &DockerPlugin{httpClient: server.Client()}
+	warnings := p.notifyDownstreams(context.Background(), cfg, "1.2.0", outputs)
+
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "/missing") || !strings.Contains(warnings[0], "404") {
+		t.Errorf("expected a warning for the missing hook, got %v", warnings)
+	}
+	if len(outputs.Notified) != 2 || outputs.Notified[0] != "myorg/api/rebuild.yml" {
+		t.Errorf("unexpected notified downstreams: %v", outputs.Notified)
+	}
+	if len(outputs.Stages) != 1 || outputs.Stages[0].Name != "notify" || outputs.Stages[0].Status != stageFailed {
+		t.Errorf("expected a failed notify stage, got %+v", outputs.Stages)
+	}
+
+	dispatch := requests["/repos/myorg/api/actions/workflows/rebuild.yml/dispatches"]
+	inputs, _ := dispatch["inputs"].(map[string]any)
+	if dispatch["ref"] != "main" || inputs["image"] != "ghcr.io/myorg/base:1.2.0@sha256:abc" || inputs["version"] != "1.2.0" {
+		t.Errorf("unexpected workflow dispatch: %v", dispatch)
+	}
+	if auth["/repos/myorg/api/actions/workflows/rebuild.yml/dispatches"] != "Bearer gh-token" {
+		t.Errorf("expected the dispatch to send the token")
+	}
+	hook := requests["/hook"]
+	if hook["event"] != "image_pushed" || hook["digest"] != "sha256:abc" || len(hook["refs"].([]any)) != 2 {
+		t.Errorf("unexpected webhook event: %v", hook)
+	}
+	if auth["/hook"] != "Bearer hook-token" || auth["/missing"] != "" {
+		t.Errorf("unexpected webhook authorization: %v", auth)
+	}
+}
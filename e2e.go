@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// defaultE2ETimeout bounds the deployment and verification of the e2e stage.
+const defaultE2ETimeout = 10 * time.Minute
+
+// e2eImageVar is the variable replaced with the pushed image reference in
+// compose files and manifests, and set for the verify command.
+const e2eImageVar = "E2E_IMAGE"
+
+// composeProjectInvalid matches the characters compose project names may
+// not contain.
+var composeProjectInvalid = regexp.MustCompile(`[^a-z0-9_-]+`)
+
+// namespacePattern matches Kubernetes namespace names.
+var namespacePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// E2EConfig describes an ephemeral deployment that verifies the pushed image
+// before the release continues.
+type E2EConfig struct {
+	// ComposeFile is deployed with docker compose; ${E2E_IMAGE} is resolved
+	// by compose from the environment.
+	ComposeFile string
+	// Manifest is applied with kubectl after replacing ${E2E_IMAGE}.
+	Manifest  string
+	Namespace string
+	Verify    []string
+	Timeout   string
+}
+
+// parseE2E reads the e2e option, returning nil when it is not set.
+func parseE2E(raw map[string]any) *E2EConfig {
+	m, ok := raw["e2e"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	e2e := &E2EConfig{}
+	e2e.ComposeFile, _ = m["compose_file"].(string)
+	e2e.Manifest, _ = m["manifest"].(string)
+	e2e.Namespace, _ = m["namespace"].(string)
+	e2e.Timeout, _ = m["timeout"].(string)
+	if verify, ok := m["verify"].([]any); ok {
+		for _, arg := range verify {
+			if s, ok := arg.(string); ok {
+				e2e.Verify = append(e2e.Verify, s)
+			}
+		}
+	}
+	return e2e
+}
+
+// validateE2E checks the e2e settings. Exactly one deployment method is
+// required, and the files must stay inside the working directory.
+func validateE2E(cfg *Config) error {
+	e2e := cfg.E2E
+	if e2e == nil {
+		return nil
+	}
+	if (e2e.ComposeFile == "") == (e2e.Manifest == "") {
+		return fmt.Errorf("exactly one of compose_file or manifest is required")
+	}
+	if err := validatePath(e2e.ComposeFile); err != nil {
+		return fmt.Errorf("invalid compose_file: %v", err)
+	}
+	if err := validatePath(e2e.Manifest); err != nil {
+		return fmt.Errorf("invalid manifest: %v", err)
+	}
+	if e2e.Namespace != "" {
+		if e2e.Manifest == "" {
+			return fmt.Errorf("namespace requires manifest")
+		}
+		if !namespacePattern.MatchString(e2e.Namespace) {
+			return fmt.Errorf("invalid namespace %q", e2e.Namespace)
+		}
+	}
+	if len(e2e.Verify) == 0 || strings.TrimSpace(e2e.Verify[0]) == "" {
+		return fmt.Errorf("verify must start with an executable")
+	}
+	if e2e.Timeout != "" {
+		if d, err := time.ParseDuration(e2e.Timeout); err != nil || d <= 0 {
+			return fmt.Errorf("timeout must be a positive duration such as 5m")
+		}
+	}
+	if cfg.Canary == nil && len(movingTemplates(cfg)) == len(tagTemplates(cfg)) {
+		return fmt.Errorf("requires canary or a version-specific tag, such as {{version}}, to verify before the moving tags")
+	}
+	return nil
+}
+
+// e2eComposeProject names the compose project of the e2e deployment of a
+// release after its image and version, e.g. relicta-e2e-myapp-1-2-3, so
+// that concurrent releases do not share, or tear down, one project.
+func e2eComposeProject(cfg *Config, version string) string {
+	name := cfg.Image
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	project := strings.ToLower("relicta-e2e-" + name + "-" + strings.TrimPrefix(version, "v"))
+	return strings.Trim(composeProjectInvalid.ReplaceAllString(project, "-"), "-")
+}
+
+// e2eTimeout returns the configured e2e timeout or the default.
+func e2eTimeout(e2e *E2EConfig) time.Duration {
+	if timeout, err := time.ParseDuration(e2e.Timeout); err == nil && timeout > 0 {
+		return timeout
+	}
+	return defaultE2ETimeout
+}
+
+// runE2E deploys image, released as version, to the e2e environment, runs
+// the verify command and tears the environment down again. Teardown also runs when deployment or
+// verification fails; a failed teardown after a successful verification is
+// returned as a warning.
+func (p *DockerPlugin) runE2E(ctx context.Context, cfg *Config, image, version string) (string, error) {
+	e2e := cfg.E2E
+	env := []string{"env", e2eImageVar + "=" + image}
+	project := e2eComposeProject(cfg, version)
+
+	var manifest string
+	if e2e.Manifest != "" {
+		data, err := os.ReadFile(e2e.Manifest)
+		if err != nil {
+			return "", fmt.Errorf("failed to read manifest: %w", err)
+		}
+		manifest = strings.ReplaceAll(string(data), "${"+e2eImageVar+"}", image)
+	}
+
+	deployCtx, cancel := context.WithTimeout(ctx, e2eTimeout(e2e))
+	defer cancel()
+
+	err := p.e2eDeploy(deployCtx, e2e, project, env, manifest)
+	if err == nil {
+		name, args := wrapCommand(e2e.Verify[0], e2e.Verify[1:], env)
+		if verifyErr := p.getExecutor().Run(deployCtx, name, args, nil); verifyErr != nil {
+			err = fmt.Errorf("verify command failed: %w", verifyErr)
+		}
+	}
+
+	// Tear down even when the deadline was hit.
+	teardownErr := p.e2eTeardown(context.WithoutCancel(ctx), e2e, project, env, manifest)
+	if err != nil {
+		return "", err
+	}
+	if teardownErr != nil {
+		return fmt.Sprintf("e2e environment was not torn down: %v", teardownErr), nil
+	}
+	return "", nil
+}
+
+func (p *DockerPlugin) e2eDeploy(ctx context.Context, e2e *E2EConfig, project string, env []string, manifest string) error {
+	if e2e.ComposeFile != "" {
+		name, args := wrapCommand("docker", []string{"compose", "-f", e2e.ComposeFile, "-p", project, "up", "-d", "--wait"}, env)
+		if err := p.getExecutor().Run(ctx, name, args, nil); err != nil {
+			return fmt.Errorf("failed to deploy %s: %w", e2e.ComposeFile, err)
+		}
+		return nil
+	}
+	if err := p.getExecutor().Run(ctx, "kubectl", kubectlArgs(e2e, "apply", "-f", "-"), strings.NewReader(manifest)); err != nil {
+		return fmt.Errorf("failed to apply %s: %w", e2e.Manifest, err)
+	}
+	return nil
+}
+
+func (p *DockerPlugin) e2eTeardown(ctx context.Context, e2e *E2EConfig, project string, env []string, manifest string) error {
+	if e2e.ComposeFile != "" {
+		name, args := wrapCommand("docker", []string{"compose", "-f", e2e.ComposeFile, "-p", project, "down", "--volumes", "--remove-orphans"}, env)
+		return p.getExecutor().Run(ctx, name, args, nil)
+	}
+	return p.getExecutor().Run(ctx, "kubectl", kubectlArgs(e2e, "delete", "--ignore-not-found", "-f", "-"), strings.NewReader(manifest))
+}
+
+// kubectlArgs prefixes args with the e2e namespace, if any.
+func kubectlArgs(e2e *E2EConfig, args ...string) []string {
+	if e2e.Namespace == "" {
+		return args
+	}
+	return append([]string{"--namespace", e2e.Namespace}, args...)
+}
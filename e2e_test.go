@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestValidateE2E(t *testing.T) {
+	verify := []string{"./smoke.sh"}
+	tests := []struct {
+		name    string
+		e2e     *E2EConfig
+		wantErr bool
+	}{
+		{"unset", nil, false},
+		{"compose", &E2EConfig{ComposeFile: "e2e/compose.yml", Verify: verify, Timeout: "5m"}, false},
+		{"manifest", &E2EConfig{Manifest: "e2e/deploy.yaml", Namespace: "release-e2e", Verify: verify}, false},
+		{"no deployment", &E2EConfig{Verify: verify}, true},
+		{"both deployments", &E2EConfig{ComposeFile: "compose.yml", Manifest: "deploy.yaml", Verify: verify}, true},
+		{"path traversal", &E2EConfig{ComposeFile: "../compose.yml", Verify: verify}, true},
+		{"namespace with compose", &E2EConfig{ComposeFile: "compose.yml", Namespace: "e2e", Verify: verify}, true},
+		{"invalid namespace", &E2EConfig{Manifest: "deploy.yaml", Namespace: "E2E", Verify: verify}, true},
+		{"no verify", &E2EConfig{ComposeFile: "compose.yml"}, true},
+		{"invalid timeout", &E2EConfig{ComposeFile: "compose.yml", Verify: verify, Timeout: "soon"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateE2E(&Config{E2E: tt.e2e}); (err != nil) != tt.wantErr {
+				t.Errorf("validateE2E() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExecuteE2ECompose(t *testing.T) {
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image": "myapp",
+			"e2e":   map[string]any{"compose_file": "e2e/compose.yml", "verify": []any{"./smoke.sh", "--fast"}},
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+
+	var calls []string
+	for _, call := range mock.RunCalls {
+		if call.Name == "env" || containsFlag(call.Args, "push") || containsFlag(call.Args, "imagetools") {
+			calls = append(calls, call.Name+" "+strings.Join(call.Args, " "))
+		}
+	}
+	// latest moves only once the version tag was verified.
+	want := []string{
+		"docker push myapp:1.0.0",
+		"env E2E_IMAGE=myapp:1.0.0 docker compose -f e2e/compose.yml -p relicta-e2e-myapp-1-0-0 up -d --wait",
+		"env E2E_IMAGE=myapp:1.0.0 ./smoke.sh --fast",
+		"env E2E_IMAGE=myapp:1.0.0 docker compose -f e2e/compose.yml -p relicta-e2e-myapp-1-0-0 down --volumes --remove-orphans",
+		"docker buildx imagetools create --tag myapp:latest myapp:1.0.0",
+	}
+	if strings.Join(calls, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected commands:\n%s", strings.Join(calls, "\n"))
+	}
+}
+
+func TestE2EComposeProject(t *testing.T) {
+	tests := []struct {
+		image, version, want string
+	}{
+		{"myapp", "1.0.0", "relicta-e2e-myapp-1-0-0"},
+		{"ghcr.io/MyOrg/My.App", "v2.1.0-rc.1+build.7", "relicta-e2e-my-app-2-1-0-rc-1-build-7"},
+	}
+	for _, tt := range tests {
+		if got := e2eComposeProject(&Config{Image: tt.image}, tt.version); got != tt.want {
+			t.Errorf("e2eComposeProject(%q, %q) = %q, want %q", tt.image, tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestExecuteE2EVerifiesCanaryBeforePromotion(t *testing.T) {
+	mock := &MockCommandExecutor{
+		OutputFunc: func(_ context.Context, name string, args []string) ([]byte, error) {
+			if containsFlag(args, "inspect") {
+				return []byte(testDigest), nil
+			}
+			return nil, nil
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":  "myapp",
+			"canary": map[string]any{"tag": "canary"},
+			"e2e":    map[string]any{"compose_file": "compose.yml", "verify": []any{"./smoke.sh"}},
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil || !resp.Success {
+		t.Fatalf("expected success, got %+v, %v", resp, err)
+	}
+	verified, promoted := -1, -1
+	for i, call := range mock.RunCalls {
+		switch {
+		case call.Name == "env" && containsFlag(call.Args, "./smoke.sh"):
+			if !containsFlag(call.Args, "E2E_IMAGE=myapp@"+testDigest) {
+				t.Errorf("expected the canary digest to be verified, got %v", call.Args)
+			}
+			verified = i
+		case containsFlag(call.Args, "imagetools") && containsFlag(call.Args, "create"):
+			promoted = i
+		}
+	}
+	if verified < 0 || promoted < verified {
+		t.Errorf("expected the canary to be verified before its promotion, got %v", mock.RunCalls)
+	}
+}
+
+func TestValidateE2ERequiresVersionTag(t *testing.T) {
+	e2e := &E2EConfig{ComposeFile: "compose.yml", Verify: []string{"./smoke.sh"}}
+	if err := validateE2E(&Config{E2E: e2e, Tags: []string{"latest", "{{major}}"}}); err == nil || !strings.Contains(err.Error(), "version-specific tag") {
+		t.Errorf("expected only moving tags to be rejected, got %v", err)
+	}
+	if err := validateE2E(&Config{E2E: e2e, Tags: []string{"latest"}, Canary: &CanaryConfig{Tag: "canary"}}); err != nil {
+		t.Errorf("expected a canary to be verified instead, got %v", err)
+	}
+}
+
+func TestExecuteE2EManifestFailureTearsDown(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"deploy.yaml": "image: ${E2E_IMAGE}\n",
+	})
+	chdir(t, dir)
+
+	mock := &MockCommandExecutor{
+		RunFunc: func(_ context.Context, name string, args []string, _ io.Reader) error {
+			if name == "env" && containsFlag(args, "./smoke.sh") {
+				return errors.New("exit status 1")
+			}
+			return nil
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image": "myapp",
+			"e2e":   map[string]any{"manifest": "deploy.yaml", "namespace": "e2e", "verify": []any{"./smoke.sh"}},
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success || !strings.Contains(resp.Error, "verify command failed") {
+		t.Fatalf("expected verification failure, got %+v", resp)
+	}
+	var applied, deleted string
+	for _, call := range mock.RunCalls {
+		switch {
+		case call.Name == "kubectl" && containsArg(call.Args, "--namespace", "e2e") && containsFlag(call.Args, "apply"):
+			applied = call.Stdin
+		case call.Name == "kubectl" && containsArg(call.Args, "--namespace", "e2e") && containsFlag(call.Args, "delete"):
+			deleted = call.Stdin
+		}
+	}
+	if applied != "image: myapp:1.0.0\n" {
+		t.Errorf("expected substituted manifest to be applied, got %q", applied)
+	}
+	if deleted != applied {
+		t.Errorf("expected the applied manifest to be deleted, got %q", deleted)
+	}
+	for _, call := range mock.RunCalls {
+		if strings.Contains(strings.Join(call.Args, " "), "myapp:latest") && (containsFlag(call.Args, "push") || containsFlag(call.Args, "imagetools")) {
+			t.Errorf("expected latest to stay unmoved after the failed verification, got %s %v", call.Name, call.Args)
+		}
+	}
+}
+
+func TestExecuteE2ETeardownFailureWarns(t *testing.T) {
+	mock := &MockCommandExecutor{
+		RunFunc: func(_ context.Context, name string, args []string, _ io.Reader) error {
+			if name == "env" && containsFlag(args, "down") {
+				return errors.New("exit status 1")
+			}
+			return nil
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image": "myapp",
+			"e2e":   map[string]any{"compose_file": "compose.yml", "verify": []any{"./smoke.sh"}},
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+	warnings, _ := resp.Outputs["warnings"].([]string)
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "not torn down") {
+		t.Errorf("expected teardown warning, got %v", warnings)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ecrTokenTTL is the lifetime of the ECR authorization tokens printed by
+// aws ecr get-login-password.
+const ecrTokenTTL = "12h"
+
+// ecrHostPattern matches the hostnames of ECR private registries, e.g.
+// 123456789012.dkr.ecr.eu-west-1.amazonaws.com, capturing the account and
+// region.
+var ecrHostPattern = regexp.MustCompile(`^([0-9]{12})\.dkr\.ecr(?:-fips)?\.([a-z0-9-]+)\.amazonaws\.com(?:\.cn)?$`)
+
+// ECRConfig sets up the ECR repository of the image before it is pushed.
+type ECRConfig struct {
+	// CreateRepository creates the repository when it does not exist;
+	// existing repositories are left unchanged.
+	CreateRepository bool
+	ImmutableTags    bool
+	ScanOnPush       bool
+}
+
+// parseECR reads the ecr option, returning nil when it is not set.
+func parseECR(raw map[string]any) *ECRConfig {
+	m, ok := raw["ecr"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	ecr := &ECRConfig{}
+	ecr.CreateRepository, _ = m["create_repository"].(bool)
+	ecr.ImmutableTags, _ = m["immutable_tags"].(bool)
+	ecr.ScanOnPush, _ = m["scan_on_push"].(bool)
+	return ecr
+}
+
+// ecrRegistry returns the account and region of an ECR registry.
+func ecrRegistry(registry string) (account, region string, ok bool) {
+	m := ecrHostPattern.FindStringSubmatch(registry)
+	if m == nil {
+		return "", "", false
+	}
+	return m[1], m[2], true
+}
+
+// applyECR logs in to ECR registries configured without credentials with
+// the token of aws ecr get-login-password, which the AWS CLI obtains from
+// the usual credential chain: environment, profile, or the role of the CI
+// runner. The token is renewed before its 12 hour lifetime runs out.
+func applyECR(cfg *Config) {
+	_, region, ok := ecrRegistry(cfg.Registry)
+	if !ok || len(cfg.PasswordCommand) > 0 || hasStaticCredentials(cfg) {
+		return
+	}
+	if cfg.Username != "" && cfg.Username != "AWS" {
+		return
+	}
+	cfg.Username = "AWS"
+	cfg.PasswordCommand = []string{"aws", "ecr", "get-login-password", "--region", region}
+	if cfg.TokenTTL == "" {
+		cfg.TokenTTL = ecrTokenTTL
+	}
+}
+
+// validateECR checks that the ecr option is set for an ECR registry.
+func validateECR(cfg *Config) error {
+	if cfg.ECR == nil {
+		return nil
+	}
+	if _, _, ok := ecrRegistry(cfg.Registry); !ok {
+		return fmt.Errorf("ecr requires an ECR registry such as <account>.dkr.ecr.<region>.amazonaws.com")
+	}
+	if (cfg.ECR.ImmutableTags || cfg.ECR.ScanOnPush) && !cfg.ECR.CreateRepository {
+		return fmt.Errorf("immutable_tags and scan_on_push apply to created repositories and require create_repository")
+	}
+	return nil
+}
+
+// ensureECRRepository creates the ECR repository of the image when
+// create_repository is set and it does not exist yet.
+func (p *DockerPlugin) ensureECRRepository(ctx context.Context, cfg *Config, outputs *Outputs) error {
+	if cfg.ECR == nil || !cfg.ECR.CreateRepository {
+		return nil
+	}
+	account, region, _ := ecrRegistry(cfg.Registry)
+	started := time.Now()
+	created, err := p.createECRRepository(ctx, cfg, account, region)
+	outputs.stage("repository", started, err)
+	outputs.RepositoryCreated = created
+	return err
+}
+
+// createECRRepository creates the repository unless describing it
+// succeeds, reporting whether it was created.
+func (p *DockerPlugin) createECRRepository(ctx context.Context, cfg *Config, account, region string) (bool, error) {
+	var stderr bytes.Buffer
+	err := p.getExecutor().RunCaptureStderr(ctx, "aws", []string{
+		"ecr", "describe-repositories", "--region", region, "--registry-id", account, "--repository-names", cfg.Image,
+	}, nil, &stderr)
+	if err == nil {
+		return false, nil
+	}
+	if !strings.Contains(stderr.String(), "RepositoryNotFoundException") {
+		return false, fmt.Errorf("failed to describe repository %s: %w: %s", cfg.Image, err, strings.TrimSpace(stderr.String()))
+	}
+
+	mutability := "MUTABLE"
+	if cfg.ECR.ImmutableTags {
+		mutability = "IMMUTABLE"
+	}
+	stderr.Reset()
+	err = p.getExecutor().RunCaptureStderr(ctx, "aws", []string{
+		"ecr", "create-repository", "--region", region, "--registry-id", account,
+		"--repository-name", cfg.Image,
+		"--image-tag-mutability", mutability,
+		"--image-scanning-configuration", fmt.Sprintf("scanOnPush=%t", cfg.ECR.ScanOnPush),
+	}, nil, &stderr)
+	// A concurrent release may have created it in the meantime.
+	if err != nil && strings.Contains(stderr.String(), "RepositoryAlreadyExistsException") {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to create repository %s: %w: %s", cfg.Image, err, strings.TrimSpace(stderr.String()))
+	}
+	return true, nil
+}
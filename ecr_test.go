@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const testECRRegistry = "123456789012.dkr.ecr.eu-west-1.amazonaws.com"
+
+func TestECRRegistry(t *testing.T) {
+	tests := []struct {
+		registry, account, region string
+		ok                        bool
+	}{
+		{testECRRegistry, "123456789012", "eu-west-1", true},
+		{"123456789012.dkr.ecr-fips.us-east-1.amazonaws.com", "123456789012", "us-east-1", true},
+		{"123456789012.dkr.ecr.cn-north-1.amazonaws.com.cn", "123456789012", "cn-north-1", true},
+		{"public.ecr.aws", "", "", false},
+		{"ghcr.io", "", "", false},
+	}
+	for _, tt := range tests {
+		account, region, ok := ecrRegistry(tt.registry)
+		if account != tt.account || region != tt.region || ok != tt.ok {
+			t.Errorf("ecrRegistry(%q) = %q, %q, %v", tt.registry, account, region, ok)
+		}
+	}
+}
+
+func TestApplyECR(t *testing.T) {
+	cfg := &Config{Registry: testECRRegistry}
+	applyECR(cfg)
+	if cfg.Username != "AWS" || cfg.TokenTTL != ecrTokenTTL {
+		t.Errorf("unexpected login: %q, ttl %q", cfg.Username, cfg.TokenTTL)
+	}
+	if !slices.Equal(cfg.PasswordCommand, []string{"aws", "ecr", "get-login-password", "--region", "eu-west-1"}) {
+		t.Errorf("unexpected password_command: %v", cfg.PasswordCommand)
+	}
+
+	static := &Config{Registry: testECRRegistry, Username: "AWS", Password: "token"}
+	applyECR(static)
+	if len(static.PasswordCommand) > 0 {
+		t.Errorf("expected static credentials to take precedence")
+	}
+	other := &Config{Registry: "ghcr.io"}
+	applyECR(other)
+	if other.Username != "" {
+		t.Errorf("expected other registries to be left unchanged")
+	}
+}
+
+func TestValidateECR(t *testing.T) {
+	if err := validateECR(&Config{Registry: testECRRegistry, ECR: &ECRConfig{CreateRepository: true, ImmutableTags: true}}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := validateECR(&Config{Registry: "ghcr.io", ECR: &ECRConfig{CreateRepository: true}}); err == nil || !strings.Contains(err.Error(), "requires an ECR registry") {
+		t.Errorf("expected a non-ECR registry to be rejected, got %v", err)
+	}
+	if err := validateECR(&Config{Registry: testECRRegistry, ECR: &ECRConfig{ScanOnPush: true}}); err == nil || !strings.Contains(err.Error(), "require create_repository") {
+		t.Errorf("expected scan_on_push without create_repository to be rejected, got %v", err)
+	}
+}
+
+func TestEnsureECRRepository(t *testing.T) {
+	cfg := &Config{Registry: testECRRegistry, Image: "myorg/myapp", ECR: &ECRConfig{CreateRepository: true, ImmutableTags: true, ScanOnPush: true}}
+
+	t.Run("missing", func(t *testing.T) {
+		mock := &MockCommandExecutor{
+			RunFunc: func(ctx context.Context, name string, args []string, stdin io.Reader) error {
+				if args[1] == "describe-repositories" {
+					return errors.New("exit status 254")
+				}
+				return nil
+			},
+			StderrFunc: func(name string, args []string) string {
+				if args[1] == "describe-repositories" {
+					return "An error occurred (RepositoryNotFoundException) when calling the DescribeRepositories operation"
+				}
+				return ""
+			},
+		}
+		outputs := &Outputs{}
+		if err := (&DockerPlugin{executor: mock}).ensureECRRepository(context.Background(), cfg, outputs); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(mock.RunCalls) != 2 || mock.RunCalls[1].Args[1] != "create-repository" {
+			t.Fatalf("expected the repository to be created, got %v", mock.RunCalls)
+		}
+		create := mock.RunCalls[1].Args
+		if !containsArg(create, "--image-tag-mutability", "IMMUTABLE") || !containsArg(create, "--image-scanning-configuration", "scanOnPush=true") || !containsArg(create, "--registry-id", "123456789012") {
+			t.Errorf("unexpected create-repository arguments: %v", create)
+		}
+		if !outputs.RepositoryCreated {
+			t.Errorf("expected repository_created to be reported")
+		}
+	})
+
+	t.Run("existing", func(t *testing.T) {
+		mock := &MockCommandExecutor{}
+		outputs := &Outputs{}
+		if err := (&DockerPlugin{executor: mock}).ensureECRRepository(context.Background(), cfg, outputs); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(mock.RunCalls) != 1 || outputs.RepositoryCreated {
+			t.Errorf("expected an existing repository to be left unchanged, got %v", mock.RunCalls)
+		}
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		mock := &MockCommandExecutor{
+			FailOnCall:  1,
+			FailWithErr: errors.New("exit status 254"),
+			StderrFunc: func(name string, args []string) string {
+				return "An error occurred (AccessDeniedException)"
+			},
+		}
+		err := (&DockerPlugin{executor: mock}).ensureECRRepository(context.Background(), cfg, &Outputs{})
+		if err == nil || !strings.Contains(err.Error(), "AccessDeniedException") {
+			t.Errorf("expected the describe failure, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// encryptedLayerMediaType is the layer media type produced by ocicrypt.
+const encryptedLayerMediaType = "application/vnd.oci.image.layer.v1.tar+gzip+encrypted"
+
+// Encryption recipient pattern: ocicrypt protocol prefix followed by a key
+// reference, e.g. jwe:keys/pub.pem, pgp:ops@example.com, provider:kms:key-id.
+var encryptionRecipientPattern = regexp.MustCompile(`^(jwe|pkcs7|pgp|provider):[^\s;|&$<>'"\x60]+$`)
+
+// encryptionRegistryHosts lists registry host suffixes known to accept
+// encrypted OCI layer media types.
+var encryptionRegistryHosts = []string{
+	"registry-1.docker.io",
+	"ghcr.io",
+	"quay.io",
+	".amazonaws.com",
+	".azurecr.io",
+	".pkg.dev",
+}
+
+// validateEncryptionRecipients validates ocicrypt recipients. Key files
+// referenced by jwe and pkcs7 recipients must stay inside the working directory.
+func validateEncryptionRecipients(recipients []string) error {
+	for _, recipient := range recipients {
+		if !encryptionRecipientPattern.MatchString(recipient) {
+			return fmt.Errorf("invalid encryption recipient %q: expected jwe:, pkcs7:, pgp: or provider: prefix", recipient)
+		}
+		protocol, key, _ := strings.Cut(recipient, ":")
+		if protocol == "jwe" || protocol == "pkcs7" {
+			if err := validatePath(key); err != nil {
+				return fmt.Errorf("invalid encryption recipient %q: %v", recipient, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validateEncryptionConfig checks that encryption is combined only with
+// settings it supports.
+func validateEncryptionConfig(cfg *Config) error {
+	if len(cfg.EncryptionRecipients) == 0 {
+		return nil
+	}
+	if err := validateEncryptionRecipients(cfg.EncryptionRecipients); err != nil {
+		return err
+	}
+	if err := validatePath(cfg.EncryptionKeyProviderConfig); err != nil {
+		return fmt.Errorf("invalid encryption keyprovider config: %v", err)
+	}
+	if useBuildx(cfg) {
+		return fmt.Errorf("image encryption is not supported with buildx builds; remove builder settings")
+	}
+	return nil
+}
+
+// encryptionRegistryWarning returns a warning when the registry is not known
+// to accept encrypted layers, or an empty string.
+func encryptionRegistryWarning(cfg *Config) string {
+	if len(cfg.EncryptionRecipients) == 0 {
+		return ""
+	}
+	host := registryHost(cfg)
+	for _, known := range encryptionRegistryHosts {
+		if host == known || (strings.HasPrefix(known, ".") && strings.HasSuffix(host, known)) {
+			return ""
+		}
+	}
+	if cfg.RegistryType == registryTypeHarbor {
+		return ""
+	}
+	return fmt.Sprintf("registry %s is not known to accept %s layers; verify it supports OCI encrypted images", host, encryptedLayerMediaType)
+}
+
+// pushEncrypted copies ref from the local daemon to the registry with
+// skopeo, encrypting every layer for the configured recipients.
+func (p *DockerPlugin) pushEncrypted(ctx context.Context, cfg *Config, ref string) error {
+	name, args := "skopeo", []string{"copy"}
+	for _, recipient := range cfg.EncryptionRecipients {
+		args = append(args, "--encryption-key", recipient)
+	}
+	args = append(args, "docker-daemon:"+ref, "docker://"+ref)
+
+	if cfg.EncryptionKeyProviderConfig != "" {
+		name, args = "env", append([]string{"OCICRYPT_KEYPROVIDER_CONFIG=" + cfg.EncryptionKeyProviderConfig, name}, args...)
+	}
+
+	return p.getExecutor().Run(ctx, name, args, nil)
+}
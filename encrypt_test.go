@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestValidateEncryptionRecipients(t *testing.T) {
+	tests := []struct {
+		name       string
+		recipients []string
+		wantErr    bool
+	}{
+		{"jwe key", []string{"jwe:keys/pub.pem"}, false},
+		{"pgp and kms", []string{"pgp:ops@example.com", "provider:aws-kms:alias/release"}, false},
+		{"unknown protocol", []string{"rsa:pub.pem"}, true},
+		{"absolute key path", []string{"jwe:/etc/keys/pub.pem"}, true},
+		{"traversal", []string{"pkcs7:../cert.pem"}, true},
+		{"shell metacharacters", []string{"pgp:ops;rm"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateEncryptionRecipients(tt.recipients)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateEncryptionRecipients(%v) error = %v, wantErr %v", tt.recipients, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateEncryptionConfigRejectsBuildx(t *testing.T) {
+	cfg := &Config{EncryptionRecipients: []string{"jwe:pub.pem"}, Builder: "release"}
+	if err := validateEncryptionConfig(cfg); err == nil || !strings.Contains(err.Error(), "buildx") {
+		t.Errorf("expected buildx error, got %v", err)
+	}
+}
+
+func TestEncryptionRegistryWarning(t *testing.T) {
+	tests := []struct {
+		registry     string
+		registryType string
+		wantWarning  bool
+	}{
+		{"docker.io", "", false},
+		{"ghcr.io", "", false},
+		{"123456789012.dkr.ecr.us-east-1.amazonaws.com", "", false},
+		{"myregistry.azurecr.io", "", false},
+		{"harbor.internal", registryTypeHarbor, false},
+		{"registry.internal:5000", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.registry, func(t *testing.T) {
+			cfg := &Config{Registry: tt.registry, RegistryType: tt.registryType, EncryptionRecipients: []string{"jwe:pub.pem"}}
+			if got := encryptionRegistryWarning(cfg) != ""; got != tt.wantWarning {
+				t.Errorf("expected warning=%v, got %q", tt.wantWarning, encryptionRegistryWarning(cfg))
+			}
+		})
+	}
+}
+
+func TestEncryptedPush(t *testing.T) {
+	ctx := context.Background()
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(ctx, plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":                         "myorg/internal",
+			"registry":                      "ghcr.io",
+			"tags":                          []any{"{{version}}"},
+			"encryption_recipients":         []any{"jwe:keys/pub.pem", "provider:vault:release"},
+			"encryption_keyprovider_config": "ocicrypt.json",
+		},
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+
+	push := mock.RunCalls[len(mock.RunCalls)-1]
+	expected := "OCICRYPT_KEYPROVIDER_CONFIG=ocicrypt.json skopeo copy --encryption-key jwe:keys/pub.pem --encryption-key provider:vault:release " +
+		"docker-daemon:ghcr.io/myorg/internal:1.0.0 docker://ghcr.io/myorg/internal:1.0.0"
+	if push.Name != "env" || strings.Join(push.Args, " ") != expected {
+		t.Errorf("unexpected encrypted push: %s %v", push.Name, push.Args)
+	}
+	for _, call := range mock.RunCalls {
+		if call.Name == "docker" && call.Args[0] == "push" {
+			t.Error("expected no plain docker push when encryption is enabled")
+		}
+	}
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Container engines of the engine option.
+const (
+	engineDocker = "docker"
+	enginePodman = "podman"
+	engineAuto   = "auto"
+)
+
+// validateEngine checks engine and the options podman cannot honour.
+func validateEngine(cfg *Config) error {
+	switch cfg.Engine {
+	case "", engineDocker, enginePodman, engineAuto:
+	default:
+		return fmt.Errorf("engine must be %s, %s or %s", engineDocker, enginePodman, engineAuto)
+	}
+	if cfg.Engine != enginePodman {
+		return nil
+	}
+	switch {
+	case len(cfg.BuilderNodes) > 0:
+		return fmt.Errorf("builder_nodes requires docker buildx, which podman does not provide")
+	case cfg.Builder != "":
+		return fmt.Errorf("builder requires docker buildx, which podman does not provide")
+	case len(cfg.IndexSources) > 0:
+		return fmt.Errorf("index_sources requires docker buildx, which podman does not provide")
+	}
+	return nil
+}
+
+// resolveEngine returns the engine the plugin runs. auto prefers docker and
+// falls back to podman when only podman is installed.
+func resolveEngine(engine string) string {
+	if engine != engineAuto {
+		if engine == "" {
+			return engineDocker
+		}
+		return engine
+	}
+	if _, err := lookPath("docker"); err == nil {
+		return engineDocker
+	}
+	if _, err := lookPath("podman"); err == nil {
+		return enginePodman
+	}
+	return engineDocker
+}
+
+// withPodman returns a copy of the plugin running its commands with podman.
+func (p *DockerPlugin) withPodman(cfg *Config) *DockerPlugin {
+	engine := *p
+	engine.executor = &podmanExecutor{next: p.getExecutor(), insecure: cfg.Insecure}
+	return &engine
+}
+
+// wrapperTools are the commands wrapCommand prefixes docker with.
+var wrapperTools = []string{"env", "ionice", "nice", "prlimit", "systemd-run", "taskset"}
+
+// engineCommand is one command of a translated docker command.
+type engineCommand struct {
+	name string
+	args []string
+	// optional commands may fail without failing the translated command.
+	optional bool
+}
+
+// podmanExecutor runs the docker commands of the plugin with podman. Most
+// commands take the same arguments; buildx imagetools, which talks to the
+// registry, is replaced by skopeo, and multi-platform builds produce a
+// podman manifest list that is pushed with podman manifest push.
+type podmanExecutor struct {
+	next CommandExecutor
+	// insecure skips TLS verification of skopeo registry requests.
+	insecure bool
+
+	mu sync.Mutex
+	// manifests holds the references built as manifest lists.
+	manifests map[string]bool
+}
+
+// translate returns the commands running name and args with podman. Docker
+// commands prefixed by a wrapper tool are translated after the wrapper.
+func (e *podmanExecutor) translate(name string, args []string) ([]engineCommand, error) {
+	var prefix []string
+	switch {
+	case name == "docker":
+	case slices.Contains(wrapperTools, name) && slices.Contains(args, "docker"):
+		i := slices.Index(args, "docker")
+		prefix = append([]string{name}, args[:i]...)
+		args = args[i+1:]
+	case name == "skopeo":
+		return []engineCommand{{name: name, args: skopeoStorageArgs(args)}}, nil
+	case slices.Contains(wrapperTools, name) && slices.Contains(args, "skopeo"):
+		return []engineCommand{{name: name, args: skopeoStorageArgs(args)}}, nil
+	default:
+		return []engineCommand{{name: name, args: args}}, nil
+	}
+
+	cmds, err := e.podmanCommands(args)
+	if err != nil {
+		return nil, err
+	}
+	if len(prefix) > 0 {
+		for i := range cmds {
+			if cmds[i].name == enginePodman {
+				cmds[i].args = append(append(slices.Clone(prefix[1:]), enginePodman), cmds[i].args...)
+				cmds[i].name = prefix[0]
+			}
+		}
+	}
+	return cmds, nil
+}
+
+// skopeoStorageArgs points skopeo at the podman image store instead of the
+// docker daemon.
+func skopeoStorageArgs(args []string) []string {
+	translated := make([]string, len(args))
+	for i, arg := range args {
+		if ref, ok := strings.CutPrefix(arg, "docker-daemon:"); ok {
+			arg = "containers-storage:" + ref
+		}
+		translated[i] = arg
+	}
+	return translated
+}
+
+// podmanCommands translates the arguments of one docker command.
+func (e *podmanExecutor) podmanCommands(args []string) ([]engineCommand, error) {
+	if len(args) == 0 {
+		return []engineCommand{{name: enginePodman}}, nil
+	}
+	switch args[0] {
+	case "buildx":
+		if len(args) > 2 && args[1] == "imagetools" {
+			return e.imagetoolsCommands(args[2:])
+		}
+		return nil, fmt.Errorf("podman does not provide docker buildx (%s)", strings.Join(args, " "))
+	case "build":
+		return e.buildCommands(args), nil
+	case "push":
+		ref := args[len(args)-1]
+		if e.isManifest(ref) {
+			return []engineCommand{{name: enginePodman, args: []string{"manifest", "push", "--all", ref, "docker://" + ref}}}, nil
+		}
+	case "version":
+		translated := make([]string, len(args))
+		for i, arg := range args {
+			arg = strings.ReplaceAll(arg, "{{.Server.Os}}/{{.Server.Arch}}", "{{.Client.OsArch}}")
+			translated[i] = strings.ReplaceAll(arg, "{{.Server.", "{{.Client.")
+		}
+		return []engineCommand{{name: enginePodman, args: translated}}, nil
+	case "info":
+		if slices.ContainsFunc(args, func(arg string) bool { return strings.Contains(arg, ".RegistryConfig") }) {
+			return nil, fmt.Errorf("podman info does not report the docker registry configuration")
+		}
+	case "trust":
+		return nil, fmt.Errorf("podman does not support docker content trust")
+	}
+	return []engineCommand{{name: enginePodman, args: args}}, nil
+}
+
+// imagetoolsCommands replaces docker buildx imagetools with skopeo.
+func (e *podmanExecutor) imagetoolsCommands(args []string) ([]engineCommand, error) {
+	skopeo := func(args ...string) engineCommand {
+		if e.insecure {
+			args = append([]string{args[0], "--tls-verify=false"}, args[1:]...)
+		}
+		return engineCommand{name: "skopeo", args: args}
+	}
+
+	switch args[0] {
+	case "inspect":
+		ref := args[len(args)-1]
+		format := ""
+		if i := slices.Index(args, "--format"); i >= 0 && i+1 < len(args) {
+			format = args[i+1]
+		}
+		switch format {
+		case "":
+			return []engineCommand{skopeo("inspect", "--raw", "docker://"+ref)}, nil
+		case "{{json .Image}}":
+			return []engineCommand{skopeo("inspect", "--config", "docker://"+ref)}, nil
+		}
+		return nil, fmt.Errorf("imagetools inspect --format %s is not supported with podman", format)
+	case "create":
+		var tags, sources []string
+		for i := 1; i < len(args); i++ {
+			if args[i] == "--tag" && i+1 < len(args) {
+				tags = append(tags, args[i+1])
+				i++
+				continue
+			}
+			sources = append(sources, args[i])
+		}
+		if len(sources) != 1 {
+			return nil, fmt.Errorf("assembling an index from several sources is not supported with podman")
+		}
+		cmds := make([]engineCommand, 0, len(tags))
+		for _, tag := range tags {
+			cmds = append(cmds, skopeo("copy", "--all", "docker://"+sources[0], "docker://"+tag))
+		}
+		return cmds, nil
+	}
+	return nil, fmt.Errorf("imagetools %s is not supported with podman", args[0])
+}
+
+// buildCommands translates a build. A build for several platforms produces
+// a manifest list under the first tag instead of an image; it is recreated
+// because podman would add to a list left by an earlier build, and the
+// other tags are added to the list.
+func (e *podmanExecutor) buildCommands(args []string) []engineCommand {
+	i := slices.Index(args, "--platform")
+	if i < 0 || i+1 >= len(args) || !strings.Contains(args[i+1], ",") {
+		return []engineCommand{{name: enginePodman, args: args}}
+	}
+
+	var tags []string
+	build := []string{"build"}
+	for i := 1; i < len(args); i++ {
+		if args[i] == "-t" && i+1 < len(args) {
+			tags = append(tags, args[i+1])
+			i++
+			continue
+		}
+		build = append(build, args[i])
+	}
+	if len(tags) == 0 {
+		return []engineCommand{{name: enginePodman, args: args}}
+	}
+	build = append([]string{"build", "--manifest", tags[0]}, build[1:]...)
+
+	cmds := []engineCommand{
+		{name: enginePodman, args: []string{"manifest", "rm", tags[0]}, optional: true},
+		{name: enginePodman, args: build},
+	}
+	for _, tag := range tags[1:] {
+		cmds = append(cmds, engineCommand{name: enginePodman, args: []string{"tag", tags[0], tag}})
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.manifests == nil {
+		e.manifests = make(map[string]bool)
+	}
+	for _, tag := range tags {
+		e.manifests[tag] = true
+	}
+	return cmds
+}
+
+// isManifest reports whether ref was built as a manifest list.
+func (e *podmanExecutor) isManifest(ref string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.manifests[ref]
+}
+
+// runAll runs the translated commands with run, stopping at the first
+// failure of a command that is not optional.
+func runAll(cmds []engineCommand, run func(engineCommand) error) error {
+	for _, cmd := range cmds {
+		if err := run(cmd); err != nil && !cmd.optional {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run executes the translated command.
+func (e *podmanExecutor) Run(ctx context.Context, name string, args []string, stdin io.Reader) error {
+	cmds, err := e.translate(name, args)
+	if err != nil {
+		return err
+	}
+	return runAll(cmds, func(cmd engineCommand) error {
+		return e.next.Run(ctx, cmd.name, cmd.args, stdin)
+	})
+}
+
+// RunCapture executes the translated command.
+func (e *podmanExecutor) RunCapture(ctx context.Context, name string, args []string, stdin io.Reader, stdout io.Writer) error {
+	cmds, err := e.translate(name, args)
+	if err != nil {
+		return err
+	}
+	return runAll(cmds, func(cmd engineCommand) error {
+		return e.next.RunCapture(ctx, cmd.name, cmd.args, stdin, stdout)
+	})
+}
+
+// RunCaptureStderr executes the translated command.
+func (e *podmanExecutor) RunCaptureStderr(ctx context.Context, name string, args []string, stdin io.Reader, stderr io.Writer) error {
+	cmds, err := e.translate(name, args)
+	if err != nil {
+		return err
+	}
+	return runAll(cmds, func(cmd engineCommand) error {
+		return e.next.RunCaptureStderr(ctx, cmd.name, cmd.args, stdin, stderr)
+	})
+}
+
+// Output executes the translated command and returns the output of its
+// last command. A registry digest lookup hashes the raw manifest, as skopeo
+// does not report the digest of a manifest list.
+func (e *podmanExecutor) Output(ctx context.Context, name string, args []string) ([]byte, error) {
+	if name == "docker" && len(args) > 4 && args[0] == "buildx" && args[1] == "imagetools" && args[2] == "inspect" && slices.Contains(args, "{{.Manifest.Digest}}") {
+		raw, err := e.Output(ctx, "docker", []string{"buildx", "imagetools", "inspect", "--raw", args[len(args)-1]})
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(raw)
+		return []byte("sha256:" + hex.EncodeToString(sum[:]) + "\n"), nil
+	}
+
+	cmds, err := e.translate(name, args)
+	if err != nil {
+		return nil, err
+	}
+	var out []byte
+	err = runAll(cmds, func(cmd engineCommand) error {
+		var err error
+		out, err = e.next.Output(ctx, cmd.name, cmd.args)
+		return err
+	})
+	return out, err
+}
+
+// OutputInput executes the translated command and returns the output of
+// its last command.
+func (e *podmanExecutor) OutputInput(ctx context.Context, name string, args []string, stdin io.Reader) ([]byte, error) {
+	cmds, err := e.translate(name, args)
+	if err != nil {
+		return nil, err
+	}
+	var out []byte
+	err = runAll(cmds, func(cmd engineCommand) error {
+		var err error
+		out, err = e.next.OutputInput(ctx, cmd.name, cmd.args, stdin)
+		return err
+	})
+	return out, err
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestResolveEngine(t *testing.T) {
+	stubLookPath(t, "podman")
+	if got := resolveEngine(engineAuto); got != enginePodman {
+		t.Errorf("expected podman when only podman is installed, got %s", got)
+	}
+	stubLookPath(t, "docker", "podman")
+	if got := resolveEngine(engineAuto); got != engineDocker {
+		t.Errorf("expected docker to be preferred, got %s", got)
+	}
+	stubLookPath(t)
+	if got := resolveEngine(engineAuto); got != engineDocker {
+		t.Errorf("expected docker without any engine installed, got %s", got)
+	}
+	if got := resolveEngine(enginePodman); got != enginePodman {
+		t.Errorf("expected the configured engine, got %s", got)
+	}
+}
+
+func TestValidateEngine(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *Config
+		wantErr string
+	}{
+		{"docker", &Config{Engine: engineDocker, Builder: "ci"}, ""},
+		{"podman", &Config{Engine: enginePodman}, ""},
+		{"unknown", &Config{Engine: "containerd"}, "engine must be"},
+		{"podman builder", &Config{Engine: enginePodman, Builder: "ci"}, "builder requires docker buildx"},
+		{"podman index", &Config{Engine: enginePodman, IndexSources: []string{"a", "b"}}, "index_sources requires docker buildx"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateEngine(tt.cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPodmanTranslate(t *testing.T) {
+	e := &podmanExecutor{}
+	tests := []struct {
+		name     string
+		args     []string
+		expected []string
+	}{
+		{"docker", []string{"login", "ghcr.io", "-u", "user", "--password-stdin"}, []string{"podman login ghcr.io -u user --password-stdin"}},
+		{"docker", []string{"buildx", "imagetools", "inspect", "--raw", "myapp:1"}, []string{"skopeo inspect --raw docker://myapp:1"}},
+		{"docker", []string{"buildx", "imagetools", "inspect", "--format", "{{json .Image}}", "myapp:1"}, []string{"skopeo inspect --config docker://myapp:1"}},
+		{"docker", []string{"buildx", "imagetools", "create", "--tag", "myapp:latest", "--tag", "myapp:1", "myapp:1.0.0"}, []string{
+			"skopeo copy --all docker://myapp:1.0.0 docker://myapp:latest",
+			"skopeo copy --all docker://myapp:1.0.0 docker://myapp:1",
+		}},
+		{"docker", []string{"version", "--format", "{{.Server.Os}}/{{.Server.Arch}}"}, []string{"podman version --format {{.Client.OsArch}}"}},
+		{"nice", []string{"-n", "19", "docker", "push", "myapp:1"}, []string{"nice -n 19 podman push myapp:1"}},
+		{"skopeo", []string{"copy", "docker-daemon:myapp:1", "docker://myapp:1"}, []string{"skopeo copy containers-storage:myapp:1 docker://myapp:1"}},
+		{"git", []string{"status"}, []string{"git status"}},
+	}
+	for _, tt := range tests {
+		cmds, err := e.translate(tt.name, tt.args)
+		if err != nil {
+			t.Fatalf("%s %v: unexpected error: %v", tt.name, tt.args, err)
+		}
+		var got []string
+		for _, cmd := range cmds {
+			got = append(got, strings.Join(append([]string{cmd.name}, cmd.args...), " "))
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("%s %v: expected %q, got %q", tt.name, tt.args, tt.expected, got)
+		}
+	}
+
+	if _, err := e.translate("docker", []string{"buildx", "inspect", "ci"}); err == nil {
+		t.Error("expected buildx commands to be rejected")
+	}
+}
+
+func TestPodmanResolveDigest(t *testing.T) {
+	raw := `{"schemaVersion":2,"manifests":[]}`
+	mock := &MockCommandExecutor{
+		OutputFunc: func(_ context.Context, name string, args []string) ([]byte, error) {
+			if name == "skopeo" && strings.Join(args, " ") == "inspect --raw docker://myapp:1" {
+				return []byte(raw), nil
+			}
+			return nil, nil
+		},
+	}
+	p := (&DockerPlugin{executor: mock}).withPodman(&Config{})
+
+	digest, err := p.resolveDigest(context.Background(), "myapp:1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sum := sha256.Sum256([]byte(raw))
+	if digest != "sha256:"+hex.EncodeToString(sum[:]) {
+		t.Errorf("expected the digest of the raw manifest, got %s", digest)
+	}
+}
+
+func TestExecutePodmanMultiPlatform(t *testing.T) {
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":     "myapp",
+			"engine":    "podman",
+			"tags":      []any{"1.0.0", "latest"},
+			"platforms": []any{"linux/amd64", "linux/arm64"},
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+
+	var calls []string
+	for _, call := range mock.RunCalls {
+		if call.Name != "podman" {
+			t.Errorf("expected only podman commands, got %s %v", call.Name, call.Args)
+		}
+		calls = append(calls, strings.Join(call.Args[:min(len(call.Args), 3)], " "))
+	}
+	expected := []string{
+		"manifest rm myapp:1.0.0",
+		"build --manifest myapp:1.0.0",
+		"tag myapp:1.0.0 myapp:latest",
+		"manifest push --all",
+		"manifest push --all",
+	}
+	if strings.Join(calls, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected podman commands:\n%s", strings.Join(calls, "\n"))
+	}
+	if last := mock.RunCalls[len(mock.RunCalls)-1].Args; last[len(last)-1] != "docker://myapp:latest" {
+		t.Errorf("expected the latest tag to be pushed, got %v", last)
+	}
+}
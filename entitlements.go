@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Buildx entitlements a build may be granted. Both are denied unless listed
+// in entitlements.
+const (
+	entitlementNetworkHost      = "network.host"
+	entitlementSecurityInsecure = "security.insecure"
+)
+
+var knownEntitlements = []string{entitlementNetworkHost, entitlementSecurityInsecure}
+
+// validateEntitlements checks the requested entitlements. They are passed to
+// buildx as --allow, so they require a buildx builder.
+func validateEntitlements(cfg *Config) error {
+	for _, entitlement := range cfg.Entitlements {
+		if !slices.Contains(knownEntitlements, entitlement) {
+			return fmt.Errorf("unknown entitlement %q: expected one of %s", entitlement, strings.Join(knownEntitlements, ", "))
+		}
+	}
+	if len(cfg.Entitlements) > 0 && !useBuildx(cfg) {
+		return fmt.Errorf("entitlements require a buildx builder; set builder")
+	}
+	return nil
+}
+
+// entitlementArgs returns the --allow flags for the requested entitlements.
+func entitlementArgs(cfg *Config) []string {
+	var args []string
+	for _, entitlement := range cfg.Entitlements {
+		args = append(args, "--allow", entitlement)
+	}
+	return args
+}
+
+// entitlementBuildkitdFlags returns the buildkitd flags that let nodes created
+// from builder_nodes grant the requested entitlements. Remote nodes run a
+// buildkitd started elsewhere, which must be configured by its owner.
+func entitlementBuildkitdFlags(cfg *Config) string {
+	if cfg.BuilderDriver == "remote" {
+		return ""
+	}
+	flags := make([]string, 0, len(cfg.Entitlements))
+	for _, entitlement := range cfg.Entitlements {
+		flags = append(flags, "--allow-insecure-entitlement "+entitlement)
+	}
+	return strings.Join(flags, " ")
+}
+
+// entitlementWarning reports the entitlements granted to the build, so they
+// show up in release logs as well as in config review.
+func entitlementWarning(cfg *Config) string {
+	if len(cfg.Entitlements) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("build is granted entitlements %s", strings.Join(cfg.Entitlements, ", "))
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestValidateEntitlements(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *Config
+		wantErr bool
+	}{
+		{"none", &Config{}, false},
+		{"network host", &Config{Builder: "release", Entitlements: []string{"network.host"}}, false},
+		{"both", &Config{Builder: "release", Entitlements: []string{"network.host", "security.insecure"}}, false},
+		{"unknown", &Config{Builder: "release", Entitlements: []string{"device"}}, true},
+		{"classic build", &Config{Entitlements: []string{"network.host"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateEntitlements(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateEntitlements() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEnsureBuilderEntitlements(t *testing.T) {
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock}
+	cfg := &Config{
+		Builder:      "release",
+		BuilderNodes: []BuilderNode{{Endpoint: "ssh://amd64", Platforms: []string{"linux/amd64"}}},
+		Entitlements: []string{"network.host"},
+	}
+
+	if err := p.ensureBuilder(context.Background(), cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !containsArg(mock.RunCalls[0].Args, "--buildkitd-flags", "--allow-insecure-entitlement network.host") {
+		t.Errorf("expected buildkitd entitlement flags, got %v", mock.RunCalls[0].Args)
+	}
+
+	mock = &MockCommandExecutor{}
+	p = &DockerPlugin{executor: mock}
+	cfg.BuilderDriver = "remote"
+	if err := p.ensureBuilder(context.Background(), cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if containsFlag(mock.RunCalls[0].Args, "--buildkitd-flags") {
+		t.Errorf("remote nodes should not get buildkitd flags, got %v", mock.RunCalls[0].Args)
+	}
+}
+
+func TestExecuteEntitlements(t *testing.T) {
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":        "myapp",
+			"builder":      "release",
+			"entitlements": []any{"network.host"},
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+
+	var build []string
+	for _, call := range mock.RunCalls {
+		if len(call.Args) > 1 && call.Args[0] == "buildx" && call.Args[1] == "build" {
+			build = call.Args
+		}
+	}
+	if !containsArg(build, "--allow", "network.host") {
+		t.Errorf("expected --allow network.host, got %v", build)
+	}
+	warnings, _ := resp.Outputs["warnings"].([]string)
+	if len(warnings) == 0 || !strings.Contains(warnings[len(warnings)-1], "network.host") {
+		t.Errorf("expected entitlement warning, got %v", warnings)
+	}
+}
+
+func TestExecuteEntitlementsRequireBuildx(t *testing.T) {
+	p := &DockerPlugin{executor: &MockCommandExecutor{}}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":        "myapp",
+			"entitlements": []any{"security.insecure"},
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success || !strings.Contains(resp.Error, "invalid entitlements") {
+		t.Errorf("expected entitlements failure, got %+v", resp)
+	}
+}
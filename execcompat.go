@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// defaultExecCompatTimeout bounds each exec_compat script.
+const defaultExecCompatTimeout = 10 * time.Minute
+
+// Points of the release at which exec_compat scripts run.
+const (
+	execPreBuild = "pre_build"
+	execPostPush = "post_push"
+)
+
+// execCompatVars are the variables set for exec_compat scripts, and
+// replaced as ${NAME} in their arguments.
+var execCompatVars = []string{"RESOLVED_TAGS", "IMAGE", "REGISTRY", "VERSION", "DIGEST"}
+
+// ExecCompat runs the scripts of a release script being migrated to the
+// plugin, so its steps move to configuration one at a time.
+type ExecCompat struct {
+	// PreBuild runs before the image is built.
+	PreBuild []string
+	// PostPush runs once the release is pushed.
+	PostPush []string
+	Timeout  string
+}
+
+// parseExecCompat reads the exec_compat option, returning nil when it is not
+// set.
+func parseExecCompat(raw map[string]any) *ExecCompat {
+	m, ok := raw["exec_compat"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	compat := &ExecCompat{}
+	for key, command := range map[string]*[]string{execPreBuild: &compat.PreBuild, execPostPush: &compat.PostPush} {
+		if args, ok := m[key].([]any); ok {
+			for _, arg := range args {
+				if s, ok := arg.(string); ok {
+					*command = append(*command, s)
+				}
+			}
+		}
+	}
+	compat.Timeout, _ = m["timeout"].(string)
+	return compat
+}
+
+// validateExecCompat checks the exec_compat settings. At least one script is
+// required, and each must start with an executable.
+func validateExecCompat(cfg *Config) error {
+	compat := cfg.ExecCompat
+	if compat == nil {
+		return nil
+	}
+	if len(compat.PreBuild) == 0 && len(compat.PostPush) == 0 {
+		return fmt.Errorf("set pre_build, post_push or both")
+	}
+	if len(compat.PreBuild) > 0 && strings.TrimSpace(compat.PreBuild[0]) == "" {
+		return fmt.Errorf("pre_build must start with an executable")
+	}
+	if len(compat.PostPush) > 0 && strings.TrimSpace(compat.PostPush[0]) == "" {
+		return fmt.Errorf("post_push must start with an executable")
+	}
+	if compat.Timeout != "" {
+		if d, err := time.ParseDuration(compat.Timeout); err != nil || d <= 0 {
+			return fmt.Errorf("timeout must be a positive duration such as 5m")
+		}
+	}
+	return nil
+}
+
+// execCompatEnv returns the variables of the scripts of a release: the
+// resolved tags separated by spaces, the image, registry and version, and
+// the digest once pushed.
+func execCompatEnv(cfg *Config, version string, outputs *Outputs) map[string]string {
+	return map[string]string{
+		"RESOLVED_TAGS": strings.Join(outputs.Tags, " "),
+		"IMAGE":         cfg.Image,
+		"REGISTRY":      cfg.Registry,
+		"VERSION":       version,
+		"DIGEST":        outputs.Digest,
+	}
+}
+
+// execCompatStage runs the exec_compat script of point, if any. A failing
+// script fails the release, as it would have failed the release script.
+func (p *DockerPlugin) execCompatStage(ctx context.Context, cfg *Config, point, version string, outputs *Outputs) *plugin.ExecuteResponse {
+	if cfg.ExecCompat == nil {
+		return nil
+	}
+	command := cfg.ExecCompat.PreBuild
+	if point == execPostPush {
+		command = cfg.ExecCompat.PostPush
+	}
+	if len(command) == 0 {
+		return nil
+	}
+
+	vars := execCompatEnv(cfg, version, outputs)
+	env := []string{"env"}
+	placeholders := make([]string, 0, 2*len(vars))
+	for _, name := range execCompatVars {
+		env = append(env, name+"="+vars[name])
+		placeholders = append(placeholders, "${"+name+"}", vars[name])
+	}
+	replacer := strings.NewReplacer(placeholders...)
+	args := make([]string, len(command)-1)
+	for i, arg := range command[1:] {
+		args[i] = replacer.Replace(arg)
+	}
+
+	timeout := defaultExecCompatTimeout
+	if d, err := time.ParseDuration(cfg.ExecCompat.Timeout); err == nil && d > 0 {
+		timeout = d
+	}
+	scriptCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	started := time.Now()
+	name, args := wrapCommand(command[0], args, env)
+	err := p.getExecutor().Run(scriptCtx, name, args, nil)
+	outputs.stage(point, started, err)
+	if err != nil {
+		return outputs.response(false, "", fmt.Sprintf("%s script %s failed: %v", point, command[0], err))
+	}
+	return nil
+}
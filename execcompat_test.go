@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestValidateExecCompat(t *testing.T) {
+	tests := []struct {
+		name    string
+		compat  *ExecCompat
+		wantErr bool
+	}{
+		{"unset", nil, false},
+		{"both", &ExecCompat{PreBuild: []string{"./pre.sh"}, PostPush: []string{"./post.sh"}, Timeout: "5m"}, false},
+		{"no scripts", &ExecCompat{Timeout: "5m"}, true},
+		{"empty executable", &ExecCompat{PostPush: []string{" ", "x"}}, true},
+		{"invalid timeout", &ExecCompat{PreBuild: []string{"./pre.sh"}, Timeout: "soon"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateExecCompat(&Config{ExecCompat: tt.compat})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateExecCompat() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExecuteExecCompat(t *testing.T) {
+	mock := &MockCommandExecutor{
+		StdoutFunc: func(_ string, args []string) string {
+			if args[0] == "push" {
+				return samplePushOutput
+			}
+			return ""
+		},
+		OutputFunc: func(context.Context, string, []string) ([]byte, error) { return []byte("[]"), nil },
+	}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":    "myorg/myapp",
+			"registry": "ghcr.io",
+			"tags":     []any{"{{version}}", "latest"},
+			"exec_compat": map[string]any{
+				"pre_build": []any{"./scripts/pre-build.sh"},
+				"post_push": []any{"./scripts/post-push.sh", "--digest=${DIGEST}"},
+			},
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+
+	var order []string
+	var post string
+	for _, call := range mock.RunCalls {
+		switch {
+		case call.Name == "env":
+			order = append(order, call.Args[len(call.Args)-1])
+			if strings.Contains(strings.Join(call.Args, " "), "post-push.sh") {
+				post = strings.Join(call.Args, " ")
+			}
+		case call.Args[0] == "build" || call.Args[0] == "push":
+			order = append(order, call.Args[0])
+		}
+	}
+	if want := "./scripts/pre-build.sh build push push --digest=" + testDigest; strings.Join(order, " ") != want {
+		t.Errorf("unexpected commands: %v", order)
+	}
+	want := "RESOLVED_TAGS=1.0.0 latest IMAGE=myorg/myapp REGISTRY=ghcr.io VERSION=1.0.0 DIGEST=" + testDigest +
+		" ./scripts/post-push.sh --digest=" + testDigest
+	if post != want {
+		t.Errorf("unexpected post_push command:\n got %s\nwant %s", post, want)
+	}
+}
+
+func TestExecuteExecCompatFailure(t *testing.T) {
+	mock := &MockCommandExecutor{
+		RunFunc: func(_ context.Context, name string, args []string, _ io.Reader) error {
+			if name == "env" {
+				return errors.New("exit status 3")
+			}
+			return nil
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":       "myapp",
+			"exec_compat": map[string]any{"pre_build": []any{"./pre.sh"}},
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success || !strings.Contains(resp.Error, "pre_build script ./pre.sh failed") {
+		t.Fatalf("expected the pre_build failure, got %+v", resp)
+	}
+	if len(mock.RunCalls) != 1 {
+		t.Errorf("expected nothing built after the failed script, got %v", mock.RunCalls)
+	}
+}
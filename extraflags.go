@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// extraFlagPattern matches the flags extra_build_flags and extra_push_flags
+// may pass: one long flag, with its value joined by "=". Values are limited
+// to characters without meaning to a shell or to docker reference parsing.
+var extraFlagPattern = regexp.MustCompile(`^--[a-z][a-z0-9-]*(=[A-Za-z0-9_.,:/=@%+-]*)?$`)
+
+// managedBuildFlags are the build flags the plugin sets itself, with the
+// option setting each.
+var managedBuildFlags = map[string]string{
+	"tag":           "tags",
+	"file":          "dockerfile",
+	"push":          "push",
+	"load":          "load",
+	"output":        "oci_tarball",
+	"builder":       "builder",
+	"metadata-file": "",
+	"allow":         "entitlements",
+	"platform":      "platforms",
+	"target":        "target",
+	"build-arg":     "build_args",
+	"label":         "labels",
+	"secret":        "secrets",
+	"cache-from":    "cache_from",
+	"no-cache":      "no_cache",
+	"cgroup-parent": "cgroup_slice",
+	"ulimit":        "ulimits",
+	"progress":      "debug",
+}
+
+// managedPushFlags are the push flags that would break the pushes of the
+// plugin: it pushes the release tags one by one and reads their progress.
+var managedPushFlags = map[string]string{
+	"all-tags": "tags",
+	"quiet":    "",
+}
+
+// validateExtraFlags checks extra_build_flags and extra_push_flags.
+func validateExtraFlags(cfg *Config) error {
+	if err := checkExtraFlags("extra_build_flags", cfg.ExtraBuildFlags, managedBuildFlags); err != nil {
+		return err
+	}
+	if len(cfg.ExtraPushFlags) > 0 {
+		switch {
+		case cfg.Daemonless:
+			return fmt.Errorf("extra_push_flags cannot be combined with daemonless, which does not run docker push")
+		case len(cfg.EncryptionRecipients) > 0:
+			return fmt.Errorf("extra_push_flags cannot be combined with encryption_recipients, which does not run docker push")
+		}
+	}
+	return checkExtraFlags("extra_push_flags", cfg.ExtraPushFlags, managedPushFlags)
+}
+
+func checkExtraFlags(option string, flags []string, managed map[string]string) error {
+	for _, flag := range flags {
+		if !extraFlagPattern.MatchString(flag) {
+			return fmt.Errorf("%s: invalid flag %q: use the --name or --name=value form, without spaces or shell characters", option, flag)
+		}
+		name, _, _ := strings.Cut(strings.TrimPrefix(flag, "--"), "=")
+		if replacement, ok := managed[name]; ok {
+			if replacement == "" {
+				return fmt.Errorf("%s: --%s is set by the plugin", option, name)
+			}
+			return fmt.Errorf("%s: --%s is set by the plugin, use %s", option, name, replacement)
+		}
+	}
+	return nil
+}
+
+// extraPushFlagsWarning explains when extra_push_flags are not applied:
+// buildx builds push the release themselves, without docker push.
+func extraPushFlagsWarning(cfg *Config) string {
+	if len(cfg.ExtraPushFlags) == 0 || !useBuildx(cfg) || !cfg.Push {
+		return ""
+	}
+	return "extra_push_flags are not applied: buildx builds push without docker push"
+}
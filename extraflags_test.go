@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestValidateExtraFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *Config
+		wantErr string
+	}{
+		{"none", &Config{}, ""},
+		{"flags", &Config{ExtraBuildFlags: []string{"--sbom=true", "--provenance=mode=max", "--pull"}, ExtraPushFlags: []string{"--disable-content-trust"}}, ""},
+		{"short flag", &Config{ExtraBuildFlags: []string{"-q"}}, "--name or --name=value"},
+		{"separate value", &Config{ExtraBuildFlags: []string{"--network host"}}, "without spaces"},
+		{"shell", &Config{ExtraBuildFlags: []string{"--iidfile=$(id)"}}, "shell characters"},
+		{"command separator", &Config{ExtraPushFlags: []string{"--quiet;reboot"}}, "invalid flag"},
+		{"managed build flag", &Config{ExtraBuildFlags: []string{"--tag=evil/image"}}, "use tags"},
+		{"managed build flag without option", &Config{ExtraBuildFlags: []string{"--metadata-file=out.json"}}, "set by the plugin"},
+		{"managed push flag", &Config{ExtraPushFlags: []string{"--all-tags"}}, "use tags"},
+		{"daemonless", &Config{ExtraPushFlags: []string{"--disable-content-trust"}, Daemonless: true}, "daemonless"},
+		{"encryption", &Config{ExtraPushFlags: []string{"--disable-content-trust"}, EncryptionRecipients: []string{"jwe:key.pem"}}, "encryption_recipients"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateExtraFlags(tt.cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestExtraFlagsAppended(t *testing.T) {
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":             "myapp",
+			"extra_build_flags": []any{"--pull", "--network=none"},
+			"extra_push_flags":  []any{"--disable-content-trust"},
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+
+	build, push := mock.RunCalls[0].Args, mock.RunCalls[1].Args
+	i := slices.Index(build, "--pull")
+	if i < 0 || build[i+1] != "--network=none" || build[len(build)-1] != "." {
+		t.Errorf("expected extra flags before the build context, got %v", build)
+	}
+	if strings.Join(push, " ") != "push --disable-content-trust myapp:1.0.0" {
+		t.Errorf("expected extra flags before the pushed image, got %v", push)
+	}
+}
+
+func TestExtraFlagsRejected(t *testing.T) {
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"image": "myapp", "extra_build_flags": []any{"--build-arg=TOKEN=x"}},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success || !strings.Contains(resp.Error, "use build_args") || len(mock.RunCalls) != 0 {
+		t.Errorf("expected rejection before running docker, got %+v", resp)
+	}
+
+	vresp, err := p.Validate(context.Background(), map[string]any{"image": "myapp", "extra_push_flags": []any{"--all-tags"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if vresp.Valid || len(vresp.Errors) == 0 || vresp.Errors[0].Field != "extra_push_flags" {
+		t.Errorf("expected an extra_push_flags error, got %+v", vresp)
+	}
+}
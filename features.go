@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// experimentalFeature is an experimental behavior opted into through the
+// features option. Every experiment ends on a date: by then it has either
+// become stable under its own option or been removed, and a flag still
+// naming it is reported so it can be cleaned up.
+type experimentalFeature struct {
+	name   string
+	ends   time.Time
+	enable func(cfg *Config)
+}
+
+// experimentalFeatures lists the experiments features may enable.
+var experimentalFeatures = []experimentalFeature{
+	{
+		// Push the OCI image through the registry API, without a daemon.
+		name:   "daemonless",
+		ends:   time.Date(2027, time.April, 1, 0, 0, 0, 0, time.UTC),
+		enable: func(cfg *Config) { cfg.Daemonless = true },
+	},
+	{
+		// Render every buildx build from its JSON progress.
+		name:   "rawjson_progress",
+		ends:   time.Date(2027, time.April, 1, 0, 0, 0, 0, time.UTC),
+		enable: func(cfg *Config) { cfg.rawJSONProgress = true },
+	},
+	{
+		// Checkpoint completed stages so a re-run resumes after them.
+		name:   "resume",
+		ends:   time.Date(2027, time.April, 1, 0, 0, 0, 0, time.UTC),
+		enable: func(cfg *Config) { cfg.Resume = true },
+	},
+}
+
+// findFeature returns the experiment called name.
+func findFeature(name string) (experimentalFeature, bool) {
+	for _, f := range experimentalFeatures {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return experimentalFeature{}, false
+}
+
+// validateFeatures checks that features names known experiments.
+func validateFeatures(cfg *Config) error {
+	for _, name := range cfg.Features {
+		if _, ok := findFeature(name); !ok {
+			names := make([]string, len(experimentalFeatures))
+			for i, f := range experimentalFeatures {
+				names[i] = f.name
+			}
+			return fmt.Errorf("unknown feature %q (known: %s)", name, strings.Join(names, ", "))
+		}
+	}
+	return nil
+}
+
+// applyFeatures enables the experiments named by features and returns a
+// warning for every experiment that has ended. Ended experiments stay
+// enabled, so a stale flag does not change a release.
+func applyFeatures(cfg *Config, now time.Time) []string {
+	var warnings []string
+	for _, name := range cfg.Features {
+		f, ok := findFeature(name)
+		if !ok {
+			continue
+		}
+		f.enable(cfg)
+		if !now.Before(f.ends) {
+			warnings = append(warnings, fmt.Sprintf("experimental feature %s ended on %s; remove it from features", f.name, f.ends.Format(time.DateOnly)))
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestApplyFeatures(t *testing.T) {
+	cfg := &Config{Features: []string{"daemonless", "rawjson_progress", "resume"}}
+	before := time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC)
+	if warnings := applyFeatures(cfg, before); len(warnings) != 0 {
+		t.Errorf("expected no warnings for running experiments, got %v", warnings)
+	}
+	if !cfg.Daemonless || !cfg.rawJSONProgress || !cfg.Resume {
+		t.Errorf("expected every experiment to be enabled, got %+v", cfg)
+	}
+
+	ended := &Config{Features: []string{"resume"}}
+	warnings := applyFeatures(ended, time.Date(2027, time.May, 1, 0, 0, 0, 0, time.UTC))
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "experimental feature resume ended on 2027-04-01") {
+		t.Errorf("expected an ended experiment to be reported, got %v", warnings)
+	}
+	if !ended.Resume {
+		t.Errorf("expected an ended experiment to stay enabled")
+	}
+}
+
+func TestValidateFeatures(t *testing.T) {
+	if err := validateFeatures(&Config{Features: []string{"resume"}}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	err := validateFeatures(&Config{Features: []string{"turbo"}})
+	if err == nil || !strings.Contains(err.Error(), `unknown feature "turbo"`) || !strings.Contains(err.Error(), "rawjson_progress") {
+		t.Errorf("expected an unknown feature to be rejected, got %v", err)
+	}
+}
+
+func TestRawJSONProgressFeature(t *testing.T) {
+	cfg := &Config{Builder: "ci", rawJSONProgress: true}
+	if buildTraceFor(cfg) == nil {
+		t.Errorf("expected buildx builds to be traced with rawjson_progress")
+	}
+	if buildTraceFor(&Config{rawJSONProgress: true}) != nil {
+		t.Errorf("expected classic builds not to be traced")
+	}
+}
+
+func TestExecuteRejectsUnknownFeature(t *testing.T) {
+	p := &DockerPlugin{executor: &MockCommandExecutor{}}
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"image": "myorg/myapp", "features": []any{"turbo"}},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success || !strings.Contains(resp.Error, "invalid features") {
+		t.Errorf("expected invalid features, got %+v", resp)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// garUsername is the user name registries of Google Cloud accept OAuth 2.0
+// access tokens with.
+const garUsername = "oauth2accesstoken"
+
+// garTokenTTL is the lifetime of Google Cloud access tokens.
+const garTokenTTL = "1h"
+
+// Credential sources of gcp_credentials.
+const (
+	gcpCredentialsGcloud             = "gcloud"
+	gcpCredentialsApplicationDefault = "application_default"
+)
+
+// garHostPattern matches the hostnames of Artifact Registry, e.g.
+// europe-west1-docker.pkg.dev, and of Container Registry, which Artifact
+// Registry serves.
+var garHostPattern = regexp.MustCompile(`^([a-z0-9-]+-docker\.pkg\.dev|([a-z]+\.)?gcr\.io)$`)
+
+// isGARRegistry reports whether registry is hosted by Google Cloud.
+func isGARRegistry(registry string) bool {
+	return garHostPattern.MatchString(registry)
+}
+
+// validateGCPCredentials checks gcp_credentials.
+func validateGCPCredentials(cfg *Config) error {
+	switch cfg.GCPCredentials {
+	case "":
+		return nil
+	case gcpCredentialsGcloud, gcpCredentialsApplicationDefault:
+	default:
+		return fmt.Errorf("gcp_credentials must be %s or %s", gcpCredentialsGcloud, gcpCredentialsApplicationDefault)
+	}
+	if !isGARRegistry(cfg.Registry) {
+		return fmt.Errorf("gcp_credentials requires an Artifact Registry registry such as <region>-docker.pkg.dev")
+	}
+	return nil
+}
+
+// applyGAR logs in to Artifact Registry registries configured without
+// credentials with an access token printed by gcloud: the gcloud account,
+// which is the workload identity of a GKE pod or the service account of a
+// Cloud Build or Compute Engine runner, or with gcp_credentials:
+// application_default the Application Default Credentials, such as a
+// GOOGLE_APPLICATION_CREDENTIALS key or workload identity federation file.
+func applyGAR(cfg *Config) {
+	if !isGARRegistry(cfg.Registry) || len(cfg.PasswordCommand) > 0 || hasStaticCredentials(cfg) {
+		return
+	}
+	if cfg.Username != "" && cfg.Username != garUsername {
+		return
+	}
+	cfg.Username = garUsername
+	if cfg.GCPCredentials == gcpCredentialsApplicationDefault {
+		cfg.PasswordCommand = []string{"gcloud", "auth", "application-default", "print-access-token"}
+	} else {
+		cfg.PasswordCommand = []string{"gcloud", "auth", "print-access-token"}
+	}
+	if cfg.TokenTTL == "" {
+		cfg.TokenTTL = garTokenTTL
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestIsGARRegistry(t *testing.T) {
+	for registry, want := range map[string]bool{
+		"europe-west1-docker.pkg.dev": true,
+		"us-docker.pkg.dev":           true,
+		"gcr.io":                      true,
+		"eu.gcr.io":                   true,
+		"europe-west1-npm.pkg.dev":    false,
+		"ghcr.io":                     false,
+		"pkg.dev.example.com":         false,
+	} {
+		if got := isGARRegistry(registry); got != want {
+			t.Errorf("isGARRegistry(%q) = %v, want %v", registry, got, want)
+		}
+	}
+}
+
+func TestApplyGAR(t *testing.T) {
+	cfg := &Config{Registry: "europe-west1-docker.pkg.dev"}
+	applyGAR(cfg)
+	if cfg.Username != garUsername || cfg.TokenTTL != garTokenTTL {
+		t.Errorf("unexpected login: %q, ttl %q", cfg.Username, cfg.TokenTTL)
+	}
+	if !slices.Equal(cfg.PasswordCommand, []string{"gcloud", "auth", "print-access-token"}) {
+		t.Errorf("unexpected password_command: %v", cfg.PasswordCommand)
+	}
+
+	adc := &Config{Registry: "europe-west1-docker.pkg.dev", GCPCredentials: gcpCredentialsApplicationDefault}
+	applyGAR(adc)
+	if !slices.Equal(adc.PasswordCommand, []string{"gcloud", "auth", "application-default", "print-access-token"}) {
+		t.Errorf("unexpected password_command: %v", adc.PasswordCommand)
+	}
+
+	static := &Config{Registry: "gcr.io", Username: "_json_key", Password: "{}"}
+	applyGAR(static)
+	if len(static.PasswordCommand) > 0 {
+		t.Errorf("expected static credentials to take precedence")
+	}
+}
+
+func TestValidateGCPCredentials(t *testing.T) {
+	if err := validateGCPCredentials(&Config{Registry: "us-docker.pkg.dev", GCPCredentials: "application_default"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := validateGCPCredentials(&Config{Registry: "us-docker.pkg.dev", GCPCredentials: "keyfile"}); err == nil {
+		t.Errorf("expected an unknown source to be rejected")
+	}
+	if err := validateGCPCredentials(&Config{Registry: "ghcr.io", GCPCredentials: "gcloud"}); err == nil || !strings.Contains(err.Error(), "Artifact Registry") {
+		t.Errorf("expected a non-Google registry to be rejected, got %v", err)
+	}
+}
+
+func TestExecuteGARLogsInWithAccessToken(t *testing.T) {
+	mock := &MockCommandExecutor{
+		OutputFunc: func(ctx context.Context, name string, args []string) ([]byte, error) {
+			if name == "gcloud" {
+				return []byte("ya29.token\n"), nil
+			}
+			return nil, nil
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":    "my-project/releases/myapp",
+			"registry": "europe-west1-docker.pkg.dev",
+			"tags":     []any{"1.0.0"},
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+	for _, call := range mock.RunCalls {
+		if len(call.Args) > 0 && call.Args[0] == "login" {
+			if !containsArg(call.Args, "-u", garUsername) || call.Stdin != "ya29.token" {
+				t.Errorf("unexpected login: %v with %q", call.Args, call.Stdin)
+			}
+			return
+		}
+	}
+	t.Errorf("expected a docker login, got %v", mock.RunCalls)
+}
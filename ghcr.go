@@ -0,0 +1,22 @@
+package main
+
+import "os"
+
+// applyGHCR logs in to ghcr.io registries configured without credentials
+// with the GITHUB_TOKEN and GITHUB_ACTOR of the GitHub Actions run, which
+// can push packages of the repository when the workflow grants
+// packages: write.
+func applyGHCR(cfg *Config) {
+	if normalizeRegistry(cfg.Registry) != "ghcr.io" || len(cfg.PasswordCommand) > 0 || loginSecret(cfg) != "" {
+		return
+	}
+	token := os.Getenv("GITHUB_TOKEN")
+	username := cfg.Username
+	if username == "" {
+		username = os.Getenv("GITHUB_ACTOR")
+	}
+	if token == "" || username == "" {
+		return
+	}
+	cfg.Username, cfg.Password = username, token
+}
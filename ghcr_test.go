@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestApplyGHCR(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "ghs_token")
+	t.Setenv("GITHUB_ACTOR", "octocat")
+
+	cfg := &Config{Registry: "ghcr.io"}
+	applyGHCR(cfg)
+	if cfg.Username != "octocat" || cfg.Password != "ghs_token" {
+		t.Errorf("expected the GitHub Actions credentials, got %q/%q", cfg.Username, cfg.Password)
+	}
+
+	named := &Config{Registry: "ghcr.io", Username: "release-bot"}
+	applyGHCR(named)
+	if named.Username != "release-bot" || named.Password != "ghs_token" {
+		t.Errorf("expected the configured username with GITHUB_TOKEN, got %q/%q", named.Username, named.Password)
+	}
+
+	static := &Config{Registry: "ghcr.io", Username: "bot", Password: "ghp_pat"}
+	applyGHCR(static)
+	if static.Password != "ghp_pat" {
+		t.Errorf("expected static credentials to take precedence, got %q", static.Password)
+	}
+
+	command := &Config{Registry: "ghcr.io", PasswordCommand: []string{"get-token"}}
+	applyGHCR(command)
+	if command.Password != "" {
+		t.Errorf("expected password_command to take precedence")
+	}
+
+	other := &Config{Registry: "docker.io"}
+	applyGHCR(other)
+	if other.Username != "" {
+		t.Errorf("expected other registries to be left unchanged")
+	}
+
+	t.Setenv("GITHUB_ACTOR", "")
+	anonymous := &Config{Registry: "ghcr.io"}
+	applyGHCR(anonymous)
+	if anonymous.Password != "" {
+		t.Errorf("expected no login without GITHUB_ACTOR")
+	}
+}
+
+func TestExecuteLogsInToGHCRWithGitHubToken(t *testing.T) {
+	t.Setenv("DOCKER_USERNAME", "")
+	t.Setenv("DOCKER_PASSWORD", "")
+	t.Setenv("DOCKER_PAT", "")
+	t.Setenv("GITHUB_TOKEN", "ghs_token")
+	t.Setenv("GITHUB_ACTOR", "octocat")
+
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock}
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"registry": "ghcr.io", "image": "myorg/myapp"},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil || !resp.Success {
+		t.Fatalf("unexpected failure: %v %+v", err, resp)
+	}
+	login := mock.RunCalls[0]
+	if login.Args[0] != "login" || !containsArg(login.Args, "-u", "octocat") || login.Stdin != "ghs_token" {
+		t.Errorf("expected a login with GITHUB_TOKEN, got %+v", login)
+	}
+}
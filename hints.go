@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// remediations suggest fixes for well-known failures, matched in order
+// against the lowercased error. A remediation is not suggested when the
+// error already explains the failure with one of its unless fragments.
+var remediations = []struct {
+	fragments []string
+	unless    []string
+	advice    string
+}{
+	{fragments: []string{"cannot connect to the docker daemon", "is the docker daemon running"}, advice: "Start the Docker daemon or point DOCKER_HOST at a running one."},
+	{fragments: []string{"no space left on device"}, advice: "Free disk space on the runner, e.g. with docker system prune, or move the docker data root to a larger disk."},
+	{fragments: []string{"toomanyrequests", "too many requests", "rate limit"}, advice: "The registry is rate limiting; authenticate pulls, or set push_retries and push_retry_backoff."},
+	{fragments: []string{"exec format error"}, advice: "A binary was built for another architecture; install QEMU binfmt handlers (docker run --privileged --rm tonistiigi/binfmt --install all) or build each platform natively with builder_nodes."},
+	{
+		fragments: []string{"unauthorized: authentication required", "unauthorized", "incorrect username or password"},
+		unless:    []string{"docker hub rejected"},
+		advice:    "Check the registry credentials (username, password, password_command or the DOCKER_* environment variables).",
+	},
+	{fragments: []string{"denied: requested access", "denied", "forbidden"}, advice: "The credentials may not push to this repository; check that it exists and that the token has write scope and repository access."},
+	{fragments: []string{"no such host", "connection refused", "i/o timeout", "tls handshake timeout"}, advice: "Check the registry address and the network or proxy settings of the runner."},
+	{fragments: []string{"x509:", "certificate"}, advice: "The registry certificate is not trusted; install its CA, or set insecure for a test registry."},
+	{fragments: []string{"failed to solve", "dockerfile parse error", "failed to compute cache key"}, advice: "Fix the Dockerfile step named in the build output; build locally with the same build args to reproduce."},
+	{fragments: []string{"does not exist; the plugin producing it"}, advice: "Run the plugin producing the input before this one."},
+	{fragments: []string{"working tree is not clean"}, advice: "Commit or stash the changes, or use clean_checkout."},
+	{fragments: []string{"executable file not found", "not found in $path"}, advice: "Install the missing tool on the runner."},
+}
+
+// remediationHint returns advice for a failure described by text, or an
+// empty string.
+func remediationHint(text string) string {
+	text = strings.ToLower(text)
+	for _, r := range remediations {
+		explained := false
+		for _, fragment := range r.unless {
+			explained = explained || strings.Contains(text, fragment)
+		}
+		if explained {
+			continue
+		}
+		for _, fragment := range r.fragments {
+			if strings.Contains(text, fragment) {
+				return r.advice
+			}
+		}
+	}
+	return ""
+}
+
+// addRemediationHint appends advice for well-known failures to the error of
+// a failed response.
+func addRemediationHint(resp *plugin.ExecuteResponse) {
+	if resp == nil || resp.Success || resp.Error == "" {
+		return
+	}
+	if hint := remediationHint(resp.Error); hint != "" {
+		resp.Error += "; hint: " + hint
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestRemediationHint(t *testing.T) {
+	tests := []struct {
+		err      string
+		expected string
+	}{
+		{"exit status 1: denied: requested access to the resource is denied", "write scope"},
+		{"exit status 1: toomanyrequests: You have reached your pull rate limit", "push_retries"},
+		{"write /var/lib/docker/tmp/x: no space left on device", "docker system prune"},
+		{"process \"/bin/sh -c make\" did not complete successfully: exec format error", "binfmt"},
+		{"exit status 1: unauthorized: authentication required", "registry credentials"},
+		{"exit status 1: unauthorized: incorrect username or password; Docker Hub rejected the password", ""},
+		{"label policy violation: missing required labels maintainer", ""},
+	}
+	for _, tt := range tests {
+		got := remediationHint(tt.err)
+		if tt.expected == "" {
+			if got != "" {
+				t.Errorf("remediationHint(%q) = %q, expected none", tt.err, got)
+			}
+			continue
+		}
+		if !strings.Contains(got, tt.expected) {
+			t.Errorf("remediationHint(%q) = %q, expected it to mention %q", tt.err, got, tt.expected)
+		}
+	}
+}
+
+func TestExecuteAddsRemediationHint(t *testing.T) {
+	mock := &MockCommandExecutor{
+		FailOnCall:  1,
+		FailWithErr: errors.New("exit status 1: ERROR: failed to solve: write /tmp/x: no space left on device"),
+	}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"image": "myapp"},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success || !strings.Contains(resp.Error, "no space left on device; hint: Free disk space on the runner") {
+		t.Errorf("expected a disk space hint, got %q", resp.Error)
+	}
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// supportedHooks lists the hooks advertised in GetInfo. Hosts that only call
+// PostPublish get the single-phase build and push; the other hooks only act
+// when enabled in the configuration.
+var supportedHooks = []plugin.Hook{
+	plugin.HookPrePublish,
+	plugin.HookPostPublish,
+	plugin.HookOnSuccess,
+	plugin.HookOnError,
+}
+
+// hookNotHandled is the response for hooks the configuration does not enable.
+func hookNotHandled(hook plugin.Hook) *plugin.ExecuteResponse {
+	return &plugin.ExecuteResponse{
+		Success: true,
+		Message: fmt.Sprintf("Hook %s not handled", hook),
+	}
+}
+
+// releaseRefs returns the image references of the release.
+func releaseRefs(cfg *Config, releaseVersion string) ([]string, error) {
+	tags, err := resolveTags(cfg.Tags, releaseVersion, cfg.tagContext)
+	if err != nil {
+		return nil, err
+	}
+	repository := imageRepository(cfg)
+	refs := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		refs = append(refs, fmt.Sprintf("%s:%s", repository, tag))
+	}
+	return refs, nil
+}
+
+// prePublish builds the image without pushing it when split_phases is set,
+// so a failing build stops the release before anything is published.
+func (p *DockerPlugin) prePublish(ctx context.Context, cfg *Config, releaseCtx plugin.ReleaseContext, dryRun bool) (*plugin.ExecuteResponse, error) {
+	buildCfg := *cfg
+	buildCfg.Push = false
+	resp, err := p.buildAndPush(ctx, &buildCfg, releaseCtx, dryRun)
+	if resp != nil && resp.Success {
+		resp.Message = "Built Docker image; push deferred to post-publish"
+		if dryRun {
+			resp.Message = "Would build Docker image; push deferred to post-publish"
+		} else if err := p.savePhaseState(ctx, &buildCfg, releaseCtx.Version); err != nil {
+			warnings, _ := resp.Outputs["warnings"].([]string)
+			resp.Outputs["warnings"] = append(warnings, fmt.Sprintf("failed to record the built image for post-publish: %v", err))
+		}
+	}
+	return resp, err
+}
+
+// markPrebuilt flags a classic build as already done when every release
+// reference exists in the local daemon, as left behind by prePublish. When
+// prePublish recorded the image it built, the references must still be that
+// image; otherwise the image is built again with a warning. Buildx builds
+// always run again; their layers come from the builder cache.
+func (p *DockerPlugin) markPrebuilt(ctx context.Context, cfg *Config, releaseVersion string) {
+	if useBuildx(cfg) {
+		return
+	}
+	refs, err := releaseRefs(cfg, releaseVersion)
+	if err != nil || len(refs) == 0 {
+		return
+	}
+	state, recorded := loadPhaseState(cfg, releaseVersion, refs)
+	for _, ref := range refs {
+		out, err := p.getExecutor().Output(ctx, "docker", []string{"image", "inspect", "--format", "{{.Id}}", ref})
+		if err != nil {
+			return
+		}
+		if recorded && state.ImageID != "" && strings.TrimSpace(string(out)) != state.ImageID {
+			cfg.phaseWarnings = append(cfg.phaseWarnings, fmt.Sprintf("%s is no longer the image built by pre-publish; building again", ref))
+			return
+		}
+	}
+	cfg.prebuilt = true
+}
+
+// verifyRelease checks that every release reference resolves in the registry.
+func (p *DockerPlugin) verifyRelease(ctx context.Context, cfg *Config, releaseCtx plugin.ReleaseContext, dryRun bool) (*plugin.ExecuteResponse, error) {
+	refs, err := releaseRefs(cfg, releaseCtx.Version)
+	if err != nil {
+		return &plugin.ExecuteResponse{Success: false, Error: err.Error()}, nil
+	}
+	if dryRun {
+		return &plugin.ExecuteResponse{
+			Success: true,
+			Message: fmt.Sprintf("Would verify %d image references", len(refs)),
+			Outputs: map[string]any{"refs": refs},
+		}, nil
+	}
+
+	var missing []string
+	for _, ref := range refs {
+		if !p.imageExists(ctx, ref) {
+			missing = append(missing, ref)
+		}
+	}
+	if len(missing) > 0 {
+		return &plugin.ExecuteResponse{
+			Success: false,
+			Error:   fmt.Sprintf("image verification failed: %s not found in registry", strings.Join(missing, ", ")),
+			Outputs: map[string]any{"refs": refs, "missing_refs": missing},
+		}, nil
+	}
+	return &plugin.ExecuteResponse{
+		Success: true,
+		Message: fmt.Sprintf("Verified %d image references", len(refs)),
+		Outputs: map[string]any{"refs": refs},
+	}, nil
+}
+
+// rollbackRelease points the moving tags of a failed release (those that do
+// not change between versions, such as latest or {{major}}) back at the
+// previous release. Version-specific tags are left alone, as nothing
+// referenced them before the release, unless rollback_delete_tags deletes
+// them.
+func (p *DockerPlugin) rollbackRelease(ctx context.Context, cfg *Config, releaseCtx plugin.ReleaseContext, dryRun bool) (*plugin.ExecuteResponse, error) {
+	restore, source, err := rollbackRefs(cfg, releaseCtx)
+	if err != nil {
+		return &plugin.ExecuteResponse{Success: false, Error: err.Error()}, nil
+	}
+	var remove []string
+	if cfg.RollbackDeleteTags {
+		refs, err := releaseRefs(cfg, releaseCtx.Version)
+		if err != nil {
+			return &plugin.ExecuteResponse{Success: false, Error: err.Error()}, nil
+		}
+		for _, ref := range refs {
+			if !slices.Contains(restore, ref) {
+				remove = append(remove, ref)
+			}
+		}
+	}
+	if len(restore) == 0 && len(remove) == 0 {
+		message := "No moving tags to roll back"
+		if releaseCtx.PreviousVersion == "" {
+			message = "No previous version; nothing to roll back"
+		}
+		return &plugin.ExecuteResponse{
+			Success: true,
+			Message: message,
+		}, nil
+	}
+
+	outputs := map[string]any{}
+	var actions []string
+	if len(restore) > 0 {
+		outputs["source"], outputs["restored_refs"] = source, restore
+		actions = append(actions, fmt.Sprintf("point %d tags back at %s", len(restore), source))
+	}
+	if len(remove) > 0 {
+		outputs["deleted_refs"] = remove
+		actions = append(actions, fmt.Sprintf("delete %d tags", len(remove)))
+	}
+	if dryRun {
+		return &plugin.ExecuteResponse{
+			Success: true,
+			Message: "Would " + strings.Join(actions, " and "),
+			Outputs: outputs,
+		}, nil
+	}
+
+	if hasStaticCredentials(cfg) && !skipLogin(cfg) {
+		if err := p.dockerLogin(ctx, cfg); err != nil {
+			return &plugin.ExecuteResponse{
+				Success: false,
+				Error:   fmt.Sprintf("failed to login to registry: %v", err),
+			}, nil
+		}
+	}
+
+	if len(restore) > 0 {
+		if !p.imageExists(ctx, source) {
+			return &plugin.ExecuteResponse{
+				Success: false,
+				Error:   fmt.Sprintf("rollback failed: previous image %s not found in registry", source),
+			}, nil
+		}
+		if err := p.retagImage(ctx, source, restore); err != nil {
+			return &plugin.ExecuteResponse{
+				Success: false,
+				Error:   fmt.Sprintf("rollback failed: %v", err),
+			}, nil
+		}
+	}
+
+	if len(remove) > 0 {
+		// The previous release keeps its digest, and the artifacts attached
+		// to it, even when the failed release retagged that same digest.
+		keep := restore
+		if releaseCtx.PreviousVersion != "" {
+			keep = append(slices.Clone(restore), fmt.Sprintf("%s:%s", imageRepository(cfg), strings.TrimPrefix(releaseCtx.PreviousVersion, "v")))
+		}
+		deleted, warnings, err := p.deleteReleaseTags(ctx, cfg, remove, keep)
+		outputs["deleted_refs"] = deleted
+		if len(warnings) > 0 {
+			outputs["warnings"] = warnings
+		}
+		if err != nil {
+			return &plugin.ExecuteResponse{
+				Success: false,
+				Error:   fmt.Sprintf("rollback failed: %v", err),
+				Outputs: outputs,
+			}, nil
+		}
+	}
+	message := strings.Join(actions, " and ")
+	return &plugin.ExecuteResponse{
+		Success: true,
+		Message: strings.ToUpper(message[:1]) + message[1:],
+		Outputs: outputs,
+	}, nil
+}
+
+// rollbackRefs returns the moving tags of a failed release that resolve the
+// same for the previous release, and the image of the previous release they
+// are pointed back at.
+func rollbackRefs(cfg *Config, releaseCtx plugin.ReleaseContext) ([]string, string, error) {
+	// Tags naming one release, such as {{version}} or {{short_sha}}, are
+	// never moved back.
+	moving := movingTemplates(cfg)
+	if releaseCtx.PreviousVersion == "" || len(moving) == 0 {
+		return nil, "", nil
+	}
+	current, err := resolveTags(moving, releaseCtx.Version, cfg.tagContext)
+	if err != nil {
+		return nil, "", err
+	}
+	previous, err := resolveTags(moving, releaseCtx.PreviousVersion, cfg.tagContext)
+	if err != nil {
+		return nil, "", err
+	}
+
+	repository := imageRepository(cfg)
+	source := fmt.Sprintf("%s:%s", repository, strings.TrimPrefix(releaseCtx.PreviousVersion, "v"))
+
+	var restore []string
+	for i, tag := range current {
+		if i < len(previous) && previous[i] == tag {
+			restore = append(restore, fmt.Sprintf("%s:%s", repository, tag))
+		}
+	}
+	return restore, source, nil
+}
+
+// deleteReleaseTags deletes refs of a failed release over the registry API,
+// keeping the tags of keep. It returns the deleted references; registries
+// that cannot delete tags are reported as warnings. The signatures,
+// attestations and SBOMs attached to a digest are deleted once all of its
+// tags are; tags whose artifacts the registry cannot delete are kept, so
+// those artifacts are never orphaned.
+func (p *DockerPlugin) deleteReleaseTags(ctx context.Context, cfg *Config, refs, keep []string) ([]string, []string, error) {
+	deleter, err := p.newTagDeleter(ctx, cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to resolve registry credentials: %w", err)
+	}
+	keepTags := make([]string, 0, len(keep))
+	for _, ref := range keep {
+		keepTags = append(keepTags, ref[strings.LastIndex(ref, ":")+1:])
+	}
+	tags := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		tags = append(tags, ref[strings.LastIndex(ref, ":")+1:])
+	}
+	attached, err := deleter.referrers(ctx, tags, keepTags)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to look up referrers: %w", err)
+	}
+
+	deleted := []string{}
+	var warnings, failed []string
+	// remaining counts the tags of each digest with referrers that are not
+	// deleted yet.
+	remaining := make(map[*digestReferrers]int)
+	var referrers []*digestReferrers
+	for _, tag := range tags {
+		if r, ok := attached[tag]; ok {
+			if remaining[r] == 0 {
+				referrers = append(referrers, r)
+			}
+			remaining[r]++
+		}
+	}
+	for i, ref := range refs {
+		tag := tags[i]
+		r, hasReferrers := attached[tag]
+		if hasReferrers && len(r.digests) > 0 && !deleter.deletesDigests() {
+			warnings = append(warnings, fmt.Sprintf("%s was not deleted: it would orphan %s, which the registry cannot delete", ref, strings.Join(r.digests, ", ")))
+			continue
+		}
+		err := deleter.delete(ctx, tag, keepTags)
+		switch {
+		case errors.Is(err, errTagDeleteUnsupported):
+			warnings = append(warnings, fmt.Sprintf("%s was not deleted: %v", ref, err))
+		case err != nil:
+			failed = append(failed, fmt.Sprintf("%s: %v", ref, err))
+		default:
+			deleted = append(deleted, ref)
+			if hasReferrers {
+				remaining[r]--
+			}
+		}
+	}
+
+	repository := imageRepository(cfg)
+	for _, r := range referrers {
+		if remaining[r] > 0 {
+			continue
+		}
+		for _, tag := range r.tags {
+			if err := deleter.delete(ctx, tag, keepTags); err != nil {
+				failed = append(failed, fmt.Sprintf("%s:%s: %v", repository, tag, err))
+				continue
+			}
+			deleted = append(deleted, fmt.Sprintf("%s:%s", repository, tag))
+		}
+		for _, digest := range r.digests {
+			if err := deleter.deleteDigest(ctx, digest); err != nil {
+				failed = append(failed, fmt.Sprintf("%s@%s: %v", repository, digest, err))
+				continue
+			}
+			deleted = append(deleted, fmt.Sprintf("%s@%s", repository, digest))
+		}
+	}
+	p.lookups.invalidate()
+	if len(failed) > 0 {
+		return deleted, warnings, fmt.Errorf("failed to delete %s", strings.Join(failed, "; "))
+	}
+	return deleted, warnings, nil
+}
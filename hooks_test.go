@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestGetInfoAdvertisesHooks(t *testing.T) {
+	info := (&DockerPlugin{}).GetInfo()
+	for _, want := range []plugin.Hook{plugin.HookPrePublish, plugin.HookPostPublish, plugin.HookOnSuccess, plugin.HookOnError} {
+		found := false
+		for _, hook := range info.Hooks {
+			found = found || hook == want
+		}
+		if !found {
+			t.Errorf("expected hook %s to be advertised", want)
+		}
+	}
+}
+
+func TestOptionalHooksDisabledByDefault(t *testing.T) {
+	for _, hook := range []plugin.Hook{plugin.HookPrePublish, plugin.HookOnSuccess, plugin.HookOnError} {
+		t.Run(string(hook), func(t *testing.T) {
+			mock := &MockCommandExecutor{}
+			p := &DockerPlugin{executor: mock}
+
+			resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+				Hook:    hook,
+				Config:  map[string]any{"image": "myapp"},
+				Context: plugin.ReleaseContext{Version: "v1.0.0", PreviousVersion: "v0.9.0"},
+			})
+			if err != nil || !resp.Success || !strings.Contains(resp.Message, "not handled") {
+				t.Errorf("expected hook to be ignored, got %v %+v", err, resp)
+			}
+			if len(mock.RunCalls)+len(mock.OutputCalls) != 0 {
+				t.Errorf("expected no commands, got %d run and %d output calls", len(mock.RunCalls), len(mock.OutputCalls))
+			}
+		})
+	}
+}
+
+func TestSplitPhases(t *testing.T) {
+	config := map[string]any{"image": "myapp", "tags": []any{"{{version}}"}, "split_phases": true}
+	releaseCtx := plugin.ReleaseContext{Version: "v1.0.0"}
+
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{Hook: plugin.HookPrePublish, Config: config, Context: releaseCtx})
+	if err != nil || !resp.Success {
+		t.Fatalf("pre-publish failed: %v %+v", err, resp)
+	}
+	if len(mock.RunCalls) != 1 || mock.RunCalls[0].Args[0] != "build" {
+		t.Fatalf("expected only a build in pre-publish, got %v", mock.RunCalls)
+	}
+
+	// The image built in pre-publish is found locally, so only the push runs.
+	mock = &MockCommandExecutor{}
+	p.executor = mock
+	resp, err = p.Execute(context.Background(), plugin.ExecuteRequest{Hook: plugin.HookPostPublish, Config: config, Context: releaseCtx})
+	if err != nil || !resp.Success {
+		t.Fatalf("post-publish failed: %v %+v", err, resp)
+	}
+	if len(mock.RunCalls) != 1 || mock.RunCalls[0].Args[0] != "push" {
+		t.Errorf("expected only a push in post-publish, got %v", mock.RunCalls)
+	}
+
+	// A host that skipped pre-publish still gets a build.
+	mock = &MockCommandExecutor{
+		OutputFunc: func(_ context.Context, _ string, args []string) ([]byte, error) {
+			if args[0] == "image" {
+				return nil, errors.New("No such image")
+			}
+			return nil, nil
+		},
+	}
+	p.executor = mock
+	resp, err = p.Execute(context.Background(), plugin.ExecuteRequest{Hook: plugin.HookPostPublish, Config: config, Context: releaseCtx})
+	if err != nil || !resp.Success {
+		t.Fatalf("post-publish failed: %v %+v", err, resp)
+	}
+	if len(mock.RunCalls) != 2 || mock.RunCalls[0].Args[0] != "build" {
+		t.Errorf("expected build and push, got %v", mock.RunCalls)
+	}
+}
+
+func TestPhaseStateCarriesImageID(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	config := map[string]any{"image": "myapp", "tags": []any{"{{version}}"}, "phase": "split"}
+	stateFile := phaseStatePath(&Config{}, []string{"myapp:1.0.0"})
+	releaseCtx := plugin.ReleaseContext{Version: "v1.0.0"}
+	imageID := func(id string) func(context.Context, string, []string) ([]byte, error) {
+		return func(_ context.Context, _ string, args []string) ([]byte, error) {
+			if args[0] == "image" {
+				return []byte(id + "\n"), nil
+			}
+			return nil, nil
+		}
+	}
+
+	p := &DockerPlugin{executor: &MockCommandExecutor{OutputFunc: imageID("sha256:built")}}
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{Hook: plugin.HookPrePublish, Config: config, Context: releaseCtx})
+	if err != nil || !resp.Success {
+		t.Fatalf("pre-publish failed: %v %+v", err, resp)
+	}
+	data, err := os.ReadFile(stateFile)
+	if err != nil || !strings.Contains(string(data), "sha256:built") {
+		t.Fatalf("expected the built image to be recorded, got %q, %v", data, err)
+	}
+
+	// The tag was pointed at another image between the hooks.
+	mock := &MockCommandExecutor{OutputFunc: imageID("sha256:other")}
+	p.executor = mock
+	resp, err = p.Execute(context.Background(), plugin.ExecuteRequest{Hook: plugin.HookPostPublish, Config: config, Context: releaseCtx})
+	if err != nil || !resp.Success {
+		t.Fatalf("post-publish failed: %v %+v", err, resp)
+	}
+	if len(mock.RunCalls) != 2 || mock.RunCalls[0].Args[0] != "build" {
+		t.Errorf("expected the image to be built again, got %v", mock.RunCalls)
+	}
+	warnings, _ := resp.Outputs["warnings"].([]string)
+	if len(warnings) == 0 || !strings.Contains(warnings[0], "no longer the image built by pre-publish") {
+		t.Errorf("expected a warning about the changed image, got %v", warnings)
+	}
+	if _, err := os.Stat(stateFile); !os.IsNotExist(err) {
+		t.Errorf("expected the state to be removed after the push, got %v", err)
+	}
+}
+
+func TestValidatePhase(t *testing.T) {
+	if err := validatePhase(&Config{Phase: "later"}); err == nil || !strings.Contains(err.Error(), "unknown phase") {
+		t.Errorf("expected an unknown phase to fail, got %v", err)
+	}
+	if err := validatePhase(&Config{Phase: phaseSplit}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestVerifyRelease(t *testing.T) {
+	mock := &MockCommandExecutor{
+		OutputFunc: func(_ context.Context, _ string, args []string) ([]byte, error) {
+			if strings.HasSuffix(args[len(args)-1], ":latest") {
+				return nil, errors.New("not found")
+			}
+			return nil, nil
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookOnSuccess,
+		Config:  map[string]any{"image": "myapp", "verify": true},
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success || !strings.Contains(resp.Error, "myapp:latest") {
+		t.Errorf("expected missing latest tag, got %+v", resp)
+	}
+}
+
+func TestRollbackRelease(t *testing.T) {
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookOnError,
+		Config: map[string]any{
+			"image":             "myorg/myapp",
+			"tags":              []any{"{{version}}", "{{major}}", "{{major}}.{{minor}}", "latest"},
+			"rollback_on_error": true,
+		},
+		Context: plugin.ReleaseContext{Version: "v1.3.0", PreviousVersion: "v1.2.4"},
+	})
+	if err != nil || !resp.Success {
+		t.Fatalf("rollback failed: %v %+v", err, resp)
+	}
+
+	if len(mock.RunCalls) != 1 {
+		t.Fatalf("expected one retag, got %v", mock.RunCalls)
+	}
+	args := mock.RunCalls[0].Args
+	if args[len(args)-1] != "myorg/myapp:1.2.4" {
+		t.Errorf("expected previous release as source, got %v", args)
+	}
+	if !containsArg(args, "--tag", "myorg/myapp:1") || !containsArg(args, "--tag", "myorg/myapp:latest") {
+		t.Errorf("expected moving tags to be restored, got %v", args)
+	}
+	if containsArg(args, "--tag", "myorg/myapp:1.3") || containsArg(args, "--tag", "myorg/myapp:1.3.0") {
+		t.Errorf("expected new tags to be left alone, got %v", args)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// ImageConfig is the runtime configuration of the built image, as recorded
+// in its image config.
+type ImageConfig struct {
+	ExposedPorts []string `json:"exposed_ports,omitempty"`
+	Entrypoint   []string `json:"entrypoint,omitempty"`
+	Cmd          []string `json:"cmd,omitempty"`
+	User         string   `json:"user,omitempty"`
+	WorkingDir   string   `json:"workdir,omitempty"`
+}
+
+// containerConfig is the config section shared by docker image inspect and
+// OCI image configs.
+type containerConfig struct {
+	ExposedPorts map[string]struct{} `json:"ExposedPorts"`
+	Entrypoint   []string            `json:"Entrypoint"`
+	Cmd          []string            `json:"Cmd"`
+	User         string              `json:"User"`
+	WorkingDir   string              `json:"WorkingDir"`
+}
+
+func (c containerConfig) imageConfig() *ImageConfig {
+	cfg := &ImageConfig{
+		Entrypoint: c.Entrypoint,
+		Cmd:        c.Cmd,
+		User:       c.User,
+		WorkingDir: c.WorkingDir,
+	}
+	for port := range c.ExposedPorts {
+		cfg.ExposedPorts = append(cfg.ExposedPorts, port)
+	}
+	sort.Strings(cfg.ExposedPorts)
+	return cfg
+}
+
+// inspectImageConfig returns the image config of ref. Images in the local
+// daemon are inspected directly; images buildx pushed without loading them are
+// read from the registry, using the first platform of a multi-platform index.
+func (p *DockerPlugin) inspectImageConfig(ctx context.Context, ref string, local bool) (*ImageConfig, error) {
+	if local {
+		out, err := p.getExecutor().Output(ctx, "docker", []string{"image", "inspect", "--format", "{{json .Config}}", ref})
+		if err != nil {
+			return nil, err
+		}
+		var c containerConfig
+		if err := json.Unmarshal(out, &c); err != nil {
+			return nil, fmt.Errorf("invalid image config of %s: %w", ref, err)
+		}
+		return c.imageConfig(), nil
+	}
+
+	out, err := p.getExecutor().Output(ctx, "docker", []string{"buildx", "imagetools", "inspect", "--format", "{{json .Image}}", ref})
+	if err != nil {
+		return nil, err
+	}
+	// A single-platform image is an OCI image config; a multi-platform index
+	// maps each platform to one.
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(out, &fields); err != nil || len(fields) == 0 {
+		return nil, fmt.Errorf("invalid image config of %s", ref)
+	}
+	image := out
+	if _, ok := fields["config"]; !ok {
+		image = fields[sortedKeys(fields)[0]]
+	}
+	var oci struct {
+		Config containerConfig `json:"config"`
+	}
+	if err := json.Unmarshal(image, &oci); err != nil {
+		return nil, fmt.Errorf("invalid image config of %s: %w", ref, err)
+	}
+	return oci.Config.imageConfig(), nil
+}
+
+// imageInDaemon reports whether the build left the image in the local daemon.
+func imageInDaemon(cfg *Config) bool {
+	return !useBuildx(cfg) || (cfg.Load && len(cfg.Platforms) <= 1)
+}
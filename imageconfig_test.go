@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+const testContainerConfig = `{"ExposedPorts":{"8080/tcp":{},"443/tcp":{}},"Entrypoint":["/app"],"Cmd":["serve"],"User":"65532","WorkingDir":"/srv"}`
+
+func TestInspectImageConfig(t *testing.T) {
+	want := &ImageConfig{
+		ExposedPorts: []string{"443/tcp", "8080/tcp"},
+		Entrypoint:   []string{"/app"},
+		Cmd:          []string{"serve"},
+		User:         "65532",
+		WorkingDir:   "/srv",
+	}
+
+	tests := []struct {
+		name   string
+		local  bool
+		output string
+	}{
+		{"local image", true, testContainerConfig},
+		{"registry image", false, `{"architecture":"amd64","config":` + testContainerConfig + `}`},
+		{"registry index", false, `{"linux/amd64":{"config":` + testContainerConfig + `},"linux/arm64":{"config":{}}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &MockCommandExecutor{
+				OutputFunc: func(context.Context, string, []string) ([]byte, error) {
+					return []byte(tt.output), nil
+				},
+			}
+			p := &DockerPlugin{executor: mock}
+
+			got, err := p.inspectImageConfig(context.Background(), "myapp:1.0.0", tt.local)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("inspectImageConfig() = %+v, want %+v", got, want)
+			}
+			if tt.local != !containsFlag(mock.OutputCalls[0].Args, "imagetools") {
+				t.Errorf("unexpected inspect command: %v", mock.OutputCalls[0].Args)
+			}
+		})
+	}
+}
+
+func TestExecuteReportsImageConfig(t *testing.T) {
+	mock := &MockCommandExecutor{
+		OutputFunc: func(_ context.Context, _ string, args []string) ([]byte, error) {
+			if containsArg(args, "--format", "{{json .Config}}") {
+				return []byte(testContainerConfig), nil
+			}
+			return nil, nil
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"image": "myapp"},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+
+	cfg, ok := resp.Outputs["image_config"].(*ImageConfig)
+	if !ok {
+		t.Fatalf("expected image_config output, got %v", resp.Outputs["image_config"])
+	}
+	if cfg.User != "65532" || len(cfg.ExposedPorts) != 2 {
+		t.Errorf("unexpected image config: %+v", cfg)
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// imageDefinitionKeys are the options an entry of images may set. The
+// other options are shared by every image.
+var imageDefinitionKeys = []string{"image", "dockerfile", "context", "target", "tags", "build_args"}
+
+// parallelExclusiveKeys are the options writing a file that records one
+// execution, which definitions released in parallel cannot share.
+var parallelExclusiveKeys = []string{"audit_file", "failure_report"}
+
+// checkParallel rejects the options of parallelExclusiveKeys when the
+// definitions of raw are released in parallel with option.
+func checkParallel(raw map[string]any, option string) error {
+	if parallel, _ := raw[option].(bool); !parallel {
+		return nil
+	}
+	for _, key := range parallelExclusiveKeys {
+		if _, ok := raw[key]; ok {
+			return fmt.Errorf("%s cannot be combined with %s: each definition would replace the file written by the others", key, option)
+		}
+	}
+	return nil
+}
+
+// stateFiles serializes the read-modify-write updates of files shared by
+// definitions released in parallel, such as version_manifest, by holding a
+// mutex per path.
+var stateFiles sync.Map
+
+// lockStateFile locks path for an update and returns the unlock function.
+func lockStateFile(path string) func() {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	mu, _ := stateFiles.LoadOrStore(path, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
+
+// imageDefinitions returns the configuration of each entry of images: the
+// shared options, overridden by those of the entry. The build args of an
+// entry are added to the shared ones.
+func imageDefinitions(raw map[string]any) ([]map[string]any, error) {
+	items, ok := raw["images"].([]any)
+	if !ok {
+		return nil, fmt.Errorf("images must be a list of image definitions")
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("images is empty")
+	}
+
+	shared := maps.Clone(raw)
+	delete(shared, "images")
+	delete(shared, "images_parallel")
+	if err := checkParallel(raw, "images_parallel"); err != nil {
+		return nil, err
+	}
+
+	definitions := make([]map[string]any, 0, len(items))
+	seen := make(map[string]int)
+	for i, item := range items {
+		entry, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("images[%d] is not an object", i)
+		}
+		for _, key := range sortedKeys(entry) {
+			if !slices.Contains(imageDefinitionKeys, key) {
+				return nil, fmt.Errorf("images[%d]: option %s cannot be set per image (use %s)", i, key, strings.Join(imageDefinitionKeys, ", "))
+			}
+		}
+		image, _ := entry["image"].(string)
+		if image == "" {
+			return nil, fmt.Errorf("images[%d] has no image", i)
+		}
+		if j, ok := seen[image]; ok {
+			return nil, fmt.Errorf("images[%d] and images[%d] both release %s", j, i, image)
+		}
+		seen[image] = i
+
+		definitions = append(definitions, overlayDefinition(shared, entry))
+	}
+	return definitions, nil
+}
+
+// overlayDefinition returns the shared options overridden by those of
+// entry. The build args of entry are added to the shared ones.
+func overlayDefinition(shared, entry map[string]any) map[string]any {
+	definition := maps.Clone(shared)
+	for key, value := range entry {
+		definition[key] = value
+	}
+	if args, ok := entry["build_args"].(map[string]any); ok {
+		merged := make(map[string]any)
+		if sharedArgs, ok := shared["build_args"].(map[string]any); ok {
+			maps.Copy(merged, sharedArgs)
+		}
+		maps.Copy(merged, args)
+		definition["build_args"] = merged
+	}
+	return definition
+}
+
+// executeImages releases each image definition of images as if it were
+// configured alone, one after another, stopping at the first failure, or
+// all at once with images_parallel. The outputs of each image are reported
+// in images, and its warnings prefixed with the image.
+func (p *DockerPlugin) executeImages(ctx context.Context, req plugin.ExecuteRequest) (*plugin.ExecuteResponse, error) {
+	definitions, err := imageDefinitions(req.Config)
+	if err != nil {
+		return &plugin.ExecuteResponse{
+			Success: false,
+			Error:   fmt.Sprintf("invalid images: %v", err),
+		}, nil
+	}
+	labels := make([]string, len(definitions))
+	for i, definition := range definitions {
+		labels[i], _ = definition["image"].(string)
+	}
+	parallel, _ := req.Config["images_parallel"].(bool)
+	return p.executeDefinitions(ctx, req, "images", labels, definitions, parallel)
+}
+
+// executeDefinitions releases each of definitions, the configurations of
+// option labelled with labels, and combines their responses.
+func (p *DockerPlugin) executeDefinitions(ctx context.Context, req plugin.ExecuteRequest, option string, labels []string, definitions []map[string]any, parallel bool) (*plugin.ExecuteResponse, error) {
+	responses := make([]*plugin.ExecuteResponse, len(definitions))
+	run := func(i int) error {
+		sub := req
+		sub.Config = definitions[i]
+		resp, err := p.Execute(ctx, sub)
+		responses[i] = resp
+		return err
+	}
+	if parallel {
+		errs := make([]error, len(definitions))
+		var wg sync.WaitGroup
+		for i := range definitions {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs[i] = run(i)
+			}()
+		}
+		wg.Wait()
+		for _, err := range errs {
+			if err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		for i := range definitions {
+			if err := run(i); err != nil {
+				return nil, err
+			}
+			if !responses[i].Success {
+				break
+			}
+		}
+	}
+	return definitionsResponse(option, labels, responses), nil
+}
+
+// definitionsResponse combines the responses of the definitions of option,
+// reporting their outputs in option. Definitions that were not released
+// because an earlier one failed have no response.
+func definitionsResponse(option string, labels []string, responses []*plugin.ExecuteResponse) *plugin.ExecuteResponse {
+	combined := &plugin.ExecuteResponse{Success: true}
+	results := make([]map[string]any, 0, len(responses))
+	var warnings, messages, failures, skipped []string
+	pushed := true
+	for i, resp := range responses {
+		label := labels[i]
+		if resp == nil {
+			skipped = append(skipped, label)
+			pushed = false
+			continue
+		}
+		outputs := resp.Outputs
+		if outputs == nil {
+			outputs = map[string]any{}
+		}
+		results = append(results, outputs)
+		combined.Artifacts = append(combined.Artifacts, resp.Artifacts...)
+		if w, ok := outputs["warnings"].([]string); ok {
+			for _, warning := range w {
+				warnings = append(warnings, label+": "+warning)
+			}
+		}
+		if p, _ := outputs["pushed"].(bool); !p {
+			pushed = false
+		}
+		if !resp.Success {
+			combined.Success = false
+			failures = append(failures, label+": "+resp.Error)
+			continue
+		}
+		messages = append(messages, label+": "+resp.Message)
+	}
+
+	combined.Outputs = map[string]any{
+		"outputs_version": OutputsVersion,
+		option:            results,
+		"pushed":          pushed,
+	}
+	if len(warnings) > 0 {
+		combined.Outputs["warnings"] = warnings
+	}
+	if !combined.Success {
+		combined.Error = strings.Join(failures, "; ")
+		if len(skipped) > 0 {
+			combined.Error += "; not released: " + strings.Join(skipped, ", ")
+		}
+		return combined
+	}
+	combined.Message = fmt.Sprintf("Released %d %s: %s", len(responses), option, strings.Join(messages, "; "))
+	return combined
+}
+
+// validateImages validates each image definition of images as if it were
+// configured alone. Findings are reported under images[i].
+func (p *DockerPlugin) validateImages(ctx context.Context, config map[string]any) (*plugin.ValidateResponse, error) {
+	definitions, err := imageDefinitions(config)
+	return p.validateDefinitions(ctx, "images", definitions, err)
+}
+
+// validateDefinitions validates each of definitions, the configurations of
+// option, or reports err, the error of reading them.
+func (p *DockerPlugin) validateDefinitions(ctx context.Context, option string, definitions []map[string]any, err error) (*plugin.ValidateResponse, error) {
+	if err != nil {
+		return &plugin.ValidateResponse{
+			Valid:  false,
+			Errors: []plugin.ValidationError{{Field: option, Message: err.Error()}},
+		}, nil
+	}
+	combined := &plugin.ValidateResponse{Valid: true}
+	for i, definition := range definitions {
+		resp, err := p.Validate(ctx, definition)
+		if err != nil {
+			return nil, err
+		}
+		combined.Valid = combined.Valid && resp.Valid
+		for _, e := range resp.Errors {
+			e.Field = fmt.Sprintf("%s[%d].%s", option, i, e.Field)
+			combined.Errors = append(combined.Errors, e)
+		}
+	}
+	return combined, nil
+}
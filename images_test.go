@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestImageDefinitions(t *testing.T) {
+	definitions, err := imageDefinitions(map[string]any{
+		"registry":        "ghcr.io",
+		"build_args":      map[string]any{"GO_VERSION": "1.22", "CGO_ENABLED": "0"},
+		"images_parallel": true,
+		"images": []any{
+			map[string]any{"image": "myorg/api", "dockerfile": "api/Dockerfile", "context": "api"},
+			map[string]any{"image": "myorg/worker", "target": "worker", "build_args": map[string]any{"CGO_ENABLED": "1"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(definitions) != 2 {
+		t.Fatalf("expected 2 definitions, got %d", len(definitions))
+	}
+	api, worker := definitions[0], definitions[1]
+	if api["image"] != "myorg/api" || api["dockerfile"] != "api/Dockerfile" || api["registry"] != "ghcr.io" {
+		t.Errorf("expected the api definition over the shared options, got %v", api)
+	}
+	if _, ok := api["images"]; ok {
+		t.Errorf("expected images to be removed from the definitions")
+	}
+	args := worker["build_args"].(map[string]any)
+	if args["CGO_ENABLED"] != "1" || args["GO_VERSION"] != "1.22" {
+		t.Errorf("expected merged build args, got %v", args)
+	}
+
+	tests := []struct {
+		name    string
+		images  any
+		wantErr string
+	}{
+		{"not a list", "myorg/api", "must be a list"},
+		{"empty", []any{}, "empty"},
+		{"no image", []any{map[string]any{"dockerfile": "Dockerfile"}}, "has no image"},
+		{"shared option", []any{map[string]any{"image": "myorg/api", "push": false}}, "cannot be set per image"},
+		{"duplicate", []any{map[string]any{"image": "myorg/api"}, map[string]any{"image": "myorg/api"}}, "both release"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := imageDefinitions(map[string]any{"images": tt.images})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	for _, key := range []string{"audit_file", "failure_report"} {
+		raw := map[string]any{"images_parallel": true, key: "out/" + key + ".json", "images": []any{map[string]any{"image": "myorg/api"}}}
+		if _, err := imageDefinitions(raw); err == nil || !strings.Contains(err.Error(), key+" cannot be combined with images_parallel") {
+			t.Errorf("expected %s to be rejected with images_parallel, got %v", key, err)
+		}
+		raw["images_parallel"] = false
+		if _, err := imageDefinitions(raw); err != nil {
+			t.Errorf("expected %s without images_parallel to be accepted, got %v", key, err)
+		}
+	}
+}
+
+func TestExecuteImages(t *testing.T) {
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"push": false,
+			"images": []any{
+				map[string]any{"image": "myorg/api", "dockerfile": "api/Dockerfile", "context": "api"},
+				map[string]any{"image": "myorg/worker", "tags": []any{"{{version}}-worker"}},
+			},
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+
+	var builds [][]string
+	for _, call := range mock.RunCalls {
+		if len(call.Args) > 0 && call.Args[0] == "build" {
+			builds = append(builds, call.Args)
+		}
+	}
+	if len(builds) != 2 {
+		t.Fatalf("expected 2 builds, got %v", mock.RunCalls)
+	}
+	if !containsArg(builds[0], "-f", "api/Dockerfile") || builds[0][len(builds[0])-1] != "api" || !containsArg(builds[0], "-t", "myorg/api:1.0.0") {
+		t.Errorf("expected the api build, got %v", builds[0])
+	}
+	if !containsArg(builds[1], "-t", "myorg/worker:1.0.0-worker") {
+		t.Errorf("expected the worker build, got %v", builds[1])
+	}
+
+	images, ok := resp.Outputs["images"].([]map[string]any)
+	if !ok || len(images) != 2 || images[1]["image"] != "myorg/worker" {
+		t.Errorf("expected outputs of each image, got %v", resp.Outputs["images"])
+	}
+}
+
+func TestExecuteImagesStopsAtFailure(t *testing.T) {
+	mock := &MockCommandExecutor{
+		RunFunc: func(_ context.Context, _ string, args []string, _ io.Reader) error {
+			if slices.Contains(args, "myorg/api:1.0.0") {
+				return errors.New("build failed")
+			}
+			return nil
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"push":   false,
+			"images": []any{map[string]any{"image": "myorg/api"}, map[string]any{"image": "myorg/worker"}},
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success || !strings.Contains(resp.Error, "myorg/api: ") || !strings.Contains(resp.Error, "not released: myorg/worker") {
+		t.Errorf("expected the api failure and the worker not released, got %+v", resp)
+	}
+	for _, call := range mock.RunCalls {
+		if slices.Contains(call.Args, "myorg/worker:1.0.0") {
+			t.Errorf("expected the worker not to be built after the failure")
+		}
+	}
+}
+
+// lockedExecutor serializes the calls of a MockCommandExecutor for
+// parallel releases.
+type lockedExecutor struct {
+	mu   sync.Mutex
+	mock *MockCommandExecutor
+}
+
+func (e *lockedExecutor) Run(ctx context.Context, name string, args []string, stdin io.Reader) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.mock.Run(ctx, name, args, stdin)
+}
+
+func (e *lockedExecutor) RunCapture(ctx context.Context, name string, args []string, stdin io.Reader, stdout io.Writer) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.mock.RunCapture(ctx, name, args, stdin, stdout)
+}
+
+func (e *lockedExecutor) RunCaptureStderr(ctx context.Context, name string, args []string, stdin io.Reader, stderr io.Writer) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.mock.RunCaptureStderr(ctx, name, args, stdin, stderr)
+}
+
+func (e *lockedExecutor) Output(ctx context.Context, name string, args []string) ([]byte, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.mock.Output(ctx, name, args)
+}
+
+func (e *lockedExecutor) OutputInput(ctx context.Context, name string, args []string, stdin io.Reader) ([]byte, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.mock.OutputInput(ctx, name, args, stdin)
+}
+
+func TestExecuteImagesParallel(t *testing.T) {
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: &lockedExecutor{mock: mock}}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"push":            false,
+			"images_parallel": true,
+			"images":          []any{map[string]any{"image": "myorg/api"}, map[string]any{"image": "myorg/web"}, map[string]any{"image": "myorg/worker"}},
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+	images := resp.Outputs["images"].([]map[string]any)
+	for i, image := range []string{"myorg/api", "myorg/web", "myorg/worker"} {
+		if images[i]["image"] != image {
+			t.Errorf("expected outputs of %s at %d, got %v", image, i, images[i]["image"])
+		}
+	}
+}
+
+func TestValidateImages(t *testing.T) {
+	p := &DockerPlugin{}
+	resp, err := p.Validate(context.Background(), map[string]any{
+		"images": []any{map[string]any{"image": "myorg/api"}, map[string]any{"image": "INVALID IMAGE"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Valid {
+		t.Fatalf("expected the invalid image to fail validation")
+	}
+	if !slices.ContainsFunc(resp.Errors, func(e plugin.ValidationError) bool { return e.Field == "images[1].image" }) {
+		t.Errorf("expected an error on images[1].image, got %v", resp.Errors)
+	}
+}
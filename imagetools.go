@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// imageExists reports whether ref resolves in the registry.
+func (p *DockerPlugin) imageExists(ctx context.Context, ref string) bool {
+	_, err := p.getExecutor().Output(ctx, "docker", []string{"buildx", "imagetools", "inspect", ref})
+	return err == nil
+}
+
+// retagImage points every target reference at source without pulling it.
+// The copy happens registry-side, so multi-platform indexes are preserved.
+func (p *DockerPlugin) retagImage(ctx context.Context, source string, targets []string) error {
+	args := []string{"buildx", "imagetools", "create"}
+	for _, target := range targets {
+		args = append(args, "--tag", target)
+	}
+	args = append(args, source)
+	return p.getExecutor().Run(ctx, "docker", args, nil)
+}
+
+// resolveDigest returns the manifest (or index) digest ref currently points to.
+func (p *DockerPlugin) resolveDigest(ctx context.Context, ref string) (string, error) {
+	out, err := p.getExecutor().Output(ctx, "docker", []string{"buildx", "imagetools", "inspect", "--format", "{{.Manifest.Digest}}", ref})
+	if err != nil {
+		return "", err
+	}
+	digest := strings.TrimSpace(string(out))
+	if !strings.HasPrefix(digest, "sha256:") {
+		return "", fmt.Errorf("unexpected digest %q for %s", digest, ref)
+	}
+	return digest, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// defaultIndexTimeout bounds how long index assembly waits for the
+// per-platform images pushed by other jobs.
+const defaultIndexTimeout = 10 * time.Minute
+
+// indexPollInterval is the delay between checks for missing per-platform
+// images; replaced in tests.
+var indexPollInterval = 5 * time.Second
+
+// indexSourceRefs returns the per-platform image references merged into the
+// release index. index_sources are tags in the image repository and support
+// the same placeholders as tags.
+func indexSourceRefs(cfg *Config, version string) ([]string, error) {
+	if len(cfg.IndexSources) == 0 {
+		return nil, nil
+	}
+	tags, err := resolveTags(cfg.IndexSources, version, cfg.tagContext)
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) == 0 {
+		return nil, fmt.Errorf("every source renders empty for version %s", version)
+	}
+	repository := imageRepository(cfg)
+	refs := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		refs = append(refs, fmt.Sprintf("%s:%s", repository, tag))
+	}
+	return refs, nil
+}
+
+// validateIndexConfig checks settings for assembling an index from
+// per-platform images.
+func validateIndexConfig(cfg *Config) error {
+	if len(cfg.IndexSources) == 0 {
+		return nil
+	}
+	if !cfg.Push {
+		return fmt.Errorf("index_sources requires push")
+	}
+	if cfg.IndexTimeout != "" {
+		if _, err := time.ParseDuration(cfg.IndexTimeout); err != nil {
+			return fmt.Errorf("invalid index_timeout %q: %v", cfg.IndexTimeout, err)
+		}
+	}
+	return nil
+}
+
+// indexTimeout returns the configured index_timeout or the default.
+func indexTimeout(cfg *Config) time.Duration {
+	if timeout, err := time.ParseDuration(cfg.IndexTimeout); err == nil && timeout > 0 {
+		return timeout
+	}
+	return defaultIndexTimeout
+}
+
+// isManifestUnknown reports whether err means a referenced manifest is not
+// (yet) visible in the registry.
+func isManifestUnknown(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "manifest unknown") || strings.Contains(msg, "not found")
+}
+
+// missingManifests returns the refs that do not resolve in the registry yet.
+func (p *DockerPlugin) missingManifests(ctx context.Context, refs []string) []string {
+	var missing []string
+	for _, ref := range refs {
+		if !p.imageExists(ctx, ref) {
+			missing = append(missing, ref)
+		}
+	}
+	return missing
+}
+
+// assembleIndex merges the per-platform images in sources into one index
+// tagged with every target. Matrix jobs push those images concurrently, so
+// sources that are not visible yet are polled until they resolve, and a
+// create that still races a registry replica is retried, both until the
+// index timeout.
+func (p *DockerPlugin) assembleIndex(ctx context.Context, cfg *Config, sources, targets []string) error {
+	deadline := time.Now().Add(indexTimeout(cfg))
+	args := []string{"buildx", "imagetools", "create"}
+	for _, target := range targets {
+		args = append(args, "--tag", target)
+	}
+	args = append(args, sources...)
+
+	for {
+		missing := p.missingManifests(ctx, sources)
+		if len(missing) == 0 {
+			err := p.getExecutor().Run(ctx, "docker", args, nil)
+			if err == nil || !isManifestUnknown(err) {
+				return err
+			}
+			missing = sources
+		}
+
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("images not available after %s: %s", indexTimeout(cfg), strings.Join(missing, ", "))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(indexPollInterval):
+		}
+	}
+}
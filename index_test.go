@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// fastIndexPolling removes the delay between index source checks.
+func fastIndexPolling(t *testing.T) {
+	t.Helper()
+	orig := indexPollInterval
+	indexPollInterval = time.Millisecond
+	t.Cleanup(func() { indexPollInterval = orig })
+}
+
+func TestIndexSourceRefs(t *testing.T) {
+	cfg := &Config{Registry: "ghcr.io", Image: "org/app", IndexSources: []string{"{{version}}-amd64", "{{version}}-arm64"}}
+	refs, err := indexSourceRefs(cfg, "v1.2.3")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(refs, " ") != "ghcr.io/org/app:1.2.3-amd64 ghcr.io/org/app:1.2.3-arm64" {
+		t.Errorf("unexpected refs: %v", refs)
+	}
+
+	if refs, _ := indexSourceRefs(&Config{Image: "app"}, "1.0.0"); len(refs) != 0 {
+		t.Errorf("expected no refs without index_sources, got %v", refs)
+	}
+	if _, err := indexSourceRefs(&Config{Image: "app", IndexSources: []string{"{{prerelease}}"}}, "1.0.0"); err == nil {
+		t.Errorf("expected sources that render empty to be rejected")
+	}
+}
+
+func TestValidateIndexConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *Config
+		wantErr bool
+	}{
+		{"unset", &Config{}, false},
+		{"valid", &Config{Push: true, IndexSources: []string{"1.0-amd64"}, IndexTimeout: "2m"}, false},
+		{"without push", &Config{IndexSources: []string{"1.0-amd64"}}, true},
+		{"invalid timeout", &Config{Push: true, IndexSources: []string{"1.0-amd64"}, IndexTimeout: "soon"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateIndexConfig(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateIndexConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAssembleIndex(t *testing.T) {
+	ctx := context.Background()
+	sources := []string{"app:1.0-amd64", "app:1.0-arm64"}
+
+	t.Run("waits for sources", func(t *testing.T) {
+		fastIndexPolling(t)
+		checks := 0
+		mock := &MockCommandExecutor{
+			OutputFunc: func(_ context.Context, _ string, args []string) ([]byte, error) {
+				checks++
+				if args[len(args)-1] == "app:1.0-arm64" && checks < 5 {
+					return nil, errors.New("not found")
+				}
+				return nil, nil
+			},
+		}
+		p := &DockerPlugin{executor: mock}
+
+		if err := p.assembleIndex(ctx, &Config{}, sources, []string{"app:1.0", "app:latest"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(mock.RunCalls) != 1 {
+			t.Fatalf("expected one imagetools create, got %d", len(mock.RunCalls))
+		}
+		args := mock.RunCalls[0].Args
+		if !containsArg(args, "--tag", "app:1.0") || !containsArg(args, "--tag", "app:latest") {
+			t.Errorf("expected both release tags, got %v", args)
+		}
+		if strings.Join(args[len(args)-2:], " ") != "app:1.0-amd64 app:1.0-arm64" {
+			t.Errorf("expected sources last, got %v", args)
+		}
+	})
+
+	t.Run("retries create racing the registry", func(t *testing.T) {
+		fastIndexPolling(t)
+		calls := 0
+		mock := &MockCommandExecutor{
+			RunFunc: func(context.Context, string, []string, io.Reader) error {
+				calls++
+				if calls == 1 {
+					return errors.New("manifest unknown")
+				}
+				return nil
+			},
+		}
+		p := &DockerPlugin{executor: mock}
+
+		if err := p.assembleIndex(ctx, &Config{}, sources, []string{"app:1.0"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if calls != 2 {
+			t.Errorf("expected create to be retried once, got %d calls", calls)
+		}
+	})
+
+	t.Run("times out", func(t *testing.T) {
+		fastIndexPolling(t)
+		mock := &MockCommandExecutor{
+			OutputFunc: func(context.Context, string, []string) ([]byte, error) {
+				return nil, errors.New("not found")
+			},
+		}
+		p := &DockerPlugin{executor: mock}
+
+		err := p.assembleIndex(ctx, &Config{IndexTimeout: "20ms"}, sources, []string{"app:1.0"})
+		if err == nil || !strings.Contains(err.Error(), "app:1.0-arm64") {
+			t.Errorf("expected timeout naming the missing images, got %v", err)
+		}
+		if len(mock.RunCalls) != 0 {
+			t.Errorf("expected no create before sources resolve, got %d", len(mock.RunCalls))
+		}
+	})
+
+	t.Run("fails on other errors", func(t *testing.T) {
+		mock := &MockCommandExecutor{FailOnCall: 1, FailWithErr: errors.New("denied")}
+		p := &DockerPlugin{executor: mock}
+
+		if err := p.assembleIndex(ctx, &Config{}, sources, []string{"app:1.0"}); err == nil {
+			t.Error("expected error")
+		}
+		if len(mock.RunCalls) != 1 {
+			t.Errorf("expected no retry, got %d calls", len(mock.RunCalls))
+		}
+	})
+}
+
+func TestExecuteAssemblesIndex(t *testing.T) {
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":         "myapp",
+			"index_sources": []any{"{{version}}-amd64", "{{version}}-arm64"},
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+
+	for _, call := range mock.RunCalls {
+		if len(call.Args) > 0 && (call.Args[0] == "build" || call.Args[0] == "push") {
+			t.Errorf("expected no build or push, got %v", call.Args)
+		}
+	}
+	if resp.Outputs["pushed"] != true {
+		t.Errorf("expected pushed output, got %v", resp.Outputs["pushed"])
+	}
+	if !strings.Contains(resp.Message, "2 images") {
+		t.Errorf("unexpected message: %s", resp.Message)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// inputNamePattern matches the names of build contexts.
+var inputNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]*$`)
+
+// Input is an artifact of an earlier plugin of the release, such as the
+// binaries built by goreleaser, passed to the build as a named build
+// context.
+type Input struct {
+	Name string
+	// Path is the directory the earlier plugin wrote.
+	Path string
+	// BuildArg optionally passes Path to the build as this build arg.
+	BuildArg string
+}
+
+// parseInputs reads the inputs mapping of build context names to
+// directories. An input is a path, or an object with path and build_arg.
+func parseInputs(raw map[string]any) []Input {
+	items, ok := raw["inputs"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	inputs := make([]Input, 0, len(items))
+	for _, name := range sortedKeys(items) {
+		input := Input{Name: name}
+		switch v := items[name].(type) {
+		case string:
+			input.Path = v
+		case map[string]any:
+			input.Path, _ = v["path"].(string)
+			input.BuildArg, _ = v["build_arg"].(string)
+		}
+		inputs = append(inputs, input)
+	}
+	return inputs
+}
+
+// validateInputs checks the names, paths and build args of inputs.
+func validateInputs(cfg *Config) error {
+	for _, input := range cfg.Inputs {
+		if !inputNamePattern.MatchString(input.Name) {
+			return fmt.Errorf("invalid input name %q: use lowercase letters, digits, '.', '_' and '-'", input.Name)
+		}
+		if input.Path == "" {
+			return fmt.Errorf("input %s: path is required", input.Name)
+		}
+		if err := validatePath(input.Path); err != nil {
+			return fmt.Errorf("input %s: %v", input.Name, err)
+		}
+		if input.BuildArg != "" {
+			if err := validateBuildArgKey(input.BuildArg); err != nil {
+				return fmt.Errorf("input %s: %v", input.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// checkInputs fails when an input was not produced, e.g. because the plugin
+// writing it did not run before this one.
+func checkInputs(cfg *Config) error {
+	for _, input := range cfg.Inputs {
+		info, err := os.Stat(input.Path)
+		if os.IsNotExist(err) {
+			return fmt.Errorf("input %s: %s does not exist; the plugin producing it must run before this one", input.Name, input.Path)
+		}
+		if err != nil {
+			return fmt.Errorf("input %s: %v", input.Name, err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("input %s: %s is not a directory", input.Name, input.Path)
+		}
+	}
+	return nil
+}
+
+// inputArgs returns the build flags passing the inputs to the build.
+// Dockerfiles read them with COPY --from=<name>.
+func inputArgs(cfg *Config) []string {
+	var args []string
+	for _, input := range cfg.Inputs {
+		args = append(args, "--build-context", fmt.Sprintf("%s=%s", input.Name, input.Path))
+		if input.BuildArg != "" {
+			args = append(args, "--build-arg", fmt.Sprintf("%s=%s", input.BuildArg, input.Path))
+		}
+	}
+	return args
+}
+
+// hashInputs adds the files of every input to a source digest.
+func hashInputs(h io.Writer, cfg *Config) error {
+	for _, input := range cfg.Inputs {
+		fmt.Fprintf(h, "input %s\n", input.Name)
+		err := filepath.WalkDir(input.Path, func(path string, d fs.DirEntry, walkErr error) error {
+			if walkErr != nil {
+				return walkErr
+			}
+			if d.IsDir() {
+				return nil
+			}
+			rel, err := filepath.Rel(input.Path, path)
+			if err != nil {
+				return err
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(h, "file %s %o\n", filepath.ToSlash(rel), info.Mode().Perm())
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+			return hashFile(h, path)
+		})
+		if err != nil {
+			return fmt.Errorf("input %s: %w", input.Name, err)
+		}
+	}
+	return nil
+}
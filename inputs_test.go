@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestParseInputs(t *testing.T) {
+	inputs := parseInputs(map[string]any{
+		"inputs": map[string]any{
+			"frontend": "web/dist",
+			"binaries": map[string]any{"path": "dist", "build_arg": "BINARIES"},
+		},
+	})
+	if len(inputs) != 2 {
+		t.Fatalf("expected 2 inputs, got %+v", inputs)
+	}
+	if inputs[0] != (Input{Name: "binaries", Path: "dist", BuildArg: "BINARIES"}) {
+		t.Errorf("unexpected first input: %+v", inputs[0])
+	}
+	if inputs[1] != (Input{Name: "frontend", Path: "web/dist"}) {
+		t.Errorf("unexpected second input: %+v", inputs[1])
+	}
+}
+
+func TestValidateInputs(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   Input
+		wantErr string
+	}{
+		{"valid", Input{Name: "binaries", Path: "dist", BuildArg: "BINARIES"}, ""},
+		{"invalid name", Input{Name: "Dist", Path: "dist"}, "invalid input name"},
+		{"missing path", Input{Name: "dist"}, "path is required"},
+		{"invalid build arg", Input{Name: "dist", Path: "dist", BuildArg: "1X"}, "input dist"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateInputs(&Config{Inputs: []Input{tt.input}})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCheckInputs(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"dist/app": "binary", "notes.txt": "x"})
+
+	if err := checkInputs(&Config{Inputs: []Input{{Name: "dist", Path: filepath.Join(dir, "dist")}}}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	err := checkInputs(&Config{Inputs: []Input{{Name: "web", Path: filepath.Join(dir, "web")}}})
+	if err == nil || !strings.Contains(err.Error(), "must run before this one") {
+		t.Errorf("expected a missing input, got %v", err)
+	}
+	if err := checkInputs(&Config{Inputs: []Input{{Name: "notes", Path: filepath.Join(dir, "notes.txt")}}}); err == nil {
+		t.Error("expected a file input to be rejected")
+	}
+}
+
+func TestSourceDigestIncludesInputs(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"Dockerfile": "FROM alpine\n", "dist/app": "v1"})
+	chdir(t, dir)
+	cfg := &Config{Inputs: []Input{{Name: "dist", Path: "dist"}}}
+
+	first, err := computeSourceDigest(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	writeFiles(t, dir, map[string]string{"dist/app": "v2"})
+	second, err := computeSourceDigest(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first == second {
+		t.Error("expected a changed input to change the source digest")
+	}
+}
+
+func TestExecuteInputs(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"Dockerfile": "FROM alpine\n", "dist/app": "binary"})
+	chdir(t, dir)
+
+	t.Run("passed as build contexts", func(t *testing.T) {
+		mock := &MockCommandExecutor{}
+		p := &DockerPlugin{executor: mock}
+		resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+			Hook: plugin.HookPostPublish,
+			Config: map[string]any{
+				"image":  "myapp",
+				"inputs": map[string]any{"binaries": map[string]any{"path": "dist", "build_arg": "BINARIES"}},
+			},
+			Context: plugin.ReleaseContext{Version: "1.0.0"},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !resp.Success {
+			t.Fatalf("expected success, got error: %s", resp.Error)
+		}
+		build := mock.RunCalls[0].Args
+		if !containsArg(build, "--build-context", "binaries=dist") {
+			t.Errorf("expected the input as a build context, got %v", build)
+		}
+		if !containsArg(build, "--build-arg", "BINARIES=dist") {
+			t.Errorf("expected the input as a build arg, got %v", build)
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		mock := &MockCommandExecutor{
+			RunFunc: func(context.Context, string, []string, io.Reader) error { return nil },
+		}
+		p := &DockerPlugin{executor: mock}
+		resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+			Hook:    plugin.HookPostPublish,
+			Config:  map[string]any{"image": "myapp", "inputs": map[string]any{"frontend": "web/dist"}},
+			Context: plugin.ReleaseContext{Version: "1.0.0"},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.Success || !strings.Contains(resp.Error, "input frontend: web/dist does not exist") {
+			t.Fatalf("expected a missing input failure, got %+v", resp)
+		}
+		if len(mock.RunCalls) != 0 {
+			t.Errorf("expected nothing to be built, got %v", mock.RunCalls)
+		}
+	})
+}
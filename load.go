@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// loadPlatform returns the configured platform that runs natively on the
+// local docker daemon, which is the one loaded for local testing.
+func (p *DockerPlugin) loadPlatform(ctx context.Context, cfg *Config) (string, error) {
+	daemon := p.daemonPlatform(ctx)
+	if len(cfg.Platforms) == 0 {
+		return daemon, nil
+	}
+	for _, platform := range cfg.Platforms {
+		if normalizePlatform(platform) == normalizePlatform(daemon) {
+			return platform, nil
+		}
+	}
+	for _, platform := range cfg.Platforms {
+		if runsNatively(platform, []string{daemon}) {
+			return platform, nil
+		}
+	}
+	return "", fmt.Errorf("none of the platforms %s runs on the local daemon (%s)", strings.Join(cfg.Platforms, ", "), daemon)
+}
+
+// needsSeparateLoad reports whether load requires its own build: buildx
+// cannot load a multi-platform image, and a pushing build exports to the
+// registry only.
+func needsSeparateLoad(cfg *Config) bool {
+	return cfg.Load && useBuildx(cfg) && (cfg.Push || len(cfg.Platforms) > 1)
+}
+
+// loadImage builds the host-matching platform of the image into the local
+// daemon under the release tags. It runs before the pushing build, which
+// reuses its cache for that platform.
+func (p *DockerPlugin) loadImage(ctx context.Context, cfg *Config, names []string, releaseCtx plugin.ReleaseContext) (string, error) {
+	platform, err := p.loadPlatform(ctx, cfg)
+	if err != nil {
+		return "", err
+	}
+
+	loadCfg := *cfg
+	loadCfg.Platforms = []string{platform}
+	loadCfg.Push = false
+	if err := p.dockerBuild(ctx, &loadCfg, names, releaseCtx); err != nil {
+		return "", err
+	}
+	return platform, nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// daemonOn returns an OutputFunc reporting the docker daemon as platform.
+func daemonOn(platform string) func(context.Context, string, []string) ([]byte, error) {
+	return func(_ context.Context, _ string, args []string) ([]byte, error) {
+		if args[0] == "version" {
+			return []byte(platform + "\n"), nil
+		}
+		return nil, nil
+	}
+}
+
+func TestLoadPlatform(t *testing.T) {
+	tests := []struct {
+		name      string
+		daemon    string
+		platforms []string
+		want      string
+		wantErr   bool
+	}{
+		{"no platforms", "linux/amd64", nil, "linux/amd64", false},
+		{"exact match", "linux/arm64", []string{"linux/amd64", "linux/arm64/v8"}, "linux/arm64/v8", false},
+		{"compatible platform", "linux/amd64", []string{"linux/386", "linux/arm64"}, "linux/386", false},
+		{"no match", "linux/amd64", []string{"linux/arm64", "linux/arm/v7"}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &DockerPlugin{executor: &MockCommandExecutor{OutputFunc: daemonOn(tt.daemon)}}
+			got, err := p.loadPlatform(context.Background(), &Config{Platforms: tt.platforms})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadPlatform() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("loadPlatform() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadMultiPlatformBuild(t *testing.T) {
+	mock := &MockCommandExecutor{OutputFunc: daemonOn("linux/arm64")}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":     "myorg/myapp",
+			"builder":   "release",
+			"platforms": []any{"linux/amd64", "linux/arm64"},
+			"load":      true,
+		},
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got %s", resp.Error)
+	}
+
+	if len(mock.RunCalls) != 2 {
+		t.Fatalf("expected load build and pushing build, got %d calls", len(mock.RunCalls))
+	}
+	load, push := mock.RunCalls[0].Args, mock.RunCalls[1].Args
+	if !containsFlag(load, "--load") || containsFlag(load, "--push") || !containsArg(load, "--platform", "linux/arm64") {
+		t.Errorf("expected host-platform load build first, got %v", load)
+	}
+	if !containsFlag(push, "--push") || containsFlag(push, "--load") || !containsArg(push, "--platform", "linux/amd64,linux/arm64") {
+		t.Errorf("expected multi-platform push build, got %v", push)
+	}
+	if resp.Outputs["loaded_platform"] != "linux/arm64" {
+		t.Errorf("expected loaded_platform linux/arm64, got %v", resp.Outputs["loaded_platform"])
+	}
+}
+
+func TestLoadSinglePlatformWithoutPush(t *testing.T) {
+	mock := &MockCommandExecutor{OutputFunc: daemonOn("linux/amd64")}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":     "myorg/myapp",
+			"builder":   "release",
+			"platforms": []any{"linux/amd64"},
+			"load":      true,
+			"push":      false,
+		},
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+	})
+	if err != nil || !resp.Success {
+		t.Fatalf("unexpected failure: %v %+v", err, resp)
+	}
+	if len(mock.RunCalls) != 1 || !containsFlag(mock.RunCalls[0].Args, "--load") {
+		t.Errorf("expected a single --load build, got %v", mock.RunCalls)
+	}
+}
+
+func TestLoadWithoutNativePlatform(t *testing.T) {
+	mock := &MockCommandExecutor{OutputFunc: daemonOn("linux/amd64")}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":     "myorg/myapp",
+			"builder":   "release",
+			"platforms": []any{"linux/arm64", "linux/arm/v7"},
+			"load":      true,
+		},
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success || !strings.Contains(resp.Error, "local daemon") {
+		t.Errorf("expected load failure, got %+v", resp)
+	}
+	if len(mock.RunCalls) != 0 {
+		t.Errorf("expected nothing to be built, got %d calls", len(mock.RunCalls))
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net"
+	"strings"
+)
+
+// isLocalRegistry reports whether registry runs on the local machine, such
+// as the localhost:5000 registries of kind, k3d or a docker-in-docker
+// service. Ports are optional.
+func isLocalRegistry(registry string) bool {
+	host := registry
+	if h, _, err := net.SplitHostPort(registry); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// isLocalRegistryOf reports whether registry was detected as local when the
+// configuration was parsed.
+func isLocalRegistryOf(cfg *Config, registry string) bool {
+	for _, r := range cfg.localRegistries {
+		if r == registry {
+			return true
+		}
+	}
+	return false
+}
+
+// skipLogin reports whether registry login is skipped: local registries
+// accept anonymous pushes, so CI credentials meant for a remote registry are
+// not sent to them unless login_local_registry is set.
+func skipLogin(cfg *Config) bool {
+	return isLocalRegistryOf(cfg, cfg.Registry) && !cfg.LoginLocalRegistry
+}
+
+// splitImageRegistry splits a registry off the image name, as in
+// localhost:5000/app or registry.example.com/app. As in docker references,
+// the first path component is a registry when it contains a dot or a port
+// or is localhost; otherwise it is a namespace and the image is returned
+// unchanged.
+func splitImageRegistry(image string) (registry, name string) {
+	first, rest, ok := strings.Cut(image, "/")
+	if !ok || !(strings.ContainsAny(first, ".:") || first == "localhost") {
+		return "", image
+	}
+	return first, rest
+}
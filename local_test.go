@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestIsLocalRegistry(t *testing.T) {
+	tests := []struct {
+		registry string
+		expected bool
+	}{
+		{"localhost", true},
+		{"localhost:5000", true},
+		{"registry.localhost:5001", true},
+		{"127.0.0.1:5000", true},
+		{"[::1]:5000", true},
+		{"ghcr.io", false},
+		{"registry.example.com:5000", false},
+		{"10.0.0.1:5000", false},
+	}
+
+	for _, tt := range tests {
+		if got := isLocalRegistry(tt.registry); got != tt.expected {
+			t.Errorf("isLocalRegistry(%q) = %v, want %v", tt.registry, got, tt.expected)
+		}
+	}
+}
+
+func TestParseConfigSplitsRegistryFromImage(t *testing.T) {
+	p := &DockerPlugin{}
+
+	cfg := p.parseConfig(map[string]any{"image": "localhost:5000/team/app"})
+	if cfg.Registry != "localhost:5000" || cfg.Image != "team/app" {
+		t.Errorf("expected localhost:5000 and team/app, got %s and %s", cfg.Registry, cfg.Image)
+	}
+	if !isLocalRegistryOf(cfg, cfg.Registry) {
+		t.Error("expected localhost:5000 to be detected as local")
+	}
+
+	cfg = p.parseConfig(map[string]any{"image": "team/app"})
+	if cfg.Registry != "docker.io" || cfg.Image != "team/app" {
+		t.Errorf("expected image without port to be unchanged, got %s and %s", cfg.Registry, cfg.Image)
+	}
+
+	cfg = p.parseConfig(map[string]any{"image": "ghcr.io/org/app"})
+	if cfg.Registry != "docker.io" || cfg.Image != "ghcr.io/org/app" {
+		t.Errorf("expected a remote registry to stay part of the image, got %s and %s", cfg.Registry, cfg.Image)
+	}
+
+	cfg = p.parseConfig(map[string]any{"image": "app", "registry": "https://localhost:5000"})
+	if isLocalRegistryOf(cfg, cfg.Registry) {
+		t.Error("expected explicit https:// to disable local registry handling")
+	}
+}
+
+func TestExecuteSkipsLoginToLocalRegistry(t *testing.T) {
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":    "localhost:5000/app",
+			"username": "ci",
+			"password": "secret",
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+
+	for _, call := range mock.RunCalls {
+		if call.Args[0] == "login" {
+			t.Errorf("expected no login to local registry, got %v", call.Args)
+		}
+	}
+	refs, _ := resp.Outputs["refs"].([]string)
+	if len(refs) == 0 || refs[0] != "localhost:5000/app:1.0.0" {
+		t.Errorf("unexpected refs: %v", refs)
+	}
+	warnings, _ := resp.Outputs["warnings"].([]string)
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "login_local_registry") {
+		t.Errorf("expected skipped login warning, got %v", warnings)
+	}
+}
+
+func TestExecuteLoginLocalRegistry(t *testing.T) {
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":                "app",
+			"registry":             "localhost:5000",
+			"username":             "ci",
+			"password":             "secret",
+			"login_local_registry": true,
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+	if len(mock.RunCalls) == 0 || mock.RunCalls[0].Args[0] != "login" {
+		t.Fatalf("expected login first, got %v", mock.RunCalls)
+	}
+}
+
+func TestRegistryClientFallsBackToHTTPForLocalRegistry(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"harbor_version":"v2.10.0"}`))
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	p := &DockerPlugin{}
+	cfg := p.parseConfig(map[string]any{"image": "app", "registry": host})
+	var info struct {
+		HarborVersion string `json:"harbor_version"`
+	}
+	if _, err := p.newRegistryClient(cfg).getJSON(context.Background(), "/api/v2.0/systeminfo", &info); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.HarborVersion != "v2.10.0" {
+		t.Errorf("expected response over plaintext HTTP, got %+v", info)
+	}
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultLockStaleAfter is the age after which a release lock is considered
+// abandoned, e.g. by a runner that was killed mid-release.
+const defaultLockStaleAfter = time.Hour
+
+// lockPollInterval is the delay between attempts to take a held lock;
+// replaced in tests.
+var lockPollInterval = 5 * time.Second
+
+// ReleaseLock serializes releases of the same image through a lock file in a directory shared by the runners.
+type ReleaseLock struct {
+	// Dir holds the lock files; the system temporary directory when empty.
+	Dir        string
+	StaleAfter string
+	// Wait is how long to wait for a held lock before failing.
+	Wait string
+	// Force takes over a lock that is held and not stale.
+	Force bool
+}
+
+// lockHolder is the content of a lock file.
+type lockHolder struct {
+	Refs       []string  `json:"refs"`
+	Version    string    `json:"version"`
+	Host       string    `json:"host"`
+	PID        int       `json:"pid"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	RenewedAt  time.Time `json:"renewed_at,omitempty"`
+	// Token identifies the holder, so that a release only renews and
+	// removes a lock it still holds.
+	Token string `json:"token,omitempty"`
+}
+
+// renewedAt returns when the holder last showed it was alive.
+func (h lockHolder) renewedAt() time.Time {
+	if h.RenewedAt.After(h.AcquiredAt) {
+		return h.RenewedAt
+	}
+	return h.AcquiredAt
+}
+
+func (h lockHolder) String() string {
+	return fmt.Sprintf("release %s on %s (pid %d) since %s", h.Version, h.Host, h.PID, h.AcquiredAt.Format(time.RFC3339))
+}
+
+// parseReleaseLock reads the release_lock option, returning nil when it is
+// not set. true enables the lock with the defaults.
+func parseReleaseLock(raw map[string]any) *ReleaseLock {
+	switch v := raw["release_lock"].(type) {
+	case bool:
+		if v {
+			return &ReleaseLock{}
+		}
+	case map[string]any:
+		lock := &ReleaseLock{}
+		lock.Dir, _ = v["dir"].(string)
+		lock.StaleAfter, _ = v["stale_after"].(string)
+		lock.Wait, _ = v["wait"].(string)
+		lock.Force, _ = v["force"].(bool)
+		return lock
+	}
+	return nil
+}
+
+// validateReleaseLock checks the release_lock durations.
+func validateReleaseLock(cfg *Config) error {
+	lock := cfg.ReleaseLock
+	if lock == nil {
+		return nil
+	}
+	for _, d := range []struct {
+		name  string
+		value string
+	}{
+		{"stale_after", lock.StaleAfter},
+		{"wait", lock.Wait},
+	} {
+		if d.value == "" {
+			continue
+		}
+		if v, err := time.ParseDuration(d.value); err != nil || v < 0 {
+			return fmt.Errorf("%s must be a duration such as 30m", d.name)
+		}
+	}
+	return nil
+}
+
+// lockPath returns the lock file of a release pushing refs. The name is
+// derived from the sorted image repositories of refs, not from their tags,
+// so every release of the same image contends for the same file, whatever
+// its version.
+func lockPath(lock *ReleaseLock, refs []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(refRepositories(refs), "\n")))
+	dir := lock.Dir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "relicta-docker-"+hex.EncodeToString(sum[:8])+".lock")
+}
+
+// refRepositories returns the sorted, distinct repositories of refs.
+func refRepositories(refs []string) []string {
+	seen := make(map[string]bool)
+	var repositories []string
+	for _, ref := range refs {
+		repository, _, _ := strings.Cut(ref, "@")
+		if i := strings.LastIndex(repository, ":"); i > strings.LastIndex(repository, "/") {
+			repository = repository[:i]
+		}
+		if !seen[repository] {
+			seen[repository] = true
+			repositories = append(repositories, repository)
+		}
+	}
+	sort.Strings(repositories)
+	return repositories
+}
+
+// acquireReleaseLock takes the release lock for refs, waiting up to the
+// configured wait for a holder to finish. Stale locks, and held locks with
+// force, are taken over with a warning. While held, the lock is renewed so
+// that a long release does not turn stale. The returned function stops the
+// renewal and removes the lock file, unless another release took it over.
+func acquireReleaseLock(ctx context.Context, cfg *Config, refs []string, version string) (func(), string, error) {
+	lock := cfg.ReleaseLock
+	path := lockPath(lock, refs)
+	staleAfter := defaultLockStaleAfter
+	if d, err := time.ParseDuration(lock.StaleAfter); err == nil && d > 0 {
+		staleAfter = d
+	}
+	var wait time.Duration
+	if d, err := time.ParseDuration(lock.Wait); err == nil {
+		wait = d
+	}
+
+	host, _ := os.Hostname()
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, "", err
+	}
+	self := lockHolder{Refs: refs, Version: version, Host: host, PID: os.Getpid(), Token: hex.EncodeToString(token)}
+	deadline := time.Now().Add(wait)
+	for {
+		self.AcquiredAt = time.Now().UTC()
+		err := writeLockFile(path, self)
+		if err == nil {
+			return holdReleaseLock(path, self, staleAfter), "", nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, "", fmt.Errorf("failed to create lock file %s: %w", path, err)
+		}
+
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			// Released in between.
+			continue
+		}
+		// A lock file that cannot be read ages by its modification time.
+		var holder lockHolder
+		readErr := err
+		if readErr == nil {
+			readErr = json.Unmarshal(data, &holder)
+		}
+		renewedAt := holder.renewedAt()
+		if readErr != nil {
+			if info, err := os.Stat(path); err == nil {
+				renewedAt = info.ModTime()
+			}
+		}
+		stale := !renewedAt.IsZero() && time.Since(renewedAt) > staleAfter
+		if stale || lock.Force {
+			taken, err := takeOverLockFile(path, data, self)
+			if err != nil {
+				return nil, "", err
+			}
+			if !taken {
+				// Another release renewed or took over the lock first.
+				continue
+			}
+			reason := "stale"
+			if !stale {
+				reason = "held"
+			}
+			previous := path
+			if readErr == nil {
+				previous = holder.String()
+			}
+			return holdReleaseLock(path, self, staleAfter), fmt.Sprintf("took over %s release lock of %s", reason, previous), nil
+		}
+
+		if !time.Now().Before(deadline) {
+			if readErr != nil {
+				return nil, "", fmt.Errorf("release lock %s is held", path)
+			}
+			return nil, "", fmt.Errorf("release lock %s is held by %s", path, holder)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, "", ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+// takeOverLockFile replaces the lock file at path, which read as seen, with
+// a lock of self. The file is first moved aside, which only one release
+// can do; when what was moved is no longer what was seen, because the
+// holder renewed the lock or another release took it over in between, it
+// is put back and takeOverLockFile reports that the lock was not taken.
+func takeOverLockFile(path string, seen []byte, self lockHolder) (bool, error) {
+	aside := path + "." + self.Token
+	if err := os.Rename(path, aside); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to take over lock file %s: %w", path, err)
+	}
+	moved, err := os.ReadFile(aside)
+	if err != nil || !bytes.Equal(moved, seen) {
+		// Link, unlike rename, never replaces a lock created meanwhile.
+		os.Link(aside, path)
+		os.Remove(aside)
+		return false, nil
+	}
+	os.Remove(aside)
+	if err := writeLockFile(path, self); err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to take over lock file %s: %w", path, err)
+	}
+	return true, nil
+}
+
+// holdReleaseLock renews the lock of self at path until the returned
+// function releases it. It renews often enough that the lock never ages
+// past staleAfter while held, and stops once another release took it over.
+func holdReleaseLock(path string, self lockHolder, staleAfter time.Duration) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(staleAfter / 4)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				self.RenewedAt = time.Now().UTC()
+				if !renewLockFile(path, self) {
+					return
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-stopped
+			if holder, err := readLockFile(path); err == nil && holder.Token == self.Token {
+				os.Remove(path)
+			}
+		})
+	}
+}
+
+// renewLockFile rewrites the lock file with self, reporting false when it
+// is no longer held by self.
+func renewLockFile(path string, self lockHolder) bool {
+	if holder, err := readLockFile(path); err != nil || holder.Token != self.Token {
+		return false
+	}
+	data, err := json.Marshal(self)
+	if err != nil {
+		return false
+	}
+	tmp := path + "." + self.Token + ".renew"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return false
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return false
+	}
+	return true
+}
+
+// writeLockFile creates the lock file exclusively.
+func writeLockFile(path string, holder lockHolder) error {
+	data, err := json.Marshal(holder)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+// readLockFile returns the holder recorded in the lock file.
+func readLockFile(path string) (lockHolder, error) {
+	var holder lockHolder
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return holder, err
+	}
+	err = json.Unmarshal(data, &holder)
+	return holder, err
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestParseReleaseLock(t *testing.T) {
+	if parseReleaseLock(map[string]any{}) != nil {
+		t.Error("expected no lock by default")
+	}
+	if parseReleaseLock(map[string]any{"release_lock": false}) != nil {
+		t.Error("expected release_lock: false to disable the lock")
+	}
+	if lock := parseReleaseLock(map[string]any{"release_lock": true}); lock == nil || lock.Dir != "" {
+		t.Errorf("expected release_lock: true to use the defaults, got %+v", lock)
+	}
+	lock := parseReleaseLock(map[string]any{"release_lock": map[string]any{"dir": "/shared/locks", "wait": "10m", "force": true}})
+	if lock == nil || lock.Dir != "/shared/locks" || lock.Wait != "10m" || !lock.Force {
+		t.Errorf("unexpected lock: %+v", lock)
+	}
+}
+
+func TestLockPathPerRepository(t *testing.T) {
+	lock := &ReleaseLock{Dir: "/locks"}
+	a := lockPath(lock, []string{"app:1.0.0", "app:latest"})
+	if b := lockPath(lock, []string{"app:latest", "app:1.0.0"}); a != b {
+		t.Errorf("expected the same lock file for the same refs, got %s and %s", a, b)
+	}
+	if b := lockPath(lock, []string{"app:1.0.1", "app:latest", "app@sha256:abc"}); a != b {
+		t.Errorf("expected releases of the same image to share the lock file, got %s and %s", a, b)
+	}
+	if c := lockPath(lock, []string{"localhost:5000/app:1.0.0"}); c == a {
+		t.Error("expected a different lock file for a different repository")
+	}
+}
+
+func TestAcquireReleaseLock(t *testing.T) {
+	dir := t.TempDir()
+	refs := []string{"app:1.0.0", "app:latest"}
+	cfg := &Config{ReleaseLock: &ReleaseLock{Dir: dir}}
+
+	unlock, warning, err := acquireReleaseLock(context.Background(), cfg, refs, "1.0.0")
+	if err != nil || warning != "" {
+		t.Fatalf("expected lock, got warning %q, error %v", warning, err)
+	}
+
+	if _, _, err := acquireReleaseLock(context.Background(), cfg, refs, "1.0.0"); err == nil || !strings.Contains(err.Error(), "held by release 1.0.0") {
+		t.Errorf("expected held lock error, got %v", err)
+	}
+
+	forced := &Config{ReleaseLock: &ReleaseLock{Dir: dir, Force: true}}
+	unlockForced, warning, err := acquireReleaseLock(context.Background(), forced, refs, "1.0.0")
+	if err != nil || !strings.Contains(warning, "took over held release lock") {
+		t.Fatalf("expected forced takeover, got warning %q, error %v", warning, err)
+	}
+	unlockForced()
+	unlock()
+
+	if _, err := os.Stat(lockPath(cfg.ReleaseLock, refs)); !os.IsNotExist(err) {
+		t.Errorf("expected lock file to be removed, got %v", err)
+	}
+}
+
+func TestAcquireReleaseLockStale(t *testing.T) {
+	dir := t.TempDir()
+	refs := []string{"app:1.0.0"}
+	cfg := &Config{ReleaseLock: &ReleaseLock{Dir: dir, StaleAfter: "1m"}}
+
+	stale := lockHolder{Refs: refs, Version: "0.9.0", Host: "runner-1", PID: 42, AcquiredAt: time.Now().Add(-time.Hour)}
+	if err := writeLockFile(lockPath(cfg.ReleaseLock, refs), stale); err != nil {
+		t.Fatal(err)
+	}
+
+	unlock, warning, err := acquireReleaseLock(context.Background(), cfg, refs, "1.0.0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer unlock()
+	if !strings.Contains(warning, "took over stale release lock of release 0.9.0 on runner-1") {
+		t.Errorf("unexpected warning: %q", warning)
+	}
+}
+
+func TestAcquireReleaseLockWaits(t *testing.T) {
+	orig := lockPollInterval
+	lockPollInterval = time.Millisecond
+	t.Cleanup(func() { lockPollInterval = orig })
+
+	dir := t.TempDir()
+	refs := []string{"app:1.0.0"}
+	cfg := &Config{ReleaseLock: &ReleaseLock{Dir: dir, Wait: "5s"}}
+
+	unlock, _, err := acquireReleaseLock(context.Background(), cfg, refs, "1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(20*time.Millisecond, unlock)
+
+	unlockNext, _, err := acquireReleaseLock(context.Background(), cfg, refs, "1.0.0")
+	if err != nil {
+		t.Fatalf("expected lock after the holder finished, got %v", err)
+	}
+	unlockNext()
+}
+
+func TestExecuteReleaseLockHeld(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &Config{ReleaseLock: &ReleaseLock{Dir: dir}}
+	unlock, _, err := acquireReleaseLock(context.Background(), cfg, []string{"myapp:1.0.0", "myapp:latest"}, "1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock}
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"image": "myapp", "release_lock": map[string]any{"dir": dir}},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success || !strings.Contains(resp.Error, "failed to acquire release lock") {
+		t.Fatalf("expected lock failure, got %+v", resp)
+	}
+	if len(mock.RunCalls) != 0 {
+		t.Errorf("expected no commands while the lock is held, got %v", mock.RunCalls)
+	}
+
+	unlock()
+	resp, err = p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"image": "myapp", "release_lock": map[string]any{"dir": dir}},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil || !resp.Success {
+		t.Fatalf("expected success once the lock is free, got %+v, %v", resp, err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected the lock to be released after the release, got %v", entries)
+	}
+}
+
+func TestReleaseLockKeepsTakenOverLock(t *testing.T) {
+	dir := t.TempDir()
+	refs := []string{"app:1.0.0"}
+	unlock, _, err := acquireReleaseLock(context.Background(), &Config{ReleaseLock: &ReleaseLock{Dir: dir}}, refs, "1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	forced := &Config{ReleaseLock: &ReleaseLock{Dir: dir, Force: true}}
+	unlockForced, _, err := acquireReleaseLock(context.Background(), forced, refs, "1.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlockForced()
+
+	unlock()
+	holder, err := readLockFile(lockPath(forced.ReleaseLock, refs))
+	if err != nil || holder.Version != "1.0.1" {
+		t.Errorf("expected the lock taken over by 1.0.1 to remain, got %+v, %v", holder, err)
+	}
+}
+
+func TestReleaseLockRenews(t *testing.T) {
+	dir := t.TempDir()
+	refs := []string{"app:1.0.0"}
+	cfg := &Config{ReleaseLock: &ReleaseLock{Dir: dir, StaleAfter: "800ms"}}
+	unlock, _, err := acquireReleaseLock(context.Background(), cfg, refs, "1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+
+	time.Sleep(1200 * time.Millisecond)
+	if _, _, err := acquireReleaseLock(context.Background(), cfg, refs, "1.0.1"); err == nil || !strings.Contains(err.Error(), "held by release 1.0.0") {
+		t.Errorf("expected the renewed lock to stay held, got %v", err)
+	}
+	holder, err := readLockFile(lockPath(cfg.ReleaseLock, refs))
+	if err != nil || !holder.RenewedAt.After(holder.AcquiredAt) {
+		t.Errorf("expected the lock to be renewed, got %+v, %v", holder, err)
+	}
+}
+
+func TestAcquireReleaseLockStaleTakeoverIsExclusive(t *testing.T) {
+	dir := t.TempDir()
+	refs := []string{"app:1.0.0"}
+	cfg := &Config{ReleaseLock: &ReleaseLock{Dir: dir, StaleAfter: "1m"}}
+	stale := lockHolder{Refs: refs, Version: "0.9.0", Host: "runner-1", PID: 42, AcquiredAt: time.Now().Add(-time.Hour)}
+	if err := writeLockFile(lockPath(cfg.ReleaseLock, refs), stale); err != nil {
+		t.Fatal(err)
+	}
+
+	const releases = 8
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var unlocks []func()
+	for i := 0; i < releases; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if unlock, _, err := acquireReleaseLock(context.Background(), cfg, refs, "1.0.0"); err == nil {
+				mu.Lock()
+				unlocks = append(unlocks, unlock)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	for _, unlock := range unlocks {
+		unlock()
+	}
+	if len(unlocks) != 1 {
+		t.Errorf("expected exactly one release to take over the stale lock, got %d", len(unlocks))
+	}
+}
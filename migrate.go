@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// deprecatedCode marks deprecation notices in ValidateResponse.Errors. They
+// do not make the configuration invalid.
+const deprecatedCode = "deprecated"
+
+// legacyOptions maps option spellings from v1 pipeline definitions to their
+// canonical names.
+var legacyOptions = map[string]string{
+	"buildArgs":       "build_args",
+	"cacheFrom":       "cache_from",
+	"noCache":         "no_cache",
+	"dockerfile_path": "dockerfile",
+	"dockerfilePath":  "dockerfile",
+	"build_context":   "context",
+	"contextPath":     "context",
+	"image_name":      "image",
+	"imageName":       "image",
+	"registry_url":    "registry",
+	"registryUrl":     "registry",
+}
+
+// deprecation describes a legacy option found in the configuration.
+type deprecation struct {
+	option  string
+	message string
+}
+
+// migrateConfig returns a copy of raw with legacy option names replaced by
+// their canonical names, and a deprecation for every legacy option in
+// alphabetical order. When both spellings are set the canonical one wins.
+func migrateConfig(raw map[string]any) (map[string]any, []deprecation) {
+	canonical := make(map[string]any, len(raw))
+	var legacyKeys []string
+	for key, value := range raw {
+		if _, legacy := legacyOptions[key]; legacy {
+			legacyKeys = append(legacyKeys, key)
+		} else {
+			canonical[key] = value
+		}
+	}
+	sort.Strings(legacyKeys)
+
+	deprecations := make([]deprecation, 0, len(legacyKeys))
+	for _, key := range legacyKeys {
+		name := legacyOptions[key]
+		if _, set := canonical[name]; set {
+			deprecations = append(deprecations, deprecation{key, fmt.Sprintf("option %s is deprecated and ignored because %s is also set", key, name)})
+			continue
+		}
+		canonical[name] = raw[key]
+		deprecations = append(deprecations, deprecation{key, fmt.Sprintf("option %s is deprecated; use %s", key, name)})
+	}
+	return canonical, deprecations
+}
+
+// deprecationMessages returns the messages of deprecations.
+func deprecationMessages(deprecations []deprecation) []string {
+	messages := make([]string, 0, len(deprecations))
+	for _, d := range deprecations {
+		messages = append(messages, d.message)
+	}
+	return messages
+}
+
+// addDeprecations appends deprecation notices to a validation response
+// without affecting its validity.
+func addDeprecations(resp *plugin.ValidateResponse, deprecations []deprecation) {
+	for _, d := range deprecations {
+		resp.Errors = append(resp.Errors, plugin.ValidationError{
+			Field:   d.option,
+			Message: d.message,
+			Code:    deprecatedCode,
+		})
+	}
+}
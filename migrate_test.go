@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestMigrateConfig(t *testing.T) {
+	canonical, deprecations := migrateConfig(map[string]any{
+		"image":           "myapp",
+		"buildArgs":       map[string]any{"GO_VERSION": "1.22"},
+		"dockerfile_path": "build/Dockerfile",
+		"cacheFrom":       []any{"myapp:cache"},
+		"cache_from":      []any{"myapp:buildcache"},
+	})
+
+	if _, ok := canonical["buildArgs"]; ok {
+		t.Error("expected legacy key to be removed")
+	}
+	if canonical["dockerfile"] != "build/Dockerfile" {
+		t.Errorf("expected dockerfile to be migrated, got %v", canonical["dockerfile"])
+	}
+	if cache := canonical["cache_from"].([]any); cache[0] != "myapp:buildcache" {
+		t.Errorf("expected canonical cache_from to win, got %v", cache)
+	}
+
+	if len(deprecations) != 3 {
+		t.Fatalf("expected 3 deprecations, got %v", deprecations)
+	}
+	if deprecations[0].option != "buildArgs" || !strings.Contains(deprecations[0].message, "use build_args") {
+		t.Errorf("unexpected deprecation: %+v", deprecations[0])
+	}
+	if deprecations[1].option != "cacheFrom" || !strings.Contains(deprecations[1].message, "ignored") {
+		t.Errorf("unexpected deprecation: %+v", deprecations[1])
+	}
+}
+
+func TestLegacyOptionsInValidate(t *testing.T) {
+	p := &DockerPlugin{}
+	resp, err := p.Validate(context.Background(), map[string]any{"imageName": "myapp"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Valid {
+		t.Fatalf("expected legacy image option to satisfy image, got %+v", resp.Errors)
+	}
+	if len(resp.Errors) != 1 || resp.Errors[0].Code != deprecatedCode || resp.Errors[0].Field != "imageName" {
+		t.Errorf("expected a deprecation notice, got %+v", resp.Errors)
+	}
+}
+
+func TestLegacyOptionsDryRun(t *testing.T) {
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":    "myapp",
+			"noCache":  true,
+			"password": "hunter2",
+		},
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+		DryRun:  true,
+	})
+	if err != nil || !resp.Success {
+		t.Fatalf("unexpected failure: %v %+v", err, resp)
+	}
+
+	canonical, ok := resp.Outputs["canonical_config"].(map[string]any)
+	if !ok {
+		t.Fatalf("expected canonical_config output, got %v", resp.Outputs)
+	}
+	if canonical["no_cache"] != true || canonical["password"] != auditRedacted {
+		t.Errorf("unexpected canonical config: %v", canonical)
+	}
+	warnings, _ := resp.Outputs["warnings"].([]string)
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "noCache") {
+		t.Errorf("expected deprecation warning, got %v", warnings)
+	}
+}
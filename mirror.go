@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"time"
+)
+
+// Mirror is an additional registry that receives a subset of the release tags.
+type Mirror struct {
+	Registry string
+	Image    string
+	// Tags are glob patterns selecting the resolved release tags to mirror,
+	// e.g. [0-9]* for version tags only. All tags are mirrored when empty.
+	Tags     []string
+	Username string
+	Password string
+}
+
+// MirrorResult records the references pushed to one mirror.
+type MirrorResult struct {
+	Registry string   `json:"registry"`
+	Refs     []string `json:"refs"`
+}
+
+// parseMirrors reads the mirrors list. A mirror's password may be given
+// directly or through password_env.
+func parseMirrors(raw map[string]any) []Mirror {
+	return parseMirrorList(raw, "mirrors", "registry")
+}
+
+// parseRegistries reads the registries list, whose entries name their
+// registry with url.
+func parseRegistries(raw map[string]any) []Mirror {
+	return parseMirrorList(raw, "registries", "url")
+}
+
+// parseMirrorList reads a list of registries from option, naming the
+// registry of an entry with registryKey.
+func parseMirrorList(raw map[string]any, option, registryKey string) []Mirror {
+	items, ok := raw[option].([]any)
+	if !ok {
+		return nil
+	}
+
+	mirrors := make([]Mirror, 0, len(items))
+	for _, item := range items {
+		m, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		mirror := Mirror{}
+		mirror.Registry, _ = m[registryKey].(string)
+		mirror.Image, _ = m["image"].(string)
+		mirror.Username, _ = m["username"].(string)
+		mirror.Password, _ = m["password"].(string)
+		if env, ok := m["password_env"].(string); ok && mirror.Password == "" {
+			mirror.Password = os.Getenv(env)
+		}
+		if tags, ok := m["tags"].([]any); ok {
+			for _, tag := range tags {
+				if s, ok := tag.(string); ok {
+					mirror.Tags = append(mirror.Tags, s)
+				}
+			}
+		}
+		mirrors = append(mirrors, mirror)
+	}
+	return mirrors
+}
+
+// applyRegistries makes the first of registries the primary registry, with
+// its credentials and image, and the others mirrors receiving every tag.
+// registries replaces registry and mirrors, which must not be set with it.
+func applyRegistries(cfg *Config, raw map[string]any) {
+	cfg.Registries = parseRegistries(raw)
+	if len(cfg.Registries) == 0 {
+		return
+	}
+	_, hasRegistry := raw["registry"]
+	_, hasMirrors := raw["mirrors"]
+	if hasRegistry || hasMirrors {
+		return
+	}
+	primary := cfg.Registries[0]
+	cfg.Registry = primary.Registry
+	if primary.Image != "" {
+		cfg.Image = primary.Image
+	}
+	if primary.Username != "" {
+		cfg.Username, cfg.Password = primary.Username, primary.Password
+	}
+	cfg.Mirrors = append([]Mirror{}, cfg.Registries[1:]...)
+	for i := range cfg.Mirrors {
+		cfg.Mirrors[i].Tags = nil
+	}
+	cfg.registriesApplied = true
+}
+
+// validateRegistries checks that registries names every registry and is
+// not combined with registry or mirrors.
+func validateRegistries(cfg *Config) error {
+	if len(cfg.Registries) == 0 {
+		return nil
+	}
+	if !cfg.registriesApplied {
+		return fmt.Errorf("registries cannot be combined with registry or mirrors")
+	}
+	for i, registry := range cfg.Registries {
+		if registry.Registry == "" {
+			return fmt.Errorf("registry %d: url is required", i)
+		}
+	}
+	return nil
+}
+
+// validateMirrors validates every mirror's registry, image and tag patterns.
+func validateMirrors(mirrors []Mirror) error {
+	for i, mirror := range mirrors {
+		if mirror.Registry == "" {
+			return fmt.Errorf("mirror %d: registry is required", i)
+		}
+		if err := validateRegistry(mirror.Registry); err != nil {
+			return fmt.Errorf("mirror %s: %v", mirror.Registry, err)
+		}
+		if mirror.Image != "" {
+			if err := validateImageName(mirror.Image); err != nil {
+				return fmt.Errorf("mirror %s: %v", mirror.Registry, err)
+			}
+		}
+		for _, pattern := range mirror.Tags {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("mirror %s: invalid tag pattern %q", mirror.Registry, pattern)
+			}
+		}
+	}
+	return nil
+}
+
+// mirrorRepository returns the repository receiving mirror copies.
+func mirrorRepository(cfg *Config, mirror Mirror) string {
+	image := mirror.Image
+	if image == "" {
+		image = cfg.Image
+	}
+	return imageRepository(&Config{Registry: mirror.Registry, Image: image})
+}
+
+// mirrorTags returns the tags selected by the mirror's patterns.
+func mirrorTags(mirror Mirror, tags []string) []string {
+	if len(mirror.Tags) == 0 {
+		return tags
+	}
+	var selected []string
+	for _, tag := range tags {
+		for _, pattern := range mirror.Tags {
+			if ok, _ := path.Match(pattern, tag); ok {
+				selected = append(selected, tag)
+				break
+			}
+		}
+	}
+	return selected
+}
+
+// pushMirrors copies the pushed image ref, addressed by digest, to every
+// mirror under its selected tags. Mirrors that select no tag are skipped.
+func (p *DockerPlugin) pushMirrors(ctx context.Context, cfg *Config, ref, digest string, tags []string) ([]MirrorResult, error) {
+	var results []MirrorResult
+	for _, mirror := range cfg.Mirrors {
+		selected := withTagAliases(mirrorTags(mirror, tags), cfg.tagAliasGroups)
+		if len(selected) == 0 {
+			continue
+		}
+
+		if digest == "" {
+			resolved, err := p.resolveDigest(ctx, ref)
+			if err != nil {
+				return results, fmt.Errorf("failed to resolve digest of %s: %w", ref, err)
+			}
+			digest = resolved
+		}
+
+		if mirror.Username != "" && mirror.Password != "" {
+			if err := p.loginTo(ctx, cfg, mirror.Registry, mirror.Username, mirror.Password); err != nil {
+				return results, fmt.Errorf("failed to login to mirror %s: %w", mirror.Registry, err)
+			}
+		}
+
+		repository := mirrorRepository(cfg, mirror)
+		targets := make([]string, 0, len(selected))
+		for _, tag := range selected {
+			targets = append(targets, fmt.Sprintf("%s:%s", repository, tag))
+		}
+		if err := p.retagImage(ctx, imageRepository(cfg)+"@"+digest, targets); err != nil {
+			return results, fmt.Errorf("mirror %s: %w", mirror.Registry, err)
+		}
+		results = append(results, MirrorResult{Registry: mirror.Registry, Refs: targets})
+	}
+	return results, nil
+}
+
+// mirrorRelease runs the mirror stage once the release tags are pushed.
+func (p *DockerPlugin) mirrorRelease(ctx context.Context, cfg *Config, outputs *Outputs) error {
+	if len(cfg.Mirrors) == 0 || len(outputs.Refs) == 0 {
+		return nil
+	}
+	started := time.Now()
+	results, err := p.pushMirrors(ctx, cfg, outputs.Refs[0], outputs.Digest, outputs.Tags)
+	outputs.stage("mirror", started, err)
+	outputs.Mirrors = results
+	return err
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestParseMirrors(t *testing.T) {
+	t.Setenv("HUB_TOKEN", "from-env")
+	mirrors := parseMirrors(map[string]any{
+		"mirrors": []any{
+			map[string]any{"registry": "registry.internal"},
+			map[string]any{
+				"registry":     "docker.io",
+				"image":        "org/app",
+				"tags":         []any{"[0-9]*", "latest"},
+				"username":     "bot",
+				"password_env": "HUB_TOKEN",
+			},
+		},
+	})
+
+	if len(mirrors) != 2 {
+		t.Fatalf("expected 2 mirrors, got %v", mirrors)
+	}
+	hub := mirrors[1]
+	if hub.Image != "org/app" || hub.Username != "bot" || hub.Password != "from-env" || len(hub.Tags) != 2 {
+		t.Errorf("unexpected mirror: %+v", hub)
+	}
+}
+
+func TestValidateMirrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		mirrors []Mirror
+		wantErr bool
+	}{
+		{"valid", []Mirror{{Registry: "registry.internal", Tags: []string{"*"}}}, false},
+		{"missing registry", []Mirror{{Image: "app"}}, true},
+		{"invalid registry", []Mirror{{Registry: "bad registry"}}, true},
+		{"invalid image", []Mirror{{Registry: "ghcr.io", Image: "app;rm"}}, true},
+		{"invalid pattern", []Mirror{{Registry: "ghcr.io", Tags: []string{"["}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateMirrors(tt.mirrors)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateMirrors() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMirrorTags(t *testing.T) {
+	tags := []string{"1.2.3", "1.2", "latest", "edge", "nightly"}
+
+	if got := mirrorTags(Mirror{}, tags); len(got) != len(tags) {
+		t.Errorf("expected all tags without patterns, got %v", got)
+	}
+	got := mirrorTags(Mirror{Tags: []string{"[0-9]*", "latest"}}, tags)
+	if strings.Join(got, ",") != "1.2.3,1.2,latest" {
+		t.Errorf("mirrorTags() = %v", got)
+	}
+}
+
+func TestExecutePushesMirrors(t *testing.T) {
+	mock := &MockCommandExecutor{
+		OutputFunc: func(_ context.Context, _ string, args []string) ([]byte, error) {
+			if containsArg(args, "--format", "{{.Manifest.Digest}}") {
+				return []byte(testDigest), nil
+			}
+			return nil, nil
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":    "org/app",
+			"registry": "registry.internal",
+			"tags":     []any{"{{version}}", "edge"},
+			"mirrors": []any{
+				map[string]any{"registry": "docker.io", "tags": []any{"[0-9]*"}, "username": "bot", "password": "hub-token"},
+				map[string]any{"registry": "quay.io", "tags": []any{"stable"}},
+			},
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+
+	var login, copyCall *MockRunCall
+	for i, call := range mock.RunCalls {
+		if len(call.Args) > 0 && call.Args[0] == "login" {
+			login = &mock.RunCalls[i]
+		}
+		if containsFlag(call.Args, "imagetools") {
+			copyCall = &mock.RunCalls[i]
+		}
+	}
+	if login == nil || login.Stdin != "hub-token" {
+		t.Fatalf("expected mirror login, got %+v", mock.RunCalls)
+	}
+	if copyCall == nil {
+		t.Fatalf("expected mirror copy, got %+v", mock.RunCalls)
+	}
+	if !containsArg(copyCall.Args, "--tag", "org/app:1.0.0") || containsArg(copyCall.Args, "--tag", "org/app:edge") {
+		t.Errorf("expected only the version tag on Docker Hub, got %v", copyCall.Args)
+	}
+	if copyCall.Args[len(copyCall.Args)-1] != "registry.internal/org/app@"+testDigest {
+		t.Errorf("expected copy by digest, got %v", copyCall.Args)
+	}
+
+	mirrors, _ := resp.Outputs["mirrors"].([]MirrorResult)
+	if len(mirrors) != 1 || mirrors[0].Registry != "docker.io" {
+		t.Errorf("expected one mirror result, got %v", resp.Outputs["mirrors"])
+	}
+}
+
+func TestRedactConfigMirrors(t *testing.T) {
+	redacted := redactConfig(map[string]any{
+		"mirrors": []any{map[string]any{"registry": "docker.io", "password": "hub-token"}},
+	})
+	mirror := redacted["mirrors"].([]any)[0].(map[string]any)
+	if mirror["password"] != auditRedacted || mirror["registry"] != "docker.io" {
+		t.Errorf("unexpected mirror redaction: %v", mirror)
+	}
+}
+
+func TestExecutePushesRegistries(t *testing.T) {
+	mock := &MockCommandExecutor{
+		OutputFunc: func(_ context.Context, _ string, args []string) ([]byte, error) {
+			if containsArg(args, "--format", "{{.Manifest.Digest}}") {
+				return []byte(testDigest), nil
+			}
+			return nil, nil
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image": "org/app",
+			"tags":  []any{"{{version}}", "latest"},
+			"registries": []any{
+				map[string]any{"url": "registry.internal", "username": "ci", "password": "internal-token"},
+				map[string]any{"url": "docker.io", "image": "acme/app", "username": "bot", "password": "hub-token"},
+				map[string]any{"url": "ghcr.io", "username": "bot", "password": "ghcr-token"},
+			},
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+
+	var logins []string
+	for _, call := range mock.RunCalls {
+		if len(call.Args) > 0 && call.Args[0] == "login" {
+			logins = append(logins, call.Stdin)
+		}
+	}
+	if strings.Join(logins, ",") != "internal-token,hub-token,ghcr-token" {
+		t.Errorf("expected a login per registry, got %v", logins)
+	}
+
+	refs, _ := resp.Outputs["refs"].([]string)
+	if len(refs) != 2 || refs[0] != "registry.internal/org/app:1.0.0" {
+		t.Errorf("expected the first registry to be pushed to, got %v", refs)
+	}
+	mirrors, _ := resp.Outputs["mirrors"].([]MirrorResult)
+	if len(mirrors) != 2 || strings.Join(mirrors[0].Refs, ",") != "acme/app:1.0.0,acme/app:latest" || mirrors[1].Registry != "ghcr.io" {
+		t.Errorf("expected every tag on the other registries, got %+v", mirrors)
+	}
+}
+
+func TestValidateRegistries(t *testing.T) {
+	p := &DockerPlugin{}
+	tests := []struct {
+		name   string
+		config map[string]any
+		valid  bool
+	}{
+		{"valid", map[string]any{"image": "app", "registries": []any{map[string]any{"url": "ghcr.io"}, map[string]any{"url": "docker.io"}}}, true},
+		{"missing url", map[string]any{"image": "app", "registries": []any{map[string]any{"url": "ghcr.io"}, map[string]any{"image": "app"}}}, false},
+		{"with registry", map[string]any{"image": "app", "registry": "ghcr.io", "registries": []any{map[string]any{"url": "docker.io"}}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := p.Validate(context.Background(), tt.config)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Valid != tt.valid {
+				t.Errorf("Valid = %v, want %v: %+v", resp.Valid, tt.valid, resp.Errors)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// OutputsVersion is the version of the Outputs contract, reported as
+// outputs_version. Fields may be added within a version; renaming or
+// removing a field, or changing its type, bumps it.
+const OutputsVersion = 1
+
+// Stage statuses reported in Outputs.Stages.
+const (
+	stageSucceeded = "succeeded"
+	stageFailed    = "failed"
+	stageSkipped   = "skipped"
+)
+
+// imageArtifactType is the artifact type of pushed image references.
+const imageArtifactType = "docker-image"
+
+// Outputs is the contract of ExecuteResponse.Outputs. The response map is
+// keyed by the json field names; see Map.
+type Outputs struct {
+	Version  int      `json:"outputs_version"`
+	Image    string   `json:"image"`
+	Registry string   `json:"registry"`
+	Tags     []string `json:"tags"`
+	Refs     []string `json:"refs"`
+	Digest   string   `json:"digest,omitempty"`
+	// Digests maps each release tag to the digest it was pushed with, so
+	// consumers can pin the image.
+	Digests        map[string]string `json:"digests,omitempty"`
+	Platforms      []string          `json:"platforms,omitempty"`
+	Pushed         bool              `json:"pushed"`
+	Reused         *bool             `json:"reused,omitempty"`
+	SourceDigest   string            `json:"source_digest,omitempty"`
+	LoadedPlatform string            `json:"loaded_platform,omitempty"`
+	Archive        *ArchiveResult    `json:"archive,omitempty"`
+	PushStats      []*PushStats      `json:"push_stats,omitempty"`
+	BytesPushed    int64             `json:"bytes_pushed"`
+	PushedRefs     []string          `json:"pushed_refs,omitempty"`
+	Artifacts      []plugin.Artifact `json:"artifacts,omitempty"`
+	Stages         []StageStatus     `json:"stages,omitempty"`
+	Warnings       []string          `json:"warnings,omitempty"`
+
+	// BuildStages reports the time spent in each Dockerfile stage of a
+	// build_timings build, slowest first.
+	BuildStages []BuildStageTiming `json:"build_stages,omitempty"`
+	// Cache reports the build cache hit rate of a traced build.
+	Cache *CacheReport `json:"cache,omitempty"`
+
+	ImageConfig *ImageConfig   `json:"image_config,omitempty"`
+	Mirrors     []MirrorResult `json:"mirrors,omitempty"`
+	Canary      *CanaryResult  `json:"canary,omitempty"`
+
+	// RepositoryCreated reports that the release created the repository of
+	// the image.
+	RepositoryCreated bool `json:"repository_created,omitempty"`
+
+	// Notified lists the downstreams notified of the pushed image.
+	Notified []string `json:"notified,omitempty"`
+
+	// Scorecard compares the image with the previous release recorded in
+	// scorecard_file.
+	Scorecard *ScorecardResult `json:"scorecard,omitempty"`
+
+	// BaseImages lists the base images whose signatures were verified.
+	BaseImages []BaseImageResult `json:"base_images,omitempty"`
+
+	// PushDeferredUntil is the RFC 3339 opening of the push window a
+	// deferred push was postponed to.
+	PushDeferredUntil string `json:"push_deferred_until,omitempty"`
+
+	// PreviousDigests maps each moving tag (e.g. latest) to the digest it
+	// pointed at before the release; RollbackCommands restore them.
+	PreviousDigests  map[string]string `json:"previous_digests,omitempty"`
+	RollbackCommands []string          `json:"rollback_commands,omitempty"`
+
+	// Skipped reports that skip_if_exists found every tag of the release in
+	// the registry, so nothing was built or pushed.
+	Skipped bool `json:"skipped,omitempty"`
+
+	// RemoteChecks are the findings of the read-only registry checks of a
+	// dry run with dry_run_remote_checks.
+	RemoteChecks []RemoteCheck `json:"remote_checks,omitempty"`
+
+	// Bundle is the air-gap bundle exported from, or imported into, the
+	// registry.
+	Bundle *BundleResult `json:"bundle,omitempty"`
+
+	// Resources estimates the build time and registry storage the release
+	// used, to attribute CI and registry costs.
+	Resources *ResourceReport `json:"resources,omitempty"`
+
+	// CanonicalConfig is the redacted configuration rewritten with canonical
+	// option names. It is reported by dry runs using legacy option names.
+	CanonicalConfig map[string]any `json:"canonical_config,omitempty"`
+}
+
+// StageStatus reports the outcome of one stage of the execution.
+type StageStatus struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	DurationMS int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+	// Reason explains why a stage was skipped.
+	Reason string `json:"reason,omitempty"`
+}
+
+// stage records a stage that started at started and ended with err.
+func (o *Outputs) stage(name string, started time.Time, err error) {
+	s := StageStatus{
+		Name:       name,
+		Status:     stageSucceeded,
+		DurationMS: time.Since(started).Milliseconds(),
+	}
+	if err != nil {
+		s.Status = stageFailed
+		s.Error = err.Error()
+	}
+	o.Stages = append(o.Stages, s)
+}
+
+// skipStage records a stage that was not run, and why.
+func (o *Outputs) skipStage(name, reason string) {
+	o.Stages = append(o.Stages, StageStatus{Name: name, Status: stageSkipped, Reason: reason})
+}
+
+// setPushed records the pushed references and derives the digest and
+// artifacts from their transfer stats.
+func (o *Outputs) setPushed(stats []*PushStats) {
+	o.PushStats = stats
+	o.BytesPushed = totalBytesPushed(stats)
+	for _, s := range stats {
+		if o.Digest == "" {
+			o.Digest = s.Digest
+		}
+		o.Artifacts = append(o.Artifacts, plugin.Artifact{
+			Name:     s.Ref,
+			Path:     s.Ref,
+			Type:     imageArtifactType,
+			Size:     s.BytesTotal,
+			Checksum: s.Digest,
+		})
+	}
+}
+
+// setDigests maps each tag to the digest of its reference: the digest its
+// push reported, the digest of the release once every reference points at
+// it, or the digest the tag was already found with.
+func (o *Outputs) setDigests() {
+	pushed := make(map[string]string, len(o.PushStats))
+	for _, s := range o.PushStats {
+		if s.Digest != "" {
+			pushed[s.Ref] = s.Digest
+		}
+	}
+	digests := make(map[string]string, len(o.Tags))
+	for i, tag := range o.Tags {
+		if i >= len(o.Refs) {
+			break
+		}
+		if digest, ok := pushed[o.Refs[i]]; ok {
+			digests[tag] = digest
+		} else if o.Pushed && o.Digest != "" {
+			digests[tag] = o.Digest
+		} else if digest := o.Digests[tag]; digest != "" {
+			digests[tag] = digest
+		}
+	}
+	o.Digests = digests
+}
+
+// Map converts the outputs to the response map. Keys are the json field
+// names, omitempty fields are left out when empty, and pointers to scalars
+// are dereferenced so consumers see plain values.
+func (o *Outputs) Map() map[string]any {
+	out := make(map[string]any)
+	v := reflect.ValueOf(o).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, opts, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		field := v.Field(i)
+		if opts == "omitempty" && isEmptyValue(field) {
+			continue
+		}
+		if field.Kind() == reflect.Pointer && !field.IsNil() && field.Elem().Kind() != reflect.Struct {
+			field = field.Elem()
+		}
+		out[name] = field.Interface()
+	}
+	return out
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	default:
+		return v.IsZero()
+	}
+}
+
+// response builds an ExecuteResponse carrying the outputs and artifacts.
+func (o *Outputs) response(success bool, message, errMsg string) *plugin.ExecuteResponse {
+	o.setDigests()
+	o.setResources()
+	return &plugin.ExecuteResponse{
+		Success:   success,
+		Message:   message,
+		Error:     errMsg,
+		Outputs:   o.Map(),
+		Artifacts: o.Artifacts,
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestOutputsMap(t *testing.T) {
+	reused := false
+	o := &Outputs{
+		Version: OutputsVersion,
+		Image:   "myapp",
+		Tags:    []string{"1.0.0"},
+		Reused:  &reused,
+	}
+
+	m := o.Map()
+	if m["outputs_version"] != OutputsVersion {
+		t.Errorf("expected outputs_version %d, got %v", OutputsVersion, m["outputs_version"])
+	}
+	if m["reused"] != false {
+		t.Errorf("expected reused to be a plain false, got %#v", m["reused"])
+	}
+	if _, ok := m["bytes_pushed"]; !ok {
+		t.Error("expected bytes_pushed to always be present")
+	}
+	for _, key := range []string{"digest", "digests", "archive", "push_stats", "warnings", "stages"} {
+		if _, ok := m[key]; ok {
+			t.Errorf("expected empty %s to be omitted", key)
+		}
+	}
+
+	// The map must encode to the same JSON as the struct.
+	fromMap, _ := json.Marshal(m)
+	var decoded Outputs
+	if err := json.Unmarshal(fromMap, &decoded); err != nil {
+		t.Fatalf("map does not decode into Outputs: %v", err)
+	}
+	if decoded.Image != "myapp" || decoded.Reused == nil || *decoded.Reused {
+		t.Errorf("unexpected round trip: %+v", decoded)
+	}
+}
+
+func TestOutputsStagesAndArtifacts(t *testing.T) {
+	o := &Outputs{}
+	o.stage("build", time.Now(), nil)
+	o.stage("push", time.Now(), errors.New("denied"))
+	o.setPushed([]*PushStats{{Ref: "myapp:1.0.0", Digest: testDigest, BytesTotal: 42}})
+
+	if o.Stages[0].Status != stageSucceeded || o.Stages[1].Status != stageFailed || o.Stages[1].Error != "denied" {
+		t.Errorf("unexpected stages: %+v", o.Stages)
+	}
+	if o.Digest != testDigest {
+		t.Errorf("expected digest from push stats, got %q", o.Digest)
+	}
+	if len(o.Artifacts) != 1 || o.Artifacts[0].Type != imageArtifactType || o.Artifacts[0].Checksum != testDigest {
+		t.Errorf("unexpected artifacts: %+v", o.Artifacts)
+	}
+}
+
+func TestExecuteOutputsContract(t *testing.T) {
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"image": "myorg/myapp", "registry": "ghcr.io", "tags": []any{"{{version}}"}},
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+	})
+	if err != nil || !resp.Success {
+		t.Fatalf("unexpected failure: %v %+v", err, resp)
+	}
+
+	if resp.Outputs["outputs_version"] != OutputsVersion {
+		t.Errorf("expected outputs_version, got %v", resp.Outputs["outputs_version"])
+	}
+	refs, _ := resp.Outputs["refs"].([]string)
+	if len(refs) != 1 || refs[0] != "ghcr.io/myorg/myapp:1.0.0" {
+		t.Errorf("unexpected refs: %v", resp.Outputs["refs"])
+	}
+	stages, _ := resp.Outputs["stages"].([]StageStatus)
+	if len(stages) != 2 || stages[0].Name != "build" || stages[1].Name != "push" {
+		t.Errorf("expected build and push stages, got %+v", resp.Outputs["stages"])
+	}
+	if len(resp.Artifacts) != 1 || resp.Artifacts[0].Name != "ghcr.io/myorg/myapp:1.0.0" {
+		t.Errorf("expected pushed ref as artifact, got %+v", resp.Artifacts)
+	}
+}
+
+func TestOutputsDigests(t *testing.T) {
+	o := &Outputs{Tags: []string{"1.0.0", "latest"}, Refs: []string{"myapp:1.0.0", "myapp:latest"}}
+	o.setPushed([]*PushStats{{Ref: "myapp:1.0.0", Digest: testDigest}})
+	o.setDigests()
+	if len(o.Digests) != 1 || o.Digests["1.0.0"] != testDigest {
+		t.Errorf("expected only the pushed tag before the release is pushed, got %v", o.Digests)
+	}
+
+	o.Pushed = true
+	o.setDigests()
+	if o.Digests["1.0.0"] != testDigest || o.Digests["latest"] != testDigest {
+		t.Errorf("expected every tag at the release digest, got %v", o.Digests)
+	}
+}
+
+func TestExecuteBuildxDigests(t *testing.T) {
+	mock := &MockCommandExecutor{
+		RunFunc: func(_ context.Context, _ string, args []string, _ io.Reader) error {
+			for i, arg := range args {
+				if arg == "--metadata-file" {
+					return os.WriteFile(args[i+1], []byte(`{"containerimage.digest":"`+testDigest+`"}`), 0o600)
+				}
+			}
+			return nil
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"image": "myapp", "builder": "release", "tags": []any{"{{version}}", "latest"}},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil || !resp.Success {
+		t.Fatalf("unexpected failure: %v %+v", err, resp)
+	}
+
+	if resp.Outputs["digest"] != testDigest {
+		t.Errorf("expected the digest from the build metadata, got %v", resp.Outputs["digest"])
+	}
+	digests, _ := resp.Outputs["digests"].(map[string]string)
+	if digests["1.0.0"] != testDigest || digests["latest"] != testDigest {
+		t.Errorf("expected every tag at the pushed digest, got %v", resp.Outputs["digests"])
+	}
+	for _, call := range mock.OutputCalls {
+		if containsArg(call.Args, "{{.Manifest.Digest}}", "myapp:1.0.0") {
+			t.Errorf("expected no registry lookup of the pushed digest, got %v", call.Args)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Values of phase.
+const (
+	phaseSingle = "single"
+	phaseSplit  = "split"
+)
+
+// PhaseState carries the image built by the pre-publish hook of a split
+// release to its post-publish hook.
+type PhaseState struct {
+	Version string   `json:"version"`
+	Refs    []string `json:"refs"`
+	// ImageID identifies the image of a classic build in the local daemon.
+	ImageID string `json:"image_id,omitempty"`
+}
+
+// validatePhase checks phase.
+func validatePhase(cfg *Config) error {
+	switch cfg.Phase {
+	case "", phaseSingle, phaseSplit:
+	default:
+		return fmt.Errorf("unknown phase %q: use %s or %s", cfg.Phase, phaseSingle, phaseSplit)
+	}
+	if cfg.PhaseStateFile != "" {
+		if err := validatePath(cfg.PhaseStateFile); err != nil {
+			return fmt.Errorf("invalid phase_state_file: %v", err)
+		}
+	}
+	return nil
+}
+
+// phaseStatePath returns phase_state_file, or by default a file in the
+// system temporary directory named after the sorted refs.
+func phaseStatePath(cfg *Config, refs []string) string {
+	if cfg.PhaseStateFile != "" {
+		return cfg.PhaseStateFile
+	}
+	sorted := append([]string{}, refs...)
+	sort.Strings(sorted)
+	sum := sha256.Sum256([]byte(strings.Join(sorted, "\n")))
+	return filepath.Join(os.TempDir(), "relicta-docker-"+hex.EncodeToString(sum[:8])+".phase.json")
+}
+
+// savePhaseState records the image the pre-publish hook built. Buildx
+// builds record no image: the post-publish build is served from the
+// builder cache.
+func (p *DockerPlugin) savePhaseState(ctx context.Context, cfg *Config, releaseVersion string) error {
+	refs, err := releaseRefs(cfg, releaseVersion)
+	if err != nil || len(refs) == 0 {
+		return err
+	}
+	state := PhaseState{Version: releaseVersion, Refs: refs}
+	if !useBuildx(cfg) {
+		if state.ImageID, err = p.localImageID(ctx, refs[0]); err != nil {
+			return err
+		}
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(phaseStatePath(cfg, refs), append(data, '\n'), 0o644)
+}
+
+// loadPhaseState returns the state the pre-publish hook of the release
+// recorded, if any.
+func loadPhaseState(cfg *Config, releaseVersion string, refs []string) (*PhaseState, bool) {
+	data, err := os.ReadFile(phaseStatePath(cfg, refs))
+	if err != nil {
+		return nil, false
+	}
+	var state PhaseState
+	if json.Unmarshal(data, &state) != nil || state.Version != releaseVersion || strings.Join(state.Refs, ",") != strings.Join(refs, ",") {
+		return nil, false
+	}
+	return &state, true
+}
+
+// removePhaseState deletes the state of a release once it is pushed.
+func removePhaseState(cfg *Config, releaseVersion string) {
+	if refs, err := releaseRefs(cfg, releaseVersion); err == nil && len(refs) > 0 {
+		os.Remove(phaseStatePath(cfg, refs))
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// emulationSlowdown is the rough slowdown of a QEMU-emulated build compared
+// to a native one. Compile-heavy stages are usually at the upper end.
+const emulationSlowdown = "5-10x"
+
+// defaultPlatformEnv names the platform docker targets when --platform is
+// not given.
+const defaultPlatformEnv = "DOCKER_DEFAULT_PLATFORM"
+
+// compatiblePlatforms lists platforms a native architecture can execute
+// without emulation in addition to itself.
+var compatiblePlatforms = map[string][]string{
+	"linux/amd64": {"linux/386"},
+	"linux/arm64": {"linux/arm/v7", "linux/arm/v6"},
+}
+
+// normalizePlatform canonicalises a platform string so that equivalent
+// spellings (e.g. linux/arm64/v8 and linux/arm64) compare equal.
+func normalizePlatform(platform string) string {
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(platform)), "/")
+	if len(parts) < 2 {
+		return strings.Join(parts, "/")
+	}
+	switch parts[1] {
+	case "x86_64", "x86-64":
+		parts[1] = "amd64"
+	case "aarch64":
+		parts[1] = "arm64"
+	}
+	// Variants of amd64 (v2, v3, ...) and arm64/v8 run on the base architecture.
+	if len(parts) > 2 && (parts[1] == "amd64" || parts[1] == "arm64") {
+		parts = parts[:2]
+	}
+	return strings.Join(parts, "/")
+}
+
+// runsNatively reports whether platform can be built without emulation on
+// any of the given native platforms.
+func runsNatively(platform string, native []string) bool {
+	target := normalizePlatform(platform)
+	for _, n := range native {
+		n = normalizePlatform(n)
+		if n == target {
+			return true
+		}
+		for _, compat := range compatiblePlatforms[n] {
+			if compat == target {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// emulatedPlatforms returns the requested platforms that no native platform covers.
+func emulatedPlatforms(requested, native []string) []string {
+	var emulated []string
+	for _, platform := range requested {
+		if !runsNatively(platform, native) {
+			emulated = append(emulated, platform)
+		}
+	}
+	return emulated
+}
+
+// inspectedNode is a node of a buildx builder as `docker buildx inspect`
+// reports it: the platforms it can build, and those pinned to it with
+// `buildx create --platform`, which buildx schedules on it first.
+type inspectedNode struct {
+	name   string
+	listed []string
+	pinned []string
+}
+
+// native returns the platforms the node builds without emulation: those
+// pinned to it, or else the first one it lists.
+func (n inspectedNode) native() []string {
+	if len(n.pinned) > 0 {
+		return n.pinned
+	}
+	if len(n.listed) > 0 {
+		return n.listed[:1]
+	}
+	return nil
+}
+
+// parseInspectedNodes extracts the nodes of a builder from the output of
+// `docker buildx inspect`. Pinned platforms are marked with an asterisk.
+func parseInspectedNodes(output []byte) []inspectedNode {
+	var nodes []inspectedNode
+	var name string
+	inNodes := false
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "Nodes:" {
+			inNodes = true
+			continue
+		}
+		if value, ok := strings.CutPrefix(line, "Name:"); ok && inNodes {
+			name = strings.TrimSpace(value)
+			continue
+		}
+		value, ok := strings.CutPrefix(line, "Platforms:")
+		if !ok {
+			continue
+		}
+
+		node := inspectedNode{name: name}
+		name = ""
+		for _, platform := range strings.Split(value, ",") {
+			platform = strings.TrimSpace(platform)
+			if platform == "" {
+				continue
+			}
+			if trimmed, ok := strings.CutSuffix(platform, "*"); ok {
+				node.pinned = append(node.pinned, trimmed)
+			}
+			node.listed = append(node.listed, strings.TrimSuffix(platform, "*"))
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// parseBuilderPlatforms extracts the native platform of every node from the
+// output of `docker buildx inspect`. Platforms explicitly assigned to a node
+// are marked with an asterisk; otherwise the first listed platform is native.
+func parseBuilderPlatforms(output []byte) []string {
+	var native []string
+	for _, node := range parseInspectedNodes(output) {
+		native = append(native, node.native()...)
+	}
+	return native
+}
+
+// inspectBuilder returns the nodes of the configured builder, or nil when
+// the plugin creates the builder itself from builder_nodes, whose nodes it
+// pins to their platforms, or the builder cannot be inspected.
+func (p *DockerPlugin) inspectBuilder(ctx context.Context, cfg *Config) []inspectedNode {
+	if len(cfg.BuilderNodes) > 0 || cfg.Builder == "" {
+		return nil
+	}
+	out, err := p.getExecutor().Output(ctx, "docker", []string{"buildx", "inspect", cfg.Builder})
+	if err != nil {
+		return nil
+	}
+	return parseInspectedNodes(out)
+}
+
+// nativePlatforms returns the platforms the configured builder can produce
+// without QEMU. With a buildx builder every node contributes its native
+// platform; otherwise the docker daemon's platform is used, falling back to
+// the platform of the plugin process. Configured builder_nodes take precedence
+// so the plan is accurate before the builder exists.
+func (p *DockerPlugin) nativePlatforms(ctx context.Context, cfg *Config, nodes []inspectedNode) []string {
+	if len(cfg.BuilderNodes) > 0 {
+		return builderNodePlatforms(cfg.BuilderNodes)
+	}
+
+	var native []string
+	for _, node := range nodes {
+		native = append(native, node.native()...)
+	}
+	if len(native) > 0 {
+		return native
+	}
+
+	return []string{p.daemonPlatform(ctx)}
+}
+
+// unpinnedPlatforms returns a warning for every platform of requested that
+// a node of nodes builds natively without it being pinned there, while an
+// earlier node emulates it. buildx schedules a platform on the first node
+// pinning it, or else on the first node listing it, so such a platform may
+// be built under emulation although a native node exists.
+func unpinnedPlatforms(builder string, nodes []inspectedNode, requested []string) []string {
+	var warnings []string
+	for _, platform := range requested {
+		for i, node := range nodes {
+			if !runsNatively(platform, node.native()) {
+				continue
+			}
+			if runsNatively(platform, node.pinned) {
+				break
+			}
+			for _, earlier := range nodes[:i] {
+				if slices.ContainsFunc(earlier.listed, func(listed string) bool {
+					return normalizePlatform(listed) == normalizePlatform(platform)
+				}) {
+					warnings = append(warnings, fmt.Sprintf(
+						"platform %s may be built under emulation on node %s although node %s builds it natively; pin it with `docker buildx create --append --name %s --node %s --platform %s`",
+						platform, earlier.name, node.name, builder, node.name, strings.Join(append(slices.Clone(node.native()), platform), ",")))
+					break
+				}
+			}
+			break
+		}
+	}
+	return warnings
+}
+
+// daemonPlatform returns the platform of the docker daemon, falling back to
+// the platform of the plugin process.
+func (p *DockerPlugin) daemonPlatform(ctx context.Context) string {
+	out, err := p.getExecutor().Output(ctx, "docker", []string{"version", "--format", "{{.Server.Os}}/{{.Server.Arch}}"})
+	if err == nil {
+		platform := strings.TrimSpace(string(out))
+		if goos, arch, ok := strings.Cut(platform, "/"); ok && goos != "" && arch != "" {
+			return platform
+		}
+	}
+
+	return runtime.GOOS + "/" + runtime.GOARCH
+}
+
+// emulationWarnings returns a warning for every configured platform that will
+// be built under emulation.
+func (p *DockerPlugin) emulationWarnings(ctx context.Context, cfg *Config) []string {
+	nodes := p.inspectBuilder(ctx, cfg)
+	native := p.nativePlatforms(ctx, cfg, nodes)
+	emulated := emulatedPlatforms(cfg.Platforms, native)
+
+	warnings := make([]string, 0, len(emulated))
+	for _, platform := range emulated {
+		hint := "configure a multi-node buildx builder to build it natively"
+		if cfg.Builder != "" && !cfg.autoBuilder {
+			hint = fmt.Sprintf("add a native %s node to builder %q", platform, cfg.Builder)
+		}
+		warnings = append(warnings, fmt.Sprintf(
+			"platform %s will be emulated via QEMU on %s (estimated %s slower); %s",
+			platform, strings.Join(native, ", "), emulationSlowdown, hint))
+	}
+	return append(warnings, unpinnedPlatforms(cfg.Builder, nodes, cfg.Platforms)...)
+}
+
+// defaultPlatforms returns the platforms from DOCKER_DEFAULT_PLATFORM, used
+// when no platforms are configured so the target is explicit in the build.
+func defaultPlatforms() []string {
+	var platforms []string
+	for _, platform := range strings.Split(os.Getenv(defaultPlatformEnv), ",") {
+		if platform = strings.TrimSpace(platform); platform != "" {
+			platforms = append(platforms, platform)
+		}
+	}
+	return platforms
+}
+
+// validatePlatforms validates every configured platform.
+func validatePlatforms(platforms []string) error {
+	for _, platform := range platforms {
+		if err := validatePlatform(platform); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildxAvailable reports whether the docker buildx plugin is installed.
+func (p *DockerPlugin) buildxAvailable(ctx context.Context) bool {
+	_, err := p.getExecutor().Output(ctx, "docker", []string{"buildx", "version"})
+	return err == nil
+}
+
+// classicFallback switches a single-platform buildx build to classic docker
+// build when buildx is not installed, returning a warning describing the
+// switch. Multi-platform and multi-node builds cannot fall back and fail.
+func (p *DockerPlugin) classicFallback(ctx context.Context, cfg *Config) (string, error) {
+	if !useBuildx(cfg) || p.buildxAvailable(ctx) {
+		return "", nil
+	}
+	if len(cfg.Platforms) > 1 || len(cfg.BuilderNodes) > 0 {
+		return "", fmt.Errorf("docker buildx is not available; it is required for multi-platform and multi-node builds")
+	}
+
+	target := "the daemon platform"
+	if len(cfg.Platforms) == 1 {
+		target = cfg.Platforms[0]
+	}
+	cfg.Builder = ""
+	return fmt.Sprintf("docker buildx is not available; building %s with classic docker build", target), nil
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestNormalizePlatform(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"linux/amd64", "linux/amd64"},
+		{"linux/amd64/v3", "linux/amd64"},
+		{"linux/arm64/v8", "linux/arm64"},
+		{"Linux/AARCH64", "linux/arm64"},
+		{"linux/x86_64", "linux/amd64"},
+		{"linux/arm/v7", "linux/arm/v7"},
+		{"linux", "linux"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := normalizePlatform(tt.input); got != tt.expected {
+				t.Errorf("normalizePlatform(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEmulatedPlatforms(t *testing.T) {
+	tests := []struct {
+		name      string
+		requested []string
+		native    []string
+		expected  []string
+	}{
+		{
+			name:      "all native",
+			requested: []string{"linux/amd64"},
+			native:    []string{"linux/amd64"},
+			expected:  nil,
+		},
+		{
+			name:      "arm64 on amd64 host",
+			requested: []string{"linux/amd64", "linux/arm64"},
+			native:    []string{"linux/amd64"},
+			expected:  []string{"linux/arm64"},
+		},
+		{
+			name:      "compatible platform is native",
+			requested: []string{"linux/386", "linux/arm/v7"},
+			native:    []string{"linux/amd64", "linux/arm64"},
+			expected:  nil,
+		},
+		{
+			name:      "multi-node covers both",
+			requested: []string{"linux/amd64", "linux/arm64", "linux/s390x"},
+			native:    []string{"linux/amd64", "linux/arm64/v8"},
+			expected:  []string{"linux/s390x"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := emulatedPlatforms(tt.requested, tt.native)
+			if strings.Join(got, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseBuilderPlatforms(t *testing.T) {
+	output := `Name:          release
+Driver:        remote
+Last Activity: 2024-12-01 10:00:00 +0000 UTC
+
+Nodes:
+Name:      release0
+Endpoint:  tcp://amd64-builder:1234
+Status:    running
+Platforms: linux/amd64*, linux/amd64/v2, linux/386
+
+Name:      release1
+Endpoint:  tcp://arm64-builder:1234
+Status:    running
+Platforms: linux/arm64, linux/arm/v7, linux/arm/v6
+`
+
+	got := parseBuilderPlatforms([]byte(output))
+	expected := []string{"linux/amd64", "linux/arm64"}
+	if strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestUnpinnedPlatforms(t *testing.T) {
+	output := `Name:          release
+Driver:        remote
+
+Nodes:
+Name:      release0
+Endpoint:  tcp://amd64-builder:1234
+Status:    running
+Platforms: linux/amd64, linux/arm64, linux/386
+
+Name:      release1
+Endpoint:  tcp://arm64-builder:1234
+Status:    running
+Platforms: linux/arm64, linux/arm/v7
+`
+	nodes := parseInspectedNodes([]byte(output))
+	if len(nodes) != 2 || nodes[0].name != "release0" || nodes[1].name != "release1" {
+		t.Fatalf("expected both nodes, got %+v", nodes)
+	}
+
+	warnings := unpinnedPlatforms("release", nodes, []string{"linux/amd64", "linux/arm64"})
+	if len(warnings) != 1 {
+		t.Fatalf("expected one warning, got %v", warnings)
+	}
+	for _, want := range []string{"platform linux/arm64", "node release0", "node release1", "--node release1 --platform linux/arm64"} {
+		if !strings.Contains(warnings[0], want) {
+			t.Errorf("expected warning to contain %q, got %s", want, warnings[0])
+		}
+	}
+
+	pinned := strings.Replace(output, "Platforms: linux/arm64, linux/arm/v7", "Platforms: linux/arm64*, linux/arm/v7", 1)
+	if warnings := unpinnedPlatforms("release", parseInspectedNodes([]byte(pinned)), []string{"linux/amd64", "linux/arm64"}); len(warnings) != 0 {
+		t.Errorf("expected no warning for a pinned native node, got %v", warnings)
+	}
+}
+
+func TestEmulationWarnings(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("warns for emulated platform on daemon host", func(t *testing.T) {
+		mock := &MockCommandExecutor{
+			OutputFunc: func(_ context.Context, _ string, args []string) ([]byte, error) {
+				if args[0] == "version" {
+					return []byte("linux/amd64\n"), nil
+				}
+				return nil, nil
+			},
+		}
+		p := &DockerPlugin{executor: mock}
+
+		resp, err := p.Execute(ctx, plugin.ExecuteRequest{
+			Hook: plugin.HookPostPublish,
+			Config: map[string]any{
+				"image":     "myorg/myapp",
+				"platforms": []any{"linux/amd64", "linux/arm64"},
+			},
+			Context: plugin.ReleaseContext{Version: "v1.0.0"},
+			DryRun:  true,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		warnings, ok := resp.Outputs["warnings"].([]string)
+		if !ok || len(warnings) != 1 {
+			t.Fatalf("expected one warning, got %v", resp.Outputs["warnings"])
+		}
+		if !strings.Contains(warnings[0], "linux/arm64") || !strings.Contains(warnings[0], emulationSlowdown) {
+			t.Errorf("unexpected warning: %s", warnings[0])
+		}
+	})
+
+	t.Run("no warning when builder has native nodes", func(t *testing.T) {
+		mock := &MockCommandExecutor{
+			OutputFunc: func(_ context.Context, _ string, args []string) ([]byte, error) {
+				if args[0] == "buildx" {
+					return []byte("Platforms: linux/amd64\nPlatforms: linux/arm64\n"), nil
+				}
+				return []byte("linux/amd64"), nil
+			},
+		}
+		p := &DockerPlugin{executor: mock}
+
+		resp, err := p.Execute(ctx, plugin.ExecuteRequest{
+			Hook: plugin.HookPostPublish,
+			Config: map[string]any{
+				"image":     "myorg/myapp",
+				"builder":   "release",
+				"platforms": []any{"linux/amd64", "linux/arm64"},
+			},
+			Context: plugin.ReleaseContext{Version: "v1.0.0"},
+			DryRun:  true,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if _, ok := resp.Outputs["warnings"]; ok {
+			t.Errorf("expected no warnings, got %v", resp.Outputs["warnings"])
+		}
+	})
+}
+
+func TestBuildxBuilderRouting(t *testing.T) {
+	ctx := context.Background()
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(ctx, plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":     "myorg/myapp",
+			"builder":   "release",
+			"platforms": []any{"linux/amd64", "linux/arm64"},
+			"push":      true,
+		},
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+
+	if len(mock.RunCalls) != 1 {
+		t.Fatalf("expected a single buildx invocation, got %d calls", len(mock.RunCalls))
+	}
+	args := mock.RunCalls[0].Args
+	if args[0] != "buildx" || !containsArg(args, "--builder", "release") || !containsFlag(args, "--push") {
+		t.Errorf("expected buildx build with builder and --push, got %v", args)
+	}
+}
+
+func TestValidateBuilderName(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder string
+		wantErr bool
+	}{
+		{"empty", "", false},
+		{"simple", "release", false},
+		{"with dashes", "multi-node_builder-1", false},
+		{"injection", "release; rm -rf /", true},
+		{"leading dash", "-flag", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateBuilderName(tt.builder)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateBuilderName(%q) error = %v, wantErr %v", tt.builder, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClassicPlatformBuild(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("DOCKER_DEFAULT_PLATFORM is passed explicitly", func(t *testing.T) {
+		t.Setenv(defaultPlatformEnv, "linux/arm64")
+		mock := &MockCommandExecutor{}
+		p := &DockerPlugin{executor: mock}
+
+		resp, err := p.Execute(ctx, plugin.ExecuteRequest{
+			Hook:    plugin.HookPostPublish,
+			Config:  map[string]any{"image": "myorg/myapp", "push": false},
+			Context: plugin.ReleaseContext{Version: "v1.0.0"},
+		})
+		if err != nil || !resp.Success {
+			t.Fatalf("unexpected failure: %v %+v", err, resp)
+		}
+		args := mock.RunCalls[0].Args
+		if args[0] != "build" || !containsArg(args, "--platform", "linux/arm64") {
+			t.Errorf("expected classic build for linux/arm64, got %v", args)
+		}
+	})
+
+	t.Run("single platform falls back when buildx is missing", func(t *testing.T) {
+		t.Setenv(defaultPlatformEnv, "")
+		mock := &MockCommandExecutor{
+			OutputFunc: func(_ context.Context, _ string, args []string) ([]byte, error) {
+				if args[0] == "buildx" {
+					return nil, errors.New("docker: 'buildx' is not a docker command")
+				}
+				return nil, nil
+			},
+		}
+		p := &DockerPlugin{executor: mock}
+
+		resp, err := p.Execute(ctx, plugin.ExecuteRequest{
+			Hook: plugin.HookPostPublish,
+			Config: map[string]any{
+				"image":     "myorg/myapp",
+				"builder":   "release",
+				"platforms": []any{"linux/arm64"},
+				"push":      false,
+			},
+			Context: plugin.ReleaseContext{Version: "v1.0.0"},
+		})
+		if err != nil || !resp.Success {
+			t.Fatalf("unexpected failure: %v %+v", err, resp)
+		}
+		args := mock.RunCalls[0].Args
+		if args[0] != "build" || !containsArg(args, "--platform", "linux/arm64") {
+			t.Errorf("expected classic build for linux/arm64, got %v", args)
+		}
+		warnings, _ := resp.Outputs["warnings"].([]string)
+		if len(warnings) == 0 || !strings.Contains(warnings[0], "buildx is not available") {
+			t.Errorf("expected fallback warning, got %v", resp.Outputs["warnings"])
+		}
+	})
+
+	t.Run("multiple platforms require buildx", func(t *testing.T) {
+		mock := &MockCommandExecutor{
+			OutputFunc: func(_ context.Context, _ string, args []string) ([]byte, error) {
+				if args[0] == "buildx" {
+					return nil, errors.New("docker: 'buildx' is not a docker command")
+				}
+				return nil, nil
+			},
+		}
+		p := &DockerPlugin{executor: mock}
+
+		resp, err := p.Execute(ctx, plugin.ExecuteRequest{
+			Hook: plugin.HookPostPublish,
+			Config: map[string]any{
+				"image":     "myorg/myapp",
+				"builder":   "release",
+				"platforms": []any{"linux/amd64", "linux/arm64"},
+			},
+			Context: plugin.ReleaseContext{Version: "v1.0.0"},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.Success || !strings.Contains(resp.Error, "buildx is not available") {
+			t.Errorf("expected buildx error, got %+v", resp)
+		}
+	})
+}
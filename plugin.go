@@ -2,14 +2,18 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/relicta-tech/relicta-plugin-sdk/helpers"
 	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
@@ -30,6 +34,9 @@ var (
 	// Build arg key pattern: alphanumerics and underscores (environment variable style)
 	buildArgKeyPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
 
+	// Buildx builder name pattern: alphanumerics, dashes, underscores
+	builderNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]*$`)
+
 	// Label key pattern: OCI standard allows reverse-DNS style with dots, dashes
 	// e.g., org.opencontainers.image.source, com.example.my-label
 	labelKeyPattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9._-]*[a-zA-Z0-9]$`)
@@ -103,6 +110,20 @@ func validateLabelKey(key string) error {
 	return nil
 }
 
+// validateBuilderName validates a buildx builder name.
+func validateBuilderName(name string) error {
+	if name == "" {
+		return nil
+	}
+	if len(name) > 128 {
+		return fmt.Errorf("builder name too long (max 128 characters)")
+	}
+	if !builderNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid builder name: must be alphanumeric with dashes or underscores")
+	}
+	return nil
+}
+
 // validatePath validates a file path to prevent path traversal.
 func validatePath(path string) error {
 	if path == "" {
@@ -128,8 +149,15 @@ func validatePath(path string) error {
 // CommandExecutor abstracts command execution for testability.
 type CommandExecutor interface {
 	Run(ctx context.Context, name string, args []string, stdin io.Reader) error
+	RunCapture(ctx context.Context, name string, args []string, stdin io.Reader, stdout io.Writer) error
+	RunCaptureStderr(ctx context.Context, name string, args []string, stdin io.Reader, stderr io.Writer) error
+	Output(ctx context.Context, name string, args []string) ([]byte, error)
+	OutputInput(ctx context.Context, name string, args []string, stdin io.Reader) ([]byte, error)
 }
 
+// lookPath resolves executables in PATH; replaced in tests.
+var lookPath = exec.LookPath
+
 // RealCommandExecutor executes actual system commands.
 type RealCommandExecutor struct{}
 
@@ -140,13 +168,100 @@ func (e *RealCommandExecutor) Run(ctx context.Context, name string, args []strin
 		cmd.Stdin = stdin
 	}
 	cmd.Stdout = os.Stdout
+	stderr := &stderrTail{}
+	cmd.Stderr = io.MultiWriter(os.Stderr, stderr)
+	return stderr.wrap(cmd.Run())
+}
+
+// RunCapture executes the command like Run and additionally copies its
+// standard output to stdout.
+func (e *RealCommandExecutor) RunCapture(ctx context.Context, name string, args []string, stdin io.Reader, stdout io.Writer) error {
+	cmd := exec.CommandContext(ctx, name, args...)
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	cmd.Stdout = io.MultiWriter(os.Stdout, stdout)
+	stderr := &stderrTail{}
+	cmd.Stderr = io.MultiWriter(os.Stderr, stderr)
+	return stderr.wrap(cmd.Run())
+}
+
+// RunCaptureStderr executes the command like Run but sends its standard error
+// to stderr instead of the terminal.
+func (e *RealCommandExecutor) RunCaptureStderr(ctx context.Context, name string, args []string, stdin io.Reader, stderr io.Writer) error {
+	cmd := exec.CommandContext(ctx, name, args...)
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	cmd.Stdout = os.Stdout
+	tail := &stderrTail{}
+	cmd.Stderr = io.MultiWriter(stderr, tail)
+	return tail.wrap(cmd.Run())
+}
+
+// stderrTailSize bounds the standard error kept for error messages.
+const stderrTailSize = 4096
+
+// stderrTail keeps the end of a command's standard error so failures can
+// report the command's own error message.
+type stderrTail struct {
+	buf []byte
+}
+
+func (t *stderrTail) Write(p []byte) (int, error) {
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > stderrTailSize {
+		t.buf = t.buf[len(t.buf)-stderrTailSize:]
+	}
+	return len(p), nil
+}
+
+// wrap annotates err with the last line written to standard error. The
+// whole tail stays available as a commandError for failure reports.
+func (t *stderrTail) wrap(err error) error {
+	if err == nil {
+		return nil
+	}
+	lines := strings.Split(strings.TrimSpace(string(t.buf)), "\n")
+	if last := strings.TrimSpace(lines[len(lines)-1]); last != "" {
+		err = fmt.Errorf("%w: %s", err, last)
+	}
+	return &commandError{err: err, stderr: lines}
+}
+
+// commandError is the error of a failed command, carrying the last lines
+// of its standard error.
+type commandError struct {
+	err    error
+	stderr []string
+}
+
+func (e *commandError) Error() string { return e.err.Error() }
+
+func (e *commandError) Unwrap() error { return e.err }
+
+// Output executes the command and returns its standard output.
+func (e *RealCommandExecutor) Output(ctx context.Context, name string, args []string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stderr = os.Stderr
+	return cmd.Output()
+}
+
+// OutputInput executes the command with stdin and returns its standard
+// output, for commands printing secrets that must not reach the terminal.
+func (e *RealCommandExecutor) OutputInput(ctx context.Context, name string, args []string, stdin io.Reader) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdin = stdin
 	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	return cmd.Output()
 }
 
 // DockerPlugin implements the Docker container registry plugin.
 type DockerPlugin struct {
-	executor CommandExecutor
+	executor   CommandExecutor
+	httpClient *http.Client
+	// lookups caches the registry lookups of the execution.
+	lookups *lookupCache
 }
 
 // getExecutor returns the command executor, defaulting to RealCommandExecutor.
@@ -168,11 +283,296 @@ type Config struct {
 	Platforms  []string
 	Username   string
 	Password   string
+	PAT        string
 	Push       bool
 	Labels     map[string]string
 	CacheFrom  []string
 	NoCache    bool
 	Target     string
+	Builder    string
+	Engine     string
+	Load       bool
+
+	// TagAliases maps tags to aliases always pushed with them, e.g.
+	// v{{version}} for consumers expecting a v prefix.
+	TagAliases map[string][]string
+
+	// DryRunRemoteChecks makes dry runs query the registry, read-only.
+	DryRunRemoteChecks bool
+
+	// LatestOnPrerelease keeps latest among the tags of prereleases.
+	LatestOnPrerelease bool
+
+	// ChannelTags maps release channels, such as stable or rc, to the
+	// floating tags only releases of the channel write.
+	ChannelTags map[string][]string
+
+	// TagSanitize is how rendered tags with characters a tag cannot
+	// contain, such as the + of build metadata, are handled.
+	TagSanitize string
+
+	Daemonless bool
+	OCITarball string
+
+	// Features opts into experimental behaviors; see experimentalFeatures.
+	Features []string
+
+	ClusterLoad string
+	ClusterName string
+
+	BuildArgSources map[string]BuildArgSource
+	Secrets         map[string]BuildArgSource
+
+	// TrustCerts are CA certificates mounted into the build as the
+	// trust_certs secret.
+	TrustCerts []string
+
+	// ExtraBuildFlags and ExtraPushFlags are appended verbatim to docker
+	// build and docker push, for flags without an option yet.
+	ExtraBuildFlags []string
+	ExtraPushFlags  []string
+
+	ForbidSensitiveBuildArgs bool
+
+	Entitlements []string
+
+	CosignCopy bool
+	CosignSign bool
+	CosignKey  string
+
+	IndexSources []string
+	IndexTimeout string
+
+	// AppendPlatforms are built for a release pushed earlier and added to
+	// the index its tags point at.
+	AppendPlatforms []string
+
+	CPUSet      string
+	CgroupSlice string
+	Priority    string
+
+	Ulimits        []string
+	NofileLimit    int
+	MaxParallelism int
+
+	BuilderNodes  []BuilderNode
+	BuilderDriver string
+
+	ReuseIdentical bool
+
+	Resume         bool
+	CheckpointFile string
+
+	Debug bool
+
+	FixDockerignore bool
+
+	CleanCheckout        bool
+	RequireCleanWorktree bool
+	VerifyCommit         bool
+
+	BuildTimings      bool
+	CacheHitThreshold float64
+
+	PushRetries      int
+	PushRetryBackoff string
+	PushRateLimit    string
+
+	RegistryType      string
+	ECR               *ECRConfig
+	QuotaCheck        bool
+	QuotaStrict       bool
+	QuotaAllowOverrun bool
+
+	GCPCredentials string
+	ACR            *ACRConfig
+
+	ArchiveRegistry string
+	ArchiveImage    string
+	ArchiveUsername string
+	ArchivePassword string
+
+	Mirrors []Mirror
+
+	// Downstreams are notified of the pushed image.
+	Downstreams []Downstream
+
+	// Registries lists every registry the release is pushed to; see
+	// applyRegistries.
+	Registries []Mirror
+
+	E2E *E2EConfig
+
+	// ExecCompat runs the scripts of a release script being migrated to
+	// the plugin.
+	ExecCompat *ExecCompat
+
+	Canary *CanaryConfig
+
+	Approval *ApprovalConfig
+
+	PushWindow *PushWindow
+
+	ReleaseLock *ReleaseLock
+
+	BaseImageTrust []BaseImagePolicy
+
+	ImageNaming *NamingPolicy
+
+	RequiredLabels []string
+
+	RootUser          string
+	RootUserAllowlist []string
+
+	Inputs []Input
+
+	StaticBinaries       []string
+	VerifyEntrypointArch bool
+
+	ScorecardFile          string
+	ScorecardSizeThreshold float64
+	ScorecardScanReport    string
+
+	VersionManifest string
+
+	MaxDuration  string
+	BuildTimeout string
+	PushTimeout  string
+	LoginTimeout string
+
+	RegistryReadyTimeout string
+	RegistryKeepalive    string
+
+	// RegistryCacheTTL keeps registry lookups in RegistryCacheDir between
+	// executions for this long.
+	RegistryCacheTTL string
+	RegistryCacheDir string
+
+	EncryptionRecipients        []string
+	EncryptionKeyProviderConfig string
+
+	AllowedRegistries []string
+	AllowedBaseImages []string
+	Insecure          bool
+
+	LoginLocalRegistry bool
+
+	// UseCredentialHelper skips docker login and relies on the credential
+	// helpers configured for docker.
+	UseCredentialHelper bool
+
+	// SelfTest replaces the release with a check of every configured
+	// integration.
+	SelfTest bool
+
+	// RollingTagCheck keeps rolling tags such as {{major}} from moving to a
+	// release older than the newest one of their major or minor.
+	RollingTagCheck bool
+
+	// ClassicFallback retries single-platform buildx builds that failed
+	// because of the builder with classic docker build.
+	ClassicFallback bool
+
+	// SkipIfExists ends a release without building when every tag already
+	// exists in the registry, so re-running a release is idempotent.
+	SkipIfExists bool
+
+	AuditFile       string
+	AuditSigningKey string
+	FailureReport   string
+
+	PasswordCommand []string
+	TokenTTL        string
+
+	UserAgent       string
+	RegistryHeaders map[string]string
+
+	SplitPhases     bool
+	Phase           string
+	PhaseStateFile  string
+	Verify          bool
+	RollbackOnError bool
+
+	// RollbackDeleteTags makes rollback delete the version-specific tags of
+	// the failed release over the registry API.
+	RollbackDeleteTags bool
+
+	// Bundle exports a pushed release to an air-gap bundle, or imports one
+	// into the registry.
+	Bundle     string
+	BundleFile string
+
+	// plaintextRegistries lists registries configured with an http:// scheme.
+	plaintextRegistries []string
+	// localRegistries lists registries detected as running on this machine
+	// and configured without a scheme; plaintext HTTP is their fallback.
+	localRegistries []string
+
+	// prebuilt reports that the pre-publish hook already built the image
+	// into the local daemon.
+	prebuilt bool
+	// pushGated reports that the gates of gatePush passed.
+	pushGated bool
+	// pushReserved reports that reservePush ran; unlockRelease releases
+	// the release lock it took.
+	pushReserved  bool
+	unlockRelease func()
+	// pushThrottle limits the uploads of the release to push_rate_limit.
+	pushThrottle *throttle
+	// phaseWarnings report why the image built by the pre-publish hook was
+	// not reused.
+	phaseWarnings []string
+
+	// autoBuilder reports that Builder was chosen because several
+	// platforms were configured without one; ensureBuilder creates it.
+	autoBuilder bool
+
+	// registriesApplied reports that registries set the registry and
+	// mirrors.
+	registriesApplied bool
+
+	// variant is the name of the variant of variants being released.
+	variant string
+
+	// tagAliasGroups lists each aliased release tag followed by its
+	// aliases.
+	tagAliasGroups [][]string
+
+	// heldTags are the rolling tags the release does not move.
+	heldTags []string
+
+	// metadataFile is where a pushing buildx build writes its metadata,
+	// which records the pushed digest.
+	metadataFile string
+
+	// trustBundle is the temporary bundle of trust_certs mounted into the
+	// build.
+	trustBundle string
+
+	// ociOutput is where a daemonless build exports the OCI image layout
+	// tarball instead of pushing or loading the image.
+	ociOutput string
+
+	// tagContext holds the commit, branch and time of the release for tag
+	// placeholders.
+	tagContext tagContext
+
+	// rawJSONProgress renders every buildx build from its JSON progress.
+	rawJSONProgress bool
+
+	// featureWarnings reports enabled experiments that have ended.
+	featureWarnings []string
+
+	// deprecations lists legacy option names found in the configuration, and
+	// canonicalConfig is the redacted configuration with canonical names.
+	deprecations    []string
+	canonicalConfig map[string]any
+
+	// loggedInAt records the last registry login made with password_command.
+	loggedInAt time.Time
+
+	// deadline is the end of the max_duration budget of the execution.
+	deadline time.Time
 }
 
 // GetInfo returns plugin metadata.
@@ -182,233 +582,1197 @@ func (p *DockerPlugin) GetInfo() plugin.Info {
 		Version:     "2.0.0",
 		Description: "Build and push Docker images to container registries",
 		Author:      "Relicta Team",
-		Hooks: []plugin.Hook{
-			plugin.HookPostPublish,
-		},
+		Hooks:       supportedHooks,
 		ConfigSchema: `{
 			"type": "object",
 			"properties": {
 				"registry": {"type": "string", "description": "Container registry URL", "default": "docker.io"},
 				"image": {"type": "string", "description": "Image name (e.g., user/image)"},
-				"tags": {"type": "array", "items": {"type": "string"}, "description": "Tags to apply (supports {{version}})"},
+				"tags": {"type": "array", "items": {"type": "string"}, "description": "Tags to apply (supports {{version}}, {{major}}, {{minor}}, {{patch}}, {{prerelease}}, {{channel}}, {{sha}}, {{short_sha}}, {{branch}}, {{date}} and {{timestamp}})"},
+				"append_platform": {"type": "array", "items": {"type": "string"}, "description": "Platforms to build for a release pushed earlier and append to the index its tags point at, e.g. linux/arm64"},
+				"classic_fallback": {"type": "boolean", "description": "Retry single-platform buildx builds that failed because of the builder or its driver with classic docker build", "default": false},
+				"skip_if_exists": {"type": "boolean", "description": "Succeed without building when every tag of the release already exists in the registry", "default": false},
+				"dry_run_remote_checks": {"type": "boolean", "description": "Make dry runs query the registry, read-only, for existing tags, the digests moving tags would replace, the quota and held rolling tags", "default": false},
+				"latest_on_prerelease": {"type": "boolean", "description": "Keep the latest tag, implicit or configured, for prereleases such as 1.4.0-rc.1", "default": false},
+				"channel_tags": {"type": "object", "additionalProperties": {"type": "array", "items": {"type": "string"}}, "description": "Floating tags per release channel (stable, or the first prerelease identifier such as rc), written only by releases of that channel, e.g. {\"stable\": [\"latest\"], \"rc\": [\"next\"]}"},
+				"tag_sanitize": {"type": "string", "enum": ["replace", "strict", "fail"], "description": "Handling of rendered tags with characters a tag cannot contain: replace maps + to _ and others to -, strict also drops build metadata, fail rejects the tag", "default": "replace"},
+				"tag_aliases": {"type": "object", "additionalProperties": {"type": "array", "items": {"type": "string"}}, "description": "Aliases of tags that are always pushed with them and verified to resolve to the same digest, e.g. {\"{{version}}\": [\"v{{version}}\"]}"},
 				"dockerfile": {"type": "string", "description": "Dockerfile path", "default": "Dockerfile"},
 				"context": {"type": "string", "description": "Build context", "default": "."},
-				"build_args": {"type": "object", "description": "Build arguments"},
+				"build_args": {"type": "object", "description": "Build arguments; values are strings or {from_env, from_file, redact} objects resolved at execution time"},
+				"secrets": {"type": "object", "description": "BuildKit secrets by id, as {from_env} or {from_file} objects, mounted with RUN --mount=type=secret"},
+				"trust_certs": {"type": "array", "items": {"type": "string"}, "description": "PEM CA certificate files bundled into the trust_certs build secret, for builds behind TLS-intercepting proxies"},
+				"extra_build_flags": {"type": "array", "items": {"type": "string"}, "description": "Flags appended verbatim to docker build, as --name or --name=value, for flags the plugin has no option for"},
+				"extra_push_flags": {"type": "array", "items": {"type": "string"}, "description": "Flags appended verbatim to docker push, as --name or --name=value"},
+				"entitlements": {"type": "array", "items": {"type": "string", "enum": ["network.host", "security.insecure"]}, "description": "Buildx entitlements granted to the build; all are denied by default"},
+				"cosign_copy": {"type": "boolean", "description": "Copy cosign signatures, attestations and SBOMs along with images promoted to the archive registry", "default": false},
+				"cosign_sign": {"type": "boolean", "description": "Sign promoted images with cosign under the release identity", "default": false},
+				"cosign_key": {"type": "string", "description": "cosign key file or KMS URI for cosign_sign; keyless signing when unset"},
+				"index_sources": {"type": "array", "items": {"type": "string"}, "description": "Per-platform tags pushed by other jobs to merge into the release index instead of building (supports {{version}})"},
+				"index_timeout": {"type": "string", "description": "How long to wait for index_sources to appear in the registry", "default": "10m"},
+				"cpuset": {"type": "string", "description": "CPUs the docker build and push processes are pinned to, e.g. 0-3"},
+				"cgroup_slice": {"type": "string", "description": "systemd slice that docker build and push processes and RUN steps run in, e.g. release.slice"},
+				"priority": {"type": "string", "enum": ["normal", "low"], "description": "CPU and IO priority of docker build and push processes", "default": "normal"},
+				"ulimits": {"type": "array", "items": {"type": "string"}, "description": "Resource limits of RUN steps passed as --ulimit, e.g. nofile=65536:65536"},
+				"nofile_limit": {"type": "integer", "description": "Open file soft limit of the docker build and push processes, raised with prlimit"},
+				"max_parallelism": {"type": "integer", "description": "BuildKit max-parallelism of builders created from builder_nodes"},
+				"forbid_sensitive_build_args": {"type": "boolean", "description": "Fail instead of warning when a build arg name looks like a credential", "default": false},
 				"platforms": {"type": "array", "items": {"type": "string"}, "description": "Target platforms"},
 				"username": {"type": "string", "description": "Registry username (or use DOCKER_USERNAME env)"},
 				"password": {"type": "string", "description": "Registry password (or use DOCKER_PASSWORD env)"},
+				"auth": {"type": "object", "properties": {"pat": {"type": "string", "description": "Personal access token, required for Docker Hub accounts with 2FA (or use DOCKER_PAT env)"}}},
 				"push": {"type": "boolean", "description": "Push after building", "default": true},
 				"labels": {"type": "object", "description": "Image labels"},
 				"cache_from": {"type": "array", "items": {"type": "string"}, "description": "Cache source images"},
 				"no_cache": {"type": "boolean", "description": "Disable build cache"},
-				"target": {"type": "string", "description": "Target build stage"}
+				"target": {"type": "string", "description": "Target build stage"},
+				"images": {"type": "array", "items": {"type": "object", "properties": {"image": {"type": "string"}, "dockerfile": {"type": "string"}, "context": {"type": "string"}, "target": {"type": "string"}, "tags": {"type": "array", "items": {"type": "string"}}, "build_args": {"type": "object"}}, "required": ["image"]}, "description": "Several images released with the other options, each with its own image, dockerfile, context, target, tags and build_args"},
+				"images_parallel": {"type": "boolean", "description": "Release the images of images in parallel instead of one after another", "default": false},
+				"variants": {"type": "array", "items": {"type": "object", "properties": {"name": {"type": "string"}, "dockerfile": {"type": "string"}, "context": {"type": "string"}, "target": {"type": "string"}, "build_args": {"type": "object"}, "tags": {"type": "array", "items": {"type": "string"}}, "suffix": {"type": "string"}, "default": {"type": "boolean"}}, "required": ["name"]}, "description": "Variants of the image, such as alpine or debian, each built with its own dockerfile, target and build_args and tagged with the release tags suffixed with -<name>, or its own tags"},
+				"variants_parallel": {"type": "boolean", "description": "Release the variants in parallel instead of one after another", "default": false},
+				"builder": {"type": "string", "description": "Buildx builder to use; buildx builds each platform on the node it is pinned to"},
+				"engine": {"type": "string", "enum": ["docker", "podman", "auto"], "description": "Container engine running the builds and pushes; auto uses docker, or podman when only podman is installed", "default": "docker"},
+				"daemonless": {"type": "boolean", "description": "Push an OCI image layout over the registry API without a docker daemon", "default": false},
+				"oci_tarball": {"type": "string", "description": "Pre-built OCI image layout tarball pushed by daemonless instead of building"},
+				"features": {"type": "array", "items": {"type": "string", "enum": ["daemonless", "rawjson_progress", "resume"]}, "description": "Experimental behaviors to opt into; each experiment ends on a fixed date"},
+				"load": {"type": "boolean", "description": "Load the image for the local daemon's platform before pushing, for local testing", "default": false},
+				"cluster_load": {"type": "string", "enum": ["kind", "minikube", "k3d"], "description": "Load the built image into a local kind, minikube or k3d cluster before pushing"},
+				"cluster_name": {"type": "string", "description": "Cluster name (kind, k3d) or profile (minikube) for cluster_load; the tool's default when empty"},
+				"builder_nodes": {"type": "array", "items": {"type": "object", "properties": {"name": {"type": "string"}, "endpoint": {"type": "string"}, "platforms": {"type": "array", "items": {"type": "string"}}}, "required": ["endpoint", "platforms"]}, "description": "Remote nodes composing the buildx builder, each with the platforms it builds natively"},
+				"builder_driver": {"type": "string", "description": "Buildx driver for builder_nodes (e.g., remote, docker-container)"},
+				"reuse_identical": {"type": "boolean", "description": "Skip the build and retag the existing image when the source digest is unchanged", "default": false},
+				"resume": {"type": "boolean", "description": "Checkpoint completed stages so a re-run of a failed release skips the build and completed pushes", "default": false},
+				"checkpoint_file": {"type": "string", "description": "Checkpoint file used by resume (default: a file in the system temporary directory per tag set)"},
+				"debug": {"type": "boolean", "description": "Debug buildx builds: enable BUILDX_EXPERIMENTAL and debug logs, and report how to open a shell in a failed step", "default": false},
+				"clean_checkout": {"type": "boolean", "description": "Build from a git archive of the release tag instead of the working tree", "default": false},
+				"require_clean_worktree": {"type": "boolean", "description": "Fail when the build context has uncommitted changes or untracked files", "default": false},
+				"verify_commit": {"type": "boolean", "description": "Fail when the commit being built is not the release commit", "default": false},
+				"fix_dockerignore": {"type": "boolean", "description": "Add the .dockerignore entries suggested by validation for large context paths the Dockerfile does not use", "default": false},
+				"build_timings": {"type": "boolean", "description": "Report the time and cache hits of each Dockerfile stage from the buildx progress", "default": false},
+				"cache_hit_threshold": {"type": "number", "description": "Warn when fewer than this percentage of build steps are served from the cache (0 disables)", "default": 0},
+				"push_retries": {"type": "integer", "description": "Times a push failing with a transient error is retried; only the failed reference is pushed again", "default": 0},
+				"push_retry_backoff": {"type": "string", "description": "Delay before the first push retry, doubled for every further retry (max 2m)", "default": "2s"},
+				"push_rate_limit": {"type": "string", "description": "Maximum upload bandwidth of daemonless pushes and bundle imports (e.g., 20MB/s)"},
+				"registry_type": {"type": "string", "enum": ["generic", "dockerhub", "ghcr", "harbor", "ecr"], "description": "Registry flavour for provider-specific APIs (detected when unset)"},
+				"ecr": {"type": "object", "properties": {"create_repository": {"type": "boolean", "default": false}, "immutable_tags": {"type": "boolean", "default": false}, "scan_on_push": {"type": "boolean", "default": false}}, "description": "Create the ECR repository of the image, with tag immutability and scan-on-push, when it does not exist"},
+				"gcp_credentials": {"type": "string", "enum": ["gcloud", "application_default"], "description": "Credentials of the Artifact Registry access token printed by gcloud", "default": "gcloud"},
+				"acr": {"type": "object", "properties": {"mode": {"type": "string", "enum": ["az", "service_principal"]}, "client_id": {"type": "string"}, "client_secret": {"type": "string"}, "client_secret_env": {"type": "string"}}, "description": "Azure Container Registry login with az acr login --expose-token or a service principal (or use AZURE_CLIENT_ID and AZURE_CLIENT_SECRET env)"},
+				"quota_check": {"type": "boolean", "description": "Check the registry storage quota before pushing, failing when it is exhausted or the push would exceed it", "default": false},
+				"quota_strict": {"type": "boolean", "description": "Also fail quota_check when the quota cannot be queried, instead of warning", "default": false},
+				"quota_allow_overrun": {"type": "boolean", "description": "Only warn when the estimated push size would exceed the quota, for estimates known to overcount", "default": false},
+				"archive_registry": {"type": "string", "description": "Registry receiving an immutable digest-named copy of every pushed image"},
+				"archive_image": {"type": "string", "description": "Image name in the archive registry (defaults to image)"},
+				"archive_username": {"type": "string", "description": "Archive registry username (or use DOCKER_ARCHIVE_USERNAME env)"},
+				"archive_password": {"type": "string", "description": "Archive registry password (or use DOCKER_ARCHIVE_PASSWORD env)"},
+				"registries": {"type": "array", "items": {"type": "object", "properties": {"url": {"type": "string"}, "image": {"type": "string"}, "username": {"type": "string"}, "password": {"type": "string"}, "password_env": {"type": "string"}}, "required": ["url"]}, "description": "Registries to push the release to, instead of registry and mirrors: the first is built and pushed to, the others receive copies of every tag"},
+				"mirrors": {"type": "array", "items": {"type": "object", "properties": {"registry": {"type": "string"}, "image": {"type": "string"}, "tags": {"type": "array", "items": {"type": "string"}}, "username": {"type": "string"}, "password": {"type": "string"}, "password_env": {"type": "string"}}, "required": ["registry"]}, "description": "Additional registries receiving the release tags matching their tags patterns"},
+				"downstreams": {"type": "array", "items": {"type": "object", "properties": {"repo": {"type": "string"}, "workflow": {"type": "string"}, "ref": {"type": "string", "default": "main"}, "api_url": {"type": "string"}, "url": {"type": "string"}, "token": {"type": "string"}, "token_env": {"type": "string"}}}, "description": "Consumers notified of the pushed image: GitHub workflows dispatched with repo and workflow, or webhooks receiving a JSON event at url"},
+				"e2e": {"type": "object", "properties": {"compose_file": {"type": "string"}, "manifest": {"type": "string"}, "namespace": {"type": "string"}, "verify": {"type": "array", "items": {"type": "string"}}, "timeout": {"type": "string"}}, "required": ["verify"], "description": "Ephemeral deployment (compose file or kubectl manifest) verifying the pushed image before the release continues"},
+				"exec_compat": {"type": "object", "properties": {"pre_build": {"type": "array", "items": {"type": "string"}}, "post_push": {"type": "array", "items": {"type": "string"}}, "timeout": {"type": "string"}}, "description": "Commands of a release script run before the build and after the push, with RESOLVED_TAGS, IMAGE, REGISTRY, VERSION and DIGEST set, for migrating to the plugin step by step"},
+				"canary": {"type": "object", "properties": {"tag": {"type": "string", "default": "canary"}, "image": {"type": "string"}, "soak": {"type": "string"}, "approval_url": {"type": "string"}, "approval_file": {"type": "string"}, "approval_token": {"type": "string"}, "approval_timeout": {"type": "string", "default": "1h"}}, "description": "Push the new digest under a canary tag first and move the release tags after a soak period or approval"},
+				"approval": {"type": "object", "properties": {"url": {"type": "string"}, "token": {"type": "string"}, "timeout": {"type": "string", "default": "1h"}}, "required": ["url"], "description": "Webhook polled until it approves the push (or use DOCKER_APPROVAL_TOKEN env for the expected token)"},
+				"push_window": {"type": "object", "properties": {"schedule": {"type": "string"}, "timezone": {"type": "string"}, "on_closed": {"type": "string", "enum": ["wait", "defer"], "default": "wait"}, "max_wait": {"type": "string", "default": "1h"}}, "required": ["schedule"], "description": "Cron expression of the minutes pushes are allowed in; outside it the push waits or is deferred"},
+				"release_lock": {"type": ["boolean", "object"], "properties": {"dir": {"type": "string"}, "stale_after": {"type": "string", "default": "1h"}, "wait": {"type": "string"}, "force": {"type": "boolean", "default": false}}, "description": "Lock file serializing releases of the same image and tags on shared runners"},
+				"base_image_trust": {"type": "array", "items": {"type": "object", "properties": {"registry": {"type": "string"}, "method": {"type": "string", "enum": ["cosign", "dct"]}, "key": {"type": "string"}, "certificate_identity": {"type": "string"}, "certificate_oidc_issuer": {"type": "string"}}, "required": ["registry", "method"]}, "description": "Signature checks (cosign or Docker Content Trust) required of base images per registry; bases from other registries fail the build"},
+				"scorecard_file": {"type": "string", "description": "JSON file recording image size, layer count and vulnerability counts per release, compared with the previous release"},
+				"version_manifest": {"type": "string", "description": "JSON file recording the version, digest and tags of the latest release of each image, for dependency bots"},
+				"scorecard_size_threshold": {"type": "number", "description": "Image size growth in percent reported as a regression", "default": 10},
+				"scorecard_scan_report": {"type": "string", "description": "Trivy or Grype JSON report of the image whose critical and high vulnerability counts are recorded in the scorecard"},
+				"max_duration": {"type": "string", "description": "Time budget of the execution (e.g. 45m); optional stages are skipped when it runs short"},
+				"build_timeout": {"type": "string", "description": "Time after which the build is aborted (e.g. 30m)"},
+				"push_timeout": {"type": "string", "description": "Time after which a single push is aborted (e.g. 10m)"},
+				"login_timeout": {"type": "string", "description": "Time after which a registry login is aborted (e.g. 1m)"},
+				"registry_ready_timeout": {"type": "string", "description": "Time to wait for the registry API to answer before building (e.g. 2m)"},
+				"registry_keepalive": {"type": "string", "description": "Interval at which the registry is probed and password_command logins renewed during the build (e.g. 5m)"},
+				"registry_cache_ttl": {"type": "string", "description": "Time registry lookups are kept on disk between executions (e.g. 2m); lookups are always cached within an execution"},
+				"registry_cache_dir": {"type": "string", "description": "Directory of the registry lookups kept by registry_cache_ttl (default: a directory in the system temp dir)"},
+				"encryption_recipients": {"type": "array", "items": {"type": "string"}, "description": "ocicrypt recipients (jwe:, pkcs7:, pgp:, provider:) used to encrypt layers before push"},
+				"encryption_keyprovider_config": {"type": "string", "description": "ocicrypt keyprovider config for KMS recipients (or use OCICRYPT_KEYPROVIDER_CONFIG env)"},
+				"image_naming": {"type": "object", "properties": {"pattern": {"type": "string"}, "prefix": {"type": "string"}, "max_length": {"type": "integer"}}, "description": "Naming convention for the image repository: a regular expression the whole name must match, a required prefix and a maximum length"},
+				"allowed_registries": {"type": "array", "items": {"type": "string"}, "description": "Registries (glob patterns) images may be pushed to; DOCKER_ALLOWED_REGISTRIES env applies additionally"},
+				"required_labels": {"type": "array", "items": {"type": "string"}, "description": "Label keys every image must carry, from labels or the Dockerfile; the release fails when one is missing"},
+				"inputs": {"type": "object", "additionalProperties": {"type": ["string", "object"], "properties": {"path": {"type": "string"}, "build_arg": {"type": "string"}}}, "description": "Directories written by earlier plugins, keyed by build context name; each must exist and is passed with --build-context"},
+				"static_binaries": {"type": "array", "items": {"type": "string"}, "description": "Absolute paths of binaries verified without running the image: present, statically linked and built for each platform"},
+				"verify_entrypoint_arch": {"type": "boolean", "description": "Check that the entrypoint binary of each platform's image is built for that platform", "default": false},
+				"root_user": {"type": "string", "enum": ["allow", "warn", "fail"], "description": "Action when the built image runs as root", "default": "allow"},
+				"root_user_allowlist": {"type": "array", "items": {"type": "string"}, "description": "Image patterns allowed to run as root despite root_user"},
+				"allowed_base_images": {"type": "array", "items": {"type": "string"}, "description": "Image patterns (e.g. cgr.dev/chainguard/*) the FROM lines of the Dockerfile may use"},
+				"login_local_registry": {"type": "boolean", "description": "Log in to localhost registries, which are pushed to anonymously by default", "default": false},
+				"use_credential_helper": {"type": "boolean", "description": "Skip docker login and use the docker credential helper configured for the registry, verified before the build", "default": false},
+				"selftest": {"type": "boolean", "description": "Check tools, credentials, registries and the signing key without releasing, reporting a readiness matrix", "default": false},
+				"rolling_tag_check": {"type": "boolean", "description": "Only move rolling tags such as {{major}} or {{major}}.{{minor}} when the release is the highest version of their major or minor in the registry", "default": false},
+				"insecure": {"type": "boolean", "description": "Allow plaintext HTTP registries (http:// prefix or daemon insecure-registries)", "default": false},
+				"audit_file": {"type": "string", "description": "Path of a JSON provenance record written for every execution"},
+				"failure_report": {"type": "string", "description": "Path of a JSON report of the failed stage, command, exit code, stderr and remediation written when the execution fails"},
+				"audit_signing_key": {"type": "string", "description": "Ed25519 PKCS#8 PEM key (or file) signing the audit record (or use DOCKER_AUDIT_SIGNING_KEY env)"},
+				"password_command": {"type": "array", "items": {"type": "string"}, "description": "Command printing a fresh registry token; re-run to re-authenticate before pushing"},
+				"token_ttl": {"type": "string", "description": "Lifetime of tokens from password_command (e.g. 15m); renewal happens after half of it"},
+				"user_agent": {"type": "string", "description": "User-Agent for registry API calls (default: relicta-plugin-docker/<version>)"},
+				"registry_headers": {"type": "object", "additionalProperties": {"type": "string"}, "description": "Extra HTTP headers sent with registry API calls, e.g. for enterprise proxies"},
+				"split_phases": {"type": "boolean", "description": "Build in pre-publish and push in post-publish", "default": false},
+				"phase": {"type": "string", "enum": ["single", "split"], "description": "single builds and pushes in post-publish; split builds in pre-publish and pushes in post-publish, like split_phases", "default": "single"},
+				"phase_state_file": {"type": "string", "description": "File carrying the image built in pre-publish to post-publish (default: a file in the system temp directory)"},
+				"bundle": {"type": "string", "enum": ["export", "import"], "description": "export writes the pushed release with its signatures, attestations and SBOMs to an air-gap bundle; import verifies a bundle and pushes it to the registry"},
+				"bundle_file": {"type": "string", "description": "Air-gap bundle archive (default: <image>-<version>.bundle.tar)"},
+				"verify": {"type": "boolean", "description": "Verify pushed references resolve in the registry on success", "default": false},
+				"rollback_on_error": {"type": "boolean", "description": "Point moving tags (e.g. latest) back at the previous release when the release fails", "default": false},
+				"rollback_delete_tags": {"type": "boolean", "description": "With rollback_on_error, also delete the version-specific tags of the failed release over the Docker Hub, GHCR, Harbor or OCI registry API", "default": false}
 			},
-			"required": ["image"]
+			"anyOf": [{"required": ["image"]}, {"required": ["images"]}]
 		}`,
 	}
 }
 
 // Execute runs the plugin for a given hook.
 func (p *DockerPlugin) Execute(ctx context.Context, req plugin.ExecuteRequest) (*plugin.ExecuteResponse, error) {
+	if _, ok := req.Config["images"]; ok {
+		return p.executeImages(ctx, req)
+	}
+	if _, ok := req.Config["variants"]; ok {
+		return p.executeVariants(ctx, req)
+	}
 	cfg := p.parseConfig(req.Config)
+	cfg.tagContext = newTagContext(req.Context, timeNow())
+	cfg.tagContext.sanitize = cfg.TagSanitize
+	applyChannelTags(cfg, req.Context.Version)
+	dropLatestOnPrerelease(cfg, req.Context.Version)
+	startBudget(cfg, time.Now())
+
+	if cfg.Engine == enginePodman {
+		p = p.withPodman(cfg)
+	}
+	if err := validateTagSanitize(cfg.TagSanitize); err != nil {
+		return &plugin.ExecuteResponse{
+			Success: false,
+			Error:   fmt.Sprintf("invalid tag_sanitize: %v", err),
+		}, nil
+	}
+	if err := validateTimeout("registry_cache_ttl", cfg.RegistryCacheTTL); err != nil {
+		return &plugin.ExecuteResponse{
+			Success: false,
+			Error:   fmt.Sprintf("invalid registry_cache_ttl: %v", err),
+		}, nil
+	}
+	p = p.withLookupCache(cfg)
+
+	run := (*DockerPlugin).execute
+	if cfg.AuditFile != "" {
+		if err := validatePath(cfg.AuditFile); err != nil {
+			return &plugin.ExecuteResponse{
+				Success: false,
+				Error:   fmt.Sprintf("invalid audit_file: %v", err),
+			}, nil
+		}
+		run = (*DockerPlugin).executeAudited
+	}
+	if cfg.FailureReport != "" {
+		if err := validatePath(cfg.FailureReport); err != nil {
+			return &plugin.ExecuteResponse{
+				Success: false,
+				Error:   fmt.Sprintf("invalid failure_report: %v", err),
+			}, nil
+		}
+		inner := run
+		run = func(p *DockerPlugin, ctx context.Context, cfg *Config, req plugin.ExecuteRequest) (*plugin.ExecuteResponse, error) {
+			return p.executeReported(ctx, cfg, req, inner)
+		}
+	}
+	resp, err := run(p, ctx, cfg, req)
+	addRemediationHint(resp)
+	return resp, err
+}
 
+func (p *DockerPlugin) execute(ctx context.Context, cfg *Config, req plugin.ExecuteRequest) (*plugin.ExecuteResponse, error) {
+	if cfg.SelfTest {
+		return p.selfTest(ctx, cfg), nil
+	}
 	switch req.Hook {
+	case plugin.HookPrePublish:
+		if !cfg.SplitPhases {
+			return hookNotHandled(req.Hook), nil
+		}
+		return p.prePublish(ctx, cfg, req.Context, req.DryRun)
 	case plugin.HookPostPublish:
-		return p.buildAndPush(ctx, cfg, req.Context, req.DryRun)
+		if cfg.Bundle == bundleImport {
+			return p.importBundle(ctx, cfg, req.Context, req.DryRun)
+		}
+		if cfg.SplitPhases && !req.DryRun {
+			p.markPrebuilt(ctx, cfg, req.Context.Version)
+		}
+		resp, err := p.buildAndPush(ctx, cfg, req.Context, req.DryRun)
+		if cfg.SplitPhases && !req.DryRun && resp != nil && resp.Success {
+			removePhaseState(cfg, req.Context.Version)
+		}
+		return resp, err
+	case plugin.HookOnSuccess:
+		if !cfg.Verify {
+			return hookNotHandled(req.Hook), nil
+		}
+		return p.verifyRelease(ctx, cfg, req.Context, req.DryRun)
+	case plugin.HookOnError:
+		if !cfg.RollbackOnError {
+			return hookNotHandled(req.Hook), nil
+		}
+		return p.rollbackRelease(ctx, cfg, req.Context, req.DryRun)
 	default:
-		return &plugin.ExecuteResponse{
-			Success: true,
-			Message: fmt.Sprintf("Hook %s not handled", req.Hook),
-		}, nil
+		return hookNotHandled(req.Hook), nil
 	}
 }
 
 func (p *DockerPlugin) buildAndPush(ctx context.Context, cfg *Config, releaseCtx plugin.ReleaseContext, dryRun bool) (*plugin.ExecuteResponse, error) {
-	// Security validation
-	if err := validateImageName(cfg.Image); err != nil {
+	defer cfg.releaseLock()
+	if resp := runValidators(cfg, releaseValidators); resp != nil {
+		return resp, nil
+	}
+
+	if err := resolveBuildArgSources(cfg); err != nil {
 		return &plugin.ExecuteResponse{
 			Success: false,
-			Error:   fmt.Sprintf("invalid image configuration: %v", err),
+			Error:   fmt.Sprintf("failed to resolve build args: %v", err),
 		}, nil
 	}
 
-	if err := validateRegistry(cfg.Registry); err != nil {
+	sensitiveWarning, err := checkSensitiveBuildArgs(cfg)
+	if err != nil {
 		return &plugin.ExecuteResponse{
 			Success: false,
-			Error:   fmt.Sprintf("invalid registry configuration: %v", err),
+			Error:   fmt.Sprintf("sensitive build args: %v", err),
 		}, nil
 	}
 
-	if err := validatePath(cfg.Dockerfile); err != nil {
+	// Images assembled from index_sources are not built here.
+	if len(cfg.RequiredLabels) > 0 && len(cfg.IndexSources) == 0 {
+		missing, err := missingLabels(cfg)
+		if err != nil {
+			return &plugin.ExecuteResponse{
+				Success: false,
+				Error:   fmt.Sprintf("failed to read Dockerfile labels: %v", err),
+			}, nil
+		}
+		if len(missing) > 0 {
+			return &plugin.ExecuteResponse{
+				Success: false,
+				Error:   fmt.Sprintf("label policy violation: missing required labels %s", strings.Join(missing, ", ")),
+			}, nil
+		}
+	}
+
+	resolvedTags, err := resolveTags(cfg.Tags, releaseCtx.Version, cfg.tagContext)
+	if err != nil {
+		return &plugin.ExecuteResponse{
+			Success: false,
+			Error:   err.Error(),
+		}, nil
+	}
+	resolvedTags, cfg.tagAliasGroups, err = addTagAliases(cfg, resolvedTags, releaseCtx.Version)
+	if err != nil {
 		return &plugin.ExecuteResponse{
 			Success: false,
-			Error:   fmt.Sprintf("invalid dockerfile path: %v", err),
+			Error:   err.Error(),
 		}, nil
 	}
+	if len(resolvedTags) == 0 {
+		return &plugin.ExecuteResponse{
+			Success: false,
+			Error:   fmt.Sprintf("no tags to push: every tag renders empty for version %s", releaseCtx.Version),
+		}, nil
+	}
+
+	repository := imageRepository(cfg)
+	imageNames := make([]string, 0, len(resolvedTags))
+	for _, tag := range resolvedTags {
+		imageNames = append(imageNames, fmt.Sprintf("%s:%s", repository, tag))
+	}
 
-	if err := validatePath(cfg.Context); err != nil {
+	indexSources, err := indexSourceRefs(cfg, releaseCtx.Version)
+	if err != nil {
 		return &plugin.ExecuteResponse{
 			Success: false,
-			Error:   fmt.Sprintf("invalid build context path: %v", err),
+			Error:   fmt.Sprintf("invalid index_sources: %v", err),
 		}, nil
 	}
 
-	// Validate build args keys
-	for key := range cfg.BuildArgs {
-		if err := validateBuildArgKey(key); err != nil {
+	if cfg.RequireCleanWorktree && len(cfg.IndexSources) == 0 {
+		if err := p.checkCleanWorktree(ctx, cfg); err != nil {
 			return &plugin.ExecuteResponse{
 				Success: false,
-				Error:   fmt.Sprintf("invalid build arg key '%s': %v", key, err),
+				Error:   fmt.Sprintf("working tree is not clean: %v", err),
 			}, nil
 		}
 	}
 
-	// Validate label keys
-	for key := range cfg.Labels {
-		if err := validateLabelKey(key); err != nil {
+	if len(cfg.Inputs) > 0 && len(cfg.IndexSources) == 0 {
+		if err := checkInputs(cfg); err != nil {
 			return &plugin.ExecuteResponse{
 				Success: false,
-				Error:   fmt.Sprintf("invalid label key '%s': %v", key, err),
+				Error:   fmt.Sprintf("missing input: %v", err),
 			}, nil
 		}
 	}
 
-	version := strings.TrimPrefix(releaseCtx.Version, "v")
-	parts := strings.Split(version, ".")
-
-	major, minor, patch := "", "", ""
-	if len(parts) >= 1 {
-		major = parts[0]
-	}
-	if len(parts) >= 2 {
-		minor = parts[1]
-	}
-	if len(parts) >= 3 {
-		patch = parts[2]
-	}
-
-	tags := cfg.Tags
-	if len(tags) == 0 {
-		tags = []string{"{{version}}", "latest"}
-	}
-
-	resolvedTags := make([]string, 0, len(tags))
-	for _, tag := range tags {
-		resolved := tag
-		resolved = strings.ReplaceAll(resolved, "{{version}}", version)
-		resolved = strings.ReplaceAll(resolved, "{{major}}", major)
-		resolved = strings.ReplaceAll(resolved, "{{minor}}", minor)
-		resolved = strings.ReplaceAll(resolved, "{{patch}}", patch)
-
-		// Skip empty tags (e.g., when {{patch}} resolves to empty string)
-		if resolved == "" {
-			continue
+	if cfg.VerifyCommit && len(cfg.IndexSources) == 0 {
+		if err := p.verifyReleaseCommit(ctx, cfg, releaseCtx); err != nil {
+			return &plugin.ExecuteResponse{
+				Success: false,
+				Error:   err.Error(),
+			}, nil
 		}
+	}
 
-		// Validate resolved tag
-		if err := validateTag(resolved); err != nil {
+	// From here on, the build reads the release tag rather than the
+	// working tree.
+	if cfg.CleanCheckout && len(cfg.IndexSources) == 0 {
+		cleanup, err := p.cleanCheckout(ctx, cfg, releaseCtx)
+		if err != nil {
 			return &plugin.ExecuteResponse{
 				Success: false,
-				Error:   fmt.Sprintf("invalid tag '%s': %v", resolved, err),
+				Error:   fmt.Sprintf("failed to check out release: %v", err),
 			}, nil
 		}
-		resolvedTags = append(resolvedTags, resolved)
+		defer cleanup()
 	}
 
-	imageNames := make([]string, 0, len(resolvedTags))
-	for _, tag := range resolvedTags {
-		imageName := cfg.Image
-		if cfg.Registry != "" && cfg.Registry != "docker.io" {
-			imageName = fmt.Sprintf("%s/%s", cfg.Registry, cfg.Image)
+	// The entries are added before the source digest, which honours
+	// .dockerignore, is computed.
+	var dockerignoreFixes []string
+	if cfg.FixDockerignore && !dryRun && len(cfg.IndexSources) == 0 {
+		added, err := fixDockerignore(cfg)
+		if err != nil {
+			added = []string{fmt.Sprintf("failed to update .dockerignore: %v", err)}
 		}
-		imageNames = append(imageNames, fmt.Sprintf("%s:%s", imageName, tag))
+		dockerignoreFixes = added
 	}
 
-	if dryRun {
-		return &plugin.ExecuteResponse{
-			Success: true,
-			Message: "Would build and push Docker image",
-			Outputs: map[string]any{
-				"image":    cfg.Image,
-				"tags":     resolvedTags,
-				"registry": cfg.Registry,
-			},
-		}, nil
+	var sourceDigest string
+	if cfg.ReuseIdentical {
+		digest, err := computeSourceDigest(cfg)
+		if err != nil {
+			return &plugin.ExecuteResponse{
+				Success: false,
+				Error:   fmt.Sprintf("failed to compute source digest: %v", err),
+			}, nil
+		}
+		sourceDigest = digest
 	}
 
-	if cfg.Username != "" && cfg.Password != "" {
-		if err := p.dockerLogin(ctx, cfg); err != nil {
+	if cfg.Push && !cfg.Daemonless {
+		if err := p.checkDaemonInsecureRegistries(ctx, cfg); err != nil {
 			return &plugin.ExecuteResponse{
 				Success: false,
-				Error:   fmt.Sprintf("failed to login to registry: %v", err),
+				Error:   fmt.Sprintf("insecure registry: %v", err),
 			}, nil
 		}
 	}
 
-	if err := p.dockerBuild(ctx, cfg, imageNames, releaseCtx); err != nil {
+	warnings := append([]string{}, cfg.deprecations...)
+	warnings = append(warnings, cfg.featureWarnings...)
+	warnings = append(warnings, cfg.phaseWarnings...)
+	fallbackWarning, err := p.classicFallback(ctx, cfg)
+	if err != nil {
 		return &plugin.ExecuteResponse{
 			Success: false,
-			Error:   fmt.Sprintf("failed to build image: %v", err),
+			Error:   fmt.Sprintf("failed to select builder: %v", err),
 		}, nil
 	}
+	if fallbackWarning != "" {
+		warnings = append(warnings, fallbackWarning)
+	}
+	if len(cfg.Platforms) > 0 {
+		warnings = append(warnings, p.emulationWarnings(ctx, cfg)...)
+	}
+	if w := encryptionRegistryWarning(cfg); w != "" && cfg.Push {
+		warnings = append(warnings, w)
+	}
+	if sensitiveWarning != "" {
+		warnings = append(warnings, sensitiveWarning)
+	}
+	if w := entitlementWarning(cfg); w != "" {
+		warnings = append(warnings, w)
+	}
+	if w := resourceWarning(cfg); w != "" {
+		warnings = append(warnings, w)
+	}
+	if w := priorityWarning(cfg); w != "" {
+		warnings = append(warnings, w)
+	}
+	if w := fileLimitWarning(cfg); w != "" {
+		warnings = append(warnings, w)
+	}
+	if w := debugWarning(cfg); w != "" {
+		warnings = append(warnings, w)
+	}
+	if w := extraPushFlagsWarning(cfg); w != "" {
+		warnings = append(warnings, w)
+	}
+	warnings = append(warnings, dockerignoreFixes...)
+
+	outputs := &Outputs{
+		Version:      OutputsVersion,
+		Image:        cfg.Image,
+		Registry:     cfg.Registry,
+		Tags:         resolvedTags,
+		Refs:         imageNames,
+		Platforms:    cfg.Platforms,
+		SourceDigest: sourceDigest,
+		Warnings:     warnings,
+	}
+	if !dryRun {
+		outputs.Resources = newResourceReport(cfg)
+	}
 
-	if cfg.Push {
-		for _, imageName := range imageNames {
-			if err := p.dockerPush(ctx, imageName); err != nil {
-				return &plugin.ExecuteResponse{
-					Success: false,
-					Error:   fmt.Sprintf("failed to push image %s: %v", imageName, err),
-				}, nil
-			}
+	// The certificates are read before a dry run returns, so it reports
+	// invalid ones.
+	if len(cfg.TrustCerts) > 0 {
+		cleanup, err := withTrustBundle(cfg)
+		if err != nil {
+			return outputs.response(false, "", fmt.Sprintf("failed to read trust_certs: %v", err)), nil
 		}
+		defer cleanup()
 	}
 
-	return &plugin.ExecuteResponse{
-		Success: true,
-		Message: fmt.Sprintf("Built and pushed Docker image with %d tags", len(resolvedTags)),
-		Outputs: map[string]any{
-			"image":  cfg.Image,
-			"tags":   resolvedTags,
-			"pushed": cfg.Push,
-		},
-	}, nil
-}
-
-func (p *DockerPlugin) dockerLogin(ctx context.Context, cfg *Config) error {
-	registry := cfg.Registry
-	if registry == "" || registry == "docker.io" {
-		registry = ""
+	if dryRun {
+		outputs.CanonicalConfig = cfg.canonicalConfig
+		if cfg.DryRunRemoteChecks {
+			warnings = append(warnings, p.remoteChecks(ctx, cfg, releaseCtx.Version, imageNames, outputs)...)
+			outputs.Warnings = warnings
+		}
+		if len(cfg.IndexSources) > 0 {
+			return outputs.response(true, fmt.Sprintf("Would assemble image index from %d images", len(indexSources)), ""), nil
+		}
+		if len(cfg.AppendPlatforms) > 0 {
+			return outputs.response(true, fmt.Sprintf("Would append %s to %s", strings.Join(cfg.AppendPlatforms, ", "), imageNames[0]), ""), nil
+		}
+		if cfg.Daemonless {
+			return outputs.response(true, "Would push OCI image without a docker daemon", ""), nil
+		}
+		return outputs.response(true, "Would build and push Docker image", ""), nil
 	}
 
-	args := []string{"login"}
-	if registry != "" {
-		args = append(args, registry)
+	// Concurrent releases of the same tags must not interleave their pushes.
+	if cfg.Push {
+		if resp := p.reservePush(ctx, cfg, releaseCtx.Version, outputs); resp != nil {
+			return resp, nil
+		}
+		warnings = outputs.Warnings
 	}
-	args = append(args, "-u", cfg.Username, "--password-stdin")
-
-	return p.getExecutor().Run(ctx, "docker", args, strings.NewReader(cfg.Password))
-}
 
-func (p *DockerPlugin) dockerBuild(ctx context.Context, cfg *Config, imageNames []string, releaseCtx plugin.ReleaseContext) error {
-	args := []string{"build"}
-
-	for _, name := range imageNames {
-		args = append(args, "-t", name)
+	if cfg.Push && cfg.RollingTagCheck {
+		held, heldWarnings, err := p.checkRollingTags(ctx, cfg, releaseCtx.Version, outputs)
+		if err != nil {
+			return outputs.response(false, "", fmt.Sprintf("rolling tag check failed: %v", err)), nil
+		}
+		if len(held) > 0 {
+			resolvedTags = holdTags(cfg, resolvedTags, held)
+			if len(resolvedTags) == 0 {
+				return outputs.response(false, "", "rolling tag check held back every tag; add a tag such as {{version}} that names the release"), nil
+			}
+			imageNames = imageNames[:0]
+			for _, tag := range resolvedTags {
+				imageNames = append(imageNames, fmt.Sprintf("%s:%s", repository, tag))
+			}
+			outputs.Tags, outputs.Refs = resolvedTags, imageNames
+			warnings = append(warnings, heldWarnings...)
+			outputs.Warnings = warnings
+		}
 	}
 
-	dockerfile := cfg.Dockerfile
-	if dockerfile == "" {
-		dockerfile = "Dockerfile"
+	// A re-run of a release whose tags were all pushed has nothing to do.
+	if cfg.Push && cfg.SkipIfExists && len(cfg.IndexSources) == 0 && len(cfg.AppendPlatforms) == 0 {
+		existing, err := p.existingTags(ctx, cfg, resolvedTags, outputs)
+		if err != nil {
+			return outputs.response(false, "", fmt.Sprintf("failed to check existing tags: %v", err)), nil
+		}
+		if len(existing) == len(resolvedTags) {
+			outputs.Skipped = true
+			outputs.Digest = existing[resolvedTags[0]]
+			outputs.Digests = existing
+			return outputs.response(true, fmt.Sprintf("All %d tags already exist, skipped build and push", len(resolvedTags)), ""), nil
+		}
 	}
-	args = append(args, "-f", dockerfile)
 
-	for key, value := range cfg.BuildArgs {
-		args = append(args, "--build-arg", fmt.Sprintf("%s=%s", key, value))
+	// Daemonless releases talk to the registry API instead of docker.
+	if cfg.Daemonless {
+		return p.releaseDaemonless(ctx, cfg, releaseCtx, imageNames, resolvedTags, outputs)
 	}
 
-	args = append(args, "--build-arg", fmt.Sprintf("VERSION=%s", releaseCtx.Version))
-
-	if len(cfg.Platforms) > 0 {
+	if cfg.UseCredentialHelper {
+		if err := p.checkCredentialHelper(ctx, cfg, outputs); err != nil {
+			return outputs.response(false, "", fmt.Sprintf("failed to resolve registry credentials: %v", err)), nil
+		}
+	} else if skipLogin(cfg) {
+		if len(cfg.PasswordCommand) > 0 || hasStaticCredentials(cfg) {
+			warnings = append(warnings, fmt.Sprintf("skipped login to local registry %s; set login_local_registry to log in", cfg.Registry))
+			outputs.Warnings = warnings
+		}
+	} else if len(cfg.PasswordCommand) > 0 {
+		if err := p.refreshLogin(ctx, cfg, true); err != nil {
+			return &plugin.ExecuteResponse{
+				Success: false,
+				Error:   fmt.Sprintf("failed to login to registry: %v", err),
+			}, nil
+		}
+	} else if hasStaticCredentials(cfg) {
+		if err := p.dockerLogin(ctx, cfg); err != nil {
+			return &plugin.ExecuteResponse{
+				Success: false,
+				Error:   fmt.Sprintf("failed to login to registry: %v", err),
+			}, nil
+		}
+	}
+
+	// Deferred platforms join the index an earlier run pushed.
+	if len(cfg.AppendPlatforms) > 0 {
+		return p.appendPlatforms(ctx, cfg, releaseCtx, imageNames, outputs, warnings), nil
+	}
+
+	if cfg.Push && outputs.runOptionalStage(cfg, "tag_moves") {
+		warnings = append(warnings, p.recordTagMoves(ctx, cfg, releaseCtx.Version, outputs)...)
+		outputs.Warnings = warnings
+	}
+
+	// Per-platform images were built and pushed by other jobs; only the
+	// index is created here.
+	if len(cfg.IndexSources) > 0 {
+		if resp := p.gatePush(ctx, cfg, releaseCtx.Version, outputs); resp != nil {
+			return resp, nil
+		}
+		started := time.Now()
+		err := p.assembleIndex(ctx, cfg, indexSources, imageNames)
+		outputs.stage("index", started, err)
+		if err != nil {
+			return outputs.response(false, "", fmt.Sprintf("failed to assemble image index: %v", err)), nil
+		}
+		outputs.Pushed = true
+		if digest, err := p.resolveDigest(ctx, imageNames[0]); err == nil {
+			outputs.Digest = digest
+		}
+		if resp := p.execCompatStage(ctx, cfg, execPostPush, releaseCtx.Version, outputs); resp != nil {
+			return resp, nil
+		}
+
+		if cfg.ArchiveRegistry != "" {
+			started := time.Now()
+			archive, err := p.pushArchive(ctx, cfg, imageNames[0], outputs.Digest)
+			outputs.stage("archive", started, err)
+			if err != nil {
+				return outputs.response(false, "", fmt.Sprintf("failed to push archive copy: %v", err)), nil
+			}
+			outputs.Archive = archive
+		}
+		if err := p.mirrorRelease(ctx, cfg, outputs); err != nil {
+			return outputs.response(false, "", fmt.Sprintf("failed to push mirror copies: %v", err)), nil
+		}
+		if resp := p.bundleStage(ctx, cfg, releaseCtx.Version, outputs); resp != nil {
+			return resp, nil
+		}
+		return outputs.response(true, fmt.Sprintf("Assembled image index from %d images with %d tags", len(indexSources), len(resolvedTags)), ""), nil
+	}
+
+	buildNames := imageNames
+	if sourceDigest != "" {
+		sourceRef := fmt.Sprintf("%s:%s", repository, sourceTag(sourceDigest))
+		if cfg.Push && p.imageExists(ctx, sourceRef) {
+			if resp := p.gatePush(ctx, cfg, releaseCtx.Version, outputs); resp != nil {
+				return resp, nil
+			}
+			reused := true
+			outputs.Reused = &reused
+
+			started := time.Now()
+			err := p.retagImage(ctx, sourceRef, imageNames)
+			outputs.stage("retag", started, err)
+			if err != nil {
+				return outputs.response(false, "", fmt.Sprintf("failed to retag identical image %s: %v", sourceRef, err)), nil
+			}
+			outputs.Pushed = true
+
+			if cfg.CosignSign {
+				started := time.Now()
+				digest, err := p.resolveDigest(ctx, imageNames[0])
+				if err == nil {
+					outputs.Digest = digest
+					err = p.signPromoted(ctx, cfg, []string{repository + "@" + digest})
+				}
+				outputs.stage("sign", started, err)
+				if err != nil {
+					return outputs.response(false, "", fmt.Sprintf("failed to sign promoted image: %v", err)), nil
+				}
+			}
+			if resp := p.execCompatStage(ctx, cfg, execPostPush, releaseCtx.Version, outputs); resp != nil {
+				return resp, nil
+			}
+
+			if cfg.ArchiveRegistry != "" {
+				started := time.Now()
+				archive, err := p.pushArchive(ctx, cfg, imageNames[0], "")
+				outputs.stage("archive", started, err)
+				if err != nil {
+					return outputs.response(false, "", fmt.Sprintf("failed to push archive copy: %v", err)), nil
+				}
+				outputs.Archive = archive
+				outputs.Digest = archive.Digest
+			}
+			if err := p.mirrorRelease(ctx, cfg, outputs); err != nil {
+				return outputs.response(false, "", fmt.Sprintf("failed to push mirror copies: %v", err)), nil
+			}
+			if resp := p.bundleStage(ctx, cfg, releaseCtx.Version, outputs); resp != nil {
+				return resp, nil
+			}
+			outputs.Warnings = warnings
+			return outputs.response(true, fmt.Sprintf("Source unchanged; retagged existing image with %d tags", len(resolvedTags)), ""), nil
+		}
+
+		reused := false
+		outputs.Reused = &reused
+		if cfg.Labels == nil {
+			cfg.Labels = make(map[string]string)
+		}
+		cfg.Labels[sourceDigestLabel] = sourceDigest
+		buildNames = append(append([]string{}, imageNames...), sourceRef)
+	}
+
+	// A canary release pushes only the canary tag; the release tags move to
+	// its digest once it is promoted. Classic builds still tag the release
+	// references locally.
+	pushNames := buildNames
+	extraNames := buildNames[len(imageNames):]
+	var canary string
+	if cfg.Push && cfg.Canary != nil {
+		canary, err = canaryRef(cfg, releaseCtx.Version)
+		if err != nil {
+			return &plugin.ExecuteResponse{
+				Success: false,
+				Error:   fmt.Sprintf("failed to resolve canary tag: %v", err),
+			}, nil
+		}
+		pushNames = append([]string{canary}, extraNames...)
+		if useBuildx(cfg) {
+			buildNames = pushNames
+		}
+	}
+
+	// Without a canary, e2e holds back the moving tags the same way: only
+	// the version-specific references are pushed before the verification,
+	// and the moving tags follow once it passed.
+	var e2eHeld []string
+	if cfg.Push && cfg.E2E != nil && canary == "" {
+		moving, err := movingRefs(cfg, releaseCtx.Version)
+		if err != nil {
+			return &plugin.ExecuteResponse{
+				Success: false,
+				Error:   err.Error(),
+			}, nil
+		}
+		var verified []string
+		for _, name := range imageNames {
+			if slices.Contains(moving, name) {
+				e2eHeld = append(e2eHeld, name)
+			} else {
+				verified = append(verified, name)
+			}
+		}
+		if len(verified) == 0 {
+			return &plugin.ExecuteResponse{
+				Success: false,
+				Error:   fmt.Sprintf("e2e needs a version-specific tag to verify before the moving tags, but every tag of version %s moves", releaseCtx.Version),
+			}, nil
+		}
+		pushNames = append(verified, extraNames...)
+		if useBuildx(cfg) {
+			buildNames = pushNames
+		}
+	}
+
+	// Base images are checked before anything is built on them. The
+	// pre-publish hook checked the bases of a prebuilt image.
+	if (len(cfg.AllowedBaseImages) > 0 || len(cfg.BaseImageTrust) > 0) && !cfg.prebuilt {
+		started := time.Now()
+		bases, err := dockerfileBases(cfg, releaseCtx.Version)
+		if err != nil {
+			outputs.stage("base_images", started, err)
+			return outputs.response(false, "", fmt.Sprintf("failed to read base images: %v", err)), nil
+		}
+		if err := checkBaseImagePolicy(cfg, bases); err != nil {
+			outputs.stage("base_images", started, err)
+			return outputs.response(false, "", fmt.Sprintf("base image policy violation: %v", err)), nil
+		}
+		verified, err := p.verifyBaseImages(ctx, cfg, bases)
+		outputs.stage("base_images", started, err)
+		if err != nil {
+			return outputs.response(false, "", fmt.Sprintf("base image verification failed: %v", err)), nil
+		}
+		outputs.BaseImages = verified
+	}
+
+	// With resume, a re-run of a failed release skips the build and the
+	// pushes its checkpoint records as completed.
+	var checkpoint *Checkpoint
+	resumed := false
+	if cfg.Resume && cfg.Push {
+		digest := sourceDigest
+		if digest == "" {
+			if digest, err = computeSourceDigest(cfg); err != nil {
+				return &plugin.ExecuteResponse{
+					Success: false,
+					Error:   fmt.Sprintf("failed to compute source digest: %v", err),
+				}, nil
+			}
+		}
+		var warning string
+		checkpoint, warning = loadCheckpoint(cfg, releaseCtx.Version, digest, imageNames)
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+		if resumed = p.resumeBuild(ctx, cfg, checkpoint, imageNames, pushNames); resumed {
+			outputs.skipStage("build", "completed by a previous run")
+			if useBuildx(cfg) {
+				outputs.Digest = checkpoint.Digest
+			}
+		}
+	}
+
+	if err := p.ensureBuilder(ctx, cfg); err != nil {
+		return &plugin.ExecuteResponse{
+			Success: false,
+			Error:   fmt.Sprintf("failed to set up buildx builder: %v", err),
+		}, nil
+	}
+
+	// Buildx pushes during the build, so its quota check cannot account for
+	// the image size.
+	if cfg.QuotaCheck && cfg.Push && useBuildx(cfg) {
+		warning, err := p.checkQuota(ctx, cfg, 0)
+		if err != nil {
+			return &plugin.ExecuteResponse{
+				Success: false,
+				Error:   fmt.Sprintf("registry quota check failed: %v", err),
+			}, nil
+		}
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+	}
+
+	// Buildx pushes during the build, so the approval and push window gates
+	// first build without pushing; the pushing build after them is served
+	// from the builder cache.
+	if cfg.Push && useBuildx(cfg) && (cfg.Approval != nil || cfg.PushWindow != nil) && !resumed {
+		gateCfg := *cfg
+		gateCfg.Push = false
+		gateCfg.Load = false
+		trace := buildTraceFor(cfg)
+		started := time.Now()
+		err := p.tracedBuild(ctx, &gateCfg, buildNames, releaseCtx, trace)
+		outputs.stage("build", started, err)
+		outputs.setBuildTrace(cfg, trace)
+		if err != nil {
+			return outputs.response(false, "", fmt.Sprintf("failed to build image: %v", err)), nil
+		}
+	}
+
+	// Buildx pushes at the end of the build, so the gates and the token
+	// renewal come first.
+	if cfg.Push && useBuildx(cfg) {
+		if resp := p.gatePush(ctx, cfg, releaseCtx.Version, outputs); resp != nil {
+			return resp, nil
+		}
+		if err := p.refreshLogin(ctx, cfg, false); err != nil {
+			return &plugin.ExecuteResponse{
+				Success: false,
+				Error:   fmt.Sprintf("failed to refresh registry credentials: %v", err),
+			}, nil
+		}
+	}
+
+	if needsSeparateLoad(cfg) {
+		started := time.Now()
+		platform, err := p.loadImage(ctx, cfg, imageNames, releaseCtx)
+		outputs.stage("load", started, err)
+		if err != nil {
+			return outputs.response(false, "", fmt.Sprintf("failed to load image into local daemon: %v", err)), nil
+		}
+		outputs.LoadedPlatform = platform
+
+		// The build below pushes, so the cluster gets the loaded image now.
+		if err := p.loadIntoCluster(ctx, cfg, imageNames, outputs); err != nil {
+			return outputs.response(false, "", fmt.Sprintf("failed to load image into cluster: %v", err)), nil
+		}
+	}
+
+	// A classic build done by the pre-publish hook is already in the daemon.
+	if !cfg.prebuilt && !resumed {
+		if resp := p.execCompatStage(ctx, cfg, execPreBuild, releaseCtx.Version, outputs); resp != nil {
+			return resp, nil
+		}
+		if cfg.Push && useBuildx(cfg) {
+			cleanup, err := withMetadataFile(cfg)
+			if err != nil {
+				return outputs.response(false, "", fmt.Sprintf("failed to create build metadata file: %v", err)), nil
+			}
+			defer cleanup()
+		}
+		trace := buildTraceFor(cfg)
+		started := time.Now()
+		stopKeepalive := p.keepRegistryWarm(ctx, cfg)
+		err = p.tracedBuild(ctx, cfg, buildNames, releaseCtx, trace)
+		if err != nil && retriesClassic(ctx, cfg, err) {
+			warnings = append(warnings, fmt.Sprintf("buildx build failed because of the builder, retried with classic docker build: %v", err))
+			cfg.Builder = ""
+			if canary != "" || len(e2eHeld) > 0 {
+				buildNames = append(slices.Clone(imageNames), extraNames...)
+			}
+			trace = buildTraceFor(cfg)
+			err = p.tracedBuild(ctx, cfg, buildNames, releaseCtx, trace)
+		}
+		warnings = append(warnings, stopKeepalive()...)
+		outputs.stage("build", started, err)
+		outputs.setBuildTrace(cfg, trace)
+		if err != nil {
+			return outputs.response(false, "", fmt.Sprintf("failed to build image: %v", err)), nil
+		}
+		if cfg.Push && useBuildx(cfg) {
+			outputs.Digest = p.pushedDigest(ctx, cfg, pushNames[0])
+		}
+		p.recordBuild(ctx, cfg, checkpoint, imageNames, pushNames)
+		if w := cacheHitWarning(cfg, outputs.Cache); w != "" {
+			warnings = append(warnings, w)
+		}
+
+		if !needsSeparateLoad(cfg) {
+			if err := p.loadIntoCluster(ctx, cfg, imageNames, outputs); err != nil {
+				return outputs.response(false, "", fmt.Sprintf("failed to load image into cluster: %v", err)), nil
+			}
+		}
+	}
+
+	// Classic builds are checked before the push; buildx pushed while
+	// building, so its image is read from the registry.
+	if checksRootUser(cfg) && len(imageNames) > 0 && (imageInDaemon(cfg) || cfg.Push) {
+		ref := imageNames[0]
+		if !imageInDaemon(cfg) {
+			ref = pushNames[0]
+		}
+		started := time.Now()
+		warning, err := p.checkRootUser(ctx, cfg, ref, cfg.Push && useBuildx(cfg))
+		outputs.stage("root_user", started, err)
+		if err != nil {
+			return outputs.response(false, "", fmt.Sprintf("image user policy violation: %v", err)), nil
+		}
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+	}
+
+	if verifiesBinaries(cfg) && len(imageNames) > 0 && (imageInDaemon(cfg) || cfg.Push) {
+		ref := imageNames[0]
+		if !imageInDaemon(cfg) {
+			ref = pushNames[0]
+		}
+		started := time.Now()
+		err := p.verifyBinaries(ctx, cfg, ref)
+		outputs.stage("binaries", started, err)
+		if err != nil {
+			return outputs.response(false, "", fmt.Sprintf("binary verification failed: %v", err)), nil
+		}
+	}
+
+	if cfg.QuotaCheck && cfg.Push && !useBuildx(cfg) && len(buildNames) > 0 {
+		warning, err := p.checkQuota(ctx, cfg, p.estimatePushSize(ctx, buildNames[0]))
+		if err != nil {
+			return &plugin.ExecuteResponse{
+				Success: false,
+				Error:   fmt.Sprintf("registry quota check failed: %v", err),
+			}, nil
+		}
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+	}
+
+	if cfg.Push && !useBuildx(cfg) {
+		if resp := p.gatePush(ctx, cfg, releaseCtx.Version, outputs); resp != nil {
+			return resp, nil
+		}
+	}
+
+	// Buildx pushes as part of the build.
+	if cfg.Push && !useBuildx(cfg) {
+		if canary != "" && len(imageNames) > 0 {
+			if err := p.getExecutor().Run(ctx, "docker", []string{"tag", imageNames[0], canary}, nil); err != nil {
+				return outputs.response(false, "", fmt.Sprintf("failed to tag canary image: %v", err)), nil
+			}
+		}
+
+		pending := checkpoint.pending(pushNames)
+		if n := len(pushNames) - len(pending); n > 0 {
+			warnings = append(warnings, fmt.Sprintf("resumed push: %d of %d references were pushed by a previous run", n, len(pushNames)))
+		}
+		started := time.Now()
+		pushStats, err := p.pushAll(ctx, cfg, pending)
+		outputs.stage("push", started, err)
+		outputs.setPushed(pushStats)
+		checkpoint.recordPushed(pushStats)
+		if err != nil {
+			outputs.PushedRefs = make([]string, 0, len(pushStats))
+			for _, stats := range pushStats {
+				outputs.PushedRefs = append(outputs.PushedRefs, stats.Ref)
+			}
+			return outputs.response(false, "", fmt.Sprintf("failed to push image %v (%d of %d references pushed)", err, len(outputs.PushedRefs), len(pending))), nil
+		}
+	}
+
+	// Verify the pushed image before the moving tags or the promotion of the
+	// canary point at it, and before it is archived or mirrored.
+	var verifiedDigest string
+	if cfg.Push && cfg.E2E != nil && len(imageNames) > 0 {
+		image := pushNames[0]
+		verifiedDigest = outputs.Digest
+		if verifiedDigest == "" {
+			verifiedDigest, _ = p.resolveDigest(ctx, image)
+		}
+		if verifiedDigest != "" {
+			imageRepo := repository
+			if canary != "" {
+				imageRepo = canaryRepository(cfg)
+			}
+			image = imageRepo + "@" + verifiedDigest
+		}
+		started := time.Now()
+		warning, err := p.runE2E(ctx, cfg, image, releaseCtx.Version)
+		outputs.stage("e2e", started, err)
+		if err != nil {
+			return outputs.response(false, "", fmt.Sprintf("end-to-end verification failed: %v", err)), nil
+		}
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+
+		if len(e2eHeld) > 0 {
+			started := time.Now()
+			err := p.retagImage(ctx, image, e2eHeld)
+			outputs.stage("retag", started, err)
+			if err != nil {
+				return outputs.response(false, "", fmt.Sprintf("failed to move tags to the verified image %s: %v", image, err)), nil
+			}
+		}
+	}
+
+	if canary != "" {
+		started := time.Now()
+		result, err := p.promoteCanary(ctx, cfg, releaseCtx.Version, canary, verifiedDigest, imageNames)
+		outputs.stage("canary", started, err)
+		outputs.Canary = result
+		if err != nil {
+			outputs.PushedRefs = []string{canary}
+			return outputs.response(false, "", fmt.Sprintf("canary was not promoted: %v", err)), nil
+		}
+		outputs.Digest = result.Digest
+	}
+	outputs.Pushed = cfg.Push
+
+	if cfg.Push {
+		if err := p.verifyTagAliases(ctx, cfg, repository, outputs); err != nil {
+			return outputs.response(false, "", err.Error()), nil
+		}
+		if resp := p.execCompatStage(ctx, cfg, execPostPush, releaseCtx.Version, outputs); resp != nil {
+			return resp, nil
+		}
+	}
+
+	if len(imageNames) > 0 && (cfg.Push || imageInDaemon(cfg)) && outputs.runOptionalStage(cfg, "image_config") {
+		if imageConfig, err := p.inspectImageConfig(ctx, imageNames[0], imageInDaemon(cfg)); err == nil {
+			outputs.ImageConfig = imageConfig
+		}
+	}
+
+	if cfg.Push && cfg.ArchiveRegistry != "" && len(imageNames) > 0 {
+		started := time.Now()
+		archive, err := p.pushArchive(ctx, cfg, imageNames[0], outputs.Digest)
+		outputs.stage("archive", started, err)
+		if err != nil {
+			return outputs.response(false, "", fmt.Sprintf("failed to push archive copy: %v", err)), nil
+		}
+		outputs.Archive = archive
+		if outputs.Digest == "" {
+			outputs.Digest = archive.Digest
+		}
+	}
+
+	if cfg.Push {
+		if err := p.mirrorRelease(ctx, cfg, outputs); err != nil {
+			return outputs.response(false, "", fmt.Sprintf("failed to push mirror copies: %v", err)), nil
+		}
+		if resp := p.bundleStage(ctx, cfg, releaseCtx.Version, outputs); resp != nil {
+			return resp, nil
+		}
+	}
+
+	if cfg.Load && outputs.LoadedPlatform == "" {
+		if len(cfg.Platforms) == 1 {
+			outputs.LoadedPlatform = cfg.Platforms[0]
+		} else {
+			outputs.LoadedPlatform = p.daemonPlatform(ctx)
+		}
+	}
+
+	// A scorecard that cannot be updated does not undo the release.
+	if cfg.Push && cfg.ScorecardFile != "" && len(imageNames) > 0 && outputs.runOptionalStage(cfg, "scorecard") {
+		started := time.Now()
+		digest := outputs.Digest
+		var err error
+		if digest == "" {
+			digest, err = p.resolveDigest(ctx, imageNames[0])
+		}
+		var scorecard *ScorecardResult
+		if err == nil {
+			scorecard, err = p.updateScorecard(ctx, cfg, repository, releaseCtx.Version, digest)
+		}
+		outputs.stage("scorecard", started, err)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("failed to update scorecard: %v", err))
+		} else {
+			outputs.Scorecard = scorecard
+			warnings = append(warnings, scorecard.Regressions...)
+		}
+	}
+
+	if cfg.Push {
+		if w := p.recordVersion(ctx, cfg, repository, releaseCtx.Version, outputs); w != "" {
+			warnings = append(warnings, w)
+		}
+		warnings = append(warnings, p.notifyDownstreams(ctx, cfg, releaseCtx.Version, outputs)...)
+	}
+
+	checkpoint.remove()
+	if w := checkpoint.warning(); w != "" {
+		warnings = append(warnings, w)
+	}
+
+	message := fmt.Sprintf("Built and pushed Docker image with %d tags", len(resolvedTags))
+	if len(outputs.PushStats) > 0 {
+		message += fmt.Sprintf(" (%s uploaded)", formatBytes(outputs.BytesPushed))
+	}
+	if retried := retriedPushes(outputs.PushStats); len(retried) > 0 {
+		message += "; pushed " + strings.Join(retried, ", ")
+	}
+	if outputs.Scorecard != nil && len(outputs.Scorecard.Regressions) > 0 {
+		message += "; regressions: " + strings.Join(outputs.Scorecard.Regressions, "; ")
+	}
+	outputs.Warnings = warnings
+
+	return outputs.response(true, message, ""), nil
+}
+
+// resolveTags renders the tag templates in tags, defaulting to the version
+// and latest. Tags that render to an empty string, or use a placeholder of
+// the release context without a value, are dropped.
+func resolveTags(tags []string, releaseVersion string, tc tagContext) ([]string, error) {
+	if len(tags) == 0 {
+		tags = []string{"{{version}}", "latest"}
+	}
+
+	data := tc.data(releaseVersion)
+	resolvedTags := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		resolved, err := renderTag(tag, data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tag template '%s': %v", tag, err)
+		}
+
+		// Skip empty tags (e.g., when {{patch}} resolves to empty string)
+		if resolved == "" {
+			continue
+		}
+
+		// Validate resolved tag
+		if err := validateTag(resolved); err != nil {
+			return nil, fmt.Errorf("invalid tag '%s': %v", resolved, err)
+		}
+		resolvedTags = append(resolvedTags, resolved)
+	}
+	return resolvedTags, nil
+}
+
+// imageRepository returns the image reference without a tag, prefixed with
+// the registry unless it is Docker Hub.
+func imageRepository(cfg *Config) string {
+	if cfg.Registry != "" && cfg.Registry != "docker.io" {
+		return fmt.Sprintf("%s/%s", cfg.Registry, cfg.Image)
+	}
+	return cfg.Image
+}
+
+// loginTo logs in to registry, passing the password on stdin. The login is
+// bounded by login_timeout.
+func (p *DockerPlugin) loginTo(ctx context.Context, cfg *Config, registry, username, password string) error {
+	if registry == "" || registry == "docker.io" {
+		registry = ""
+	}
+
+	args := []string{"login"}
+	if registry != "" {
+		args = append(args, registry)
+	}
+	args = append(args, "-u", username, "--password-stdin")
+
+	loginCtx, cancel := withTimeout(ctx, cfg.LoginTimeout)
+	defer cancel()
+	err := p.getExecutor().Run(loginCtx, "docker", args, strings.NewReader(password))
+	return timeoutError(ctx, loginCtx, err, "login_timeout", cfg.LoginTimeout)
+}
+
+// useBuildx reports whether the build runs through docker buildx.
+func useBuildx(cfg *Config) bool {
+	return cfg.Builder != ""
+}
+
+func (p *DockerPlugin) dockerBuild(ctx context.Context, cfg *Config, imageNames []string, releaseCtx plugin.ReleaseContext) error {
+	return p.tracedBuild(ctx, cfg, imageNames, releaseCtx, nil)
+}
+
+// tracedBuild runs the build like dockerBuild. With a trace, buildx reports
+// its progress as JSON to the trace, which renders the build log.
+func (p *DockerPlugin) tracedBuild(ctx context.Context, cfg *Config, imageNames []string, releaseCtx plugin.ReleaseContext, trace *buildTrace) error {
+	args := []string{"build"}
+	if useBuildx(cfg) {
+		args = []string{"buildx", "build", "--builder", cfg.Builder}
+		args = append(args, entitlementArgs(cfg)...)
+		if trace != nil {
+			args = append(args, "--progress", "rawjson")
+		} else if cfg.Debug {
+			args = append(args, "--progress", "plain")
+		}
+		if cfg.ociOutput != "" {
+			args = append(args, "--output", "type=oci,dest="+cfg.ociOutput)
+		} else if cfg.Push {
+			args = append(args, "--push")
+			if cfg.metadataFile != "" {
+				args = append(args, "--metadata-file", cfg.metadataFile)
+			}
+		} else if cfg.Load && len(cfg.Platforms) <= 1 {
+			args = append(args, "--load")
+		}
+	}
+
+	for _, name := range imageNames {
+		args = append(args, "-t", name)
+	}
+
+	dockerfile := cfg.Dockerfile
+	if dockerfile == "" {
+		dockerfile = "Dockerfile"
+	}
+	args = append(args, "-f", dockerfile)
+
+	for key, value := range cfg.BuildArgs {
+		args = append(args, "--build-arg", fmt.Sprintf("%s=%s", key, value))
+	}
+
+	args = append(args, "--build-arg", fmt.Sprintf("VERSION=%s", releaseCtx.Version))
+	args = append(args, secretArgs(cfg)...)
+	args = append(args, inputArgs(cfg)...)
+
+	if len(cfg.Platforms) > 0 {
 		args = append(args, "--platform", strings.Join(cfg.Platforms, ","))
 	}
 
@@ -427,38 +1791,321 @@ func (p *DockerPlugin) dockerBuild(ctx context.Context, cfg *Config, imageNames
 		args = append(args, "--target", cfg.Target)
 	}
 
+	if cfg.CgroupSlice != "" {
+		args = append(args, "--cgroup-parent", cfg.CgroupSlice)
+	}
+	args = append(args, ulimitArgs(cfg)...)
+	args = append(args, cfg.ExtraBuildFlags...)
+
 	buildContext := cfg.Context
 	if buildContext == "" {
 		buildContext = "."
 	}
 	args = append(args, buildContext)
 
-	return p.getExecutor().Run(ctx, "docker", args, nil)
+	buildArgs := args
+	if cfg.Debug && useBuildx(cfg) {
+		args = debugBuildArgs(args)
+	}
+
+	name, args := wrapCommand("docker", args, debugWrapper(cfg), resourceWrapper(cfg), priorityWrapper(cfg), fileLimitWrapper(cfg))
+	buildCtx, cancel := withTimeout(ctx, cfg.BuildTimeout)
+	defer cancel()
+	var err error
+	if trace != nil {
+		err = p.getExecutor().RunCaptureStderr(buildCtx, name, args, nil, trace)
+		trace.flush()
+	} else {
+		err = p.getExecutor().Run(buildCtx, name, args, nil)
+	}
+	err = timeoutError(ctx, buildCtx, err, "build_timeout", cfg.BuildTimeout)
+	if err != nil {
+		if cfg.Debug && useBuildx(cfg) {
+			return fmt.Errorf("%w; %s", err, debugAttachInstructions(cfg, buildArgs))
+		}
+		return err
+	}
+	return nil
 }
 
 func (p *DockerPlugin) dockerPush(ctx context.Context, imageName string) error {
-	return p.getExecutor().Run(ctx, "docker", []string{"push", imageName}, nil)
+	_, err := p.pushImage(ctx, &Config{}, imageName)
+	return err
+}
+
+// pushImage pushes imageName and reports how much of it was actually uploaded.
+// The push is bounded by push_timeout.
+func (p *DockerPlugin) pushImage(ctx context.Context, cfg *Config, imageName string) (*PushStats, error) {
+	pushCtx, cancel := withTimeout(ctx, cfg.PushTimeout)
+	defer cancel()
+
+	if len(cfg.EncryptionRecipients) > 0 {
+		if err := p.pushEncrypted(pushCtx, cfg, imageName); err != nil {
+			return nil, timeoutError(ctx, pushCtx, err, "push_timeout", cfg.PushTimeout)
+		}
+		return &PushStats{Ref: imageName}, nil
+	}
+
+	name, args := wrapCommand("docker", append(append([]string{"push"}, cfg.ExtraPushFlags...), imageName), resourceWrapper(cfg), priorityWrapper(cfg), fileLimitWrapper(cfg))
+
+	var out bytes.Buffer
+	if err := p.getExecutor().RunCapture(pushCtx, name, args, nil, &out); err != nil {
+		return nil, timeoutError(ctx, pushCtx, err, "push_timeout", cfg.PushTimeout)
+	}
+
+	stats := parsePushOutput(imageName, out.Bytes())
+	if stats.LayersPushed > 0 {
+		p.resolveTransferSizes(ctx, stats)
+	}
+	return stats, nil
 }
 
 func (p *DockerPlugin) parseConfig(raw map[string]any) *Config {
+	raw, deprecations := migrateConfig(raw)
 	parser := helpers.NewConfigParser(raw)
 
-	return &Config{
+	cfg := &Config{
 		Registry:   parser.GetString("registry", "", "docker.io"),
 		Image:      parser.GetString("image", "", ""),
 		Tags:       parser.GetStringSlice("tags", nil),
+		TagAliases: parseTagAliases(raw),
 		Dockerfile: parser.GetString("dockerfile", "", "Dockerfile"),
 		Context:    parser.GetString("context", "", "."),
 		BuildArgs:  getStringMap(raw, "build_args"),
 		Platforms:  parser.GetStringSlice("platforms", nil),
 		Username:   parser.GetString("username", "DOCKER_USERNAME", ""),
 		Password:   parser.GetString("password", "DOCKER_PASSWORD", ""),
+		PAT:        getStringMap(raw, "auth")["pat"],
 		Push:       parser.GetBool("push", true),
 		Labels:     getStringMap(raw, "labels"),
 		CacheFrom:  parser.GetStringSlice("cache_from", nil),
 		NoCache:    parser.GetBool("no_cache", false),
 		Target:     parser.GetString("target", "", ""),
+		Builder:    parser.GetString("builder", "", ""),
+		Engine:     resolveEngine(parser.GetString("engine", "", engineDocker)),
+		Load:       parser.GetBool("load", false),
+
+		Daemonless: parser.GetBool("daemonless", false),
+		OCITarball: parser.GetString("oci_tarball", "", ""),
+
+		Features: parser.GetStringSlice("features", nil),
+
+		ClusterLoad: parser.GetString("cluster_load", "", ""),
+		ClusterName: parser.GetString("cluster_name", "", ""),
+
+		BuildArgSources: parseBuildArgSources(raw),
+		Secrets:         parseSources(raw, "secrets"),
+
+		TrustCerts: parser.GetStringSlice("trust_certs", nil),
+
+		ExtraBuildFlags: parser.GetStringSlice("extra_build_flags", nil),
+		ExtraPushFlags:  parser.GetStringSlice("extra_push_flags", nil),
+
+		ForbidSensitiveBuildArgs: parser.GetBool("forbid_sensitive_build_args", false),
+
+		Entitlements: parser.GetStringSlice("entitlements", nil),
+
+		CosignCopy: parser.GetBool("cosign_copy", false),
+		CosignSign: parser.GetBool("cosign_sign", false),
+		CosignKey:  parser.GetString("cosign_key", "", ""),
+
+		IndexSources: parser.GetStringSlice("index_sources", nil),
+		IndexTimeout: parser.GetString("index_timeout", "", ""),
+
+		CPUSet:      parser.GetString("cpuset", "", ""),
+		CgroupSlice: parser.GetString("cgroup_slice", "", ""),
+		Priority:    parser.GetString("priority", "", priorityNormal),
+
+		Ulimits:        parser.GetStringSlice("ulimits", nil),
+		NofileLimit:    parser.GetInt("nofile_limit", 0),
+		MaxParallelism: parser.GetInt("max_parallelism", 0),
+
+		BuilderNodes:  parseBuilderNodes(raw),
+		BuilderDriver: parser.GetString("builder_driver", "", ""),
+
+		ReuseIdentical: parser.GetBool("reuse_identical", false),
+
+		Resume:         parser.GetBool("resume", false),
+		CheckpointFile: parser.GetString("checkpoint_file", "", ""),
+
+		Debug: parser.GetBool("debug", false),
+
+		FixDockerignore: parser.GetBool("fix_dockerignore", false),
+
+		CleanCheckout:        parser.GetBool("clean_checkout", false),
+		RequireCleanWorktree: parser.GetBool("require_clean_worktree", false),
+		VerifyCommit:         parser.GetBool("verify_commit", false),
+
+		BuildTimings:      parser.GetBool("build_timings", false),
+		CacheHitThreshold: parser.GetFloat("cache_hit_threshold", 0),
+
+		PushRetries:      parser.GetInt("push_retries", 0),
+		PushRetryBackoff: parser.GetString("push_retry_backoff", "", defaultPushRetryBackoff),
+		PushRateLimit:    parser.GetString("push_rate_limit", "", ""),
+
+		RegistryType:      parser.GetString("registry_type", "", ""),
+		ECR:               parseECR(raw),
+		QuotaCheck:        parser.GetBool("quota_check", false),
+		QuotaStrict:       parser.GetBool("quota_strict", false),
+		QuotaAllowOverrun: parser.GetBool("quota_allow_overrun", false),
+
+		GCPCredentials: parser.GetString("gcp_credentials", "", ""),
+		ACR:            parseACR(raw),
+
+		ArchiveRegistry: parser.GetString("archive_registry", "", ""),
+		ArchiveImage:    parser.GetString("archive_image", "", ""),
+		ArchiveUsername: parser.GetString("archive_username", "DOCKER_ARCHIVE_USERNAME", ""),
+		ArchivePassword: parser.GetString("archive_password", "DOCKER_ARCHIVE_PASSWORD", ""),
+
+		Mirrors: parseMirrors(raw),
+
+		Downstreams: parseDownstreams(raw),
+
+		E2E: parseE2E(raw),
+
+		ExecCompat: parseExecCompat(raw),
+
+		Canary: parseCanary(raw, os.Getenv("DOCKER_APPROVAL_TOKEN")),
+
+		Approval: parseApproval(raw, os.Getenv("DOCKER_APPROVAL_TOKEN")),
+
+		PushWindow: parsePushWindow(raw),
+
+		ReleaseLock: parseReleaseLock(raw),
+
+		BaseImageTrust: parseBaseImageTrust(raw),
+
+		ImageNaming: parseNamingPolicy(raw),
+
+		RequiredLabels: parser.GetStringSlice("required_labels", nil),
+
+		RootUser:          parser.GetString("root_user", "", rootUserAllow),
+		RootUserAllowlist: parser.GetStringSlice("root_user_allowlist", nil),
+
+		Inputs: parseInputs(raw),
+
+		StaticBinaries:       parser.GetStringSlice("static_binaries", nil),
+		VerifyEntrypointArch: parser.GetBool("verify_entrypoint_arch", false),
+
+		ScorecardFile:          parser.GetString("scorecard_file", "", ""),
+		ScorecardSizeThreshold: parser.GetFloat("scorecard_size_threshold", defaultScorecardSizeThreshold),
+		ScorecardScanReport:    parser.GetString("scorecard_scan_report", "", ""),
+
+		VersionManifest: parser.GetString("version_manifest", "", ""),
+
+		MaxDuration:  parser.GetString("max_duration", "", ""),
+		BuildTimeout: parser.GetString("build_timeout", "", ""),
+		PushTimeout:  parser.GetString("push_timeout", "", ""),
+		LoginTimeout: parser.GetString("login_timeout", "", ""),
+
+		RegistryReadyTimeout: parser.GetString("registry_ready_timeout", "", ""),
+		RegistryKeepalive:    parser.GetString("registry_keepalive", "", ""),
+
+		RegistryCacheTTL: parser.GetString("registry_cache_ttl", "", ""),
+		RegistryCacheDir: parser.GetString("registry_cache_dir", "", ""),
+
+		EncryptionRecipients:        parser.GetStringSlice("encryption_recipients", nil),
+		EncryptionKeyProviderConfig: parser.GetString("encryption_keyprovider_config", "OCICRYPT_KEYPROVIDER_CONFIG", ""),
+
+		AllowedRegistries: parser.GetStringSlice("allowed_registries", nil),
+		AllowedBaseImages: parser.GetStringSlice("allowed_base_images", nil),
+		Insecure:          parser.GetBool("insecure", false),
+
+		LoginLocalRegistry: parser.GetBool("login_local_registry", false),
+
+		UseCredentialHelper: parser.GetBool("use_credential_helper", false),
+
+		SelfTest: parser.GetBool("selftest", false),
+
+		RollingTagCheck: parser.GetBool("rolling_tag_check", false),
+
+		TagSanitize: parser.GetString("tag_sanitize", "", tagSanitizeReplace),
+		ChannelTags: parseTagLists(raw, "channel_tags"),
+
+		LatestOnPrerelease: parser.GetBool("latest_on_prerelease", false),
+		DryRunRemoteChecks: parser.GetBool("dry_run_remote_checks", false),
+		SkipIfExists:       parser.GetBool("skip_if_exists", false),
+		ClassicFallback:    parser.GetBool("classic_fallback", false),
+		RollbackDeleteTags: parser.GetBool("rollback_delete_tags", false),
+		AppendPlatforms:    getStringOrSlice(parser, "append_platform"),
+
+		AuditFile:       parser.GetString("audit_file", "", ""),
+		AuditSigningKey: parser.GetString("audit_signing_key", "DOCKER_AUDIT_SIGNING_KEY", ""),
+		FailureReport:   parser.GetString("failure_report", "", ""),
+
+		PasswordCommand: parser.GetStringSlice("password_command", nil),
+		TokenTTL:        parser.GetString("token_ttl", "", ""),
+
+		UserAgent:       parser.GetString("user_agent", "", ""),
+		RegistryHeaders: getStringMap(raw, "registry_headers"),
+
+		SplitPhases:     parser.GetBool("split_phases", false),
+		Phase:           parser.GetString("phase", "", ""),
+		PhaseStateFile:  parser.GetString("phase_state_file", "", ""),
+		Verify:          parser.GetBool("verify", false),
+		RollbackOnError: parser.GetBool("rollback_on_error", false),
+
+		Bundle:     parser.GetString("bundle", "", ""),
+		BundleFile: parser.GetString("bundle_file", "", ""),
+	}
+
+	// Only a local registry is split off the image: other registries in the
+	// image name, such as ghcr.io/org/app, stay part of the image, so the
+	// image and registry outputs and the login target are unchanged.
+	if registry, name := splitImageRegistry(cfg.Image); isLocalRegistry(registry) && cfg.Registry == "docker.io" {
+		cfg.Registry, cfg.Image = registry, name
+	}
+
+	for _, registry := range []*string{&cfg.Registry, &cfg.ArchiveRegistry} {
+		explicitTLS := strings.HasPrefix(*registry, "https://")
+		host, plaintext := splitRegistryScheme(*registry)
+		if plaintext {
+			cfg.plaintextRegistries = append(cfg.plaintextRegistries, host)
+		} else if !explicitTLS && isLocalRegistry(host) {
+			cfg.localRegistries = append(cfg.localRegistries, host)
+		}
+		*registry = host
+	}
+
+	if len(cfg.BuilderNodes) > 0 && cfg.Builder == "" {
+		cfg.Builder = defaultBuilderName
+	}
+
+	if cfg.Phase == phaseSplit {
+		cfg.SplitPhases = true
+	}
+
+	if cfg.PAT == "" {
+		cfg.PAT = os.Getenv("DOCKER_PAT")
+	}
+
+	cfg.variant, _ = raw[variantKey].(string)
+	applyRegistries(cfg, raw)
+	if !cfg.UseCredentialHelper {
+		applyECR(cfg)
+		applyGAR(cfg)
+		applyACR(cfg)
+		applyGHCR(cfg)
+	}
+	cfg.featureWarnings = applyFeatures(cfg, timeNow())
+
+	if len(cfg.Platforms) == 0 {
+		cfg.Platforms = defaultPlatforms()
+	}
+
+	// Classic docker build cannot produce a multi-platform image; podman
+	// builds it as a manifest list.
+	if len(cfg.Platforms) > 1 && cfg.Builder == "" && cfg.Engine != enginePodman && !cfg.Daemonless {
+		cfg.Builder = defaultBuilderName
+		cfg.autoBuilder = true
+	}
+
+	if len(deprecations) > 0 {
+		cfg.deprecations = deprecationMessages(deprecations)
+		cfg.canonicalConfig = redactConfig(raw)
 	}
+
+	return cfg
 }
 
 func getStringMap(raw map[string]any, key string) map[string]string {
@@ -476,7 +2123,14 @@ func getStringMap(raw map[string]any, key string) map[string]string {
 }
 
 // Validate validates the plugin configuration.
-func (p *DockerPlugin) Validate(_ context.Context, config map[string]any) (*plugin.ValidateResponse, error) {
+func (p *DockerPlugin) Validate(ctx context.Context, config map[string]any) (*plugin.ValidateResponse, error) {
+	if _, ok := config["images"]; ok {
+		return p.validateImages(ctx, config)
+	}
+	if _, ok := config["variants"]; ok {
+		return p.validateVariants(ctx, config)
+	}
+	config, deprecations := migrateConfig(config)
 	vb := helpers.NewValidationBuilder()
 	parser := helpers.NewConfigParser(config)
 
@@ -484,44 +2138,45 @@ func (p *DockerPlugin) Validate(_ context.Context, config map[string]any) (*plug
 	image := parser.GetString("image", "", "")
 	if image == "" {
 		vb.AddError("image", "Docker image name is required")
-	} else if err := validateImageName(image); err != nil {
-		vb.AddError("image", err.Error())
 	}
 
-	// Validate registry if provided
-	registry := parser.GetString("registry", "", "docker.io")
-	if err := validateRegistry(registry); err != nil {
-		vb.AddError("registry", err.Error())
-	}
+	cfg := p.parseConfig(config)
+	addValidatorErrors(vb, cfg, releaseValidators, map[string]bool{"image": image == ""})
 
-	// Validate dockerfile path
-	dockerfile := parser.GetString("dockerfile", "", "Dockerfile")
-	if err := validatePath(dockerfile); err != nil {
-		vb.AddError("dockerfile", err.Error())
+	// Validate registry response cache
+	if err := validateTimeout("registry_cache_ttl", cfg.RegistryCacheTTL); err != nil {
+		vb.AddError("registry_cache_ttl", err.Error())
 	}
 
-	// Validate context path
-	contextPath := parser.GetString("context", "", ".")
-	if err := validatePath(contextPath); err != nil {
-		vb.AddError("context", err.Error())
+	// Validate credential refresh
+	if err := validatePasswordCommand(cfg.PasswordCommand, cfg.TokenTTL); err != nil {
+		vb.AddError("password_command", err.Error())
 	}
 
-	// Validate build args keys
-	if buildArgs, ok := config["build_args"].(map[string]any); ok {
-		for key := range buildArgs {
-			if err := validateBuildArgKey(key); err != nil {
-				vb.AddError("build_args", fmt.Sprintf("invalid key '%s': %s", key, err.Error()))
-			}
+	// Validate audit settings
+	if err := validatePath(cfg.AuditFile); err != nil {
+		vb.AddError("audit_file", err.Error())
+	}
+	if cfg.AuditSigningKey != "" {
+		if _, err := loadAuditSigningKey(cfg.AuditSigningKey); err != nil {
+			vb.AddError("audit_signing_key", err.Error())
 		}
 	}
 
-	// Validate label keys
-	if labels, ok := config["labels"].(map[string]any); ok {
-		for key := range labels {
-			if err := validateLabelKey(key); err != nil {
-				vb.AddError("labels", fmt.Sprintf("invalid key '%s': %s", key, err.Error()))
-			}
-		}
+	// Validate failure report path
+	if err := validatePath(cfg.FailureReport); err != nil {
+		vb.AddError("failure_report", err.Error())
+	}
+
+	// Validate sensitive build args
+	if _, err := checkSensitiveBuildArgs(cfg); err != nil {
+		vb.AddError("build_args", err.Error())
+	}
+
+	// Validate required labels. An unreadable Dockerfile is reported when
+	// the release runs.
+	if missing, err := missingLabels(cfg); err == nil && len(missing) > 0 && len(cfg.IndexSources) == 0 {
+		vb.AddError("required_labels", fmt.Sprintf("missing required labels %s", strings.Join(missing, ", ")))
 	}
 
 	// Validate tags
@@ -536,5 +2191,43 @@ func (p *DockerPlugin) Validate(_ context.Context, config map[string]any) (*plug
 		}
 	}
 
-	return vb.Build(), nil
+	if err := validateTagTemplates(tags); err != nil {
+		vb.AddError("tags", err.Error())
+	}
+
+	// Validate channel tags
+	if err := validateChannelTags(cfg); err != nil {
+		vb.AddError("channel_tags", err.Error())
+	}
+
+	// Validate tag sanitization
+	if err := validateTagSanitize(cfg.TagSanitize); err != nil {
+		vb.AddError("tag_sanitize", err.Error())
+	}
+
+	resp := vb.Build()
+	addDeprecations(resp, deprecations)
+	addWarnings(resp, "features", cfg.featureWarnings)
+	if cfg.RollingTagCheck && !slices.ContainsFunc(allTagTemplates(cfg), func(tag string) bool { return rollingScope(tag) != "" }) {
+		addWarnings(resp, "rolling_tag_check", []string{"rolling_tag_check has no effect: no tag follows {{major}} or {{major}}.{{minor}}"})
+	}
+	if cfg.RollbackDeleteTags && !cfg.RollbackOnError {
+		addWarnings(resp, "rollback_delete_tags", []string{"rollback_delete_tags has no effect without rollback_on_error"})
+	}
+	if w := extraPushFlagsWarning(cfg); w != "" {
+		addWarnings(resp, "extra_push_flags", []string{w})
+	}
+
+	// A missing Dockerfile may be generated later in the pipeline.
+	if validatePath(cfg.Dockerfile) == nil {
+		if warnings, err := dockerfileArgWarnings(cfg); err == nil {
+			addWarnings(resp, "build_args", warnings)
+		}
+		if validatePath(cfg.Context) == nil {
+			if warnings, err := dockerignoreWarnings(cfg); err == nil {
+				addWarnings(resp, "context", warnings)
+			}
+		}
+	}
+	return resp, nil
 }
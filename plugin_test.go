@@ -19,6 +19,14 @@ type MockCommandExecutor struct {
 	FailOnCall  int // Which call number should fail (1-indexed, 0 means never fail)
 	callCount   int
 	FailWithErr error
+
+	OutputFunc  func(ctx context.Context, name string, args []string) ([]byte, error)
+	OutputCalls []MockRunCall
+
+	// StdoutFunc supplies the standard output written by RunCapture.
+	StdoutFunc func(name string, args []string) string
+	// StderrFunc supplies the standard error written by RunCaptureStderr.
+	StderrFunc func(name string, args []string) string
 }
 
 // MockRunCall records a call to Run.
@@ -58,6 +66,58 @@ func (m *MockCommandExecutor) Run(ctx context.Context, name string, args []strin
 	return nil
 }
 
+// RunCapture implements CommandExecutor. Calls are recorded like Run.
+func (m *MockCommandExecutor) RunCapture(ctx context.Context, name string, args []string, stdin io.Reader, stdout io.Writer) error {
+	if err := m.Run(ctx, name, args, stdin); err != nil {
+		return err
+	}
+	if m.StdoutFunc != nil {
+		_, _ = io.WriteString(stdout, m.StdoutFunc(name, args))
+	}
+	return nil
+}
+
+// RunCaptureStderr implements CommandExecutor. Calls are recorded like Run;
+// the standard error is written even when the call fails.
+func (m *MockCommandExecutor) RunCaptureStderr(ctx context.Context, name string, args []string, stdin io.Reader, stderr io.Writer) error {
+	err := m.Run(ctx, name, args, stdin)
+	if m.StderrFunc != nil {
+		_, _ = io.WriteString(stderr, m.StderrFunc(name, args))
+	}
+	return err
+}
+
+// Output implements CommandExecutor.
+func (m *MockCommandExecutor) Output(ctx context.Context, name string, args []string) ([]byte, error) {
+	m.OutputCalls = append(m.OutputCalls, MockRunCall{
+		Name: name,
+		Args: args,
+	})
+
+	if m.OutputFunc != nil {
+		return m.OutputFunc(ctx, name, args)
+	}
+
+	return nil, nil
+}
+
+// OutputInput implements CommandExecutor. Calls are recorded like Output,
+// with their standard input.
+func (m *MockCommandExecutor) OutputInput(ctx context.Context, name string, args []string, stdin io.Reader) ([]byte, error) {
+	data, _ := io.ReadAll(stdin)
+	m.OutputCalls = append(m.OutputCalls, MockRunCall{
+		Name:  name,
+		Args:  args,
+		Stdin: string(data),
+	})
+
+	if m.OutputFunc != nil {
+		return m.OutputFunc(ctx, name, args)
+	}
+
+	return nil, nil
+}
+
 func TestGetInfo(t *testing.T) {
 	p := &DockerPlugin{}
 	info := p.GetInfo()
@@ -1016,6 +1076,7 @@ func TestBuildAndPushVersionParsing(t *testing.T) {
 		version      string
 		tags         []any
 		expectedTags []string
+		wantErr      string
 	}{
 		{
 			name:         "full semver",
@@ -1036,10 +1097,10 @@ func TestBuildAndPushVersionParsing(t *testing.T) {
 			expectedTags: []string{"1", "1"}, // {{minor}} and {{patch}} resolve to empty, filtered out
 		},
 		{
-			name:         "empty version parts",
-			version:      "v",
-			tags:         []any{"{{version}}", "{{major}}"},
-			expectedTags: []string{}, // both resolve to empty, filtered out
+			name:    "empty version parts",
+			version: "v",
+			tags:    []any{"{{version}}", "{{major}}"},
+			wantErr: "no tags to push", // both resolve to empty, filtered out
 		},
 	}
 
@@ -1063,6 +1124,12 @@ func TestBuildAndPushVersionParsing(t *testing.T) {
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
+			if tt.wantErr != "" {
+				if resp.Success || !strings.Contains(resp.Error, tt.wantErr) {
+					t.Errorf("expected error containing %q, got %+v", tt.wantErr, resp)
+				}
+				return
+			}
 
 			tags := resp.Outputs["tags"].([]string)
 			for i, expected := range tt.expectedTags {
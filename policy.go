@@ -0,0 +1,297 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// allowedRegistriesEnv names the runner-level registry allowlist. It applies
+// in addition to allowed_registries so a modified release config cannot widen it.
+const allowedRegistriesEnv = "DOCKER_ALLOWED_REGISTRIES"
+
+// normalizeRegistry maps the Docker Hub spellings to docker.io and lowercases hosts.
+func normalizeRegistry(registry string) string {
+	registry = strings.ToLower(strings.TrimSpace(registry))
+	switch registry {
+	case "", "docker.io", "index.docker.io", "registry-1.docker.io":
+		return "docker.io"
+	}
+	return registry
+}
+
+// registryAllowed reports whether registry matches any of the patterns.
+// Patterns are hostnames with optional glob wildcards, e.g. *.internal.example.com.
+func registryAllowed(registry string, patterns []string) bool {
+	registry = normalizeRegistry(registry)
+	for _, pattern := range patterns {
+		pattern = normalizeRegistry(pattern)
+		if ok, _ := path.Match(pattern, registry); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// envAllowedRegistries returns the allowlist from the environment, if any.
+func envAllowedRegistries() []string {
+	var patterns []string
+	for _, pattern := range strings.Split(os.Getenv(allowedRegistriesEnv), ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
+
+// validateAllowedRegistryPatterns validates allowed_registries entries.
+func validateAllowedRegistryPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil || strings.ContainsAny(pattern, "/ ") || pattern == "" {
+			return fmt.Errorf("invalid registry pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// pushRepositories returns every repository the configuration pushes to:
+// the image, the canary, the archive copy and the mirrors.
+func pushRepositories(cfg *Config) []string {
+	repositories := []string{imageRepository(cfg)}
+	if cfg.Canary != nil {
+		repositories = append(repositories, canaryRepository(cfg))
+	}
+	if cfg.ArchiveRegistry != "" {
+		repositories = append(repositories, archiveRepository(cfg))
+	}
+	for _, mirror := range cfg.Mirrors {
+		repositories = append(repositories, mirrorRepository(cfg, mirror))
+	}
+	return repositories
+}
+
+// configuredRegistries returns every registry the configuration pushes to:
+// the configured registries and the registry hosts of the pushed
+// repositories, resolved as docker resolves them, so an image name carrying
+// its own registry cannot escape the allowlist.
+func configuredRegistries(cfg *Config) []string {
+	registries := []string{normalizeRegistry(cfg.Registry)}
+	if cfg.ArchiveRegistry != "" {
+		registries = append(registries, normalizeRegistry(cfg.ArchiveRegistry))
+	}
+	for _, mirror := range cfg.Mirrors {
+		registries = append(registries, normalizeRegistry(mirror.Registry))
+	}
+	for _, repository := range pushRepositories(cfg) {
+		if registry := referenceRegistry(repository); !slices.Contains(registries, registry) {
+			registries = append(registries, registry)
+		}
+	}
+	return registries
+}
+
+// checkRegistryPolicy fails when a configured registry is outside the
+// allowlists. Both the config allowlist and the environment allowlist must
+// admit the registry when both are set.
+func checkRegistryPolicy(cfg *Config) error {
+	allowlists := []struct {
+		source   string
+		patterns []string
+	}{
+		{"allowed_registries", cfg.AllowedRegistries},
+		{allowedRegistriesEnv, envAllowedRegistries()},
+	}
+
+	for _, registry := range configuredRegistries(cfg) {
+		for _, allowlist := range allowlists {
+			if len(allowlist.patterns) == 0 {
+				continue
+			}
+			if !registryAllowed(registry, allowlist.patterns) {
+				return fmt.Errorf("registry %s is not permitted by %s (%s)",
+					normalizeRegistry(registry), allowlist.source, strings.Join(allowlist.patterns, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+// normalizeImageName expands a Docker Hub reference to its full name, e.g.
+// alpine to docker.io/library/alpine, so patterns and FROM lines compare
+// regardless of spelling.
+func normalizeImageName(ref string) string {
+	registry := referenceRegistry(ref)
+	name := ref
+	if first, rest, ok := strings.Cut(ref, "/"); ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		name = rest
+	}
+	if registry == "docker.io" && !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+	return registry + "/" + name
+}
+
+// validateBaseImagePatterns validates allowed_base_images entries.
+func validateBaseImagePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil || strings.ContainsAny(pattern, " \t") || pattern == "" {
+			return fmt.Errorf("invalid base image pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// baseImageAllowed reports whether ref matches any of the patterns. Patterns
+// match the repository or the full reference, so they may pin tags, and *
+// matches across path components: cgr.dev/chainguard/* allows every image
+// below cgr.dev/chainguard.
+func baseImageAllowed(ref string, patterns []string) bool {
+	full := normalizeImageName(ref)
+	repository := full
+	if i := strings.Index(repository, "@"); i >= 0 {
+		repository = repository[:i]
+	}
+	if i := strings.LastIndex(repository, ":"); i > strings.LastIndex(repository, "/") {
+		repository = repository[:i]
+	}
+	for _, pattern := range patterns {
+		pattern = normalizeImageName(pattern)
+		if globMatch(pattern, repository) || globMatch(pattern, full) {
+			return true
+		}
+	}
+	return false
+}
+
+// globMatch matches name against a glob pattern whose * may span slashes.
+func globMatch(pattern, name string) bool {
+	ok, _ := path.Match(strings.ReplaceAll(pattern, "/", "\x00"), strings.ReplaceAll(name, "/", "\x00"))
+	return ok
+}
+
+// checkBaseImagePolicy fails when a base image is outside
+// allowed_base_images.
+func checkBaseImagePolicy(cfg *Config, bases []string) error {
+	if len(cfg.AllowedBaseImages) == 0 {
+		return nil
+	}
+	for _, base := range bases {
+		if !baseImageAllowed(base, cfg.AllowedBaseImages) {
+			return fmt.Errorf("base image %s is not permitted by allowed_base_images (%s)", base, strings.Join(cfg.AllowedBaseImages, ", "))
+		}
+	}
+	return nil
+}
+
+// NamingPolicy is the organization's naming convention for image
+// repositories, checked against image without the registry.
+type NamingPolicy struct {
+	// Pattern is a regular expression the whole name must match.
+	Pattern string
+	// Prefix is a required leading path, e.g. payments/.
+	Prefix    string
+	MaxLength int
+}
+
+// parseNamingPolicy reads the image_naming option, returning nil when it is
+// not set.
+func parseNamingPolicy(raw map[string]any) *NamingPolicy {
+	v, ok := raw["image_naming"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	policy := &NamingPolicy{}
+	policy.Pattern, _ = v["pattern"].(string)
+	policy.Prefix, _ = v["prefix"].(string)
+	switch n := v["max_length"].(type) {
+	case int:
+		policy.MaxLength = n
+	case float64:
+		policy.MaxLength = int(n)
+	}
+	return policy
+}
+
+// validateNamingPolicy checks the image_naming settings.
+func validateNamingPolicy(cfg *Config) error {
+	policy := cfg.ImageNaming
+	if policy == nil {
+		return nil
+	}
+	if _, err := regexp.Compile(policy.Pattern); err != nil {
+		return fmt.Errorf("invalid pattern: %v", err)
+	}
+	if policy.MaxLength < 0 {
+		return fmt.Errorf("max_length must not be negative")
+	}
+	return nil
+}
+
+// checkNamingPolicy fails when the image name breaks image_naming, naming
+// every rule it breaks.
+func checkNamingPolicy(cfg *Config) error {
+	policy := cfg.ImageNaming
+	if policy == nil {
+		return nil
+	}
+	var violations []string
+	if policy.Pattern != "" {
+		if re, err := regexp.Compile("^(?:" + policy.Pattern + ")$"); err == nil && !re.MatchString(cfg.Image) {
+			violations = append(violations, fmt.Sprintf("does not match pattern %s", policy.Pattern))
+		}
+	}
+	if policy.Prefix != "" && !strings.HasPrefix(cfg.Image, policy.Prefix) {
+		violations = append(violations, fmt.Sprintf("does not start with %s", policy.Prefix))
+	}
+	if policy.MaxLength > 0 && len(cfg.Image) > policy.MaxLength {
+		violations = append(violations, fmt.Sprintf("is longer than %d characters", policy.MaxLength))
+	}
+	if len(violations) > 0 {
+		return fmt.Errorf("image %s %s", cfg.Image, strings.Join(violations, ", "))
+	}
+	return nil
+}
+
+// missingLabels returns the required_labels the image would be built
+// without: neither set by labels, nor added by the plugin, nor by the
+// Dockerfile.
+func missingLabels(cfg *Config) ([]string, error) {
+	if len(cfg.RequiredLabels) == 0 {
+		return nil, nil
+	}
+	present := make(map[string]bool, len(cfg.Labels)+1)
+	for key := range cfg.Labels {
+		present[key] = true
+	}
+	if cfg.ReuseIdentical {
+		present[sourceDigestLabel] = true
+	}
+
+	dockerfile := cfg.Dockerfile
+	if dockerfile == "" {
+		dockerfile = "Dockerfile"
+	}
+	f, err := os.Open(dockerfile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	keys, err := parseDockerfileLabels(f, cfg.Target)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		present[key] = true
+	}
+
+	var missing []string
+	for _, key := range cfg.RequiredLabels {
+		if !present[key] {
+			missing = append(missing, key)
+		}
+	}
+	return missing, nil
+}
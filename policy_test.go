@@ -0,0 +1,364 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestRegistryAllowed(t *testing.T) {
+	patterns := []string{"docker.io", "ghcr.io", "*.internal.example.com", "registry.local:5000"}
+
+	tests := []struct {
+		registry string
+		allowed  bool
+	}{
+		{"", true},
+		{"docker.io", true},
+		{"index.docker.io", true},
+		{"GHCR.IO", true},
+		{"harbor.internal.example.com", true},
+		{"registry.local:5000", true},
+		{"registry.local:5001", false},
+		{"evil.example.net", false},
+		{"internal.example.com.evil.net", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.registry, func(t *testing.T) {
+			if got := registryAllowed(tt.registry, patterns); got != tt.allowed {
+				t.Errorf("registryAllowed(%q) = %v, want %v", tt.registry, got, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestCheckRegistryPolicy(t *testing.T) {
+	t.Run("no allowlist permits everything", func(t *testing.T) {
+		t.Setenv(allowedRegistriesEnv, "")
+		if err := checkRegistryPolicy(&Config{Registry: "anything.example.com"}); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("archive registry is checked", func(t *testing.T) {
+		t.Setenv(allowedRegistriesEnv, "")
+		cfg := &Config{Registry: "ghcr.io", ArchiveRegistry: "attacker.example.net", AllowedRegistries: []string{"ghcr.io"}}
+		err := checkRegistryPolicy(cfg)
+		if err == nil || !strings.Contains(err.Error(), "attacker.example.net") {
+			t.Errorf("expected archive registry violation, got %v", err)
+		}
+	})
+
+	t.Run("registries of pushed references are checked", func(t *testing.T) {
+		t.Setenv(allowedRegistriesEnv, "")
+		for name, cfg := range map[string]*Config{
+			"image":        {Registry: "docker.io", Image: "attacker.example.com/app"},
+			"canary image": {Registry: "docker.io", Image: "myorg/app", Canary: &CanaryConfig{Image: "attacker.example.com/app"}},
+			"mirror image": {Registry: "docker.io", Image: "myorg/app", Mirrors: []Mirror{{Registry: "docker.io", Image: "attacker.example.com/app"}}},
+		} {
+			cfg.AllowedRegistries = []string{"docker.io"}
+			err := checkRegistryPolicy(cfg)
+			if err == nil || !strings.Contains(err.Error(), "attacker.example.com") {
+				t.Errorf("%s: expected a violation for attacker.example.com, got %v", name, err)
+			}
+		}
+	})
+
+	t.Run("environment allowlist cannot be widened by config", func(t *testing.T) {
+		t.Setenv(allowedRegistriesEnv, "ghcr.io, registry.internal")
+		cfg := &Config{Registry: "evil.example.net", AllowedRegistries: []string{"evil.example.net"}}
+		err := checkRegistryPolicy(cfg)
+		if err == nil || !strings.Contains(err.Error(), allowedRegistriesEnv) {
+			t.Errorf("expected environment allowlist violation, got %v", err)
+		}
+	})
+}
+
+func TestRegistryPolicyEnforcement(t *testing.T) {
+	t.Setenv(allowedRegistriesEnv, "")
+	config := map[string]any{
+		"image":              "myorg/myapp",
+		"registry":           "evil.example.net",
+		"allowed_registries": []any{"ghcr.io", "docker.io"},
+	}
+
+	p := &DockerPlugin{executor: &MockCommandExecutor{}}
+
+	vresp, err := p.Validate(context.Background(), config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if vresp.Valid {
+		t.Error("expected Validate to reject disallowed registry")
+	}
+
+	mock := &MockCommandExecutor{}
+	p.executor = mock
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  config,
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success || !strings.Contains(resp.Error, "registry policy violation") {
+		t.Errorf("expected policy violation, got %+v", resp)
+	}
+	if len(mock.RunCalls) != 0 {
+		t.Errorf("expected no commands to run, got %d", len(mock.RunCalls))
+	}
+}
+
+func TestValidateAllowedRegistryPatterns(t *testing.T) {
+	if err := validateAllowedRegistryPatterns([]string{"*.example.com", "ghcr.io"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := validateAllowedRegistryPatterns([]string{"ghcr.io/org"}); err == nil {
+		t.Error("expected error for pattern with path")
+	}
+	if err := validateAllowedRegistryPatterns([]string{"[invalid"}); err == nil {
+		t.Error("expected error for malformed glob")
+	}
+}
+
+func TestBaseImageAllowed(t *testing.T) {
+	patterns := []string{"cgr.dev/chainguard/*", "registry.internal/*", "alpine:3.*"}
+
+	tests := []struct {
+		ref     string
+		allowed bool
+	}{
+		{"cgr.dev/chainguard/go", true},
+		{"cgr.dev/chainguard/go:latest-dev", true},
+		{"cgr.dev/chainguard/static@sha256:" + strings.Repeat("a", 64), true},
+		{"registry.internal/team/base:1.2", true},
+		{"alpine:3.19", true},
+		{"docker.io/library/alpine:3.20", true},
+		{"alpine:edge", false},
+		{"alpine", false},
+		{"cgr.dev/other/go", false},
+		{"registry.internal.evil.com/base", false},
+		{"ubuntu:24.04", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			if got := baseImageAllowed(tt.ref, patterns); got != tt.allowed {
+				t.Errorf("baseImageAllowed(%q) = %v, want %v", tt.ref, got, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestBaseImagePolicyEnforcement(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+	writeFiles(t, dir, map[string]string{
+		"Dockerfile": "ARG BASE=cgr.dev/chainguard/static\nFROM cgr.dev/chainguard/go AS build\nFROM ${BASE}\nCOPY --from=build /app /app\n",
+	})
+
+	run := func(config map[string]any) (*plugin.ExecuteResponse, *MockCommandExecutor) {
+		t.Helper()
+		mock := &MockCommandExecutor{}
+		p := &DockerPlugin{executor: mock}
+		resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+			Hook:    plugin.HookPostPublish,
+			Config:  config,
+			Context: plugin.ReleaseContext{Version: "1.0.0"},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return resp, mock
+	}
+
+	resp, _ := run(map[string]any{"image": "myapp", "allowed_base_images": []any{"cgr.dev/chainguard/*"}})
+	if !resp.Success {
+		t.Fatalf("expected approved bases to build, got error: %s", resp.Error)
+	}
+
+	resp, mock := run(map[string]any{
+		"image":               "myapp",
+		"allowed_base_images": []any{"cgr.dev/chainguard/*"},
+		"build_args":          map[string]any{"BASE": "debian:12"},
+	})
+	if resp.Success || !strings.Contains(resp.Error, "base image policy violation: base image debian:12 is not permitted") {
+		t.Fatalf("expected base image policy violation, got %+v", resp)
+	}
+	if len(mock.RunCalls) != 0 {
+		t.Errorf("expected no commands on an unapproved base, got %v", mock.RunCalls)
+	}
+
+	writeFiles(t, dir, map[string]string{
+		"Dockerfile": "FROM cgr.dev/chainguard/static\nCOPY --from=docker.io/someone/tools:latest /bin/tool /bin/tool\n",
+	})
+	resp, _ = run(map[string]any{"image": "myapp", "allowed_base_images": []any{"cgr.dev/chainguard/*"}})
+	if resp.Success || !strings.Contains(resp.Error, "base image docker.io/someone/tools:latest is not permitted") {
+		t.Fatalf("expected an unapproved COPY --from image to be rejected, got %+v", resp)
+	}
+}
+
+func TestValidateBaseImagePatterns(t *testing.T) {
+	if err := validateBaseImagePatterns([]string{"cgr.dev/chainguard/*", "alpine:3.*"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for _, pattern := range []string{"", "cgr.dev/[", "cgr.dev/ chainguard"} {
+		if err := validateBaseImagePatterns([]string{pattern}); err == nil {
+			t.Errorf("expected error for pattern %q", pattern)
+		}
+	}
+}
+
+func TestCheckNamingPolicy(t *testing.T) {
+	policy := &NamingPolicy{Pattern: `[a-z]+/[a-z][a-z0-9-]*`, Prefix: "payments/", MaxLength: 24}
+
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{"payments/ledger-api", ""},
+		{"payments/Ledger", "does not match pattern"},
+		{"checkout/cart", "does not start with payments/"},
+		{"payments/settlement-reconciler", "is longer than 24 characters"},
+		{"payments", "does not match pattern [a-z]+/[a-z][a-z0-9-]*, does not start with payments/"},
+	}
+	for _, tt := range tests {
+		err := checkNamingPolicy(&Config{Image: tt.image, ImageNaming: policy})
+		if tt.want == "" && err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.image, err)
+		}
+		if tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.image, tt.want, err)
+		}
+	}
+}
+
+func TestNamingPolicyEnforcement(t *testing.T) {
+	config := map[string]any{
+		"image":        "myapp",
+		"image_naming": map[string]any{"prefix": "payments/"},
+	}
+
+	p := &DockerPlugin{executor: &MockCommandExecutor{}}
+
+	vresp, err := p.Validate(context.Background(), config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if vresp.Valid {
+		t.Error("expected Validate to reject a misnamed image")
+	}
+
+	mock := &MockCommandExecutor{}
+	p.executor = mock
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  config,
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success || !strings.Contains(resp.Error, "image naming policy violation") {
+		t.Errorf("expected policy violation, got %+v", resp)
+	}
+	if len(mock.RunCalls) != 0 {
+		t.Errorf("expected no commands to run, got %d", len(mock.RunCalls))
+	}
+}
+
+func TestValidateNamingPolicy(t *testing.T) {
+	if err := validateNamingPolicy(&Config{ImageNaming: &NamingPolicy{Pattern: "[invalid"}}); err == nil {
+		t.Error("expected error for malformed pattern")
+	}
+	if err := validateNamingPolicy(&Config{ImageNaming: &NamingPolicy{MaxLength: -1}}); err == nil {
+		t.Error("expected error for negative max_length")
+	}
+}
+
+func TestRequiredLabelsEnforcement(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"Dockerfile": "FROM alpine\nLABEL team=payments\n"})
+	chdir(t, dir)
+
+	config := map[string]any{
+		"image":           "myapp",
+		"labels":          map[string]any{"owner": "alice"},
+		"required_labels": []any{"owner", "team", "cost-center"},
+	}
+
+	p := &DockerPlugin{executor: &MockCommandExecutor{}}
+
+	vresp, err := p.Validate(context.Background(), config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if vresp.Valid {
+		t.Error("expected Validate to reject a missing required label")
+	}
+
+	mock := &MockCommandExecutor{}
+	p.executor = mock
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  config,
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success || !strings.Contains(resp.Error, "missing required labels cost-center") {
+		t.Errorf("expected only cost-center to be missing, got %+v", resp)
+	}
+	if len(mock.RunCalls) != 0 {
+		t.Errorf("expected no commands to run, got %d", len(mock.RunCalls))
+	}
+
+	config["labels"] = map[string]any{"owner": "alice", "cost-center": "4711"}
+	resp, err = p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  config,
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+	})
+	if err != nil || !resp.Success {
+		t.Errorf("expected success with every required label, got %v %+v", err, resp)
+	}
+}
+
+func TestRegistryPolicyImageHostWithoutPort(t *testing.T) {
+	t.Setenv(allowedRegistriesEnv, "")
+	config := map[string]any{
+		"image":              "attacker.example.com/app",
+		"allowed_registries": []any{"docker.io"},
+	}
+
+	p := &DockerPlugin{executor: &MockCommandExecutor{}}
+	vresp, err := p.Validate(context.Background(), config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if vresp.Valid {
+		t.Error("expected Validate to reject an image naming a registry outside the allowlist")
+	}
+
+	mock := &MockCommandExecutor{}
+	p.executor = mock
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  config,
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success || !strings.Contains(resp.Error, "registry attacker.example.com is not permitted") {
+		t.Errorf("expected policy violation, got %+v", resp)
+	}
+	if len(mock.RunCalls) != 0 {
+		t.Errorf("expected no commands to run, got %v", mock.RunCalls)
+	}
+}
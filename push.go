@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maxPushRetries caps push_retries so a broken registry cannot stall a release.
+const maxPushRetries = 10
+
+// validatePushRetries validates the push_retries setting.
+func validatePushRetries(retries int) error {
+	if retries < 0 || retries > maxPushRetries {
+		return fmt.Errorf("push_retries must be between 0 and %d", maxPushRetries)
+	}
+	return nil
+}
+
+// defaultPushRetryBackoff is the delay before the first retry of a push.
+const defaultPushRetryBackoff = "2s"
+
+// maxPushRetryDelay caps the delay between push attempts.
+const maxPushRetryDelay = 2 * time.Minute
+
+// validatePushRetryBackoff validates the push_retry_backoff setting.
+func validatePushRetryBackoff(backoff string) error {
+	if backoff == "" {
+		return nil
+	}
+	if d, err := time.ParseDuration(backoff); err != nil || d < 0 {
+		return fmt.Errorf("push_retry_backoff must be a non-negative duration such as 2s")
+	}
+	return nil
+}
+
+// pushRetryDelay returns the delay before the given retry, counting from 1:
+// push_retry_backoff, doubled for every further retry up to
+// maxPushRetryDelay.
+func pushRetryDelay(cfg *Config, retry int) time.Duration {
+	backoff, err := time.ParseDuration(cfg.PushRetryBackoff)
+	if err != nil || backoff <= 0 {
+		return 0
+	}
+	delay := backoff
+	for i := 1; i < retry && delay < maxPushRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxPushRetryDelay)
+}
+
+// transientPushErrors are fragments of push errors caused by the network or
+// an overloaded registry, which may succeed when retried.
+var transientPushErrors = []string{
+	"429", "toomanyrequests", "too many requests",
+	"500 internal server error", "502", "503", "504",
+	"bad gateway", "service unavailable", "gateway timeout",
+	"timeout", "timed out", "connection reset", "connection refused",
+	"broken pipe", "unexpected eof", "tls handshake",
+	"temporary failure in name resolution", "net/http: request canceled",
+}
+
+// transientPushError reports whether err was caused by the network or an
+// overloaded registry.
+func transientPushError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	if strings.HasSuffix(msg, ": eof") {
+		return true
+	}
+	for _, fragment := range transientPushErrors {
+		if strings.Contains(msg, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// authPushErrors are fragments of push errors caused by rejected
+// credentials.
+var authPushErrors = []string{"unauthorized", "authentication required", "token expired"}
+
+// retryablePush reports whether a failed push may succeed when retried.
+// Rejected credentials are retried only with password_command, whose
+// credentials are renewed before the retry; other failures, such as a
+// denied repository or a manifest the registry refuses, fail at once.
+func retryablePush(cfg *Config, err error) bool {
+	if transientPushError(err) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	if len(cfg.PasswordCommand) > 0 {
+		for _, fragment := range authPushErrors {
+			if strings.Contains(msg, fragment) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// pushAll pushes every reference in order. A push failing with a retryable
+// error is retried on its own up to cfg.PushRetries times, waiting
+// exponentially longer between attempts; references pushed before it are not
+// pushed again, and docker itself skips layers that already reached the
+// registry. Credentials from password_command are renewed when due before
+// every push and always before a retry, in case the failure was an expired
+// token. On failure the stats of the references pushed so far are returned
+// together with an error naming the failed reference and its attempts.
+func (p *DockerPlugin) pushAll(ctx context.Context, cfg *Config, refs []string) ([]*PushStats, error) {
+	pushed := make([]*PushStats, 0, len(refs))
+	for _, ref := range refs {
+		var stats *PushStats
+		var err error
+		attempts := 0
+		for attempt := 0; attempt <= cfg.PushRetries; attempt++ {
+			if attempt > 0 {
+				select {
+				case <-ctx.Done():
+					return pushed, fmt.Errorf("%s: %d attempts: %w", ref, attempts, err)
+				case <-time.After(pushRetryDelay(cfg, attempt)):
+				}
+			}
+			if refreshErr := p.refreshLogin(ctx, cfg, attempt > 0); refreshErr != nil {
+				return pushed, fmt.Errorf("%s: failed to refresh registry credentials: %w", ref, refreshErr)
+			}
+			attempts++
+			if stats, err = p.pushImage(ctx, cfg, ref); err == nil {
+				break
+			}
+			if ctx.Err() != nil || !retryablePush(cfg, err) {
+				break
+			}
+		}
+		if err != nil {
+			if attempts > 1 {
+				return pushed, fmt.Errorf("%s: %d attempts: %w", ref, attempts, err)
+			}
+			return pushed, fmt.Errorf("%s: %w", ref, err)
+		}
+		stats.Attempts = attempts
+		pushed = append(pushed, stats)
+	}
+	return pushed, nil
+}
+
+// retriedPushes describes the references that needed more than one push
+// attempt, e.g. "myapp:1.0.0 after 3 attempts".
+func retriedPushes(stats []*PushStats) []string {
+	var retried []string
+	for _, s := range stats {
+		if s.Attempts > 1 {
+			retried = append(retried, fmt.Sprintf("%s after %d attempts", s.Ref, s.Attempts))
+		}
+	}
+	return retried
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestPushAllRetriesOnlyFailedRef(t *testing.T) {
+	ctx := context.Background()
+	failures := 0
+	mock := &MockCommandExecutor{
+		RunFunc: func(_ context.Context, _ string, args []string, _ io.Reader) error {
+			if args[1] == "myapp:2" && failures < 2 {
+				failures++
+				return errors.New("connection reset")
+			}
+			return nil
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+
+	stats, err := p.pushAll(ctx, &Config{PushRetries: 2}, []string{"myapp:1", "myapp:2", "myapp:3"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(stats) != 3 {
+		t.Fatalf("expected 3 pushes, got %d", len(stats))
+	}
+
+	var refs []string
+	for _, call := range mock.RunCalls {
+		refs = append(refs, call.Args[1])
+	}
+	expected := "myapp:1,myapp:2,myapp:2,myapp:2,myapp:3"
+	if strings.Join(refs, ",") != expected {
+		t.Errorf("expected push sequence %s, got %s", expected, strings.Join(refs, ","))
+	}
+}
+
+func TestPushAllReportsProgressOnFailure(t *testing.T) {
+	ctx := context.Background()
+	mock := &MockCommandExecutor{
+		RunFunc: func(_ context.Context, _ string, args []string, _ io.Reader) error {
+			if args[1] == "myapp:2" {
+				return errors.New("broken pipe")
+			}
+			return nil
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+
+	stats, err := p.pushAll(ctx, &Config{PushRetries: 1}, []string{"myapp:1", "myapp:2", "myapp:3"})
+	if err == nil || !strings.Contains(err.Error(), "myapp:2") {
+		t.Fatalf("expected error naming failed ref, got %v", err)
+	}
+	if len(stats) != 1 || stats[0].Ref != "myapp:1" {
+		t.Errorf("expected only myapp:1 reported as pushed, got %v", stats)
+	}
+	if len(mock.RunCalls) != 3 {
+		t.Errorf("expected 3 push attempts, got %d", len(mock.RunCalls))
+	}
+}
+
+func TestPushAllDoesNotRetryPermanentErrors(t *testing.T) {
+	mock := &MockCommandExecutor{
+		RunFunc: func(context.Context, string, []string, io.Reader) error {
+			return errors.New("exit status 1: denied: requested access to the resource is denied")
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+
+	_, err := p.pushAll(context.Background(), &Config{PushRetries: 3}, []string{"myapp:1"})
+	if err == nil || strings.Contains(err.Error(), "attempts") {
+		t.Fatalf("expected a single failed attempt, got %v", err)
+	}
+	if len(mock.RunCalls) != 1 {
+		t.Errorf("expected a denied push not to be retried, got %d attempts", len(mock.RunCalls))
+	}
+}
+
+func TestRetryablePush(t *testing.T) {
+	tests := []struct {
+		err      string
+		cfg      *Config
+		expected bool
+	}{
+		{"exit status 1: toomanyrequests: retry-after: 10s", &Config{}, true},
+		{"exit status 1: received unexpected HTTP status: 503 Service Unavailable", &Config{}, true},
+		{"exit status 1: net/http: TLS handshake timeout", &Config{}, true},
+		{"exit status 1: Patch \"https://ghcr.io/v2/org/app/blobs/uploads/x\": EOF", &Config{}, true},
+		{"exit status 1: denied: permission_denied: write_package", &Config{}, false},
+		{"exit status 1: unauthorized: authentication required", &Config{}, false},
+		{"exit status 1: unauthorized: authentication required", &Config{PasswordCommand: []string{"get-token"}}, true},
+	}
+	for _, tt := range tests {
+		if got := retryablePush(tt.cfg, errors.New(tt.err)); got != tt.expected {
+			t.Errorf("retryablePush(%q) = %v, expected %v", tt.err, got, tt.expected)
+		}
+	}
+}
+
+func TestPushRetryDelay(t *testing.T) {
+	cfg := &Config{PushRetryBackoff: "2s"}
+	for retry, expected := range map[int]time.Duration{1: 2 * time.Second, 2: 4 * time.Second, 4: 16 * time.Second, 10: maxPushRetryDelay} {
+		if got := pushRetryDelay(cfg, retry); got != expected {
+			t.Errorf("retry %d: expected %v, got %v", retry, expected, got)
+		}
+	}
+	if got := pushRetryDelay(&Config{}, 3); got != 0 {
+		t.Errorf("expected no delay without a backoff, got %v", got)
+	}
+}
+
+func TestExecuteReportsPushAttempts(t *testing.T) {
+	failures := 0
+	mock := &MockCommandExecutor{
+		RunFunc: func(_ context.Context, _ string, args []string, _ io.Reader) error {
+			if args[0] == "push" && failures < 2 {
+				failures++
+				return errors.New("exit status 1: toomanyrequests: too many requests")
+			}
+			return nil
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"image": "myapp", "tags": []any{"1.0.0"}, "push_retries": 3, "push_retry_backoff": "1ms"},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+	if !strings.Contains(resp.Message, "pushed myapp:1.0.0 after 3 attempts") {
+		t.Errorf("expected the attempts in the message, got %q", resp.Message)
+	}
+}
+
+func TestPushFailureOutputs(t *testing.T) {
+	ctx := context.Background()
+	mock := &MockCommandExecutor{FailOnCall: 3}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(ctx, plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image": "myorg/myapp",
+			"tags":  []any{"1.0.0", "latest"},
+		},
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success {
+		t.Fatal("expected failure")
+	}
+	if !strings.Contains(resp.Error, "myorg/myapp:latest") || !strings.Contains(resp.Error, "1 of 2") {
+		t.Errorf("unexpected error: %s", resp.Error)
+	}
+	pushed, _ := resp.Outputs["pushed_refs"].([]string)
+	if len(pushed) != 1 || pushed[0] != "myorg/myapp:1.0.0" {
+		t.Errorf("expected pushed_refs [myorg/myapp:1.0.0], got %v", resp.Outputs["pushed_refs"])
+	}
+}
+
+func TestValidatePushRetryBackoff(t *testing.T) {
+	p := &DockerPlugin{}
+	for _, backoff := range []string{"-1s", "soon"} {
+		resp, err := p.Validate(context.Background(), map[string]any{"image": "myapp", "push_retry_backoff": backoff})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.Valid {
+			t.Errorf("expected push_retry_backoff=%s to be invalid", backoff)
+		}
+	}
+}
+
+func TestValidatePushRetries(t *testing.T) {
+	p := &DockerPlugin{}
+	for _, retries := range []any{-1, 11} {
+		resp, err := p.Validate(context.Background(), map[string]any{"image": "myapp", "push_retries": retries})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.Valid {
+			t.Errorf("expected push_retries=%v to be invalid", retries)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// reservePush takes the release lock of the refs of outputs and waits for
+// the registry to answer. It runs once per release; releaseLock releases
+// the lock. Releases that read the registry to decide what to push, such as
+// the rolling tag check, call it before those reads so that no concurrent
+// release of the same tags pushes in between; gatePush calls it for the
+// others.
+func (p *DockerPlugin) reservePush(ctx context.Context, cfg *Config, version string, outputs *Outputs) *plugin.ExecuteResponse {
+	if cfg.pushReserved {
+		return nil
+	}
+	cfg.pushReserved = true
+	if cfg.ReleaseLock != nil {
+		started := time.Now()
+		unlock, warning, err := acquireReleaseLock(ctx, cfg, outputs.Refs, version)
+		outputs.stage("lock", started, err)
+		if err != nil {
+			return outputs.response(false, "", fmt.Sprintf("failed to acquire release lock: %v", err))
+		}
+		cfg.unlockRelease = unlock
+		if warning != "" {
+			outputs.Warnings = append(outputs.Warnings, warning)
+		}
+	}
+	if err := p.awaitRegistry(ctx, cfg, outputs); err != nil {
+		return outputs.response(false, "", err.Error())
+	}
+	return nil
+}
+
+// releaseLock releases the release lock reservePush took, if any.
+func (cfg *Config) releaseLock() {
+	if cfg.unlockRelease != nil {
+		cfg.unlockRelease()
+		cfg.unlockRelease = nil
+	}
+}
+
+// gatePush runs the gates every release waits for before it first writes to
+// the registry: the release lock and registry readiness of reservePush, the
+// approval of the push, the push window and the creation of the ECR
+// repository. It also sets up the throttle of push_rate_limit for the
+// uploads of the plugin. Every push path calls it right before its first
+// registry write, and the settings of its gates are checked by
+// pushGateValidators; it runs once per release. It returns the response
+// ending the execution when a gate stops the push, and nil when the push may
+// proceed.
+func (p *DockerPlugin) gatePush(ctx context.Context, cfg *Config, version string, outputs *Outputs) *plugin.ExecuteResponse {
+	if cfg.pushGated {
+		return nil
+	}
+	cfg.pushGated = true
+	if resp := p.reservePush(ctx, cfg, version, outputs); resp != nil {
+		return resp
+	}
+	if err := p.approvePush(ctx, cfg, version, outputs); err != nil {
+		return outputs.response(false, "", fmt.Sprintf("push not approved: %v", err))
+	}
+	if resp := p.gatePushWindow(ctx, cfg, outputs); resp != nil {
+		return resp
+	}
+	if err := p.ensureECRRepository(ctx, cfg, outputs); err != nil {
+		return outputs.response(false, "", err.Error())
+	}
+	cfg.pushThrottle = newThrottle(cfg)
+	return nil
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// rejectingApproval serves an approval URL that rejects every push.
+func rejectingApproval(t *testing.T) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+// registryWrites returns the commands of calls that write to a registry.
+func registryWrites(calls []MockRunCall) []string {
+	var writes []string
+	for _, call := range calls {
+		args := strings.Join(call.Args, " ")
+		if strings.HasPrefix(args, "push ") || strings.Contains(args, "imagetools create") || strings.Contains(args, "--push") {
+			writes = append(writes, args)
+		}
+	}
+	return writes
+}
+
+func TestGatePushEveryPath(t *testing.T) {
+	approval := map[string]any{"url": rejectingApproval(t)}
+	tests := []struct {
+		name   string
+		config map[string]any
+		mock   *MockCommandExecutor
+	}{
+		{"index_sources", map[string]any{"index_sources": []any{"{{version}}-amd64", "{{version}}-arm64"}}, &MockCommandExecutor{}},
+		{"append_platform", map[string]any{"append_platform": []any{"linux/arm64"}}, &MockCommandExecutor{
+			OutputFunc: func(_ context.Context, _ string, args []string) ([]byte, error) {
+				if slices.Contains(args, "{{json .Image}}") {
+					return []byte(`{"architecture": "amd64", "os": "linux"}`), nil
+				}
+				return []byte("sha256:release\n"), nil
+			},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config["image"] = "myorg/myapp"
+			tt.config["approval"] = approval
+			p := &DockerPlugin{executor: tt.mock}
+			resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+				Hook:    plugin.HookPostPublish,
+				Config:  tt.config,
+				Context: plugin.ReleaseContext{Version: "1.0.0"},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Success || !strings.Contains(resp.Error, "push not approved") {
+				t.Fatalf("expected the push to be rejected, got %+v", resp)
+			}
+			if writes := registryWrites(tt.mock.RunCalls); len(writes) > 0 {
+				t.Errorf("expected no registry writes, got %v", writes)
+			}
+		})
+	}
+}
+
+func TestGatePushReuseIdentical(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"Dockerfile": "FROM scratch\n"})
+	chdir(t, dir)
+
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock}
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":           "myorg/myapp",
+			"reuse_identical": true,
+			"approval":        map[string]any{"url": rejectingApproval(t)},
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success || !strings.Contains(resp.Error, "push not approved") {
+		t.Fatalf("expected the retag to be rejected, got %+v", resp)
+	}
+	if writes := registryWrites(mock.RunCalls); len(writes) > 0 {
+		t.Errorf("expected no retag, got %v", writes)
+	}
+}
+
+func TestGatePushDaemonless(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+	writeOCITarball(t, dir)
+	reg, server := newFakeRegistry(t)
+
+	p := &DockerPlugin{executor: &MockCommandExecutor{}, httpClient: server.Client()}
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":       "myapp",
+			"registry":    strings.TrimPrefix(server.URL, "https://"),
+			"daemonless":  true,
+			"oci_tarball": "image.tar",
+			"approval":    map[string]any{"url": rejectingApproval(t)},
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success || !strings.Contains(resp.Error, "push not approved") {
+		t.Fatalf("expected the push to be rejected, got %+v", resp)
+	}
+	if len(reg.manifests) > 0 || len(reg.blobs) > 0 {
+		t.Errorf("expected nothing uploaded, got %d manifests and %d blobs", len(reg.manifests), len(reg.blobs))
+	}
+}
+
+func TestGatePushBundleImport(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+	t.Setenv("TMPDIR", t.TempDir())
+	writeOCITarball(t, dir)
+	_, sourceServer := newFakeRegistry(t)
+
+	p := &DockerPlugin{executor: &MockCommandExecutor{}, httpClient: sourceServer.Client()}
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":       "myapp",
+			"registry":    strings.TrimPrefix(sourceServer.URL, "https://"),
+			"tags":        []any{"1.0.0", "latest"},
+			"daemonless":  true,
+			"oci_tarball": "image.tar",
+			"bundle":      "export",
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil || !resp.Success {
+		t.Fatalf("expected the bundle to be exported, got %+v, %v", resp, err)
+	}
+
+	target, targetServer := newFakeRegistry(t)
+	host := strings.TrimPrefix(targetServer.URL, "https://")
+	lockDir := t.TempDir()
+	cfg := &Config{ReleaseLock: &ReleaseLock{Dir: lockDir}}
+	unlock, _, err := acquireReleaseLock(context.Background(), cfg, []string{host + "/myapp:1.0.0", host + "/myapp:latest"}, "1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+
+	p = &DockerPlugin{executor: &MockCommandExecutor{}, httpClient: targetServer.Client()}
+	for _, tt := range []struct {
+		name   string
+		config map[string]any
+		want   string
+	}{
+		{"release lock", map[string]any{"release_lock": map[string]any{"dir": lockDir}}, "failed to acquire release lock"},
+		{"approval", map[string]any{"approval": map[string]any{"url": rejectingApproval(t)}}, "push not approved"},
+		{"invalid rate limit", map[string]any{"push_rate_limit": "fast"}, "invalid push_rate_limit"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config["image"] = "myapp"
+			tt.config["registry"] = host
+			tt.config["bundle"] = "import"
+			resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+				Hook:    plugin.HookPostPublish,
+				Config:  tt.config,
+				Context: plugin.ReleaseContext{Version: "1.0.0"},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Success || !strings.Contains(resp.Error, tt.want) {
+				t.Fatalf("expected %q, got %+v", tt.want, resp)
+			}
+			if len(target.manifests) > 0 || len(target.blobs) > 0 {
+				t.Errorf("expected nothing imported, got %d manifests and %d blobs", len(target.manifests), len(target.blobs))
+			}
+		})
+	}
+}
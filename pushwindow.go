@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// Behaviours of push_window outside the window.
+const (
+	pushWindowWait  = "wait"
+	pushWindowDefer = "defer"
+)
+
+// defaultPushWindowMaxWait bounds how long a push waits for its window.
+const defaultPushWindowMaxWait = time.Hour
+
+// maxPushWindowSearch bounds the search for the next window opening.
+const maxPushWindowSearch = 8 * 24 * time.Hour
+
+// timeNow returns the current time; replaced in tests.
+var timeNow = time.Now
+
+// PushWindow restricts pushes to the minutes matched by a cron expression,
+// for registries with change freezes.
+type PushWindow struct {
+	// Schedule is a five-field cron expression (minute, hour, day of month,
+	// month, day of week) matching the minutes pushes are allowed in, e.g.
+	// "* 9-16 * * 1-5" for weekday office hours.
+	Schedule string
+	Timezone string
+	// OnClosed is wait or defer.
+	OnClosed string
+	MaxWait  string
+}
+
+// cronSchedule is a parsed cron expression; each field holds the matching
+// values.
+type cronSchedule struct {
+	minute, hour, dom, month, dow map[int]bool
+	// domAny and dowAny record unrestricted day fields: when both day
+	// fields are restricted, a day matching either one matches.
+	domAny, dowAny bool
+}
+
+// parsePushWindow reads the push_window option, returning nil when it is not
+// set.
+func parsePushWindow(raw map[string]any) *PushWindow {
+	m, ok := raw["push_window"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	window := &PushWindow{}
+	window.Schedule, _ = m["schedule"].(string)
+	window.Timezone, _ = m["timezone"].(string)
+	window.OnClosed, _ = m["on_closed"].(string)
+	window.MaxWait, _ = m["max_wait"].(string)
+	if window.OnClosed == "" {
+		window.OnClosed = pushWindowWait
+	}
+	return window
+}
+
+// parseCron parses a five-field cron expression. Fields accept *, values,
+// ranges, lists and steps; day of week 0 and 7 are Sunday.
+func parseCron(expr string) (*cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields, got %d", len(fields))
+	}
+	bounds := []struct {
+		name     string
+		min, max int
+	}{
+		{"minute", 0, 59},
+		{"hour", 0, 23},
+		{"day of month", 1, 31},
+		{"month", 1, 12},
+		{"day of week", 0, 7},
+	}
+	sets := make([]map[int]bool, len(fields))
+	for i, field := range fields {
+		set, err := parseCronField(field, bounds[i].min, bounds[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %v", bounds[i].name, field, err)
+		}
+		sets[i] = set
+	}
+	if sets[4][7] {
+		sets[4][0] = true
+	}
+	return &cronSchedule{
+		minute: sets[0], hour: sets[1], dom: sets[2], month: sets[3], dow: sets[4],
+		domAny: fields[2] == "*", dowAny: fields[4] == "*",
+	}, nil
+}
+
+// parseCronField returns the values matched by one cron field.
+func parseCronField(field string, min, max int) (map[int]bool, error) {
+	set := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			s, err := strconv.Atoi(stepPart)
+			if err != nil || s <= 0 {
+				return nil, fmt.Errorf("invalid step %q", stepPart)
+			}
+			step = s
+		}
+
+		lo, hi := min, max
+		if rangePart != "*" {
+			from, to, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if lo, err = strconv.Atoi(from); err != nil {
+				return nil, fmt.Errorf("invalid value %q", from)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(to); err != nil {
+					return nil, fmt.Errorf("invalid value %q", to)
+				}
+			} else if hasStep {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return nil, fmt.Errorf("%s out of range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
+
+// matches reports whether the minute of t is in the schedule.
+func (c *cronSchedule) matches(t time.Time) bool {
+	if !c.minute[t.Minute()] || !c.hour[t.Hour()] || !c.month[int(t.Month())] {
+		return false
+	}
+	dom, dow := c.dom[t.Day()], c.dow[int(t.Weekday())]
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dow
+	case c.dowAny:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+// nextOpening returns the start of the first minute at or after t that is in
+// the schedule, or false when none is found within maxPushWindowSearch.
+func (c *cronSchedule) nextOpening(t time.Time) (time.Time, bool) {
+	if c.matches(t) {
+		return t, true
+	}
+	next := t.Truncate(time.Minute).Add(time.Minute)
+	for end := t.Add(maxPushWindowSearch); next.Before(end); next = next.Add(time.Minute) {
+		if c.matches(next) {
+			return next, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// validatePushWindow checks the push_window settings.
+func validatePushWindow(cfg *Config) error {
+	window := cfg.PushWindow
+	if window == nil {
+		return nil
+	}
+	if window.Schedule == "" {
+		return fmt.Errorf("schedule is required")
+	}
+	if _, err := parseCron(window.Schedule); err != nil {
+		return fmt.Errorf("invalid schedule: %v", err)
+	}
+	if _, err := time.LoadLocation(window.Timezone); err != nil {
+		return fmt.Errorf("unknown timezone %q", window.Timezone)
+	}
+	if window.OnClosed != pushWindowWait && window.OnClosed != pushWindowDefer {
+		return fmt.Errorf("invalid on_closed %q: expected wait or defer", window.OnClosed)
+	}
+	if window.MaxWait != "" {
+		if window.OnClosed != pushWindowWait {
+			return fmt.Errorf("max_wait requires on_closed: wait")
+		}
+		if d, err := time.ParseDuration(window.MaxWait); err != nil || d < 0 {
+			return fmt.Errorf("max_wait must be a duration such as 2h")
+		}
+	}
+	return nil
+}
+
+// awaitPushWindow returns once the push window is open. Outside the window
+// it waits up to max_wait for the window to open, or, with on_closed: defer,
+// returns the opening time the push is deferred to. Waiting too long or a
+// window that never opens is an error.
+func (p *DockerPlugin) awaitPushWindow(ctx context.Context, cfg *Config) (time.Time, error) {
+	window := cfg.PushWindow
+	schedule, err := parseCron(window.Schedule)
+	if err != nil {
+		return time.Time{}, err
+	}
+	location, err := time.LoadLocation(window.Timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	now := timeNow().In(location)
+	opening, ok := schedule.nextOpening(now)
+	if !ok {
+		return time.Time{}, fmt.Errorf("push window %q does not open within %s", window.Schedule, maxPushWindowSearch)
+	}
+	if !opening.After(now) {
+		return time.Time{}, nil
+	}
+	if window.OnClosed == pushWindowDefer {
+		return opening, nil
+	}
+
+	maxWait := defaultPushWindowMaxWait
+	if d, err := time.ParseDuration(window.MaxWait); err == nil {
+		maxWait = d
+	}
+	wait := opening.Sub(now)
+	if wait > maxWait {
+		return time.Time{}, fmt.Errorf("outside push window %q; it opens at %s, after max_wait %s", window.Schedule, opening.Format(time.RFC3339), maxWait)
+	}
+	select {
+	case <-ctx.Done():
+		return time.Time{}, ctx.Err()
+	case <-time.After(wait):
+	}
+	return time.Time{}, nil
+}
+
+// gatePushWindow runs the push window stage before anything is pushed. It
+// returns the response ending the execution when the push fails or is
+// deferred, and nil when the push may proceed. A deferred push succeeds with
+// pushed: false and push_deferred_until set.
+func (p *DockerPlugin) gatePushWindow(ctx context.Context, cfg *Config, outputs *Outputs) *plugin.ExecuteResponse {
+	if cfg.PushWindow == nil {
+		return nil
+	}
+	started := time.Now()
+	deferred, err := p.awaitPushWindow(ctx, cfg)
+	outputs.stage("push_window", started, err)
+	if err != nil {
+		return outputs.response(false, "", fmt.Sprintf("push window: %v", err))
+	}
+	if deferred.IsZero() {
+		return nil
+	}
+	outputs.Pushed = false
+	outputs.PushDeferredUntil = deferred.Format(time.RFC3339)
+	return outputs.response(true, fmt.Sprintf("Push deferred until %s: outside push window %q", outputs.PushDeferredUntil, cfg.PushWindow.Schedule), "")
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func stubTimeNow(t *testing.T, now time.Time) {
+	t.Helper()
+	orig := timeNow
+	timeNow = func() time.Time { return now }
+	t.Cleanup(func() { timeNow = orig })
+}
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr bool
+	}{
+		{"* * * * *", false},
+		{"*/15 9-16 * * 1-5", false},
+		{"0,30 8 1,15 1-12/2 7", false},
+		{"5/10 * * * *", false},
+		{"* * * *", true},
+		{"60 * * * *", true},
+		{"* 17-9 * * *", true},
+		{"*/0 * * * *", true},
+		{"* * * * mon", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			if _, err := parseCron(tt.expr); (err != nil) != tt.wantErr {
+				t.Errorf("parseCron(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCronMatches(t *testing.T) {
+	// 2024-06-03 is a Monday.
+	monday := time.Date(2024, 6, 3, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		expr     string
+		at       time.Time
+		expected bool
+	}{
+		{"office hours", "* 9-16 * * 1-5", monday, true},
+		{"after hours", "* 9-16 * * 1-5", monday.Add(7 * time.Hour), false},
+		{"weekend", "* 9-16 * * 1-5", monday.AddDate(0, 0, 5), false},
+		{"sunday as 7", "* * * * 7", monday.AddDate(0, 0, 6), true},
+		{"step", "*/15 * * * *", monday, true},
+		{"step miss", "*/20 * * * *", monday, false},
+		{"day of month or week", "* * 1 * 1", monday, true},
+		{"day of month only", "* * 1 * *", monday, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := parseCron(tt.expr)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := schedule.matches(tt.at); got != tt.expected {
+				t.Errorf("matches(%s) = %v, want %v", tt.at, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCronNextOpening(t *testing.T) {
+	schedule, _ := parseCron("0 9 * * 1-5")
+	friday := time.Date(2024, 6, 7, 17, 12, 30, 0, time.UTC)
+
+	next, ok := schedule.nextOpening(friday)
+	if !ok || !next.Equal(time.Date(2024, 6, 10, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("nextOpening() = %s, %v; want Monday 09:00", next, ok)
+	}
+
+	never, _ := parseCron("0 0 30 2 *")
+	if _, ok := never.nextOpening(friday); ok {
+		t.Error("expected no opening for February 30")
+	}
+}
+
+func TestValidatePushWindow(t *testing.T) {
+	tests := []struct {
+		name    string
+		window  *PushWindow
+		wantErr bool
+	}{
+		{"unset", nil, false},
+		{"wait", &PushWindow{Schedule: "* 9-16 * * 1-5", Timezone: "UTC", OnClosed: "wait", MaxWait: "2h"}, false},
+		{"defer", &PushWindow{Schedule: "* 9-16 * * 1-5", OnClosed: "defer"}, false},
+		{"missing schedule", &PushWindow{OnClosed: "wait"}, true},
+		{"invalid schedule", &PushWindow{Schedule: "office hours", OnClosed: "wait"}, true},
+		{"unknown timezone", &PushWindow{Schedule: "* * * * *", Timezone: "Mars/Olympus", OnClosed: "wait"}, true},
+		{"invalid on_closed", &PushWindow{Schedule: "* * * * *", OnClosed: "skip"}, true},
+		{"max_wait with defer", &PushWindow{Schedule: "* * * * *", OnClosed: "defer", MaxWait: "1h"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validatePushWindow(&Config{PushWindow: tt.window}); (err != nil) != tt.wantErr {
+				t.Errorf("validatePushWindow() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExecutePushWindowDefers(t *testing.T) {
+	// Saturday 2024-06-08, outside weekday hours.
+	stubTimeNow(t, time.Date(2024, 6, 8, 12, 0, 0, 0, time.UTC))
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":       "myapp",
+			"push_window": map[string]any{"schedule": "* 9-16 * * 1-5", "on_closed": "defer"},
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success || !strings.Contains(resp.Message, "Push deferred") {
+		t.Fatalf("expected deferred push, got %+v", resp)
+	}
+	if resp.Outputs["push_deferred_until"] != "2024-06-10T09:00:00Z" || resp.Outputs["pushed"] != false {
+		t.Errorf("unexpected outputs: %v", resp.Outputs)
+	}
+	for _, call := range mock.RunCalls {
+		if call.Args[0] == "push" {
+			t.Error("expected no push outside the window")
+		}
+	}
+}
+
+func TestExecutePushWindowWaits(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("timezone data not available")
+	}
+	stubTimeNow(t, time.Date(2024, 6, 3, 8, 59, 59, int(990*time.Millisecond), berlin))
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":       "myapp",
+			"push_window": map[string]any{"schedule": "* 9-16 * * 1-5", "timezone": "Europe/Berlin"},
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success || resp.Outputs["pushed"] != true {
+		t.Fatalf("expected push after waiting for the window, got %+v", resp)
+	}
+}
+
+func TestExecutePushWindowExceedsMaxWait(t *testing.T) {
+	stubTimeNow(t, time.Date(2024, 6, 3, 18, 0, 0, 0, time.UTC))
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":       "myapp",
+			"builder":     "release",
+			"push_window": map[string]any{"schedule": "* 9-16 * * 1-5", "max_wait": "30m"},
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success || !strings.Contains(resp.Error, "opens at 2024-06-04T09:00:00Z") {
+		t.Fatalf("expected push window failure, got %+v", resp)
+	}
+	for _, call := range mock.RunCalls {
+		if containsFlag(call.Args, "--push") {
+			t.Errorf("expected no pushing build, got %v", call.Args)
+		}
+	}
+}
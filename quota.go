@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// harborProjectSummary is the subset of Harbor's project summary API used for quota checks.
+type harborProjectSummary struct {
+	Quota *struct {
+		Hard struct {
+			Storage int64 `json:"storage"`
+		} `json:"hard"`
+		Used struct {
+			Storage int64 `json:"storage"`
+		} `json:"used"`
+	} `json:"quota"`
+}
+
+// estimatePushSize returns the local size of the built image. The daemon
+// reports the uncompressed size of every layer, including those the
+// registry already has, so this is an upper bound for what the push adds
+// to the registry. Zero means unknown.
+func (p *DockerPlugin) estimatePushSize(ctx context.Context, ref string) int64 {
+	out, err := p.getExecutor().Output(ctx, "docker", []string{"image", "inspect", "--format", "{{.Size}}", ref})
+	if err != nil {
+		return 0
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return size
+}
+
+// checkQuota checks the registry's storage quota before pushing up to
+// estimate more bytes. An exhausted quota, or a push the estimate says
+// would exceed it, fails the check. The estimate is an upper bound, so
+// quota_allow_overrun turns an overrun into a warning for images whose
+// estimate is known to overcount. A quota API that cannot be queried only
+// yields a warning unless quota_strict is set, which also overrides
+// quota_allow_overrun. Registries without a quota API, including Docker
+// Hub, whose plan limits have no API, yield a warning.
+func (p *DockerPlugin) checkQuota(ctx context.Context, cfg *Config, estimate int64) (string, error) {
+	registryType := p.detectRegistryType(ctx, cfg, true)
+	if registryType != registryTypeHarbor {
+		return fmt.Sprintf("quota_check skipped: registry type %s has no quota API", registryType), nil
+	}
+	advisory := func(err error) (string, error) {
+		if cfg.QuotaStrict {
+			return "", err
+		}
+		return err.Error(), nil
+	}
+
+	project, _, _ := strings.Cut(cfg.Image, "/")
+
+	var summary harborProjectSummary
+	path := "/api/v2.0/projects/" + url.PathEscape(project) + "/summary"
+	if _, err := p.newRegistryClient(cfg).getJSON(ctx, path, &summary); err != nil {
+		return advisory(fmt.Errorf("failed to query quota for project %s: %w", project, err))
+	}
+
+	if summary.Quota == nil || summary.Quota.Hard.Storage < 0 {
+		return "", nil
+	}
+
+	hard, used := summary.Quota.Hard.Storage, summary.Quota.Used.Storage
+	if used >= hard {
+		return "", fmt.Errorf("project %s has exhausted its %s storage quota", project, formatBytes(hard))
+	}
+	if used+estimate > hard {
+		err := fmt.Errorf("project %s uses %s of its %s storage quota; pushing up to %s more would exceed it",
+			project, formatBytes(used), formatBytes(hard), formatBytes(estimate))
+		if cfg.QuotaAllowOverrun && !cfg.QuotaStrict {
+			return err.Error(), nil
+		}
+		return "", err
+	}
+	return "", nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// harborQuotaHandler serves a Harbor project summary with the given quota.
+func harborQuotaHandler(t *testing.T, used, hard string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2.0/systeminfo":
+			_, _ = w.Write([]byte(`{"harbor_version":"v2.11.0"}`))
+		case "/api/v2.0/projects/team/summary":
+			if user, _, ok := r.BasicAuth(); !ok || user != "robot" {
+				t.Errorf("expected basic auth for robot, got %q", user)
+			}
+			_, _ = w.Write([]byte(`{"quota":{"hard":{"storage":` + hard + `},"used":{"storage":` + used + `}}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func TestCheckQuota(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		used     string
+		hard     string
+		estimate int64
+		strict   bool
+		overrun  bool
+		wantWarn string
+		wantErr  string
+	}{
+		{name: "within quota", used: "1000", hard: "10000", estimate: 500},
+		{name: "unlimited", used: "1000", hard: "-1", estimate: 1 << 40},
+		{name: "would exceed", used: "9000", hard: "10000", estimate: 2000, wantErr: "would exceed"},
+		{name: "overrun allowed", used: "9000", hard: "10000", estimate: 2000, overrun: true, wantWarn: "would exceed"},
+		{name: "strict overrides allowed overrun", used: "9000", hard: "10000", estimate: 2000, overrun: true, strict: true, wantErr: "would exceed"},
+		{name: "exhausted", used: "10000", hard: "10000", estimate: 0, wantErr: "exhausted"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, host := newTestRegistry(t, harborQuotaHandler(t, tt.used, tt.hard))
+			cfg := &Config{Registry: host, Image: "team/service", Username: "robot", Password: "secret", QuotaStrict: tt.strict, QuotaAllowOverrun: tt.overrun}
+
+			warning, err := p.checkQuota(ctx, cfg, tt.estimate)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				if tt.wantWarn == "" && warning != "" || !strings.Contains(warning, tt.wantWarn) {
+					t.Errorf("expected warning containing %q, got %q", tt.wantWarn, warning)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCheckQuotaUnsupportedRegistry(t *testing.T) {
+	p := &DockerPlugin{}
+	warning, err := p.checkQuota(context.Background(), &Config{Registry: "ghcr.io", Image: "org/app"}, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(warning, "ghcr") {
+		t.Errorf("expected skip warning, got %q", warning)
+	}
+}
+
+func TestQuotaCheckBlocksPush(t *testing.T) {
+	for _, allowOverrun := range []bool{false, true} {
+		p, host := newTestRegistry(t, harborQuotaHandler(t, "9000", "10000"))
+		mock := &MockCommandExecutor{
+			OutputFunc: func(_ context.Context, _ string, args []string) ([]byte, error) {
+				if args[0] == "image" {
+					return []byte("5000\n"), nil
+				}
+				return nil, nil
+			},
+		}
+		p.executor = mock
+
+		resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+			Hook: plugin.HookPostPublish,
+			Config: map[string]any{
+				"image":               "team/service",
+				"registry":            host,
+				"username":            "robot",
+				"password":            "secret",
+				"quota_check":         true,
+				"quota_allow_overrun": allowOverrun,
+			},
+			Context: plugin.ReleaseContext{Version: "v1.0.0"},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		pushed := false
+		for _, call := range mock.RunCalls {
+			pushed = pushed || call.Args[0] == "push"
+		}
+		if !allowOverrun {
+			if resp.Success || !strings.Contains(resp.Error, "quota") {
+				t.Fatalf("expected quota failure, got %+v", resp)
+			}
+			if pushed {
+				t.Error("expected no push after quota failure")
+			}
+			continue
+		}
+		if !resp.Success || !pushed {
+			t.Fatalf("expected the estimate to only warn, got %+v", resp)
+		}
+		if warnings, _ := resp.Outputs["warnings"].([]string); !slices.ContainsFunc(warnings, func(w string) bool { return strings.Contains(w, "would exceed") }) {
+			t.Errorf("expected a quota warning, got %v", warnings)
+		}
+	}
+}
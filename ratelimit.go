@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rate pattern: number, optional unit, optional "/s" (e.g. 20MB/s, 512KiB/s, 1.5G).
+var rateLimitPattern = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)\s*([kKmMgG]i?)?[bB]?(?:/s)?$`)
+
+// rateUnits maps unit prefixes to byte multipliers. Plain prefixes are
+// decimal, the "i" forms binary.
+var rateUnits = map[string]float64{
+	"":   1,
+	"k":  1e3,
+	"m":  1e6,
+	"g":  1e9,
+	"ki": 1 << 10,
+	"mi": 1 << 20,
+	"gi": 1 << 30,
+}
+
+// parseRateLimit converts a rate such as "20MB/s" into bytes per second.
+// An empty string means unlimited and yields zero.
+func parseRateLimit(rate string) (int64, error) {
+	rate = strings.TrimSpace(rate)
+	if rate == "" {
+		return 0, nil
+	}
+
+	m := rateLimitPattern.FindStringSubmatch(rate)
+	if m == nil {
+		return 0, fmt.Errorf("invalid rate %q: expected a value like 20MB/s", rate)
+	}
+
+	value, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate %q: %v", rate, err)
+	}
+
+	bytesPerSecond := int64(value * rateUnits[strings.ToLower(m[2])])
+	if bytesPerSecond < 1024 {
+		return 0, fmt.Errorf("invalid rate %q: must be at least 1KiB/s", rate)
+	}
+	return bytesPerSecond, nil
+}
+
+// validateRateLimit checks push_rate_limit. The limit is applied by the
+// plugin's own registry uploads, daemonless pushes and bundle imports;
+// docker and buildx pushes are uploaded by the docker daemon or BuildKit,
+// which the plugin cannot throttle.
+func validateRateLimit(cfg *Config) error {
+	if _, err := parseRateLimit(cfg.PushRateLimit); err != nil {
+		return err
+	}
+	if cfg.PushRateLimit != "" && !cfg.Daemonless && cfg.Bundle != bundleImport {
+		return fmt.Errorf("requires daemonless or bundle: import: docker and buildx pushes are uploaded by the docker daemon or BuildKit, which cannot be throttled")
+	}
+	return nil
+}
+
+// throttle limits the combined rate of the uploads it wraps.
+type throttle struct {
+	limit   int64
+	started time.Time
+	sent    int64
+}
+
+// newThrottle returns the throttle of push_rate_limit, or nil when no limit
+// is set.
+func newThrottle(cfg *Config) *throttle {
+	limit, err := parseRateLimit(cfg.PushRateLimit)
+	if err != nil || limit == 0 {
+		return nil
+	}
+	return &throttle{limit: limit}
+}
+
+// reader returns r read no faster than the limit. A nil throttle returns r.
+func (t *throttle) reader(ctx context.Context, r io.Reader) io.Reader {
+	if t == nil {
+		return r
+	}
+	if t.started.IsZero() {
+		t.started = time.Now()
+	}
+	return &throttledReader{ctx: ctx, t: t, r: r}
+}
+
+type throttledReader struct {
+	ctx context.Context
+	t   *throttle
+	r   io.Reader
+}
+
+// Read reads at most a tenth of a second of the limit at once and then
+// waits until the bytes sent so far are within the limit.
+func (tr *throttledReader) Read(b []byte) (int, error) {
+	if chunk := max(tr.t.limit/10, 1024); int64(len(b)) > chunk {
+		b = b[:chunk]
+	}
+	n, err := tr.r.Read(b)
+	tr.t.sent += int64(n)
+	due := time.Duration(float64(tr.t.sent) / float64(tr.t.limit) * float64(time.Second))
+	if wait := due - time.Since(tr.t.started); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-tr.ctx.Done():
+			return n, tr.ctx.Err()
+		}
+	}
+	return n, err
+}
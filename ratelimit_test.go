@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// stubLookPath makes lookPath find only the given executables.
+func stubLookPath(t *testing.T, found ...string) {
+	t.Helper()
+	orig := lookPath
+	lookPath = func(file string) (string, error) {
+		for _, f := range found {
+			if f == file {
+				return "/usr/bin/" + file, nil
+			}
+		}
+		return "", errors.New("not found")
+	}
+	t.Cleanup(func() { lookPath = orig })
+}
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		rate     string
+		expected int64
+		wantErr  bool
+	}{
+		{"", 0, false},
+		{"20MB/s", 20_000_000, false},
+		{"512KiB/s", 512 * 1024, false},
+		{"1.5G", 1_500_000_000, false},
+		{"2048", 2048, false},
+		{"fast", 0, true},
+		{"100B/s", 0, true},
+		{"-5MB/s", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rate, func(t *testing.T) {
+			got, err := parseRateLimit(tt.rate)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRateLimit(%q) error = %v, wantErr %v", tt.rate, err, tt.wantErr)
+			}
+			if got != tt.expected {
+				t.Errorf("parseRateLimit(%q) = %d, want %d", tt.rate, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestThrottledPush(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("throttles uploads", func(t *testing.T) {
+		th := newThrottle(&Config{PushRateLimit: "64KiB/s"})
+		started := time.Now()
+		n, err := io.Copy(io.Discard, th.reader(ctx, bytes.NewReader(make([]byte, 32<<10))))
+		if err != nil || n != 32<<10 {
+			t.Fatalf("unexpected copy result: %d %v", n, err)
+		}
+		if elapsed := time.Since(started); elapsed < 400*time.Millisecond {
+			t.Errorf("expected 32KiB at 64KiB/s to take about 500ms, took %s", elapsed)
+		}
+		if got := newThrottle(&Config{}); got != nil {
+			t.Errorf("expected no throttle without a limit, got %+v", got)
+		}
+	})
+
+	t.Run("stops waiting when cancelled", func(t *testing.T) {
+		th := newThrottle(&Config{PushRateLimit: "1KiB/s"})
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		if _, err := io.Copy(io.Discard, th.reader(cancelled, bytes.NewReader(make([]byte, 8<<10)))); !errors.Is(err, context.Canceled) {
+			t.Errorf("expected the upload to stop, got %v", err)
+		}
+	})
+
+	t.Run("rejects docker pushes", func(t *testing.T) {
+		mock := &MockCommandExecutor{}
+		p := &DockerPlugin{executor: mock}
+
+		resp, err := p.Execute(ctx, plugin.ExecuteRequest{
+			Hook: plugin.HookPostPublish,
+			Config: map[string]any{
+				"image":           "myorg/myapp",
+				"push_rate_limit": "20MB/s",
+			},
+			Context: plugin.ReleaseContext{Version: "v1.0.0"},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.Success || !strings.Contains(resp.Error, "requires daemonless") {
+			t.Errorf("expected push_rate_limit to be rejected, got %+v", resp)
+		}
+		if len(mock.RunCalls) != 0 {
+			t.Errorf("expected nothing to run, got %v", mock.RunCalls)
+		}
+
+		validation, err := p.Validate(ctx, map[string]any{"image": "myorg/myapp", "push_rate_limit": "20MB/s"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if validation.Valid {
+			t.Error("expected validation to reject push_rate_limit without daemonless")
+		}
+		validation, err = p.Validate(ctx, map[string]any{"image": "myorg/myapp", "push_rate_limit": "20MB/s", "daemonless": true, "oci_tarball": "image.tar"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, e := range validation.Errors {
+			if e.Field == "push_rate_limit" {
+				t.Errorf("expected push_rate_limit to be accepted with daemonless, got %s", e.Message)
+			}
+		}
+	})
+
+	t.Run("rejects invalid rate", func(t *testing.T) {
+		p := &DockerPlugin{executor: &MockCommandExecutor{}}
+
+		resp, err := p.Execute(ctx, plugin.ExecuteRequest{
+			Hook: plugin.HookPostPublish,
+			Config: map[string]any{
+				"image":           "myorg/myapp",
+				"push_rate_limit": "unlimited",
+			},
+			Context: plugin.ReleaseContext{Version: "v1.0.0"},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.Success || !strings.Contains(resp.Error, "push_rate_limit") {
+			t.Errorf("expected push_rate_limit error, got %+v", resp)
+		}
+	})
+}
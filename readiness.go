@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// registryProbeInterval is the delay between readiness probes of the
+// registry; replaced in tests.
+var registryProbeInterval = 2 * time.Second
+
+// probeRegistry reports whether the registry API answers. An unauthorized
+// answer counts: the registry is up and asks for credentials.
+func (p *DockerPlugin) probeRegistry(ctx context.Context, cfg *Config) error {
+	status, err := p.newRegistryClient(cfg).getJSON(ctx, "/v2/", nil)
+	switch {
+	case status == http.StatusOK, status == http.StatusUnauthorized:
+		return nil
+	case err != nil:
+		return err
+	}
+	return fmt.Errorf("registry answered %d", status)
+}
+
+// awaitRegistry waits until the registry answers, for registries started by
+// the pipeline that may still be starting or scaled to zero.
+func (p *DockerPlugin) awaitRegistry(ctx context.Context, cfg *Config, outputs *Outputs) error {
+	if cfg.RegistryReadyTimeout == "" {
+		return nil
+	}
+	timeout, _ := time.ParseDuration(cfg.RegistryReadyTimeout)
+	started := time.Now()
+	err := p.waitForRegistry(ctx, cfg, timeout)
+	outputs.stage("registry_ready", started, err)
+	return err
+}
+
+// waitForRegistry probes the registry until it answers or timeout passes.
+func (p *DockerPlugin) waitForRegistry(ctx context.Context, cfg *Config, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var last error
+	for {
+		err := p.probeRegistry(ctx, cfg)
+		if err == nil {
+			return nil
+		}
+		if last == nil || ctx.Err() == nil {
+			last = err
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("registry %s not ready after %s: %w", registryHost(cfg), timeout, last)
+		case <-time.After(registryProbeInterval):
+		}
+	}
+}
+
+// keepRegistryWarm probes the registry and renews password_command logins
+// every registry_keepalive while a long build runs, so the push at its end
+// finds the registry up and the credentials fresh. The returned function
+// stops it and returns the failures as warnings.
+func (p *DockerPlugin) keepRegistryWarm(ctx context.Context, cfg *Config) func() []string {
+	if cfg.RegistryKeepalive == "" || !cfg.Push {
+		return func() []string { return nil }
+	}
+	interval, _ := time.ParseDuration(cfg.RegistryKeepalive)
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var warnings []string
+	warn := func(format string, args ...any) {
+		mu.Lock()
+		defer mu.Unlock()
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if err := p.probeRegistry(ctx, cfg); err != nil && ctx.Err() == nil {
+				warn("registry keepalive probe failed: %v", err)
+			}
+			if err := p.refreshLogin(ctx, cfg, false); err != nil && ctx.Err() == nil {
+				warn("registry keepalive login failed: %v", err)
+			}
+		}
+	}()
+
+	return func() []string {
+		cancel()
+		wg.Wait()
+		return warnings
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAwaitRegistryWaitsForRegistry(t *testing.T) {
+	registryProbeInterval = time.Millisecond
+	t.Cleanup(func() { registryProbeInterval = 2 * time.Second })
+
+	var probes atomic.Int32
+	p, host := newTestRegistry(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if probes.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	outputs := &Outputs{}
+	if err := p.awaitRegistry(context.Background(), &Config{Registry: host, RegistryReadyTimeout: "5s"}, outputs); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if probes.Load() != 3 {
+		t.Errorf("expected 3 probes, got %d", probes.Load())
+	}
+	if len(outputs.Stages) != 1 || outputs.Stages[0].Name != "registry_ready" || outputs.Stages[0].Status != stageSucceeded {
+		t.Errorf("expected a succeeded registry_ready stage, got %+v", outputs.Stages)
+	}
+}
+
+func TestAwaitRegistryTimesOut(t *testing.T) {
+	registryProbeInterval = time.Millisecond
+	t.Cleanup(func() { registryProbeInterval = 2 * time.Second })
+
+	p, host := newTestRegistry(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	err := p.awaitRegistry(context.Background(), &Config{Registry: host, RegistryReadyTimeout: "50ms"}, &Outputs{})
+	if err == nil || !strings.Contains(err.Error(), "not ready after 50ms") || !strings.Contains(err.Error(), "503") {
+		t.Errorf("expected a readiness timeout, got %v", err)
+	}
+}
+
+func TestKeepRegistryWarmReportsFailures(t *testing.T) {
+	p, host := newTestRegistry(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	stop := p.keepRegistryWarm(context.Background(), &Config{Registry: host, Push: true, RegistryKeepalive: "5ms"})
+	time.Sleep(50 * time.Millisecond)
+	warnings := stop()
+	if len(warnings) == 0 || !strings.Contains(warnings[0], "registry keepalive probe failed") {
+		t.Errorf("expected keepalive warnings, got %v", warnings)
+	}
+
+	if warnings := p.keepRegistryWarm(context.Background(), &Config{Registry: host, Push: true})(); warnings != nil {
+		t.Errorf("expected no keepalive without registry_keepalive, got %v", warnings)
+	}
+}
+
+func TestValidateRegistryReadiness(t *testing.T) {
+	if resp := runValidators(&Config{RegistryReadyTimeout: "2m", RegistryKeepalive: "5m"}, pushGateValidators); resp != nil {
+		t.Errorf("unexpected error: %s", resp.Error)
+	}
+	if resp := runValidators(&Config{RegistryKeepalive: "often"}, pushGateValidators); resp == nil || !strings.Contains(resp.Error, "registry_keepalive") {
+		t.Errorf("expected an invalid keepalive to be rejected, got %v", resp)
+	}
+}
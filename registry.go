@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// registryHTTPTimeout bounds every direct registry API request. Blob
+// transfers are bounded by their progress instead; see sendBlob.
+const registryHTTPTimeout = 30 * time.Second
+
+// defaultUserAgent identifies the plugin in registry API calls.
+const defaultUserAgent = "relicta-plugin-docker/2.0.0"
+
+// headerNamePattern matches HTTP header field names (RFC 9110 tokens).
+var headerNamePattern = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+
+// Registry types with provider-specific APIs.
+const (
+	registryTypeGeneric   = "generic"
+	registryTypeDockerHub = "dockerhub"
+	registryTypeGHCR      = "ghcr"
+	registryTypeHarbor    = "harbor"
+	registryTypeECR       = "ecr"
+)
+
+// registryClient performs direct HTTP calls against a registry's API.
+type registryClient struct {
+	httpClient *http.Client
+	baseURL    string
+	username   string
+	password   string
+	userAgent  string
+	headers    map[string]string
+
+	// fallbackURL is tried when the registry cannot be reached at baseURL,
+	// like docker does for local registries serving plaintext HTTP.
+	fallbackURL string
+}
+
+// getHTTPClient returns the HTTP client for registry calls, defaulting to a
+// TLS-verifying client with a request timeout.
+func (p *DockerPlugin) getHTTPClient() *http.Client {
+	if p.httpClient != nil {
+		return p.httpClient
+	}
+	return newRegistryHTTPClient()
+}
+
+// registryHost returns the host serving the registry API for cfg.
+func registryHost(cfg *Config) string {
+	if cfg.Registry == "" || cfg.Registry == "docker.io" {
+		return "registry-1.docker.io"
+	}
+	return cfg.Registry
+}
+
+// newRegistryClient returns a client for the configured registry. Plaintext
+// HTTP is only used for registries explicitly configured with http:// and
+// insecure: true, and as a fallback for local registries configured without
+// a scheme.
+func (p *DockerPlugin) newRegistryClient(cfg *Config) *registryClient {
+	scheme := "https://"
+	if cfg.Insecure && isPlaintextRegistry(cfg, cfg.Registry) {
+		scheme = "http://"
+	}
+	var fallbackURL string
+	if isLocalRegistryOf(cfg, cfg.Registry) {
+		fallbackURL = "http://" + registryHost(cfg)
+	}
+	return &registryClient{
+		fallbackURL: fallbackURL,
+		httpClient:  p.getHTTPClient(),
+		baseURL:     scheme + registryHost(cfg),
+		username:    cfg.Username,
+		password:    cfg.Password,
+		userAgent:   userAgent(cfg),
+		headers:     cfg.RegistryHeaders,
+	}
+}
+
+// getJSON performs an authenticated GET and decodes the JSON response into v.
+// The returned status code is valid whenever the request reached the server.
+func (c *registryClient) getJSON(ctx context.Context, path string, v any) (int, error) {
+	status, err := c.getJSONFrom(ctx, c.baseURL, path, v)
+	if status == 0 && err != nil && c.fallbackURL != "" && ctx.Err() == nil {
+		return c.getJSONFrom(ctx, c.fallbackURL, path, v)
+	}
+	return status, err
+}
+
+func (c *registryClient) getJSONFrom(ctx context.Context, baseURL, path string, v any) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+path, nil)
+	if err != nil {
+		return 0, err
+	}
+	for name, value := range c.headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+	if c.username != "" && c.password != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return resp.StatusCode, fmt.Errorf("GET %s: %s: %s", path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	if v == nil {
+		return resp.StatusCode, nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return resp.StatusCode, fmt.Errorf("GET %s: invalid response: %w", path, err)
+	}
+	return resp.StatusCode, nil
+}
+
+// detectRegistryType returns the configured registry type, inferring it from
+// well-known hostnames and, when probe is set, from the Harbor API.
+func (p *DockerPlugin) detectRegistryType(ctx context.Context, cfg *Config, probe bool) string {
+	if cfg.RegistryType != "" {
+		return cfg.RegistryType
+	}
+
+	switch registryHost(cfg) {
+	case "registry-1.docker.io":
+		return registryTypeDockerHub
+	case "ghcr.io":
+		return registryTypeGHCR
+	}
+	if _, _, ok := ecrRegistry(cfg.Registry); ok {
+		return registryTypeECR
+	}
+
+	if probe {
+		var info struct {
+			HarborVersion string `json:"harbor_version"`
+		}
+		if _, err := p.newRegistryClient(cfg).getJSON(ctx, "/api/v2.0/systeminfo", &info); err == nil && info.HarborVersion != "" {
+			return registryTypeHarbor
+		}
+	}
+	return registryTypeGeneric
+}
+
+// userAgent returns the User-Agent sent with registry API calls.
+func userAgent(cfg *Config) string {
+	if cfg.UserAgent != "" {
+		return cfg.UserAgent
+	}
+	return defaultUserAgent
+}
+
+// validateRegistryHeaders validates user_agent and registry_headers. Accept
+// and User-Agent are managed by the plugin and cannot be overridden.
+func validateRegistryHeaders(agent string, headers map[string]string) error {
+	if strings.ContainsAny(agent, "\r\n") {
+		return fmt.Errorf("user_agent must not contain line breaks")
+	}
+	for name, value := range headers {
+		if !headerNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+		switch http.CanonicalHeaderKey(name) {
+		case "Accept", "User-Agent", "Authorization", "Host", "Content-Length":
+			return fmt.Errorf("header %s is managed by the plugin", http.CanonicalHeaderKey(name))
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("header %s value must not contain line breaks", name)
+		}
+	}
+	return nil
+}
+
+// validateRegistryType validates the registry_type setting.
+func validateRegistryType(registryType string) error {
+	switch registryType {
+	case "", registryTypeGeneric, registryTypeDockerHub, registryTypeGHCR, registryTypeHarbor, registryTypeECR:
+		return nil
+	}
+	return fmt.Errorf("registry_type must be one of: %s, %s, %s, %s, %s",
+		registryTypeGeneric, registryTypeDockerHub, registryTypeGHCR, registryTypeHarbor, registryTypeECR)
+}
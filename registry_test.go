@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestRegistry starts a TLS test server and returns a plugin whose HTTP
+// client trusts it, along with the registry host to configure.
+func newTestRegistry(t *testing.T, handler http.Handler) (*DockerPlugin, string) {
+	t.Helper()
+	srv := httptest.NewTLSServer(handler)
+	t.Cleanup(srv.Close)
+	return &DockerPlugin{executor: &MockCommandExecutor{}, httpClient: srv.Client()}, strings.TrimPrefix(srv.URL, "https://")
+}
+
+func TestRegistryHost(t *testing.T) {
+	tests := []struct {
+		registry string
+		expected string
+	}{
+		{"", "registry-1.docker.io"},
+		{"docker.io", "registry-1.docker.io"},
+		{"ghcr.io", "ghcr.io"},
+		{"harbor.internal:8443", "harbor.internal:8443"},
+	}
+
+	for _, tt := range tests {
+		if got := registryHost(&Config{Registry: tt.registry}); got != tt.expected {
+			t.Errorf("registryHost(%q) = %q, want %q", tt.registry, got, tt.expected)
+		}
+	}
+}
+
+func TestDetectRegistryType(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("well-known hosts", func(t *testing.T) {
+		p := &DockerPlugin{}
+		if got := p.detectRegistryType(ctx, &Config{Registry: "docker.io"}, false); got != registryTypeDockerHub {
+			t.Errorf("expected dockerhub, got %s", got)
+		}
+		if got := p.detectRegistryType(ctx, &Config{Registry: "ghcr.io"}, false); got != registryTypeGHCR {
+			t.Errorf("expected ghcr, got %s", got)
+		}
+	})
+
+	t.Run("explicit type wins", func(t *testing.T) {
+		p := &DockerPlugin{}
+		cfg := &Config{Registry: "ghcr.io", RegistryType: registryTypeGeneric}
+		if got := p.detectRegistryType(ctx, cfg, true); got != registryTypeGeneric {
+			t.Errorf("expected generic, got %s", got)
+		}
+	})
+
+	t.Run("probes harbor", func(t *testing.T) {
+		p, host := newTestRegistry(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/api/v2.0/systeminfo" {
+				_, _ = w.Write([]byte(`{"harbor_version":"v2.11.0"}`))
+				return
+			}
+			http.NotFound(w, r)
+		}))
+		if got := p.detectRegistryType(ctx, &Config{Registry: host}, true); got != registryTypeHarbor {
+			t.Errorf("expected harbor, got %s", got)
+		}
+	})
+
+	t.Run("falls back to generic", func(t *testing.T) {
+		p, host := newTestRegistry(t, http.NotFoundHandler())
+		if got := p.detectRegistryType(ctx, &Config{Registry: host}, true); got != registryTypeGeneric {
+			t.Errorf("expected generic, got %s", got)
+		}
+	})
+}
+
+func TestValidateRegistryType(t *testing.T) {
+	if err := validateRegistryType("harbor"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := validateRegistryType("quay"); err == nil {
+		t.Error("expected error for unknown registry type")
+	}
+}
+
+func TestRegistryRequestMetadata(t *testing.T) {
+	var got http.Header
+	p, host := newTestRegistry(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	cfg := &Config{Registry: host}
+	if _, err := p.newRegistryClient(cfg).getJSON(context.Background(), "/v2/", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Get("User-Agent") != defaultUserAgent {
+		t.Errorf("expected default User-Agent, got %q", got.Get("User-Agent"))
+	}
+
+	cfg.UserAgent = "acme-release/1.0"
+	cfg.RegistryHeaders = map[string]string{"X-Proxy-Tenant": "releases"}
+	if _, err := p.newRegistryClient(cfg).getJSON(context.Background(), "/v2/", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Get("User-Agent") != "acme-release/1.0" || got.Get("X-Proxy-Tenant") != "releases" {
+		t.Errorf("expected custom metadata, got %v", got)
+	}
+}
+
+func TestValidateRegistryHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		agent   string
+		headers map[string]string
+		wantErr bool
+	}{
+		{"empty", "", nil, false},
+		{"custom", "acme/1.0", map[string]string{"X-Proxy-Tenant": "releases"}, false},
+		{"agent with newline", "acme\r\nX-Evil: 1", nil, true},
+		{"invalid name", "", map[string]string{"X Proxy": "a"}, true},
+		{"managed header", "", map[string]string{"authorization": "Bearer x"}, true},
+		{"value with newline", "", map[string]string{"X-Proxy": "a\nb"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRegistryHeaders(tt.agent, tt.headers)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateRegistryHeaders() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
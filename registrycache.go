@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultRegistryCacheDir is where registry_cache_ttl keeps lookups between
+// executions unless registry_cache_dir is set.
+var defaultRegistryCacheDir = filepath.Join(os.TempDir(), "relicta-docker-registry-cache")
+
+// lookupCache remembers registry lookups, such as the digest a tag resolves
+// to or the tags of a repository, so repeated checks across tags, mirrors
+// and stages ask the registry once. Operations that change the registry
+// clear it.
+type lookupCache struct {
+	// dir keeps successful lookups for ttl between executions when ttl is
+	// set.
+	dir string
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]lookupResult
+}
+
+// lookupResult is the outcome of one lookup.
+type lookupResult struct {
+	out []byte
+	err error
+}
+
+// lookupFile is a lookup kept on disk.
+type lookupFile struct {
+	Key      string    `json:"key"`
+	Output   []byte    `json:"output"`
+	StoredAt time.Time `json:"stored_at"`
+}
+
+// newLookupCache returns the lookup cache of one execution.
+func newLookupCache(cfg *Config) *lookupCache {
+	c := &lookupCache{entries: map[string]lookupResult{}}
+	if ttl, err := time.ParseDuration(cfg.RegistryCacheTTL); err == nil && ttl > 0 {
+		c.ttl = ttl
+		c.dir = cfg.RegistryCacheDir
+		if c.dir == "" {
+			c.dir = defaultRegistryCacheDir
+		}
+	}
+	return c
+}
+
+// withLookupCache returns a copy of p whose registry lookups are cached for
+// the execution.
+func (p *DockerPlugin) withLookupCache(cfg *Config) *DockerPlugin {
+	cache := newLookupCache(cfg)
+	cached := *p
+	cached.lookups = cache
+	cached.executor = &cachingExecutor{next: p.getExecutor(), cache: cache}
+	return &cached
+}
+
+// lookup returns the cached result of key, calling fetch on a miss. Failed
+// lookups, such as a tag that does not exist yet, are only cached in memory;
+// a canceled lookup is not cached.
+func (c *lookupCache) lookup(ctx context.Context, key string, fetch func() ([]byte, error)) ([]byte, error) {
+	if c == nil {
+		return fetch()
+	}
+	c.mu.Lock()
+	r, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok {
+		return r.out, r.err
+	}
+	if out, ok := c.load(key); ok {
+		c.store(key, lookupResult{out: out})
+		return out, nil
+	}
+
+	out, err := fetch()
+	if ctx.Err() != nil {
+		return out, err
+	}
+	c.store(key, lookupResult{out: out, err: err})
+	if err == nil {
+		c.save(key, out)
+	}
+	return out, err
+}
+
+func (c *lookupCache) store(key string, r lookupResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = r
+}
+
+// invalidate forgets every lookup, in memory and on disk.
+func (c *lookupCache) invalidate() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) > 0 {
+		c.entries = map[string]lookupResult{}
+	}
+	if c.dir != "" {
+		files, _ := filepath.Glob(filepath.Join(c.dir, "*.json"))
+		for _, file := range files {
+			os.Remove(file)
+		}
+	}
+}
+
+// path returns the file of key on disk.
+func (c *lookupCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// load returns the lookup of key kept on disk, unless it is older than the
+// TTL.
+func (c *lookupCache) load(key string) ([]byte, bool) {
+	if c.dir == "" {
+		return nil, false
+	}
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	var f lookupFile
+	if json.Unmarshal(data, &f) != nil || f.Key != key || timeNow().Sub(f.StoredAt) > c.ttl {
+		return nil, false
+	}
+	return f.Output, true
+}
+
+// save keeps a lookup on disk. Failures only cost a later lookup.
+func (c *lookupCache) save(key string, out []byte) {
+	if c.dir == "" {
+		return
+	}
+	data, err := json.Marshal(lookupFile{Key: key, Output: out, StoredAt: timeNow()})
+	if err != nil || os.MkdirAll(c.dir, 0o700) != nil {
+		return
+	}
+	os.WriteFile(c.path(key), data, 0o600)
+}
+
+// lookupKey identifies a command or request in the cache.
+func lookupKey(name string, args ...string) string {
+	return name + "\x00" + strings.Join(args, "\x00")
+}
+
+// isRegistryLookup reports whether a command only reads from the registry.
+func isRegistryLookup(name string, args []string) bool {
+	return name == "docker" && len(args) > 3 && slices.Equal(args[:3], []string{"buildx", "imagetools", "inspect"})
+}
+
+// readOnlyCommands are the docker commands run through Run that cannot
+// change what the registry returns.
+var readOnlyCommands = []string{"build", "login", "logout", "pull", "tag"}
+
+// changesRegistry reports whether a command run through Run may push, retag
+// or sign in the registry. Output is only used for commands that read.
+func changesRegistry(name string, args []string) bool {
+	return name != "docker" || len(args) == 0 || !slices.Contains(readOnlyCommands, args[0])
+}
+
+// cachingExecutor answers registry lookups from the cache of the execution
+// and clears it before commands that change the registry, such as pushes,
+// retags and signatures.
+type cachingExecutor struct {
+	next  CommandExecutor
+	cache *lookupCache
+}
+
+func (e *cachingExecutor) invalidate(name string, args []string) {
+	if changesRegistry(name, args) {
+		e.cache.invalidate()
+	}
+}
+
+// Run clears the cache when the command changes the registry and executes
+// it.
+func (e *cachingExecutor) Run(ctx context.Context, name string, args []string, stdin io.Reader) error {
+	e.invalidate(name, args)
+	return e.next.Run(ctx, name, args, stdin)
+}
+
+// RunCapture clears the cache when the command changes the registry and
+// executes it.
+func (e *cachingExecutor) RunCapture(ctx context.Context, name string, args []string, stdin io.Reader, stdout io.Writer) error {
+	e.invalidate(name, args)
+	return e.next.RunCapture(ctx, name, args, stdin, stdout)
+}
+
+// RunCaptureStderr clears the cache when the command changes the registry
+// and executes it.
+func (e *cachingExecutor) RunCaptureStderr(ctx context.Context, name string, args []string, stdin io.Reader, stderr io.Writer) error {
+	e.invalidate(name, args)
+	return e.next.RunCaptureStderr(ctx, name, args, stdin, stderr)
+}
+
+// Output answers registry lookups from the cache.
+func (e *cachingExecutor) Output(ctx context.Context, name string, args []string) ([]byte, error) {
+	if !isRegistryLookup(name, args) {
+		return e.next.Output(ctx, name, args)
+	}
+	return e.cache.lookup(ctx, lookupKey(name, args...), func() ([]byte, error) {
+		return e.next.Output(ctx, name, args)
+	})
+}
+
+// OutputInput executes the command.
+func (e *cachingExecutor) OutputInput(ctx context.Context, name string, args []string, stdin io.Reader) ([]byte, error) {
+	return e.next.OutputInput(ctx, name, args, stdin)
+}
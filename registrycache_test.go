@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// countLookups returns the imagetools inspect calls that reached mock.
+func countLookups(mock *MockCommandExecutor) int {
+	n := 0
+	for _, call := range mock.OutputCalls {
+		if isRegistryLookup(call.Name, call.Args) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestCachingExecutorCachesLookups(t *testing.T) {
+	mock := &MockCommandExecutor{
+		OutputFunc: func(ctx context.Context, name string, args []string) ([]byte, error) {
+			if strings.HasSuffix(args[len(args)-1], ":missing") {
+				return nil, errors.New("not found")
+			}
+			return []byte("sha256:abc\n"), nil
+		},
+	}
+	p := (&DockerPlugin{executor: mock}).withLookupCache(&Config{})
+	ctx := context.Background()
+
+	for range 3 {
+		if digest, err := p.resolveDigest(ctx, "myorg/myapp:1.0.0"); err != nil || digest != "sha256:abc" {
+			t.Fatalf("resolveDigest() = %q, %v", digest, err)
+		}
+		if p.imageExists(ctx, "myorg/myapp:missing") {
+			t.Fatalf("expected the missing tag not to exist")
+		}
+	}
+	if n := countLookups(mock); n != 2 {
+		t.Errorf("expected one lookup per reference, got %d", n)
+	}
+
+	if err := p.getExecutor().Run(ctx, "docker", []string{"login", "ghcr.io"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	p.resolveDigest(ctx, "myorg/myapp:1.0.0")
+	if n := countLookups(mock); n != 2 {
+		t.Errorf("expected a login to keep the cache, got %d lookups", n)
+	}
+
+	if err := p.getExecutor().Run(ctx, "docker", []string{"push", "myorg/myapp:missing"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	p.resolveDigest(ctx, "myorg/myapp:1.0.0")
+	p.imageExists(ctx, "myorg/myapp:missing")
+	if n := countLookups(mock); n != 4 {
+		t.Errorf("expected a push to clear the cache, got %d lookups", n)
+	}
+}
+
+func TestLookupCacheOnDisk(t *testing.T) {
+	now := time.Date(2026, time.October, 14, 12, 0, 0, 0, time.UTC)
+	stubTimeNow(t, now)
+	cfg := &Config{RegistryCacheTTL: "2m", RegistryCacheDir: t.TempDir()}
+	ctx := context.Background()
+	fetches := 0
+	fetch := func() ([]byte, error) {
+		fetches++
+		return []byte("sha256:abc"), nil
+	}
+
+	if _, err := newLookupCache(cfg).lookup(ctx, "key", fetch); err != nil {
+		t.Fatal(err)
+	}
+	out, err := newLookupCache(cfg).lookup(ctx, "key", fetch)
+	if err != nil || string(out) != "sha256:abc" || fetches != 1 {
+		t.Errorf("expected the next execution to reuse the lookup, got %q, %v after %d fetches", out, err, fetches)
+	}
+
+	stubTimeNow(t, now.Add(3*time.Minute))
+	newLookupCache(cfg).lookup(ctx, "key", fetch)
+	if fetches != 2 {
+		t.Errorf("expected an expired lookup to be fetched again, got %d fetches", fetches)
+	}
+
+	newLookupCache(cfg).invalidate()
+	newLookupCache(cfg).lookup(ctx, "key", fetch)
+	if fetches != 3 {
+		t.Errorf("expected an invalidated lookup to be fetched again, got %d fetches", fetches)
+	}
+
+	failing := newLookupCache(cfg)
+	failing.lookup(ctx, "failed", func() ([]byte, error) { return nil, errors.New("not found") })
+	if _, ok := failing.load("failed"); ok {
+		t.Errorf("expected a failed lookup not to be kept on disk")
+	}
+}
+
+func TestExecuteInvalidRegistryCacheTTL(t *testing.T) {
+	p := &DockerPlugin{executor: &MockCommandExecutor{}}
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"image": "myorg/myapp", "registry_cache_ttl": "soon"},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success || !strings.Contains(resp.Error, "invalid registry_cache_ttl") {
+		t.Errorf("expected an invalid registry_cache_ttl to fail, got %+v", resp)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// Statuses of remote checks.
+const (
+	remoteCheckNew     = "new"
+	remoteCheckExists  = "exists"
+	remoteCheckOK      = "ok"
+	remoteCheckWarning = "warning"
+	remoteCheckFailed  = "failed"
+)
+
+// RemoteCheck is a finding of the read-only registry checks of a dry run.
+type RemoteCheck struct {
+	// Check is registry, tag, quota or rolling_tag.
+	Check  string `json:"check"`
+	Target string `json:"target"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// remoteChecks previews a release against the live registry without
+// changing it: whether the registry answers, which tags exist and the
+// digest the release would replace, the moves of moving tags with their
+// rollback commands, the storage quota and the rolling tags the release
+// would hold back. Failed checks become warnings, the dry run still
+// succeeds. It uses the credentials docker already has, as dry runs do not
+// log in.
+func (p *DockerPlugin) remoteChecks(ctx context.Context, cfg *Config, releaseVersion string, imageNames []string, outputs *Outputs) []string {
+	var checks []RemoteCheck
+	var warnings []string
+	add := func(c RemoteCheck) {
+		checks = append(checks, c)
+		if c.Status == remoteCheckFailed || c.Status == remoteCheckWarning {
+			warnings = append(warnings, fmt.Sprintf("remote check %s %s: %s", c.Check, c.Target, c.Detail))
+		}
+	}
+
+	if err := p.probeRegistry(ctx, cfg); err != nil {
+		add(RemoteCheck{Check: "registry", Target: registryHost(cfg), Status: remoteCheckFailed, Detail: err.Error()})
+		outputs.RemoteChecks = checks
+		return warnings
+	}
+	add(RemoteCheck{Check: "registry", Target: registryHost(cfg), Status: remoteCheckOK})
+
+	moving, _ := movingRefs(cfg, releaseVersion)
+	for _, ref := range imageNames {
+		digest, err := p.resolveDigest(ctx, ref)
+		switch {
+		case err != nil:
+			add(RemoteCheck{Check: "tag", Target: ref, Status: remoteCheckNew})
+		case slices.Contains(moving, ref):
+			add(RemoteCheck{Check: "tag", Target: ref, Status: remoteCheckExists, Detail: "would move from " + digest})
+		default:
+			add(RemoteCheck{Check: "tag", Target: ref, Status: remoteCheckWarning, Detail: "already exists at " + digest + " and would be overwritten"})
+		}
+	}
+	p.recordTagMoves(ctx, cfg, releaseVersion, outputs)
+
+	if cfg.QuotaCheck {
+		target := registryHost(cfg)
+		switch warning, err := p.checkQuota(ctx, cfg, 0); {
+		case err != nil:
+			add(RemoteCheck{Check: "quota", Target: target, Status: remoteCheckFailed, Detail: err.Error()})
+		case warning != "":
+			add(RemoteCheck{Check: "quota", Target: target, Status: remoteCheckWarning, Detail: warning})
+		default:
+			add(RemoteCheck{Check: "quota", Target: target, Status: remoteCheckOK})
+		}
+	}
+
+	if cfg.RollingTagCheck {
+		_, held, err := p.checkRollingTags(ctx, cfg, releaseVersion, outputs)
+		if err != nil {
+			add(RemoteCheck{Check: "rolling_tag", Target: imageRepository(cfg), Status: remoteCheckFailed, Detail: err.Error()})
+		}
+		for _, warning := range held {
+			add(RemoteCheck{Check: "rolling_tag", Target: imageRepository(cfg), Status: remoteCheckWarning, Detail: warning})
+		}
+	}
+
+	outputs.RemoteChecks = checks
+	return warnings
+}
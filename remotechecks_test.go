@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestDryRunRemoteChecks(t *testing.T) {
+	p, host := newTestRegistry(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	mock := &MockCommandExecutor{
+		OutputFunc: func(ctx context.Context, name string, args []string) ([]byte, error) {
+			if strings.HasSuffix(args[len(args)-1], ":1.2.0") {
+				return nil, errors.New("not found")
+			}
+			return []byte("sha256:abc\n"), nil
+		},
+	}
+	p.executor = mock
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"registry":              host,
+			"image":                 "myorg/myapp",
+			"dry_run_remote_checks": true,
+		},
+		Context: plugin.ReleaseContext{Version: "1.2.0"},
+		DryRun:  true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+	if len(mock.RunCalls) != 0 {
+		t.Errorf("expected no commands to run, got %+v", mock.RunCalls)
+	}
+
+	checks, _ := resp.Outputs["remote_checks"].([]RemoteCheck)
+	var statuses []string
+	for _, check := range checks {
+		statuses = append(statuses, check.Check+"="+check.Status)
+	}
+	if !slices.Equal(statuses, []string{"registry=ok", "tag=new", "tag=exists"}) {
+		t.Errorf("unexpected remote checks %+v", checks)
+	}
+	if checks[2].Detail != "would move from sha256:abc" {
+		t.Errorf("expected the previous digest of latest, got %q", checks[2].Detail)
+	}
+}
+
+func TestRemoteChecksUnreachableRegistry(t *testing.T) {
+	p, host := newTestRegistry(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	p.executor = &MockCommandExecutor{}
+	cfg := &Config{Registry: host, Image: "myorg/myapp"}
+	outputs := &Outputs{}
+
+	warnings := p.remoteChecks(context.Background(), cfg, "1.2.0", []string{host + "/myorg/myapp:1.2.0"}, outputs)
+	if len(outputs.RemoteChecks) != 1 || outputs.RemoteChecks[0].Status != remoteCheckFailed {
+		t.Errorf("expected only the failed registry check, got %+v", outputs.RemoteChecks)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "503 Service Unavailable") {
+		t.Errorf("expected a warning about the registry, got %v", warnings)
+	}
+}
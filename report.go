@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// failureReportStderrLines bounds the standard error lines of a failure
+// report.
+const failureReportStderrLines = 20
+
+// FailureReport is the machine-readable report written to failure_report
+// when an execution fails, so CI systems can annotate the failure without
+// scraping logs.
+type FailureReport struct {
+	Plugin      string         `json:"plugin"`
+	Hook        plugin.Hook    `json:"hook"`
+	Version     string         `json:"version"`
+	FailedAt    time.Time      `json:"failed_at"`
+	Stage       string         `json:"stage,omitempty"`
+	Error       string         `json:"error"`
+	Command     *FailedCommand `json:"command,omitempty"`
+	Remediation string         `json:"remediation,omitempty"`
+}
+
+// FailedCommand is the last external command that failed.
+type FailedCommand struct {
+	Name string   `json:"name"`
+	Args []string `json:"args"`
+	// ExitCode is omitted when the command did not exit, e.g. because it
+	// was not found or was killed.
+	ExitCode int      `json:"exit_code,omitempty"`
+	Stderr   []string `json:"stderr,omitempty"`
+}
+
+// failureExecutor remembers the last command failing through the wrapped
+// executor.
+type failureExecutor struct {
+	next CommandExecutor
+	// redactKeys lists build args to redact regardless of their name.
+	redactKeys []string
+
+	mu     sync.Mutex
+	failed *FailedCommand
+	// failedErr is the error of the failed command.
+	failedErr string
+}
+
+func (e *failureExecutor) record(name string, args []string, err error) {
+	if err == nil {
+		return
+	}
+	cmd := &FailedCommand{Name: name, Args: redactArgs(args, e.redactKeys...)}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		cmd.ExitCode = exitErr.ExitCode()
+	}
+	var cmdErr *commandError
+	if errors.As(err, &cmdErr) {
+		cmd.Stderr = cmdErr.stderr
+		if n := len(cmd.Stderr); n > failureReportStderrLines {
+			cmd.Stderr = cmd.Stderr[n-failureReportStderrLines:]
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failed = cmd
+	e.failedErr = err.Error()
+}
+
+// cause returns the last failed command when errMsg reports its error, so
+// tolerated failures, such as a lookup of an image that does not exist yet,
+// are not blamed for unrelated errors.
+func (e *failureExecutor) cause(errMsg string) *FailedCommand {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.failed == nil || !strings.Contains(errMsg, e.failedErr) {
+		return nil
+	}
+	return e.failed
+}
+
+// Run executes the command and records its failure.
+func (e *failureExecutor) Run(ctx context.Context, name string, args []string, stdin io.Reader) error {
+	err := e.next.Run(ctx, name, args, stdin)
+	e.record(name, args, err)
+	return err
+}
+
+// RunCapture executes the command and records its failure.
+func (e *failureExecutor) RunCapture(ctx context.Context, name string, args []string, stdin io.Reader, stdout io.Writer) error {
+	err := e.next.RunCapture(ctx, name, args, stdin, stdout)
+	e.record(name, args, err)
+	return err
+}
+
+// RunCaptureStderr executes the command and records its failure.
+func (e *failureExecutor) RunCaptureStderr(ctx context.Context, name string, args []string, stdin io.Reader, stderr io.Writer) error {
+	err := e.next.RunCaptureStderr(ctx, name, args, stdin, stderr)
+	e.record(name, args, err)
+	return err
+}
+
+// Output executes the command and records its failure.
+func (e *failureExecutor) Output(ctx context.Context, name string, args []string) ([]byte, error) {
+	out, err := e.next.Output(ctx, name, args)
+	e.record(name, args, err)
+	return out, err
+}
+
+// OutputInput executes the command and records its failure.
+func (e *failureExecutor) OutputInput(ctx context.Context, name string, args []string, stdin io.Reader) ([]byte, error) {
+	out, err := e.next.OutputInput(ctx, name, args, stdin)
+	e.record(name, args, err)
+	return out, err
+}
+
+// failedStage returns the name of the last failed stage of the outputs.
+func failedStage(outputs map[string]any) string {
+	stages, _ := outputs["stages"].([]StageStatus)
+	for i := len(stages) - 1; i >= 0; i-- {
+		if stages[i].Status == stageFailed {
+			return stages[i].Name
+		}
+	}
+	return ""
+}
+
+// commandStage names the stage a command belongs to, for failures before
+// any stage was recorded.
+func commandStage(cmd *FailedCommand) string {
+	for _, arg := range cmd.Args {
+		switch arg {
+		case "login":
+			return "login"
+		case "build":
+			return "build"
+		case "push":
+			return "push"
+		}
+	}
+	return ""
+}
+
+// suggestRemediation returns advice for the failure, or an empty string.
+func suggestRemediation(report *FailureReport) string {
+	text := report.Error
+	if report.Command != nil {
+		text += "\n" + strings.Join(report.Command.Stderr, "\n")
+	}
+	return remediationHint(text)
+}
+
+// writeFailureReport writes report to cfg.FailureReport.
+func writeFailureReport(cfg *Config, report *FailureReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(cfg.FailureReport, append(data, '\n'), 0o644)
+}
+
+// executeReported runs the hook with failing commands recorded and writes a
+// failure report when it fails. A report left by an earlier execution is
+// removed first, so the file exists only when this execution failed.
+func (p *DockerPlugin) executeReported(ctx context.Context, cfg *Config, req plugin.ExecuteRequest, run func(*DockerPlugin, context.Context, *Config, plugin.ExecuteRequest) (*plugin.ExecuteResponse, error)) (*plugin.ExecuteResponse, error) {
+	if err := os.Remove(cfg.FailureReport); err != nil && !os.IsNotExist(err) {
+		return &plugin.ExecuteResponse{Success: false, Error: "failed to remove stale failure report: " + err.Error()}, nil
+	}
+
+	recorder := &failureExecutor{next: p.getExecutor(), redactKeys: redactedBuildArgs(cfg)}
+	reported := *p
+	reported.executor = recorder
+
+	resp, err := run(&reported, ctx, cfg, req)
+	if err == nil && (resp == nil || resp.Success) {
+		return resp, err
+	}
+
+	report := &FailureReport{
+		Plugin:   "docker",
+		Hook:     req.Hook,
+		Version:  req.Context.Version,
+		FailedAt: time.Now().UTC(),
+	}
+	if resp != nil {
+		report.Error = resp.Error
+		report.Stage = failedStage(resp.Outputs)
+	}
+	if err != nil {
+		report.Error = err.Error()
+	}
+	report.Command = recorder.cause(report.Error)
+	if report.Stage == "" && report.Command != nil {
+		report.Stage = commandStage(report.Command)
+	}
+	report.Remediation = suggestRemediation(report)
+
+	if reportErr := writeFailureReport(cfg, report); reportErr != nil && resp != nil {
+		resp.Error += "; failed to write failure report: " + reportErr.Error()
+	}
+	return resp, err
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestExecuteWritesFailureReport(t *testing.T) {
+	chdir(t, t.TempDir())
+	exitErr := exec.Command("sh", "-c", "exit 3").Run()
+	mock := &MockCommandExecutor{
+		RunFunc: func(_ context.Context, _ string, args []string, _ io.Reader) error {
+			if args[0] == "push" {
+				return &commandError{
+					err:    errors.Join(exitErr, errors.New("denied: requested access to the resource is denied")),
+					stderr: []string{"The push refers to repository [docker.io/myorg/myapp]", "denied: requested access to the resource is denied"},
+				}
+			}
+			return nil
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":          "myorg/myapp",
+			"tags":           []any{"1.0.0"},
+			"build_args":     map[string]any{"NPM_TOKEN": "s3cret"},
+			"failure_report": "failure.json",
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success {
+		t.Fatal("expected the push to fail")
+	}
+
+	data, err := os.ReadFile("failure.json")
+	if err != nil {
+		t.Fatalf("expected a failure report: %v", err)
+	}
+	var report FailureReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("invalid failure report: %v", err)
+	}
+	if report.Stage != "push" || !strings.HasPrefix(resp.Error, report.Error) {
+		t.Errorf("unexpected stage or error: %+v", report)
+	}
+	if report.Command == nil || report.Command.ExitCode != 3 || !slices.Equal(report.Command.Args, []string{"push", "myorg/myapp:1.0.0"}) {
+		t.Fatalf("unexpected failed command: %+v", report.Command)
+	}
+	if len(report.Command.Stderr) != 2 {
+		t.Errorf("expected the stderr lines, got %v", report.Command.Stderr)
+	}
+	if !strings.Contains(report.Remediation, "write scope") {
+		t.Errorf("unexpected remediation: %q", report.Remediation)
+	}
+}
+
+func TestFailureReportRedactsArgs(t *testing.T) {
+	chdir(t, t.TempDir())
+	mock := &MockCommandExecutor{FailOnCall: 1}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":          "myapp",
+			"build_args":     map[string]any{"NPM_TOKEN": "s3cret"},
+			"failure_report": "failure.json",
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil || resp.Success {
+		t.Fatalf("expected the build to fail, got %+v, %v", resp, err)
+	}
+	data, err := os.ReadFile("failure.json")
+	if err != nil {
+		t.Fatalf("expected a failure report: %v", err)
+	}
+	if strings.Contains(string(data), "s3cret") {
+		t.Errorf("expected the build arg to be redacted, got %s", data)
+	}
+	var report FailureReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("invalid failure report: %v", err)
+	}
+	if report.Stage != "build" || report.Command == nil || !slices.Contains(report.Command.Args, "NPM_TOKEN="+auditRedacted) {
+		t.Errorf("unexpected report: %s", data)
+	}
+}
+
+func TestFailureReportRemovedOnSuccess(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+	writeFiles(t, dir, map[string]string{"failure.json": "{}"})
+	p := &DockerPlugin{executor: &MockCommandExecutor{}}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"image": "myapp", "failure_report": "failure.json"},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil || !resp.Success {
+		t.Fatalf("expected success, got %+v, %v", resp, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "failure.json")); !os.IsNotExist(err) {
+		t.Errorf("expected the stale report to be removed, got %v", err)
+	}
+}
+
+func TestFailureReportIgnoresToleratedFailures(t *testing.T) {
+	recorder := &failureExecutor{next: &MockCommandExecutor{FailOnCall: 1}}
+	_ = recorder.Run(context.Background(), "docker", []string{"pull", "myapp:previous"}, nil)
+	if cmd := recorder.cause("label policy violation: missing required labels maintainer"); cmd != nil {
+		t.Errorf("expected an unrelated failed command to be left out, got %+v", cmd)
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// CPU list pattern as accepted by taskset -c and AllowedCPUs, e.g. 0-3,6.
+	cpuSetPattern = regexp.MustCompile(`^[0-9]+(-[0-9]+)?(,[0-9]+(-[0-9]+)?)*$`)
+
+	// systemd slice unit name, e.g. release.slice or ci-builds.slice.
+	cgroupSlicePattern = regexp.MustCompile(`^[a-zA-Z0-9_.:-]+\.slice$`)
+
+	// ulimit as accepted by docker build --ulimit, e.g. nofile=65536:65536.
+	ulimitPattern = regexp.MustCompile(`^([a-z]+)=(-1|[0-9]+)(:(-1|[0-9]+))?$`)
+)
+
+// ulimitNames are the resource limits docker build accepts.
+var ulimitNames = map[string]bool{
+	"core": true, "cpu": true, "data": true, "fsize": true, "locks": true, "memlock": true,
+	"msgqueue": true, "nice": true, "nofile": true, "nproc": true, "rss": true, "rtprio": true,
+	"rttime": true, "sigpending": true, "stack": true,
+}
+
+// Process priorities accepted by priority.
+const (
+	priorityNormal = "normal"
+	priorityLow    = "low"
+)
+
+// validateResourceConfig validates cpuset, cgroup_slice, priority and the
+// limits.
+func validateResourceConfig(cfg *Config) error {
+	if cfg.Priority != "" && cfg.Priority != priorityNormal && cfg.Priority != priorityLow {
+		return fmt.Errorf("invalid priority %q: expected low or normal", cfg.Priority)
+	}
+	if cfg.CPUSet != "" && !cpuSetPattern.MatchString(cfg.CPUSet) {
+		return fmt.Errorf("invalid cpuset %q: expected a CPU list like 0-3,6", cfg.CPUSet)
+	}
+	if cfg.CgroupSlice != "" && !cgroupSlicePattern.MatchString(cfg.CgroupSlice) {
+		return fmt.Errorf("invalid cgroup_slice %q: expected a systemd slice unit like release.slice", cfg.CgroupSlice)
+	}
+	for _, ulimit := range cfg.Ulimits {
+		if m := ulimitPattern.FindStringSubmatch(ulimit); m == nil || !ulimitNames[m[1]] {
+			return fmt.Errorf("invalid ulimit %q: expected name=soft[:hard] like nofile=65536:65536", ulimit)
+		}
+	}
+	if cfg.NofileLimit < 0 {
+		return fmt.Errorf("nofile_limit must not be negative")
+	}
+	if cfg.MaxParallelism < 0 {
+		return fmt.Errorf("max_parallelism must not be negative")
+	}
+	if cfg.MaxParallelism > 0 && len(cfg.BuilderNodes) == 0 {
+		return fmt.Errorf("max_parallelism requires builder_nodes; set max-parallelism in the buildkitd.toml of existing builders")
+	}
+	return nil
+}
+
+// ulimitArgs returns the --ulimit flags applied to RUN steps.
+func ulimitArgs(cfg *Config) []string {
+	var args []string
+	for _, ulimit := range cfg.Ulimits {
+		args = append(args, "--ulimit", ulimit)
+	}
+	return args
+}
+
+// fileLimitWrapper returns the command prefix that raises the open file
+// limit of docker build and push invocations to nofile_limit. The docker CLI
+// opens every file of the build context while sending it, so very large
+// contexts hit EMFILE under the default soft limit of many runners. Only the
+// soft limit is raised; it cannot exceed the runner's hard limit. Without
+// prlimit the limit is not raised.
+func fileLimitWrapper(cfg *Config) []string {
+	if cfg.NofileLimit == 0 {
+		return nil
+	}
+	if _, err := lookPath("prlimit"); err != nil {
+		return nil
+	}
+	return []string{"prlimit", fmt.Sprintf("--nofile=%d:", cfg.NofileLimit), "--"}
+}
+
+// fileLimitWarning explains when nofile_limit cannot be applied.
+func fileLimitWarning(cfg *Config) string {
+	if cfg.NofileLimit == 0 {
+		return ""
+	}
+	if _, err := lookPath("prlimit"); err != nil {
+		return "nofile_limit is not applied: prlimit was not found in PATH"
+	}
+	return ""
+}
+
+// resourceWrapper returns the command prefix that confines docker build and
+// push invocations to cgroup_slice and cpuset, or nil when neither is set or
+// the required tool is unavailable. With a slice, systemd-run applies the
+// cpuset as AllowedCPUs of the transient scope; otherwise taskset pins it.
+func resourceWrapper(cfg *Config) []string {
+	if cfg.CgroupSlice != "" {
+		if _, err := lookPath("systemd-run"); err != nil {
+			return nil
+		}
+		wrapper := []string{"systemd-run", "--scope", "--quiet", "--collect", "--slice=" + cfg.CgroupSlice}
+		if cfg.CPUSet != "" {
+			wrapper = append(wrapper, "--property=AllowedCPUs="+cfg.CPUSet)
+		}
+		return append(wrapper, "--")
+	}
+	if cfg.CPUSet != "" {
+		if _, err := lookPath("taskset"); err != nil {
+			return nil
+		}
+		return []string{"taskset", "-c", cfg.CPUSet}
+	}
+	return nil
+}
+
+// resourceWarning explains when cpuset or cgroup_slice cannot be enforced.
+func resourceWarning(cfg *Config) string {
+	tool := ""
+	switch {
+	case cfg.CgroupSlice != "":
+		tool = "systemd-run"
+	case cfg.CPUSet != "":
+		tool = "taskset"
+	default:
+		return ""
+	}
+	if _, err := lookPath(tool); err != nil {
+		return fmt.Sprintf("cpuset and cgroup_slice are not enforced: %s was not found in PATH", tool)
+	}
+	return ""
+}
+
+// priorityWrapper returns the command prefix that lowers the CPU and IO
+// priority of docker build and push invocations for priority: low, using the
+// lowest best-effort IO class rather than idle so the release still finishes
+// on a busy runner. Missing tools are skipped.
+func priorityWrapper(cfg *Config) []string {
+	if cfg.Priority != priorityLow {
+		return nil
+	}
+	var wrapper []string
+	if _, err := lookPath("nice"); err == nil {
+		wrapper = append(wrapper, "nice", "-n", "10")
+	}
+	if _, err := lookPath("ionice"); err == nil {
+		wrapper = append(wrapper, "ionice", "-c", "2", "-n", "7")
+	}
+	return wrapper
+}
+
+// priorityWarning explains what priority: low does not cover: nice and
+// ionice only apply to the docker CLI, while RUN steps, layer compression
+// and uploads run in the daemon at its own priority. Those follow the
+// weights of cgroup_slice, which RUN steps are placed in, if set.
+func priorityWarning(cfg *Config) string {
+	if cfg.Priority != priorityLow {
+		return ""
+	}
+	var missing []string
+	for _, tool := range []string{"nice", "ionice"} {
+		if _, err := lookPath(tool); err != nil {
+			missing = append(missing, tool)
+		}
+	}
+	warning := "priority low only lowers the docker CLI processes; work done by the docker daemon, such as RUN steps and layer uploads, keeps its priority"
+	if cfg.CgroupSlice != "" {
+		warning += fmt.Sprintf(" (RUN steps run in %s, so its CPU and IO weights apply to them)", cfg.CgroupSlice)
+	} else {
+		warning += " unless cgroup_slice names a slice with lower CPU and IO weights"
+	}
+	if len(missing) > 0 {
+		warning += fmt.Sprintf("; %s not found in PATH", strings.Join(missing, " and "))
+	}
+	return warning
+}
+
+// wrapCommand prefixes name and args with each non-empty wrapper in order.
+func wrapCommand(name string, args []string, wrappers ...[]string) (string, []string) {
+	for i := len(wrappers) - 1; i >= 0; i-- {
+		wrapper := wrappers[i]
+		if len(wrapper) == 0 {
+			continue
+		}
+		args = append(append(append([]string{}, wrapper[1:]...), name), args...)
+		name = wrapper[0]
+	}
+	return name, args
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestValidateResourceConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *Config
+		wantErr bool
+	}{
+		{"unset", &Config{}, false},
+		{"cpu list", &Config{CPUSet: "0-3,6"}, false},
+		{"slice", &Config{CgroupSlice: "release.slice"}, false},
+		{"invalid cpu list", &Config{CPUSet: "0-3;reboot"}, true},
+		{"slice without suffix", &Config{CgroupSlice: "release"}, true},
+		{"slice path", &Config{CgroupSlice: "../release.slice"}, true},
+		{"ulimits", &Config{Ulimits: []string{"nofile=65536:65536", "nproc=-1"}}, false},
+		{"unknown ulimit", &Config{Ulimits: []string{"files=1024"}}, true},
+		{"ulimit flag", &Config{Ulimits: []string{"nofile=1024 --privileged"}}, true},
+		{"negative nofile limit", &Config{NofileLimit: -1}, true},
+		{"parallelism", &Config{MaxParallelism: 4, BuilderNodes: []BuilderNode{{Endpoint: "tcp://a:1234"}}}, false},
+		{"parallelism without nodes", &Config{MaxParallelism: 4}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateResourceConfig(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateResourceConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResourceWrapper(t *testing.T) {
+	t.Run("taskset", func(t *testing.T) {
+		stubLookPath(t, "taskset")
+		got := strings.Join(resourceWrapper(&Config{CPUSet: "0-3"}), " ")
+		if got != "taskset -c 0-3" {
+			t.Errorf("resourceWrapper() = %q", got)
+		}
+	})
+
+	t.Run("systemd-run", func(t *testing.T) {
+		stubLookPath(t, "systemd-run", "taskset")
+		got := strings.Join(resourceWrapper(&Config{CPUSet: "0-3", CgroupSlice: "release.slice"}), " ")
+		want := "systemd-run --scope --quiet --collect --slice=release.slice --property=AllowedCPUs=0-3 --"
+		if got != want {
+			t.Errorf("resourceWrapper() = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("tool missing", func(t *testing.T) {
+		stubLookPath(t)
+		cfg := &Config{CPUSet: "0-3"}
+		if wrapper := resourceWrapper(cfg); wrapper != nil {
+			t.Errorf("expected no wrapper, got %v", wrapper)
+		}
+		if w := resourceWarning(cfg); !strings.Contains(w, "taskset") {
+			t.Errorf("expected taskset warning, got %q", w)
+		}
+	})
+}
+
+func TestFileLimitWrapper(t *testing.T) {
+	t.Run("prlimit", func(t *testing.T) {
+		stubLookPath(t, "prlimit")
+		cfg := &Config{NofileLimit: 65536}
+		if got := strings.Join(fileLimitWrapper(cfg), " "); got != "prlimit --nofile=65536: --" {
+			t.Errorf("fileLimitWrapper() = %q", got)
+		}
+		if w := fileLimitWarning(cfg); w != "" {
+			t.Errorf("unexpected warning: %q", w)
+		}
+	})
+
+	t.Run("unset", func(t *testing.T) {
+		stubLookPath(t, "prlimit")
+		if wrapper := fileLimitWrapper(&Config{}); wrapper != nil {
+			t.Errorf("expected no wrapper, got %v", wrapper)
+		}
+	})
+
+	t.Run("prlimit missing", func(t *testing.T) {
+		stubLookPath(t)
+		cfg := &Config{NofileLimit: 65536}
+		if wrapper := fileLimitWrapper(cfg); wrapper != nil {
+			t.Errorf("expected no wrapper, got %v", wrapper)
+		}
+		if w := fileLimitWarning(cfg); !strings.Contains(w, "prlimit") {
+			t.Errorf("expected prlimit warning, got %q", w)
+		}
+	})
+}
+
+func TestExecuteRaisesLimits(t *testing.T) {
+	stubLookPath(t, "prlimit")
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":        "myapp",
+			"ulimits":      []any{"nofile=65536:65536"},
+			"nofile_limit": 65536,
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+
+	build, push := mock.RunCalls[0], mock.RunCalls[1]
+	if build.Name != "prlimit" || !containsArg(build.Args, "--ulimit", "nofile=65536:65536") {
+		t.Errorf("expected build under prlimit with --ulimit, got %s %v", build.Name, build.Args)
+	}
+	if push.Name != "prlimit" || !containsArg(push.Args, "docker", "push") {
+		t.Errorf("expected push under prlimit, got %s %v", push.Name, push.Args)
+	}
+}
+
+func TestWrapCommand(t *testing.T) {
+	name, args := wrapCommand("docker", []string{"push", "myapp"}, []string{"taskset", "-c", "1"}, nil, []string{"ionice", "-c3"})
+	got := name + " " + strings.Join(args, " ")
+	if got != "taskset -c 1 ionice -c3 docker push myapp" {
+		t.Errorf("wrapCommand() = %q", got)
+	}
+}
+
+func TestExecutePinsBuildAndPush(t *testing.T) {
+	stubLookPath(t, "systemd-run")
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":        "myapp",
+			"cpuset":       "0-1",
+			"cgroup_slice": "release.slice",
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+
+	build, push := mock.RunCalls[0], mock.RunCalls[1]
+	if build.Name != "systemd-run" || !containsArg(build.Args, "--cgroup-parent", "release.slice") {
+		t.Errorf("expected build in release.slice, got %s %v", build.Name, build.Args)
+	}
+	if push.Name != "systemd-run" || !containsArg(push.Args, "docker", "push") {
+		t.Errorf("expected push in release.slice, got %s %v", push.Name, push.Args)
+	}
+}
+
+func TestPriorityWrapper(t *testing.T) {
+	t.Run("low", func(t *testing.T) {
+		stubLookPath(t, "nice", "ionice")
+		cfg := &Config{Priority: priorityLow}
+		got := strings.Join(priorityWrapper(cfg), " ")
+		if got != "nice -n 10 ionice -c 2 -n 7" {
+			t.Errorf("priorityWrapper() = %q", got)
+		}
+		if w := priorityWarning(cfg); !strings.Contains(w, "only lowers the docker CLI processes") || !strings.Contains(w, "unless cgroup_slice") {
+			t.Errorf("expected a warning that the daemon keeps its priority, got %q", w)
+		}
+		cfg.CgroupSlice = "release.slice"
+		if w := priorityWarning(cfg); !strings.Contains(w, "RUN steps run in release.slice") {
+			t.Errorf("expected the warning to name cgroup_slice, got %q", w)
+		}
+	})
+
+	t.Run("normal", func(t *testing.T) {
+		stubLookPath(t, "nice", "ionice")
+		if wrapper := priorityWrapper(&Config{Priority: priorityNormal}); wrapper != nil {
+			t.Errorf("expected no wrapper, got %v", wrapper)
+		}
+		if w := priorityWarning(&Config{Priority: priorityNormal}); w != "" {
+			t.Errorf("unexpected warning: %q", w)
+		}
+	})
+
+	t.Run("ionice missing", func(t *testing.T) {
+		stubLookPath(t, "nice")
+		cfg := &Config{Priority: priorityLow}
+		if got := strings.Join(priorityWrapper(cfg), " "); got != "nice -n 10" {
+			t.Errorf("priorityWrapper() = %q", got)
+		}
+		if w := priorityWarning(cfg); !strings.Contains(w, "ionice not found in PATH") {
+			t.Errorf("expected ionice warning, got %q", w)
+		}
+	})
+}
+
+func TestExecuteLowPriority(t *testing.T) {
+	stubLookPath(t, "nice", "ionice")
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"image": "myapp", "priority": "low"},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+
+	for _, call := range mock.RunCalls[:2] {
+		if call.Name != "nice" || !containsArg(call.Args, "ionice", "-c") {
+			t.Errorf("expected command under nice and ionice, got %s %v", call.Name, call.Args)
+		}
+	}
+}
+
+func TestValidateInvalidPriority(t *testing.T) {
+	p := &DockerPlugin{}
+	resp, err := p.Validate(context.Background(), map[string]any{"image": "myapp", "priority": "high"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Valid {
+		t.Error("expected invalid priority to fail validation")
+	}
+}
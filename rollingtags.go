@@ -0,0 +1,256 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Scopes of rolling tags: the part of the version a rolling tag follows.
+const (
+	rollingScopeMajor = "major"
+	rollingScopeMinor = "minor"
+)
+
+// semverPattern matches released versions, with an optional v prefix.
+var semverPattern = regexp.MustCompile(`^v?(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)(?:-([0-9A-Za-z.-]+))?(?:\+[0-9A-Za-z.-]+)?$`)
+
+// nextLinkPattern matches the next page in the Link header of a tag list.
+var nextLinkPattern = regexp.MustCompile(`<([^>]+)>\s*;\s*rel="?next"?`)
+
+// semver is a parsed semantic version.
+type semver struct {
+	major, minor, patch int
+	prerelease          string
+}
+
+// parseSemver parses a version such as v1.2.3 or 1.4.0-rc.1.
+func parseSemver(version string) (semver, bool) {
+	m := semverPattern.FindStringSubmatch(version)
+	if m == nil {
+		return semver{}, false
+	}
+	v := semver{prerelease: m[4]}
+	v.major, _ = strconv.Atoi(m[1])
+	v.minor, _ = strconv.Atoi(m[2])
+	v.patch, _ = strconv.Atoi(m[3])
+	return v, true
+}
+
+// compare orders versions by semver precedence.
+func (v semver) compare(o semver) int {
+	if c := cmp.Compare(v.major, o.major); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(v.minor, o.minor); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(v.patch, o.patch); c != 0 {
+		return c
+	}
+	switch {
+	case v.prerelease == o.prerelease:
+		return 0
+	case v.prerelease == "":
+		return 1
+	case o.prerelease == "":
+		return -1
+	}
+	a, b := strings.Split(v.prerelease, "."), strings.Split(o.prerelease, ".")
+	for i := 0; i < len(a) && i < len(b); i++ {
+		x, xErr := strconv.Atoi(a[i])
+		y, yErr := strconv.Atoi(b[i])
+		switch {
+		case xErr == nil && yErr == nil:
+			if c := cmp.Compare(x, y); c != 0 {
+				return c
+			}
+		case xErr == nil:
+			return -1
+		case yErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(a[i], b[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return cmp.Compare(len(a), len(b))
+}
+
+// String returns the version without a v prefix.
+func (v semver) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.major, v.minor, v.patch)
+	if v.prerelease != "" {
+		s += "-" + v.prerelease
+	}
+	return s
+}
+
+// rollingScope returns the scope of a rolling tag template: major for
+// {{major}} or v{{major}}, minor for {{major}}.{{minor}}. Other templates
+// are not rolling tags and return an empty scope.
+func rollingScope(template string) string {
+	if containsAny(template, versionPlaceholders) || !containsAny(template, []string{"{{major}}", ".Major"}) {
+		return ""
+	}
+	if containsAny(template, []string{"{{minor}}", ".Minor"}) {
+		return rollingScopeMinor
+	}
+	return rollingScopeMajor
+}
+
+// newerRelease returns the highest stable release among tags that is in
+// the same scope as version and newer than it.
+func newerRelease(tags []string, version semver, scope string) (semver, bool) {
+	var newest semver
+	found := false
+	for _, tag := range tags {
+		v, ok := parseSemver(tag)
+		if !ok || v.prerelease != "" || v.major != version.major || scope == rollingScopeMinor && v.minor != version.minor {
+			continue
+		}
+		if v.compare(version) > 0 && (!found || v.compare(newest) > 0) {
+			newest, found = v, true
+		}
+	}
+	return newest, found
+}
+
+// checkRollingTags lists the released versions in the registry and returns
+// the rolling tags, such as 1 or 1.2, that the release must not move
+// because a newer version in their major or minor was already released,
+// e.g. for a 1.2.x hotfix released after 1.3.0. The aliases of a held tag
+// are held with it. It records a rolling_tags stage.
+func (p *DockerPlugin) checkRollingTags(ctx context.Context, cfg *Config, releaseVersion string, outputs *Outputs) ([]string, []string, error) {
+	templates := tagTemplates(cfg)
+	version, ok := parseSemver(releaseVersion)
+	if !ok {
+		return nil, nil, nil
+	}
+
+	var held, warnings []string
+	var tags []string
+	listed := false
+	started := time.Now()
+	for _, template := range templates {
+		scope := rollingScope(template)
+		if scope == "" {
+			continue
+		}
+		resolved, err := resolveTags([]string{template}, releaseVersion, cfg.tagContext)
+		if err != nil || len(resolved) == 0 {
+			continue
+		}
+		if !listed {
+			if tags, err = p.listTags(ctx, cfg); err != nil {
+				outputs.stage("rolling_tags", started, err)
+				return nil, nil, err
+			}
+			listed = true
+		}
+		newer, ok := newerRelease(tags, version, scope)
+		if !ok {
+			continue
+		}
+		for _, group := range cfg.tagAliasGroups {
+			if group[0] == resolved[0] {
+				held = append(held, group[1:]...)
+			}
+		}
+		held = append(held, resolved[0])
+		warnings = append(warnings, fmt.Sprintf("rolling tag %s was not moved: %s is newer than %s", resolved[0], newer, version))
+	}
+	if listed {
+		outputs.stage("rolling_tags", started, nil)
+	}
+	return held, warnings, nil
+}
+
+// holdTags removes the held rolling tags and their alias groups from the
+// tags of the release, and records them so they are not treated as moved.
+func holdTags(cfg *Config, tags, held []string) []string {
+	cfg.heldTags = append(cfg.heldTags, held...)
+	cfg.tagAliasGroups = slices.DeleteFunc(cfg.tagAliasGroups, func(group []string) bool {
+		return slices.Contains(held, group[0])
+	})
+	return slices.DeleteFunc(slices.Clone(tags), func(tag string) bool {
+		return slices.Contains(held, tag)
+	})
+}
+
+// listTags returns the tags of the repository of cfg, following the pages
+// of the registry API. A repository that does not exist yet has no tags.
+// The list is cached like other registry lookups.
+func (p *DockerPlugin) listTags(ctx context.Context, cfg *Config) ([]string, error) {
+	client := p.newRegistryClient(cfg)
+	repository := registryRepository(cfg)
+	out, err := p.lookups.lookup(ctx, lookupKey("tags/list", client.baseURL, repository), func() ([]byte, error) {
+		username, password, _, err := p.registryCredentials(ctx, cfg, cfg.Username, loginSecret(cfg))
+		if err != nil {
+			return nil, err
+		}
+		client.username, client.password = username, password
+		tags, err := fetchTags(ctx, &ociPusher{client: client, repository: repository})
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(tags)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags of %s: %w", imageRepository(cfg), err)
+	}
+	var tags []string
+	if err := json.Unmarshal(out, &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// fetchTags lists every tag of a repository over the registry API.
+func fetchTags(ctx context.Context, o *ociPusher) ([]string, error) {
+	if err := o.authorize(ctx); err != nil {
+		return nil, err
+	}
+	tags := []string{}
+	next := "/v2/" + o.repository + "/tags/list?n=1000"
+	for next != "" {
+		target := next
+		if strings.HasPrefix(target, "/") {
+			target = o.client.baseURL + target
+		}
+		resp, err := o.do(ctx, http.MethodGet, target, nil, 0, "")
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusNotFound {
+			resp.Body.Close()
+			return tags, nil
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, expect(resp, "list tags", http.StatusOK)
+		}
+		var page struct {
+			Tags []string `json:"tags"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("list tags: invalid response: %w", err)
+		}
+		tags = append(tags, page.Tags...)
+
+		next = ""
+		if m := nextLinkPattern.FindStringSubmatch(resp.Header.Get("Link")); m != nil && len(page.Tags) > 0 {
+			next = m[1]
+		}
+	}
+	return tags, nil
+}
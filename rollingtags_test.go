@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestSemverCompare(t *testing.T) {
+	ordered := []string{"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta", "1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "1.0.1", "1.2.0", "v1.10.0", "2.0.0"}
+	for i := 1; i < len(ordered); i++ {
+		a, okA := parseSemver(ordered[i-1])
+		b, okB := parseSemver(ordered[i])
+		if !okA || !okB {
+			t.Fatalf("failed to parse %s or %s", ordered[i-1], ordered[i])
+		}
+		if a.compare(b) >= 0 || b.compare(a) <= 0 {
+			t.Errorf("expected %s < %s", ordered[i-1], ordered[i])
+		}
+	}
+	for _, tag := range []string{"latest", "1", "1.2", "01.2.3", "sha-abc1234"} {
+		if _, ok := parseSemver(tag); ok {
+			t.Errorf("expected %q not to be a version", tag)
+		}
+	}
+}
+
+func TestRollingScope(t *testing.T) {
+	tests := map[string]string{
+		"{{major}}":                     "major",
+		"v{{major}}":                    "major",
+		"{{major}}.{{minor}}":           "minor",
+		"{{.Major}}-{{.Minor}}":         "minor",
+		"{{version}}":                   "",
+		"{{major}}.{{minor}}.{{patch}}": "",
+		"latest":                        "",
+	}
+	for template, want := range tests {
+		if got := rollingScope(template); got != want {
+			t.Errorf("rollingScope(%q) = %q, want %q", template, got, want)
+		}
+	}
+}
+
+func TestNewerRelease(t *testing.T) {
+	tags := []string{"latest", "1", "1.2.0", "1.2.5", "1.3.0", "v1.4.0-rc.1", "2.0.0"}
+	hotfix, _ := parseSemver("1.2.6")
+	if newer, ok := newerRelease(tags, hotfix, rollingScopeMajor); !ok || newer.String() != "1.3.0" {
+		t.Errorf("expected 1.3.0 to hold back the major tag, got %v, %v", newer, ok)
+	}
+	if _, ok := newerRelease(tags, hotfix, rollingScopeMinor); ok {
+		t.Errorf("expected the hotfix to be the newest of its minor")
+	}
+	latest, _ := parseSemver("1.3.1")
+	if _, ok := newerRelease(tags, latest, rollingScopeMajor); ok {
+		t.Errorf("expected prereleases and other majors to be ignored")
+	}
+}
+
+func TestExecuteHoldsRollingMajorTag(t *testing.T) {
+	p, host := newTestRegistry(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case r.URL.Path == "/v2/myorg/myapp/tags/list" && r.URL.Query().Get("last") == "":
+			w.Header().Set("Link", `</v2/myorg/myapp/tags/list?last=1.2.5&n=1000>; rel="next"`)
+			_, _ = w.Write([]byte(`{"name": "myorg/myapp", "tags": ["1", "1.2", "1.2.0", "1.2.5"]}`))
+		case r.URL.Path == "/v2/myorg/myapp/tags/list":
+			_, _ = w.Write([]byte(`{"name": "myorg/myapp", "tags": ["1.3", "1.3.0"]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	mock := &MockCommandExecutor{}
+	p.executor = mock
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"registry":          host,
+			"image":             "myorg/myapp",
+			"tags":              []any{"{{version}}", "{{major}}.{{minor}}", "{{major}}"},
+			"tag_aliases":       map[string]any{"{{major}}": "v{{major}}"},
+			"rolling_tag_check": true,
+		},
+		Context: plugin.ReleaseContext{Version: "1.2.6"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+
+	var pushed []string
+	for _, call := range mock.RunCalls {
+		if len(call.Args) == 2 && call.Args[0] == "push" {
+			pushed = append(pushed, strings.TrimPrefix(call.Args[1], host+"/"))
+		}
+	}
+	if !slices.Equal(pushed, []string{"myorg/myapp:1.2.6", "myorg/myapp:1.2"}) {
+		t.Errorf("expected the major tag and its alias to be held back, got %v", pushed)
+	}
+	warnings, _ := resp.Outputs["warnings"].([]string)
+	if !slices.ContainsFunc(warnings, func(w string) bool {
+		return strings.Contains(w, "rolling tag 1 was not moved: 1.3.0 is newer than 1.2.6")
+	}) {
+		t.Errorf("expected a warning about the held tag, got %v", warnings)
+	}
+}
+
+func TestValidateRollingTagCheckWithoutRollingTags(t *testing.T) {
+	p := &DockerPlugin{}
+	resp, err := p.Validate(context.Background(), map[string]any{"image": "myorg/myapp", "rolling_tag_check": true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.ContainsFunc(resp.Errors, func(e plugin.ValidationError) bool { return e.Field == "rolling_tag_check" && e.Code == warningCode }) {
+		t.Errorf("expected a rolling_tag_check warning, got %+v", resp.Errors)
+	}
+}
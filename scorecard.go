@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// defaultScorecardSizeThreshold is the image size growth, in percent, that
+// counts as a regression.
+const defaultScorecardSizeThreshold = 10
+
+// maxScorecardEntries bounds the releases kept in the scorecard file.
+const maxScorecardEntries = 50
+
+// ScorecardEntry records the metrics of one release.
+type ScorecardEntry struct {
+	Version string `json:"version"`
+	Digest  string `json:"digest,omitempty"`
+	Size    int64  `json:"size"`
+	Layers  int    `json:"layers"`
+	// Vulnerabilities counts the findings of scorecard_scan_report, if set.
+	Vulnerabilities *VulnerabilityCounts `json:"vulnerabilities,omitempty"`
+	RecordedAt      time.Time            `json:"recorded_at"`
+}
+
+// VulnerabilityCounts counts the critical and high findings of a scan.
+type VulnerabilityCounts struct {
+	Critical int `json:"critical"`
+	High     int `json:"high"`
+}
+
+// scorecardFile is the content of scorecard_file, oldest release first.
+type scorecardFile struct {
+	Releases []ScorecardEntry `json:"releases"`
+}
+
+// ScorecardResult compares the release with the previous one.
+type ScorecardResult struct {
+	ScorecardEntry
+	PreviousVersion string `json:"previous_version,omitempty"`
+	SizeDelta       int64  `json:"size_delta"`
+	LayersDelta     int    `json:"layers_delta"`
+	// CriticalDelta and HighDelta are set when both releases were scanned.
+	CriticalDelta *int `json:"critical_delta,omitempty"`
+	HighDelta     *int `json:"high_delta,omitempty"`
+	// Regressions describes the metrics that got worse beyond the
+	// threshold.
+	Regressions []string `json:"regressions,omitempty"`
+}
+
+// registryManifest is the subset of a manifest or index needed to size an
+// image in the registry.
+type registryManifest struct {
+	Manifests []struct {
+		Digest   string `json:"digest"`
+		Platform *struct {
+			OS string `json:"os"`
+		} `json:"platform"`
+	} `json:"manifests"`
+	Layers []struct {
+		Size int64 `json:"size"`
+	} `json:"layers"`
+}
+
+// validateScorecard checks scorecard_file, the scan report and the size
+// threshold.
+func validateScorecard(cfg *Config) error {
+	if cfg.ScorecardFile == "" {
+		if cfg.ScorecardScanReport != "" {
+			return fmt.Errorf("scorecard_scan_report requires scorecard_file")
+		}
+		return nil
+	}
+	if err := validatePath(cfg.ScorecardFile); err != nil {
+		return fmt.Errorf("invalid scorecard_file: %v", err)
+	}
+	if cfg.ScorecardScanReport != "" {
+		if err := validatePath(cfg.ScorecardScanReport); err != nil {
+			return fmt.Errorf("invalid scorecard_scan_report: %v", err)
+		}
+	}
+	if cfg.ScorecardSizeThreshold < 0 {
+		return fmt.Errorf("scorecard_size_threshold must not be negative")
+	}
+	return nil
+}
+
+// measureImage returns the compressed size and layer count of the pushed
+// image. An index counts the layers of all its platform images; attestation
+// manifests are skipped.
+func (p *DockerPlugin) measureImage(ctx context.Context, repository, digest string) (int64, int, error) {
+	manifest, err := p.fetchManifest(ctx, repository+"@"+digest)
+	if err != nil {
+		return 0, 0, err
+	}
+	manifests := []registryManifest{manifest}
+	if len(manifest.Manifests) > 0 {
+		manifests = manifests[:0]
+		for _, child := range manifest.Manifests {
+			if child.Platform != nil && child.Platform.OS == "unknown" {
+				continue
+			}
+			m, err := p.fetchManifest(ctx, repository+"@"+child.Digest)
+			if err != nil {
+				return 0, 0, err
+			}
+			manifests = append(manifests, m)
+		}
+	}
+
+	var size int64
+	var layers int
+	for _, m := range manifests {
+		for _, layer := range m.Layers {
+			size += layer.Size
+		}
+		layers += len(m.Layers)
+	}
+	return size, layers, nil
+}
+
+// fetchManifest reads the raw manifest of ref from the registry.
+func (p *DockerPlugin) fetchManifest(ctx context.Context, ref string) (registryManifest, error) {
+	var manifest registryManifest
+	out, err := p.getExecutor().Output(ctx, "docker", []string{"buildx", "imagetools", "inspect", "--raw", ref})
+	if err != nil {
+		return manifest, fmt.Errorf("failed to inspect %s: %w", ref, err)
+	}
+	if err := json.Unmarshal(out, &manifest); err != nil {
+		return manifest, fmt.Errorf("failed to parse manifest of %s: %w", ref, err)
+	}
+	return manifest, nil
+}
+
+// scanReport is the subset of a Trivy or Grype JSON report needed to count
+// its findings by severity.
+type scanReport struct {
+	// Results are the scanned targets of a Trivy report.
+	Results *[]struct {
+		Vulnerabilities []struct {
+			Severity string `json:"Severity"`
+		} `json:"Vulnerabilities"`
+	} `json:"Results"`
+	// Matches are the findings of a Grype report.
+	Matches *[]struct {
+		Vulnerability struct {
+			Severity string `json:"severity"`
+		} `json:"vulnerability"`
+	} `json:"matches"`
+}
+
+// readScanReport counts the critical and high findings of the Trivy or
+// Grype JSON report at path. The plugin does not scan images itself; the
+// report comes from a scan of the pushed image earlier in the pipeline.
+func readScanReport(path string) (*VulnerabilityCounts, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scan report: %w", err)
+	}
+	var report scanReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse scan report %s: %w", path, err)
+	}
+	var severities []string
+	switch {
+	case report.Results != nil:
+		for _, result := range *report.Results {
+			for _, vulnerability := range result.Vulnerabilities {
+				severities = append(severities, vulnerability.Severity)
+			}
+		}
+	case report.Matches != nil:
+		for _, match := range *report.Matches {
+			severities = append(severities, match.Vulnerability.Severity)
+		}
+	default:
+		return nil, fmt.Errorf("scan report %s is neither a Trivy nor a Grype JSON report", path)
+	}
+	counts := &VulnerabilityCounts{}
+	for _, severity := range severities {
+		switch strings.ToUpper(severity) {
+		case "CRITICAL":
+			counts.Critical++
+		case "HIGH":
+			counts.High++
+		}
+	}
+	return counts, nil
+}
+
+// readScorecard returns the recorded releases; a missing file has none.
+func readScorecard(path string) (*scorecardFile, error) {
+	scorecard := &scorecardFile{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return scorecard, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, scorecard); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return scorecard, nil
+}
+
+// updateScorecard measures the pushed image, compares it with the previous
+// release in scorecard_file and records it there. Re-running a release
+// replaces its entry.
+func (p *DockerPlugin) updateScorecard(ctx context.Context, cfg *Config, repository, version, digest string) (*ScorecardResult, error) {
+	size, layers, err := p.measureImage(ctx, repository, digest)
+	if err != nil {
+		return nil, err
+	}
+	var vulnerabilities *VulnerabilityCounts
+	if cfg.ScorecardScanReport != "" {
+		if vulnerabilities, err = readScanReport(cfg.ScorecardScanReport); err != nil {
+			return nil, err
+		}
+	}
+	defer lockStateFile(cfg.ScorecardFile)()
+	scorecard, err := readScorecard(cfg.ScorecardFile)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := ScorecardEntry{Version: version, Digest: digest, Size: size, Layers: layers, Vulnerabilities: vulnerabilities, RecordedAt: time.Now().UTC()}
+	releases := make([]ScorecardEntry, 0, len(scorecard.Releases)+1)
+	for _, r := range scorecard.Releases {
+		if r.Version != version {
+			releases = append(releases, r)
+		}
+	}
+
+	result := &ScorecardResult{ScorecardEntry: entry}
+	if len(releases) > 0 {
+		previous := releases[len(releases)-1]
+		result.PreviousVersion = previous.Version
+		result.SizeDelta = size - previous.Size
+		result.LayersDelta = layers - previous.Layers
+		if previous.Size > 0 {
+			growth := float64(result.SizeDelta) * 100 / float64(previous.Size)
+			if growth > cfg.ScorecardSizeThreshold {
+				result.Regressions = append(result.Regressions, fmt.Sprintf("image size grew %.1f%% since %s (%s to %s)",
+					growth, previous.Version, formatBytes(previous.Size), formatBytes(size)))
+			}
+		}
+		// Any new critical or high finding is a regression.
+		if vulnerabilities != nil && previous.Vulnerabilities != nil {
+			critical := vulnerabilities.Critical - previous.Vulnerabilities.Critical
+			high := vulnerabilities.High - previous.Vulnerabilities.High
+			result.CriticalDelta, result.HighDelta = &critical, &high
+			if critical > 0 {
+				result.Regressions = append(result.Regressions, fmt.Sprintf("critical vulnerabilities rose from %d to %d since %s",
+					previous.Vulnerabilities.Critical, vulnerabilities.Critical, previous.Version))
+			}
+			if high > 0 {
+				result.Regressions = append(result.Regressions, fmt.Sprintf("high vulnerabilities rose from %d to %d since %s",
+					previous.Vulnerabilities.High, vulnerabilities.High, previous.Version))
+			}
+		}
+	}
+
+	releases = append(releases, entry)
+	if len(releases) > maxScorecardEntries {
+		releases = releases[len(releases)-maxScorecardEntries:]
+	}
+	data, err := json.MarshalIndent(scorecardFile{Releases: releases}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(cfg.ScorecardFile, append(data, '\n'), 0o644); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// scorecardRegistry returns an OutputFunc resolving references to testDigest
+// and serving an index of one image of layers plus an attestation manifest.
+func scorecardRegistry(layers ...int64) func(context.Context, string, []string) ([]byte, error) {
+	return func(_ context.Context, _ string, args []string) ([]byte, error) {
+		switch {
+		case containsArg(args, "--format", "{{.Manifest.Digest}}"):
+			return []byte(testDigest + "\n"), nil
+		case containsFlag(args, "--raw") && strings.HasSuffix(args[len(args)-1], "@"+testDigest):
+			return []byte(`{"manifests": [
+				{"digest": "sha256:amd64", "platform": {"os": "linux"}},
+				{"digest": "sha256:attestation", "platform": {"os": "unknown"}}
+			]}`), nil
+		case containsFlag(args, "--raw") && strings.HasSuffix(args[len(args)-1], "@sha256:amd64"):
+			var manifest registryManifest
+			for _, size := range layers {
+				manifest.Layers = append(manifest.Layers, struct {
+					Size int64 `json:"size"`
+				}{size})
+			}
+			return json.Marshal(manifest)
+		}
+		return nil, nil
+	}
+}
+
+func TestValidateScorecard(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{"unset", Config{}, false},
+		{"file", Config{ScorecardFile: "scorecard.json", ScorecardSizeThreshold: 10}, false},
+		{"outside working directory", Config{ScorecardFile: "../scorecard.json"}, true},
+		{"negative threshold", Config{ScorecardFile: "scorecard.json", ScorecardSizeThreshold: -1}, true},
+		{"scan report", Config{ScorecardFile: "scorecard.json", ScorecardScanReport: "trivy.json"}, false},
+		{"scan report without file", Config{ScorecardScanReport: "trivy.json"}, true},
+		{"scan report outside working directory", Config{ScorecardFile: "scorecard.json", ScorecardScanReport: "/tmp/trivy.json"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateScorecard(&tt.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("validateScorecard() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUpdateScorecard(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &Config{ScorecardFile: filepath.Join(dir, "scorecard.json"), ScorecardSizeThreshold: 10}
+	ctx := context.Background()
+
+	p := &DockerPlugin{executor: &MockCommandExecutor{OutputFunc: scorecardRegistry(1000, 500)}}
+	first, err := p.updateScorecard(ctx, cfg, "myapp", "1.0.0", testDigest)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first.Size != 1500 || first.Layers != 2 || first.PreviousVersion != "" {
+		t.Errorf("unexpected first scorecard: %+v", first)
+	}
+
+	p = &DockerPlugin{executor: &MockCommandExecutor{OutputFunc: scorecardRegistry(1000, 500, 200)}}
+	second, err := p.updateScorecard(ctx, cfg, "myapp", "1.1.0", testDigest)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if second.PreviousVersion != "1.0.0" || second.SizeDelta != 200 || second.LayersDelta != 1 {
+		t.Errorf("unexpected deltas: %+v", second)
+	}
+	if len(second.Regressions) != 1 || !strings.Contains(second.Regressions[0], "image size grew 13.3% since 1.0.0") {
+		t.Errorf("expected size regression, got %v", second.Regressions)
+	}
+
+	// Re-running 1.1.0 compares with 1.0.0 again and replaces its entry.
+	p = &DockerPlugin{executor: &MockCommandExecutor{OutputFunc: scorecardRegistry(1000, 550)}}
+	rerun, err := p.updateScorecard(ctx, cfg, "myapp", "1.1.0", testDigest)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rerun.PreviousVersion != "1.0.0" || len(rerun.Regressions) != 0 {
+		t.Errorf("unexpected re-run scorecard: %+v", rerun)
+	}
+
+	scorecard, err := readScorecard(cfg.ScorecardFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scorecard.Releases) != 2 || scorecard.Releases[1].Version != "1.1.0" || scorecard.Releases[1].Size != 1550 {
+		t.Errorf("unexpected scorecard file: %+v", scorecard.Releases)
+	}
+}
+
+func TestReadScanReport(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"trivy.json": `{"Results": [
+			{"Vulnerabilities": [{"Severity": "CRITICAL"}, {"Severity": "HIGH"}, {"Severity": "LOW"}]},
+			{"Vulnerabilities": [{"Severity": "HIGH"}]},
+			{}
+		]}`,
+		"grype.json": `{"matches": [{"vulnerability": {"severity": "Critical"}}, {"vulnerability": {"severity": "Medium"}}]}`,
+		"clean.json": `{"Results": []}`,
+		"other.json": `{"findings": []}`,
+	})
+
+	tests := []struct {
+		file    string
+		want    VulnerabilityCounts
+		wantErr bool
+	}{
+		{"trivy.json", VulnerabilityCounts{Critical: 1, High: 2}, false},
+		{"grype.json", VulnerabilityCounts{Critical: 1}, false},
+		{"clean.json", VulnerabilityCounts{}, false},
+		{"other.json", VulnerabilityCounts{}, true},
+		{"missing.json", VulnerabilityCounts{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			counts, err := readScanReport(filepath.Join(dir, tt.file))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readScanReport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && *counts != tt.want {
+				t.Errorf("readScanReport() = %+v, want %+v", *counts, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateScorecardRecordsVulnerabilities(t *testing.T) {
+	dir := t.TempDir()
+	report := filepath.Join(dir, "trivy.json")
+	cfg := &Config{ScorecardFile: filepath.Join(dir, "scorecard.json"), ScorecardSizeThreshold: 10, ScorecardScanReport: report}
+	ctx := context.Background()
+	p := &DockerPlugin{executor: &MockCommandExecutor{OutputFunc: scorecardRegistry(1000)}}
+
+	writeFiles(t, dir, map[string]string{"trivy.json": `{"Results": [{"Vulnerabilities": [{"Severity": "HIGH"}, {"Severity": "HIGH"}]}]}`})
+	first, err := p.updateScorecard(ctx, cfg, "myapp", "1.0.0", testDigest)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first.Vulnerabilities == nil || first.Vulnerabilities.High != 2 || first.HighDelta != nil {
+		t.Errorf("unexpected first scorecard: %+v", first)
+	}
+
+	writeFiles(t, dir, map[string]string{"trivy.json": `{"Results": [{"Vulnerabilities": [{"Severity": "CRITICAL"}, {"Severity": "HIGH"}]}]}`})
+	second, err := p.updateScorecard(ctx, cfg, "myapp", "1.1.0", testDigest)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if second.CriticalDelta == nil || *second.CriticalDelta != 1 || *second.HighDelta != -1 {
+		t.Errorf("unexpected deltas: %+v", second)
+	}
+	if len(second.Regressions) != 1 || second.Regressions[0] != "critical vulnerabilities rose from 0 to 1 since 1.0.0" {
+		t.Errorf("expected a critical regression only, got %v", second.Regressions)
+	}
+}
+
+func TestExecuteReportsScorecardRegressions(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+	writeFiles(t, dir, map[string]string{
+		"scorecard.json": `{"releases": [{"version": "0.9.0", "size": 1000, "layers": 1}]}`,
+	})
+
+	p := &DockerPlugin{executor: &MockCommandExecutor{OutputFunc: scorecardRegistry(2000)}}
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"image": "myapp", "builder": "release", "scorecard_file": "scorecard.json"},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+	if !strings.Contains(resp.Message, "regressions: image size grew 100.0% since 0.9.0") {
+		t.Errorf("expected regression in the summary, got %q", resp.Message)
+	}
+	scorecard, _ := resp.Outputs["scorecard"].(*ScorecardResult)
+	if scorecard == nil || scorecard.Size != 2000 || scorecard.SizeDelta != 1000 {
+		t.Errorf("unexpected scorecard output: %+v", resp.Outputs["scorecard"])
+	}
+	if _, err := os.Stat(filepath.Join(dir, "scorecard.json")); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// sensitiveBuildArgPattern matches build arg names that usually carry
+// credentials. Build args are recorded in the image history, so such values
+// belong in secrets instead.
+var sensitiveBuildArgPattern = regexp.MustCompile(`(?i)(TOKEN|PASSWORD|PASSWD|SECRET|KEY)`)
+
+// sensitiveBuildArgs returns the configured build args whose names look like
+// credentials, sorted.
+func sensitiveBuildArgs(cfg *Config) []string {
+	var names []string
+	for _, key := range sortedKeys(cfg.BuildArgs) {
+		if sensitiveBuildArgPattern.MatchString(key) {
+			names = append(names, key)
+		}
+	}
+	for _, key := range sortedKeys(cfg.BuildArgSources) {
+		if _, inline := cfg.BuildArgs[key]; !inline && sensitiveBuildArgPattern.MatchString(key) {
+			names = append(names, key)
+		}
+	}
+	return names
+}
+
+// checkSensitiveBuildArgs returns a warning for build args that look like
+// credentials, or an error when forbid_sensitive_build_args is set.
+func checkSensitiveBuildArgs(cfg *Config) (string, error) {
+	names := sensitiveBuildArgs(cfg)
+	if len(names) == 0 {
+		return "", nil
+	}
+	msg := fmt.Sprintf("build args %s look sensitive and persist in the image history; pass them with secrets instead", strings.Join(names, ", "))
+	if cfg.ForbidSensitiveBuildArgs {
+		return "", fmt.Errorf("%s", msg)
+	}
+	return msg, nil
+}
+
+// validateSecrets checks that every secret names exactly one of an environment
+// variable or a file inside the working directory.
+func validateSecrets(secrets map[string]BuildArgSource) error {
+	for _, id := range sortedKeys(secrets) {
+		secret := secrets[id]
+		if !buildArgKeyPattern.MatchString(id) {
+			return fmt.Errorf("invalid secret id %q", id)
+		}
+		if (secret.FromEnv == "") == (secret.FromFile == "") {
+			return fmt.Errorf("secret %s must set exactly one of from_env or from_file", id)
+		}
+		if secret.FromEnv != "" && !buildArgKeyPattern.MatchString(secret.FromEnv) {
+			return fmt.Errorf("secret %s: invalid environment variable name %q", id, secret.FromEnv)
+		}
+		if err := validatePath(secret.FromFile); err != nil {
+			return fmt.Errorf("secret %s: %v", id, err)
+		}
+	}
+	return nil
+}
+
+// secretArgs returns the --secret flags that mount the configured secrets,
+// and the trust_certs bundle, into RUN --mount=type=secret instructions.
+// BuildKit reads the values itself, so they never appear on the command line
+// or in the image.
+func secretArgs(cfg *Config) []string {
+	var args []string
+	for _, id := range sortedKeys(cfg.Secrets) {
+		secret := cfg.Secrets[id]
+		if secret.FromEnv != "" {
+			args = append(args, "--secret", fmt.Sprintf("id=%s,env=%s", id, secret.FromEnv))
+		} else {
+			args = append(args, "--secret", fmt.Sprintf("id=%s,src=%s", id, secret.FromFile))
+		}
+	}
+	if cfg.trustBundle != "" {
+		args = append(args, "--secret", fmt.Sprintf("id=%s,src=%s", trustCertsSecret, cfg.trustBundle))
+	}
+	return args
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestSensitiveBuildArgs(t *testing.T) {
+	cfg := &Config{
+		BuildArgs: map[string]string{
+			"GO_VERSION":  "1.22",
+			"NPM_TOKEN":   "abc",
+			"DB_PASSWORD": "hunter2",
+		},
+		BuildArgSources: map[string]BuildArgSource{
+			"SIGNING_KEY": {FromEnv: "SIGNING_KEY"},
+		},
+	}
+
+	got := strings.Join(sensitiveBuildArgs(cfg), ",")
+	if got != "DB_PASSWORD,NPM_TOKEN,SIGNING_KEY" {
+		t.Errorf("sensitiveBuildArgs() = %s", got)
+	}
+}
+
+func TestCheckSensitiveBuildArgs(t *testing.T) {
+	cfg := &Config{BuildArgs: map[string]string{"NPM_TOKEN": "abc"}}
+
+	warning, err := checkSensitiveBuildArgs(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(warning, "NPM_TOKEN") || !strings.Contains(warning, "secrets") {
+		t.Errorf("unexpected warning: %q", warning)
+	}
+
+	cfg.ForbidSensitiveBuildArgs = true
+	if _, err := checkSensitiveBuildArgs(cfg); err == nil {
+		t.Error("expected error with forbid_sensitive_build_args")
+	}
+
+	cfg.BuildArgs = map[string]string{"GO_VERSION": "1.22"}
+	if warning, err := checkSensitiveBuildArgs(cfg); warning != "" || err != nil {
+		t.Errorf("expected no warning, got %q, %v", warning, err)
+	}
+}
+
+func TestValidateSecrets(t *testing.T) {
+	tests := []struct {
+		name    string
+		secrets map[string]BuildArgSource
+		wantErr bool
+	}{
+		{"env", map[string]BuildArgSource{"npm": {FromEnv: "NPM_TOKEN"}}, false},
+		{"file", map[string]BuildArgSource{"npmrc": {FromFile: ".npmrc"}}, false},
+		{"invalid id", map[string]BuildArgSource{"a,b": {FromEnv: "NPM_TOKEN"}}, true},
+		{"neither", map[string]BuildArgSource{"npm": {}}, true},
+		{"file outside working directory", map[string]BuildArgSource{"npmrc": {FromFile: "../.npmrc"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSecrets(tt.secrets)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateSecrets() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExecuteSensitiveBuildArgs(t *testing.T) {
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock}
+
+	config := map[string]any{
+		"image":      "myapp",
+		"push":       false,
+		"build_args": map[string]any{"NPM_TOKEN": "abc"},
+	}
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  config,
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+		DryRun:  true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+	warnings, _ := resp.Outputs["warnings"].([]string)
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "NPM_TOKEN") {
+		t.Errorf("expected sensitive build arg warning, got %v", warnings)
+	}
+
+	config["forbid_sensitive_build_args"] = true
+	resp, err = p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  config,
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+		DryRun:  true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success || !strings.Contains(resp.Error, "sensitive build args") {
+		t.Errorf("expected sensitive build args failure, got %+v", resp)
+	}
+}
+
+func TestExecuteMountsSecrets(t *testing.T) {
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image": "myapp",
+			"push":  false,
+			"secrets": map[string]any{
+				"npm":   map[string]any{"from_env": "NPM_TOKEN"},
+				"npmrc": map[string]any{"from_file": ".npmrc"},
+			},
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+
+	build := mock.RunCalls[0].Args
+	if !containsArg(build, "--secret", "id=npm,env=NPM_TOKEN") || !containsArg(build, "--secret", "id=npmrc,src=.npmrc") {
+		t.Errorf("expected secret mounts, got %v", build)
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// Statuses of self-test checks.
+const (
+	selfTestOK      = "ok"
+	selfTestFailed  = "failed"
+	selfTestSkipped = "skipped"
+)
+
+// SelfTestCheck is one row of the readiness matrix reported by selftest.
+type SelfTestCheck struct {
+	// Check is tool, credentials, registry or signer.
+	Check  string `json:"check"`
+	Target string `json:"target"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// selfTestRegistry is a registry the release pushes to, with the
+// credentials configured for it.
+type selfTestRegistry struct {
+	role     string
+	cfg      *Config
+	username string
+	password string
+}
+
+// selfTest exercises every configured integration without building,
+// pushing, signing or logging in: it runs the tools the configuration
+// needs, resolves the credentials, lists one tag of every repository the
+// release pushes to and checks access to the signing key.
+func (p *DockerPlugin) selfTest(ctx context.Context, cfg *Config) *plugin.ExecuteResponse {
+	var checks []SelfTestCheck
+	add := func(check, target string, err error, detail string) {
+		c := SelfTestCheck{Check: check, Target: target, Status: selfTestOK, Detail: detail}
+		if err != nil {
+			c.Status, c.Detail = selfTestFailed, err.Error()
+		}
+		checks = append(checks, c)
+	}
+
+	for _, tool := range selfTestTools(cfg) {
+		version, err := p.toolVersion(ctx, tool)
+		add("tool", strings.Join(tool, " "), err, version)
+	}
+
+	for _, r := range selfTestRegistries(cfg) {
+		target := r.role + " " + imageRepository(r.cfg)
+		username, password, detail, err := p.registryCredentials(ctx, r.cfg, r.username, r.password)
+		add("credentials", target, err, detail)
+		if err != nil {
+			checks = append(checks, SelfTestCheck{Check: "registry", Target: target, Status: selfTestSkipped, Detail: "no credentials"})
+			continue
+		}
+		detail, err = p.listOneTag(ctx, r.cfg, username, password)
+		add("registry", target, err, detail)
+	}
+
+	if cfg.CosignSign {
+		if err := checkSigningPrerequisites(cfg); err != nil {
+			add("signer", "cosign", err, "")
+		} else if cfg.CosignKey == "" {
+			checks = append(checks, SelfTestCheck{Check: "signer", Target: "cosign keyless", Status: selfTestSkipped, Detail: "the signing identity is issued when signing"})
+		} else {
+			_, err := p.getExecutor().Output(ctx, "cosign", []string{"public-key", "--key", cfg.CosignKey})
+			add("signer", "cosign "+cfg.CosignKey, err, "key accessible")
+		}
+	}
+
+	var failed []string
+	for _, c := range checks {
+		if c.Status == selfTestFailed {
+			failed = append(failed, fmt.Sprintf("%s %s: %s", c.Check, c.Target, c.Detail))
+		}
+	}
+	outputs := map[string]any{"selftest": checks}
+	if len(failed) > 0 {
+		return &plugin.ExecuteResponse{
+			Success: false,
+			Error:   fmt.Sprintf("self-test failed %d of %d checks: %s", len(failed), len(checks), strings.Join(failed, "; ")),
+			Outputs: outputs,
+		}
+	}
+	return &plugin.ExecuteResponse{
+		Success: true,
+		Message: fmt.Sprintf("Self-test passed %d checks", len(checks)),
+		Outputs: outputs,
+	}
+}
+
+// selfTestTools returns the version commands of the tools the
+// configuration runs.
+func selfTestTools(cfg *Config) [][]string {
+	var tools [][]string
+	if !cfg.Daemonless || cfg.OCITarball == "" {
+		tools = append(tools, []string{"docker", "version", "--format", "{{.Server.Version}}"})
+	}
+	if useBuildx(cfg) {
+		tools = append(tools, []string{"docker", "buildx", "version"})
+	}
+	cosign := cfg.CosignSign || cfg.CosignCopy || slices.ContainsFunc(cfg.BaseImageTrust, func(b BaseImagePolicy) bool { return b.Method == "cosign" })
+	if cosign {
+		tools = append(tools, []string{"cosign", "version"})
+	}
+	if cfg.ECR != nil {
+		tools = append(tools, []string{"aws", "--version"})
+	}
+	if cfg.E2E != nil {
+		tools = append(tools, []string{"kubectl", "version", "--client"})
+	}
+	if cfg.ClusterLoad != "" {
+		tools = append(tools, []string{cfg.ClusterLoad, "version"})
+	}
+	return tools
+}
+
+// toolVersion runs a version command and returns the first line it printed.
+func (p *DockerPlugin) toolVersion(ctx context.Context, tool []string) (string, error) {
+	out, err := p.getExecutor().Output(ctx, tool[0], tool[1:])
+	if err != nil {
+		return "", err
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	return strings.TrimSpace(line), nil
+}
+
+// selfTestRegistries returns the registry of the release, its mirrors and
+// the archive registry.
+func selfTestRegistries(cfg *Config) []selfTestRegistry {
+	registries := []selfTestRegistry{{role: "registry", cfg: cfg, username: cfg.Username, password: loginSecret(cfg)}}
+	for _, mirror := range cfg.Mirrors {
+		mirrorCfg := *cfg
+		mirrorCfg.Registry = mirror.Registry
+		if mirror.Image != "" {
+			mirrorCfg.Image = mirror.Image
+		}
+		mirrorCfg.PasswordCommand = nil
+		mirrorCfg.UseCredentialHelper = false
+		registries = append(registries, selfTestRegistry{role: "mirror", cfg: &mirrorCfg, username: mirror.Username, password: mirror.Password})
+	}
+	if cfg.ArchiveRegistry != "" {
+		archiveCfg := *cfg
+		archiveCfg.Registry = cfg.ArchiveRegistry
+		if cfg.ArchiveImage != "" {
+			archiveCfg.Image = cfg.ArchiveImage
+		}
+		archiveCfg.PasswordCommand = nil
+		archiveCfg.UseCredentialHelper = false
+		registries = append(registries, selfTestRegistry{role: "archive", cfg: &archiveCfg, username: cfg.ArchiveUsername, password: cfg.ArchivePassword})
+	}
+	return registries
+}
+
+// registryCredentials resolves the credentials of a registry the way the
+// release would, without logging in, and describes their source. username
+// and password are the static credentials configured for it.
+func (p *DockerPlugin) registryCredentials(ctx context.Context, cfg *Config, username, password string) (string, string, string, error) {
+	switch {
+	case cfg.UseCredentialHelper:
+		username, secret, err := p.resolveHelperCredentials(ctx, cfg.Registry)
+		return username, secret, "credential helper", err
+	case skipLogin(cfg):
+		return "", "", "anonymous (local registry)", nil
+	case len(cfg.PasswordCommand) > 0:
+		secret, err := p.fetchPassword(ctx, cfg)
+		return cfg.Username, secret, "password_command", err
+	case username != "" && password != "":
+		return username, password, "static credentials", nil
+	}
+	return "", "", "anonymous", nil
+}
+
+// listOneTag lists at most one tag of the repository of cfg over the
+// registry API, authorizing like a daemonless push, so the credentials are
+// checked against the registry without changing it.
+func (p *DockerPlugin) listOneTag(ctx context.Context, cfg *Config, username, password string) (string, error) {
+	client := p.newRegistryClient(cfg)
+	client.username, client.password = username, password
+	repository := registryRepository(cfg)
+	o := &ociPusher{client: client, repository: repository}
+	if err := o.authorize(ctx); err != nil {
+		return "", err
+	}
+	resp, err := o.do(ctx, http.MethodGet, client.baseURL+"/v2/"+repository+"/tags/list?n=1", nil, 0, "")
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return "repository has no tags yet", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", expect(resp, "list tags", http.StatusOK)
+	}
+	defer resp.Body.Close()
+	var list struct {
+		Tags []string `json:"tags"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return "", fmt.Errorf("list tags: invalid response: %w", err)
+	}
+	if len(list.Tags) == 0 {
+		return "repository has no tags yet", nil
+	}
+	return "listed tag " + list.Tags[0], nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestSelfTest(t *testing.T) {
+	t.Setenv("DOCKER_USERNAME", "")
+	t.Setenv("DOCKER_PASSWORD", "")
+	t.Setenv("DOCKER_PAT", "")
+	stubLookPath(t, "cosign")
+
+	p, host := newTestRegistry(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, _ := r.BasicAuth(); user != "bot" || pass != "secret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case "/v2/myorg/myapp/tags/list":
+			_, _ = w.Write([]byte(`{"name": "myorg/myapp", "tags": ["1.0.0"]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	mock := &MockCommandExecutor{
+		OutputFunc: func(ctx context.Context, name string, args []string) ([]byte, error) {
+			switch {
+			case name == "docker" && args[0] == "version":
+				return []byte("27.1.1\n"), nil
+			case name == "cosign" && args[0] == "version":
+				return []byte("GitVersion:    v2.4.0\nGitCommit:     abc\n"), nil
+			}
+			return nil, nil
+		},
+	}
+	p.executor = mock
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"registry":             host,
+			"image":                "myorg/myapp",
+			"username":             "bot",
+			"password":             "secret",
+			"login_local_registry": true,
+			"cosign_sign":          true,
+			"cosign_key":           "cosign.key",
+			"mirrors":              []any{map[string]any{"registry": host, "image": "myorg/mirror", "username": "bot", "password": "secret"}},
+			"selftest":             true,
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success || !strings.Contains(resp.Error, "self-test failed 1 of") || !strings.Contains(resp.Error, "signer cosign: cosign_key cosign.key does not exist") {
+		t.Errorf("expected only the signer to fail, got %q", resp.Error)
+	}
+
+	checks, _ := resp.Outputs["selftest"].([]SelfTestCheck)
+	want := map[string]string{
+		"tool docker version --format {{.Server.Version}}": "27.1.1",
+		"tool cosign version":                              "GitVersion:    v2.4.0",
+		"credentials registry " + host + "/myorg/myapp":    "static credentials",
+		"registry registry " + host + "/myorg/myapp":       "listed tag 1.0.0",
+		"registry mirror " + host + "/myorg/mirror":        "repository has no tags yet",
+	}
+	for _, c := range checks {
+		if detail, ok := want[c.Check+" "+c.Target]; ok {
+			if c.Status != selfTestOK || c.Detail != detail {
+				t.Errorf("unexpected check %+v, want detail %q", c, detail)
+			}
+			delete(want, c.Check+" "+c.Target)
+		}
+	}
+	if len(want) > 0 {
+		t.Errorf("missing checks %v in %+v", want, checks)
+	}
+	for _, call := range mock.RunCalls {
+		t.Errorf("expected the self-test to run nothing, got %s %v", call.Name, call.Args)
+	}
+}
+
+func TestSelfTestTools(t *testing.T) {
+	tools := selfTestTools(&Config{Daemonless: true, OCITarball: "image.tar"})
+	if len(tools) != 0 {
+		t.Errorf("expected no docker check for daemonless tarball pushes, got %v", tools)
+	}
+	tools = selfTestTools(&Config{Builder: "ci", ClusterLoad: clusterKind})
+	if len(tools) != 3 || tools[1][1] != "buildx" || tools[2][0] != "kind" {
+		t.Errorf("unexpected tools: %v", tools)
+	}
+}
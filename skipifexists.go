@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// manifestAccept lists the manifest media types the registry may answer a
+// manifest request with.
+var manifestAccept = strings.Join([]string{ociIndexMediaType, ociManifestMediaType, dockerManifestListType, dockerManifestMediaType}, ", ")
+
+// existingTags asks the registry API, with HEAD requests for their
+// manifests, which of tags already exist in the repository of cfg, and
+// returns their digests keyed by tag. It records a skip_if_exists stage.
+func (p *DockerPlugin) existingTags(ctx context.Context, cfg *Config, tags []string, outputs *Outputs) (map[string]string, error) {
+	started := time.Now()
+	digests, err := p.headManifests(ctx, cfg, tags)
+	outputs.stage("skip_if_exists", started, err)
+	return digests, err
+}
+
+func (p *DockerPlugin) headManifests(ctx context.Context, cfg *Config, tags []string) (map[string]string, error) {
+	o, err := p.registrySession(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return o.headManifests(ctx, tags)
+}
+
+// headManifests returns the digests of the manifests of refs, tags or
+// digests, that exist in the repository, keyed by ref.
+func (o *ociPusher) headManifests(ctx context.Context, refs []string) (map[string]string, error) {
+	digests := make(map[string]string)
+	for _, ref := range refs {
+		resp, err := o.do(ctx, http.MethodHead, fmt.Sprintf("%s/v2/%s/manifests/%s", o.client.baseURL, o.repository, ref), nil, 0, "")
+		if err != nil {
+			return nil, err
+		}
+		resp.Body.Close()
+		switch resp.StatusCode {
+		case http.StatusOK:
+			digests[ref] = resp.Header.Get("Docker-Content-Digest")
+		case http.StatusNotFound:
+		default:
+			return nil, fmt.Errorf("HEAD manifest %s: %s", ref, resp.Status)
+		}
+	}
+	return digests, nil
+}
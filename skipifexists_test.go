@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestExecuteSkipIfExists(t *testing.T) {
+	existing := []string{"1.2.0", "latest"}
+	p, host := newTestRegistry(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodHead && slices.Contains(existing, strings.TrimPrefix(r.URL.Path, "/v2/myorg/myapp/manifests/")):
+			if r.Header.Get("Accept") == "" {
+				t.Errorf("expected manifest media types to be accepted")
+			}
+			w.Header().Set("Docker-Content-Digest", "sha256:abc")
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	tests := []struct {
+		name    string
+		version string
+		skipped bool
+	}{
+		{"every tag exists", "1.2.0", true},
+		{"new version", "1.3.0", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &MockCommandExecutor{}
+			p.executor = mock
+			resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+				Hook:    plugin.HookPostPublish,
+				Config:  map[string]any{"registry": host, "image": "myorg/myapp", "skip_if_exists": true},
+				Context: plugin.ReleaseContext{Version: tt.version},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !resp.Success {
+				t.Fatalf("expected success, got error: %s", resp.Error)
+			}
+			skipped, _ := resp.Outputs["skipped"].(bool)
+			if skipped != tt.skipped || (len(mock.RunCalls) == 0) != tt.skipped {
+				t.Fatalf("expected skipped=%v, got %v after %d commands", tt.skipped, skipped, len(mock.RunCalls))
+			}
+			if digests, _ := resp.Outputs["digests"].(map[string]string); tt.skipped && digests["latest"] != "sha256:abc" {
+				t.Errorf("expected the digests of the existing tags, got %v", digests)
+			}
+		})
+	}
+
+	t.Run("no tags", func(t *testing.T) {
+		mock := &MockCommandExecutor{}
+		p.executor = mock
+		resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+			Hook:    plugin.HookPostPublish,
+			Config:  map[string]any{"registry": host, "image": "myorg/myapp", "skip_if_exists": true, "tags": []any{"{{prerelease}}"}},
+			Context: plugin.ReleaseContext{Version: "1.2.0"},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.Success || !strings.Contains(resp.Error, "no tags to push") {
+			t.Errorf("expected a release without tags to fail instead of being skipped, got %+v", resp)
+		}
+	})
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"debug/elf"
+	"debug/pe"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// elfMachines maps platform architectures to the ELF machine of their
+// binaries.
+var elfMachines = map[string]elf.Machine{
+	"amd64":   elf.EM_X86_64,
+	"386":     elf.EM_386,
+	"arm64":   elf.EM_AARCH64,
+	"arm":     elf.EM_ARM,
+	"ppc64le": elf.EM_PPC64,
+	"s390x":   elf.EM_S390,
+	"riscv64": elf.EM_RISCV,
+}
+
+// validateStaticBinaries checks that static_binaries are absolute paths in
+// the image.
+func validateStaticBinaries(cfg *Config) error {
+	for _, binary := range cfg.StaticBinaries {
+		if !path.IsAbs(binary) || path.Clean(binary) != binary {
+			return fmt.Errorf("static binary %q must be a clean absolute path", binary)
+		}
+	}
+	return nil
+}
+
+// defaultSearchPath is the PATH of containers whose image sets none.
+const defaultSearchPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// verifiesBinaries reports whether binaries of the image are checked.
+func verifiesBinaries(cfg *Config) bool {
+	return len(cfg.StaticBinaries) > 0 || cfg.VerifyEntrypointArch
+}
+
+// verifyBinaries checks binaries of the image ref for every platform
+// without running a container, so images without a shell, such as scratch
+// and distroless images, can be verified. Every static_binaries path must
+// exist, be statically linked and be built for the platform; with
+// verify_entrypoint_arch, the entrypoint must be built for the platform.
+func (p *DockerPlugin) verifyBinaries(ctx context.Context, cfg *Config, ref string) error {
+	platforms := cfg.Platforms
+	if len(platforms) == 0 {
+		platforms = []string{p.daemonPlatform(ctx)}
+	}
+
+	var failures []string
+	for _, platform := range platforms {
+		problems, err := p.inspectBinaries(ctx, cfg, ref, platform)
+		if err != nil {
+			return fmt.Errorf("%s: %w", platform, err)
+		}
+		for _, problem := range problems {
+			failures = append(failures, platform+": "+problem)
+		}
+	}
+	if len(failures) > 0 {
+		return errors.New(strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// inspectBinaries copies the binaries out of a container created, but not
+// started, from the platform's image.
+func (p *DockerPlugin) inspectBinaries(ctx context.Context, cfg *Config, ref, platform string) ([]string, error) {
+	out, err := p.getExecutor().Output(ctx, "docker", []string{"create", "--platform", platform, ref})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create container: %w", err)
+	}
+	container := strings.TrimSpace(string(out))
+	defer p.getExecutor().Run(ctx, "docker", []string{"rm", "-f", container}, nil)
+
+	goos, arch, _ := strings.Cut(platform, "/")
+	arch, _, _ = strings.Cut(arch, "/")
+
+	var problems []string
+	for _, binary := range cfg.StaticBinaries {
+		data, err := p.copyBinary(ctx, container, binary)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s %v", binary, err))
+			continue
+		}
+		if problem := checkStaticELF(data, arch); problem != "" {
+			problems = append(problems, fmt.Sprintf("%s %s", binary, problem))
+		}
+	}
+
+	if cfg.VerifyEntrypointArch {
+		binary, data, err := p.copyEntrypoint(ctx, container)
+		switch {
+		case err != nil:
+			problems = append(problems, fmt.Sprintf("entrypoint %v", err))
+		case binary == "":
+		default:
+			if problem := checkBinaryArch(data, goos, arch); problem != "" {
+				problems = append(problems, fmt.Sprintf("entrypoint %s %s", binary, problem))
+			}
+		}
+	}
+	return problems, nil
+}
+
+// copyBinary returns the content of file in container, following symlinks.
+func (p *DockerPlugin) copyBinary(ctx context.Context, container, file string) ([]byte, error) {
+	archive, err := p.getExecutor().Output(ctx, "docker", []string{"cp", "-L", container + ":" + file, "-"})
+	if err != nil {
+		return nil, fmt.Errorf("not found")
+	}
+	data, err := firstTarFile(archive)
+	if err != nil {
+		return nil, fmt.Errorf("is unreadable: %v", err)
+	}
+	return data, nil
+}
+
+// copyEntrypoint returns the path and content of the program the container
+// starts: the first word of its entrypoint, or of its command without one.
+// A relative program is looked up on the PATH of the image. The path is
+// empty when the image sets neither.
+func (p *DockerPlugin) copyEntrypoint(ctx context.Context, container string) (string, []byte, error) {
+	out, err := p.getExecutor().Output(ctx, "docker", []string{"container", "inspect", "--format", "{{json .Config}}", container})
+	if err != nil {
+		return "", nil, fmt.Errorf("cannot be inspected: %w", err)
+	}
+	var config struct {
+		Entrypoint []string `json:"Entrypoint"`
+		Cmd        []string `json:"Cmd"`
+		Env        []string `json:"Env"`
+	}
+	if err := json.Unmarshal(out, &config); err != nil {
+		return "", nil, fmt.Errorf("cannot be inspected: %w", err)
+	}
+	command := config.Entrypoint
+	if len(command) == 0 {
+		command = config.Cmd
+	}
+	if len(command) == 0 || command[0] == "" {
+		return "", nil, nil
+	}
+
+	program := command[0]
+	if strings.Contains(program, "/") {
+		data, err := p.copyBinary(ctx, container, program)
+		if err != nil {
+			return "", nil, fmt.Errorf("%s %v", program, err)
+		}
+		return program, data, nil
+	}
+	searchPath := defaultSearchPath
+	for _, env := range config.Env {
+		if value, ok := strings.CutPrefix(env, "PATH="); ok {
+			searchPath = value
+		}
+	}
+	for _, dir := range strings.Split(searchPath, ":") {
+		candidate := path.Join(dir, program)
+		if data, err := p.copyBinary(ctx, container, candidate); err == nil {
+			return candidate, data, nil
+		}
+	}
+	return "", nil, fmt.Errorf("%s not found on PATH %s", program, searchPath)
+}
+
+// firstTarFile returns the content of the first regular file of the tar
+// stream written by docker cp.
+func firstTarFile(archive []byte) ([]byte, error) {
+	r := tar.NewReader(bytes.NewReader(archive))
+	for {
+		header, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("not a regular file")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag == tar.TypeReg {
+			return io.ReadAll(r)
+		}
+	}
+}
+
+// peMachines maps platform architectures to the PE machine of Windows
+// binaries.
+var peMachines = map[string]uint16{
+	"amd64": pe.IMAGE_FILE_MACHINE_AMD64,
+	"386":   pe.IMAGE_FILE_MACHINE_I386,
+	"arm64": pe.IMAGE_FILE_MACHINE_ARM64,
+	"arm":   pe.IMAGE_FILE_MACHINE_ARMNT,
+}
+
+// checkBinaryArch describes why data is not built for arch, or returns an
+// empty string. Windows images hold PE binaries, other images ELF binaries;
+// scripts run on any architecture.
+func checkBinaryArch(data []byte, goos, arch string) string {
+	if bytes.HasPrefix(data, []byte("#!")) {
+		return ""
+	}
+	if goos == "windows" {
+		f, err := pe.NewFile(bytes.NewReader(data))
+		if err != nil {
+			return "is not a PE binary"
+		}
+		defer f.Close()
+		if want, ok := peMachines[arch]; ok && f.Machine != want {
+			return fmt.Sprintf("is built for PE machine %#x, not %s", f.Machine, arch)
+		}
+		return ""
+	}
+	f, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		return "is not an ELF binary"
+	}
+	defer f.Close()
+	if want, ok := elfMachines[arch]; ok && f.Machine != want {
+		return fmt.Sprintf("is built for %s, not %s", f.Machine, arch)
+	}
+	return ""
+}
+
+// checkStaticELF describes why data is not a static ELF executable for
+// arch, or returns an empty string.
+func checkStaticELF(data []byte, arch string) string {
+	f, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		return "is not an ELF binary"
+	}
+	defer f.Close()
+
+	if problem := checkBinaryArch(data, "linux", arch); problem != "" {
+		return problem
+	}
+	for _, prog := range f.Progs {
+		if prog.Type == elf.PT_INTERP {
+			interp, _ := io.ReadAll(prog.Open())
+			return fmt.Sprintf("is dynamically linked (interpreter %s)", strings.TrimRight(string(interp), "\x00"))
+		}
+	}
+	if libs, _ := f.ImportedLibraries(); len(libs) > 0 {
+		return fmt.Sprintf("is dynamically linked (needs %s)", strings.Join(libs, ", "))
+	}
+	return ""
+}
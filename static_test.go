@@ -0,0 +1,212 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// testELF returns a minimal ELF executable for machine, dynamically linked
+// with interp when it is set.
+func testELF(machine elf.Machine, interp string) []byte {
+	const headerSize, progSize = 64, 56
+	var b bytes.Buffer
+	phnum := uint16(0)
+	if interp != "" {
+		phnum = 1
+	}
+	b.Write([]byte{0x7f, 'E', 'L', 'F', byte(elf.ELFCLASS64), byte(elf.ELFDATA2LSB), byte(elf.EV_CURRENT)})
+	b.Write(make([]byte, 16-b.Len()))
+	header := []any{
+		uint16(elf.ET_EXEC), uint16(machine), uint32(elf.EV_CURRENT), uint64(0x400000),
+		uint64(headerSize), uint64(0), uint32(0),
+		uint16(headerSize), uint16(progSize), phnum, uint16(64), uint16(0), uint16(0),
+	}
+	for _, field := range header {
+		binary.Write(&b, binary.LittleEndian, field)
+	}
+	if interp != "" {
+		data := append([]byte(interp), 0)
+		prog := []any{uint32(elf.PT_INTERP), uint32(elf.PF_R), uint64(headerSize + progSize), uint64(0), uint64(0), uint64(len(data)), uint64(len(data)), uint64(1)}
+		for _, field := range prog {
+			binary.Write(&b, binary.LittleEndian, field)
+		}
+		b.Write(data)
+	}
+	return b.Bytes()
+}
+
+// testFileTar wraps data in a tar stream as docker cp writes it.
+func testFileTar(name string, data []byte) []byte {
+	var b bytes.Buffer
+	w := tar.NewWriter(&b)
+	w.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0o755, Size: int64(len(data))})
+	w.Write(data)
+	w.Close()
+	return b.Bytes()
+}
+
+func TestCheckStaticELF(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		arch string
+		want string
+	}{
+		{"static", testELF(elf.EM_X86_64, ""), "amd64", ""},
+		{"wrong arch", testELF(elf.EM_X86_64, ""), "arm64", "is built for EM_X86_64, not arm64"},
+		{"dynamic", testELF(elf.EM_AARCH64, "/lib/ld-linux-aarch64.so.1"), "arm64", "is dynamically linked (interpreter /lib/ld-linux-aarch64.so.1)"},
+		{"script", []byte("#!/bin/sh\n"), "amd64", "is not an ELF binary"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkStaticELF(tt.data, tt.arch); got != tt.want {
+				t.Errorf("checkStaticELF() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateStaticBinaries(t *testing.T) {
+	if err := validateStaticBinaries(&Config{StaticBinaries: []string{"/app/server"}}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for _, binary := range []string{"app/server", "/app/../server"} {
+		if err := validateStaticBinaries(&Config{StaticBinaries: []string{binary}}); err == nil {
+			t.Errorf("expected %q to be rejected", binary)
+		}
+	}
+}
+
+func TestExecuteVerifiesStaticBinaries(t *testing.T) {
+	var removed []string
+	mock := &MockCommandExecutor{
+		OutputFunc: func(_ context.Context, _ string, args []string) ([]byte, error) {
+			switch args[0] {
+			case "create":
+				return []byte("ctr-" + strings.ReplaceAll(args[2], "/", "-") + "\n"), nil
+			case "cp":
+				container, file, _ := strings.Cut(args[2], ":")
+				switch {
+				case file == "/missing":
+					return nil, errors.New("no such file")
+				case strings.HasSuffix(container, "arm64"):
+					return testFileTar("server", testELF(elf.EM_AARCH64, "/lib/ld-linux-aarch64.so.1")), nil
+				default:
+					return testFileTar("server", testELF(elf.EM_X86_64, "")), nil
+				}
+			}
+			return nil, nil
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":           "myapp",
+			"platforms":       []any{"linux/amd64", "linux/arm64"},
+			"static_binaries": []any{"/app/server", "/missing"},
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success {
+		t.Fatal("expected static binary verification to fail")
+	}
+	for _, want := range []string{
+		"linux/amd64: /missing not found",
+		"linux/arm64: /app/server is dynamically linked",
+	} {
+		if !strings.Contains(resp.Error, want) {
+			t.Errorf("expected %q in %q", want, resp.Error)
+		}
+	}
+	if strings.Contains(resp.Error, "linux/amd64: /app/server") {
+		t.Errorf("expected the amd64 binary to pass, got %q", resp.Error)
+	}
+
+	for _, call := range mock.RunCalls {
+		if call.Args[0] == "rm" {
+			removed = append(removed, call.Args[2])
+		}
+	}
+	if len(removed) != 2 {
+		t.Errorf("expected both containers to be removed, got %v", removed)
+	}
+}
+
+func TestCheckBinaryArch(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       []byte
+		goos, arch string
+		want       string
+	}{
+		{"matching ELF", testELF(elf.EM_AARCH64, "/lib/ld-linux-aarch64.so.1"), "linux", "arm64", ""},
+		{"amd64 binary in arm64 image", testELF(elf.EM_X86_64, ""), "linux", "arm64", "is built for EM_X86_64, not arm64"},
+		{"script", []byte("#!/bin/sh\nexec app\n"), "linux", "arm64", ""},
+		{"ELF in windows image", testELF(elf.EM_X86_64, ""), "windows", "amd64", "is not a PE binary"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkBinaryArch(tt.data, tt.goos, tt.arch); got != tt.want {
+				t.Errorf("checkBinaryArch() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteVerifiesEntrypointArch(t *testing.T) {
+	var copied []string
+	mock := &MockCommandExecutor{
+		OutputFunc: func(_ context.Context, _ string, args []string) ([]byte, error) {
+			switch args[0] {
+			case "create":
+				return []byte("ctr-" + strings.ReplaceAll(args[2], "/", "-") + "\n"), nil
+			case "container":
+				return []byte(`{"Entrypoint":["server"],"Cmd":["--port","8080"],"Env":["PATH=/app:/usr/bin"]}`), nil
+			case "cp":
+				copied = append(copied, args[2])
+				if !strings.HasSuffix(args[2], ":/app/server") {
+					return nil, errors.New("no such file")
+				}
+				// The arm64 image got the amd64 binary.
+				return testFileTar("server", testELF(elf.EM_X86_64, "")), nil
+			}
+			return nil, nil
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":                  "myapp",
+			"platforms":              []any{"linux/amd64", "linux/arm64"},
+			"verify_entrypoint_arch": true,
+		},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success || !strings.Contains(resp.Error, "linux/arm64: entrypoint /app/server is built for EM_X86_64, not arm64") {
+		t.Fatalf("expected an architecture mismatch, got %+v", resp)
+	}
+	if strings.Contains(resp.Error, "linux/amd64") {
+		t.Errorf("expected the amd64 image to pass, got %q", resp.Error)
+	}
+	if len(copied) == 0 || !strings.HasSuffix(copied[0], ":/app/server") {
+		t.Errorf("expected the entrypoint to be found on the image PATH, got %v", copied)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// parseTagAliases reads the tag_aliases mapping of tags to the aliases
+// always pushed with them. An alias list may be a single string.
+func parseTagAliases(raw map[string]any) map[string][]string {
+	return parseTagLists(raw, "tag_aliases")
+}
+
+// parseTagLists reads a mapping of names to tag lists, where a list may be
+// a single string.
+func parseTagLists(raw map[string]any, key string) map[string][]string {
+	items, ok := raw[key].(map[string]any)
+	if !ok {
+		return nil
+	}
+	aliases := make(map[string][]string, len(items))
+	for tag, value := range items {
+		switch v := value.(type) {
+		case string:
+			aliases[tag] = []string{v}
+		case []any:
+			for _, item := range v {
+				if s, ok := item.(string); ok {
+					aliases[tag] = append(aliases[tag], s)
+				}
+			}
+		}
+	}
+	return aliases
+}
+
+// validateTagAliases checks that every aliased tag is a release tag.
+func validateTagAliases(cfg *Config) error {
+	tags := allTagTemplates(cfg)
+	for _, tag := range sortedKeys(cfg.TagAliases) {
+		if !slices.Contains(tags, tag) {
+			return fmt.Errorf("%q is not one of tags", tag)
+		}
+		if len(cfg.TagAliases[tag]) == 0 {
+			return fmt.Errorf("%q has no aliases", tag)
+		}
+	}
+	return nil
+}
+
+// addTagAliases resolves the aliases of the release tags and adds each
+// after its tag. It returns the tags and the groups of tags that must
+// resolve to the same digest, each starting with the aliased tag.
+func addTagAliases(cfg *Config, tags []string, version string) ([]string, [][]string, error) {
+	if len(cfg.TagAliases) == 0 {
+		return tags, nil, nil
+	}
+	byTag := make(map[string][]string, len(cfg.TagAliases))
+	for _, tag := range sortedKeys(cfg.TagAliases) {
+		resolved, err := resolveTags([]string{tag}, version, cfg.tagContext)
+		if err != nil || len(resolved) == 0 {
+			continue
+		}
+		aliases, err := resolveTags(cfg.TagAliases[tag], version, cfg.tagContext)
+		if err != nil {
+			return nil, nil, fmt.Errorf("tag_aliases %s: %v", tag, err)
+		}
+		byTag[resolved[0]] = aliases
+	}
+
+	var expanded []string
+	var groups [][]string
+	for _, tag := range tags {
+		if !slices.Contains(expanded, tag) {
+			expanded = append(expanded, tag)
+		}
+		aliases, ok := byTag[tag]
+		if !ok {
+			continue
+		}
+		group := []string{tag}
+		for _, alias := range aliases {
+			if !slices.Contains(group, alias) {
+				group = append(group, alias)
+			}
+			if !slices.Contains(expanded, alias) {
+				expanded = append(expanded, alias)
+			}
+		}
+		if len(group) > 1 {
+			groups = append(groups, group)
+		}
+	}
+	return expanded, groups, nil
+}
+
+// withTagAliases adds the aliases of the selected tags, so a mirror never
+// receives a tag without its aliases.
+func withTagAliases(selected []string, groups [][]string) []string {
+	result := slices.Clone(selected)
+	for _, group := range groups {
+		if !slices.ContainsFunc(group, func(tag string) bool { return slices.Contains(selected, tag) }) {
+			continue
+		}
+		for _, tag := range group {
+			if !slices.Contains(result, tag) {
+				result = append(result, tag)
+			}
+		}
+	}
+	return result
+}
+
+// verifyTagAliases checks that every tag and its aliases resolve to the
+// same digest in the registry, so old and new consumers pull the same
+// image.
+func (p *DockerPlugin) verifyTagAliases(ctx context.Context, cfg *Config, repository string, outputs *Outputs) error {
+	if len(cfg.tagAliasGroups) == 0 {
+		return nil
+	}
+	started := time.Now()
+	err := p.checkTagAliases(ctx, repository, cfg.tagAliasGroups)
+	outputs.stage("tag_aliases", started, err)
+	return err
+}
+
+func (p *DockerPlugin) checkTagAliases(ctx context.Context, repository string, groups [][]string) error {
+	var mismatches []string
+	for _, group := range groups {
+		digests := make([]string, len(group))
+		for i, tag := range group {
+			digest, err := p.resolveDigest(ctx, repository+":"+tag)
+			if err != nil {
+				return fmt.Errorf("failed to resolve %s:%s: %w", repository, tag, err)
+			}
+			digests[i] = digest
+		}
+		for i := 1; i < len(group); i++ {
+			if digests[i] != digests[0] {
+				mismatches = append(mismatches, fmt.Sprintf("%s is %s but its alias %s is %s", group[0], digests[0], group[i], digests[i]))
+			}
+		}
+	}
+	if len(mismatches) > 0 {
+		return fmt.Errorf("tag aliases differ: %s", strings.Join(mismatches, "; "))
+	}
+	return nil
+}
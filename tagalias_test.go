@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestAddTagAliases(t *testing.T) {
+	cfg := &Config{
+		Tags:       []string{"{{version}}", "{{major}}", "latest"},
+		TagAliases: map[string][]string{"{{version}}": {"v{{version}}"}, "{{major}}": {"v{{major}}"}},
+	}
+	tags, groups, err := addTagAliases(cfg, []string{"1.2.3", "1", "latest"}, "v1.2.3")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(tags, []string{"1.2.3", "v1.2.3", "1", "v1", "latest"}) {
+		t.Errorf("unexpected tags: %v", tags)
+	}
+	if len(groups) != 2 || !slices.Equal(groups[0], []string{"1.2.3", "v1.2.3"}) || !slices.Equal(groups[1], []string{"1", "v1"}) {
+		t.Errorf("unexpected alias groups: %v", groups)
+	}
+
+	cfg.TagAliases = map[string][]string{"{{version}}": {"v{{version}}!"}}
+	if _, _, err := addTagAliases(cfg, []string{"1.2.3"}, "1.2.3"); err == nil || !strings.Contains(err.Error(), "tag_aliases") {
+		t.Errorf("expected an invalid alias to be rejected, got %v", err)
+	}
+}
+
+func TestValidateTagAliases(t *testing.T) {
+	if err := validateTagAliases(&Config{TagAliases: map[string][]string{"{{version}}": {"v{{version}}"}}}); err != nil {
+		t.Errorf("expected the default tags to be aliasable, got %v", err)
+	}
+	if err := validateTagAliases(&Config{Tags: []string{"latest"}, TagAliases: map[string][]string{"{{version}}": {"v{{version}}"}}}); err == nil {
+		t.Errorf("expected an alias of a tag that is not released to be rejected")
+	}
+	if err := validateTagAliases(&Config{TagAliases: map[string][]string{"latest": nil}}); err == nil {
+		t.Errorf("expected an empty alias list to be rejected")
+	}
+}
+
+func TestWithTagAliases(t *testing.T) {
+	groups := [][]string{{"1.2.3", "v1.2.3"}}
+	if got := withTagAliases([]string{"1.2.3"}, groups); !slices.Equal(got, []string{"1.2.3", "v1.2.3"}) {
+		t.Errorf("expected the alias to follow its tag, got %v", got)
+	}
+	if got := withTagAliases([]string{"latest"}, groups); !slices.Equal(got, []string{"latest"}) {
+		t.Errorf("expected unrelated tags to be left alone, got %v", got)
+	}
+}
+
+func TestCheckTagAliasesMismatch(t *testing.T) {
+	mock := &MockCommandExecutor{
+		OutputFunc: func(ctx context.Context, name string, args []string) ([]byte, error) {
+			if strings.HasSuffix(args[len(args)-1], ":v1.2.3") {
+				return []byte("sha256:old\n"), nil
+			}
+			return []byte("sha256:new\n"), nil
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+	err := p.checkTagAliases(context.Background(), "myorg/myapp", [][]string{{"1.2.3", "v1.2.3"}})
+	if err == nil || !strings.Contains(err.Error(), "its alias v1.2.3 is sha256:old") {
+		t.Errorf("expected the mismatch to be reported, got %v", err)
+	}
+}
+
+func TestExecutePushesTagAliases(t *testing.T) {
+	mock := &MockCommandExecutor{
+		OutputFunc: func(ctx context.Context, name string, args []string) ([]byte, error) {
+			if slices.Contains(args, "{{.Manifest.Digest}}") {
+				return []byte("sha256:abc\n"), nil
+			}
+			return nil, nil
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image":       "myorg/myapp",
+			"tags":        []any{"{{version}}"},
+			"tag_aliases": map[string]any{"{{version}}": "v{{version}}"},
+		},
+		Context: plugin.ReleaseContext{Version: "1.2.3"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+
+	var pushed []string
+	for _, call := range mock.RunCalls {
+		if len(call.Args) == 2 && call.Args[0] == "push" {
+			pushed = append(pushed, call.Args[1])
+		}
+	}
+	if !slices.Equal(pushed, []string{"myorg/myapp:1.2.3", "myorg/myapp:v1.2.3"}) {
+		t.Errorf("expected the tag and its alias to be pushed, got %v", pushed)
+	}
+	stages, _ := resp.Outputs["stages"].([]StageStatus)
+	if !slices.ContainsFunc(stages, func(s StageStatus) bool { return s.Name == "tag_aliases" && s.Status == stageSucceeded }) {
+		t.Errorf("expected a succeeded tag_aliases stage, got %+v", stages)
+	}
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// dockerHubAPI and githubAPI serve the tag deletion APIs of Docker Hub and
+// GHCR; replaced in tests.
+var (
+	dockerHubAPI = "https://hub.docker.com"
+	githubAPI    = defaultGitHubAPI
+)
+
+// errTagDeleteUnsupported reports a registry without an API to delete tags.
+var errTagDeleteUnsupported = errors.New("the registry does not support deleting tags")
+
+// tagDeleter removes tags of one repository over the API of its registry.
+type tagDeleter struct {
+	p            *DockerPlugin
+	cfg          *Config
+	registryType string
+	repository   string
+	username     string
+	password     string
+	// token authorizes Docker Hub requests once logged in.
+	token string
+}
+
+// newTagDeleter resolves the credentials of cfg for deleting its tags.
+func (p *DockerPlugin) newTagDeleter(ctx context.Context, cfg *Config) (*tagDeleter, error) {
+	username, password, _, err := p.registryCredentials(ctx, cfg, cfg.Username, loginSecret(cfg))
+	if err != nil {
+		return nil, err
+	}
+	return &tagDeleter{
+		p:            p,
+		cfg:          cfg,
+		registryType: p.detectRegistryType(ctx, cfg, true),
+		repository:   registryRepository(cfg),
+		username:     username,
+		password:     password,
+	}, nil
+}
+
+// delete removes tag from the repository. A tag that does not exist is not
+// an error. Docker Hub and Harbor delete the tag alone; GHCR deletes the
+// package version, so a version also carrying tags that are kept is left
+// alone. Other registries use the OCI distribution API, which not every
+// registry implements.
+func (d *tagDeleter) delete(ctx context.Context, tag string, keep []string) error {
+	switch d.registryType {
+	case registryTypeDockerHub:
+		return d.deleteDockerHub(ctx, tag)
+	case registryTypeGHCR:
+		return d.deleteGHCR(ctx, tag, keep)
+	case registryTypeHarbor:
+		return d.deleteHarbor(ctx, tag)
+	case registryTypeECR:
+		return errTagDeleteUnsupported
+	}
+	return d.deleteOCI(ctx, tag)
+}
+
+// digestReferrers are the artifacts attached to an image digest, which
+// are orphaned once no tag references the digest anymore.
+type digestReferrers struct {
+	// tags are the cosign signature, attestation and SBOM tags of the
+	// digest, e.g. sha256-<hex>.sig.
+	tags []string
+	// digests are the manifests the OCI referrers API lists for the digest.
+	digests []string
+}
+
+// referrers returns the artifacts attached to the digest of each of tags
+// that deleting the tags would orphan, keyed by tag. Digests still tagged
+// with a tag that is not deleted, such as one of keep or a tag of an older
+// release, stay referenced and are left out.
+func (d *tagDeleter) referrers(ctx context.Context, tags, keep []string) (map[string]*digestReferrers, error) {
+	session, err := d.p.registrySession(ctx, d.cfg)
+	if err != nil {
+		return nil, err
+	}
+	digests, err := session.headManifests(ctx, tags)
+	if err != nil {
+		return nil, err
+	}
+
+	byDigest := make(map[string]*digestReferrers)
+	for _, tag := range tags {
+		digest, ok := digests[tag]
+		switch {
+		case !ok:
+			continue
+		case digest == "":
+			return nil, fmt.Errorf("the registry did not report the digest of %s", tag)
+		}
+		if _, ok := byDigest[digest]; ok {
+			continue
+		}
+		r := &digestReferrers{}
+		names := make([]string, 0, len(cosignTagSuffixes))
+		for _, cosign := range cosignTagSuffixes {
+			names = append(names, cosignTag(digest)+cosign.suffix)
+		}
+		existing, err := session.headManifests(ctx, names)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			if _, ok := existing[name]; ok {
+				r.tags = append(r.tags, name)
+			}
+		}
+		listed, err := session.fetchReferrers(ctx, digest)
+		if err != nil {
+			return nil, err
+		}
+		for _, referrer := range listed {
+			r.digests = append(r.digests, referrer.Digest)
+		}
+		byDigest[digest] = r
+	}
+
+	attached := make(map[string]*digestReferrers)
+	var kept map[string]bool
+	for _, tag := range tags {
+		r, ok := byDigest[digests[tag]]
+		if !ok || len(r.tags)+len(r.digests) == 0 {
+			continue
+		}
+		// Only a digest with referrers needs the other tags looked up.
+		if kept == nil {
+			if kept, err = d.taggedDigests(ctx, session, tags, keep); err != nil {
+				return nil, err
+			}
+		}
+		if !kept[digests[tag]] {
+			attached[tag] = r
+		}
+	}
+	return attached, nil
+}
+
+// taggedDigests returns the digests the tags of the repository other than
+// deleted reference, looked up from its tag list and keep. Cosign tags name
+// the artifacts of a digest, not the digest, and are skipped.
+func (d *tagDeleter) taggedDigests(ctx context.Context, session *ociPusher, deleted, keep []string) (map[string]bool, error) {
+	listed, err := fetchTags(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+	var others []string
+	for _, tag := range append(listed, keep...) {
+		if !slices.Contains(deleted, tag) && !slices.Contains(others, tag) && !strings.HasPrefix(tag, "sha256-") {
+			others = append(others, tag)
+		}
+	}
+	digests, err := session.headManifests(ctx, others)
+	if err != nil {
+		return nil, err
+	}
+	kept := make(map[string]bool)
+	for _, digest := range digests {
+		kept[digest] = true
+	}
+	return kept, nil
+}
+
+// deletesDigests reports whether the registry can delete untagged
+// manifests, such as the referrers of a deleted release. The Docker Hub
+// API only deletes tags.
+func (d *tagDeleter) deletesDigests() bool {
+	switch d.registryType {
+	case registryTypeDockerHub, registryTypeECR:
+		return false
+	}
+	return true
+}
+
+// deleteDigest removes the manifest digest from the repository. A manifest
+// that does not exist is not an error.
+func (d *tagDeleter) deleteDigest(ctx context.Context, digest string) error {
+	switch d.registryType {
+	case registryTypeDockerHub, registryTypeECR:
+		return errTagDeleteUnsupported
+	case registryTypeGHCR:
+		err := d.deleteGHCRVersion(ctx, "digest "+digest, func(version ghcrVersion) (bool, error) {
+			return version.Name == digest, nil
+		})
+		if errors.Is(err, errGHCRVersionNotFound) {
+			return nil
+		}
+		return err
+	case registryTypeHarbor:
+		return d.deleteHarborArtifact(ctx, digest, "")
+	}
+	return d.deleteOCI(ctx, digest)
+}
+
+// send performs a registry API request and fails unless it answers with one
+// of want. The body of a successful response is decoded into v, if set.
+func (d *tagDeleter) send(ctx context.Context, req *http.Request, v any, want ...int) (int, error) {
+	status, _, err := d.sendHeader(ctx, req, v, want...)
+	return status, err
+}
+
+// sendHeader is send that also returns the response header, such as the
+// Link to the next page of a list.
+func (d *tagDeleter) sendHeader(ctx context.Context, req *http.Request, v any, want ...int) (int, http.Header, error) {
+	for name, value := range d.cfg.RegistryHeaders {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("User-Agent", userAgent(d.cfg))
+	resp, err := d.p.getHTTPClient().Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	if !slices.Contains(want, resp.StatusCode) {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return resp.StatusCode, resp.Header, fmt.Errorf("%s %s: %s: %s", req.Method, req.URL.Path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	if v != nil && resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			return resp.StatusCode, resp.Header, fmt.Errorf("%s %s: invalid response: %w", req.Method, req.URL.Path, err)
+		}
+	}
+	return resp.StatusCode, resp.Header, nil
+}
+
+// deleteDockerHub deletes a tag with the Docker Hub API, which needs a token
+// from a login with the account credentials.
+func (d *tagDeleter) deleteDockerHub(ctx context.Context, tag string) error {
+	if d.token == "" {
+		if d.username == "" || d.password == "" {
+			return fmt.Errorf("deleting Docker Hub tags requires credentials")
+		}
+		body, _ := json.Marshal(map[string]string{"username": d.username, "password": d.password})
+		req, _ := http.NewRequest(http.MethodPost, dockerHubAPI+"/v2/users/login", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		var login struct {
+			Token string `json:"token"`
+		}
+		if _, err := d.send(ctx, req, &login, http.StatusOK); err != nil {
+			return fmt.Errorf("Docker Hub login: %w", err)
+		}
+		d.token = login.Token
+	}
+	req, _ := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/v2/repositories/%s/tags/%s/", dockerHubAPI, d.repository, url.PathEscape(tag)), nil)
+	req.Header.Set("Authorization", "Bearer "+d.token)
+	_, err := d.send(ctx, req, nil, http.StatusNoContent, http.StatusOK, http.StatusNotFound)
+	return err
+}
+
+// ghcrVersion is a version of a GHCR container package, named by the
+// digest of its manifest.
+type ghcrVersion struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Metadata struct {
+		Container struct {
+			Tags []string `json:"tags"`
+		} `json:"container"`
+	} `json:"metadata"`
+}
+
+// deleteGHCR deletes the package version carrying tag with the GitHub
+// packages API.
+func (d *tagDeleter) deleteGHCR(ctx context.Context, tag string, keep []string) error {
+	return d.deleteGHCRVersion(ctx, "tag "+tag, func(version ghcrVersion) (bool, error) {
+		tags := version.Metadata.Container.Tags
+		if !slices.Contains(tags, tag) {
+			return false, nil
+		}
+		for _, other := range tags {
+			if other != tag && slices.Contains(keep, other) {
+				return false, fmt.Errorf("the package version of %s also carries %s", tag, other)
+			}
+		}
+		return true, nil
+	})
+}
+
+// errGHCRVersionNotFound is returned when no package version matches.
+var errGHCRVersionNotFound = errors.New("no matching package version")
+
+// deleteGHCRVersion deletes the first package version matching match,
+// described by what, reading every page of the versions. The package
+// belongs to an organization or a user.
+func (d *tagDeleter) deleteGHCRVersion(ctx context.Context, what string, match func(ghcrVersion) (bool, error)) error {
+	owner, name, ok := strings.Cut(d.repository, "/")
+	if !ok {
+		return fmt.Errorf("invalid GHCR repository %s", d.repository)
+	}
+	for _, scope := range []string{"orgs", "users"} {
+		base := fmt.Sprintf("%s/%s/%s/packages/container/%s/versions", githubAPI, scope, url.PathEscape(owner), url.PathEscape(name))
+		found := false
+		for next := base + "?per_page=100"; next != ""; {
+			req, _ := http.NewRequest(http.MethodGet, next, nil)
+			d.authorizeGitHub(req)
+			var versions []ghcrVersion
+			status, header, err := d.sendHeader(ctx, req, &versions, http.StatusOK, http.StatusNotFound)
+			if err != nil {
+				return err
+			}
+			if status == http.StatusNotFound {
+				break
+			}
+			found = true
+			for _, version := range versions {
+				matched, err := match(version)
+				if err != nil {
+					return err
+				}
+				if !matched {
+					continue
+				}
+				req, _ := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/%d", base, version.ID), nil)
+				d.authorizeGitHub(req)
+				_, err = d.send(ctx, req, nil, http.StatusNoContent, http.StatusNotFound)
+				return err
+			}
+
+			next = ""
+			if m := nextLinkPattern.FindStringSubmatch(header.Get("Link")); m != nil && len(versions) > 0 {
+				// The token is only sent to the GitHub API.
+				if !strings.HasPrefix(m[1], githubAPI+"/") {
+					return fmt.Errorf("refusing next page %s outside %s", m[1], githubAPI)
+				}
+				next = m[1]
+			}
+		}
+		if found {
+			return fmt.Errorf("GHCR package %s has no version with %s: %w", d.repository, what, errGHCRVersionNotFound)
+		}
+	}
+	return fmt.Errorf("GHCR package %s not found", d.repository)
+}
+
+func (d *tagDeleter) authorizeGitHub(req *http.Request) {
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if d.password != "" {
+		req.Header.Set("Authorization", "Bearer "+d.password)
+	}
+}
+
+// deleteHarbor deletes a tag with the Harbor API.
+func (d *tagDeleter) deleteHarbor(ctx context.Context, tag string) error {
+	return d.deleteHarborArtifact(ctx, tag, tag)
+}
+
+// deleteHarborArtifact deletes tag of the artifact reference, a tag or a
+// digest, with the Harbor API, or the artifact itself when tag is empty.
+// Repository names nested in a project are escaped twice, as Harbor
+// expects.
+func (d *tagDeleter) deleteHarborArtifact(ctx context.Context, reference, tag string) error {
+	project, repository, ok := strings.Cut(d.repository, "/")
+	if !ok {
+		return fmt.Errorf("invalid Harbor repository %s", d.repository)
+	}
+	client := d.p.newRegistryClient(d.cfg)
+	target := fmt.Sprintf("%s/api/v2.0/projects/%s/repositories/%s/artifacts/%s", client.baseURL,
+		url.PathEscape(project), url.PathEscape(url.PathEscape(repository)), url.PathEscape(reference))
+	if tag != "" {
+		target += "/tags/" + url.PathEscape(tag)
+	}
+	req, _ := http.NewRequest(http.MethodDelete, target, nil)
+	if d.username != "" && d.password != "" {
+		req.SetBasicAuth(d.username, d.password)
+	}
+	_, err := d.send(ctx, req, nil, http.StatusOK, http.StatusNotFound)
+	return err
+}
+
+// deleteOCI deletes a tag or a digest with the OCI distribution API.
+func (d *tagDeleter) deleteOCI(ctx context.Context, tag string) error {
+	client := d.p.newRegistryClient(d.cfg)
+	client.username, client.password = d.username, d.password
+	o := &ociPusher{client: client, repository: d.repository}
+	if err := o.authorize(ctx); err != nil {
+		return err
+	}
+	resp, err := o.do(ctx, http.MethodDelete, fmt.Sprintf("%s/v2/%s/manifests/%s", client.baseURL, d.repository, tag), nil, 0, "")
+	if err != nil {
+		return err
+	}
+	switch resp.StatusCode {
+	case http.StatusMethodNotAllowed, http.StatusBadRequest:
+		resp.Body.Close()
+		return errTagDeleteUnsupported
+	}
+	return expect(resp, "DELETE manifest "+tag, http.StatusAccepted, http.StatusOK, http.StatusNotFound)
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// stubDeleteAPIs points the Docker Hub and GitHub APIs at url.
+func stubDeleteAPIs(t *testing.T, url string) {
+	t.Helper()
+	hub, github := dockerHubAPI, githubAPI
+	dockerHubAPI, githubAPI = url, url
+	t.Cleanup(func() { dockerHubAPI, githubAPI = hub, github })
+}
+
+// routeTransport sends every request to srv, whatever its host, so the
+// registry and its deletion API can be served by one test server.
+type routeTransport struct{ srv *httptest.Server }
+
+func (rt routeTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	target, _ := url.Parse(rt.srv.URL)
+	r = r.Clone(r.Context())
+	r.URL.Scheme, r.URL.Host = target.Scheme, target.Host
+	return rt.srv.Client().Transport.RoundTrip(r)
+}
+
+// serveManifests answers the registry API requests of the tag deleter:
+// HEAD requests of the manifests of digests, keyed by tag or digest, and
+// the referrers listed for a digest.
+func serveManifests(w http.ResponseWriter, r *http.Request, digests map[string]string, referrers map[string][]string) bool {
+	path := strings.TrimPrefix(r.URL.Path, "/v2/myorg/myapp/")
+	switch {
+	case r.URL.Path == "/v2/":
+	case r.Method == http.MethodGet && path == "tags/list":
+		tags := make([]string, 0, len(digests))
+		for tag := range digests {
+			tags = append(tags, tag)
+		}
+		slices.Sort(tags)
+		_ = json.NewEncoder(w).Encode(map[string]any{"tags": tags})
+	case r.Method == http.MethodHead && strings.HasPrefix(path, "manifests/"):
+		digest, ok := digests[strings.TrimPrefix(path, "manifests/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return true
+		}
+		w.Header().Set("Docker-Content-Digest", digest)
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "referrers/"):
+		index := ociIndex{MediaType: ociIndexMediaType}
+		for _, digest := range referrers[strings.TrimPrefix(path, "referrers/")] {
+			index.Manifests = append(index.Manifests, ociDescriptor{MediaType: ociManifestMediaType, Digest: digest})
+		}
+		_ = json.NewEncoder(w).Encode(index)
+	default:
+		return false
+	}
+	return true
+}
+
+func TestRollbackDeletesTagsOnDockerHub(t *testing.T) {
+	var deleted []string
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if serveManifests(w, r, map[string]string{"1.3.0": "sha256:new", "1.3": "sha256:new", "latest": "sha256:old", "1.2.4": "sha256:old"}, nil) {
+			return
+		}
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v2/users/login":
+			_ = json.NewEncoder(w).Encode(map[string]string{"token": "jwt"})
+		case r.Method == http.MethodDelete && r.Header.Get("Authorization") == "Bearer jwt":
+			deleted = append(deleted, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+	stubDeleteAPIs(t, srv.URL)
+
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock, httpClient: &http.Client{Transport: routeTransport{srv}}}
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookOnError,
+		Config: map[string]any{
+			"image":                "myorg/myapp",
+			"username":             "bot",
+			"password":             "secret",
+			"tags":                 []any{"{{version}}", "{{major}}.{{minor}}", "latest"},
+			"rollback_on_error":    true,
+			"rollback_delete_tags": true,
+		},
+		Context: plugin.ReleaseContext{Version: "v1.3.0", PreviousVersion: "v1.2.4"},
+	})
+	if err != nil || !resp.Success {
+		t.Fatalf("rollback failed: %v %+v", err, resp)
+	}
+
+	want := []string{"/v2/repositories/myorg/myapp/tags/1.3.0/", "/v2/repositories/myorg/myapp/tags/1.3/"}
+	if !slices.Equal(deleted, want) {
+		t.Errorf("expected the version-specific tags to be deleted, got %v", deleted)
+	}
+	if refs, _ := resp.Outputs["restored_refs"].([]string); !slices.Equal(refs, []string{"myorg/myapp:latest"}) {
+		t.Errorf("expected latest to be pointed back, got %v", refs)
+	}
+}
+
+func TestTagDeleterGHCR(t *testing.T) {
+	var deleted []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/orgs/octo/packages/container/tools/app/versions" && r.Method == http.MethodGet:
+			_, _ = w.Write([]byte(`[{"id": 7, "metadata": {"container": {"tags": ["1.3.0"]}}}, {"id": 8, "metadata": {"container": {"tags": ["1.3", "latest"]}}}, {"id": 9, "name": "sha256:sbom"}]`))
+		case r.Method == http.MethodDelete:
+			deleted = append(deleted, r.URL.EscapedPath())
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	stubDeleteAPIs(t, srv.URL)
+
+	d := &tagDeleter{p: &DockerPlugin{httpClient: srv.Client()}, cfg: &Config{}, registryType: registryTypeGHCR, repository: "octo/tools/app", password: "token"}
+	ctx := context.Background()
+	if err := d.delete(ctx, "1.3.0", []string{"latest"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := d.delete(ctx, "1.3", []string{"latest"}); err == nil || !strings.Contains(err.Error(), "also carries latest") {
+		t.Errorf("expected a version shared with a kept tag not to be deleted, got %v", err)
+	}
+	if err := d.deleteDigest(ctx, "sha256:sbom"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"/orgs/octo/packages/container/tools%2Fapp/versions/7", "/orgs/octo/packages/container/tools%2Fapp/versions/9"}
+	if !slices.Equal(deleted, want) {
+		t.Errorf("expected versions 7 and 9 to be deleted, got %v", deleted)
+	}
+}
+
+func TestTagDeleterGHCRPagesVersions(t *testing.T) {
+	var deleted []string
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/orgs/octo/packages/container/app/versions" && r.Method == http.MethodGet:
+			if r.URL.Query().Get("page") == "2" {
+				_, _ = w.Write([]byte(`[{"id": 7, "metadata": {"container": {"tags": ["1.3.0"]}}}]`))
+				return
+			}
+			w.Header().Set("Link", fmt.Sprintf(`<%s/orgs/octo/packages/container/app/versions?per_page=100&page=2>; rel="next"`, srv.URL))
+			_, _ = w.Write([]byte(`[{"id": 8, "metadata": {"container": {"tags": ["1.2.0"]}}}]`))
+		case r.Method == http.MethodDelete:
+			deleted = append(deleted, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	stubDeleteAPIs(t, srv.URL)
+
+	d := &tagDeleter{p: &DockerPlugin{httpClient: srv.Client()}, cfg: &Config{}, registryType: registryTypeGHCR, repository: "octo/app", password: "token"}
+	ctx := context.Background()
+	if err := d.delete(ctx, "1.3.0", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(deleted, []string{"/orgs/octo/packages/container/app/versions/7"}) {
+		t.Errorf("expected the version on the second page to be deleted, got %v", deleted)
+	}
+	if err := d.delete(ctx, "9.9.9", nil); err == nil || !strings.Contains(err.Error(), "no version with tag 9.9.9") {
+		t.Errorf("expected a missing tag to fail, got %v", err)
+	}
+	if err := d.deleteDigest(ctx, "sha256:gone"); err != nil {
+		t.Errorf("expected a missing digest to be ignored, got %v", err)
+	}
+}
+
+func TestTagDeleterHarborAndOCI(t *testing.T) {
+	var paths []string
+	p, host := newTestRegistry(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/v2/"):
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case r.Method == http.MethodDelete:
+			paths = append(paths, r.URL.EscapedPath())
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	cfg := &Config{Registry: host, Image: "proj/team/app"}
+	ctx := context.Background()
+
+	harbor := &tagDeleter{p: p, cfg: cfg, registryType: registryTypeHarbor, repository: "proj/team/app"}
+	if err := harbor.delete(ctx, "1.3.0", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := harbor.deleteDigest(ctx, "sha256:sbom"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"/api/v2.0/projects/proj/repositories/team%252Fapp/artifacts/1.3.0/tags/1.3.0", "/api/v2.0/projects/proj/repositories/team%252Fapp/artifacts/sha256:sbom"}
+	if !slices.Equal(paths, want) {
+		t.Errorf("expected %v, got %v", want, paths)
+	}
+
+	generic := &tagDeleter{p: p, cfg: cfg, registryType: registryTypeGeneric, repository: "proj/team/app"}
+	if err := generic.delete(ctx, "1.3.0", nil); err != errTagDeleteUnsupported {
+		t.Errorf("expected a registry without tag deletion to be reported, got %v", err)
+	}
+}
+
+func TestRollbackKeepsTagsWithReferrers(t *testing.T) {
+	var deleted []string
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		digests := map[string]string{
+			"1.3.0": "sha256:new", "1.3": "sha256:new", "latest": "sha256:old", "1.2.4": "sha256:old",
+			"sha256-new.sig": "sha256:sig",
+		}
+		if serveManifests(w, r, digests, map[string][]string{"sha256:new": {"sha256:sbom"}}) {
+			return
+		}
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v2/users/login":
+			_ = json.NewEncoder(w).Encode(map[string]string{"token": "jwt"})
+		case r.Method == http.MethodDelete:
+			deleted = append(deleted, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	stubDeleteAPIs(t, srv.URL)
+
+	p := &DockerPlugin{executor: &MockCommandExecutor{}, httpClient: &http.Client{Transport: routeTransport{srv}}}
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookOnError,
+		Config: map[string]any{
+			"image":                "myorg/myapp",
+			"username":             "bot",
+			"password":             "secret",
+			"tags":                 []any{"{{version}}", "{{major}}.{{minor}}", "latest"},
+			"rollback_on_error":    true,
+			"rollback_delete_tags": true,
+		},
+		Context: plugin.ReleaseContext{Version: "v1.3.0", PreviousVersion: "v1.2.4"},
+	})
+	if err != nil || !resp.Success {
+		t.Fatalf("rollback failed: %v %+v", err, resp)
+	}
+	if len(deleted) != 0 {
+		t.Errorf("expected no deletion while referrers are attached, got %v", deleted)
+	}
+	warnings, _ := resp.Outputs["warnings"].([]string)
+	if len(warnings) != 2 || !strings.Contains(warnings[0], "would orphan sha256:sbom, which the registry cannot delete") {
+		t.Errorf("expected both tags to be kept for their referrers, got %v", warnings)
+	}
+}
+
+func TestRollbackDeletesReferrers(t *testing.T) {
+	var deleted []string
+	p, host := newTestRegistry(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		digests := map[string]string{
+			"1.3.0": "sha256:new", "latest": "sha256:old", "1.2.4": "sha256:old",
+			"sha256-new.sig": "sha256:sig", "sha256-new.att": "sha256:att", "sha256-old.sig": "sha256:oldsig",
+		}
+		if serveManifests(w, r, digests, map[string][]string{"sha256:new": {"sha256:sbom"}}) {
+			return
+		}
+		if r.Method == http.MethodDelete {
+			deleted = append(deleted, strings.TrimPrefix(r.URL.Path, "/v2/myorg/myapp/manifests/"))
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookOnError,
+		Config: map[string]any{
+			"image":                "myorg/myapp",
+			"registry":             host,
+			"registry_type":        "generic",
+			"tags":                 []any{"{{version}}", "latest"},
+			"rollback_on_error":    true,
+			"rollback_delete_tags": true,
+		},
+		Context: plugin.ReleaseContext{Version: "v1.3.0", PreviousVersion: "v1.2.4"},
+	})
+	if err != nil || !resp.Success {
+		t.Fatalf("rollback failed: %v %+v", err, resp)
+	}
+	if want := []string{"1.3.0", "sha256-new.sig", "sha256-new.att", "sha256:sbom"}; !slices.Equal(deleted, want) {
+		t.Errorf("expected the tag and then its referrers to be deleted, got %v", deleted)
+	}
+	repository := host + "/myorg/myapp"
+	want := []string{repository + ":1.3.0", repository + ":sha256-new.sig", repository + ":sha256-new.att", repository + "@sha256:sbom"}
+	if refs, _ := resp.Outputs["deleted_refs"].([]string); !slices.Equal(refs, want) {
+		t.Errorf("expected %v in deleted_refs, got %v", want, refs)
+	}
+}
+
+func TestTagDeleterReferrersSkipsKeptDigests(t *testing.T) {
+	p, host := newTestRegistry(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		digests := map[string]string{"1.3.0": "sha256:old", "1.2.4": "sha256:old", "sha256-old.sig": "sha256:sig"}
+		if !serveManifests(w, r, digests, nil) {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	d := &tagDeleter{p: p, cfg: &Config{Registry: host, Image: "myorg/myapp"}, repository: "myorg/myapp"}
+	attached, err := d.referrers(context.Background(), []string{"1.3.0", "missing"}, []string{"1.2.4"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(attached) != 0 {
+		t.Errorf("expected the signature of a kept digest not to block deletion, got %v", attached)
+	}
+}
+
+func TestTagDeleterReferrersSkipsDigestsWithOtherTags(t *testing.T) {
+	p, host := newTestRegistry(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		digests := map[string]string{"1.3.0": "sha256:new", "1.3.0-hotfix": "sha256:new", "sha256-new.sig": "sha256:sig"}
+		if !serveManifests(w, r, digests, nil) {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	d := &tagDeleter{p: p, cfg: &Config{Registry: host, Image: "myorg/myapp"}, repository: "myorg/myapp"}
+	attached, err := d.referrers(context.Background(), []string{"1.3.0"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(attached) != 0 {
+		t.Errorf("expected the signature of a digest still tagged 1.3.0-hotfix to be kept, got %v", attached)
+	}
+}
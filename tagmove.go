@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// versionPlaceholders make a tag specific to one release, as placeholders
+// or template fields; tags without them, such as latest, {{major}}.{{minor}}
+// or {{branch}}, move from release to release.
+var versionPlaceholders = []string{
+	"{{version}}", "{{patch}}", "{{prerelease}}", "{{sha}}", "{{short_sha}}", "{{date}}", "{{timestamp}}",
+	".Version", ".Patch", ".Prerelease", ".SHA", ".ShortSHA", ".Date", ".Timestamp", ".Time",
+}
+
+// movingTemplates returns the tag templates that move between releases.
+func movingTemplates(cfg *Config) []string {
+	templates := tagTemplates(cfg)
+
+	var moving []string
+	for _, template := range templates {
+		if containsAny(template, versionPlaceholders) {
+			continue
+		}
+		moving = append(moving, template)
+	}
+	return moving
+}
+
+// movingRefs returns the release references whose tags move between
+// releases.
+func movingRefs(cfg *Config, releaseVersion string) ([]string, error) {
+	moving := movingTemplates(cfg)
+	if len(moving) == 0 {
+		return nil, nil
+	}
+
+	tags, err := resolveTags(moving, releaseVersion, cfg.tagContext)
+	if err != nil {
+		return nil, err
+	}
+	repository := imageRepository(cfg)
+	refs := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if slices.Contains(cfg.heldTags, tag) {
+			continue
+		}
+		refs = append(refs, fmt.Sprintf("%s:%s", repository, tag))
+	}
+	return refs, nil
+}
+
+// containsAny reports whether s contains any of substrs.
+func containsAny(s string, substrs []string) bool {
+	for _, substr := range substrs {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// rollbackCommand returns the command that points ref back at digest.
+func rollbackCommand(ref, digest string) string {
+	repository, _, _ := strings.Cut(ref, "@")
+	if i := strings.LastIndex(repository, ":"); i > strings.LastIndex(repository, "/") {
+		repository = repository[:i]
+	}
+	return fmt.Sprintf("docker buildx imagetools create --tag %s %s@%s", ref, repository, digest)
+}
+
+// recordTagMoves resolves the digest each moving tag points at before the
+// release overwrites it, and records it in outputs with a rollback command.
+// It returns a warning per moved tag. Tags that do not exist yet are not
+// moves and are skipped.
+func (p *DockerPlugin) recordTagMoves(ctx context.Context, cfg *Config, releaseVersion string, outputs *Outputs) []string {
+	refs, err := movingRefs(cfg, releaseVersion)
+	if err != nil {
+		return nil
+	}
+
+	var warnings []string
+	for _, ref := range refs {
+		digest, err := p.resolveDigest(ctx, ref)
+		if err != nil {
+			continue
+		}
+		if outputs.PreviousDigests == nil {
+			outputs.PreviousDigests = make(map[string]string)
+		}
+		outputs.PreviousDigests[ref] = digest
+		command := rollbackCommand(ref, digest)
+		outputs.RollbackCommands = append(outputs.RollbackCommands, command)
+		warnings = append(warnings, fmt.Sprintf("moving tag %s previously pointed at %s; to roll back run: %s", ref, digest, command))
+	}
+	return warnings
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestMovingRefs(t *testing.T) {
+	tests := []struct {
+		name     string
+		tags     []string
+		expected string
+	}{
+		{"default tags", nil, "ghcr.io/org/app:latest"},
+		{"major and minor", []string{"{{version}}", "{{major}}", "{{major}}.{{minor}}", "v{{major}}.{{minor}}.{{patch}}"}, "ghcr.io/org/app:1 ghcr.io/org/app:1.2"},
+		{"version only", []string{"{{version}}"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refs, err := movingRefs(&Config{Registry: "ghcr.io", Image: "org/app", Tags: tt.tags}, "1.2.3")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := strings.Join(refs, " "); got != tt.expected {
+				t.Errorf("movingRefs() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRollbackCommand(t *testing.T) {
+	got := rollbackCommand("localhost:5000/app:latest", testDigest)
+	want := "docker buildx imagetools create --tag localhost:5000/app:latest localhost:5000/app@" + testDigest
+	if got != want {
+		t.Errorf("rollbackCommand() = %q, want %q", got, want)
+	}
+}
+
+func TestExecuteRecordsTagMoves(t *testing.T) {
+	mock := &MockCommandExecutor{
+		OutputFunc: func(_ context.Context, _ string, args []string) ([]byte, error) {
+			if containsArg(args, "--format", "{{.Manifest.Digest}}") && args[len(args)-1] == "myapp:latest" {
+				return []byte(testDigest + "\n"), nil
+			}
+			return nil, errors.New("not found")
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"image": "myapp", "tags": []any{"{{version}}", "latest", "{{major}}"}},
+		Context: plugin.ReleaseContext{Version: "2.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+
+	previous, _ := resp.Outputs["previous_digests"].(map[string]string)
+	if len(previous) != 1 || previous["myapp:latest"] != testDigest {
+		t.Errorf("expected previous digest of latest only, got %v", resp.Outputs["previous_digests"])
+	}
+	commands, _ := resp.Outputs["rollback_commands"].([]string)
+	if len(commands) != 1 || !strings.HasSuffix(commands[0], "myapp@"+testDigest) {
+		t.Errorf("unexpected rollback commands: %v", commands)
+	}
+	warnings, _ := resp.Outputs["warnings"].([]string)
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "myapp:latest") {
+		t.Errorf("expected tag move warning, got %v", warnings)
+	}
+}
+
+func TestExecuteNoTagMovesWithoutPush(t *testing.T) {
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"image": "myapp", "push": false},
+		Context: plugin.ReleaseContext{Version: "2.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := resp.Outputs["previous_digests"]; ok {
+		t.Errorf("expected no previous digests without push, got %v", resp.Outputs["previous_digests"])
+	}
+	for _, call := range mock.OutputCalls {
+		if containsFlag(call.Args, "imagetools") {
+			t.Errorf("expected no registry lookups, got %v", call.Args)
+		}
+	}
+}
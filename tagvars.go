@@ -0,0 +1,323 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// tagUnsafeChars matches characters a tag cannot contain.
+var tagUnsafeChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// Policies of tag_sanitize.
+const (
+	tagSanitizeReplace = "replace"
+	tagSanitizeStrict  = "strict"
+	tagSanitizeFail    = "fail"
+)
+
+// maxTagLength is the longest tag registries accept.
+const maxTagLength = 128
+
+// shortSHALength is the length of {{short_sha}}, as git abbreviates.
+const shortSHALength = 7
+
+// tagContext holds the values of the tag placeholders that come from the
+// release rather than its version.
+type tagContext struct {
+	sha             string
+	branch          string
+	releaseType     string
+	previousVersion string
+	// time is when the release ran, for {{date}} and {{timestamp}}.
+	time time.Time
+	// sanitize is the tag_sanitize policy; empty means replace.
+	sanitize string
+}
+
+// newTagContext returns the tag context of a release run at now.
+func newTagContext(releaseCtx plugin.ReleaseContext, now time.Time) tagContext {
+	return tagContext{
+		sha:             strings.ToLower(strings.TrimSpace(releaseCtx.CommitSHA)),
+		branch:          sanitizeTagValue(releaseCtx.Branch),
+		releaseType:     releaseCtx.ReleaseType,
+		previousVersion: strings.TrimPrefix(releaseCtx.PreviousVersion, "v"),
+		time:            now.UTC(),
+	}
+}
+
+// sanitizeTagValue replaces the characters a tag cannot contain, such as
+// the slashes of feature/login, with dashes.
+func sanitizeTagValue(value string) string {
+	return strings.Trim(tagUnsafeChars.ReplaceAllString(value, "-"), ".-")
+}
+
+// sanitizeVersion applies the tag_sanitize policy to a version used in tags:
+// the + of build metadata becomes _, other characters a tag cannot contain
+// become -, and the value is cut to the length registries accept. With
+// fail, the value is left as is and tags using it fail validation.
+func sanitizeVersion(value, policy string) string {
+	if policy == tagSanitizeFail {
+		return value
+	}
+	value = strings.ReplaceAll(value, "+", "_")
+	value = strings.TrimLeft(tagUnsafeChars.ReplaceAllString(value, "-"), "._-")
+	if len(value) > maxTagLength {
+		value = strings.TrimRight(value[:maxTagLength], ".-")
+	}
+	return value
+}
+
+// validateTagSanitize checks the tag_sanitize policy.
+func validateTagSanitize(policy string) error {
+	switch policy {
+	case "", tagSanitizeReplace, tagSanitizeStrict, tagSanitizeFail:
+		return nil
+	}
+	return fmt.Errorf("tag_sanitize must be %s, %s or %s", tagSanitizeReplace, tagSanitizeStrict, tagSanitizeFail)
+}
+
+// splitPrerelease splits a version such as 1.4.0-rc.1+build.5 into its core
+// version and prerelease identifiers; build metadata is dropped.
+func splitPrerelease(version string) (core, prerelease string) {
+	version, _, _ = strings.Cut(version, "+")
+	core, prerelease, _ = strings.Cut(version, "-")
+	return core, prerelease
+}
+
+// releaseChannel returns the channel of a prerelease, its first identifier
+// (rc for 1.4.0-rc.1), or stable.
+func releaseChannel(prerelease string) string {
+	if prerelease == "" {
+		return "stable"
+	}
+	channel, _, _ := strings.Cut(prerelease, ".")
+	return strings.ToLower(channel)
+}
+
+// tagData is what tag templates are evaluated with, e.g.
+// {{if not .IsPrerelease}}latest{{end}}.
+type tagData struct {
+	Version         string
+	Major           string
+	Minor           string
+	Patch           string
+	Prerelease      string
+	IsPrerelease    bool
+	Channel         string
+	SHA             string
+	ShortSHA        string
+	Branch          string
+	Date            string
+	Timestamp       string
+	ReleaseType     string
+	PreviousVersion string
+	// Time is when the release ran, for layouts such as
+	// {{.Time.Format "2006.01"}}.
+	Time time.Time
+}
+
+// data returns the template data of a release.
+func (tc tagContext) data(releaseVersion string) tagData {
+	version := strings.TrimPrefix(releaseVersion, "v")
+	core, prerelease := splitPrerelease(version)
+	if tc.sanitize == tagSanitizeStrict {
+		version, _, _ = strings.Cut(version, "+")
+	}
+	version = sanitizeVersion(version, tc.sanitize)
+	parts := append(strings.Split(core, "."), "", "", "")
+
+	data := tagData{
+		Version:         version,
+		Major:           parts[0],
+		Minor:           parts[1],
+		Patch:           parts[2],
+		Prerelease:      sanitizeTagValue(prerelease),
+		IsPrerelease:    prerelease != "",
+		Channel:         sanitizeTagValue(releaseChannel(prerelease)),
+		SHA:             tc.sha,
+		ShortSHA:        tc.sha,
+		Branch:          tc.branch,
+		ReleaseType:     tc.releaseType,
+		PreviousVersion: sanitizeVersion(tc.previousVersion, tc.sanitize),
+		Time:            tc.time,
+	}
+	if len(tc.sha) > shortSHALength {
+		data.ShortSHA = tc.sha[:shortSHALength]
+	}
+	if !tc.time.IsZero() {
+		data.Date = tc.time.Format("20060102")
+		data.Timestamp = tc.time.Format("20060102150405")
+	}
+	return data
+}
+
+// placeholders returns the values of the original placeholders, such as
+// {{version}}, which templates call as functions. The placeholders taken
+// from the release context, rather than its version, report whether they
+// were used without a value.
+func (d tagData) placeholders() (version, release map[string]string) {
+	version = map[string]string{
+		"version": d.Version,
+		"major":   d.Major,
+		"minor":   d.Minor,
+		"patch":   d.Patch,
+	}
+	release = map[string]string{
+		"prerelease": d.Prerelease,
+		"channel":    d.Channel,
+		"sha":        d.SHA,
+		"short_sha":  d.ShortSHA,
+		"branch":     d.Branch,
+		"date":       d.Date,
+		"timestamp":  d.Timestamp,
+	}
+	return version, release
+}
+
+// tagFuncs returns the helpers of tag templates. They follow sprig, so
+// arguments come in the order that suits pipelines:
+// {{.Branch | trimPrefix "release-" | trunc 20}}.
+func tagFuncs() template.FuncMap {
+	return template.FuncMap{
+		"lower":      strings.ToLower,
+		"upper":      strings.ToUpper,
+		"trim":       strings.TrimSpace,
+		"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+		"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+		"replace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+		"contains":   func(substr, s string) bool { return strings.Contains(s, substr) },
+		"hasPrefix":  func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+		"hasSuffix":  func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+		"trunc": func(n int, s string) string {
+			switch {
+			case n >= 0 && len(s) > n:
+				return s[:n]
+			case n < 0 && len(s) > -n:
+				return s[len(s)+n:]
+			}
+			return s
+		},
+		"default": func(def, given string) string {
+			if given == "" {
+				return def
+			}
+			return given
+		},
+		"regexReplaceAll": func(pattern, s, repl string) (string, error) {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return "", err
+			}
+			return re.ReplaceAllString(s, repl), nil
+		},
+	}
+}
+
+// renderTag evaluates a tag template. It returns an empty tag when the
+// template renders to whitespace or outputs a placeholder of the release
+// context without a value, as {{branch}} does on a detached checkout.
+// Placeholders that are only arguments or conditions, as in
+// {{default "main" branch}} or {{if prerelease}}, may be empty.
+func renderTag(tag string, data tagData) (string, error) {
+	if !strings.Contains(tag, "{{") {
+		return tag, nil
+	}
+
+	missing := false
+	funcs := tagFuncs()
+	version, release := data.placeholders()
+	for name, value := range version {
+		funcs[name] = func() string { return value }
+	}
+	for name, value := range release {
+		funcs[name] = func() string { return value }
+		funcs[barePlaceholder(name)] = func() string {
+			missing = missing || value == ""
+			return value
+		}
+	}
+
+	tmpl, err := template.New("tag").Funcs(funcs).Parse(tag)
+	if err != nil {
+		return "", err
+	}
+	markBarePlaceholders(tmpl.Tree.Root, release)
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	if missing {
+		return "", nil
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// barePlaceholder returns the name renderTag calls a placeholder under when
+// the template outputs it as is.
+func barePlaceholder(name string) string {
+	return "bare_" + name
+}
+
+// markBarePlaceholders makes the actions of node that output one of
+// placeholders as is, such as {{branch}}, call its barePlaceholder, which
+// reports an empty value. Only the actions a release reaches run, so
+// {{if branch}}{{branch}}{{else}}main{{end}} renders main without a branch.
+func markBarePlaceholders(node parse.Node, placeholders map[string]string) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			markBarePlaceholders(child, placeholders)
+		}
+	case *parse.ActionNode:
+		if len(n.Pipe.Decl) != 0 || len(n.Pipe.Cmds) != 1 || len(n.Pipe.Cmds[0].Args) != 1 {
+			return
+		}
+		if ident, ok := n.Pipe.Cmds[0].Args[0].(*parse.IdentifierNode); ok {
+			if _, ok := placeholders[ident.Ident]; ok {
+				ident.Ident = barePlaceholder(ident.Ident)
+			}
+		}
+	case *parse.IfNode:
+		markBarePlaceholders(n.List, placeholders)
+		markBarePlaceholders(n.ElseList, placeholders)
+	case *parse.RangeNode:
+		markBarePlaceholders(n.List, placeholders)
+		markBarePlaceholders(n.ElseList, placeholders)
+	case *parse.WithNode:
+		markBarePlaceholders(n.List, placeholders)
+		markBarePlaceholders(n.ElseList, placeholders)
+	}
+}
+
+// sampleTagContext is a release context with every value set, to check tag
+// templates before one runs.
+var sampleTagContext = tagContext{
+	sha:             "0123456789abcdef0123456789abcdef01234567",
+	branch:          "main",
+	releaseType:     "minor",
+	previousVersion: "1.3.0",
+	time:            time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC),
+}
+
+// sampleTagData is a release with every value set.
+var sampleTagData = sampleTagContext.data("1.4.0-rc.1")
+
+// validateTagTemplates checks that tags are valid templates that only use
+// supported placeholders, fields and helpers.
+func validateTagTemplates(tags []string) error {
+	for _, tag := range tags {
+		if _, err := renderTag(tag, sampleTagData); err != nil {
+			return fmt.Errorf("invalid tag template %q: %v", tag, err)
+		}
+	}
+	return nil
+}
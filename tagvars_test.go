@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestResolveTagPlaceholders(t *testing.T) {
+	tc := newTagContext(plugin.ReleaseContext{
+		CommitSHA: "ABC1234DEF5678",
+		Branch:    "feature/login",
+	}, time.Date(2026, time.October, 14, 9, 30, 5, 0, time.UTC))
+
+	tags, err := resolveTags([]string{
+		"{{version}}-{{short_sha}}",
+		"{{major}}.{{minor}}.{{patch}}",
+		"{{prerelease}}",
+		"{{channel}}",
+		"{{branch}}",
+		"nightly-{{date}}",
+		"{{timestamp}}",
+		"sha-{{sha}}",
+	}, "v1.4.0-rc.1", tc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"1.4.0-rc.1-abc1234", "1.4.0", "rc.1", "rc", "feature-login", "nightly-20261014", "20261014093005", "sha-abc1234def5678"}
+	if !slices.Equal(tags, want) {
+		t.Errorf("resolveTags() = %v, want %v", tags, want)
+	}
+}
+
+func TestResolveTagsDropsMissingValues(t *testing.T) {
+	tags, err := resolveTags([]string{"{{version}}", "{{version}}-{{short_sha}}", "{{prerelease}}", "{{channel}}"}, "2.0.0", tagContext{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(tags, []string{"2.0.0", "stable"}) {
+		t.Errorf("expected tags without values to be dropped, got %v", tags)
+	}
+}
+
+func TestResolveTagsKeepsEmptyPlaceholderArguments(t *testing.T) {
+	tags, err := resolveTags([]string{
+		`{{version}}-{{ default "main" branch }}`,
+		"{{if prerelease}}{{prerelease}}{{else}}stable-{{version}}{{end}}",
+		"{{if branch}}{{branch}}{{else}}detached{{end}}",
+		"{{version}}-{{branch}}",
+	}, "1.2.3", tagContext{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"1.2.3-main", "stable-1.2.3", "detached"}; !slices.Equal(tags, want) {
+		t.Errorf("resolveTags() = %v, want %v", tags, want)
+	}
+}
+
+func TestExecuteFailsWithoutTags(t *testing.T) {
+	for _, platforms := range [][]any{nil, {"linux/amd64", "linux/arm64"}} {
+		mock := &MockCommandExecutor{}
+		p := &DockerPlugin{executor: mock}
+		resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+			Hook:    plugin.HookPostPublish,
+			Config:  map[string]any{"image": "myapp", "tags": []any{"{{prerelease}}"}, "platforms": platforms},
+			Context: plugin.ReleaseContext{Version: "1.0.0"},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.Success || !strings.Contains(resp.Error, "no tags to push") {
+			t.Errorf("platforms %v: expected no tags to be reported, got %+v", platforms, resp)
+		}
+		if len(mock.RunCalls) != 0 {
+			t.Errorf("platforms %v: expected nothing to run, got %v", platforms, mock.RunCalls)
+		}
+	}
+}
+
+func TestValidateTagTemplates(t *testing.T) {
+	if err := validateTagTemplates([]string{"{{version}}-{{short_sha}}", "{{channel}}", "latest"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := validateTagTemplates([]string{"{{commit}}"}); err == nil || !strings.Contains(err.Error(), `function "commit" not defined`) {
+		t.Errorf("expected an unknown placeholder to be rejected, got %v", err)
+	}
+	if err := validateTagTemplates([]string{"{{.Commit}}"}); err == nil || !strings.Contains(err.Error(), "can't evaluate field Commit") {
+		t.Errorf("expected an unknown field to be rejected, got %v", err)
+	}
+	if err := validateTagTemplates([]string{"{{if .IsPrerelease}}"}); err == nil {
+		t.Errorf("expected an unterminated conditional to be rejected")
+	}
+}
+
+func TestResolveTagConditionals(t *testing.T) {
+	tc := newTagContext(plugin.ReleaseContext{Branch: "release/2.x", ReleaseType: "minor"}, time.Date(2026, time.October, 14, 0, 0, 0, 0, time.UTC))
+	tags := []string{
+		"{{version}}",
+		"{{if not .IsPrerelease}}latest{{end}}",
+		"{{if .IsPrerelease}}{{.Channel}}{{else}}{{.Major}}.{{.Minor}}{{end}}",
+		"{{.Branch | trimPrefix \"release-\" | upper}}",
+		"{{if eq .ReleaseType \"major\"}}breaking{{end}}",
+		"build-{{.Time.Format \"2006.01\"}}",
+	}
+
+	stable, err := resolveTags(tags, "v2.1.0", tc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"2.1.0", "latest", "2.1", "2.X", "build-2026.10"}; !slices.Equal(stable, want) {
+		t.Errorf("resolveTags() = %v, want %v", stable, want)
+	}
+
+	prerelease, err := resolveTags(tags, "v2.1.0-beta.2", tc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"2.1.0-beta.2", "beta", "2.X", "build-2026.10"}; !slices.Equal(prerelease, want) {
+		t.Errorf("resolveTags() = %v, want %v", prerelease, want)
+	}
+}
+
+func TestTagFuncs(t *testing.T) {
+	data := tagContext{sha: "abc1234def"}.data("1.2.3")
+	tests := []struct {
+		tag  string
+		want string
+	}{
+		{`{{.SHA | trunc 4}}`, "abc1"},
+		{`{{.SHA | trunc -3}}`, "def"},
+		{`{{.Branch | default "detached"}}`, "detached"},
+		{`{{.Version | replace "." "_"}}`, "1_2_3"},
+		{`{{regexReplaceAll "[0-9]+$" .Version "x"}}`, "1.2.x"},
+		{`{{if hasPrefix "1." .Version}}one{{end}}`, "one"},
+		{`{{ version }}`, "1.2.3"},
+	}
+	for _, tt := range tests {
+		got, err := renderTag(tt.tag, data)
+		if err != nil {
+			t.Errorf("renderTag(%q): %v", tt.tag, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("renderTag(%q) = %q, want %q", tt.tag, got, tt.want)
+		}
+	}
+}
+
+func TestMovingTemplates(t *testing.T) {
+	moving := movingTemplates(&Config{Tags: []string{"{{version}}", "{{short_sha}}", "{{branch}}", "{{channel}}", "latest"}})
+	if !slices.Equal(moving, []string{"{{branch}}", "{{channel}}", "latest"}) {
+		t.Errorf("unexpected moving tags: %v", moving)
+	}
+}
+
+func TestResolveTagsSanitize(t *testing.T) {
+	tests := []struct {
+		policy  string
+		want    []string
+		wantErr bool
+	}{
+		{"", []string{"1.2.3-rc.1_build.5", "1.2.3-rc.1_build.5-alpine", "1"}, false},
+		{tagSanitizeReplace, []string{"1.2.3-rc.1_build.5", "1.2.3-rc.1_build.5-alpine", "1"}, false},
+		{tagSanitizeStrict, []string{"1.2.3-rc.1", "1.2.3-rc.1-alpine", "1"}, false},
+		{tagSanitizeFail, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			tags, err := resolveTags([]string{"{{version}}", "{{.Version}}-alpine", "{{major}}"}, "v1.2.3-rc.1+build.5", tagContext{sanitize: tt.policy})
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "disallowed characters") {
+					t.Errorf("expected the build metadata to be rejected, got %v, %v", tags, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(tags, tt.want) {
+				t.Errorf("resolveTags() = %v, want %v", tags, tt.want)
+			}
+		})
+	}
+
+	if err := validateTagSanitize("lenient"); err == nil {
+		t.Errorf("expected an unknown policy to be rejected")
+	}
+}
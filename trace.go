@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// vertexStagePattern captures the bracketed prefix BuildKit gives the
+	// steps of a Dockerfile stage, e.g. [builder 3/5] or [linux/arm64 builder 3/5].
+	vertexStagePattern = regexp.MustCompile(`^\[([^\]]+)\]`)
+
+	// stepCountPattern matches the step position in a vertex prefix.
+	stepCountPattern = regexp.MustCompile(`^[0-9]+/[0-9]+$`)
+)
+
+// traceVertex is a build step in BuildKit's JSON progress.
+type traceVertex struct {
+	Digest    string     `json:"digest"`
+	Name      string     `json:"name"`
+	Started   *time.Time `json:"started"`
+	Completed *time.Time `json:"completed"`
+	Cached    bool       `json:"cached"`
+	Error     string     `json:"error"`
+}
+
+// traceLog is output of a build step in BuildKit's JSON progress.
+type traceLog struct {
+	Vertex string `json:"vertex"`
+	Data   []byte `json:"data"`
+}
+
+// solveStatus is one line of docker buildx build --progress rawjson.
+type solveStatus struct {
+	Vertexes []traceVertex `json:"vertexes"`
+	Logs     []traceLog    `json:"logs"`
+}
+
+// BuildStageTiming reports the time spent in one Dockerfile stage. Steps
+// outside any stage, such as exporting and pushing the image, are reported
+// as the export stage.
+type BuildStageTiming struct {
+	Stage       string `json:"stage"`
+	DurationMS  int64  `json:"duration_ms"`
+	Steps       int    `json:"steps"`
+	CachedSteps int    `json:"cached_steps"`
+}
+
+// CacheReport summarizes how much of a build was served from the cache.
+// Only Dockerfile steps count, except FROM, whose cache state reflects the
+// base images on the builder rather than the build cache.
+type CacheReport struct {
+	Steps       int `json:"steps"`
+	CachedSteps int `json:"cached_steps"`
+	// HitRate is the percentage of steps served from the cache.
+	HitRate float64 `json:"hit_rate"`
+}
+
+// buildTrace collects the steps of a build from its JSON progress and
+// renders them to log in the style of --progress plain, so build logs stay
+// readable.
+type buildTrace struct {
+	log io.Writer
+
+	buf      []byte
+	order    []string
+	vertices map[string]*traceVertex
+	numbers  map[string]int
+}
+
+// newBuildTrace returns a trace rendering the build to log.
+func newBuildTrace(log io.Writer) *buildTrace {
+	return &buildTrace{log: log, vertices: make(map[string]*traceVertex), numbers: make(map[string]int)}
+}
+
+// buildTraceFor returns the trace of a build rendering to the standard
+// error, or nil when neither build_timings nor cache_hit_threshold is set
+// and the rawjson_progress feature does not apply to the build.
+func buildTraceFor(cfg *Config) *buildTrace {
+	rawJSON := cfg.rawJSONProgress && useBuildx(cfg)
+	if !cfg.BuildTimings && cfg.CacheHitThreshold == 0 && !rawJSON {
+		return nil
+	}
+	return newBuildTrace(os.Stderr)
+}
+
+// validateBuildTimings checks that build_timings is used with buildx, the
+// only builder reporting JSON progress.
+func validateBuildTimings(cfg *Config) error {
+	if cfg.BuildTimings && !useBuildx(cfg) {
+		return fmt.Errorf("build_timings requires builder")
+	}
+	return nil
+}
+
+// validateCacheHitThreshold checks cache_hit_threshold, which also needs the
+// JSON progress of buildx.
+func validateCacheHitThreshold(cfg *Config) error {
+	if cfg.CacheHitThreshold < 0 || cfg.CacheHitThreshold > 100 {
+		return fmt.Errorf("cache_hit_threshold must be between 0 and 100")
+	}
+	if cfg.CacheHitThreshold > 0 && !useBuildx(cfg) {
+		return fmt.Errorf("cache_hit_threshold requires builder")
+	}
+	return nil
+}
+
+// setBuildTrace records the stage timings and cache report of the first
+// traced build. A push after the approval gate rebuilds from the builder
+// cache, so its trace would hide where the build spent its time.
+func (o *Outputs) setBuildTrace(cfg *Config, t *buildTrace) {
+	if t == nil || o.Cache != nil {
+		return
+	}
+	if cfg.BuildTimings {
+		o.BuildStages = t.stages()
+	}
+	o.Cache = t.cacheReport()
+}
+
+// Write consumes the standard error of the build line by line.
+func (t *buildTrace) Write(p []byte) (int, error) {
+	t.buf = append(t.buf, p...)
+	for {
+		i := bytes.IndexByte(t.buf, '\n')
+		if i < 0 {
+			break
+		}
+		t.line(t.buf[:i])
+		t.buf = t.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// flush consumes a final line without a newline.
+func (t *buildTrace) flush() {
+	if len(t.buf) > 0 {
+		t.line(t.buf)
+		t.buf = nil
+	}
+}
+
+// line records a progress update. Other output, such as the final error of
+// buildx, is passed through.
+func (t *buildTrace) line(line []byte) {
+	var status solveStatus
+	if len(line) == 0 || line[0] != '{' || json.Unmarshal(line, &status) != nil {
+		fmt.Fprintf(t.log, "%s\n", line)
+		return
+	}
+
+	for _, update := range status.Vertexes {
+		v, seen := t.vertices[update.Digest]
+		if !seen {
+			v = &traceVertex{Digest: update.Digest, Name: update.Name}
+			t.vertices[update.Digest] = v
+			t.order = append(t.order, update.Digest)
+		}
+		if update.Started != nil && v.Started == nil {
+			v.Started = update.Started
+			fmt.Fprintf(t.log, "#%d %s\n", t.number(v.Digest), v.Name)
+		}
+		if update.Cached && !v.Cached {
+			v.Cached = true
+			fmt.Fprintf(t.log, "#%d CACHED\n", t.number(v.Digest))
+		}
+		if update.Error != "" && v.Error == "" {
+			v.Error = update.Error
+			fmt.Fprintf(t.log, "#%d ERROR: %s\n", t.number(v.Digest), v.Error)
+		}
+		if update.Completed != nil && v.Completed == nil {
+			v.Completed = update.Completed
+			if !v.Cached && v.Error == "" && v.Started != nil {
+				fmt.Fprintf(t.log, "#%d DONE %.1fs\n", t.number(v.Digest), v.Completed.Sub(*v.Started).Seconds())
+			}
+		}
+	}
+	for _, l := range status.Logs {
+		for _, text := range strings.SplitAfter(string(l.Data), "\n") {
+			if text != "" {
+				fmt.Fprintf(t.log, "#%d %s\n", t.number(l.Vertex), strings.TrimSuffix(text, "\n"))
+			}
+		}
+	}
+}
+
+// number returns the position of the step in the rendered log.
+func (t *buildTrace) number(digest string) int {
+	if n, ok := t.numbers[digest]; ok {
+		return n
+	}
+	n := len(t.numbers) + 1
+	t.numbers[digest] = n
+	return n
+}
+
+// vertexStage returns the Dockerfile stage of a step from its name. The only
+// stage of a single-stage Dockerfile is unnamed and reported as stage-0.
+func vertexStage(name string) string {
+	m := vertexStagePattern.FindStringSubmatch(name)
+	if m == nil {
+		return "export"
+	}
+	fields := strings.Fields(m[1])
+	if n := len(fields); n > 0 && stepCountPattern.MatchString(fields[n-1]) {
+		fields = fields[:n-1]
+	}
+	if len(fields) > 0 && strings.Contains(fields[0], "/") {
+		fields = fields[1:]
+	}
+	if len(fields) == 0 {
+		return "stage-0"
+	}
+	return strings.Join(fields, " ")
+}
+
+// stages reports the time of every stage, from the start of its first step
+// to the end of its last, slowest stage first.
+func (t *buildTrace) stages() []BuildStageTiming {
+	type span struct {
+		timing           BuildStageTiming
+		started, stopped time.Time
+	}
+	var names []string
+	spans := make(map[string]*span)
+	for _, digest := range t.order {
+		v := t.vertices[digest]
+		stage := vertexStage(v.Name)
+		s, ok := spans[stage]
+		if !ok {
+			s = &span{timing: BuildStageTiming{Stage: stage}}
+			spans[stage] = s
+			names = append(names, stage)
+		}
+		s.timing.Steps++
+		if v.Cached {
+			s.timing.CachedSteps++
+		}
+		if v.Started != nil && (s.started.IsZero() || v.Started.Before(s.started)) {
+			s.started = *v.Started
+		}
+		if v.Completed != nil && v.Completed.After(s.stopped) {
+			s.stopped = *v.Completed
+		}
+	}
+
+	timings := make([]BuildStageTiming, 0, len(names))
+	for _, name := range names {
+		s := spans[name]
+		if !s.started.IsZero() && s.stopped.After(s.started) {
+			s.timing.DurationMS = s.stopped.Sub(s.started).Milliseconds()
+		}
+		timings = append(timings, s.timing)
+	}
+	sort.SliceStable(timings, func(i, j int) bool { return timings[i].DurationMS > timings[j].DurationMS })
+	return timings
+}
+
+// cacheReport counts the Dockerfile steps served from the cache.
+func (t *buildTrace) cacheReport() *CacheReport {
+	report := &CacheReport{}
+	for _, digest := range t.order {
+		v := t.vertices[digest]
+		if stage := vertexStage(v.Name); stage == "internal" || stage == "export" {
+			continue
+		}
+		if _, step, _ := strings.Cut(v.Name, "] "); strings.HasPrefix(step, "FROM ") {
+			continue
+		}
+		report.Steps++
+		if v.Cached {
+			report.CachedSteps++
+		}
+	}
+	if report.Steps > 0 {
+		report.HitRate = float64(report.CachedSteps) * 100 / float64(report.Steps)
+	}
+	return report
+}
+
+// cacheHitWarning reports a cache hit rate below cache_hit_threshold, the
+// usual sign of a cache_from source that cannot be read. Builds with
+// no_cache are expected to miss.
+func cacheHitWarning(cfg *Config, report *CacheReport) string {
+	if cfg.CacheHitThreshold == 0 || cfg.NoCache || report == nil || report.Steps == 0 || report.HitRate >= cfg.CacheHitThreshold {
+		return ""
+	}
+	warning := fmt.Sprintf("build cache hit rate %.0f%% (%d of %d steps) is below cache_hit_threshold %.0f%%",
+		report.HitRate, report.CachedSteps, report.Steps, cfg.CacheHitThreshold)
+	if len(cfg.CacheFrom) > 0 {
+		return warning + fmt.Sprintf("; check that cache_from %s is readable and was exported with the cache of a previous build", strings.Join(cfg.CacheFrom, ", "))
+	}
+	return warning + "; set cache_from to reuse the cache of previous releases"
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// testTrace is the JSON progress of a two-stage build whose builder stage
+// ran for 40 seconds with its dependency download cached.
+const testTrace = `{"vertexes":[{"digest":"sha256:a","name":"[internal] load build definition from Dockerfile","started":"2026-01-01T00:00:00Z","completed":"2026-01-01T00:00:01Z"}]}
+{"vertexes":[{"digest":"sha256:b","name":"[builder 2/3] RUN go mod download","started":"2026-01-01T00:00:01Z","completed":"2026-01-01T00:00:01Z","cached":true}]}
+{"vertexes":[{"digest":"sha256:c","name":"[builder 3/3] RUN go build ./...","started":"2026-01-01T00:00:01Z"}]}
+{"logs":[{"vertex":"sha256:c","data":"Y29tcGlsaW5nCmRvbmUK"}]}
+{"vertexes":[{"digest":"sha256:c","name":"[builder 3/3] RUN go build ./...","started":"2026-01-01T00:00:01Z","completed":"2026-01-01T00:00:41Z"}]}
+{"vertexes":[{"digest":"sha256:d","name":"[stage-1 2/2] COPY --from=builder /app /app","started":"2026-01-01T00:00:41Z","completed":"2026-01-01T00:00:43Z"}]}
+{"vertexes":[{"digest":"sha256:e","name":"exporting to image","started":"2026-01-01T00:00:43Z","completed":"2026-01-01T00:00:48Z"}]}
+`
+
+func TestVertexStage(t *testing.T) {
+	tests := map[string]string{
+		"[builder 3/5] RUN go build":             "builder",
+		"[linux/arm64 builder 3/5] RUN go build": "builder",
+		"[2/3] COPY . .":                         "stage-0",
+		"[internal] load .dockerignore":          "internal",
+		"exporting to image":                     "export",
+	}
+	for name, want := range tests {
+		if got := vertexStage(name); got != want {
+			t.Errorf("vertexStage(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestBuildTraceStages(t *testing.T) {
+	var log bytes.Buffer
+	trace := newBuildTrace(&log)
+	// Split the input at arbitrary points, as the build writes it.
+	trace.Write([]byte(testTrace[:100]))
+	trace.Write([]byte(testTrace[100:] + "ERROR: failed to solve"))
+	trace.flush()
+
+	stages := trace.stages()
+	want := []BuildStageTiming{
+		{Stage: "builder", DurationMS: 40000, Steps: 2, CachedSteps: 1},
+		{Stage: "export", DurationMS: 5000, Steps: 1},
+		{Stage: "stage-1", DurationMS: 2000, Steps: 1},
+		{Stage: "internal", DurationMS: 1000, Steps: 1},
+	}
+	if len(stages) != len(want) {
+		t.Fatalf("stages() = %+v", stages)
+	}
+	for i := range want {
+		if stages[i] != want[i] {
+			t.Errorf("stage %d = %+v, want %+v", i, stages[i], want[i])
+		}
+	}
+
+	for _, line := range []string{"#2 CACHED", "#3 [builder 3/3] RUN go build", "#3 compiling", "#3 DONE 40.0s", "ERROR: failed to solve"} {
+		if !strings.Contains(log.String(), line) {
+			t.Errorf("expected %q in rendered log:\n%s", line, log.String())
+		}
+	}
+	if strings.Contains(log.String(), `"vertexes"`) {
+		t.Errorf("expected JSON progress not to be logged:\n%s", log.String())
+	}
+}
+
+func TestExecuteBuildTimings(t *testing.T) {
+	mock := &MockCommandExecutor{
+		StderrFunc: func(string, []string) string { return testTrace },
+	}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"image": "myapp", "builder": "release", "build_timings": true},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+
+	var build *MockRunCall
+	for i, call := range mock.RunCalls {
+		if containsArg(call.Args, "buildx", "build") {
+			build = &mock.RunCalls[i]
+		}
+	}
+	if build == nil || !containsArg(build.Args, "--progress", "rawjson") {
+		t.Fatalf("expected a rawjson build, got %v", mock.RunCalls)
+	}
+	stages, ok := resp.Outputs["build_stages"].([]BuildStageTiming)
+	if !ok || len(stages) == 0 || stages[0].Stage != "builder" {
+		t.Errorf("expected builder as the slowest stage, got %v", resp.Outputs["build_stages"])
+	}
+}
+
+func TestValidateBuildTimingsRequiresBuilder(t *testing.T) {
+	if err := validateBuildTimings(&Config{BuildTimings: true}); err == nil {
+		t.Error("expected build_timings without builder to be rejected")
+	}
+}
+
+func TestCacheReport(t *testing.T) {
+	trace := newBuildTrace(io.Discard)
+	trace.Write([]byte(testTrace + `{"vertexes":[{"digest":"sha256:f","name":"[builder 1/3] FROM docker.io/library/golang:1.22","started":"2026-01-01T00:00:00Z","completed":"2026-01-01T00:00:00Z","cached":true}]}` + "\n"))
+
+	report := trace.cacheReport()
+	if report.Steps != 3 || report.CachedSteps != 1 {
+		t.Fatalf("cacheReport() = %+v, want 1 of 3 steps cached", report)
+	}
+	if report.HitRate < 33.3 || report.HitRate > 33.4 {
+		t.Errorf("HitRate = %v, want 33.3", report.HitRate)
+	}
+}
+
+func TestCacheHitWarning(t *testing.T) {
+	low := &CacheReport{Steps: 10, CachedSteps: 2, HitRate: 20}
+
+	tests := []struct {
+		name string
+		cfg  *Config
+		want string
+	}{
+		{"disabled", &Config{}, ""},
+		{"above threshold", &Config{CacheHitThreshold: 10}, ""},
+		{"no cache", &Config{CacheHitThreshold: 50, NoCache: true}, ""},
+		{"without cache_from", &Config{CacheHitThreshold: 50}, "set cache_from"},
+		{"with cache_from", &Config{CacheHitThreshold: 50, CacheFrom: []string{"type=registry,ref=myapp:cache"}}, "check that cache_from type=registry,ref=myapp:cache is readable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := cacheHitWarning(tt.cfg, low)
+			if tt.want == "" && got != "" {
+				t.Errorf("unexpected warning: %q", got)
+			}
+			if tt.want != "" && (!strings.Contains(got, "hit rate 20% (2 of 10 steps)") || !strings.Contains(got, tt.want)) {
+				t.Errorf("cacheHitWarning() = %q, want it to mention %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteLowCacheHitRate(t *testing.T) {
+	mock := &MockCommandExecutor{
+		StderrFunc: func(string, []string) string { return testTrace },
+	}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"image": "myapp", "builder": "release", "cache_hit_threshold": 50},
+		Context: plugin.ReleaseContext{Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+
+	if cache, ok := resp.Outputs["cache"].(*CacheReport); !ok || cache.Steps != 3 {
+		t.Errorf("unexpected cache output: %v", resp.Outputs["cache"])
+	}
+	if _, ok := resp.Outputs["build_stages"]; ok {
+		t.Error("expected no build_stages without build_timings")
+	}
+	warnings, _ := resp.Outputs["warnings"].([]string)
+	if !strings.Contains(strings.Join(warnings, "\n"), "below cache_hit_threshold 50%") {
+		t.Errorf("expected low cache hit warning, got %v", warnings)
+	}
+}
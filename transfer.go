@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// Layer status lines printed by docker push, e.g. "5f70bf18a086: Pushed".
+	pushLayerPattern = regexp.MustCompile(`^([0-9a-f]{12}): (Pushed|Layer already exists|Mounted from .+)$`)
+
+	// Final line of docker push, e.g. "1.0.0: digest: sha256:... size: 1570".
+	pushDigestPattern = regexp.MustCompile(`digest: (sha256:[0-9a-f]{64}) size: ([0-9]+)`)
+)
+
+// PushStats describes what a single docker push actually transferred.
+type PushStats struct {
+	Ref            string `json:"ref"`
+	Digest         string `json:"digest,omitempty"`
+	LayersPushed   int    `json:"layers_pushed"`
+	LayersExisting int    `json:"layers_existing"`
+	BytesPushed    int64  `json:"bytes_pushed"`
+	BytesTotal     int64  `json:"bytes_total"`
+	Attempts       int    `json:"attempts,omitempty"`
+
+	// pushedLayers holds the short diff IDs of uploaded layers.
+	pushedLayers []string
+}
+
+// parsePushOutput extracts per-layer transfer status and the pushed manifest
+// digest from docker push output.
+func parsePushOutput(ref string, output []byte) *PushStats {
+	stats := &PushStats{Ref: ref}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if m := pushLayerPattern.FindStringSubmatch(line); m != nil {
+			if m[2] == "Pushed" {
+				stats.LayersPushed++
+				stats.pushedLayers = append(stats.pushedLayers, m[1])
+			} else {
+				stats.LayersExisting++
+			}
+			continue
+		}
+		if m := pushDigestPattern.FindStringSubmatch(line); m != nil {
+			stats.Digest = m[1]
+		}
+	}
+	return stats
+}
+
+// imageManifest is the subset of an OCI/Docker image manifest needed to size layers.
+type imageManifest struct {
+	Layers []struct {
+		Size int64 `json:"size"`
+	} `json:"layers"`
+}
+
+// resolveTransferSizes fills in byte counts by pairing the image's diff IDs
+// (the IDs docker push reports) with the compressed layer sizes from the
+// pushed manifest; both list layers in the same order. Sizes are left at zero
+// when the manifest is an index or cannot be read.
+func (p *DockerPlugin) resolveTransferSizes(ctx context.Context, stats *PushStats) {
+	out, err := p.getExecutor().Output(ctx, "docker", []string{"image", "inspect", "--format", "{{json .RootFS.Layers}}", stats.Ref})
+	if err != nil {
+		return
+	}
+	var diffIDs []string
+	if err := json.Unmarshal(bytes.TrimSpace(out), &diffIDs); err != nil {
+		return
+	}
+
+	out, err = p.getExecutor().Output(ctx, "docker", []string{"buildx", "imagetools", "inspect", "--raw", stats.Ref})
+	if err != nil {
+		return
+	}
+	var manifest imageManifest
+	if err := json.Unmarshal(out, &manifest); err != nil || len(manifest.Layers) != len(diffIDs) {
+		return
+	}
+
+	pushed := make(map[string]bool, len(stats.pushedLayers))
+	for _, id := range stats.pushedLayers {
+		pushed[id] = true
+	}
+
+	for i, diffID := range diffIDs {
+		size := manifest.Layers[i].Size
+		stats.BytesTotal += size
+		if pushed[shortLayerID(diffID)] {
+			stats.BytesPushed += size
+		}
+	}
+}
+
+// shortLayerID truncates a layer digest the way docker progress output does.
+func shortLayerID(digest string) string {
+	id := strings.TrimPrefix(digest, "sha256:")
+	if len(id) > 12 {
+		id = id[:12]
+	}
+	return id
+}
+
+// totalBytesPushed sums the uploaded bytes across pushes.
+func totalBytesPushed(stats []*PushStats) int64 {
+	var total int64
+	for _, s := range stats {
+		total += s.BytesPushed
+	}
+	return total
+}
+
+// formatBytes renders a byte count for human-readable messages.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return strconv.FormatInt(n, 10) + " B"
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return strconv.FormatFloat(float64(n)/float64(div), 'f', 1, 64) + " " + string("KMGTPE"[exp]) + "iB"
+}
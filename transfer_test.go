@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+const samplePushOutput = `The push refers to repository [docker.io/myorg/myapp]
+aaaaaaaaaaaa: Preparing
+bbbbbbbbbbbb: Preparing
+cccccccccccc: Preparing
+aaaaaaaaaaaa: Pushed
+bbbbbbbbbbbb: Layer already exists
+cccccccccccc: Mounted from library/alpine
+1.0.0: digest: sha256:1111111111111111111111111111111111111111111111111111111111111111 size: 1570
+`
+
+func TestParsePushOutput(t *testing.T) {
+	stats := parsePushOutput("myorg/myapp:1.0.0", []byte(samplePushOutput))
+
+	if stats.LayersPushed != 1 {
+		t.Errorf("expected 1 pushed layer, got %d", stats.LayersPushed)
+	}
+	if stats.LayersExisting != 2 {
+		t.Errorf("expected 2 existing layers, got %d", stats.LayersExisting)
+	}
+	if stats.Digest != "sha256:1111111111111111111111111111111111111111111111111111111111111111" {
+		t.Errorf("unexpected digest: %s", stats.Digest)
+	}
+}
+
+func TestPushImageTransferSizes(t *testing.T) {
+	ctx := context.Background()
+	mock := &MockCommandExecutor{
+		StdoutFunc: func(string, []string) string { return samplePushOutput },
+		OutputFunc: func(_ context.Context, _ string, args []string) ([]byte, error) {
+			if args[0] == "image" {
+				return []byte(`["sha256:aaaaaaaaaaaa0000","sha256:bbbbbbbbbbbb0000","sha256:cccccccccccc0000"]`), nil
+			}
+			return []byte(`{"layers":[{"size":1000},{"size":2000},{"size":4000}]}`), nil
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+
+	stats, err := p.pushImage(ctx, &Config{}, "myorg/myapp:1.0.0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.BytesPushed != 1000 {
+		t.Errorf("expected 1000 bytes pushed, got %d", stats.BytesPushed)
+	}
+	if stats.BytesTotal != 7000 {
+		t.Errorf("expected 7000 bytes total, got %d", stats.BytesTotal)
+	}
+}
+
+func TestPushImageSkipsSizingWhenNothingUploaded(t *testing.T) {
+	ctx := context.Background()
+	mock := &MockCommandExecutor{
+		StdoutFunc: func(string, []string) string {
+			return "aaaaaaaaaaaa: Layer already exists\n"
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+
+	stats, err := p.pushImage(ctx, &Config{}, "myorg/myapp:latest")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.BytesPushed != 0 || len(mock.OutputCalls) != 0 {
+		t.Errorf("expected no sizing lookups, got %d output calls", len(mock.OutputCalls))
+	}
+}
+
+func TestPushStatsInOutputs(t *testing.T) {
+	ctx := context.Background()
+	mock := &MockCommandExecutor{
+		StdoutFunc: func(_ string, args []string) string {
+			if args[0] == "push" {
+				return samplePushOutput
+			}
+			return ""
+		},
+		OutputFunc: func(_ context.Context, _ string, args []string) ([]byte, error) {
+			if args[0] == "image" {
+				return []byte(`["sha256:aaaaaaaaaaaa0000","sha256:bbbbbbbbbbbb0000","sha256:cccccccccccc0000"]`), nil
+			}
+			return []byte(`{"layers":[{"size":2048},{"size":1},{"size":1}]}`), nil
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(ctx, plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"image": "myorg/myapp", "tags": []any{"{{version}}"}},
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+
+	stats, ok := resp.Outputs["push_stats"].([]*PushStats)
+	if !ok || len(stats) != 1 {
+		t.Fatalf("expected push_stats for one push, got %v", resp.Outputs["push_stats"])
+	}
+	if resp.Outputs["bytes_pushed"] != int64(2048) {
+		t.Errorf("expected 2048 bytes pushed, got %v", resp.Outputs["bytes_pushed"])
+	}
+	if !strings.Contains(resp.Message, "2.0 KiB uploaded") {
+		t.Errorf("expected upload size in message, got %q", resp.Message)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		n        int64
+		expected string
+	}{
+		{0, "0 B"},
+		{512, "512 B"},
+		{1536, "1.5 KiB"},
+		{10 * 1024 * 1024, "10.0 MiB"},
+	}
+
+	for _, tt := range tests {
+		if got := formatBytes(tt.n); got != tt.expected {
+			t.Errorf("formatBytes(%d) = %q, want %q", tt.n, got, tt.expected)
+		}
+	}
+}
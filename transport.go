@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// splitRegistryScheme strips an http:// or https:// prefix from registry and
+// reports whether it asked for plaintext HTTP.
+func splitRegistryScheme(registry string) (string, bool) {
+	if rest, ok := strings.CutPrefix(registry, "http://"); ok {
+		return strings.TrimSuffix(rest, "/"), true
+	}
+	if rest, ok := strings.CutPrefix(registry, "https://"); ok {
+		return strings.TrimSuffix(rest, "/"), false
+	}
+	return registry, false
+}
+
+// isPlaintextRegistry reports whether registry was configured with http://.
+func isPlaintextRegistry(cfg *Config, registry string) bool {
+	for _, r := range cfg.plaintextRegistries {
+		if r == registry {
+			return true
+		}
+	}
+	return false
+}
+
+// validateRegistryTransport rejects plaintext registries unless insecure is set.
+func validateRegistryTransport(cfg *Config) error {
+	if cfg.Insecure || len(cfg.plaintextRegistries) == 0 {
+		return nil
+	}
+	return fmt.Errorf("registry %s uses plaintext HTTP; set insecure: true to allow it", cfg.plaintextRegistries[0])
+}
+
+// checkDaemonInsecureRegistries fails when the docker daemon is configured to
+// reach a target registry without TLS verification, unless insecure is set.
+// Local registries are expected to be insecure. Daemons that cannot be
+// queried are not treated as insecure.
+func (p *DockerPlugin) checkDaemonInsecureRegistries(ctx context.Context, cfg *Config) error {
+	if cfg.Insecure {
+		return nil
+	}
+
+	out, err := p.getExecutor().Output(ctx, "docker", []string{"info", "--format", "{{json .RegistryConfig.IndexConfigs}}"})
+	if err != nil {
+		return nil
+	}
+
+	var indexConfigs map[string]struct {
+		Secure bool `json:"Secure"`
+	}
+	if err := json.Unmarshal(out, &indexConfigs); err != nil {
+		return nil
+	}
+
+	for _, registry := range configuredRegistries(cfg) {
+		if isLocalRegistryOf(cfg, registry) {
+			continue
+		}
+		if index, ok := indexConfigs[normalizeRegistry(registry)]; ok && !index.Secure {
+			return fmt.Errorf("registry %s is listed in the docker daemon's insecure-registries; set insecure: true to push to it", normalizeRegistry(registry))
+		}
+	}
+	return nil
+}
+
+// newRegistryHTTPClient returns the default client for direct registry API
+// calls: certificates are always verified, TLS 1.2 is the minimum and
+// redirects may not downgrade from HTTPS to HTTP.
+func newRegistryHTTPClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+
+	return &http.Client{
+		Timeout:       registryHTTPTimeout,
+		Transport:     transport,
+		CheckRedirect: rejectTLSDowngrade,
+	}
+}
+
+func rejectTLSDowngrade(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return fmt.Errorf("stopped after 10 redirects")
+	}
+	if via[0].URL.Scheme == "https" && req.URL.Scheme != "https" {
+		return fmt.Errorf("refusing redirect from HTTPS to %s", req.URL.Redacted())
+	}
+	return nil
+}
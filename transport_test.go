@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestSplitRegistryScheme(t *testing.T) {
+	tests := []struct {
+		registry  string
+		host      string
+		plaintext bool
+	}{
+		{"ghcr.io", "ghcr.io", false},
+		{"https://ghcr.io/", "ghcr.io", false},
+		{"http://registry.local:5000", "registry.local:5000", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.registry, func(t *testing.T) {
+			host, plaintext := splitRegistryScheme(tt.registry)
+			if host != tt.host || plaintext != tt.plaintext {
+				t.Errorf("splitRegistryScheme(%q) = %q, %v; want %q, %v", tt.registry, host, plaintext, tt.host, tt.plaintext)
+			}
+		})
+	}
+}
+
+func TestPlaintextRegistryRequiresInsecure(t *testing.T) {
+	config := map[string]any{
+		"image":    "myapp",
+		"registry": "http://registry.local:5000",
+	}
+
+	p := &DockerPlugin{executor: &MockCommandExecutor{}}
+
+	vresp, err := p.Validate(context.Background(), config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if vresp.Valid {
+		t.Error("expected Validate to reject plaintext registry")
+	}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  config,
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success || !strings.Contains(resp.Error, "plaintext HTTP") {
+		t.Errorf("expected plaintext registry error, got %+v", resp)
+	}
+
+	config["insecure"] = true
+	mock := &MockCommandExecutor{}
+	p.executor = mock
+	resp, err = p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  config,
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success with insecure: true, got %s", resp.Error)
+	}
+	if !containsArg(mock.RunCalls[0].Args, "-t", "registry.local:5000/myapp:1.0.0") {
+		t.Errorf("expected scheme to be stripped from image reference, got %v", mock.RunCalls[0].Args)
+	}
+}
+
+func TestDaemonInsecureRegistry(t *testing.T) {
+	mock := &MockCommandExecutor{
+		OutputFunc: func(_ context.Context, name string, args []string) ([]byte, error) {
+			if len(args) > 0 && args[0] == "info" {
+				return []byte(`{"docker.io":{"Secure":true},"registry.local:5000":{"Secure":false}}`), nil
+			}
+			return nil, nil
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+
+	cfg := &Config{Registry: "registry.local:5000"}
+	if err := p.checkDaemonInsecureRegistries(context.Background(), cfg); err == nil {
+		t.Error("expected error for daemon insecure registry")
+	}
+
+	cfg.Insecure = true
+	if err := p.checkDaemonInsecureRegistries(context.Background(), cfg); err != nil {
+		t.Errorf("unexpected error with insecure: true: %v", err)
+	}
+
+	if err := p.checkDaemonInsecureRegistries(context.Background(), &Config{Registry: "docker.io"}); err != nil {
+		t.Errorf("unexpected error for secure registry: %v", err)
+	}
+}
+
+func TestRegistryClientScheme(t *testing.T) {
+	p := &DockerPlugin{}
+
+	cfg := p.parseConfig(map[string]any{"registry": "http://registry.local:5000"})
+	if got := p.newRegistryClient(cfg).baseURL; got != "https://registry.local:5000" {
+		t.Errorf("expected HTTPS without insecure, got %s", got)
+	}
+
+	cfg.Insecure = true
+	if got := p.newRegistryClient(cfg).baseURL; got != "http://registry.local:5000" {
+		t.Errorf("expected HTTP with insecure, got %s", got)
+	}
+}
+
+func TestRejectTLSDowngrade(t *testing.T) {
+	secure := &http.Request{URL: &url.URL{Scheme: "https", Host: "ghcr.io"}}
+	plaintext := &http.Request{URL: &url.URL{Scheme: "http", Host: "ghcr.io"}}
+
+	if err := rejectTLSDowngrade(plaintext, []*http.Request{secure}); err == nil {
+		t.Error("expected HTTPS to HTTP redirect to be rejected")
+	}
+	if err := rejectTLSDowngrade(secure, []*http.Request{secure}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	client := newRegistryHTTPClient()
+	tlsConfig := client.Transport.(*http.Transport).TLSClientConfig
+	if tlsConfig.InsecureSkipVerify {
+		t.Error("registry client must verify certificates")
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+)
+
+// trustCertsSecret is the id of the BuildKit secret carrying trust_certs.
+const trustCertsSecret = "trust_certs"
+
+// validateTrustCerts checks that trust_certs are paths inside the working
+// directory and do not collide with a configured secret.
+func validateTrustCerts(cfg *Config) error {
+	for _, path := range cfg.TrustCerts {
+		if err := validatePath(path); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	if _, ok := cfg.Secrets[trustCertsSecret]; ok && len(cfg.TrustCerts) > 0 {
+		return fmt.Errorf("secret id %s is reserved for trust_certs", trustCertsSecret)
+	}
+	return nil
+}
+
+// trustBundle reads trust_certs into one PEM bundle of CA certificates.
+// Files may hold several certificates; a file without one, or with an
+// invalid one, fails.
+func trustBundle(cfg *Config) ([]byte, error) {
+	var bundle bytes.Buffer
+	for _, path := range cfg.TrustCerts {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		found := 0
+		for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+				return nil, fmt.Errorf("%s: invalid certificate: %v", path, err)
+			}
+			if err := pem.Encode(&bundle, block); err != nil {
+				return nil, err
+			}
+			found++
+		}
+		if found == 0 {
+			return nil, fmt.Errorf("%s: no PEM certificate found", path)
+		}
+	}
+	return bundle.Bytes(), nil
+}
+
+// withTrustBundle writes the trust_certs bundle to a temporary file mounted
+// into the build as the trust_certs secret. The returned function removes
+// it.
+func withTrustBundle(cfg *Config) (func(), error) {
+	bundle, err := trustBundle(cfg)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp("", "relicta-docker-trust-*.pem")
+	if err != nil {
+		return nil, err
+	}
+	_, err = f.Write(bundle)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return nil, err
+	}
+	cfg.trustBundle = f.Name()
+	return func() { os.Remove(f.Name()) }, nil
+}
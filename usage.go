@@ -0,0 +1,97 @@
+package main
+
+import (
+	"math"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ResourceReport estimates the resources a release used, so registry and CI
+// costs can be attributed to the image.
+type ResourceReport struct {
+	// BuildDurationMS is the time spent in build stages.
+	BuildDurationMS int64 `json:"build_duration_ms"`
+	// BuildCPUs is the number of CPUs builds could use: those of cpuset,
+	// or of the runner. It is zero for builds on remote builder nodes.
+	BuildCPUs int `json:"build_cpus,omitempty"`
+	// BuildCPUMinutes is the build duration times BuildCPUs, an upper
+	// bound of the CPU time builds used.
+	BuildCPUMinutes float64 `json:"build_cpu_minutes"`
+	// BytesPushed is the compressed size of the layers uploaded.
+	BytesPushed int64 `json:"bytes_pushed"`
+	// StorageAddedBytes is the compressed size of the layers new to the
+	// registry, counted once however many tags they were pushed with.
+	StorageAddedBytes int64 `json:"storage_added_bytes"`
+	// ImageBytes is the compressed size of the pushed image, shared layers
+	// included.
+	ImageBytes int64 `json:"image_bytes,omitempty"`
+	// Unmeasured names the usage the estimate leaves out.
+	Unmeasured []string `json:"unmeasured,omitempty"`
+}
+
+// newResourceReport starts the resource report of a release of cfg.
+func newResourceReport(cfg *Config) *ResourceReport {
+	report := &ResourceReport{}
+	switch {
+	case len(cfg.BuilderNodes) > 0:
+		report.Unmeasured = append(report.Unmeasured, "build CPUs of remote builder nodes")
+	case cfg.CPUSet != "":
+		report.BuildCPUs = cpuSetSize(cfg.CPUSet)
+	default:
+		report.BuildCPUs = runtime.NumCPU()
+	}
+	if len(cfg.Mirrors) > 0 || cfg.ArchiveRegistry != "" {
+		report.Unmeasured = append(report.Unmeasured, "storage of mirror and archive copies")
+	}
+	return report
+}
+
+// cpuSetSize returns the number of CPUs of a CPU list like 0-3,6.
+func cpuSetSize(cpuset string) int {
+	n := 0
+	for _, part := range strings.Split(cpuset, ",") {
+		first, last, isRange := strings.Cut(part, "-")
+		if !isRange {
+			n++
+			continue
+		}
+		a, _ := strconv.Atoi(first)
+		b, _ := strconv.Atoi(last)
+		if b >= a {
+			n += b - a + 1
+		}
+	}
+	return n
+}
+
+// setResources completes the resource report from the stages and pushes
+// of the release. Pushes made by buildx or imagetools report no
+// transfer sizes and are left out.
+func (o *Outputs) setResources() {
+	r := o.Resources
+	if r == nil {
+		return
+	}
+	r.BuildDurationMS = 0
+	for _, s := range o.Stages {
+		if s.Name == "build" {
+			r.BuildDurationMS += s.DurationMS
+		}
+	}
+	r.BuildCPUMinutes = math.Round(float64(r.BuildDurationMS)/60000*float64(r.BuildCPUs)*100) / 100
+
+	r.BytesPushed = o.BytesPushed
+	r.StorageAddedBytes, r.ImageBytes = 0, 0
+	for _, s := range o.PushStats {
+		r.StorageAddedBytes += s.BytesPushed
+		r.ImageBytes = max(r.ImageBytes, s.BytesTotal)
+	}
+	if o.Pushed && len(o.PushStats) == 0 {
+		const buildxPush = "transfers of pushes made by buildx or imagetools"
+		if !slices.Contains(r.Unmeasured, buildxPush) {
+			r.Unmeasured = append(r.Unmeasured, buildxPush)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestCPUSetSize(t *testing.T) {
+	for cpuset, want := range map[string]int{"0": 1, "0-3": 4, "0-3,6": 5, "1,3,5-7": 5} {
+		if got := cpuSetSize(cpuset); got != want {
+			t.Errorf("cpuSetSize(%q) = %d, want %d", cpuset, got, want)
+		}
+	}
+}
+
+func TestNewResourceReport(t *testing.T) {
+	if r := newResourceReport(&Config{}); r.BuildCPUs != runtime.NumCPU() || len(r.Unmeasured) != 0 {
+		t.Errorf("unexpected report: %+v", r)
+	}
+	if r := newResourceReport(&Config{CPUSet: "0-1"}); r.BuildCPUs != 2 {
+		t.Errorf("expected the CPUs of cpuset, got %d", r.BuildCPUs)
+	}
+	r := newResourceReport(&Config{BuilderNodes: []BuilderNode{{Endpoint: "tcp://buildkit:1234"}}, Mirrors: []Mirror{{Registry: "mirror.example.com"}}})
+	if r.BuildCPUs != 0 || len(r.Unmeasured) != 2 {
+		t.Errorf("expected remote builds and mirrors unmeasured, got %+v", r)
+	}
+}
+
+func TestSetResources(t *testing.T) {
+	o := &Outputs{Resources: &ResourceReport{BuildCPUs: 4}, Pushed: true}
+	o.Stages = []StageStatus{{Name: "build", DurationMS: 90000}, {Name: "push", DurationMS: 30000}}
+	o.setPushed([]*PushStats{
+		{Ref: "myorg/myapp:1.0.0", BytesPushed: 3000, BytesTotal: 7000},
+		{Ref: "myorg/myapp:latest", BytesTotal: 7000},
+	})
+	o.setResources()
+
+	r := o.Resources
+	if r.BuildDurationMS != 90000 || r.BuildCPUMinutes != 6 {
+		t.Errorf("unexpected build usage: %+v", r)
+	}
+	if r.BytesPushed != 3000 || r.StorageAddedBytes != 3000 || r.ImageBytes != 7000 {
+		t.Errorf("unexpected push usage: %+v", r)
+	}
+	if len(r.Unmeasured) != 0 {
+		t.Errorf("expected every push measured, got %v", r.Unmeasured)
+	}
+
+	// Without transfer stats the pushes are reported unmeasured, once.
+	o = &Outputs{Resources: &ResourceReport{}, Pushed: true}
+	o.setResources()
+	o.setResources()
+	if len(o.Resources.Unmeasured) != 1 {
+		t.Errorf("expected buildx pushes unmeasured, got %v", o.Resources.Unmeasured)
+	}
+}
+
+func TestExecuteReportsResources(t *testing.T) {
+	ctx := context.Background()
+	mock := &MockCommandExecutor{
+		StdoutFunc: func(_ string, args []string) string {
+			if args[0] == "push" {
+				return samplePushOutput
+			}
+			return ""
+		},
+		OutputFunc: func(_ context.Context, _ string, args []string) ([]byte, error) {
+			if args[0] == "image" {
+				return []byte(`["sha256:aaaaaaaaaaaa0000","sha256:bbbbbbbbbbbb0000","sha256:cccccccccccc0000"]`), nil
+			}
+			return []byte(`{"layers":[{"size":2048},{"size":1},{"size":1}]}`), nil
+		},
+	}
+	p := &DockerPlugin{executor: mock}
+
+	config := map[string]any{"image": "myorg/myapp", "tags": []any{"{{version}}"}}
+	resp, err := p.Execute(ctx, plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  config,
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+	})
+	if err != nil || !resp.Success {
+		t.Fatalf("unexpected failure: %v %+v", err, resp)
+	}
+	r, ok := resp.Outputs["resources"].(*ResourceReport)
+	if !ok {
+		t.Fatalf("expected resources, got %v", resp.Outputs["resources"])
+	}
+	if r.BuildCPUs != runtime.NumCPU() || r.BytesPushed != 2048 || r.StorageAddedBytes != 2048 || r.ImageBytes != 2050 {
+		t.Errorf("unexpected resources: %+v", r)
+	}
+
+	resp, err = p.Execute(ctx, plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  config,
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+		DryRun:  true,
+	})
+	if err != nil || !resp.Success {
+		t.Fatalf("unexpected failure: %v %+v", err, resp)
+	}
+	if _, ok := resp.Outputs["resources"]; ok {
+		t.Error("expected no resources for a dry run")
+	}
+}
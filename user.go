@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Actions of root_user for images running as root.
+const (
+	rootUserAllow = "allow"
+	rootUserWarn  = "warn"
+	rootUserFail  = "fail"
+)
+
+// validateRootUser checks root_user and the root_user_allowlist patterns.
+func validateRootUser(cfg *Config) error {
+	switch cfg.RootUser {
+	case "", rootUserAllow, rootUserWarn, rootUserFail:
+	default:
+		return fmt.Errorf("root_user must be %s, %s or %s", rootUserAllow, rootUserWarn, rootUserFail)
+	}
+	if err := validateBaseImagePatterns(cfg.RootUserAllowlist); err != nil {
+		return fmt.Errorf("invalid root_user_allowlist: %v", err)
+	}
+	return nil
+}
+
+// isRootUser reports whether a USER setting runs the container as root. An
+// empty user is root, and so is uid 0 with any group.
+func isRootUser(user string) bool {
+	name, _, _ := strings.Cut(strings.TrimSpace(user), ":")
+	return name == "" || name == "root" || name == "0"
+}
+
+// checksRootUser reports whether the image is held to root_user, i.e. the
+// check is enabled and the image is not on root_user_allowlist.
+func checksRootUser(cfg *Config) bool {
+	if cfg.RootUser == "" || cfg.RootUser == rootUserAllow {
+		return false
+	}
+	return !baseImageAllowed(imageRepository(cfg), cfg.RootUserAllowlist)
+}
+
+// checkRootUser inspects the user of the built image ref. Running as root
+// is reported as a warning, or as an error with root_user: fail. pushed
+// notes that the image was already pushed, as buildx pushes while building.
+func (p *DockerPlugin) checkRootUser(ctx context.Context, cfg *Config, ref string, pushed bool) (string, error) {
+	imageConfig, err := p.inspectImageConfig(ctx, ref, imageInDaemon(cfg))
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect image user: %w", err)
+	}
+	if !isRootUser(imageConfig.User) {
+		return "", nil
+	}
+
+	user := imageConfig.User
+	if user == "" {
+		user = "root (no USER set)"
+	}
+	message := fmt.Sprintf("image %s runs as %s; set a non-root USER in the Dockerfile or add the image to root_user_allowlist", ref, user)
+	if cfg.RootUser != rootUserFail {
+		return message, nil
+	}
+	if pushed {
+		message += "; the image was already pushed by the build"
+	}
+	return "", fmt.Errorf("%s", message)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestIsRootUser(t *testing.T) {
+	tests := map[string]bool{
+		"":             true,
+		"root":         true,
+		"0":            true,
+		"0:1000":       true,
+		"root:nogroup": true,
+		"nonroot":      false,
+		"65532:65532":  false,
+		"1000:0":       false,
+	}
+	for user, want := range tests {
+		if got := isRootUser(user); got != want {
+			t.Errorf("isRootUser(%q) = %v, want %v", user, got, want)
+		}
+	}
+}
+
+func TestChecksRootUser(t *testing.T) {
+	if checksRootUser(&Config{Image: "myapp", RootUser: rootUserAllow}) {
+		t.Error("expected no check with root_user: allow")
+	}
+	if !checksRootUser(&Config{Image: "myapp", RootUser: rootUserFail}) {
+		t.Error("expected the image to be checked")
+	}
+	if checksRootUser(&Config{Image: "org/node-exporter", Registry: "ghcr.io", RootUser: rootUserFail, RootUserAllowlist: []string{"ghcr.io/org/node-*"}}) {
+		t.Error("expected an allowlisted image not to be checked")
+	}
+}
+
+func TestValidateRootUser(t *testing.T) {
+	if err := validateRootUser(&Config{RootUser: "deny"}); err == nil {
+		t.Error("expected an unknown root_user action to be rejected")
+	}
+	if err := validateRootUser(&Config{RootUser: rootUserWarn, RootUserAllowlist: []string{"["}}); err == nil {
+		t.Error("expected a malformed allowlist pattern to be rejected")
+	}
+}
+
+func TestExecuteRootUser(t *testing.T) {
+	rootImage := func() *MockCommandExecutor {
+		return &MockCommandExecutor{
+			OutputFunc: func(_ context.Context, _ string, args []string) ([]byte, error) {
+				if containsArg(args, "--format", "{{json .Config}}") {
+					return []byte(`{"User":"0"}`), nil
+				}
+				return nil, nil
+			},
+		}
+	}
+
+	t.Run("warn", func(t *testing.T) {
+		p := &DockerPlugin{executor: rootImage()}
+		resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+			Hook:    plugin.HookPostPublish,
+			Config:  map[string]any{"image": "myapp", "root_user": "warn"},
+			Context: plugin.ReleaseContext{Version: "1.0.0"},
+		})
+		if err != nil || !resp.Success {
+			t.Fatalf("unexpected failure: %v %+v", err, resp)
+		}
+		warnings, _ := resp.Outputs["warnings"].([]string)
+		if !strings.Contains(strings.Join(warnings, "\n"), "image myapp:1.0.0 runs as 0") {
+			t.Errorf("expected a root user warning, got %v", warnings)
+		}
+	})
+
+	t.Run("fail before push", func(t *testing.T) {
+		mock := rootImage()
+		p := &DockerPlugin{executor: mock}
+		resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+			Hook:    plugin.HookPostPublish,
+			Config:  map[string]any{"image": "myapp", "root_user": "fail"},
+			Context: plugin.ReleaseContext{Version: "1.0.0"},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.Success || !strings.Contains(resp.Error, "image user policy violation") {
+			t.Fatalf("expected a root user failure, got %+v", resp)
+		}
+		for _, call := range mock.RunCalls {
+			if call.Args[0] == "push" {
+				t.Errorf("expected nothing to be pushed, got %v", call.Args)
+			}
+		}
+	})
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/helpers"
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// validator checks one option of the configuration of a release. Execute
+// reports its error after prefix, or as it is without a prefix; Validate
+// reports it under field. A validator is skipped by Validate when field, or
+// the field it requires, already has an error.
+type validator struct {
+	field    string
+	prefix   string
+	check    func(cfg *Config) error
+	requires string
+}
+
+// policyValidators check the target of a push against the registry and
+// naming policies.
+var policyValidators = []validator{
+	{field: "image", prefix: "invalid image configuration", check: func(cfg *Config) error { return validateImageName(cfg.Image) }},
+	{field: "registry", prefix: "invalid registry configuration", check: func(cfg *Config) error { return validateRegistry(cfg.Registry) }},
+	{field: "allowed_registries", prefix: "invalid allowed_registries configuration", check: func(cfg *Config) error {
+		return validateAllowedRegistryPatterns(cfg.AllowedRegistries)
+	}},
+	{field: "registry", prefix: "registry policy violation", check: checkRegistryPolicy, requires: "allowed_registries"},
+	{field: "image_naming", prefix: "invalid image_naming", check: validateNamingPolicy},
+	{field: "image", prefix: "image naming policy violation", check: checkNamingPolicy, requires: "image_naming"},
+	{field: "registry", prefix: "insecure registry", check: validateRegistryTransport},
+}
+
+// pushGateValidators check the settings of the gates a release passes before
+// it first writes to the registry.
+var pushGateValidators = []validator{
+	{field: "approval", prefix: "invalid approval configuration", check: validateApproval},
+	{field: "push_window", prefix: "invalid push_window", check: validatePushWindow},
+	{field: "release_lock", prefix: "invalid release_lock", check: validateReleaseLock},
+	timeoutValidator("registry_ready_timeout", "invalid registry readiness configuration", func(cfg *Config) string { return cfg.RegistryReadyTimeout }),
+	timeoutValidator("registry_keepalive", "invalid registry readiness configuration", func(cfg *Config) string { return cfg.RegistryKeepalive }),
+	{field: "ecr", prefix: "invalid ecr configuration", check: validateECR},
+	{field: "push_rate_limit", prefix: "invalid push_rate_limit", check: validateRateLimit},
+}
+
+// timeoutValidators check the operation timeouts.
+var timeoutValidators = []validator{
+	timeoutValidator("build_timeout", "invalid timeouts", func(cfg *Config) string { return cfg.BuildTimeout }),
+	timeoutValidator("push_timeout", "invalid timeouts", func(cfg *Config) string { return cfg.PushTimeout }),
+	timeoutValidator("login_timeout", "invalid timeouts", func(cfg *Config) string { return cfg.LoginTimeout }),
+}
+
+// releaseValidators check the configuration of a release that builds or
+// assembles an image, in the order Execute checks them.
+var releaseValidators = concatValidators(policyValidators, pushGateValidators, []validator{
+	{field: "allowed_base_images", prefix: "invalid allowed_base_images configuration", check: func(cfg *Config) error {
+		return validateBaseImagePatterns(cfg.AllowedBaseImages)
+	}},
+	{field: "archive_registry", prefix: "invalid archive registry configuration", check: func(cfg *Config) error {
+		if cfg.ArchiveRegistry == "" {
+			return nil
+		}
+		return validateRegistry(cfg.ArchiveRegistry)
+	}},
+	{field: "archive_image", prefix: "invalid archive image configuration", check: func(cfg *Config) error {
+		if cfg.ArchiveImage == "" {
+			return nil
+		}
+		return validateImageName(cfg.ArchiveImage)
+	}},
+	{field: "inputs", prefix: "invalid inputs", check: validateInputs},
+	{field: "static_binaries", prefix: "invalid static_binaries", check: validateStaticBinaries},
+	{field: "root_user", prefix: "invalid root_user configuration", check: validateRootUser},
+	{field: "registries", prefix: "invalid registries configuration", check: validateRegistries},
+	{field: "mirrors", prefix: "invalid mirrors configuration", check: func(cfg *Config) error { return validateMirrors(cfg.Mirrors) }},
+	{field: "downstreams", prefix: "invalid downstreams configuration", check: validateDownstreams},
+	{field: "tag_aliases", prefix: "invalid tag_aliases", check: validateTagAliases},
+	{field: "e2e", prefix: "invalid e2e configuration", check: validateE2E},
+	{field: "exec_compat", prefix: "invalid exec_compat configuration", check: validateExecCompat},
+	{field: "canary", prefix: "invalid canary configuration", check: validateCanary},
+	{field: "base_image_trust", prefix: "invalid base_image_trust", check: validateBaseImageTrust},
+	{field: "scorecard_file", prefix: "invalid scorecard configuration", check: validateScorecard},
+	{field: "version_manifest", check: validateVersionManifest},
+	{field: "engine", prefix: "invalid engine configuration", check: validateEngine},
+	{field: "use_credential_helper", prefix: "invalid credential helper configuration", check: validateCredentialHelper},
+	{field: "features", prefix: "invalid features", check: validateFeatures},
+	{field: "daemonless", prefix: "invalid daemonless configuration", check: validateDaemonless},
+	{field: "max_duration", prefix: "invalid max_duration", check: validateMaxDuration},
+}, timeoutValidators, []validator{
+	{field: "debug", prefix: "invalid debug configuration", check: validateDebug},
+	{field: "build_timings", prefix: "invalid build_timings", check: validateBuildTimings},
+	{field: "cache_hit_threshold", prefix: "invalid cache_hit_threshold", check: validateCacheHitThreshold},
+	{field: "dockerfile", prefix: "invalid dockerfile path", check: func(cfg *Config) error { return validatePath(cfg.Dockerfile) }},
+	{field: "checkpoint_file", prefix: "invalid checkpoint_file", check: func(cfg *Config) error { return validatePath(cfg.CheckpointFile) }},
+	{field: "context", prefix: "invalid build context path", check: func(cfg *Config) error { return validatePath(cfg.Context) }},
+	{field: "builder", prefix: "invalid builder configuration", check: func(cfg *Config) error { return validateBuilderName(cfg.Builder) }},
+	{field: "builder_nodes", prefix: "invalid builder_nodes configuration", check: func(cfg *Config) error {
+		return validateBuilderNodes(cfg.BuilderNodes, cfg.Builder, cfg.BuilderDriver)
+	}},
+	{field: "platforms", prefix: "invalid platforms configuration", check: func(cfg *Config) error { return validatePlatforms(cfg.Platforms) }},
+	{field: "push_retries", prefix: "invalid push_retries", check: func(cfg *Config) error { return validatePushRetries(cfg.PushRetries) }},
+	{field: "push_retry_backoff", prefix: "invalid push_retry_backoff", check: func(cfg *Config) error {
+		return validatePushRetryBackoff(cfg.PushRetryBackoff)
+	}},
+	{field: "gcp_credentials", prefix: "invalid gcp_credentials", check: validateGCPCredentials},
+	{field: "acr", prefix: "invalid acr configuration", check: validateACR},
+	{field: "registry_type", prefix: "invalid registry_type", check: func(cfg *Config) error { return validateRegistryType(cfg.RegistryType) }},
+	{field: "registry_headers", prefix: "invalid registry_headers configuration", check: func(cfg *Config) error {
+		return validateRegistryHeaders(cfg.UserAgent, cfg.RegistryHeaders)
+	}},
+	{field: "auth", prefix: "invalid auth configuration", check: validateAuth},
+	{field: "encryption_recipients", prefix: "invalid encryption configuration", check: validateEncryptionConfig},
+	{field: "entitlements", prefix: "invalid entitlements", check: validateEntitlements},
+	resourceValidator("priority", func(cfg *Config) *Config { return &Config{Priority: cfg.Priority} }),
+	resourceValidator("cpuset", func(cfg *Config) *Config { return &Config{CPUSet: cfg.CPUSet} }),
+	resourceValidator("cgroup_slice", func(cfg *Config) *Config { return &Config{CgroupSlice: cfg.CgroupSlice} }),
+	resourceValidator("ulimits", func(cfg *Config) *Config { return &Config{Ulimits: cfg.Ulimits} }),
+	resourceValidator("nofile_limit", func(cfg *Config) *Config { return &Config{NofileLimit: cfg.NofileLimit} }),
+	resourceValidator("max_parallelism", func(cfg *Config) *Config {
+		return &Config{MaxParallelism: cfg.MaxParallelism, BuilderNodes: cfg.BuilderNodes}
+	}),
+	{field: "cosign_key", prefix: "invalid cosign configuration", check: validateCosignConfig},
+	{field: "cosign_sign", prefix: "signing is not ready", check: checkSigningPrerequisites, requires: "cosign_key"},
+	{field: "cluster_load", prefix: "invalid cluster_load configuration", check: validateClusterLoad},
+	{field: "index_sources", prefix: "invalid index configuration", check: validateIndexConfig},
+	{field: "append_platform", prefix: "invalid append_platform", check: validateAppendPlatforms},
+	{field: "phase", prefix: "invalid phase configuration", check: validatePhase},
+	{field: "build_args", check: func(cfg *Config) error {
+		keys := make([]string, 0, len(cfg.BuildArgs)+len(cfg.BuildArgSources))
+		for key := range cfg.BuildArgs {
+			keys = append(keys, key)
+		}
+		for key := range cfg.BuildArgSources {
+			keys = append(keys, key)
+		}
+		for _, key := range keys {
+			if err := validateBuildArgKey(key); err != nil {
+				return fmt.Errorf("invalid build arg key '%s': %v", key, err)
+			}
+		}
+		return nil
+	}},
+	{field: "build_args", prefix: "invalid build_args configuration", check: func(cfg *Config) error {
+		return validateBuildArgSources(cfg.BuildArgSources)
+	}},
+	{field: "secrets", prefix: "invalid secrets configuration", check: func(cfg *Config) error { return validateSecrets(cfg.Secrets) }},
+	{field: "trust_certs", prefix: "invalid trust_certs", check: validateTrustCerts},
+	{field: "extra_build_flags", prefix: "invalid extra flags", check: func(cfg *Config) error {
+		return validateExtraFlags(&Config{ExtraBuildFlags: cfg.ExtraBuildFlags})
+	}},
+	{field: "extra_push_flags", prefix: "invalid extra flags", check: func(cfg *Config) error {
+		return validateExtraFlags(&Config{ExtraPushFlags: cfg.ExtraPushFlags, Daemonless: cfg.Daemonless, EncryptionRecipients: cfg.EncryptionRecipients})
+	}},
+	{field: "bundle", prefix: "invalid bundle", check: validateBundle},
+	{field: "labels", check: func(cfg *Config) error {
+		for key := range cfg.Labels {
+			if err := validateLabelKey(key); err != nil {
+				return fmt.Errorf("invalid label key '%s': %v", key, err)
+			}
+		}
+		return nil
+	}},
+})
+
+// bundleValidators check the configuration of a release that imports a
+// bundle.
+var bundleValidators = concatValidators(policyValidators, pushGateValidators, []validator{
+	{field: "bundle", prefix: "invalid bundle", check: validateBundle},
+})
+
+// timeoutValidator returns the validator of the duration option key.
+func timeoutValidator(key, prefix string, value func(cfg *Config) string) validator {
+	return validator{field: key, prefix: prefix, check: func(cfg *Config) error { return validateTimeout(key, value(cfg)) }}
+}
+
+// resourceValidator returns the validator of the resource option field,
+// checked on the options sub returns.
+func resourceValidator(field string, sub func(cfg *Config) *Config) validator {
+	return validator{field: field, prefix: "invalid resource configuration", check: func(cfg *Config) error {
+		return validateResourceConfig(sub(cfg))
+	}}
+}
+
+func concatValidators(tables ...[]validator) []validator {
+	var all []validator
+	for _, table := range tables {
+		all = append(all, table...)
+	}
+	return all
+}
+
+// runValidators runs validators in order. It returns the response failing
+// the execution on the first error, and nil when the configuration is
+// valid.
+func runValidators(cfg *Config, validators []validator) *plugin.ExecuteResponse {
+	for _, v := range validators {
+		err := v.check(cfg)
+		if err == nil {
+			continue
+		}
+		msg := err.Error()
+		if v.prefix != "" {
+			msg = fmt.Sprintf("%s: %v", v.prefix, err)
+		}
+		return &plugin.ExecuteResponse{Success: false, Error: msg}
+	}
+	return nil
+}
+
+// addValidatorErrors reports the error of every validator under its field.
+// failed holds the fields that already have an error and is updated.
+func addValidatorErrors(vb *helpers.ValidationBuilder, cfg *Config, validators []validator, failed map[string]bool) {
+	for _, v := range validators {
+		if failed[v.field] || (v.requires != "" && failed[v.requires]) {
+			continue
+		}
+		if err := v.check(cfg); err != nil {
+			vb.AddError(v.field, err.Error())
+			failed[v.field] = true
+		}
+	}
+}
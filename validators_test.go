@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestValidatorsReportedByExecuteAndValidate(t *testing.T) {
+	tests := []struct {
+		option     string
+		value      any
+		wantPrefix string
+	}{
+		{"push_retries", -1, "invalid push_retries: "},
+		{"push_retry_backoff", "soon", "invalid push_retry_backoff: "},
+		{"registry_type", "nexus", "invalid registry_type: "},
+		{"push_timeout", "0s", "invalid timeouts: "},
+		{"cpuset", "all", "invalid resource configuration: "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.option, func(t *testing.T) {
+			config := map[string]any{"image": "myapp", tt.option: tt.value}
+			p := &DockerPlugin{executor: &MockCommandExecutor{}}
+			resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+				Hook:    plugin.HookPostPublish,
+				Config:  config,
+				Context: plugin.ReleaseContext{Version: "1.0.0"},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Success || !strings.HasPrefix(resp.Error, tt.wantPrefix) {
+				t.Errorf("expected an error starting with %q, got %+v", tt.wantPrefix, resp)
+			}
+
+			vresp, err := p.Validate(context.Background(), config)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if vresp.Valid || !slices.ContainsFunc(vresp.Errors, func(e plugin.ValidationError) bool { return e.Field == tt.option }) {
+				t.Errorf("expected an error for %s, got %+v", tt.option, vresp.Errors)
+			}
+		})
+	}
+}
+
+func TestValidateSkipsDependentValidators(t *testing.T) {
+	p := &DockerPlugin{}
+	resp, err := p.Validate(context.Background(), map[string]any{"registry": "ghcr.io"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var image []string
+	for _, e := range resp.Errors {
+		if e.Field == "image" {
+			image = append(image, e.Message)
+		}
+	}
+	if len(image) != 1 || image[0] != "Docker image name is required" {
+		t.Errorf("expected only the missing image to be reported, got %v", image)
+	}
+}
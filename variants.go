@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/helpers"
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// variantNamePattern matches variant names, such as alpine or bookworm-slim.
+var variantNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// variantKeys are the options a variant may set. The other options are
+// shared by every variant.
+var variantKeys = []string{"name", "dockerfile", "context", "target", "build_args", "tags", "suffix", "default"}
+
+// variantKey carries the name of a variant into its configuration, where
+// it keys the variant's entry of version_manifest.
+const variantKey = "variant"
+
+// variantTags returns the tag templates of a variant: its tags, or the
+// release tags with suffix appended, latest becoming the suffix alone. The
+// default variant also keeps the release tags.
+func variantTags(templates []string, suffix string, isDefault bool) []string {
+	var tags []string
+	if isDefault {
+		tags = slices.Clone(templates)
+	}
+	for _, template := range templates {
+		if template == "latest" {
+			tags = append(tags, strings.TrimLeft(suffix, "-._"))
+			continue
+		}
+		tags = append(tags, template+suffix)
+	}
+	return tags
+}
+
+// variantDefinitions returns the names and configurations of the variants:
+// the shared options, overridden by the dockerfile, context, target and
+// build args of the variant, and tagged with its tags. Tags default to the
+// release tags suffixed with -<name>, e.g. {{version}}-alpine and alpine;
+// like latest, the floating alpine is not written by prereleases of
+// version unless latest_on_prerelease is set.
+func variantDefinitions(raw map[string]any, version string) ([]string, []map[string]any, error) {
+	items, ok := raw["variants"].([]any)
+	if !ok {
+		return nil, nil, fmt.Errorf("variants must be a list of variants")
+	}
+	if len(items) == 0 {
+		return nil, nil, fmt.Errorf("variants is empty")
+	}
+	if _, ok := raw["channel_tags"]; ok {
+		return nil, nil, fmt.Errorf("variants cannot be combined with channel_tags")
+	}
+
+	if err := checkParallel(raw, "variants_parallel"); err != nil {
+		return nil, nil, err
+	}
+
+	shared := maps.Clone(raw)
+	delete(shared, "variants")
+	delete(shared, "variants_parallel")
+	parser := helpers.NewConfigParser(raw)
+	templates := tagTemplates(&Config{Tags: parser.GetStringSlice("tags", nil)})
+	if version != "" && versionChannel(version) != stableChannel && !parser.GetBool("latest_on_prerelease", false) {
+		templates = slices.DeleteFunc(slices.Clone(templates), func(tag string) bool { return tag == "latest" })
+		if len(templates) == 0 {
+			templates = []string{"{{version}}"}
+		}
+	}
+
+	names := make([]string, 0, len(items))
+	definitions := make([]map[string]any, 0, len(items))
+	writers := make(map[string]string)
+	defaultVariant := ""
+	for i, item := range items {
+		entry, ok := item.(map[string]any)
+		if !ok {
+			return nil, nil, fmt.Errorf("variants[%d] is not an object", i)
+		}
+		for _, key := range sortedKeys(entry) {
+			if !slices.Contains(variantKeys, key) {
+				return nil, nil, fmt.Errorf("variants[%d]: option %s cannot be set per variant (use %s)", i, key, strings.Join(variantKeys, ", "))
+			}
+		}
+		name, _ := entry["name"].(string)
+		if !variantNamePattern.MatchString(name) {
+			return nil, nil, fmt.Errorf("variants[%d]: invalid name %q: use lowercase letters, digits, '.', '_' and '-'", i, name)
+		}
+		if slices.Contains(names, name) {
+			return nil, nil, fmt.Errorf("variant %s is defined twice", name)
+		}
+		isDefault, _ := entry["default"].(bool)
+		if isDefault {
+			if defaultVariant != "" {
+				return nil, nil, fmt.Errorf("variants %s and %s are both default", defaultVariant, name)
+			}
+			defaultVariant = name
+		}
+
+		tags := helpers.NewConfigParser(entry).GetStringSlice("tags", nil)
+		if len(tags) == 0 {
+			suffix := "-" + name
+			if s, ok := entry["suffix"].(string); ok && s != "" {
+				suffix = s
+			}
+			tags = variantTags(templates, suffix, isDefault)
+		} else if _, ok := entry["suffix"]; ok {
+			return nil, nil, fmt.Errorf("variant %s sets both tags and suffix", name)
+		}
+		for _, tag := range tags {
+			if other, ok := writers[tag]; ok {
+				return nil, nil, fmt.Errorf("variants %s and %s both write tag %q", other, name, tag)
+			}
+			writers[tag] = name
+		}
+
+		variant := maps.Clone(entry)
+		delete(variant, "name")
+		delete(variant, "suffix")
+		delete(variant, "default")
+		tagList := make([]any, len(tags))
+		for j, tag := range tags {
+			tagList[j] = tag
+		}
+		variant["tags"] = tagList
+		variant[variantKey] = name
+
+		names = append(names, name)
+		definitions = append(definitions, overlayDefinition(shared, variant))
+	}
+	return names, definitions, nil
+}
+
+// executeVariants releases each variant of the image as if it were
+// configured alone, like the definitions of images. The outputs of each
+// variant are reported in variants.
+func (p *DockerPlugin) executeVariants(ctx context.Context, req plugin.ExecuteRequest) (*plugin.ExecuteResponse, error) {
+	names, definitions, err := variantDefinitions(req.Config, req.Context.Version)
+	if err != nil {
+		return &plugin.ExecuteResponse{
+			Success: false,
+			Error:   fmt.Sprintf("invalid variants: %v", err),
+		}, nil
+	}
+	parallel, _ := req.Config["variants_parallel"].(bool)
+	return p.executeDefinitions(ctx, req, "variants", names, definitions, parallel)
+}
+
+// validateVariants validates each variant as if it were configured alone.
+// Findings are reported under variants[i].
+func (p *DockerPlugin) validateVariants(ctx context.Context, config map[string]any) (*plugin.ValidateResponse, error) {
+	_, definitions, err := variantDefinitions(config, "")
+	return p.validateDefinitions(ctx, "variants", definitions, err)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestVariantDefinitions(t *testing.T) {
+	raw := map[string]any{
+		"image":      "myorg/myapp",
+		"build_args": map[string]any{"APP_ENV": "production"},
+		"variants": []any{
+			map[string]any{"name": "debian", "default": true},
+			map[string]any{"name": "alpine", "dockerfile": "Dockerfile.alpine", "build_args": map[string]any{"BASE": "alpine:3.20"}},
+			map[string]any{"name": "distroless", "target": "distroless", "tags": []any{"{{version}}-static"}},
+		},
+	}
+	names, definitions, err := variantDefinitions(raw, "1.2.0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(names, []string{"debian", "alpine", "distroless"}) {
+		t.Errorf("unexpected names %v", names)
+	}
+	tags := func(definition map[string]any) []string {
+		var tags []string
+		for _, tag := range definition["tags"].([]any) {
+			tags = append(tags, tag.(string))
+		}
+		return tags
+	}
+	if got := tags(definitions[0]); !slices.Equal(got, []string{"{{version}}", "latest", "{{version}}-debian", "debian"}) {
+		t.Errorf("unexpected default variant tags %v", got)
+	}
+	if got := tags(definitions[1]); !slices.Equal(got, []string{"{{version}}-alpine", "alpine"}) {
+		t.Errorf("unexpected alpine tags %v", got)
+	}
+	if got := tags(definitions[2]); !slices.Equal(got, []string{"{{version}}-static"}) {
+		t.Errorf("unexpected distroless tags %v", got)
+	}
+	alpine := definitions[1]
+	if alpine["dockerfile"] != "Dockerfile.alpine" || alpine["image"] != "myorg/myapp" {
+		t.Errorf("expected the alpine dockerfile over the shared options, got %v", alpine)
+	}
+	if args := alpine["build_args"].(map[string]any); args["BASE"] != "alpine:3.20" || args["APP_ENV"] != "production" {
+		t.Errorf("expected merged build args, got %v", args)
+	}
+	for _, key := range []string{"name", "default", "variants"} {
+		if _, ok := definitions[0][key]; ok {
+			t.Errorf("expected %s to be removed from the definition", key)
+		}
+	}
+
+	_, definitions, err = variantDefinitions(raw, "1.3.0-rc.1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := tags(definitions[1]); !slices.Equal(got, []string{"{{version}}-alpine"}) {
+		t.Errorf("expected a prerelease not to move alpine, got %v", got)
+	}
+}
+
+func TestVariantDefinitionsErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		variants any
+		extra    map[string]any
+		wantErr  string
+	}{
+		{"not a list", "alpine", nil, "must be a list"},
+		{"invalid name", []any{map[string]any{"name": "Alpine"}}, nil, "invalid name"},
+		{"duplicate", []any{map[string]any{"name": "alpine"}, map[string]any{"name": "alpine"}}, nil, "defined twice"},
+		{"two defaults", []any{map[string]any{"name": "alpine", "default": true}, map[string]any{"name": "debian", "default": true}}, nil, "both default"},
+		{"shared option", []any{map[string]any{"name": "alpine", "push": false}}, nil, "cannot be set per variant"},
+		{"tags and suffix", []any{map[string]any{"name": "alpine", "tags": []any{"a"}, "suffix": "-a"}}, nil, "both tags and suffix"},
+		{"same tag", []any{map[string]any{"name": "alpine", "tags": []any{"slim"}}, map[string]any{"name": "debian", "tags": []any{"slim"}}}, nil, `both write tag "slim"`},
+		{"channel tags", []any{map[string]any{"name": "alpine"}}, map[string]any{"channel_tags": map[string]any{}}, "channel_tags"},
+		{"parallel audit", []any{map[string]any{"name": "alpine"}}, map[string]any{"variants_parallel": true, "audit_file": "audit.json"}, "audit_file cannot be combined with variants_parallel"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := map[string]any{"image": "myapp", "variants": tt.variants}
+			for key, value := range tt.extra {
+				raw[key] = value
+			}
+			_, _, err := variantDefinitions(raw, "1.0.0")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestExecuteVariants(t *testing.T) {
+	mock := &MockCommandExecutor{}
+	p := &DockerPlugin{executor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"image": "myapp",
+			"push":  false,
+			"variants": []any{
+				map[string]any{"name": "debian", "default": true},
+				map[string]any{"name": "alpine", "dockerfile": "Dockerfile.alpine", "target": "runtime"},
+			},
+		},
+		Context: plugin.ReleaseContext{Version: "1.2.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("expected success, got error: %s", resp.Error)
+	}
+	if len(mock.RunCalls) != 2 {
+		t.Fatalf("expected 2 builds, got %v", mock.RunCalls)
+	}
+	debian, alpine := mock.RunCalls[0].Args, mock.RunCalls[1].Args
+	for _, ref := range []string{"myapp:1.2.0", "myapp:latest", "myapp:1.2.0-debian", "myapp:debian"} {
+		if !containsArg(debian, "-t", ref) {
+			t.Errorf("expected debian build tagged %s, got %v", ref, debian)
+		}
+	}
+	if !containsArg(alpine, "-f", "Dockerfile.alpine") || !containsArg(alpine, "--target", "runtime") ||
+		!containsArg(alpine, "-t", "myapp:1.2.0-alpine") || !containsArg(alpine, "-t", "myapp:alpine") || containsArg(alpine, "-t", "myapp:latest") {
+		t.Errorf("unexpected alpine build %v", alpine)
+	}
+	variants, ok := resp.Outputs["variants"].([]map[string]any)
+	if !ok || len(variants) != 2 {
+		t.Errorf("expected outputs of each variant, got %v", resp.Outputs["variants"])
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// VersionManifestEntry records the latest release of one image.
+type VersionManifestEntry struct {
+	Version    string    `json:"version"`
+	Digest     string    `json:"digest"`
+	Tags       []string  `json:"tags"`
+	ReleasedAt time.Time `json:"released_at"`
+}
+
+// versionManifest is the content of version_manifest: the latest release
+// of every image written to it, keyed by repository, so that several
+// images of a monorepo can share one file. The images released through
+// variants are recorded in Variants, by repository and variant name.
+type versionManifest struct {
+	Images   map[string]VersionManifestEntry            `json:"images"`
+	Variants map[string]map[string]VersionManifestEntry `json:"variants,omitempty"`
+}
+
+// validateVersionManifest checks version_manifest.
+func validateVersionManifest(cfg *Config) error {
+	if cfg.VersionManifest == "" {
+		return nil
+	}
+	if err := validatePath(cfg.VersionManifest); err != nil {
+		return fmt.Errorf("invalid version_manifest: %v", err)
+	}
+	return nil
+}
+
+// readVersionManifest returns the recorded images; a missing file has none.
+func readVersionManifest(path string) (*versionManifest, error) {
+	manifest := &versionManifest{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		manifest.Images = map[string]VersionManifestEntry{}
+		return manifest, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if manifest.Images == nil {
+		manifest.Images = map[string]VersionManifestEntry{}
+	}
+	return manifest, nil
+}
+
+// updateVersionManifest records the release of repository, or of its
+// variant, in version_manifest, replacing the entry of its previous
+// release. Entries of other images and variants are kept.
+func updateVersionManifest(cfg *Config, repository string, entry VersionManifestEntry) error {
+	defer lockStateFile(cfg.VersionManifest)()
+	manifest, err := readVersionManifest(cfg.VersionManifest)
+	if err != nil {
+		return err
+	}
+	if cfg.variant != "" {
+		if manifest.Variants == nil {
+			manifest.Variants = map[string]map[string]VersionManifestEntry{}
+		}
+		if manifest.Variants[repository] == nil {
+			manifest.Variants[repository] = map[string]VersionManifestEntry{}
+		}
+		manifest.Variants[repository][cfg.variant] = entry
+	} else {
+		manifest.Images[repository] = entry
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(cfg.VersionManifest); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(cfg.VersionManifest, append(data, '\n'), 0o644)
+}
+
+// recordVersion writes the pushed release to version_manifest. The release
+// is already published, so a failure is returned as a warning.
+func (p *DockerPlugin) recordVersion(ctx context.Context, cfg *Config, repository, version string, outputs *Outputs) string {
+	if cfg.VersionManifest == "" || len(outputs.Refs) == 0 {
+		return ""
+	}
+	started := time.Now()
+	digest := outputs.Digest
+	var err error
+	if digest == "" {
+		digest, err = p.resolveDigest(ctx, outputs.Refs[0])
+	}
+	if err == nil {
+		err = updateVersionManifest(cfg, repository, VersionManifestEntry{
+			Version:    version,
+			Digest:     digest,
+			Tags:       outputs.Tags,
+			ReleasedAt: time.Now().UTC(),
+		})
+	}
+	outputs.stage("version_manifest", started, err)
+	if err != nil {
+		return fmt.Sprintf("failed to update version manifest: %v", err)
+	}
+	return ""
+}
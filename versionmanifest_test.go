@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRecordVersionKeepsOtherImages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deploy", "images.json")
+	cfg := &Config{VersionManifest: path}
+	p := &DockerPlugin{executor: &MockCommandExecutor{}}
+
+	base := &Outputs{Refs: []string{"ghcr.io/myorg/base:1.0.0"}, Tags: []string{"1.0.0"}, Digest: "sha256:base"}
+	if w := p.recordVersion(context.Background(), cfg, "ghcr.io/myorg/base", "1.0.0", base); w != "" {
+		t.Fatalf("unexpected warning: %s", w)
+	}
+	app := &Outputs{Refs: []string{"ghcr.io/myorg/app:2.1.0", "ghcr.io/myorg/app:latest"}, Tags: []string{"2.1.0", "latest"}, Digest: "sha256:app"}
+	if w := p.recordVersion(context.Background(), cfg, "ghcr.io/myorg/app", "2.1.0", app); w != "" {
+		t.Fatalf("unexpected warning: %s", w)
+	}
+	app.Digest = "sha256:app2"
+	if w := p.recordVersion(context.Background(), cfg, "ghcr.io/myorg/app", "2.2.0", app); w != "" {
+		t.Fatalf("unexpected warning: %s", w)
+	}
+
+	manifest, err := readVersionManifest(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(manifest.Images) != 2 {
+		t.Fatalf("expected two images, got %v", manifest.Images)
+	}
+	if entry := manifest.Images["ghcr.io/myorg/base"]; entry.Version != "1.0.0" || entry.Digest != "sha256:base" {
+		t.Errorf("expected the base image to be kept, got %+v", entry)
+	}
+	if entry := manifest.Images["ghcr.io/myorg/app"]; entry.Version != "2.2.0" || entry.Digest != "sha256:app2" || len(entry.Tags) != 2 {
+		t.Errorf("expected the app entry to be replaced, got %+v", entry)
+	}
+}
+
+func TestRecordVersionWarnsOnInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "images.json")
+	if err := os.WriteFile(path, []byte("not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	outputs := &Outputs{Refs: []string{"myapp:1.0.0"}, Digest: "sha256:abc"}
+	p := &DockerPlugin{executor: &MockCommandExecutor{}}
+	w := p.recordVersion(context.Background(), &Config{VersionManifest: path}, "myapp", "1.0.0", outputs)
+	if !strings.Contains(w, "failed to update version manifest") {
+		t.Errorf("expected a warning, got %q", w)
+	}
+	if len(outputs.Stages) != 1 || outputs.Stages[0].Status != stageFailed {
+		t.Errorf("expected a failed version_manifest stage, got %+v", outputs.Stages)
+	}
+}
+
+func TestRecordVersionParallelImages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "images.json")
+	cfg := &Config{VersionManifest: path}
+	p := &DockerPlugin{executor: &MockCommandExecutor{}}
+
+	const images = 32
+	var wg sync.WaitGroup
+	for i := 0; i < images; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			repository := fmt.Sprintf("ghcr.io/myorg/app%d", i)
+			outputs := &Outputs{Refs: []string{repository + ":1.0.0"}, Tags: []string{"1.0.0"}, Digest: "sha256:app"}
+			if w := p.recordVersion(context.Background(), cfg, repository, "1.0.0", outputs); w != "" {
+				t.Errorf("unexpected warning: %s", w)
+			}
+		}()
+	}
+	wg.Wait()
+
+	manifest, err := readVersionManifest(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(manifest.Images) != images {
+		t.Errorf("expected %d images, got %d", images, len(manifest.Images))
+	}
+}
+
+func TestRecordVersionVariants(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "images.json")
+	_, definitions, err := variantDefinitions(map[string]any{
+		"image":            "ghcr.io/myorg/myapp",
+		"version_manifest": path,
+		"variants":         []any{map[string]any{"name": "debian", "default": true}, map[string]any{"name": "alpine"}},
+	}, "1.2.0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	p := &DockerPlugin{executor: &MockCommandExecutor{}}
+	for _, definition := range definitions {
+		cfg := p.parseConfig(definition)
+		outputs := &Outputs{Refs: []string{"ghcr.io/myorg/myapp:1.2.0-" + cfg.variant}, Tags: []string{"1.2.0-" + cfg.variant}, Digest: "sha256:" + cfg.variant}
+		if w := p.recordVersion(context.Background(), cfg, "ghcr.io/myorg/myapp", "1.2.0", outputs); w != "" {
+			t.Fatalf("unexpected warning: %s", w)
+		}
+	}
+
+	manifest, err := readVersionManifest(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	variants := manifest.Variants["ghcr.io/myorg/myapp"]
+	if len(variants) != 2 {
+		t.Fatalf("expected both variants, got %v", manifest.Variants)
+	}
+	for _, name := range []string{"debian", "alpine"} {
+		if entry := variants[name]; entry.Digest != "sha256:"+name || entry.Tags[0] != "1.2.0-"+name {
+			t.Errorf("expected the %s entry, got %+v", name, entry)
+		}
+	}
+	if len(manifest.Images) != 0 {
+		t.Errorf("expected no image entries, got %v", manifest.Images)
+	}
+}